@@ -0,0 +1,526 @@
+// Package client is a Go client for the go-spring articles API.
+//
+//	c := client.New("http://localhost:8080")
+//	article, err := c.Create(ctx, client.CreateArticleRequest{Title: "Hello", Desc: "...", Content: "..."})
+//	if errors.Is(err, client.ErrBadRequest) { ... }
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	mathrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client talks to one go-spring server. The zero value is not usable; call New.
+type Client struct {
+	BaseURL    string       // e.g. http://localhost:8080, without a trailing slash
+	HTTPClient *http.Client // http.DefaultClient if nil
+	UserAgent  string
+
+	// HTTP Basic credentials, sent when Username is set (needed for /admin routes)
+	Username string
+	Password string
+
+	// A key of the server's SIGNING_KEYS_FILE; when KeyID is set requests
+	// are signed with it instead of carrying Basic credentials
+	KeyID     string
+	KeySecret string
+
+	Retry RetryPolicy
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:   strings.TrimRight(baseURL, "/"),
+		UserAgent: "go-spring-client",
+		Retry:     DefaultRetryPolicy,
+	}
+}
+
+// RetryPolicy controls how failed requests are retried. Requests are retried
+// on network errors, 429 Too Many Requests and 5xx responses, waiting a
+// random time up to MinBackoff, 2*MinBackoff, 4*MinBackoff... (capped at
+// MaxBackoff) between attempts. A Retry-After header on the response is
+// honored instead; if it asks for longer than MaxBackoff the error is
+// returned without waiting. POST requests carry an Idempotency-Key header
+// that stays the same across retries, so the server creates at most one
+// article.
+type RetryPolicy struct {
+	MaxRetries int // 0 disables retries
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 3, MinBackoff: 250 * time.Millisecond, MaxBackoff: 10 * time.Second}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey makes POST requests made with ctx use key instead of a
+// generated one, so that a caller repeating an operation (e.g. after a crash)
+// is not applied twice.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// Article statuses
+const (
+	StatusDraft     = "draft"
+	StatusPublished = "published"
+)
+
+// Article is an article as the server shows it. A server with HASHIDS_SALT
+// set sends opaque string IDs: those go to PublicID and ID stays 0, so use
+// the ByKey methods with such servers.
+type Article struct {
+	ID            int          `json:"-"`
+	PublicID      string       `json:"-"`
+	Title         string       `json:"title"`
+	Slug          string       `json:"slug"`
+	UID           string       `json:"uid,omitempty"` // set by servers with a ULID or UUIDv7 ID_STRATEGY
+	Workspace     string       `json:"workspace,omitempty"`
+	Desc          string       `json:"desc"`
+	Content       string       `json:"content"`
+	Created       time.Time    `json:"created"`
+	Updated       time.Time    `json:"updated"`
+	Status        string       `json:"status"`
+	Published     time.Time    `json:"published,omitzero"`
+	SourceURL     string       `json:"source_url,omitempty"`
+	Categories    []string     `json:"categories,omitempty"`
+	Tags          []string     `json:"tags,omitempty"`
+	Pinned        bool         `json:"pinned"`
+	Featured      bool         `json:"featured"`
+	FeaturedOrder int          `json:"featured_order,omitempty"`
+	Attachments   []Attachment `json:"attachments,omitempty"`
+	CoverImage    *CoverImage  `json:"cover_image,omitempty"`
+}
+
+// Article without its JSON methods
+type articleFields Article
+
+func (a Article) MarshalJSON() ([]byte, error) {
+	var id any = a.ID
+	if a.PublicID != "" {
+		id = a.PublicID
+	}
+	return json.Marshal(struct {
+		ID any `json:"id"`
+		articleFields
+	}{id, articleFields(a)})
+}
+
+// UnmarshalJSON reads a number "id" into ID and a string one into PublicID
+func (a *Article) UnmarshalJSON(data []byte) error {
+	v := struct {
+		ID json.RawMessage `json:"id"`
+		*articleFields
+	}{articleFields: (*articleFields)(a)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch {
+	case len(v.ID) == 0:
+		return nil
+	case v.ID[0] == '"':
+		return json.Unmarshal(v.ID, &a.PublicID)
+	}
+	return json.Unmarshal(v.ID, &a.ID)
+}
+
+type Attachment struct {
+	ID          int       `json:"id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Created     time.Time `json:"created"`
+}
+
+type CoverImage struct {
+	AttachmentID int    `json:"attachment_id,omitempty"`
+	URL          string `json:"url"`
+	SourceURL    string `json:"source_url,omitempty"`
+}
+
+type CreateArticleRequest struct {
+	Title    string `json:"title"`
+	Desc     string `json:"desc"`
+	Content  string `json:"content"`
+	Status   string `json:"status,omitempty"` // published if empty
+	Pinned   bool   `json:"pinned,omitempty"`
+	Featured bool   `json:"featured,omitempty"`
+
+	Tags []string `json:"tags,omitempty"`
+}
+
+// UpdateArticleRequest changes only the fields that are set
+type UpdateArticleRequest struct {
+	Title    string `json:"title,omitempty"`
+	Desc     string `json:"desc,omitempty"`
+	Content  string `json:"content,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Pinned   *bool  `json:"pinned,omitempty"`
+	Featured *bool  `json:"featured,omitempty"`
+
+	Tags []string `json:"tags,omitempty"` // replace the tags, if any are set
+}
+
+// ListOptions selects a page of articles in ID order. With both fields
+// empty, ListArticles returns every article (pinned first) in one page.
+type ListOptions struct {
+	PageSize  int    // default 20, max 100
+	PageToken string // ArticlePage.NextPageToken of the previous page
+}
+
+type ArticlePage struct {
+	Articles      []Article `json:"articles"`
+	NextPageToken string    `json:"next_page_token,omitempty"` // empty on the last page
+}
+
+// Errors that an *APIError matches with errors.Is, by status code
+var (
+	ErrBadRequest   = errors.New("bad request")  // 400, e.g. validation failed
+	ErrUnauthorized = errors.New("unauthorized") // 401 and 403
+	ErrNotFound     = errors.New("not found")    // 404
+	ErrServer       = errors.New("server error") // 5xx
+	ErrRateLimited  = errors.New("rate limited") // 429
+)
+
+// Error codes of APIError.Code, stable across releases unlike the messages.
+// Errors without a code of their own have the status text in upper snake
+// case, e.g. NOT_FOUND.
+const (
+	CodeBadRequest       = "BAD_REQUEST"
+	CodeValidationFailed = "VALIDATION_FAILED" // see APIError.Fields
+	CodeInvalidBody      = "INVALID_BODY"
+	CodeInvalidID        = "INVALID_ID"
+	CodeInvalidParameter = "INVALID_PARAMETER"
+	CodeInvalidURL       = "INVALID_URL"
+	CodeInvalidFile      = "INVALID_FILE"
+	CodeFileTooLarge     = "FILE_TOO_LARGE"
+	CodeUnsupportedType  = "UNSUPPORTED_MEDIA_TYPE"
+	CodeUnreadablePage   = "UNREADABLE_PAGE"
+	CodeImageFailed      = "IMAGE_PROCESSING_FAILED"
+	CodeChecksumMismatch = "CHECKSUM_MISMATCH"
+
+	CodeAuthenticationRequired = "AUTHENTICATION_REQUIRED"
+	CodeInvalidCredentials     = "INVALID_CREDENTIALS"
+	CodeInvalidSignature       = "INVALID_SIGNATURE"
+	CodeForbidden              = "FORBIDDEN"
+	CodeCaptchaRequired        = "CAPTCHA_REQUIRED"
+	CodeCaptchaInvalid         = "CAPTCHA_INVALID"
+	CodeSignedURLExpired       = "SIGNED_URL_EXPIRED"
+	CodeSignedURLInvalid       = "SIGNED_URL_INVALID"
+
+	CodeNotFound           = "NOT_FOUND"
+	CodeArticleNotFound    = "ARTICLE_NOT_FOUND"
+	CodeAttachmentNotFound = "ATTACHMENT_NOT_FOUND"
+	CodeUploadNotFound     = "UPLOAD_NOT_FOUND"
+	CodeJobNotFound        = "JOB_NOT_FOUND"
+	CodeUserNotFound       = "USER_NOT_FOUND"
+	CodeWorkspaceNotFound  = "WORKSPACE_NOT_FOUND"
+	CodeMethodNotAllowed   = "METHOD_NOT_ALLOWED"
+
+	CodeConflict            = "CONFLICT"
+	CodeRequestInProgress   = "REQUEST_IN_PROGRESS"
+	CodeRateLimited         = "RATE_LIMITED"
+	CodeQuotaExceeded       = "QUOTA_EXCEEDED"
+	CodeRangeNotSatisfiable = "RANGE_NOT_SATISFIABLE"
+
+	CodeInternal       = "INTERNAL_SERVER_ERROR"
+	CodeUpstreamFailed = "UPSTREAM_FAILED"
+	CodeUnavailable    = "SERVICE_UNAVAILABLE"
+	CodeStoreTimeout   = "STORE_TIMEOUT"
+)
+
+// ErrorCode returns the code of an *APIError in err's chain, or ""
+func ErrorCode(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
+
+// APIError is returned for responses with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Code       string        // e.g. CodeArticleNotFound; empty from servers that predate codes
+	Message    string        // the server's error text
+	Fields     []FieldError  // the invalid fields of a 400, if the server named them
+	RetryAfter time.Duration // from the Retry-After header, if any
+	Attempts   int           // number of requests made, including retries
+}
+
+// FieldError is one invalid field of a request
+type FieldError struct {
+	Field   string `json:"field"`   // JSON name, e.g. title
+	Rule    string `json:"rule"`    // e.g. required, max, oneof
+	Message string `json:"message"` // e.g. "title is required"
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("go-spring: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrServer:
+		return e.StatusCode >= 500
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// ListArticles returns one page of articles, or all of them with zero options
+func (c *Client) ListArticles(ctx context.Context, opts ListOptions) (ArticlePage, error) {
+	query := url.Values{}
+	if opts.PageSize > 0 {
+		query.Set("page_size", strconv.Itoa(opts.PageSize))
+	}
+	if opts.PageToken != "" {
+		query.Set("page_token", opts.PageToken)
+	}
+	if len(query) == 0 {
+		var page ArticlePage
+		err := c.do(ctx, http.MethodGet, "/articles", nil, &page.Articles)
+		return page, err
+	}
+	var page ArticlePage
+	err := c.do(ctx, http.MethodGet, "/articles?"+query.Encode(), nil, &page)
+	return page, err
+}
+
+// Articles iterates over every article in ID order, fetching pageSize at a time
+func (c *Client) Articles(ctx context.Context, pageSize int) iter.Seq2[Article, error] {
+	return func(yield func(Article, error) bool) {
+		opts := ListOptions{PageSize: pageSize}
+		for {
+			page, err := c.ListArticles(ctx, opts)
+			if err != nil {
+				yield(Article{}, err)
+				return
+			}
+			for _, article := range page.Articles {
+				if !yield(article, nil) {
+					return
+				}
+			}
+			if page.NextPageToken == "" {
+				return
+			}
+			opts.PageToken = page.NextPageToken
+		}
+	}
+}
+
+func (c *Client) Get(ctx context.Context, id int) (Article, error) {
+	return c.GetByKey(ctx, strconv.Itoa(id))
+}
+
+// GetByKey gets an article by its PublicID or UID
+func (c *Client) GetByKey(ctx context.Context, key string) (Article, error) {
+	var article Article
+	err := c.do(ctx, http.MethodGet, "/articles/"+url.PathEscape(key), nil, &article)
+	return article, err
+}
+
+// GetBySlug gets an article by its slug, which may have any letters
+func (c *Client) GetBySlug(ctx context.Context, slug string) (Article, error) {
+	var article Article
+	err := c.do(ctx, http.MethodGet, "/articles/by-slug/"+url.PathEscape(slug), nil, &article)
+	return article, err
+}
+
+func (c *Client) Create(ctx context.Context, req CreateArticleRequest) (Article, error) {
+	var article Article
+	err := c.do(ctx, http.MethodPost, "/articles", req, &article)
+	return article, err
+}
+
+func (c *Client) Update(ctx context.Context, id int, req UpdateArticleRequest) (Article, error) {
+	return c.UpdateByKey(ctx, strconv.Itoa(id), req)
+}
+
+// UpdateByKey updates an article by its PublicID or UID
+func (c *Client) UpdateByKey(ctx context.Context, key string, req UpdateArticleRequest) (Article, error) {
+	var article Article
+	err := c.do(ctx, http.MethodPut, "/articles/"+url.PathEscape(key), req, &article)
+	return article, err
+}
+
+func (c *Client) Delete(ctx context.Context, id int) error {
+	return c.DeleteByKey(ctx, strconv.Itoa(id))
+}
+
+// DeleteByKey deletes an article by its PublicID or UID
+func (c *Client) DeleteByKey(ctx context.Context, key string) error {
+	return c.do(ctx, http.MethodDelete, "/articles/"+url.PathEscape(key), nil, nil)
+}
+
+// Send a JSON request, retrying as c.Retry allows, and decode the data
+// field of the response envelope into out
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	header := http.Header{}
+	header.Set("Accept", "application/json")
+	if body != nil {
+		header.Set("Content-Type", "application/json")
+	}
+	if c.UserAgent != "" {
+		header.Set("User-Agent", c.UserAgent)
+	}
+	if method == http.MethodPost {
+		key, _ := ctx.Value(idempotencyKey{}).(string)
+		if key == "" {
+			key = rand.Text()
+		}
+		header.Set("Idempotency-Key", key)
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, method, path, header, data)
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			defer resp.Body.Close()
+			return decodeData(resp.Body, out)
+		}
+
+		var apiErr *APIError
+		if err == nil {
+			text, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+			apiErr = &APIError{
+				StatusCode: resp.StatusCode,
+				Message:    strings.TrimSpace(string(text)),
+				RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+				Attempts:   attempt,
+			}
+			decodeErrorBody(resp.Header, text, apiErr)
+			err = apiErr
+		}
+		if ctx.Err() != nil || attempt > c.Retry.MaxRetries {
+			return err
+		}
+		if apiErr != nil && apiErr.StatusCode != http.StatusTooManyRequests && apiErr.StatusCode < 500 {
+			return err
+		}
+
+		wait := c.Retry.backoff(attempt)
+		if apiErr != nil && apiErr.RetryAfter > 0 {
+			if apiErr.RetryAfter > c.Retry.MaxBackoff {
+				return err
+			}
+			wait = apiErr.RetryAfter
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, header http.Header, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header.Clone()
+	switch {
+	case c.KeyID != "":
+		SignRequest(req, body, c.KeyID, c.KeySecret)
+	case c.Username != "":
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return httpClient.Do(req)
+}
+
+// Full jitter: a random wait up to the exponential backoff for the attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	limit := p.MinBackoff << min(attempt-1, 30)
+	if limit <= 0 || limit > p.MaxBackoff {
+		limit = p.MaxBackoff
+	}
+	if limit <= 0 {
+		return 0
+	}
+	return mathrand.N(limit) + 1
+}
+
+// Parse a Retry-After header, in seconds or as an HTTP date
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// Take the message, code and invalid fields from a JSON error body
+func decodeErrorBody(header http.Header, text []byte, apiErr *APIError) {
+	if !strings.HasPrefix(header.Get("Content-Type"), "application/json") {
+		return
+	}
+	var body struct {
+		Error  string       `json:"error"`
+		Code   string       `json:"code"`
+		Fields []FieldError `json:"fields"`
+	}
+	if json.Unmarshal(text, &body) == nil && body.Error != "" {
+		apiErr.Message, apiErr.Code, apiErr.Fields = body.Error, body.Code, body.Fields
+	}
+}
+
+// Decode the data field of the response envelope into out
+func decodeData(r io.Reader, out any) error {
+	if out == nil {
+		return nil
+	}
+	envelope := struct {
+		Data json.RawMessage `json:"data"`
+	}{}
+	if err := json.NewDecoder(r).Decode(&envelope); err != nil {
+		return fmt.Errorf("go-spring: decode response: %w", err)
+	}
+	if len(envelope.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
@@ -0,0 +1,246 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer answers with the responses of respond in turn, the last one
+// repeating, and records the requests
+type testServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func newTestServer(t *testing.T, respond ...func(w http.ResponseWriter, r *http.Request)) *testServer {
+	t.Helper()
+	s := &testServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		n := len(s.requests)
+		s.mu.Unlock()
+		respond[min(n, len(respond))-1](w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testServer) attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+func (s *testServer) request(i int) *http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[i]
+}
+
+func status(code int, header ...string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i+1 < len(header); i += 2 {
+			w.Header().Set(header[i], header[i+1])
+		}
+		w.WriteHeader(code)
+	}
+}
+
+func created(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(`{"success":true,"data":{"id":7,"title":"Hello"}}`))
+}
+
+// A client that retries quickly
+func newTestClient(url string) *Client {
+	c := New(url)
+	c.Retry = RetryPolicy{MaxRetries: 3, MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	return c
+}
+
+var createRequest = CreateArticleRequest{Title: "Hello", Desc: "d", Content: "c"}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{MinBackoff: 10 * time.Millisecond, MaxBackoff: 100 * time.Millisecond}
+	for attempt := 1; attempt <= 70; attempt++ {
+		limit := min(p.MinBackoff<<min(attempt-1, 30), p.MaxBackoff)
+		for range 20 {
+			if wait := p.backoff(attempt); wait <= 0 || wait > limit {
+				t.Fatalf("attempt %d: waited %v, want up to %v", attempt, wait, limit)
+			}
+		}
+	}
+	if wait := (RetryPolicy{}).backoff(1); wait != 0 {
+		t.Errorf("zero policy waited %v", wait)
+	}
+	if wait := (RetryPolicy{MinBackoff: time.Hour, MaxBackoff: time.Second}).backoff(1); wait > time.Second {
+		t.Errorf("waited %v past MaxBackoff", wait)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for header, want := range map[string]time.Duration{
+		"":                              0,
+		"0":                             0,
+		"3":                             3 * time.Second,
+		"-5":                            0,
+		"soon":                          0,
+		"Wed, 21 Oct 2015 07:28:00 GMT": 0, // in the past
+	} {
+		if got := retryAfter(header); got != want {
+			t.Errorf("%q: %v, want %v", header, got, want)
+		}
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := retryAfter(date); got < 58*time.Second || got > time.Minute {
+		t.Errorf("%q: %v, want about a minute", date, got)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	srv := newTestServer(t, status(http.StatusServiceUnavailable), status(http.StatusTooManyRequests), created)
+	article, err := newTestClient(srv.URL).Create(context.Background(), createRequest)
+	if err != nil || article.ID != 7 || article.Title != "Hello" {
+		t.Fatalf("%+v, %v", article, err)
+	}
+	if n := srv.attempts(); n != 3 {
+		t.Errorf("%d attempts, want 3", n)
+	}
+
+	// POST retries repeat the Idempotency-Key
+	key := srv.request(0).Header.Get("Idempotency-Key")
+	for i := range srv.attempts() {
+		if got := srv.request(i).Header.Get("Idempotency-Key"); key == "" || got != key {
+			t.Errorf("attempt %d: Idempotency-Key %q, want %q", i+1, got, key)
+		}
+	}
+
+	// Each request gets its own key unless the caller sets one
+	srv = newTestServer(t, created)
+	c := newTestClient(srv.URL)
+	c.Create(context.Background(), createRequest)
+	c.Create(context.Background(), createRequest)
+	c.Create(WithIdempotencyKey(context.Background(), "import-42"), createRequest)
+	keys := []string{srv.request(0).Header.Get("Idempotency-Key"), srv.request(1).Header.Get("Idempotency-Key"), srv.request(2).Header.Get("Idempotency-Key")}
+	if keys[0] == keys[1] || keys[2] != "import-42" {
+		t.Errorf("keys %q", keys)
+	}
+
+	// Other methods send none
+	srv = newTestServer(t, created)
+	newTestClient(srv.URL).Get(context.Background(), 7)
+	if key := srv.request(0).Header.Get("Idempotency-Key"); key != "" {
+		t.Errorf("GET with Idempotency-Key %q", key)
+	}
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	srv := newTestServer(t, status(http.StatusBadGateway))
+	_, err := newTestClient(srv.URL).Get(context.Background(), 1)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Attempts != 4 || !errors.Is(err, ErrServer) {
+		t.Errorf("error %v", err)
+	}
+	if n := srv.attempts(); n != 4 {
+		t.Errorf("%d attempts, want 4", n)
+	}
+
+	// MaxRetries 0 sends one request
+	srv = newTestServer(t, status(http.StatusBadGateway))
+	c := newTestClient(srv.URL)
+	c.Retry.MaxRetries = 0
+	c.Get(context.Background(), 1)
+	if n := srv.attempts(); n != 1 {
+		t.Errorf("%d attempts without retries", n)
+	}
+
+	// Network errors are retried too
+	srv = newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		conn, _, _ := http.NewResponseController(w).Hijack()
+		conn.Close()
+	}, created)
+	if _, err := newTestClient(srv.URL).Get(context.Background(), 7); err != nil {
+		t.Errorf("after a dropped connection: %v", err)
+	}
+}
+
+func TestNoRetryOnClientErrors(t *testing.T) {
+	for code, target := range map[int]error{
+		http.StatusBadRequest:   ErrBadRequest,
+		http.StatusUnauthorized: ErrUnauthorized,
+		http.StatusForbidden:    ErrUnauthorized,
+		http.StatusNotFound:     ErrNotFound,
+		http.StatusConflict:     nil,
+	} {
+		srv := newTestServer(t, status(code), created)
+		_, err := newTestClient(srv.URL).Create(context.Background(), createRequest)
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != code || apiErr.Attempts != 1 || target != nil && !errors.Is(err, target) {
+			t.Errorf("%d: error %v", code, err)
+		}
+		if n := srv.attempts(); n != 1 {
+			t.Errorf("%d: %d attempts", code, n)
+		}
+	}
+
+	// The code and fields of a JSON error body
+	srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"success":false,"error":"title is required","code":"VALIDATION_FAILED","fields":[{"field":"title","rule":"required","message":"title is required"}]}`))
+	})
+	_, err := newTestClient(srv.URL).Create(context.Background(), CreateArticleRequest{})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "title is required" || ErrorCode(err) != CodeValidationFailed ||
+		len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "title" {
+		t.Errorf("error %+v", apiErr)
+	}
+}
+
+func TestRetryAfterHonored(t *testing.T) {
+	// A Retry-After within MaxBackoff is waited for
+	srv := newTestServer(t, status(http.StatusTooManyRequests, "Retry-After", "1"), created)
+	c := newTestClient(srv.URL)
+	c.Retry.MaxBackoff = 2 * time.Second
+	start := time.Now()
+	if _, err := c.Get(context.Background(), 7); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want a second", elapsed)
+	}
+
+	// A longer one ends the retries at once
+	srv = newTestServer(t, status(http.StatusServiceUnavailable, "Retry-After", "120"), created)
+	start = time.Now()
+	_, err := newTestClient(srv.URL).Get(context.Background(), 7)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != 2*time.Minute || apiErr.Attempts != 1 {
+		t.Errorf("error %+v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %v", elapsed)
+	}
+
+	// Cancelling the context stops the wait
+	srv = newTestServer(t, status(http.StatusServiceUnavailable))
+	c = newTestClient(srv.URL)
+	c.Retry = RetryPolicy{MaxRetries: 3, MinBackoff: time.Hour, MaxBackoff: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if _, err := c.Get(ctx, 7); !errors.Is(err, ErrServer) {
+		t.Errorf("error %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %v", elapsed)
+	}
+}
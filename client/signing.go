@@ -0,0 +1,27 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SignRequest signs a request with a key of the server's SIGNING_KEYS_FILE,
+// setting its Authorization header. body must be what the request sends.
+// The signature is good once and for five minutes, so a request that is
+// sent again must be signed again; Client does that on every attempt.
+func SignRequest(req *http.Request, body []byte, keyID, secret string) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := rand.Text()
+	sum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s", req.Method, req.URL.RequestURI(), hex.EncodeToString(sum[:]), timestamp, nonce)
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	req.Header.Set("Authorization", fmt.Sprintf("HMAC-SHA256 KeyId=%s,Timestamp=%s,Nonce=%s,Signature=%s", keyID, timestamp, nonce, signature))
+}
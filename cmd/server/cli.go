@@ -0,0 +1,709 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"go-spring/internal/activity"
+	"go-spring/internal/anonymize"
+	"go-spring/internal/config"
+	"go-spring/internal/handlers"
+	"go-spring/internal/model"
+	"go-spring/internal/notify"
+	"go-spring/internal/store"
+	"go-spring/internal/webpush"
+	"go-spring/server"
+	"go-spring/spring"
+)
+
+// Command is a subcommand of the go-spring binary. Like the route table,
+// the command table drives both dispatch and the help text; with the flag
+// package it stands in for cobra, which would be a new module dependency.
+type Command struct {
+	Name     string
+	Summary  string
+	Run      func(args []string) error
+	Commands []Command // subcommands, e.g. user add
+}
+
+var commands []Command
+
+// Settings from the environment; the serve flags override some of them
+var appConfig = config.Load()
+
+// Assigned in init because the help command refers to the table
+func init() {
+	commands = []Command{
+		{Name: "serve", Summary: "Run the API server (default)", Run: runServe},
+		{Name: "export", Summary: "Export all data to a file or stdout", Run: runExport},
+		{Name: "import", Summary: "Import articles from a file", Run: runImport},
+		{Name: "seed", Summary: "Add generated sample articles", Run: runSeed},
+		{Name: "backup", Summary: "Write a timestamped export archive and prune old ones", Run: runBackup},
+		{Name: "user", Summary: "Manage operator accounts", Commands: []Command{
+			{Name: "add", Summary: "Add a user; the password is read from stdin", Run: runUserAdd},
+			{Name: "list", Summary: "List users", Run: runUserList},
+			{Name: "notify", Summary: "Set a user's email address and notification events", Run: runUserNotify},
+		}},
+		{Name: "migrate", Summary: "Upgrade the data store, or copy it to another backend with -to", Run: runMigrate},
+		{Name: "compact", Summary: "Rewrite the data store with the current STORE_COMPRESSION and data key", Run: runCompact},
+		{Name: "keys", Summary: "Manage the data keys of ENCRYPTION_KEYS_FILE", Commands: []Command{
+			{Name: "add", Summary: "Add a data key that encrypts from the next start on", Run: runKeysAdd},
+			{Name: "rewrap", Summary: "Wrap the data keys anew with the current version of KMS_KEY", Run: runKeysRewrap},
+		}},
+		{Name: "push", Summary: "Manage Web Push notifications", Commands: []Command{
+			{Name: "keys", Summary: "Generate a VAPID key pair for VAPID_PRIVATE_KEY", Run: runPushKeys},
+		}},
+		{Name: "anonymize", Summary: "Scrub personal data from a copy of the data store for staging", Run: runAnonymize},
+		{Name: "client", Summary: "Manage articles on a running server", Commands: clientCommands},
+		{Name: "help", Summary: "Show help", Run: runHelp},
+	}
+}
+
+// Dispatch the command line; no arguments runs the server
+func runCommand(args []string) error {
+	if len(args) == 0 || (strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "--help") {
+		return runServe(args)
+	}
+	list, path := commands, "go-spring"
+	for {
+		if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+			printCommands(os.Stdout, path, list)
+			return nil
+		}
+		i := slices.IndexFunc(list, func(c Command) bool { return c.Name == args[0] })
+		if i < 0 {
+			printCommands(os.Stderr, path, list)
+			return fmt.Errorf("unknown command %q", args[0])
+		}
+		cmd := list[i]
+		path, args = path+" "+cmd.Name, args[1:]
+		if cmd.Run != nil {
+			if err := cmd.Run(args); !errors.Is(err, flag.ErrHelp) {
+				return err
+			}
+			return nil
+		}
+		list = cmd.Commands
+	}
+}
+
+// List commands with their summaries lined up after the longest name
+func printCommands(w io.Writer, path string, list []Command) {
+	width := 0
+	for _, cmd := range list {
+		width = max(width, len(cmd.Name))
+	}
+	fmt.Fprintf(w, "Usage: %s <command>\n\nCommands:\n", path)
+	for _, cmd := range list {
+		fmt.Fprintf(w, "  %-*s  %s\n", width, cmd.Name, cmd.Summary)
+	}
+}
+
+// Flag set for a command; -h prints usage and the flags
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: go-spring %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Compress and encrypt content as the server would, for a command that
+// opens stores itself
+func useStoreSettings(stores ...store.DataStore) error {
+	opts, err := handlers.ContentOptions(appConfig)
+	if err != nil {
+		return err
+	}
+	for _, st := range stores {
+		store.SetContentOptions(st, opts)
+	}
+	return nil
+}
+
+// Load the data store for a command that works offline
+func openStore() (*handlers.App, error) {
+	return handlers.OpenStore(appConfig)
+}
+
+// Load the data store for a command that only reads it, which works while
+// the server is running
+func openStoreReadOnly() (*handlers.App, error) {
+	return handlers.OpenStoreReadOnly(appConfig)
+}
+
+func runServe(args []string) error {
+	fs := newFlagSet("serve", "[-addr :8080] [-grpc-addr :9090]")
+	fs.StringVar(&appConfig.Addr, "addr", appConfig.Addr, "HTTP listen address (ADDR)")
+	fs.StringVar(&appConfig.GRPCAddr, "grpc-addr", appConfig.GRPCAddr, `gRPC listen address or "off" (GRPC_ADDR)`)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return serve()
+}
+
+// Run the API server until SIGINT, SIGTERM or an admin's POST
+// /admin/shutdown or /admin/drain. The components are wired by
+// the application context, which starts them in dependency order and stops
+// them in reverse.
+func serve() error {
+	ac := spring.NewApplicationContext()
+	ac.Supply(appConfig)
+	ac.Provide(func(cfg config.Config) (store.DataStore, error) {
+		st, err := store.Open(cfg.Store)
+		if errors.Is(err, store.ErrLocked) && cfg.StoreLocked == config.StoreLockedReadOnly {
+			log.Printf("Warning: %v, starting read-only", err)
+			st, err = store.OpenReadOnly(cfg.Store)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("open data store: %w", err)
+		}
+		return st, nil
+	})
+	ac.Provide(server.New)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := ac.Start(ctx); err != nil {
+		return err
+	}
+	srv, err := spring.Get[*server.Server](ac)
+	if err != nil {
+		return err
+	}
+	srv.PrintRoutes()
+
+	failed := make(chan error, 1)
+	go func() { failed <- srv.Wait() }()
+	select {
+	case <-ctx.Done():
+	case <-srv.ShutdownRequested():
+	case err = <-failed:
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), appConfig.ShutdownTimeout)
+	defer cancel()
+	return errors.Join(err, ac.Shutdown(shutdownCtx))
+}
+
+func runExport(args []string) error {
+	fs := newFlagSet("export", "[-format zip|ndjson] [-o file]")
+	format := fs.String("format", "zip", "zip (articles, attachments, metadata) or ndjson (articles only)")
+	out := fs.String("o", "", "output file; default a timestamped .zip, or stdout for ndjson")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	app, err := openStoreReadOnly()
+	if err != nil {
+		return err
+	}
+	defer app.CloseStore()
+
+	now := time.Now().UTC()
+	if *out == "" && *format == "zip" {
+		*out = handlers.ExportArchiveName(now)
+	}
+	w := io.Writer(os.Stdout)
+	if *out != "" && *out != "-" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	bw := bufio.NewWriter(w)
+	switch *format {
+	case "zip":
+		err = app.WriteExportArchive(context.Background(), bw, now)
+	case "ndjson":
+		err = app.WriteArticlesNDJSON(context.Background(), bw, func() {})
+	default:
+		return fmt.Errorf("unknown format %q (want zip or ndjson)", *format)
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil && w != os.Stdout {
+		fmt.Fprintf(os.Stderr, "Exported %d articles to %s\n", app.ArticleCount(), *out)
+	}
+	return err
+}
+
+func runImport(args []string) error {
+	fs := newFlagSet("import", "[-dry-run] [-format json|ndjson|csv|wxr] file")
+	dryRun := fs.Bool("dry-run", false, "validate and report without storing anything")
+	format := fs.String("format", "", "file format; detected from the extension by default")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected one file to import")
+	}
+	file := fs.Arg(0)
+	if *format == "" {
+		*format = importFormat(file)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	app, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer app.CloseStore()
+
+	var report handlers.ImportReport
+	noun := "articles"
+	switch *format {
+	case "json", "ndjson":
+		report = app.ImportJSONFile(context.Background(), data, *dryRun)
+	case "csv":
+		if *dryRun {
+			return errors.New("-dry-run is not supported for CSV files")
+		}
+		var text string
+		if text, err = handlers.DecodeImportText(data, ""); err == nil {
+			report, err = app.ImportCSVText(context.Background(), text, handlers.DetectDelimiter(text), nil)
+		}
+	case "wxr":
+		noun = "posts"
+		report, err = app.ImportWXRFile(context.Background(), data, *dryRun)
+	default:
+		return fmt.Errorf("unknown format %q (want json, ndjson, csv or wxr)", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, e := range report.Errors {
+		fmt.Fprintf(os.Stderr, "%s:%d: %s\n", file, e.Line, e.Message)
+	}
+	fmt.Println(handlers.ImportMessage(report, noun))
+	if *dryRun {
+		return nil
+	}
+	return app.Save()
+}
+
+// Guess an import format from the file extension
+func importFormat(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv", ".tsv", ".txt":
+		return "csv"
+	case ".xml", ".wxr":
+		return "wxr"
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	}
+	return "json"
+}
+
+func runSeed(args []string) error {
+	fs := newFlagSet("seed", "[-n 100] [-seed 0] [-reset]")
+	n := fs.Int("n", 100, "number of articles to generate")
+	seed := fs.Uint64("seed", 0, "random seed for repeatable data; 0 picks one")
+	reset := fs.Bool("reset", false, "delete all existing articles first")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *n < 1 {
+		return errors.New("-n must be at least 1")
+	}
+	app, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer app.CloseStore()
+	if *reset {
+		app.ResetArticles()
+	}
+
+	app.SeedStore(handlers.GenerateArticles(*n, *seed))
+	if err := app.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("Generated %d articles, %d in total\n", *n, app.ArticleCount())
+	return nil
+}
+
+func runBackup(args []string) error {
+	fs := newFlagSet("backup", "[-dir backups] [-keep 7]")
+	dir := fs.String("dir", "backups", "directory for the archives")
+	keep := fs.Int("keep", 7, "number of archives to keep; 0 keeps all")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	app, err := openStoreReadOnly()
+	if err != nil {
+		return err
+	}
+	defer app.CloseStore()
+	if err := writeBackup(app, *dir, *keep); err != nil {
+		app.Notify(context.Background(), notify.Notification{
+			Event: notify.EventBackupFailed, Error: err.Error(), Detail: *dir,
+		})
+		return err
+	}
+	return nil
+}
+
+// Write an archive to dir and delete all but the newest keep
+func writeBackup(app *handlers.App, dir string, keep int) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	path := filepath.Join(dir, handlers.ExportArchiveName(now))
+	file, err := os.Create(path + ".partial")
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(file)
+	err = app.WriteExportArchive(context.Background(), bw, now)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(path+".partial", path)
+	}
+	if err != nil {
+		os.Remove(path + ".partial")
+		return err
+	}
+	fmt.Println("Backup written to", path)
+
+	// Archive names sort by time, oldest first
+	if keep > 0 {
+		old, _ := filepath.Glob(filepath.Join(dir, "go-spring-export-*.zip"))
+		slices.Sort(old)
+		for len(old) > keep {
+			if err := os.Remove(old[0]); err != nil {
+				return err
+			}
+			fmt.Println("Removed", old[0])
+			old = old[1:]
+		}
+	}
+	return nil
+}
+
+func runUserAdd(args []string) error {
+	fs := newFlagSet("user add", "[-role admin|editor] username")
+	role := fs.String("role", model.RoleAdmin, "admin or editor; only admins can use /admin routes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a username")
+	}
+	app, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer app.CloseStore()
+
+	// Read from stdin so the password stays out of shell history
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return errors.New("no password given on stdin")
+	}
+	user, err := app.AddUser(fs.Arg(0), strings.TrimRight(password, "\r\n"), *role)
+	if err != nil {
+		return err
+	}
+	if err := app.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("Added %s %s\n", user.Role, user.Username)
+	return nil
+}
+
+func runUserList(args []string) error {
+	app, err := openStoreReadOnly()
+	if err != nil {
+		return err
+	}
+	defer app.CloseStore()
+	for _, u := range app.Users() {
+		fmt.Printf("%-24s %-8s %s %s\n", u.Username, u.Role, u.Created.Format(time.DateOnly), u.Email)
+	}
+	return nil
+}
+
+func runUserNotify(args []string) error {
+	fs := newFlagSet("user notify", "[-email addr] [-on event,...] [-off event,...] username")
+	email := fs.String("email", "", "address to send notifications to; \"-\" removes it")
+	on := fs.String("on", "", "events to email about: "+strings.Join(notify.Events, ", "))
+	off := fs.String("off", "", "events not to email about")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a username")
+	}
+	app, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer app.CloseStore()
+
+	var address *string
+	if *email == "-" {
+		address = new(string)
+	} else if *email != "" {
+		address = email
+	}
+	events := map[string]bool{}
+	for _, event := range config.SplitList(*on) {
+		events[event] = true
+	}
+	for _, event := range config.SplitList(*off) {
+		events[event] = false
+	}
+	user, err := app.SetNotifications(fs.Arg(0), address, events)
+	if err != nil {
+		return err
+	}
+	if err := app.Save(); err != nil {
+		return err
+	}
+	var enabled []string
+	for _, event := range notify.Events {
+		if notify.Wants(user, event) {
+			enabled = append(enabled, event)
+		}
+	}
+	fmt.Printf("%s <%s>: %s\n", user.Username, user.Email, strings.Join(enabled, ", "))
+	return nil
+}
+
+// Without -to, upgrade the configured store to the current format (loading
+// applies pending migrations, saving writes them back). With -to, copy all
+// data from the configured store (or -from) into another one.
+func runMigrate(args []string) error {
+	fs := newFlagSet("migrate", "[-from store] [-to store] [-batch 500]")
+	from := fs.String("from", appConfig.Store, "store to read (STORE)")
+	to := fs.String("to", "", "store to copy into: a .gob path, sqlite:path or postgres://...")
+	batch := fs.Int("batch", handlers.ExportBatchSize, "articles written per batch")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *to == "" {
+		appConfig.Store = *from
+		app, err := openStore()
+		if err != nil {
+			return err
+		}
+		defer app.CloseStore()
+		if err := app.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("%s is at version %d\n", appConfig.Store, len(store.Migrations))
+		return nil
+	}
+
+	if *batch < 1 {
+		return errors.New("-batch must be at least 1")
+	}
+	src, err := store.OpenReadOnly(*from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := store.Open(*to)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	if err := useStoreSettings(src, dst); err != nil {
+		return err
+	}
+
+	// Ctrl-C stops the copy; running it again resumes
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := store.Copy(ctx, src, dst, *batch, os.Stdout); err != nil {
+		return err
+	}
+	fmt.Printf("Copied %s to %s; set STORE=%s to use it\n", *from, *to, *to)
+	return nil
+}
+
+func runCompact(args []string) error {
+	fs := newFlagSet("compact", "[-store store]")
+	location := fs.String("store", appConfig.Store, "store to rewrite (STORE)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	st, err := store.Open(*location)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	if err := useStoreSettings(st); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	before, sized := store.Size(ctx, st)
+	if err := store.Compact(ctx, st); err != nil {
+		return err
+	}
+	if after, ok := store.Size(ctx, st); sized && ok {
+		fmt.Printf("Compacted %s with compression %s: %d -> %d bytes\n", *location, appConfig.StoreCompression, before, after)
+		return nil
+	}
+	fmt.Printf("Compacted %s with compression %s\n", *location, appConfig.StoreCompression)
+	return nil
+}
+
+func runKeysAdd(args []string) error {
+	fs := newFlagSet("keys add", "id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a key id")
+	}
+	if appConfig.EncryptionKeysFile == "" {
+		return errors.New("ENCRYPTION_KEYS_FILE is not set")
+	}
+	if err := handlers.AddEncryptionKey(context.Background(), appConfig, fs.Arg(0)); err != nil {
+		return err
+	}
+	fmt.Printf("Added key %s to %s; restart the server to encrypt with it, and run compact to re-encrypt old content\n",
+		fs.Arg(0), appConfig.EncryptionKeysFile)
+	return nil
+}
+
+func runKeysRewrap(args []string) error {
+	fs := newFlagSet("keys rewrap", "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if appConfig.EncryptionKeysFile == "" {
+		return errors.New("ENCRYPTION_KEYS_FILE is not set")
+	}
+	n, err := handlers.RewrapEncryptionKeys(context.Background(), appConfig)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrapped %d keys of %s anew with %s\n", n, appConfig.EncryptionKeysFile, appConfig.KMSKey)
+	return nil
+}
+
+func runPushKeys(args []string) error {
+	fs := newFlagSet("push keys", "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	private, err := webpush.GenerateVAPID()
+	if err != nil {
+		return err
+	}
+	key, err := webpush.ParseVAPID(private, "")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("VAPID_PRIVATE_KEY=%s\n", private)
+	fmt.Printf("# Public key, which GET /push/key also gives browsers: %s\n", key.PublicKey())
+	return nil
+}
+
+func runAnonymize(args []string) error {
+	fs := newFlagSet("anonymize", "-store store [-activity file] [-patterns file] [-pattern regexp ...]")
+	location := fs.String("store", "", "copy of the store to scrub in place; required, so STORE is never scrubbed by accident")
+	activityFile := fs.String("activity", "", "copy of the ACTIVITY_FILE to scrub too")
+	patternsFile := fs.String("patterns", "", "file of regular expressions, one per line, to redact from articles")
+	var patterns []*regexp.Regexp
+	fs.Func("pattern", "regular expression to redact from articles; repeatable", func(s string) error {
+		re, err := regexp.Compile(s)
+		patterns = append(patterns, re)
+		return err
+	})
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *location == "" {
+		fs.Usage()
+		return errors.New("-store is required")
+	}
+	if *patternsFile != "" {
+		text, err := os.ReadFile(*patternsFile)
+		if err != nil {
+			return err
+		}
+		list, err := anonymize.ParsePatterns(string(text))
+		if err != nil {
+			return fmt.Errorf("%s: %w", *patternsFile, err)
+		}
+		patterns = append(patterns, list...)
+	}
+
+	st, err := store.Open(*location)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	if err := useStoreSettings(st); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	db, err := st.Load(ctx)
+	if err != nil {
+		return err
+	}
+	scrubber := anonymize.New(patterns)
+	report := scrubber.Database(&db)
+	if err := st.Save(ctx, db); err != nil {
+		return err
+	}
+	fmt.Printf("Anonymized %s: %d users, %d workspace members, %d replacements in %d articles\n",
+		*location, report.Users, report.Members, report.Replacements, report.Articles)
+
+	if *activityFile != "" {
+		activityLog, err := activity.Open(*activityFile)
+		if err != nil {
+			return err
+		}
+		activityLog.Rewrite(scrubber.Username, func(title string) string {
+			title, _ = scrubber.Text(title)
+			return title
+		})
+		if err := activityLog.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("Anonymized %s\n", *activityFile)
+	}
+	return nil
+}
+
+func runHelp(args []string) error {
+	return runCommand(append(args, "-h"))
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-spring/internal/config"
+	"go-spring/internal/model"
+)
+
+// Point the commands at a store in a temporary directory, with the files
+// the server keeps beside it turned off, for the length of the test
+func useTestStore(t *testing.T) string {
+	t.Helper()
+	saved := appConfig
+	t.Cleanup(func() { appConfig = saved })
+	dir := t.TempDir()
+	appConfig = config.Load()
+	appConfig.Store = filepath.Join(dir, "articles.gob")
+	appConfig.AttachmentsDir = filepath.Join(dir, "attachments")
+	appConfig.JobsFile = "off"
+	appConfig.AnalyticsFile = "off"
+	appConfig.ActivityFile = "off"
+	appConfig.SearchLogFile = "off"
+	return dir
+}
+
+// Run a command line and return what it printed to stdout
+func runOutput(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	err = runCommand(args)
+	w.Close()
+	return <-output, err
+}
+
+// Give the commands stdin to read
+func useStdin(t *testing.T, text string) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "stdin")
+	os.WriteFile(file, []byte(text), 0o600)
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		f.Close()
+	})
+}
+
+func TestRunCommandHelp(t *testing.T) {
+	out, err := runOutput(t, "-h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cmd := range commands {
+		if !strings.Contains(out, "\n  "+cmd.Name+" ") {
+			t.Errorf("help lacks %s:\n%s", cmd.Name, out)
+		}
+	}
+	// help names a group's subcommands
+	out, err = runOutput(t, "help", "user")
+	if err != nil || !strings.Contains(out, "Usage: go-spring user <command>") || !strings.Contains(out, "  notify ") {
+		t.Errorf("help user: %v\n%s", err, out)
+	}
+
+	if _, err := runOutput(t, "frobnicate"); err == nil || !strings.Contains(err.Error(), "frobnicate") {
+		t.Errorf("unknown command: %v", err)
+	}
+	if _, err := runOutput(t, "user", "frobnicate"); err == nil {
+		t.Error("unknown subcommand: no error")
+	}
+	// a command's -h prints its flags and isn't an error
+	if _, err := runOutput(t, "export", "-h"); err != nil {
+		t.Errorf("export -h: %v", err)
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	dir := useTestStore(t)
+	file := filepath.Join(dir, "articles.ndjson")
+	os.WriteFile(file, []byte(`{"title":"One","desc":"First","content":"Some text"}
+{"title":"","desc":"Missing a title","content":"Some text"}
+{"title":"Two","desc":"Second","content":"More text"}
+`), 0o600)
+
+	out, err := runOutput(t, "import", "-dry-run", file)
+	if err != nil || !strings.Contains(out, "Dry run: would import 2 articles, skip 1") {
+		t.Fatalf("dry run: %v, %q", err, out)
+	}
+	if _, err := os.Stat(appConfig.Store); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("dry run wrote the store: %v", err)
+	}
+	out, err = runOutput(t, "import", file)
+	if err != nil || !strings.Contains(out, "Imported 2 articles, skipped 1") {
+		t.Fatalf("import: %v, %q", err, out)
+	}
+
+	out, err = runOutput(t, "export", "-format", "ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var article model.Article
+		if err := json.Unmarshal([]byte(line), &article); err != nil {
+			t.Fatalf("export line %q: %v", line, err)
+		}
+		titles = append(titles, article.Title)
+	}
+	if strings.Join(titles, ",") != "One,Two" {
+		t.Errorf("exported %v", titles)
+	}
+
+	archive := filepath.Join(dir, "export.zip")
+	if _, err := runOutput(t, "export", "-o", archive); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(archive); err != nil || info.Size() == 0 {
+		t.Errorf("archive: %v", err)
+	}
+	if _, err := runOutput(t, "export", "-format", "csv"); err == nil {
+		t.Error("unknown export format: no error")
+	}
+}
+
+func TestUserCommands(t *testing.T) {
+	useTestStore(t)
+	useStdin(t, "correct horse\n")
+	out, err := runOutput(t, "user", "add", "-role", model.RoleEditor, "alice")
+	if err != nil || out != "Added editor alice\n" {
+		t.Fatalf("user add: %v, %q", err, out)
+	}
+	if _, err := runOutput(t, "user", "add"); err == nil {
+		t.Error("user add without a name: no error")
+	}
+
+	out, err = runOutput(t, "user", "notify", "-email", "alice@example.com", "alice")
+	if err != nil || !strings.HasPrefix(out, "alice <alice@example.com>: ") {
+		t.Errorf("user notify: %v, %q", err, out)
+	}
+	out, err = runOutput(t, "user", "list")
+	if err != nil || !strings.HasPrefix(out, "alice") || !strings.Contains(out, "editor") || !strings.Contains(out, "alice@example.com") {
+		t.Errorf("user list: %v, %q", err, out)
+	}
+
+}
+
+func TestBackupKeepsNewest(t *testing.T) {
+	dir := useTestStore(t)
+	backups := filepath.Join(dir, "backups")
+	// Archives from before, named by the time they were written
+	os.MkdirAll(backups, 0o755)
+	for _, name := range []string{"go-spring-export-20200101-000000.zip", "go-spring-export-20210101-000000.zip"} {
+		os.WriteFile(filepath.Join(backups, name), nil, 0o600)
+	}
+	if _, err := runOutput(t, "backup", "-dir", backups, "-keep", "2"); err != nil {
+		t.Fatal(err)
+	}
+	names, _ := filepath.Glob(filepath.Join(backups, "*"))
+	if len(names) != 2 || filepath.Base(names[0]) != "go-spring-export-20210101-000000.zip" {
+		t.Errorf("kept %v", names)
+	}
+}
+
+func TestSeedCommand(t *testing.T) {
+	useTestStore(t)
+	out, err := runOutput(t, "seed", "-n", "5", "-seed", "1")
+	if err != nil || out != "Generated 5 articles, 5 in total\n" {
+		t.Fatalf("seed: %v, %q", err, out)
+	}
+	if out, _ = runOutput(t, "seed", "-n", "3"); out != "Generated 3 articles, 8 in total\n" {
+		t.Errorf("seed again: %q", out)
+	}
+	if out, _ = runOutput(t, "seed", "-n", "2", "-reset"); out != "Generated 2 articles, 2 in total\n" {
+		t.Errorf("seed -reset: %q", out)
+	}
+	if _, err := runOutput(t, "seed", "-n", "0"); err == nil {
+		t.Error("seed -n 0: no error")
+	}
+}
+
+// The summaries line up however long the longest command name is
+func TestPrintCommandsAligned(t *testing.T) {
+	var b strings.Builder
+	printCommands(&b, "go-spring", commands)
+	column := -1
+	for _, line := range strings.Split(b.String(), "\n") {
+		name, _, ok := strings.Cut(strings.TrimPrefix(line, "  "), " ")
+		if !strings.HasPrefix(line, "  ") || !ok {
+			continue
+		}
+		start := len(line) - len(strings.TrimLeft(line[2+len(name):], " "))
+		if column < 0 {
+			column = start
+		} else if start != column {
+			t.Errorf("summary of %s starts at %d, not %d:\n%s", name, start, column, b.String())
+		}
+	}
+	if column < 0 {
+		t.Fatal("no commands listed")
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go-spring/client"
+	"go-spring/internal/config"
+)
+
+// Subcommands of "go-spring client", which talk to a running server over
+// HTTP instead of opening the data store
+var clientCommands = []Command{
+	{Name: "list", Summary: "List articles", Run: runClientList},
+	{Name: "get", Summary: "Show one article", Run: runClientGet},
+	{Name: "create", Summary: "Create an article", Run: runClientCreate},
+	{Name: "edit", Summary: "Edit an article in $EDITOR", Run: runClientEdit},
+	{Name: "delete", Summary: "Delete an article", Run: runClientDelete},
+}
+
+// Flags shared by the client subcommands
+type clientFlags struct {
+	server    *string
+	username  *string
+	password  *string
+	keyID     *string
+	keySecret *string
+	json      *bool
+}
+
+func addClientFlags(fs *flag.FlagSet) clientFlags {
+	return clientFlags{
+		server:    fs.String("server", config.EnvString("GO_SPRING_URL", "http://localhost:8080"), "server base URL (GO_SPRING_URL)"),
+		username:  fs.String("user", config.EnvString("GO_SPRING_USER", ""), "username for HTTP Basic auth (GO_SPRING_USER)"),
+		password:  fs.String("password", config.EnvString("GO_SPRING_PASSWORD", ""), "password for HTTP Basic auth (GO_SPRING_PASSWORD)"),
+		keyID:     fs.String("key-id", config.EnvString("GO_SPRING_KEY_ID", ""), "signing key to sign requests with instead of Basic auth (GO_SPRING_KEY_ID)"),
+		keySecret: fs.String("key-secret", config.EnvString("GO_SPRING_KEY_SECRET", ""), "secret of the signing key (GO_SPRING_KEY_SECRET)"),
+		json:      fs.Bool("json", false, "print JSON instead of a table"),
+	}
+}
+
+func (f clientFlags) client() *client.Client {
+	c := client.New(*f.server)
+	c.Username, c.Password = *f.username, *f.password
+	c.KeyID, c.KeySecret = *f.keyID, *f.keySecret
+	return c
+}
+
+// Parse flags that take exactly one article ID argument
+func parseClientID(fs *flag.FlagSet, args []string) (int, error) {
+	if err := fs.Parse(args); err != nil {
+		return 0, err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 0, errors.New("expected an article ID")
+	}
+	id, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		return 0, fmt.Errorf("invalid article ID %q", fs.Arg(0))
+	}
+	return id, nil
+}
+
+func runClientList(args []string) error {
+	fs := newFlagSet("client list", "[-server url] [-page-size n] [-json]")
+	flags := addClientFlags(fs)
+	pageSize := fs.Int("page-size", 100, "articles fetched per request")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var list []client.Article
+	for article, err := range flags.client().Articles(context.Background(), *pageSize) {
+		if err != nil {
+			return err
+		}
+		list = append(list, article)
+	}
+	if *flags.json {
+		return printJSON(list)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tUPDATED\tTITLE")
+	for _, a := range list {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", a.ID, a.Status, a.Updated.Local().Format(time.DateTime), a.Title)
+	}
+	return tw.Flush()
+}
+
+func runClientGet(args []string) error {
+	fs := newFlagSet("client get", "[-server url] [-json] id")
+	flags := addClientFlags(fs)
+	id, err := parseClientID(fs, args)
+	if err != nil {
+		return err
+	}
+	article, err := flags.client().Get(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	if *flags.json {
+		return printJSON(article)
+	}
+	printArticle(os.Stdout, article)
+	return nil
+}
+
+func runClientCreate(args []string) error {
+	fs := newFlagSet("client create", "[-server url] [-json] -title title -desc desc [-status draft] [-content text | -file path]")
+	flags := addClientFlags(fs)
+	var req client.CreateArticleRequest
+	fs.StringVar(&req.Title, "title", "", "article title")
+	fs.StringVar(&req.Desc, "desc", "", "short description")
+	fs.StringVar(&req.Content, "content", "", "article content")
+	fs.StringVar(&req.Status, "status", "", "draft or published (default published)")
+	file := fs.String("file", "", `read the content from a file, or "-" for stdin`)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file != "" {
+		data, err := readFileOrStdin(*file)
+		if err != nil {
+			return err
+		}
+		req.Content = string(data)
+	}
+
+	article, err := flags.client().Create(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	if *flags.json {
+		return printJSON(article)
+	}
+	fmt.Printf("Created article %d\n", article.ID)
+	return nil
+}
+
+// The fields of an article that edit writes to the temporary file
+type editableArticle struct {
+	Title    string `json:"title"`
+	Desc     string `json:"desc"`
+	Status   string `json:"status"`
+	Pinned   bool   `json:"pinned"`
+	Featured bool   `json:"featured"`
+	Content  string `json:"content"`
+}
+
+// Open the article as JSON in $EDITOR (vi by default) and save the result;
+// closing the editor without changes leaves the article as it was
+func runClientEdit(args []string) error {
+	fs := newFlagSet("client edit", "[-server url] [-json] id")
+	flags := addClientFlags(fs)
+	id, err := parseClientID(fs, args)
+	if err != nil {
+		return err
+	}
+	c := flags.client()
+	ctx := context.Background()
+	article, err := c.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	before, err := json.MarshalIndent(editableArticle{
+		Title:    article.Title,
+		Desc:     article.Desc,
+		Status:   article.Status,
+		Pinned:   article.Pinned,
+		Featured: article.Featured,
+		Content:  article.Content,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp("", fmt.Sprintf("go-spring-article-%d-*.json", id))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(append(before, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	editor := strings.Fields(config.EnvString("EDITOR", "vi"))
+	cmd := exec.Command(editor[0], append(editor[1:], file.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor: %w", err)
+	}
+	after, err := os.ReadFile(file.Name())
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(bytes.TrimSpace(after), before) {
+		fmt.Println("No changes")
+		return nil
+	}
+	var edited editableArticle
+	if err := json.Unmarshal(after, &edited); err != nil {
+		return fmt.Errorf("edited article is not valid JSON (%w); the article was not changed", err)
+	}
+
+	article, err = c.Update(ctx, id, client.UpdateArticleRequest{
+		Title:    edited.Title,
+		Desc:     edited.Desc,
+		Content:  edited.Content,
+		Status:   edited.Status,
+		Pinned:   &edited.Pinned,
+		Featured: &edited.Featured,
+	})
+	if err != nil {
+		return err
+	}
+	if *flags.json {
+		return printJSON(article)
+	}
+	fmt.Printf("Updated article %d\n", article.ID)
+	return nil
+}
+
+func runClientDelete(args []string) error {
+	fs := newFlagSet("client delete", "[-server url] id")
+	flags := addClientFlags(fs)
+	id, err := parseClientID(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := flags.client().Delete(context.Background(), id); err != nil {
+		return err
+	}
+	if !*flags.json {
+		fmt.Printf("Deleted article %d\n", id)
+	}
+	return nil
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func printArticle(w io.Writer, a client.Article) {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%d\n", a.ID)
+	fmt.Fprintf(tw, "Title:\t%s\n", a.Title)
+	fmt.Fprintf(tw, "Description:\t%s\n", a.Desc)
+	fmt.Fprintf(tw, "Status:\t%s\n", a.Status)
+	fmt.Fprintf(tw, "Created:\t%s\n", a.Created.Local().Format(time.DateTime))
+	fmt.Fprintf(tw, "Updated:\t%s\n", a.Updated.Local().Format(time.DateTime))
+	if len(a.Categories) > 0 {
+		fmt.Fprintf(tw, "Categories:\t%s\n", strings.Join(a.Categories, ", "))
+	}
+	if len(a.Tags) > 0 {
+		fmt.Fprintf(tw, "Tags:\t%s\n", strings.Join(a.Tags, ", "))
+	}
+	if a.Pinned || a.Featured {
+		fmt.Fprintf(tw, "Pinned:\t%t\nFeatured:\t%t\n", a.Pinned, a.Featured)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\n%s\n", a.Content)
+}
+
+func readFileOrStdin(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
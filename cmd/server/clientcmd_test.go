@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-spring/client"
+	"go-spring/internal/handlers"
+	"go-spring/internal/store"
+)
+
+// Serve the API on the test store and return its URL
+func startTestAPI(t *testing.T) string {
+	t.Helper()
+	dir := useTestStore(t)
+	appConfig.SeedArticles = 0
+	appConfig.MiddlewareDisable = []string{"log"}
+	st, err := store.Open(filepath.Join(dir, "api.gob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := handlers.New(appConfig, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(app.Router())
+	t.Cleanup(func() {
+		srv.Close()
+		app.StopBackground(context.Background())
+		st.Close()
+	})
+	return srv.URL
+}
+
+func TestClientCommands(t *testing.T) {
+	url := startTestAPI(t)
+
+	out, err := runOutput(t, "client", "create", "-server", url, "-title", "Hello", "-desc", "First", "-content", "Some text")
+	if err != nil || out != "Created article 1\n" {
+		t.Fatalf("create: %v, %q", err, out)
+	}
+	out, err = runOutput(t, "client", "create", "-server", url, "-json", "-title", "Draft", "-desc", "Second", "-content", "More", "-status", "draft")
+	var created client.Article
+	if err != nil || json.Unmarshal([]byte(out), &created) != nil || created.ID != 2 || created.Status != "draft" {
+		t.Fatalf("create -json: %v, %q", err, out)
+	}
+
+	out, err = runOutput(t, "client", "list", "-server", url)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if err != nil || len(lines) != 3 || !strings.HasPrefix(lines[0], "ID") || !strings.HasSuffix(lines[1], "Hello") {
+		t.Errorf("list: %v\n%s", err, out)
+	}
+	out, err = runOutput(t, "client", "list", "-server", url, "-json", "-page-size", "1")
+	var list []client.Article
+	if err != nil || json.Unmarshal([]byte(out), &list) != nil || len(list) != 2 {
+		t.Errorf("list -json over pages: %v, %q", err, out)
+	}
+
+	out, err = runOutput(t, "client", "get", "-server", url, "1")
+	if err != nil || !strings.Contains(out, "Title:       Hello\n") || !strings.HasSuffix(out, "\nSome text\n") {
+		t.Errorf("get: %v\n%s", err, out)
+	}
+	if _, err := runOutput(t, "client", "get", "-server", url, "x"); err == nil {
+		t.Error("get with a bad ID: no error")
+	}
+	if _, err := runOutput(t, "client", "get", "-server", url, "99"); err == nil {
+		t.Error("get of a missing article: no error")
+	}
+
+	// The editor gets the article as JSON and may change it
+	t.Setenv("EDITOR", "true")
+	if out, err = runOutput(t, "client", "edit", "-server", url, "1"); err != nil || out != "No changes\n" {
+		t.Errorf("edit without changes: %v, %q", err, out)
+	}
+	t.Setenv("EDITOR", `sed -i s/Hello/Goodbye/`)
+	if out, err = runOutput(t, "client", "edit", "-server", url, "1"); err != nil || out != "Updated article 1\n" {
+		t.Errorf("edit: %v, %q", err, out)
+	}
+	if article, err := client.New(url).Get(context.Background(), 1); err != nil || article.Title != "Goodbye" {
+		t.Errorf("after edit: %+v, %v", article, err)
+	}
+	t.Setenv("EDITOR", `sed -i s/{/[/`)
+	if _, err = runOutput(t, "client", "edit", "-server", url, "1"); err == nil || !strings.Contains(err.Error(), "not valid JSON") {
+		t.Errorf("edit to invalid JSON: %v", err)
+	}
+
+	if out, err = runOutput(t, "client", "delete", "-server", url, "2"); err != nil || out != "Deleted article 2\n" {
+		t.Errorf("delete: %v, %q", err, out)
+	}
+	if _, err := client.New(url).Get(context.Background(), 2); err == nil {
+		t.Error("deleted article is still there")
+	}
+}
@@ -0,0 +1,194 @@
+// Package activity keeps what each user did, newest last: the articles
+// they created, changed and deleted. Every entry gets a sequence number,
+// increasing across users, which pages through a user's entries from the
+// newest. A user keeps their latest MaxEntries entries, less those
+// DropBefore drops for their age. The log is kept in memory and written to
+// a gob file by Save, as the analytics counts are.
+package activity
+
+import (
+	"cmp"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// How many entries a user keeps; older ones are dropped
+const MaxEntries = 1000
+
+// Entry is one thing a user did, to an article or to another user
+type Entry struct {
+	Seq       int64
+	Time      time.Time
+	Action    string // the article event type, e.g. article.created, or user.erased
+	ArticleID int
+	Title     string // at the time
+	Subject   string // of an action on a user
+}
+
+// Log holds the entries of every user
+type Log struct {
+	path string
+
+	mutex   sync.Mutex
+	nextSeq int64
+	entries map[string][]Entry // by username, oldest first
+	dirty   bool
+}
+
+// What is written to the file
+type logFile struct {
+	NextSeq int64
+	Entries map[string][]Entry
+}
+
+// Open loads the log from path, or starts empty if it doesn't exist; an
+// empty path keeps it in memory only
+func Open(path string) (*Log, error) {
+	l := &Log{path: path, nextSeq: 1, entries: map[string][]Entry{}}
+	if path == "" {
+		return l, nil
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var data logFile
+	if err := gob.NewDecoder(file).Decode(&data); err != nil {
+		return nil, fmt.Errorf("read activity %s: %w", path, err)
+	}
+	l.nextSeq = max(data.NextSeq, 1)
+	if data.Entries != nil {
+		l.entries = data.Entries
+	}
+	return l, nil
+}
+
+// Add an entry for user, numbering it
+func (l *Log) Add(user string, e Entry) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	e.Seq = l.nextSeq
+	l.nextSeq++
+	list := append(l.entries[user], e)
+	if len(list) > MaxEntries {
+		list = append([]Entry(nil), list[len(list)-MaxEntries:]...)
+	}
+	l.entries[user] = list
+	l.dirty = true
+}
+
+// Page returns up to n of user's entries before the sequence number
+// before, newest first, or the newest ones when before is 0; more is
+// whether older ones remain
+func (l *Log) Page(user string, before int64, n int) (page []Entry, more bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	list := l.entries[user]
+	end := len(list)
+	if before > 0 {
+		for end > 0 && list[end-1].Seq >= before {
+			end--
+		}
+	}
+	for i := end - 1; i >= 0 && len(page) < n; i-- {
+		page = append(page, list[i])
+	}
+	return page, end > len(page)
+}
+
+// DropBefore removes the entries older than before and returns how many
+// there were; a dry run only counts them
+func (l *Log) DropBefore(before time.Time, dryRun bool) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	dropped := 0
+	for user, list := range l.entries {
+		n := 0
+		for n < len(list) && list[n].Time.Before(before) {
+			n++
+		}
+		dropped += n
+		if dryRun || n == 0 {
+			continue
+		}
+		if n == len(list) {
+			delete(l.entries, user)
+		} else {
+			l.entries[user] = append([]Entry(nil), list[n:]...)
+		}
+		l.dirty = true
+	}
+	return dropped
+}
+
+// DeleteUser removes every entry of user and returns how many there were
+func (l *Log) DeleteUser(user string) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	n := len(l.entries[user])
+	if n > 0 {
+		delete(l.entries, user)
+		l.dirty = true
+	}
+	return n
+}
+
+// Rewrite renames every user with rename and every entry's title with
+// title, for an anonymized copy. The entries of users renamed alike are
+// merged.
+func (l *Log) Rewrite(rename func(user string) string, title func(string) string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	entries := make(map[string][]Entry, len(l.entries))
+	for _, user := range slices.Sorted(maps.Keys(l.entries)) {
+		renamed := rename(user)
+		for _, e := range l.entries[user] {
+			e.Title = title(e.Title)
+			entries[renamed] = append(entries[renamed], e)
+		}
+	}
+	for user, list := range entries {
+		slices.SortFunc(list, func(a, b Entry) int { return cmp.Compare(a.Seq, b.Seq) })
+		if len(list) > MaxEntries {
+			entries[user] = list[len(list)-MaxEntries:]
+		}
+	}
+	l.entries = entries
+	l.dirty = true
+}
+
+// Save writes the log to the file, if it changed
+func (l *Log) Save() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.path == "" || !l.dirty {
+		return nil
+	}
+
+	// Replace the file, so an interrupted save never leaves a torn one
+	file, err := os.Create(l.path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(file).Encode(logFile{NextSeq: l.nextSeq, Entries: l.entries}); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(l.path+".tmp", l.path); err != nil {
+		return err
+	}
+	l.dirty = false
+	return nil
+}
@@ -0,0 +1,96 @@
+package activity
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPagesAndPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "activity.gob")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 10, 14, 30, 0, 0, time.UTC)
+	for i := range MaxEntries + 5 {
+		l.Add("bob", Entry{Time: now, Action: "article.updated", ArticleID: i})
+		if i%100 == 0 {
+			l.Add("alice", Entry{Time: now, Action: "article.created", ArticleID: i})
+		}
+	}
+
+	// The oldest entries past MaxEntries are gone
+	page, more := l.Page("bob", 0, 3)
+	if len(page) != 3 || page[0].ArticleID != MaxEntries+4 || page[2].ArticleID != MaxEntries+2 || !more {
+		t.Fatalf("first page %v, more %v", page, more)
+	}
+	page, more = l.Page("bob", page[2].Seq, MaxEntries)
+	if len(page) != MaxEntries-3 || page[len(page)-1].ArticleID != 5 || more {
+		t.Errorf("rest: %d entries, oldest %d, more %v", len(page), page[len(page)-1].ArticleID, more)
+	}
+
+	if err := l.Save(); err != nil {
+		t.Fatal(err)
+	}
+	l, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page, _ := l.Page("alice", 0, 100); len(page) != 11 || page[0].ArticleID != 1000 {
+		t.Errorf("reopened %v", page)
+	}
+	// Numbering goes on from the saved log
+	before := page[0].Seq
+	l.Add("alice", Entry{Time: now, Action: "article.deleted"})
+	if page, _ := l.Page("alice", 0, 1); page[0].Seq <= before {
+		t.Errorf("new entry numbered %d after %d", page[0].Seq, before)
+	}
+}
+
+func TestDropBefore(t *testing.T) {
+	l, _ := Open("")
+	now := time.Date(2026, 3, 10, 14, 30, 0, 0, time.UTC)
+	l.Add("bob", Entry{Time: now.AddDate(0, 0, -10)})
+	l.Add("bob", Entry{Time: now})
+	l.Add("alice", Entry{Time: now.AddDate(0, 0, -20)})
+
+	if n := l.DropBefore(now.AddDate(0, 0, -1), true); n != 2 {
+		t.Errorf("dry run counted %d entries", n)
+	}
+	if page, _ := l.Page("alice", 0, 10); len(page) != 1 {
+		t.Error("dry run dropped entries")
+	}
+	if n := l.DropBefore(now.AddDate(0, 0, -1), false); n != 2 {
+		t.Errorf("dropped %d entries", n)
+	}
+	bob, _ := l.Page("bob", 0, 10)
+	alice, _ := l.Page("alice", 0, 10)
+	if len(bob) != 1 || !bob[0].Time.Equal(now) || len(alice) != 0 {
+		t.Errorf("kept %v and %v", bob, alice)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	l, _ := Open("")
+	now := time.Date(2026, 3, 10, 14, 30, 0, 0, time.UTC)
+	l.Add("Bob", Entry{Time: now, Title: "Mail bob@example.com"})
+	l.Add("alice", Entry{Time: now, Title: "Plain"})
+	l.Add("bob", Entry{Time: now, Title: "Second"})
+
+	names := map[string]string{"Bob": "user1", "bob": "user1", "alice": "user2"}
+	l.Rewrite(func(user string) string { return names[user] }, func(title string) string {
+		return strings.ReplaceAll(title, "bob@example.com", "[redacted]")
+	})
+	page, _ := l.Page("user1", 0, 10)
+	if len(page) != 2 || page[0].Title != "Second" || page[1].Title != "Mail [redacted]" {
+		t.Errorf("user1 has %v", page)
+	}
+	if page, _ := l.Page("Bob", 0, 10); len(page) != 0 {
+		t.Errorf("old name kept %v", page)
+	}
+	if page, _ := l.Page("user2", 0, 10); len(page) != 1 {
+		t.Errorf("user2 has %v", page)
+	}
+}
@@ -0,0 +1,232 @@
+// Package analytics counts article views over time. Each view is added to
+// an hourly and a daily bucket of its article; hourly buckets are kept for
+// HourlyRetention, daily ones for as long as the article exists or until
+// DropDaily drops them. The counts are kept in memory and written to a gob
+// file by Save, which the server calls every minute and when it stops, so a
+// crash loses at most the views since the last save.
+package analytics
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// Interval is the width of a bucket
+type Interval string
+
+const (
+	Hour Interval = "hour"
+	Day  Interval = "day"
+)
+
+// How long hourly buckets are kept
+const HourlyRetention = 14 * 24 * time.Hour
+
+// Width of an interval's buckets
+func (i Interval) Duration() time.Duration {
+	if i == Hour {
+		return time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Point is the number of views in the bucket starting at Time
+type Point struct {
+	Time  time.Time `json:"time"`
+	Views int       `json:"views"`
+}
+
+// Counter holds the view counts of every article, by bucket start in Unix
+// seconds (UTC)
+type Counter struct {
+	path string
+
+	mutex  sync.Mutex
+	hourly map[int]map[int64]int
+	daily  map[int]map[int64]int
+	dirty  bool // changed since the last save
+}
+
+// What is written to the file
+type counterFile struct {
+	Hourly map[int]map[int64]int
+	Daily  map[int]map[int64]int
+}
+
+// Open loads the counts from path, or starts empty if it doesn't exist; an
+// empty path keeps them in memory only
+func Open(path string) (*Counter, error) {
+	c := &Counter{path: path, hourly: map[int]map[int64]int{}, daily: map[int]map[int64]int{}}
+	if path == "" {
+		return c, nil
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var data counterFile
+	if err := gob.NewDecoder(file).Decode(&data); err != nil {
+		return nil, fmt.Errorf("read analytics %s: %w", path, err)
+	}
+	if data.Hourly != nil {
+		c.hourly = data.Hourly
+	}
+	if data.Daily != nil {
+		c.daily = data.Daily
+	}
+	return c, nil
+}
+
+// The start of the bucket of t
+func bucket(t time.Time, interval Interval) int64 {
+	return t.UTC().Truncate(interval.Duration()).Unix()
+}
+
+// Record a view of an article at t
+func (c *Counter) Record(article int, t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	add(c.hourly, article, bucket(t, Hour))
+	add(c.daily, article, bucket(t, Day))
+	c.dirty = true
+}
+
+func add(buckets map[int]map[int64]int, article int, start int64) {
+	if buckets[article] == nil {
+		buckets[article] = map[int64]int{}
+	}
+	buckets[article][start]++
+}
+
+// Series returns a point for every bucket of interval from the one holding
+// from up to the one holding to, with zeros for buckets without views, and
+// their sum
+func (c *Counter) Series(article int, interval Interval, from, to time.Time) (points []Point, total int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	buckets := c.daily
+	if interval == Hour {
+		buckets = c.hourly
+	}
+	step := interval.Duration()
+	for t := from.UTC().Truncate(step); !t.After(to); t = t.Add(step) {
+		views := buckets[article][t.Unix()]
+		points = append(points, Point{Time: t, Views: views})
+		total += views
+	}
+	return points, total
+}
+
+// Total is every view of an article counted so far
+func (c *Counter) Total(article int) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	total := 0
+	for _, views := range c.daily[article] {
+		total += views
+	}
+	return total
+}
+
+// Scores weighs the views in the hourly buckets of every article by their
+// age, halving every halfLife, so that a burst of views today outranks a
+// larger one last week. Articles without views in the hourly buckets get
+// no score.
+func (c *Counter) Scores(now time.Time, halfLife time.Duration) map[int]float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	scores := make(map[int]float64, len(c.hourly))
+	for article, buckets := range c.hourly {
+		for start, views := range buckets {
+			// From the middle of the bucket, the average time of its views
+			age := now.Sub(time.Unix(start, 0).Add(time.Hour / 2))
+			scores[article] += float64(views) * math.Exp2(-max(age, 0).Hours()/halfLife.Hours())
+		}
+	}
+	return scores
+}
+
+// Delete the counts of an article
+func (c *Counter) Delete(article int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.daily[article] != nil || c.hourly[article] != nil {
+		delete(c.daily, article)
+		delete(c.hourly, article)
+		c.dirty = true
+	}
+}
+
+// DropDaily removes the daily buckets that start before before, which
+// Total no longer counts, and returns how many there were; a dry run only
+// counts them
+func (c *Counter) DropDaily(before time.Time, dryRun bool) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cutoff := bucket(before, Day)
+	dropped := 0
+	for article, buckets := range c.daily {
+		for start := range buckets {
+			if start >= cutoff {
+				continue
+			}
+			dropped++
+			if !dryRun {
+				delete(buckets, start)
+				c.dirty = true
+			}
+		}
+		if len(buckets) == 0 {
+			delete(c.daily, article)
+		}
+	}
+	return dropped
+}
+
+// Save drops hourly buckets older than HourlyRetention and writes the
+// counts to the file, if they changed
+func (c *Counter) Save(now time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cutoff := bucket(now.Add(-HourlyRetention), Hour)
+	for article, buckets := range c.hourly {
+		for start := range buckets {
+			if start < cutoff {
+				delete(buckets, start)
+				c.dirty = true
+			}
+		}
+		if len(buckets) == 0 {
+			delete(c.hourly, article)
+		}
+	}
+	if c.path == "" || !c.dirty {
+		return nil
+	}
+
+	// Replace the file, so an interrupted save never leaves a torn one
+	file, err := os.Create(c.path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(file).Encode(counterFile{Hourly: c.hourly, Daily: c.daily}); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(c.path+".tmp", c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,82 @@
+package analytics
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSeriesAndPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "analytics.gob")
+	c, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 10, 14, 30, 0, 0, time.UTC)
+	c.Record(1, now)
+	c.Record(1, now.Add(-time.Hour))
+	c.Record(1, now.Add(-48*time.Hour))
+	c.Record(2, now)
+
+	points, total := c.Series(1, Hour, now.Add(-2*time.Hour), now)
+	if len(points) != 3 || total != 2 || points[0].Views != 0 || points[1].Views != 1 || points[2].Views != 1 {
+		t.Errorf("hourly %v, total %d", points, total)
+	}
+	if !points[2].Time.Equal(time.Date(2026, 3, 10, 14, 0, 0, 0, time.UTC)) {
+		t.Errorf("last bucket starts at %v", points[2].Time)
+	}
+	points, total = c.Series(1, Day, now.AddDate(0, 0, -2), now)
+	if len(points) != 3 || total != 3 || points[0].Views != 1 || points[2].Views != 2 {
+		t.Errorf("daily %v, total %d", points, total)
+	}
+
+	// Hourly buckets past the retention go on save; daily ones stay
+	if err := c.Save(now.Add(HourlyRetention - time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	c.Delete(2)
+	if err := c.Save(now.Add(HourlyRetention - time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := reopened.Total(1); n != 3 {
+		t.Errorf("total after reopening %d, want 3", n)
+	}
+	if n := reopened.Total(2); n != 0 {
+		t.Errorf("deleted article has %d views", n)
+	}
+	if _, total := reopened.Series(1, Hour, now.Add(-48*time.Hour), now); total != 2 {
+		t.Errorf("%d hourly views kept, want the 2 within the retention", total)
+	}
+}
+
+func TestScores(t *testing.T) {
+	c, _ := Open("")
+	now := time.Date(2026, 3, 10, 14, 30, 0, 0, time.UTC)
+	c.Record(1, now)
+	c.Record(2, now.Add(-24*time.Hour))
+	c.Record(2, now.Add(-24*time.Hour))
+	scores := c.Scores(now, 24*time.Hour)
+	// Both count one view now: two views a half-life ago are worth one
+	if scores[1] < 0.99 || scores[1] > 1 || scores[2] < 0.99 || scores[2] > 1.01 {
+		t.Errorf("scores %v", scores)
+	}
+}
+
+func TestDropDaily(t *testing.T) {
+	c, _ := Open("")
+	now := time.Date(2026, 3, 10, 14, 30, 0, 0, time.UTC)
+	c.Record(1, now)
+	c.Record(1, now.AddDate(0, 0, -3))
+	c.Record(2, now.AddDate(0, 0, -5))
+
+	if n := c.DropDaily(now.AddDate(0, 0, -1), true); n != 2 || c.Total(1) != 2 || c.Total(2) != 1 {
+		t.Errorf("dry run counted %d buckets, totals %d and %d", n, c.Total(1), c.Total(2))
+	}
+	if n := c.DropDaily(now.AddDate(0, 0, -1), false); n != 2 || c.Total(1) != 1 || c.Total(2) != 0 {
+		t.Errorf("dropped %d buckets, totals %d and %d", n, c.Total(1), c.Total(2))
+	}
+}
@@ -0,0 +1,131 @@
+// Package anonymize scrubs personal data from a copy of the data, so that
+// production data can be used in staging. Users become user1, user2 and so
+// on in the order they were added, with userN@example.invalid for an email
+// address and no password hash, so no production password signs in to the
+// copy, and saved searches lose their webhooks. Workspace memberships and activity follow the new names. Email
+// addresses in articles, and whatever the given patterns match, are
+// replaced with Redacted.
+package anonymize
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"go-spring/internal/pii"
+	"go-spring/internal/store"
+)
+
+// What the patterns match is replaced with
+const Redacted = pii.Redacted
+
+// Report is what Database changed
+type Report struct {
+	Users        int // renamed
+	Members      int // workspace memberships renamed
+	Articles     int // with something replaced
+	Replacements int // matches replaced in articles
+}
+
+// Scrubber renames users and scrubs text the same way across the data
+// store and the activity log
+type Scrubber struct {
+	patterns []*regexp.Regexp
+	names    map[string]string // new username by lowercased old one
+}
+
+// New returns a scrubber that replaces email addresses and what patterns
+// match
+func New(patterns []*regexp.Regexp) *Scrubber {
+	return &Scrubber{patterns: append([]*regexp.Regexp{pii.Email}, patterns...), names: map[string]string{}}
+}
+
+// ParsePatterns compiles the patterns of a patterns file: one regular
+// expression per line; blank lines and lines starting with # are skipped
+func ParsePatterns(text string) ([]*regexp.Regexp, error) {
+	var list []*regexp.Regexp
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		re, err := regexp.Compile(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		list = append(list, re)
+	}
+	return list, nil
+}
+
+// Username returns the new name of a user. Usernames match regardless of
+// case, as at sign-in; a name seen for the first time gets the next number.
+func (s *Scrubber) Username(name string) string {
+	key := strings.ToLower(name)
+	if renamed, ok := s.names[key]; ok {
+		return renamed
+	}
+	renamed := fmt.Sprintf("user%d", len(s.names)+1)
+	s.names[key] = renamed
+	return renamed
+}
+
+// Text replaces what the patterns match and returns how many matches
+// there were
+func (s *Scrubber) Text(text string) (string, int) {
+	n := 0
+	for _, re := range s.patterns {
+		text = re.ReplaceAllStringFunc(text, func(string) string {
+			n++
+			return Redacted
+		})
+	}
+	return text, n
+}
+
+// Database scrubs db in place
+func (s *Scrubber) Database(db *store.Database) Report {
+	var report Report
+	for i, u := range db.Users {
+		u.Username = s.Username(u.Username)
+		if u.Email != "" {
+			u.Email = u.Username + "@example.invalid"
+		}
+		u.PasswordHash = ""
+		// Staging must not post to production endpoints
+		u.NotifyWebhookURL, u.NotifyWebhookKey = "", ""
+		u.PushSubscriptions = nil
+		u.SavedSearches = slices.Clone(u.SavedSearches)
+		for j := range u.SavedSearches {
+			u.SavedSearches[j].WebhookURL, u.SavedSearches[j].WebhookKey = "", ""
+		}
+		db.Users[i] = u
+		report.Users++
+	}
+	for i, ws := range db.Workspaces {
+		if len(ws.Members) == 0 {
+			continue
+		}
+		members := make(map[string]string, len(ws.Members))
+		// In order, so members without an account are numbered the same every run
+		for _, name := range slices.Sorted(maps.Keys(ws.Members)) {
+			members[s.Username(name)] = ws.Members[name]
+			report.Members++
+		}
+		db.Workspaces[i].Members = members
+	}
+	for i, a := range db.Articles {
+		var n1, n2, n3 int
+		a.Title, n1 = s.Text(a.Title)
+		a.Desc, n2 = s.Text(a.Desc)
+		a.Content, n3 = s.Text(a.Content)
+		if n := n1 + n2 + n3; n > 0 {
+			db.Articles[i] = a
+			report.Articles++
+			report.Replacements += n
+		}
+	}
+	return report
+}
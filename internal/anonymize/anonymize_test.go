@@ -0,0 +1,64 @@
+package anonymize
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"go-spring/internal/model"
+	"go-spring/internal/store"
+)
+
+func TestDatabase(t *testing.T) {
+	db := store.Database{
+		Users: []model.User{
+			{Username: "Alice", Role: model.RoleAdmin, PasswordHash: "pbkdf2-sha256$1$a$b", Email: "alice@corp.example.com",
+				NotifyWebhookURL: "https://hooks.corp.example.com/alice", NotifyWebhookKey: "0123456789abcdef",
+				PushSubscriptions: []model.PushSubscription{{Endpoint: "https://push.example.com/x", P256dh: "k", Auth: "a"}}},
+			{Username: "bob", Role: model.RoleEditor, PasswordHash: "pbkdf2-sha256$1$c$d",
+				SavedSearches: []model.SavedSearch{{ID: 1, Name: "Raft", Query: "raft", WebhookURL: "https://hooks.corp.example.com/x", WebhookKey: "0123456789abcdef"}}},
+		},
+		Workspaces: []model.Workspace{{Slug: "news", Members: map[string]string{"bob": "editor", "alice": "owner", "zed": "viewer"}}},
+		Articles: []model.Article{
+			{ID: 1, Title: "Hello", Desc: "Write to alice@corp.example.com", Content: "Call 040-1234567 or mail bob@corp.example.com"},
+			{ID: 2, Title: "Untouched", Content: "Nothing personal"},
+		},
+	}
+	s := New([]*regexp.Regexp{regexp.MustCompile(`\d{3}-\d{7}`)})
+	report := s.Database(&db)
+
+	if report != (Report{Users: 2, Members: 3, Articles: 1, Replacements: 3}) {
+		t.Errorf("report %+v", report)
+	}
+	alice, bob := db.Users[0], db.Users[1]
+	if alice.Username != "user1" || alice.Email != "user1@example.invalid" || alice.PasswordHash != "" || alice.Role != model.RoleAdmin ||
+		alice.NotifyWebhookURL != "" || alice.NotifyWebhookKey != "" || alice.PushSubscriptions != nil {
+		t.Errorf("alice became %+v", alice)
+	}
+	if bob.Username != "user2" || bob.Email != "" || bob.PasswordHash != "" ||
+		bob.SavedSearches[0].Query != "raft" || bob.SavedSearches[0].WebhookURL != "" || bob.SavedSearches[0].WebhookKey != "" {
+		t.Errorf("bob became %+v", bob)
+	}
+	// Names match regardless of case; members without an account get new names too
+	members := db.Workspaces[0].Members
+	if len(members) != 3 || members["user1"] != "owner" || members["user2"] != "editor" || members["user3"] != "viewer" {
+		t.Errorf("members %v", members)
+	}
+	a := db.Articles[0]
+	if a.Desc != "Write to "+Redacted || strings.Contains(a.Content, "@") || strings.Contains(a.Content, "1234567") {
+		t.Errorf("article 1 is %q / %q", a.Desc, a.Content)
+	}
+	if db.Articles[1].Content != "Nothing personal" {
+		t.Errorf("article 2 is %q", db.Articles[1].Content)
+	}
+}
+
+func TestParsePatterns(t *testing.T) {
+	list, err := ParsePatterns("# phone numbers\n\\d{3}-\\d{7}\n\n  Acme Corp  \n")
+	if err != nil || len(list) != 2 || list[1].String() != "Acme Corp" {
+		t.Errorf("got %v, %v", list, err)
+	}
+	if _, err := ParsePatterns("ok\n(unclosed"); err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Errorf("bad pattern gave %v", err)
+	}
+}
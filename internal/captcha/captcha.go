@@ -0,0 +1,90 @@
+// Package captcha verifies the tokens that hCaptcha and Cloudflare
+// Turnstile widgets hand to a browser once it passes their challenge. Both
+// services answer the same siteverify request.
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Providers
+const (
+	HCaptcha  = "hcaptcha"
+	Turnstile = "turnstile"
+)
+
+// The siteverify endpoints of the providers
+var Endpoints = map[string]string{
+	HCaptcha:  "https://api.hcaptcha.com/siteverify",
+	Turnstile: "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+}
+
+// ErrInvalid is returned for a token the provider rejects: made up,
+// expired or already used
+var ErrInvalid = errors.New("captcha token is invalid")
+
+// Verifier checks tokens with a provider
+type Verifier struct {
+	Provider string // HCaptcha or Turnstile
+	Secret   string // the site's secret key
+	Endpoint string // that of Provider if empty
+	Client   *http.Client
+}
+
+// Verify checks a token, passing the client's IP if known. It returns
+// ErrInvalid if the provider rejects the token, or another error if it
+// couldn't be asked.
+func (v Verifier) Verify(ctx context.Context, token, remoteIP string) error {
+	endpoint := v.Endpoint
+	if endpoint == "" {
+		endpoint = Endpoints[v.Provider]
+	}
+	if endpoint == "" {
+		return fmt.Errorf("unknown captcha provider %q", v.Provider)
+	}
+	form := url.Values{"secret": {v.Secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	client := v.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", v.Provider, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", v.Provider, resp.Status)
+	}
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("%s: %w", v.Provider, err)
+	}
+	if result.Success {
+		return nil
+	}
+	// A wrong secret is our fault, not the client's
+	for _, code := range result.ErrorCodes {
+		if code == "missing-input-secret" || code == "invalid-input-secret" {
+			return fmt.Errorf("%s: %s", v.Provider, code)
+		}
+	}
+	return ErrInvalid
+}
@@ -0,0 +1,42 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	var remoteIP string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		remoteIP = r.Form.Get("remoteip")
+		switch {
+		case r.Form.Get("secret") != "s3cret":
+			w.Write([]byte(`{"success":false,"error-codes":["invalid-input-secret"]}`))
+		case r.Form.Get("response") == "good":
+			w.Write([]byte(`{"success":true}`))
+		default:
+			w.Write([]byte(`{"success":false,"error-codes":["invalid-input-response"]}`))
+		}
+	}))
+	defer srv.Close()
+	v := Verifier{Provider: Turnstile, Secret: "s3cret", Endpoint: srv.URL}
+	ctx := context.Background()
+
+	if err := v.Verify(ctx, "good", "192.0.2.1"); err != nil || remoteIP != "192.0.2.1" {
+		t.Errorf("good token: %v, remote IP %q", err, remoteIP)
+	}
+	if err := v.Verify(ctx, "made up", ""); !errors.Is(err, ErrInvalid) {
+		t.Errorf("bad token: %v", err)
+	}
+	v.Secret = "wrong"
+	if err := v.Verify(ctx, "good", ""); err == nil || errors.Is(err, ErrInvalid) {
+		t.Errorf("wrong secret: %v", err)
+	}
+	if err := (Verifier{Provider: "recaptcha"}).Verify(ctx, "good", ""); err == nil {
+		t.Error("unknown provider accepted")
+	}
+}
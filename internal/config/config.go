@@ -0,0 +1,711 @@
+// Package config reads the server settings from environment variables.
+package config
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Sanitizer policies for submitted content (SANITIZE_MODE)
+const (
+	SanitizePlain = "plain" // strip all tags
+	SanitizeBasic = "basic" // keep simple formatting tags
+	SanitizeFull  = "full"  // keep most HTML, drop scripts and event handlers
+)
+
+// What the PII scanner does with personal data in submitted content (PII_SCAN)
+const (
+	PIIOff    = "off"    // don't scan
+	PIIFlag   = "flag"   // keep it and warn in the response
+	PIIRedact = "redact" // replace it and warn
+)
+
+// What keyword extraction does with the text of articles (AUTO_TAGS)
+const (
+	AutoTagsOff     = "off"
+	AutoTagsSuggest = "suggest" // return the keywords as suggested_tags
+	AutoTagsApply   = "apply"   // also tag new articles submitted without tags
+)
+
+// Identifiers of new articles (ID_STRATEGY)
+const (
+	IDInt    = "int"    // integer IDs only
+	IDULID   = "ulid"   // a ULID as uid too
+	IDUUIDv7 = "uuidv7" // a version 7 UUID as uid too
+)
+
+// How non-ASCII titles become slugs (SLUG_STRATEGY)
+const (
+	SlugTransliterate = "transliterate" // ä to a, ß to ss; other scripts are dropped
+	SlugPercent       = "percent"       // keep letters of every script, percent-encoded in URLs
+)
+
+// How the server is run (PROFILE)
+const (
+	ProfileProd = "prod"
+	ProfileDev  = "dev" // reloads the THEME_DIR templates when they change
+)
+
+// What the server accepts (SERVER_MODE); admins switch it at /admin/mode
+const (
+	ModeNormal      = "normal"
+	ModeReadOnly    = "read-only"   // reads are served, changes answer 503
+	ModeMaintenance = "maintenance" // only the admin routes are served
+)
+
+// What the server does when another process has locked the .gob data file
+// (STORE_LOCKED)
+const (
+	StoreLockedFail     = "fail"      // refuse to start
+	StoreLockedReadOnly = "read-only" // start in read-only mode, never saving
+)
+
+// Config holds runtime settings, read from environment variables at startup
+type Config struct {
+	// Markdown extensions enabled for HTML rendering (MARKDOWN_EXTENSIONS=tables,highlight)
+	MarkdownTables    bool
+	MarkdownHighlight bool
+
+	// Sanitizer policy for article content: plain, basic or full (SANITIZE_MODE)
+	SanitizeMode string
+
+	// Scanning of article text for emails, phone numbers and national IDs:
+	// off, flag or redact (PII_SCAN), with the patterns of a file added
+	// (PII_PATTERNS)
+	PIIScan     string
+	PIIPatterns string
+
+	// Spam checks of articles submitted without credentials (SPAM_CHECK):
+	// a point for each of SPAM_KEYWORDS found, two for more than
+	// SPAM_LINK_DENSITY links per 100 words, and three if Akismet
+	// (AKISMET_KEY, AKISMET_BLOG) says spam. Articles that score
+	// SPAM_THRESHOLD are held for moderation.
+	SpamCheck       bool
+	SpamKeywords    []string
+	SpamLinkDensity int
+	SpamThreshold   int
+	AkismetKey      string
+	AkismetBlog     string
+
+	// CAPTCHA checks of requests without credentials: the provider,
+	// hcaptcha or turnstile, or empty for none (CAPTCHA_PROVIDER), the
+	// site's secret key (CAPTCHA_SECRET) and the routes that need a token,
+	// as "METHOD /path" (CAPTCHA_ROUTES)
+	CaptchaProvider string
+	CaptchaSecret   string
+	CaptchaRoutes   []string
+
+	// Signed URLs of private attachments: the HMAC key (URL_SIGNING_KEY),
+	// random per process if empty, and how long a URL is good for unless
+	// the request asks for less (SIGNED_URL_TTL)
+	URLSigningKey string
+	SignedURLTTL  time.Duration
+
+	// Identifiers of articles: int, or ulid or uuidv7 to give every
+	// article a uid that routes accept in place of its ID (ID_STRATEGY)
+	IDStrategy string
+
+	// Opaque public article IDs (hashids) in place of the integer ones, so
+	// that IDs can't be enumerated; empty keeps the integers
+	HashidsSalt      string // HASHIDS_SALT, keep it secret and never change it
+	HashidsMinLength int    // HASHIDS_MIN_LENGTH
+
+	// Slugs of article titles: transliterate or percent (SLUG_STRATEGY)
+	SlugStrategy string
+
+	// Attachment storage and upload limits
+	AttachmentsDir     string        // ATTACHMENTS_DIR
+	AttachmentMaxBytes int64         // ATTACHMENT_MAX_BYTES
+	AttachmentTypes    []string      // ATTACHMENT_TYPES, detected MIME types accepted on upload
+	UploadSessionTTL   time.Duration // UPLOAD_SESSION_TTL, how long a resumable upload waits for its next chunk
+
+	// Blob storage backend for attachments: local, s3 or gcs (BLOB_STORE)
+	BlobStore      string
+	S3Endpoint     string // S3_ENDPOINT, defaults to AWS (or GCS for BLOB_STORE=gcs)
+	S3Region       string // S3_REGION
+	S3Bucket       string // S3_BUCKET
+	S3Prefix       string // S3_PREFIX, prepended to every object key
+	S3AccessKey    string // S3_ACCESS_KEY_ID (falls back to AWS_ACCESS_KEY_ID)
+	S3SecretKey    string // S3_SECRET_ACCESS_KEY (falls back to AWS_SECRET_ACCESS_KEY)
+	S3SessionToken string // AWS_SESSION_TOKEN
+	S3PathStyle    bool   // S3_PATH_STYLE=true for MinIO and similar
+
+	// Copy external cover images into attachment storage by default (COVER_DOWNLOAD)
+	CoverDownload bool
+
+	// Public base URL used in absolute links, e.g. https://blog.example.com (PUBLIC_URL)
+	PublicURL string
+
+	// Feed channel metadata and size
+	FeedTitle       string // FEED_TITLE
+	FeedDescription string // FEED_DESCRIPTION
+	FeedLink        string // FEED_LINK, the site the feed belongs to
+	FeedLanguage    string // FEED_LANGUAGE, e.g. fi or en-us
+	FeedCount       int    // FEED_COUNT, number of items in the feed
+
+	// Set the language of articles submitted without one from their text
+	// (DETECT_LANGUAGE=true)
+	DetectLanguage bool
+
+	// OpenAI-compatible chat completions endpoint that writes article
+	// descriptions (SUMMARIZER_URL), with its model and API key
+	// (SUMMARIZER_MODEL, SUMMARIZER_API_KEY)
+	SummarizerURL    string
+	SummarizerModel  string
+	SummarizerAPIKey string
+	// Write the description of new articles created without one
+	// (SUMMARIZE_ON_CREATE=true)
+	SummarizeOnCreate bool
+
+	// OpenAI-compatible embeddings endpoint that semantic search compares
+	// articles with (EMBEDDINGS_URL), with its model and API key
+	// (EMBEDDINGS_MODEL, EMBEDDINGS_API_KEY)
+	EmbeddingsURL    string
+	EmbeddingsModel  string
+	EmbeddingsAPIKey string
+	// Keywords of the content suggested as tags of new and changed
+	// articles: off, suggest or apply (AUTO_TAGS)
+	AutoTags string
+
+	// HTML pages of the published articles at /blog, titled with the feed
+	// settings (BLOG=true)
+	Blog bool
+	// Directory of blog templates and assets that override the embedded
+	// ones of the same name (THEME_DIR)
+	ThemeDir string
+
+	// prod or dev (PROFILE)
+	Profile string
+
+	// Listen address of the HTTP API (ADDR)
+	Addr string
+
+	// Where the data is kept: a .gob file, sqlite:path or a postgres:// URL (STORE)
+	Store string
+	// Whether to fail or start read-only when the .gob file is locked (STORE_LOCKED)
+	StoreLocked string
+	// How long a data or blob store operation may go without progress
+	// before it is abandoned (STORE_TIMEOUT); 0 waits for ever
+	StoreTimeout time.Duration
+	// Failures in a row that open a store's circuit breaker
+	// (STORE_BREAKER_FAILURES), 0 ("off") for no breakers, and how long it
+	// stays open before a probe (STORE_BREAKER_COOLDOWN)
+	StoreBreakerFailures int
+	StoreBreakerCooldown time.Duration
+	// How article content is written to the store: gzip or off
+	// (STORE_COMPRESSION)
+	StoreCompression string
+	// JSON file of the data keys that encrypt the content of confidential
+	// articles, the first one for new writes (ENCRYPTION_KEYS_FILE)
+	EncryptionKeysFile string
+
+	// Cloud KMS key that wraps the data keys, so they are stored only
+	// wrapped: aws-kms://<key ID, ARN or alias> or
+	// gcp-kms://projects/.../cryptoKeys/<key> (KMS_KEY)
+	KMSKey      string
+	KMSEndpoint string // KMS_ENDPOINT, instead of the cloud's
+	// AWS credentials and region (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+	// AWS_SESSION_TOKEN, KMS_REGION falling back to AWS_REGION)
+	KMSAccessKey    string
+	KMSSecretKey    string
+	KMSSessionToken string
+	KMSRegion       string
+	// Google Cloud access token; the metadata server's if empty (KMS_ACCESS_TOKEN)
+	KMSAccessToken string
+
+	// Sample articles generated on first run, when there is no data file (SEED_ARTICLES)
+	SeedArticles int
+
+	// Listen address of the gRPC ArticleService; "off" disables it (GRPC_ADDR)
+	GRPCAddr string
+
+	// Base URL of the primary this server replicates as a read-only replica
+	// (REPLICA_OF), with admin credentials as user:password@ when it has
+	// users; /readyz fails while the replica lags more than REPLICA_MAX_LAG
+	ReplicaOf     string
+	ReplicaMaxLag time.Duration
+
+	// Leader election between a primary and its replicas through a lease in
+	// Redis (REDIS_URL): this node's base URL, which replicas stream from
+	// while it leads (ELECTION_URL, empty for no election), the lease key
+	// (ELECTION_KEY) and how long a dead leader keeps it (ELECTION_TTL)
+	ElectionURL string
+	ElectionKey string
+	ElectionTTL time.Duration
+
+	// Middleware stages turned off by name (MIDDLEWARE_DISABLE), e.g. log
+	MiddlewareDisable []string
+
+	// Log who changed what: every non-GET and admin request (AUDIT_LOG=true)
+	AuditLog bool
+
+	// Origins allowed to call the API from a browser (CORS_ORIGINS), "*" for any; empty disables CORS
+	CORSOrigins []string
+
+	// Mode at startup: normal, read-only or maintenance (SERVER_MODE), and
+	// the Retry-After of the requests it refuses (MODE_RETRY_AFTER)
+	Mode           string
+	ModeRetryAfter time.Duration
+
+	// How long a shutdown waits for requests in progress (SHUTDOWN_TIMEOUT),
+	// and how long POST /admin/drain keeps serving first (DRAIN_GRACE)
+	ShutdownTimeout time.Duration
+	DrainGrace      time.Duration
+
+	// Requests per minute per client address (RATE_LIMIT); 0 disables the limit
+	RateLimit      int
+	RateLimitBurst int // RATE_LIMIT_BURST, requests a client may send at once
+
+	// Requests served at once (MAX_IN_FLIGHT), 0 for no limit, and how long
+	// a request over it waits for a turn before it gets 503 (MAX_IN_FLIGHT_WAIT)
+	MaxInFlight     int
+	MaxInFlightWait time.Duration
+
+	// Default limits of each workspace: articles (QUOTA_MAX_ARTICLES),
+	// attachment bytes (QUOTA_MAX_STORAGE_BYTES) and requests a minute
+	// (QUOTA_RATE_LIMIT); 0 is unlimited. Admins can change them per
+	// workspace at /admin/quotas.
+	QuotaMaxArticles     int
+	QuotaMaxStorageBytes int64
+	QuotaRateLimit       int
+
+	// Response compression (COMPRESSION=off disables it)
+	Compression         bool
+	CompressionMinBytes int64    // COMPRESSION_MIN_BYTES, smaller bodies are sent as is
+	CompressionTypes    []string // COMPRESSION_TYPES, media type patterns such as text/*
+
+	// In-memory cache of GET responses, cleared on every write
+	ResponseCacheTTL        time.Duration // RESPONSE_CACHE_TTL, e.g. 30s; 0 disables the cache
+	ResponseCacheMaxEntries int           // RESPONSE_CACHE_MAX_ENTRIES
+	ResponseCacheBackend    string        // RESPONSE_CACHE: memory, or redis to share it between replicas
+
+	// Cache-Control overrides per route (CACHE_CONTROL), keyed by "METHOD /path" or "*"
+	CacheControl map[string]string
+
+	// Redis connection for the shared cache
+	RedisURL    string // REDIS_URL, redis://[:password@]host:port/db
+	RedisPrefix string // REDIS_PREFIX, prepended to every key and channel
+
+	// Background job queue
+	JobsFile       string // JOBS_FILE, where queued jobs are kept; "off" keeps them in memory
+	JobWorkers     int    // JOB_WORKERS, jobs run at the same time
+	JobMaxAttempts int    // JOB_MAX_ATTEMPTS, before a failing job becomes a dead letter
+	CompactAt      string // COMPACT_AT, local time (15:04) of the nightly compact job; "off" never
+	RetentionAt    string // RETENTION_AT, local time of the nightly retention job; "off" never
+	DigestAt       string // DIGEST_AT, local time of the digest job; "off" never
+
+	// ANALYTICS_FILE, where article view counts are kept; "off" keeps them
+	// in memory
+	AnalyticsFile string
+	// How often GET /articles/trending is recomputed (TRENDING_INTERVAL),
+	// and the age at which a view counts half (TRENDING_HALF_LIFE)
+	TrendingInterval time.Duration
+	TrendingHalfLife time.Duration
+	// ACTIVITY_FILE, where what each user did is kept; "off" keeps it in
+	// memory
+	ActivityFile string
+	// SEARCH_LOG_FILE, where the searches made are kept; "off" keeps them
+	// in memory
+	SearchLogFile string
+	// How often saved searches look for new matches to alert their owners
+	// to (SAVED_SEARCH_INTERVAL); 0 never
+	SavedSearchInterval time.Duration
+	// Retention rules, enforced by the retention job: how long daily view
+	// counts (RETAIN_VIEWS), activity entries (RETAIN_ACTIVITY) and
+	// searches (RETAIN_SEARCHES) are kept; 0 keeps them
+	RetainViews    time.Duration
+	RetainActivity time.Duration
+	RetainSearches time.Duration
+
+	// Email notifications
+	SMTPURL       string // SMTP_URL, smtp[s]://[user:password@]host:port; empty disables email
+	MailFrom      string // MAIL_FROM, sender address
+	MailTemplates string // MAIL_TEMPLATES, directory of templates overriding the built-in ones
+
+	// Chat notifications
+	ChatWebhooks []string // CHAT_WEBHOOKS, Slack, Discord or Teams incoming-webhook URLs
+	ChatEvents   []string // CHAT_EVENTS, events posted to the webhooks
+
+	// Web Push notifications to browsers; an empty key disables them
+	VAPIDPrivateKey string   // VAPID_PRIVATE_KEY, base64url P-256 key (go-spring push keys)
+	VAPIDSubject    string   // VAPID_SUBJECT, mailto: or https: contact for push services
+	PushEvents      []string // PUSH_EVENTS, events pushed unless a user's preferences say otherwise
+
+	// JSON file of keys that machine clients sign requests with, each
+	// acting as a user (SIGNING_KEYS_FILE)
+	SigningKeysFile string
+
+	// JSON file of webhook subscriptions to article changes, each with the
+	// secret its deliveries are signed with (WEBHOOKS_FILE)
+	WebhooksFile string
+
+	// Translations of API messages: a directory of <lang>.json files added to
+	// the bundled ones (MESSAGES_DIR)
+	MessagesDir string
+
+	// Image variants generated in the background after an upload, e.g. 400x300 or 200x0 (THUMBNAIL_SIZES)
+	ThumbnailSizes []string
+}
+
+// Load reads the configuration from the environment, falling back to defaults
+func Load() Config {
+	cfg := Config{
+		MarkdownTables:    true,
+		MarkdownHighlight: true,
+		SanitizeMode:      SanitizeBasic,
+		PIIScan:           PIIOff,
+		AutoTags:          AutoTagsOff,
+		IDStrategy:        IDInt,
+		SlugStrategy:      SlugTransliterate,
+		Mode:              ModeNormal,
+
+		AttachmentsDir:     "attachments",
+		AttachmentMaxBytes: 10 << 20,
+		AttachmentTypes: []string{
+			"image/png", "image/jpeg", "image/gif", "image/webp",
+			"application/pdf", "text/plain",
+		},
+	}
+
+	if v, ok := os.LookupEnv("MARKDOWN_EXTENSIONS"); ok {
+		exts := SplitList(v)
+		cfg.MarkdownTables = slices.Contains(exts, "tables")
+		cfg.MarkdownHighlight = slices.Contains(exts, "highlight")
+	}
+
+	switch mode := strings.ToLower(os.Getenv("SANITIZE_MODE")); mode {
+	case SanitizePlain, SanitizeBasic, SanitizeFull:
+		cfg.SanitizeMode = mode
+	case "":
+	default:
+		log.Printf("Warning: unknown SANITIZE_MODE %q, using %q", mode, cfg.SanitizeMode)
+	}
+	switch mode := strings.ToLower(os.Getenv("PII_SCAN")); mode {
+	case PIIOff, PIIFlag, PIIRedact:
+		cfg.PIIScan = mode
+	case "":
+	default:
+		log.Printf("Warning: unknown PII_SCAN %q, using %q", mode, cfg.PIIScan)
+	}
+	cfg.PIIPatterns = os.Getenv("PII_PATTERNS")
+	cfg.SpamCheck = os.Getenv("SPAM_CHECK") == "true"
+	cfg.SpamKeywords = SplitList(EnvString("SPAM_KEYWORDS", "viagra,cialis,casino,payday loan,crypto giveaway,buy followers"))
+	cfg.SpamLinkDensity = int(envInt64("SPAM_LINK_DENSITY", 10))
+	cfg.SpamThreshold = int(envInt64("SPAM_THRESHOLD", 3))
+	cfg.AkismetKey = os.Getenv("AKISMET_KEY")
+	cfg.AkismetBlog = os.Getenv("AKISMET_BLOG")
+	switch provider := strings.ToLower(os.Getenv("CAPTCHA_PROVIDER")); provider {
+	case "", "hcaptcha", "turnstile":
+		cfg.CaptchaProvider = provider
+	default:
+		log.Printf("Warning: unknown CAPTCHA_PROVIDER %q, CAPTCHA checks are off", provider)
+	}
+	cfg.CaptchaSecret = os.Getenv("CAPTCHA_SECRET")
+	cfg.CaptchaRoutes = parseRouteList(EnvString("CAPTCHA_ROUTES", "POST /articles"))
+	cfg.URLSigningKey = os.Getenv("URL_SIGNING_KEY")
+	cfg.SignedURLTTL = envDuration("SIGNED_URL_TTL", time.Hour)
+
+	switch strategy := strings.ToLower(os.Getenv("ID_STRATEGY")); strategy {
+	case IDInt, IDULID, IDUUIDv7:
+		cfg.IDStrategy = strategy
+	case "":
+	default:
+		log.Printf("Warning: unknown ID_STRATEGY %q, using %q", strategy, cfg.IDStrategy)
+	}
+
+	cfg.HashidsSalt = os.Getenv("HASHIDS_SALT")
+	cfg.HashidsMinLength = int(envInt64("HASHIDS_MIN_LENGTH", 8))
+
+	switch strategy := strings.ToLower(os.Getenv("SLUG_STRATEGY")); strategy {
+	case SlugTransliterate, SlugPercent:
+		cfg.SlugStrategy = strategy
+	case "":
+	default:
+		log.Printf("Warning: unknown SLUG_STRATEGY %q, using %q", strategy, cfg.SlugStrategy)
+	}
+
+	if v := os.Getenv("ATTACHMENTS_DIR"); v != "" {
+		cfg.AttachmentsDir = v
+	}
+	cfg.AttachmentMaxBytes = envInt64("ATTACHMENT_MAX_BYTES", cfg.AttachmentMaxBytes)
+	if v := os.Getenv("ATTACHMENT_TYPES"); v != "" {
+		cfg.AttachmentTypes = SplitList(v)
+	}
+	cfg.UploadSessionTTL = envDuration("UPLOAD_SESSION_TTL", 24*time.Hour)
+
+	cfg.BlobStore = strings.ToLower(EnvString("BLOB_STORE", "local"))
+	cfg.S3Endpoint = os.Getenv("S3_ENDPOINT")
+	cfg.S3Region = EnvString("S3_REGION", EnvString("AWS_REGION", "us-east-1"))
+	cfg.S3Bucket = os.Getenv("S3_BUCKET")
+	cfg.S3Prefix = os.Getenv("S3_PREFIX")
+	cfg.S3AccessKey = EnvString("S3_ACCESS_KEY_ID", os.Getenv("AWS_ACCESS_KEY_ID"))
+	cfg.S3SecretKey = EnvString("S3_SECRET_ACCESS_KEY", os.Getenv("AWS_SECRET_ACCESS_KEY"))
+	cfg.S3SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	cfg.S3PathStyle = os.Getenv("S3_PATH_STYLE") == "true"
+
+	cfg.CoverDownload = os.Getenv("COVER_DOWNLOAD") == "true"
+
+	cfg.PublicURL = os.Getenv("PUBLIC_URL")
+	cfg.FeedTitle = EnvString("FEED_TITLE", "Go Spring Articles")
+	cfg.FeedDescription = EnvString("FEED_DESCRIPTION", "Latest articles")
+	cfg.FeedLink = os.Getenv("FEED_LINK")
+	cfg.FeedLanguage = EnvString("FEED_LANGUAGE", "en")
+	cfg.DetectLanguage = os.Getenv("DETECT_LANGUAGE") == "true"
+	cfg.SummarizerURL = os.Getenv("SUMMARIZER_URL")
+	cfg.SummarizerModel = os.Getenv("SUMMARIZER_MODEL")
+	cfg.SummarizerAPIKey = os.Getenv("SUMMARIZER_API_KEY")
+	cfg.SummarizeOnCreate = os.Getenv("SUMMARIZE_ON_CREATE") == "true"
+	cfg.EmbeddingsURL = os.Getenv("EMBEDDINGS_URL")
+	cfg.EmbeddingsModel = os.Getenv("EMBEDDINGS_MODEL")
+	cfg.EmbeddingsAPIKey = os.Getenv("EMBEDDINGS_API_KEY")
+	switch mode := strings.ToLower(os.Getenv("AUTO_TAGS")); mode {
+	case AutoTagsOff, AutoTagsSuggest, AutoTagsApply:
+		cfg.AutoTags = mode
+	case "":
+	default:
+		log.Printf("Warning: unknown AUTO_TAGS %q, using %q", mode, cfg.AutoTags)
+	}
+	cfg.FeedCount = int(envInt64("FEED_COUNT", 20))
+	cfg.Blog = os.Getenv("BLOG") == "true"
+	cfg.ThemeDir = os.Getenv("THEME_DIR")
+	cfg.Profile = ProfileProd
+	switch profile := strings.ToLower(os.Getenv("PROFILE")); profile {
+	case ProfileProd, ProfileDev:
+		cfg.Profile = profile
+	case "":
+	default:
+		log.Printf("Warning: unknown PROFILE %q, using %q", profile, cfg.Profile)
+	}
+
+	cfg.Addr = EnvString("ADDR", ":8080")
+	cfg.Store = EnvString("STORE", "articles.gob")
+	cfg.StoreLocked = StoreLockedFail
+	switch locked := strings.ToLower(os.Getenv("STORE_LOCKED")); locked {
+	case StoreLockedFail, StoreLockedReadOnly:
+		cfg.StoreLocked = locked
+	case "":
+	default:
+		log.Printf("Warning: unknown STORE_LOCKED %q, using %q", locked, cfg.StoreLocked)
+	}
+	cfg.StoreTimeout = envDuration("STORE_TIMEOUT", 10*time.Second)
+	if os.Getenv("STORE_BREAKER_FAILURES") != "off" {
+		cfg.StoreBreakerFailures = int(envInt64("STORE_BREAKER_FAILURES", 5))
+	}
+	cfg.StoreBreakerCooldown = envDuration("STORE_BREAKER_COOLDOWN", 30*time.Second)
+	cfg.StoreCompression = "gzip"
+	switch compression := strings.ToLower(os.Getenv("STORE_COMPRESSION")); compression {
+	case "gzip", "off":
+		cfg.StoreCompression = compression
+	case "":
+	default:
+		log.Printf("Warning: unknown STORE_COMPRESSION %q, using %q", compression, cfg.StoreCompression)
+	}
+	cfg.EncryptionKeysFile = os.Getenv("ENCRYPTION_KEYS_FILE")
+	cfg.KMSKey = os.Getenv("KMS_KEY")
+	cfg.KMSEndpoint = os.Getenv("KMS_ENDPOINT")
+	cfg.KMSAccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	cfg.KMSSecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	cfg.KMSSessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	cfg.KMSRegion = EnvString("KMS_REGION", EnvString("AWS_REGION", "us-east-1"))
+	cfg.KMSAccessToken = os.Getenv("KMS_ACCESS_TOKEN")
+	cfg.SeedArticles = int(envInt64("SEED_ARTICLES", 3))
+	cfg.GRPCAddr = EnvString("GRPC_ADDR", ":9090")
+	cfg.ReplicaOf = strings.TrimRight(os.Getenv("REPLICA_OF"), "/")
+	cfg.ReplicaMaxLag = envDuration("REPLICA_MAX_LAG", 30*time.Second)
+	cfg.ElectionURL = strings.TrimRight(os.Getenv("ELECTION_URL"), "/")
+	cfg.ElectionKey = EnvString("ELECTION_KEY", "go-spring:leader")
+	cfg.ElectionTTL = envDuration("ELECTION_TTL", 10*time.Second)
+
+	cfg.MiddlewareDisable = SplitList(os.Getenv("MIDDLEWARE_DISABLE"))
+	cfg.AuditLog = os.Getenv("AUDIT_LOG") == "true"
+	cfg.CORSOrigins = SplitList(os.Getenv("CORS_ORIGINS"))
+	switch mode := strings.ToLower(os.Getenv("SERVER_MODE")); mode {
+	case ModeNormal, ModeReadOnly, ModeMaintenance:
+		cfg.Mode = mode
+	case "":
+	default:
+		log.Printf("Warning: unknown SERVER_MODE %q, using %q", mode, cfg.Mode)
+	}
+	cfg.ModeRetryAfter = envDuration("MODE_RETRY_AFTER", 5*time.Minute)
+	cfg.ShutdownTimeout = envDuration("SHUTDOWN_TIMEOUT", 10*time.Second)
+	cfg.DrainGrace = envDuration("DRAIN_GRACE", 30*time.Second)
+	cfg.RateLimit = int(envInt64("RATE_LIMIT", 0))
+	cfg.RateLimitBurst = int(envInt64("RATE_LIMIT_BURST", 20))
+	cfg.MaxInFlight = int(envInt64("MAX_IN_FLIGHT", 0))
+	cfg.MaxInFlightWait = envDuration("MAX_IN_FLIGHT_WAIT", 250*time.Millisecond)
+	cfg.QuotaMaxArticles = int(envInt64("QUOTA_MAX_ARTICLES", 0))
+	cfg.QuotaMaxStorageBytes = envInt64("QUOTA_MAX_STORAGE_BYTES", 0)
+	cfg.QuotaRateLimit = int(envInt64("QUOTA_RATE_LIMIT", 0))
+
+	cfg.Compression = os.Getenv("COMPRESSION") != "off"
+	cfg.CompressionMinBytes = envInt64("COMPRESSION_MIN_BYTES", 1024)
+	cfg.CompressionTypes = []string{
+		"text/*", "application/json", "application/*+json", "application/xml", "application/*+xml",
+		"application/yaml", "application/x-yaml", "application/msgpack", "application/x-ndjson", "application/javascript",
+		"image/svg+xml",
+	}
+	if v := os.Getenv("COMPRESSION_TYPES"); v != "" {
+		cfg.CompressionTypes = SplitList(v)
+	}
+
+	cfg.ResponseCacheTTL = envDuration("RESPONSE_CACHE_TTL", 30*time.Second)
+	cfg.ResponseCacheMaxEntries = int(envInt64("RESPONSE_CACHE_MAX_ENTRIES", 1000))
+	cfg.ResponseCacheBackend = strings.ToLower(EnvString("RESPONSE_CACHE", "memory"))
+	cfg.CacheControl = parseCacheControl(os.Getenv("CACHE_CONTROL"))
+	cfg.RedisURL = EnvString("REDIS_URL", "redis://localhost:6379")
+	cfg.RedisPrefix = EnvString("REDIS_PREFIX", "go-spring:cache:")
+
+	cfg.JobsFile = EnvString("JOBS_FILE", "jobs.gob")
+	cfg.JobWorkers = int(envInt64("JOB_WORKERS", 2))
+	cfg.JobMaxAttempts = int(envInt64("JOB_MAX_ATTEMPTS", 5))
+	cfg.CompactAt = envTimeOfDay("COMPACT_AT", "03:00")
+	cfg.RetentionAt = envTimeOfDay("RETENTION_AT", "02:00")
+	cfg.DigestAt = envTimeOfDay("DIGEST_AT", "07:00")
+	cfg.AnalyticsFile = EnvString("ANALYTICS_FILE", "analytics.gob")
+	cfg.TrendingInterval = envDuration("TRENDING_INTERVAL", 5*time.Minute)
+	cfg.TrendingHalfLife = envDuration("TRENDING_HALF_LIFE", 24*time.Hour)
+	cfg.ActivityFile = EnvString("ACTIVITY_FILE", "activity.gob")
+	cfg.SearchLogFile = EnvString("SEARCH_LOG_FILE", "searches.gob")
+	cfg.SavedSearchInterval = envDuration("SAVED_SEARCH_INTERVAL", 5*time.Minute)
+	cfg.RetainViews = envDuration("RETAIN_VIEWS", 0)
+	cfg.RetainActivity = envDuration("RETAIN_ACTIVITY", 0)
+	cfg.RetainSearches = envDuration("RETAIN_SEARCHES", 0)
+	cfg.SMTPURL = os.Getenv("SMTP_URL")
+	cfg.MailFrom = EnvString("MAIL_FROM", "go-spring@localhost")
+	cfg.MailTemplates = os.Getenv("MAIL_TEMPLATES")
+	cfg.MessagesDir = os.Getenv("MESSAGES_DIR")
+	// Webhook URLs are case sensitive, so not SplitList
+	cfg.ChatWebhooks = strings.FieldsFunc(os.Getenv("CHAT_WEBHOOKS"), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	cfg.ChatEvents = SplitList(EnvString("CHAT_EVENTS", "article.published,import.completed,store.failed,primary.failover"))
+	cfg.VAPIDPrivateKey = os.Getenv("VAPID_PRIVATE_KEY")
+	cfg.VAPIDSubject = os.Getenv("VAPID_SUBJECT")
+	cfg.PushEvents = SplitList(EnvString("PUSH_EVENTS", "article.published,user.mentioned"))
+	cfg.WebhooksFile = os.Getenv("WEBHOOKS_FILE")
+	cfg.SigningKeysFile = os.Getenv("SIGNING_KEYS_FILE")
+	cfg.ThumbnailSizes = []string{"400x300"}
+	if v, ok := os.LookupEnv("THUMBNAIL_SIZES"); ok {
+		cfg.ThumbnailSizes = SplitList(v)
+	}
+
+	return cfg
+}
+
+// EnvString reads a string setting with a default
+func EnvString(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// Read a positive integer setting, keeping the default on absence or error
+func envInt64(name string, def int64) int64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		log.Printf("Warning: invalid %s %q, using %d", name, v, def)
+		return def
+	}
+	return n
+}
+
+// Read a local time of day such as 03:00, or off
+func envTimeOfDay(name, def string) string {
+	v := EnvString(name, def)
+	if _, err := time.Parse("15:04", v); err != nil && v != "off" {
+		log.Printf("Warning: invalid %s %q, using %q", name, v, def)
+		return def
+	}
+	return v
+}
+
+// Read a duration setting such as 30s or 5m; 0 is allowed and means off
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Printf("Warning: invalid %s %q, using %s", name, v, def)
+		return def
+	}
+	return d
+}
+
+// SplitList splits a comma separated setting into trimmed, lower-cased,
+// non-empty values
+func SplitList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// Parse a comma separated list of routes, "METHOD /path" or "/path" for
+// POST, into "METHOD /path" with the method upper-cased
+func parseRouteList(v string) []string {
+	var routes []string
+	for _, route := range strings.Split(v, ",") {
+		route = strings.TrimSpace(route)
+		if route == "" {
+			continue
+		}
+		method, path, ok := strings.Cut(route, " ")
+		if !ok {
+			method, path = http.MethodPost, route
+		}
+		routes = append(routes, strings.ToUpper(method)+" "+strings.TrimSpace(path))
+	}
+	return routes
+}
+
+// Cache-Control directives accepted in route policies
+var cacheControlDirectives = map[string]bool{
+	"public": true, "private": true, "no-cache": true, "no-store": true, "no-transform": true,
+	"must-revalidate": true, "proxy-revalidate": true, "immutable": true,
+	"max-age": true, "s-maxage": true, "stale-while-revalidate": true, "stale-if-error": true,
+}
+
+// Parse CACHE_CONTROL: rules separated by ";", each "[METHOD ]/path=directives"
+func parseCacheControl(v string) map[string]string {
+	rules := map[string]string{}
+	for _, rule := range strings.Split(v, ";") {
+		key, policy, ok := strings.Cut(strings.TrimSpace(rule), "=")
+		if !ok {
+			if rule = strings.TrimSpace(rule); rule != "" {
+				log.Printf("Warning: ignoring CACHE_CONTROL rule %q", rule)
+			}
+			continue
+		}
+		key, policy = strings.TrimSpace(key), strings.TrimSpace(policy)
+		if method, path, ok := strings.Cut(key, " "); ok {
+			key = strings.ToUpper(method) + " " + strings.TrimSpace(path)
+		} else if key != "*" {
+			key = http.MethodGet + " " + key
+		}
+		for _, directive := range strings.Split(policy, ",") {
+			name, _, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name = strings.ToLower(name); !cacheControlDirectives[name] && policy != "none" {
+				log.Printf("Warning: unknown Cache-Control directive %q for %s", name, key)
+			}
+		}
+		rules[key] = policy
+	}
+	return rules
+}
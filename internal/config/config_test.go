@@ -0,0 +1,50 @@
+package config
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestLoadFromEnvironment(t *testing.T) {
+	t.Setenv("ADDR", ":9000")
+	t.Setenv("SANITIZE_MODE", "FULL")
+	t.Setenv("FEED_COUNT", "-3") // invalid, keeps the default
+	t.Setenv("RESPONSE_CACHE_TTL", "0")
+	t.Setenv("ATTACHMENT_TYPES", " image/png, Text/Plain ,,")
+	t.Setenv("MARKDOWN_EXTENSIONS", "tables")
+
+	cfg := Load()
+	if cfg.Addr != ":9000" || cfg.SanitizeMode != SanitizeFull || cfg.FeedCount != 20 || cfg.ResponseCacheTTL != 0 {
+		t.Errorf("Addr %q, SanitizeMode %q, FeedCount %d, ResponseCacheTTL %s", cfg.Addr, cfg.SanitizeMode, cfg.FeedCount, cfg.ResponseCacheTTL)
+	}
+	if len(cfg.AttachmentTypes) != 2 || cfg.AttachmentTypes[1] != "text/plain" {
+		t.Errorf("AttachmentTypes %q", cfg.AttachmentTypes)
+	}
+	if !cfg.MarkdownTables || cfg.MarkdownHighlight {
+		t.Errorf("MarkdownTables %v, MarkdownHighlight %v", cfg.MarkdownTables, cfg.MarkdownHighlight)
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	for _, name := range []string{"ADDR", "STORE", "GRPC_ADDR", "RESPONSE_CACHE_TTL", "SANITIZE_MODE"} {
+		t.Setenv(name, "")
+	}
+	cfg := Load()
+	if cfg.Addr != ":8080" || cfg.Store != "articles.gob" || cfg.GRPCAddr != ":9090" ||
+		cfg.ResponseCacheTTL != 30*time.Second || cfg.SanitizeMode != SanitizeBasic {
+		t.Errorf("defaults %+v", cfg)
+	}
+}
+
+func TestParseCacheControl(t *testing.T) {
+	got := parseCacheControl("/articles=max-age=60; post /articles = no-store ;*=no-cache;broken")
+	want := map[string]string{
+		"GET /articles":  "max-age=60",
+		"POST /articles": "no-store",
+		"*":              "no-cache",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("parseCacheControl = %v, want %v", got, want)
+	}
+}
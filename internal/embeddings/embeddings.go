@@ -0,0 +1,123 @@
+// Package embeddings turns texts into vectors that point the same way when
+// the texts are about the same things, whatever their words. An Embedder
+// is usually a model behind an API; API speaks the OpenAI embeddings
+// protocol, which most hosted and self-hosted model servers offer, and any
+// other can be plugged in through the interface.
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Embedder returns a vector for each of texts, in order
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// EmbedderFunc is an Embedder as a function
+type EmbedderFunc func(ctx context.Context, texts []string) ([][]float32, error)
+
+func (f EmbedderFunc) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return f(ctx, texts)
+}
+
+// How much of a text is sent; models read a few thousand words at most,
+// and an article's opening says most of what it is about
+const maxInput = 8000
+
+// API asks an OpenAI-compatible embeddings endpoint, such as
+// https://api.openai.com/v1/embeddings or a local model server's
+// /v1/embeddings
+type API struct {
+	Endpoint string
+	Model    string
+	APIKey   string // sent as a bearer token, if set
+	Client   *http.Client
+}
+
+func (a API) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	input := make([]string, len(texts))
+	for i, text := range texts {
+		if len(text) > maxInput {
+			text = strings.ToValidUTF8(text[:maxInput], "")
+		}
+		input[i] = text
+	}
+	body, err := json.Marshal(map[string]any{"model": a.Model, "input": input})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+a.APIKey)
+	}
+	client := a.Client
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embeddings: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return nil, fmt.Errorf("embeddings: %w", err)
+	}
+	var out struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.Unmarshal(data, &out)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embeddings: %s: %s", resp.Status, out.Error.Message)
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range out.Data {
+		if d.Index < 0 || d.Index >= len(vectors) || len(d.Embedding) == 0 {
+			return nil, fmt.Errorf("embeddings: unexpected vector %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("embeddings: no vector for text %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+// Cosine returns the cosine similarity of a and b, from -1 to 1; vectors
+// of different lengths, from different models, are 0 apart
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
@@ -0,0 +1,62 @@
+package embeddings
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPI(t *testing.T) {
+	var got struct {
+		Model string
+		Input []string
+	}
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+		if got.Input[0] == "fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"message": "input too long"}}`))
+			return
+		}
+		// Out of order, as the index says where each goes
+		w.Write([]byte(`{"data": [{"index": 1, "embedding": [0, 1]}, {"index": 0, "embedding": [1, 0]}]}`))
+	}))
+	defer srv.Close()
+
+	a := API{Endpoint: srv.URL, Model: "small", APIKey: "secret"}
+	vectors, err := a.Embed(t.Context(), []string{"first", strings.Repeat("x", maxInput+10)})
+	if err != nil || len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][1] != 1 {
+		t.Fatalf("vectors %v, %v", vectors, err)
+	}
+	if auth != "Bearer secret" || got.Model != "small" || len(got.Input[1]) != maxInput {
+		t.Errorf("request: auth %q, model %q, input of %d", auth, got.Model, len(got.Input[1]))
+	}
+	if _, err := a.Embed(t.Context(), []string{"fail"}); err == nil || !strings.Contains(err.Error(), "input too long") {
+		t.Errorf("error answer: %v", err)
+	}
+	if _, err := a.Embed(t.Context(), []string{"a", "b", "c"}); err == nil {
+		t.Error("a missing vector went unnoticed")
+	}
+}
+
+func TestCosine(t *testing.T) {
+	for _, c := range []struct {
+		a, b []float32
+		want float64
+	}{
+		{[]float32{1, 0}, []float32{2, 0}, 1},
+		{[]float32{1, 0}, []float32{0, 3}, 0},
+		{[]float32{1, 1}, []float32{-1, -1}, -1},
+		{[]float32{1, 0}, []float32{1, 0, 0}, 0},
+		{[]float32{0, 0}, []float32{1, 0}, 0},
+	} {
+		if got := Cosine(c.a, c.b); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("Cosine(%v, %v) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
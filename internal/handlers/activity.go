@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"go-spring/internal/activity"
+	"go-spring/internal/config"
+	"go-spring/internal/model"
+)
+
+// What each user did is recorded from the article events, which carry the
+// user of the request that made the change, see publishArticleEvent. Writes
+// outside the account, admin and workspace routes don't require credentials,
+// so the actor stage picks up valid ones when a request has them; changes
+// made without credentials, or by jobs, belong to no one.
+
+type activityState struct {
+	// The activity of every user, opened by New; nil for commands that run
+	// without a server
+	activityLog *activity.Log
+}
+
+// ActivityEntry is one thing a user did, in GET /users/{username}/activity
+type ActivityEntry struct {
+	Time      time.Time       `json:"time"`
+	Action    string          `json:"action"` // the event type, e.g. article.created, or user.erased
+	ArticleID model.ArticleID `json:"article_id,omitempty"`
+	Title     string          `json:"title,omitempty"`   // at the time
+	Subject   string          `json:"subject,omitempty"` // the user acted on
+}
+
+// UserActivity is a page of a user's activity, newest first
+type UserActivity struct {
+	Username      string          `json:"username"`
+	Entries       []ActivityEntry `json:"entries"`
+	NextPageToken string          `json:"next_page_token,omitempty"`
+}
+
+// Record an article change in the activity of the user who made it
+func (app *App) recordActivity(event ArticleEvent) {
+	if event.Actor == "" || app.activityLog == nil {
+		return
+	}
+	app.activityLog.Add(event.Actor, activity.Entry{
+		Time:      event.Time,
+		Action:    event.Type,
+		ArticleID: event.Article.ID,
+		Title:     event.Article.Title,
+	})
+}
+
+// Open the activity in ACTIVITY_FILE
+func (app *App) initActivity(cfg config.Config) error {
+	path := cfg.ActivityFile
+	if path == "off" {
+		path = ""
+	}
+	log, err := activity.Open(path)
+	if err != nil {
+		return err
+	}
+	app.activityLog = log
+	return nil
+}
+
+// Write the activity every analyticsSaveInterval, and once more when ctx is
+// done
+func (app *App) runActivitySaver(ctx context.Context) {
+	runPeriodicSave(ctx, "activity", func(time.Time) error { return app.activityLog.Save() })
+}
+
+// Requests that may change articles
+var actorRoutes = func(r Route) bool { return r.Method != http.MethodGet }
+
+// Put the user of valid Basic credentials in the context, so the change is
+// theirs; a request without them goes on as before
+func (app *App) identifyActor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := currentUser(r); !ok {
+			if user, ok := app.requestUser(r); ok {
+				r = r.WithContext(context.WithValue(r.Context(), userKey{}, user))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GET /users/{username}/activity - What a user did, newest first; for the
+// user and admins
+func (app *App) getUserActivity(w http.ResponseWriter, r *http.Request) {
+	if !app.hasUsers() {
+		app.writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+	caller, ok := app.basicAuthUser(w, r)
+	if !ok {
+		return
+	}
+	name := mux.Vars(r)["username"]
+	if !strings.EqualFold(caller.Username, name) && caller.Role != model.RoleAdmin {
+		app.writeError(w, r, http.StatusForbidden, CodeForbidden, "Only admins can see the activity of other users")
+		return
+	}
+	user, found := app.findUser(name)
+	if !found {
+		app.writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+
+	query := r.URL.Query()
+	pageSize := 20
+	if v := query.Get("page_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid page_size")
+			return
+		}
+		pageSize = min(n, 100)
+	}
+	// Page tokens encode the sequence number of the last entry of the
+	// previous page
+	var before int64
+	if token := query.Get("page_token"); token != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(token)
+		if err == nil {
+			before, err = strconv.ParseInt(strings.TrimPrefix(string(raw), "before:"), 10, 64)
+		}
+		if err != nil || before < 1 {
+			app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid page_token")
+			return
+		}
+	}
+
+	page, more := app.activityLog.Page(user.Username, before, pageSize)
+	result := UserActivity{Username: user.Username, Entries: []ActivityEntry{}}
+	for _, e := range page {
+		result.Entries = append(result.Entries, activityEntry(e))
+	}
+	if more {
+		last := page[len(page)-1].Seq
+		result.NextPageToken = base64.RawURLEncoding.EncodeToString([]byte("before:" + strconv.FormatInt(last, 10)))
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Activity retrieved successfully", Data: result})
+}
+
+// An entry as the API shows it
+func activityEntry(e activity.Entry) ActivityEntry {
+	return ActivityEntry{Time: e.Time, Action: e.Action, ArticleID: model.ArticleID(e.ArticleID), Title: e.Title, Subject: e.Subject}
+}
+
+// The user with username, in any case
+func (app *App) findUser(username string) (model.User, bool) {
+	app.articlesMutex.RLock()
+	defer app.articlesMutex.RUnlock()
+	for _, u := range app.users {
+		if strings.EqualFold(u.Username, username) {
+			return u, true
+		}
+	}
+	return model.User{}, false
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"go-spring/internal/analytics"
+	"go-spring/internal/config"
+)
+
+// Views of an article, through GET /articles/{id}, its rendered HTML or its
+// slug, are counted by the analytics stage in front of the response cache,
+// so cached responses count too. Only successful responses are views. The
+// counts belong to the node that served them: replicas count their own.
+
+type analyticsState struct {
+	// Counts of article views, opened by New; nil for commands that run
+	// without a server
+	viewCounter *analytics.Counter
+}
+
+// How often the counts are written to ANALYTICS_FILE, and the activity to
+// ACTIVITY_FILE
+const analyticsSaveInterval = time.Minute
+
+// Most points one analytics request returns
+const maxAnalyticsPoints = 1000
+
+// ArticleAnalytics is the answer of GET /articles/{id}/analytics
+type ArticleAnalytics struct {
+	ArticleID  int               `json:"article_id"`
+	Interval   string            `json:"interval"` // hour or day
+	From       time.Time         `json:"from"`
+	To         time.Time         `json:"to"`
+	Views      int               `json:"views"`       // in the range
+	TotalViews int               `json:"total_views"` // ever
+	Points     []analytics.Point `json:"points"`
+}
+
+// Drop the view counts of a deleted article
+func (app *App) dropViews(event ArticleEvent) {
+	if event.Type == EventArticleDeleted && app.viewCounter != nil {
+		app.viewCounter.Delete(event.Article.ID)
+	}
+}
+
+// Open the view counts in ANALYTICS_FILE
+func (app *App) initAnalytics(cfg config.Config) error {
+	path := cfg.AnalyticsFile
+	if path == "off" {
+		path = ""
+	}
+	counter, err := analytics.Open(path)
+	if err != nil {
+		return err
+	}
+	app.viewCounter = counter
+	return nil
+}
+
+// Write the counts every analyticsSaveInterval, and once more when ctx is
+// done
+func (app *App) runAnalyticsSaver(ctx context.Context) {
+	runPeriodicSave(ctx, "view counts", app.viewCounter.Save)
+}
+
+// Call save every analyticsSaveInterval and once more when ctx is done,
+// logging failures to save what
+func runPeriodicSave(ctx context.Context, what string, save func(now time.Time) error) {
+	ticker := time.NewTicker(analyticsSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if err := save(time.Now()); err != nil {
+				log.Printf("Error: Failed to save %s: %v", what, err)
+			}
+			return
+		}
+		if err := save(time.Now()); err != nil {
+			log.Printf("Error: Failed to save %s: %v", what, err)
+		}
+	}
+}
+
+// Routes that count as a view of an article
+var viewRoutes = func(r Route) bool {
+	switch r.Path {
+	case "/articles/{id}", "/articles/{id}/html", "/articles/by-slug/{slug}":
+		return r.Method == http.MethodGet
+	}
+	return false
+}
+
+// Count a successful response as a view of the article in the route
+func (app *App) countViews(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lw := &logWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(lw, r)
+		if lw.status != http.StatusOK || app.viewCounter == nil {
+			return
+		}
+		var id int
+		if slug, ok := mux.Vars(r)["slug"]; ok {
+			article, found := app.findArticleBySlug(slug)
+			if !found {
+				return
+			}
+			id = article.ID
+		} else {
+			var err error
+			if id, err = app.articleRouteID(r); err != nil {
+				return
+			}
+		}
+		app.viewCounter.Record(id, time.Now())
+	})
+}
+
+// GET /articles/{id}/analytics - Views of an article over time
+func (app *App) getArticleAnalytics(w http.ResponseWriter, r *http.Request) {
+	id, err := app.articleRouteID(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid article ID")
+		return
+	}
+	if _, ok := app.readArticle(id); !ok {
+		app.writeError(w, r, http.StatusNotFound, CodeArticleNotFound, "Article not found")
+		return
+	}
+
+	query := r.URL.Query()
+	interval := analytics.Interval(query.Get("interval"))
+	span := 30 * 24 * time.Hour
+	switch interval {
+	case "":
+		interval = analytics.Day
+	case analytics.Day:
+	case analytics.Hour:
+		span = 48 * time.Hour
+	default:
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "interval must be hour or day")
+		return
+	}
+	to, err := analyticsTime(query.Get("to"), time.Now())
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid to: "+err.Error())
+		return
+	}
+	from, err := analyticsTime(query.Get("from"), to.Add(-span))
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid from: "+err.Error())
+		return
+	}
+	if from.After(to) {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "from must not be after to")
+		return
+	}
+	if to.Sub(from)/interval.Duration() >= maxAnalyticsPoints {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "Range too long for the interval")
+		return
+	}
+
+	result := ArticleAnalytics{ArticleID: id, Interval: string(interval), From: from.UTC(), To: to.UTC(), TotalViews: app.viewCounter.Total(id)}
+	result.Points, result.Views = app.viewCounter.Series(id, interval, from, to)
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Analytics retrieved successfully", Data: result})
+}
+
+// A from or to parameter: RFC 3339 or a date, or def when empty
+func analyticsTime(v string, def time.Time) (time.Time, error) {
+	if v == "" {
+		return def, nil
+	}
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"go-spring/internal/config"
+	"go-spring/internal/i18n"
+	"go-spring/internal/model"
+	"go-spring/internal/notify"
+	"go-spring/internal/store"
+)
+
+// App is the articles API: the data in memory, the stores and the
+// services set up from the configuration, and the handlers, which are its
+// methods. Each App is independent of the others, so a process may have
+// several, each with its own store.
+type App struct {
+	articlesState
+	attachmentsState
+	usersState
+	workspacesState
+	serviceState
+	snapshotState
+	listingState
+	cacheState
+	backgroundState
+	breakerState
+	dedupState
+	eventsState
+	replicationState
+	electionState
+	shutdownState
+	modeState
+	jobsState
+	notificationsState
+	webhooksState
+	pushState
+	savedsearchesState
+	activityState
+	analyticsState
+	trendingState
+	searchState
+	searchlogState
+	summarizeState
+	spamState
+	captchaState
+	piiState
+	confidentialState
+	signaturesState
+	signedurlsState
+	idempotencyState
+	ratelimitState
+	uploadsState
+	blogState
+	messagesState
+	openapiState
+	pluginsState
+}
+
+// The state every App starts with, whether it serves or a command only
+// opens its store
+func newApp(cfg config.Config) *App {
+	app := &App{}
+	app.cfg = cfg
+	app.articlesMutex.snapshot = &app.articleSnapshot
+	app.dataBreaker = &circuitBreaker{name: "data store", limit: cfg.StoreBreakerFailures, cooldown: cfg.StoreBreakerCooldown, state: BreakerClosed}
+	app.blobBreaker = &circuitBreaker{name: "blob store", limit: cfg.StoreBreakerFailures, cooldown: cfg.StoreBreakerCooldown, state: BreakerClosed}
+	app.pendingBlobs = map[string]int{}
+	app.events = NewEventBus[ArticleEvent]()
+	app.idempotentResponses = map[string]*idempotentResponse{}
+	app.listings = struct {
+		snapshot *[]model.Article // the articles data was encoded from
+		data     map[string][]byte
+	}{data: map[string][]byte{}}
+	app.messageCatalog = i18n.Bundled()
+	app.notifications = NewEventBus[notify.Notification]()
+	app.webhookClient = &http.Client{Timeout: 15 * time.Second}
+	app.userWebhookClient = externalClient
+	app.pushClient = externalClient
+	app.rateBuckets = map[string]*rateBucket{}
+	app.accessChanges = NewEventBus[ReplicationAccess]()
+	app.searchAlertClient = externalClient
+	app.articleService = storeArticleService{app}
+	app.shutdownRequested = make(chan struct{})
+	app.seenSignatures = map[string]bool{}
+	app.uploads = map[string]*uploadSession{}
+	app.setOpenAPIDocument(app.apiRoutes())
+
+	// Listeners that keep derived data current and queue the slow work of
+	// changes
+	app.events.Listen(func(ArticleEvent) { app.clearListings() })
+	app.events.Listen(app.recordActivity)
+	app.events.Listen(app.dropViews)
+	app.events.Listen(app.noteChangedForAlerts)
+	app.events.Listen(app.queueWebhooks)
+	app.notifications.Listen(app.queueUserNotifications)
+	app.notifications.Listen(app.queueChatPosts)
+	return app
+}
+
+// New loads the data in st into memory, seeding sample articles into an
+// empty store, and sets up attachment storage and the response cache from
+// cfg.
+func New(cfg config.Config, st store.DataStore) (*App, error) {
+	app := newApp(cfg)
+	if err := app.init(st); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+func (app *App) init(st store.DataStore) error {
+	cfg := app.cfg
+	app.dataStore = st
+	if err := app.initEncryption(cfg); err != nil {
+		return err
+	}
+	app.initDatabase()
+
+	var err error
+	if app.blobStore, err = store.NewBlobStore(app.cfg); err != nil {
+		return fmt.Errorf("configure blob storage: %w", err)
+	}
+	// Cache GET responses until articles change
+	if err := app.initResponseCache(app.cfg); err != nil {
+		return fmt.Errorf("configure response cache: %w", err)
+	}
+	if err := app.initJobQueue(app.cfg); err != nil {
+		return fmt.Errorf("open job queue: %w", err)
+	}
+	if err := app.initAnalytics(app.cfg); err != nil {
+		return fmt.Errorf("open ANALYTICS_FILE: %w", err)
+	}
+	if err := app.initActivity(app.cfg); err != nil {
+		return fmt.Errorf("open ACTIVITY_FILE: %w", err)
+	}
+	if err := app.initSearchLog(app.cfg); err != nil {
+		return fmt.Errorf("open SEARCH_LOG_FILE: %w", err)
+	}
+	if err := app.initNotifier(app.cfg); err != nil {
+		return fmt.Errorf("configure email: %w", err)
+	}
+	if err := app.initWebhooks(app.cfg); err != nil {
+		return fmt.Errorf("load WEBHOOKS_FILE: %w", err)
+	}
+	app.initSavedSearches(app.cfg)
+	app.initDigests(app.cfg)
+	if err := app.initSigningKeys(app.cfg); err != nil {
+		return fmt.Errorf("load SIGNING_KEYS_FILE: %w", err)
+	}
+	if err := app.initMessages(app.cfg); err != nil {
+		return fmt.Errorf("load MESSAGES_DIR: %w", err)
+	}
+	if err := app.initBlog(app.cfg); err != nil {
+		return fmt.Errorf("load THEME_DIR: %w", err)
+	}
+	if err := app.initPII(app.cfg); err != nil {
+		return fmt.Errorf("load PII_PATTERNS: %w", err)
+	}
+	app.initSpam(app.cfg)
+	app.initCaptcha(app.cfg)
+	app.initSummarizer(app.cfg)
+	app.initEmbeddings(app.cfg)
+	app.initSignedURLs(app.cfg)
+	initPublicIDs(app.cfg)
+	app.initMode(app.cfg)
+	if err := app.initElection(app.cfg); err != nil {
+		return fmt.Errorf("configure leader election: %w", err)
+	}
+	return app.checkMiddlewareConfig(app.cfg)
+}
+
+// OpenStore loads cfg.Store for a command that works offline. Unlike the
+// server, a missing file starts empty instead of with sample articles.
+// Commands that change data write the store directly, so a .gob file is
+// locked: OpenStore fails with store.ErrLocked while the server runs.
+func OpenStore(cfg config.Config) (*App, error) {
+	return openStoreWith(cfg, store.Open)
+}
+
+// OpenStoreReadOnly loads cfg.Store for a command that only reads it, even
+// while the server runs; Save fails with store.ErrReadOnly
+func OpenStoreReadOnly(cfg config.Config) (*App, error) {
+	return openStoreWith(cfg, store.OpenReadOnly)
+}
+
+func openStoreWith(cfg config.Config, open func(string) (store.DataStore, error)) (*App, error) {
+	app := newApp(cfg)
+	st, err := open(cfg.Store)
+	if err != nil {
+		return nil, err
+	}
+	app.dataStore = st
+	if err := app.initEncryption(cfg); err != nil {
+		st.Close()
+		return nil, err
+	}
+	if err := app.loadArticles(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		st.Close()
+		return nil, fmt.Errorf("load %s: %w", cfg.Store, err)
+	}
+	if app.blobStore, err = store.NewBlobStore(cfg); err != nil {
+		st.Close()
+		return nil, err
+	}
+	initPublicIDs(cfg)
+	if err := app.initNotifier(cfg); err != nil {
+		st.Close()
+		return nil, err
+	}
+	return app, nil
+}
+
+// Save writes the in-memory data to the store, after any saves still
+// running in the background
+func (app *App) Save() error {
+	return app.saveArticles()
+}
+
+// CloseStore releases the data store of OpenStore or OpenStoreReadOnly, after
+// the caller has saved
+func (app *App) CloseStore() error {
+	return app.dataStore.Close()
+}
+
+// ResetArticles deletes every article and its attachment files; users stay
+func (app *App) ResetArticles() {
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+	deleted := app.articles
+	app.articles = nil
+	for _, article := range deleted {
+		app.deleteAttachmentBlobs(article.Attachments)
+	}
+	app.articleIDs.Reset(1)
+}
+
+func (app *App) ArticleCount() int {
+	return len(app.readArticles())
+}
+
+func (app *App) Users() []model.User {
+	app.articlesMutex.RLock()
+	defer app.articlesMutex.RUnlock()
+	return slices.Clone(app.users)
+}
+
+// Router builds the HTTP handler for the route table
+func (app *App) Router() http.Handler {
+	router := mux.NewRouter()
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.writeError(w, r, http.StatusNotFound, CodeNotFound, "Not found")
+	})
+	router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.writeError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+	})
+
+	// Routes, then the ones added by plugins, each wrapped in the
+	// middleware stages that select it
+	stages := app.activeMiddleware(app.cfg)
+	routes := app.activeRoutes(app.cfg)
+	app.setOpenAPIDocument(routes)
+	for _, route := range routes {
+		router.Handle(route.Path, wrapRoute(stages, route, route.Handler)).Methods(route.Method)
+	}
+	return wrapRouter(stages, router)
+}
+
+// PrintRoutes lists the endpoints on stdout when the server starts
+func (app *App) PrintRoutes() {
+	fmt.Printf("Server starting on %s\n", app.cfg.Addr)
+	if app.cfg.GRPCAddr != "off" {
+		fmt.Printf("gRPC ArticleService listening on %s\n", app.cfg.GRPCAddr)
+	}
+	fmt.Println("Available endpoints:")
+	for _, route := range app.activeRoutes(app.cfg) {
+		fmt.Printf("%-6s %s - %s\n", route.Method, route.Path, route.Summary)
+	}
+	fmt.Println()
+	fmt.Printf("Data is persisted to: %s\n", app.cfg.Store)
+}
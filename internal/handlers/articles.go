@@ -0,0 +1,472 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+
+	"go-spring/internal/config"
+	"go-spring/internal/markdown"
+	"go-spring/internal/model"
+	"go-spring/internal/store"
+	"go-spring/internal/validate"
+)
+
+// RenderedArticle is an Article with its Markdown content rendered to HTML
+type RenderedArticle struct {
+	model.Article
+	ContentHTML string `json:"content_html"`
+}
+
+// MarshalJSON adds content_html to the article's JSON, which would
+// otherwise be all that the embedded Article writes
+func (a RenderedArticle) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(a.Article)
+	if err != nil {
+		return nil, err
+	}
+	html, err := json.Marshal(a.ContentHTML)
+	if err != nil {
+		return nil, err
+	}
+	data = append(data[:len(data)-1], `,"content_html":`...)
+	return append(append(data, html...), '}'), nil
+}
+
+func (a *RenderedArticle) UnmarshalJSON(data []byte) error {
+	var html struct {
+		ContentHTML string `json:"content_html"`
+	}
+	if err := json.Unmarshal(data, &html); err != nil {
+		return err
+	}
+	a.ContentHTML = html.ContentHTML
+	return json.Unmarshal(data, &a.Article)
+}
+
+type Response struct {
+	Message string                `json:"message"`
+	Data    interface{}           `json:"data,omitempty"`
+	Error   string                `json:"error,omitempty"`
+	Code    string                `json:"code,omitempty"`   // of an error, see errors.go
+	Fields  []validate.FieldError `json:"fields,omitempty"` // the invalid fields of a 400
+
+	Warnings      []Warning `json:"warnings,omitempty"`       // of a request that succeeded, see pii.go
+	SuggestedTags []string  `json:"suggested_tags,omitempty"` // keywords of a created or updated article, see tags.go
+	DidYouMean    string    `json:"did_you_mean,omitempty"`   // corrected query of a search, see search.go
+
+	Links map[string]string `json:"-"` // of a paged listing, written by the JSON:API codec
+}
+
+type articlesState struct {
+	// Settings, set by New and the commands that open the store
+	cfg config.Config
+
+	// The configured store (STORE)
+	dataStore store.DataStore
+
+	// In-memory storage with file persistence. Article IDs are handed out
+	// without articlesMutex, so creates don't wait for each other.
+	articles      []model.Article
+	articleIDs    store.Sequence
+	articlesMutex articlesLock // see snapshot.go
+
+	// Serializes writers of the data store; saves run in the background
+	saveMutex sync.Mutex
+}
+
+// Initialize database (load from file or generate sample data)
+func (app *App) initDatabase() {
+	// Try to load existing data
+	if err := app.loadArticles(); err != nil {
+		fmt.Printf("No existing data found, generating %d sample articles...\n", app.cfg.SeedArticles)
+		app.SeedStore(GenerateArticles(app.cfg.SeedArticles, 0))
+		app.saveArticles()
+	} else {
+		fmt.Println("Articles loaded from file!")
+	}
+
+	fmt.Printf("Database initialized with %d articles!\n", len(app.articles))
+}
+
+// Load articles from the data store
+func (app *App) loadArticles() error {
+	var data store.Database
+	err := app.callDataStore(func(ctx context.Context) (err error) {
+		data, err = app.dataStore.Load(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+
+	for _, name := range store.Migrate(&data) {
+		log.Printf("Migrated data file: %s", name)
+	}
+
+	app.articles = data.Articles
+	// Never below a stored ID, whatever NextID says
+	app.articleIDs.Reset(data.NextID)
+	if n := len(app.articles); n > 0 {
+		app.articleIDs.Advance(app.articles[n-1].ID + 1)
+	}
+	app.attachmentIDs.Reset(data.NextAttachmentID)
+	app.users = data.Users
+	app.noteUserEndpoints()
+	app.workspaces = data.Workspaces
+	app.assignMissingSlugs()
+	app.assignMissingUIDs()
+	return nil
+}
+
+// Save articles to the data store. A store that takes longer than
+// STORE_TIMEOUT, or whose breaker is open, fails the save rather than
+// keeping writers waiting for the read lock.
+func (app *App) saveArticles() error {
+	app.saveMutex.Lock()
+	defer app.saveMutex.Unlock()
+
+	app.articlesMutex.RLock()
+	defer app.articlesMutex.RUnlock()
+	data := app.currentDatabase()
+	app.publishAccess()
+	return app.callDataStore(func(ctx context.Context) error { return app.dataStore.Save(ctx, data) })
+}
+
+// The articles and the rest of the data as saved; the caller holds
+// articlesMutex. The next IDs are read after the articles, so they are
+// above every ID saved.
+func (app *App) currentDatabase() store.Database {
+	return store.Database{
+		Articles:         app.articles,
+		NextID:           app.articleIDs.Peek(),
+		NextAttachmentID: app.attachmentIDs.Peek(),
+		Users:            app.users,
+		Workspaces:       app.workspaces,
+		Version:          len(store.Migrations),
+	}
+}
+
+// GET /articles - Get all articles
+func (app *App) getAllArticles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Paged listing in ID order, the same as ListArticles over gRPC
+	query := r.URL.Query()
+	paged := query.Has("page_size") || query.Has("page_token")
+	pageSize, _ := strconv.Atoi(query.Get("page_size"))
+	lang := query.Get("language")
+	if lang != "" {
+		var ok bool
+		if lang, ok = languageTag(lang); !ok {
+			app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid language tag")
+			return
+		}
+	}
+	mayRead := app.requestReader(r)
+	listPage := func() (any, error) {
+		resp, err := app.articleService.List(app.readerContext(r), ListArticlesRequest{
+			PageSize:  pageSize,
+			PageToken: query.Get("page_token"),
+			Language:  lang,
+		})
+		resp.Articles = withholdContent(resp.Articles, mayRead)
+		return resp, err
+	}
+	listAll := func() (any, error) {
+		list := app.withoutHeld(r, slices.Clone(app.readArticles()))
+		if lang != "" {
+			list = slices.DeleteFunc(list, func(a model.Article) bool { return !app.inLanguage(lang)(a) })
+		}
+
+		// Pinned articles are listed first, otherwise keep storage order
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].Pinned && !list[j].Pinned
+		})
+		return withholdContent(app.runOnServeList(r.Context(), list), mayRead), nil
+	}
+
+	// The common listings are kept marshaled, see listing.go. They are
+	// anonymous, and whether those see held articles changes only with
+	// the first user, which takes a new snapshot too.
+	if app.cachedListing(r) {
+		key, build := "all", listAll
+		if paged {
+			key, build = "page "+strconv.Itoa(pageSize)+" "+query.Get("page_token"), listPage
+		}
+		if lang != "" {
+			key += " in " + lang
+		}
+		data, err := app.listingJSON(key, build)
+		if err != nil {
+			app.writeArticleError(w, r, err)
+			return
+		}
+		app.writeListing(w, r, "Articles retrieved successfully", data)
+		return
+	}
+
+	build := listAll
+	if paged {
+		build = listPage
+	}
+	data, err := build()
+	if err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+	response := Response{Message: "Articles retrieved successfully", Data: data}
+	if page, ok := data.(ListArticlesResponse); ok {
+		response.Links = pageLinks(r, page.NextPageToken)
+	}
+	app.writeResponse(w, r, http.StatusOK, response)
+}
+
+// GET /articles/{id} - Get single article
+func (app *App) getArticle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := app.articleRouteID(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid article ID")
+		return
+	}
+
+	article, err := app.articleService.Get(app.readerContext(r), id)
+	if err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+	app.writeArticle(w, r, article)
+}
+
+// Write an article in the request's language, rendered too with
+// ?format=html
+func (app *App) writeArticle(w http.ResponseWriter, r *http.Request, article model.Article) {
+	article = localizedArticle(r, app.withheldArticle(r, article))
+	var data interface{} = article
+	if r.URL.Query().Get("format") == "html" {
+		data = RenderedArticle{
+			Article:     article,
+			ContentHTML: app.renderArticleContent(article),
+		}
+	}
+	response := Response{
+		Message: "Article retrieved successfully",
+		Data:    data,
+	}
+	app.writeResponse(w, r, http.StatusOK, response)
+}
+
+// GET /articles/{id}/html - Get article content rendered from Markdown
+func (app *App) getArticleHTML(w http.ResponseWriter, r *http.Request) {
+	id, err := app.articleRouteID(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid article ID")
+		return
+	}
+
+	if article, ok := app.requestArticle(r, id); ok {
+		if !app.allowContent(w, r, article) {
+			return
+		}
+		article = localizedArticle(r, article)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Language", app.articleLanguage(article))
+		w.Header().Add("Vary", "Accept-Language")
+		w.Write([]byte(app.renderArticleContent(article)))
+		return
+	}
+
+	app.writeError(w, r, http.StatusNotFound, CodeArticleNotFound, "Article not found")
+}
+
+// GET /articles/{id}/content - Get the Markdown content, or the range of it
+// a Range header asks for, e.g. the first KB for a preview
+func (app *App) getArticleContent(w http.ResponseWriter, r *http.Request) {
+	id, err := app.articleRouteID(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid article ID")
+		return
+	}
+	article, ok := app.requestArticle(r, id)
+	if !ok {
+		app.writeError(w, r, http.StatusNotFound, CodeArticleNotFound, "Article not found")
+		return
+	}
+	if !app.allowContent(w, r, article) {
+		return
+	}
+
+	content := article.Content
+	acceptRanges(w, article.Updated)
+	start, length, ok, err := requestedRange(r, int64(len(content)), article.Updated)
+	if err != nil {
+		app.writeRangeNotSatisfiable(w, r, int64(len(content)))
+		return
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	if !ok {
+		io.WriteString(w, content)
+		return
+	}
+	setContentRange(w, start, length, int64(len(content)))
+	w.WriteHeader(http.StatusPartialContent)
+	io.WriteString(w, content[start:start+length])
+}
+
+// Render an article's Markdown content with the configured extensions
+func (app *App) renderArticleContent(article model.Article) string {
+	return markdown.Render(article.Content, markdown.Options{
+		Tables:    app.cfg.MarkdownTables,
+		Highlight: app.cfg.MarkdownHighlight,
+		HTML:      app.cfg.SanitizeMode,
+	})
+}
+
+// POST /articles - Create new article
+func (app *App) createArticle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req model.CreateArticleRequest
+	if err := decodeBody(r, &req); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	ctx, warnings := collectWarnings(withSubmitter(r.Context(), r))
+	ctx, tags := collectSuggestedTags(ctx)
+	article, err := app.articleService.Create(ctx, req)
+	if err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+
+	response := Response{
+		Message:       "Article created successfully",
+		Data:          article,
+		Warnings:      *warnings,
+		SuggestedTags: *tags,
+	}
+	if article.Moderation != nil {
+		response.Message = "Article submitted for moderation"
+		app.writeResponse(w, r, http.StatusAccepted, response)
+		return
+	}
+
+	app.writeResponse(w, r, http.StatusCreated, response)
+}
+
+// PUT /articles/{id} - Update article
+func (app *App) updateArticle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := app.articleRouteID(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid article ID")
+		return
+	}
+
+	var updateData model.ArticleUpdate
+	if err := decodeBody(r, &updateData); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	updateData.ID = id
+	ctx, warnings := collectWarnings(r.Context())
+	ctx, tags := collectSuggestedTags(ctx)
+	article, err := app.articleService.Update(ctx, updateData)
+	if err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+
+	response := Response{
+		Message:       "Article updated successfully",
+		Data:          article,
+		Warnings:      *warnings,
+		SuggestedTags: *tags,
+	}
+	app.writeResponse(w, r, http.StatusOK, response)
+}
+
+// DELETE /articles/{id} - Delete article
+func (app *App) deleteArticle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := app.articleRouteID(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid article ID")
+		return
+	}
+
+	if err := app.articleService.Delete(r.Context(), id); err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+
+	response := Response{
+		Message: "Article deleted successfully",
+	}
+	app.writeResponse(w, r, http.StatusOK, response)
+}
+
+// Map article operation errors to HTTP responses
+func (app *App) writeArticleError(w http.ResponseWriter, r *http.Request, err error) {
+	var validation *ValidationError
+	switch {
+	case errors.As(err, &validation):
+		app.writeValidationError(w, r, validation)
+	case errors.Is(err, ErrArticleNotFound):
+		app.writeError(w, r, http.StatusNotFound, CodeArticleNotFound, "Article not found")
+	case errors.Is(err, ErrQuotaExceeded):
+		app.writeError(w, r, http.StatusForbidden, CodeQuotaExceeded, "Article quota exceeded")
+	case errors.Is(err, ErrHeldForModeration):
+		app.writeError(w, r, http.StatusConflict, CodeConflict, "Article is held for moderation")
+	case errors.Is(err, ErrSummarizerFailed):
+		log.Printf("Error: %v", err)
+		app.writeError(w, r, http.StatusBadGateway, CodeUpstreamFailed, "Summarizer failed")
+	default:
+		log.Printf("Error: %v", err)
+		app.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Internal server error")
+	}
+}
+
+// Write a response envelope in the representation and language the client
+// asked for
+func (app *App) writeResponse(w http.ResponseWriter, r *http.Request, status int, response Response) {
+	app.localize(w, r, &response)
+	codec := negotiateCodec(r)
+	w.Header().Set("Content-Type", codec.ContentType())
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	if err := codec.Encode(w, response); err != nil {
+		log.Printf("Error: encoding %s response: %v", codec.ContentType(), err)
+	}
+}
+
+// Decode a request body in the representation named by its Content-Type
+func decodeBody(r *http.Request, v any) error {
+	return requestCodec(r).Decode(r.Body, v)
+}
+
+// Home page
+func (app *App) homePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	response := Response{
+		Message: "Welcome to the Go Spring API with persistent file storage! Use /articles for CRUD operations.",
+	}
+	app.writeResponse(w, r, http.StatusOK, response)
+}
@@ -0,0 +1,3465 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-spring/client"
+	"go-spring/internal/config"
+	"go-spring/internal/embeddings"
+	"go-spring/internal/i18n"
+	"go-spring/internal/jobs"
+	"go-spring/internal/model"
+	"go-spring/internal/notify"
+	"go-spring/internal/spam"
+	"go-spring/internal/store"
+	"go-spring/internal/summarize"
+	"go-spring/internal/webpush"
+)
+
+// memStore is a DataStore that keeps the last saved database in memory
+type memStore struct {
+	db    store.Database
+	saved bool
+}
+
+func (s *memStore) Load(ctx context.Context) (store.Database, error) {
+	if !s.saved {
+		return store.Database{}, os.ErrNotExist
+	}
+	return s.db, nil
+}
+
+func (s *memStore) Save(ctx context.Context, db store.Database) error {
+	s.db = db
+	s.db.Articles = slices.Clone(db.Articles)
+	s.saved = true
+	return nil
+}
+
+func (s *memStore) PutArticles(ctx context.Context, batch []model.Article) error {
+	s.db.Articles = append(s.db.Articles, batch...)
+	return nil
+}
+
+func (s *memStore) LastArticleID(ctx context.Context) (int, error) {
+	if len(s.db.Articles) == 0 {
+		return 0, nil
+	}
+	return s.db.Articles[len(s.db.Articles)-1].ID, nil
+}
+
+func (s *memStore) SaveMeta(ctx context.Context, db store.Database) error {
+	s.db.NextID, s.db.NextAttachmentID, s.db.Users = db.NextID, db.NextAttachmentID, db.Users
+	return nil
+}
+
+func (s *memStore) Close() error { return nil }
+
+// testServer is an App serving its API over HTTP
+type testServer struct {
+	*httptest.Server
+	*App
+}
+
+// The configuration of test servers: an empty store with n generated
+// articles, and nothing written to files
+func testConfig(t testing.TB, n int) config.Config {
+	cfg := config.Load()
+	cfg.SeedArticles = n
+	cfg.AttachmentsDir = t.TempDir()
+	cfg.ResponseCacheTTL = 0
+	cfg.JobsFile = "off"
+	cfg.AnalyticsFile = "off"
+	cfg.ActivityFile = "off"
+	cfg.SearchLogFile = "off"
+	cfg.MiddlewareDisable = []string{"log"}
+	return cfg
+}
+
+// Start the API on an empty store with n generated articles
+func newTestServer(t testing.TB, n int) *testServer {
+	t.Helper()
+	return startTestServer(t, testConfig(t, n))
+}
+
+// Start the API with cfg on an empty store
+func startTestServer(t testing.TB, cfg config.Config) *testServer {
+	t.Helper()
+	app, err := New(cfg, &memStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &testServer{Server: httptest.NewServer(app.Router()), App: app}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// Serve a router built anew, after a test changed the configuration or
+// added plugins
+func (srv *testServer) reroute() {
+	srv.Config.Handler = srv.Router()
+}
+
+// Send a request and decode the data field of the response envelope into out
+func call(t *testing.T, method, url, body string, out any) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if out != nil && resp.StatusCode < 300 {
+		var envelope struct{ Data json.RawMessage }
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			t.Fatalf("%s %s: %v in %s", method, url, err, data)
+		}
+		if err := json.Unmarshal(envelope.Data, out); err != nil {
+			t.Fatalf("%s %s: %v in %s", method, url, err, envelope.Data)
+		}
+	}
+	return resp
+}
+
+// POST data as the multipart "file" field, decoding the response as call does
+func upload(t *testing.T, url, filename string, data []byte, out any) *http.Response {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", filename)
+	fw.Write(data)
+	mw.Close()
+	resp, err := http.Post(url, mw.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(&Response{Data: out}); err != nil {
+			t.Fatalf("POST %s: %v", url, err)
+		}
+	}
+	return resp
+}
+
+func TestArticleCRUD(t *testing.T) {
+	srv := newTestServer(t, 0)
+
+	var created model.Article
+	resp := call(t, "POST", srv.URL+"/articles", `{"title":"Hello","desc":"First","content":"Some *text*"}`, &created)
+	if resp.StatusCode != http.StatusCreated || created.ID != 1 || created.Status != model.StatusPublished {
+		t.Fatalf("create: status %d, article %+v", resp.StatusCode, created)
+	}
+
+	var got model.Article
+	if resp := call(t, "GET", srv.URL+"/articles/1", "", &got); resp.StatusCode != http.StatusOK || got.Title != "Hello" {
+		t.Fatalf("get: status %d, article %+v", resp.StatusCode, got)
+	}
+
+	var updated model.Article
+	resp = call(t, "PUT", srv.URL+"/articles/1", `{"title":"Hello again","pinned":true}`, &updated)
+	if resp.StatusCode != http.StatusOK || updated.Title != "Hello again" || updated.Desc != "First" || !updated.Pinned {
+		t.Fatalf("update: status %d, article %+v", resp.StatusCode, updated)
+	}
+
+	if resp := call(t, "DELETE", srv.URL+"/articles/1", "", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete: status %d", resp.StatusCode)
+	}
+	if resp := call(t, "GET", srv.URL+"/articles/1", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("get after delete: status %d, want 404", resp.StatusCode)
+	}
+}
+
+// Two servers in one process keep their articles and configuration apart
+func TestSeveralServers(t *testing.T) {
+	first, second := newTestServer(t, 0), newTestServer(t, 0)
+	second.cfg.FeedTitle = "Second"
+	second.reroute()
+
+	if resp := call(t, "POST", first.URL+"/articles", `{"title":"Only here","desc":"First","content":"Some text"}`, nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create on the first: status %d", resp.StatusCode)
+	}
+	if resp := call(t, "GET", second.URL+"/articles/1", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("second server has the first's article: status %d", resp.StatusCode)
+	}
+	for _, tt := range []struct {
+		srv   *testServer
+		title string
+	}{{first, first.cfg.FeedTitle}, {second, "Second"}} {
+		resp, err := http.Get(tt.srv.URL + "/feed.rss")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !strings.Contains(string(body), "<title>"+tt.title+"</title>") {
+			t.Errorf("feed of %s lacks its title %q:\n%s", tt.srv.URL, tt.title, body)
+		}
+	}
+}
+
+func TestArticleSlugs(t *testing.T) {
+	srv := newTestServer(t, 0)
+
+	var first, second model.Article
+	call(t, "POST", srv.URL+"/articles", `{"title":"Hyvää yötä!","desc":"d","content":"c"}`, &first)
+	call(t, "POST", srv.URL+"/articles", `{"title":"Hyvää yötä","desc":"d","content":"c"}`, &second)
+	if first.Slug != "hyvaa-yota" || second.Slug != "hyvaa-yota-2" {
+		t.Fatalf("slugs %q and %q, want hyvaa-yota and hyvaa-yota-2", first.Slug, second.Slug)
+	}
+
+	// A new title keeps the slug, so links stay valid
+	var updated, got model.Article
+	call(t, "PUT", fmt.Sprintf("%s/articles/%d", srv.URL, first.ID), `{"title":"Hyvää huomenta"}`, &updated)
+	if resp := call(t, "GET", srv.URL+"/articles/by-slug/hyvaa-yota", "", &got); resp.StatusCode != http.StatusOK || got.ID != first.ID || got.Slug != updated.Slug {
+		t.Fatalf("get by slug: status %d, article %+v", resp.StatusCode, got)
+	}
+	if resp := call(t, "GET", srv.URL+"/articles/by-slug/missing", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown slug: status %d, want 404", resp.StatusCode)
+	}
+
+	srv.cfg.SlugStrategy = config.SlugPercent
+	var cjk model.Article
+	call(t, "POST", srv.URL+"/articles", `{"title":"東京の夜 🌃","desc":"d","content":"c"}`, &cjk)
+	if resp := call(t, "GET", srv.URL+"/articles/by-slug/%E6%9D%B1%E4%BA%AC%E3%81%AE%E5%A4%9C", "", &got); resp.StatusCode != http.StatusOK || got.ID != cjk.ID {
+		t.Fatalf("percent-encoded slug %q: status %d, article %+v", cjk.Slug, resp.StatusCode, got)
+	}
+}
+
+func TestArticleUIDs(t *testing.T) {
+	srv := newTestServer(t, 1)
+	srv.cfg.IDStrategy = config.IDULID
+
+	var created, got model.Article
+	call(t, "POST", srv.URL+"/articles", `{"title":"t","desc":"d","content":"c"}`, &created)
+	if len(created.UID) != 26 {
+		t.Fatalf("uid %q, want a ULID", created.UID)
+	}
+	for _, id := range []string{created.UID, strings.ToLower(created.UID), fmt.Sprint(created.ID)} {
+		if resp := call(t, "GET", srv.URL+"/articles/"+id, "", &got); resp.StatusCode != http.StatusOK || got.ID != created.ID {
+			t.Errorf("get %s: status %d, article %d", id, resp.StatusCode, got.ID)
+		}
+	}
+	if resp := call(t, "GET", srv.URL+"/articles/01ARYZ6S41TSV4RRFFQ69G5FAV", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown uid: status %d, want 404", resp.StatusCode)
+	}
+
+	// The seeded article gets a uid once the store is loaded again
+	srv.Save()
+	if err := srv.loadArticles(); err != nil {
+		t.Fatal(err)
+	}
+	call(t, "GET", srv.URL+"/articles/1", "", &got)
+	if len(got.UID) != 26 || got.UID >= created.UID {
+		t.Errorf("seeded article uid %q, want a ULID before %q", got.UID, created.UID)
+	}
+}
+
+func TestPublicIDs(t *testing.T) {
+	srv := newTestServer(t, 3)
+	srv.cfg.HashidsSalt, srv.cfg.HashidsMinLength = "test salt", 8
+	initPublicIDs(srv.cfg)
+	t.Cleanup(func() { model.PublicIDs = nil })
+
+	var page struct {
+		Articles []struct {
+			ID any `json:"id"`
+		} `json:"articles"`
+		NextPageToken string `json:"next_page_token"`
+	}
+	call(t, "GET", srv.URL+"/articles?page_size=2", "", &page)
+	public, ok := page.Articles[0].ID.(string)
+	if !ok || len(public) < 8 {
+		t.Fatalf("id %v, want a hashid", page.Articles[0].ID)
+	}
+	if token, _ := base64.RawURLEncoding.DecodeString(page.NextPageToken); strings.HasSuffix(string(token), ":2") {
+		t.Errorf("page token %q shows the integer ID", token)
+	}
+
+	var got model.Article
+	if resp := call(t, "GET", srv.URL+"/articles/"+public, "", &got); resp.StatusCode != http.StatusOK || got.ID != 1 {
+		t.Fatalf("get %s: status %d, article %d", public, resp.StatusCode, got.ID)
+	}
+	if resp := call(t, "GET", srv.URL+"/articles/1", "", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("get by integer ID: status %d, want 400", resp.StatusCode)
+	}
+
+	// Bodies take public IDs too
+	if resp := call(t, "PUT", srv.URL+"/articles/featured/order", fmt.Sprintf(`{"ids":[%q]}`, public), nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("featured order: status %d", resp.StatusCode)
+	}
+	if resp := call(t, "PUT", srv.URL+"/articles/featured/order", `{"ids":[1]}`, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("featured order by integer ID: status %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestCreateArticleValidation(t *testing.T) {
+	srv := newTestServer(t, 0)
+
+	tests := []struct {
+		name, body string
+		fields     []string // the invalid fields reported, by rule
+	}{
+		{"missing fields", `{"title":"Only a title"}`, []string{"desc:required", "content:required"}},
+		{"blank after sanitizing", `{"title":"<script>x</script>","desc":"d","content":"c"}`, []string{"title:required"}},
+		{"too long", `{"title":"` + strings.Repeat("é", 201) + `","desc":"d","content":"c"}`, []string{"title:max"}},
+		{"bad status", `{"title":"t","desc":"d","content":"c","status":"archived"}`, []string{"status:oneof"}},
+		{"bad JSON", `{"title":`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(srv.URL+"/articles", "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("status %d, want 400", resp.StatusCode)
+			}
+			if tt.fields == nil {
+				return
+			}
+			var body Response
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range body.Fields {
+				got = append(got, f.Field+":"+f.Rule)
+			}
+			if !slices.Equal(got, tt.fields) || body.Error == "" {
+				t.Errorf("fields %v, error %q, want %v", got, body.Error, tt.fields)
+			}
+		})
+	}
+	if n := srv.ArticleCount(); n != 0 {
+		t.Errorf("%d articles stored after invalid requests", n)
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	srv := newTestServer(t, 1)
+	srv.AddPlugin(Plugin{Name: "legacy", Routes: []PluginRoute{{Method: "GET", Path: "/legacy", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Gone fishing", http.StatusTeapot)
+	})}}})
+	srv.reroute()
+
+	tests := []struct {
+		method, path, accept string
+		status               int
+		code                 string
+	}{
+		{"GET", "/articles/99", "", http.StatusNotFound, CodeArticleNotFound},
+		{"GET", "/articles/x", "", http.StatusBadRequest, CodeInvalidID},
+		{"GET", "/nowhere", "", http.StatusNotFound, CodeNotFound},
+		{"PATCH", "/articles", "", http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{"GET", "/legacy", "", http.StatusTeapot, "I'M_A_TEAPOT"},
+		{"GET", "/articles/99", "application/yaml", http.StatusNotFound, CodeArticleNotFound},
+		{"GET", "/articles/99", jsonAPIMediaType, http.StatusNotFound, CodeArticleNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path+" "+tt.accept, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, srv.URL+tt.path, nil)
+			req.Header.Set("Accept", tt.accept)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			data, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status %d, want %d", resp.StatusCode, tt.status)
+			}
+			var code string
+			switch tt.accept {
+			case "":
+				var body Response
+				json.Unmarshal(data, &body)
+				code = body.Code
+			case jsonAPIMediaType:
+				var doc jsonAPIDocument
+				json.Unmarshal(data, &doc)
+				if len(doc.Errors) == 1 {
+					code = doc.Errors[0].Code
+				}
+			default:
+				if strings.Contains(string(data), "code: "+tt.code) {
+					code = tt.code
+				}
+			}
+			if code != tt.code {
+				t.Errorf("code %q, want %q in %s", code, tt.code, data)
+			}
+		})
+	}
+}
+
+func TestLocalizedMessages(t *testing.T) {
+	srv := newTestServer(t, 1)
+	srv.AddPlugin(Plugin{Name: "svenska", Messages: i18n.Catalog{"sv": {"Article not found": "Artikeln hittades inte"}}})
+	srv.reroute()
+
+	get := func(method, path, lang, body string) (*http.Response, Response) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		req.Header.Set("Accept-Language", lang)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var envelope Response
+		json.NewDecoder(resp.Body).Decode(&envelope)
+		return resp, envelope
+	}
+
+	resp, body := get("GET", "/articles/99", "fi-FI,en;q=0.5", "")
+	if body.Error != "Artikkelia ei löydy" || body.Message != "Ei löydy" || body.Code != CodeArticleNotFound || resp.Header.Get("Content-Language") != "fi" {
+		t.Errorf("fi: %+v, Content-Language %q", body, resp.Header.Get("Content-Language"))
+	}
+	if _, body := get("GET", "/articles/99", "sv", ""); body.Error != "Artikeln hittades inte" || body.Message != "Not Found" {
+		t.Errorf("sv from plugin: %+v", body)
+	}
+	if _, body := get("GET", "/articles/99", "de", ""); body.Error != "Article not found" {
+		t.Errorf("unknown language: %+v", body)
+	}
+	if _, body := get("GET", "/articles/x", "fi", ""); body.Error != "Virheellinen artikkelin tunnus" {
+		t.Errorf("fi: %+v", body)
+	}
+
+	_, body = get("POST", "/articles", "fi", `{"title":"t","status":"archived"}`)
+	if body.Error != "desc on pakollinen; content on pakollinen; status on oltava jokin näistä: draft, published" || len(body.Fields) != 3 {
+		t.Errorf("fi validation: %+v", body)
+	}
+
+	// Success messages too
+	_, fi := get("GET", "/articles", "fi", "")
+	resp, en := get("GET", "/articles", "", "")
+	if fi.Message != "Artikkelit haettu" || en.Message != "Articles retrieved successfully" || resp.Header.Get("Content-Language") != "en" {
+		t.Errorf("listing messages %q and %q", fi.Message, en.Message)
+	}
+}
+
+func TestListArticlesPaging(t *testing.T) {
+	srv := newTestServer(t, 5)
+
+	var ids []int
+	url := srv.URL + "/articles?page_size=2"
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("paging did not end")
+		}
+		var page ListArticlesResponse
+		if resp := call(t, "GET", url, "", &page); resp.StatusCode != http.StatusOK {
+			t.Fatalf("status %d", resp.StatusCode)
+		}
+		for _, a := range page.Articles {
+			ids = append(ids, a.ID)
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		url = srv.URL + "/articles?page_size=2&page_token=" + page.NextPageToken
+	}
+	if want := []int{1, 2, 3, 4, 5}; !slices.Equal(ids, want) {
+		t.Errorf("paged IDs %v, want %v", ids, want)
+	}
+
+	if resp := call(t, "GET", srv.URL+"/articles?page_token=nonsense", "", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid token: status %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestStats(t *testing.T) {
+	srv := newTestServer(t, 0)
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv.articlesMutex.Lock()
+	srv.articles = []model.Article{
+		{ID: 1, Content: "12345678", Created: created, Updated: created, Categories: []string{"News"}, Workspace: "docs"},
+		{ID: 2, Content: "1234", Status: model.StatusDraft, Created: created.AddDate(0, 1, 0), Updated: created.AddDate(0, 2, 0),
+			Categories: []string{"News", "Go"}, Attachments: []model.Attachment{{ID: 1, Size: 100}}},
+	}
+	srv.articlesMutex.Unlock()
+
+	var stats Stats
+	if resp := call(t, "GET", srv.URL+"/stats", "", &stats); resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	if stats.Articles != 2 || stats.ByStatus["published"] != 1 || stats.ByStatus["draft"] != 1 ||
+		stats.ByCategory["News"] != 2 || stats.ByCategory["Go"] != 1 || stats.ByWorkspace["docs"] != 1 {
+		t.Errorf("counts %+v", stats)
+	}
+	if stats.ContentBytes != 12 || stats.AverageContentBytes != 6 || stats.Attachments != 1 || stats.AttachmentBytes != 100 {
+		t.Errorf("sizes %+v", stats)
+	}
+	if !stats.Oldest.Equal(created) || !stats.Newest.Equal(created.AddDate(0, 1, 0)) || !stats.LastUpdated.Equal(created.AddDate(0, 2, 0)) {
+		t.Errorf("dates %v, %v, %v", stats.Oldest, stats.Newest, stats.LastUpdated)
+	}
+	if stats.Store.Backend != "gob" || stats.Store.DataStore.State != "closed" {
+		t.Errorf("store %+v", stats.Store)
+	}
+}
+
+func TestArticleAnalytics(t *testing.T) {
+	srv := newTestServer(t, 2)
+	article, _ := srv.readArticle(1)
+	for _, path := range []string{"/articles/1", "/articles/1", "/articles/1/html", "/articles/by-slug/" + article.Slug, "/articles/99"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	var stats ArticleAnalytics
+	if resp := call(t, "GET", srv.URL+"/articles/1/analytics", "", &stats); resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	if stats.Interval != "day" || len(stats.Points) != 31 || stats.Points[30].Views != 4 || stats.Views != 4 || stats.TotalViews != 4 {
+		t.Errorf("daily analytics %+v", stats)
+	}
+	call(t, "GET", srv.URL+"/articles/1/analytics?interval=hour", "", &stats)
+	if len(stats.Points) != 49 || stats.Views != 4 {
+		t.Errorf("hourly analytics: %d points, %d views", len(stats.Points), stats.Views)
+	}
+	for _, query := range []string{"interval=week", "from=yesterday", "from=2026-01-02&to=2026-01-01", "interval=hour&from=2020-01-01"} {
+		if resp := call(t, "GET", srv.URL+"/articles/1/analytics?"+query, "", nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, resp.StatusCode)
+		}
+	}
+
+	call(t, "DELETE", srv.URL+"/articles/1", "", nil)
+	if n := srv.viewCounter.Total(1); n != 0 {
+		t.Errorf("%d views kept after deleting the article", n)
+	}
+}
+
+func TestTrendingArticles(t *testing.T) {
+	srv := newTestServer(t, 3)
+	now := time.Now()
+	srv.viewCounter.Record(1, now.Add(-72*time.Hour))
+	srv.viewCounter.Record(1, now.Add(-72*time.Hour))
+	srv.viewCounter.Record(1, now.Add(-72*time.Hour))
+	srv.viewCounter.Record(2, now)
+	srv.viewCounter.Record(2, now)
+	srv.viewCounter.Record(3, now)
+	srv.articlesMutex.Lock()
+	srv.articles[0].Status = model.StatusPublished // seeded articles may be drafts
+	srv.articles[1].Status = model.StatusPublished
+	srv.articles[2].Status = model.StatusDraft
+	srv.articlesMutex.Unlock()
+	srv.recomputeTrending(now)
+
+	// Two views now outrank three from three half-lives ago; drafts are left out
+	var list []TrendingArticle
+	if resp := call(t, "GET", srv.URL+"/articles/trending", "", &list); resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	if len(list) != 2 || list[0].Article.ID != 2 || list[1].Article.ID != 1 || list[0].Score <= list[1].Score {
+		t.Errorf("trending %+v", list)
+	}
+	call(t, "GET", srv.URL+"/articles/trending?limit=1", "", &list)
+	if len(list) != 1 {
+		t.Errorf("%d articles with limit=1", len(list))
+	}
+	if resp := call(t, "GET", srv.URL+"/articles/trending?limit=0", "", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("limit=0: status %d", resp.StatusCode)
+	}
+}
+
+func TestListingCache(t *testing.T) {
+	srv := newTestServer(t, 3)
+	get := func(url, accept string) []byte {
+		t.Helper()
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Set("Accept", accept)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: status %d", url, resp.StatusCode)
+		}
+		return body
+	}
+	cached := func(key string) bool {
+		srv.listingsMutex.Lock()
+		defer srv.listingsMutex.Unlock()
+		_, ok := srv.listings.data[key]
+		return ok && srv.listings.snapshot == srv.loadSnapshot()
+	}
+
+	// The cached bytes are what the codec would have written
+	first := get(srv.URL+"/articles", "application/json")
+	if !cached("all") {
+		t.Fatal("listing not cached after a GET")
+	}
+	// Seeded articles may be pinned, which lists them first
+	listed := slices.Clone(srv.readArticles())
+	sort.SliceStable(listed, func(i, j int) bool { return listed[i].Pinned && !listed[j].Pinned })
+	var want bytes.Buffer
+	json.NewEncoder(&want).Encode(Response{Message: "Articles retrieved successfully", Data: listed})
+	if again := get(srv.URL+"/articles", "application/json"); !bytes.Equal(first, want.Bytes()) || !bytes.Equal(again, first) {
+		t.Errorf("cached listing\n%s\nwant\n%s", again, want.Bytes())
+	}
+	get(srv.URL+"/articles?page_size=2", "")
+	if !cached("page 2 ") {
+		t.Error("first page not cached")
+	}
+	if body := get(srv.URL+"/articles", "application/x-msgpack"); bytes.HasPrefix(body, []byte("{")) {
+		t.Errorf("msgpack listing served from the JSON cache: %s", body)
+	}
+
+	// A write drops the listings
+	call(t, "POST", srv.URL+"/articles", `{"title":"Fresh","desc":"d","content":"c"}`, nil)
+	if cached("all") || cached("page 2 ") {
+		t.Error("listings still cached after a write")
+	}
+	var list []model.Article
+	call(t, "GET", srv.URL+"/articles", "", &list)
+	if len(list) != 4 || list[3].Title != "Fresh" {
+		t.Errorf("listing after a write has %d articles", len(list))
+	}
+}
+
+func TestStreamedJSON(t *testing.T) {
+	srv := newTestServer(t, 3)
+	type pointerMarshaler struct{ N int }
+	for _, response := range []Response{
+		{Message: "Articles <retrieved>", Data: srv.readArticles()},
+		{Message: "Empty", Data: []model.Article{}},
+		{Message: "Nil", Data: []model.Article(nil)},
+		{Message: "Bytes", Data: []byte("abc")},
+		{Message: "Pages", Data: ListArticlesResponse{Articles: srv.readArticles()}},
+		{Message: "Pointers", Data: []*pointerMarshaler{{1}, nil}},
+		{Error: "Failed", Code: CodeInternal, Data: []int{1}},
+	} {
+		var want, got bytes.Buffer
+		json.NewEncoder(&want).Encode(response)
+		if err := (jsonCodec{}).Encode(&got, response); err != nil || got.String() != want.String() {
+			t.Errorf("%s: %v\n%s\nwant\n%s", response.Message, err, got.Bytes(), want.Bytes())
+		}
+	}
+}
+
+func TestIdempotentCreate(t *testing.T) {
+	srv := newTestServer(t, 0)
+
+	for i := range 2 {
+		req, _ := http.NewRequest("POST", srv.URL+"/articles", strings.NewReader(`{"title":"t","desc":"d","content":"c"}`))
+		req.Header.Set("Idempotency-Key", "key-1")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		replayed := resp.Header.Get("Idempotent-Replayed") == "true"
+		if resp.StatusCode != http.StatusCreated || replayed != (i == 1) {
+			t.Errorf("request %d: status %d, replayed %v", i+1, resp.StatusCode, replayed)
+		}
+	}
+	if n := srv.ArticleCount(); n != 1 {
+		t.Errorf("%d articles created, want 1", n)
+	}
+}
+
+func TestConcurrentCreates(t *testing.T) {
+	srv := newTestServer(t, 0)
+
+	var wg sync.WaitGroup
+	ids := make([]int, 50)
+	for i := range ids {
+		wg.Go(func() {
+			article := srv.insertArticle(context.Background(), model.Article{Title: fmt.Sprintf("Article %d", i), Status: model.StatusDraft})
+			ids[i] = article.ID
+		})
+	}
+	wg.Wait()
+
+	slices.Sort(ids)
+	for i, id := range ids {
+		if id != i+1 {
+			t.Fatalf("IDs %v, want 1 to %d once each", ids, len(ids))
+		}
+	}
+	if stored := srv.readArticles(); !slices.IsSortedFunc(stored, func(a, b model.Article) int { return a.ID - b.ID }) {
+		t.Error("articles not kept in ID order")
+	}
+	if db := srv.currentDatabase(); db.NextID != len(ids)+1 {
+		t.Errorf("next ID %d, want %d", db.NextID, len(ids)+1)
+	}
+}
+
+// stalledStore is a data and blob store that never answers, or fails at once
+// with err if set
+type stalledStore struct {
+	memStore
+	err error
+}
+
+func (s *stalledStore) wait(ctx context.Context) error {
+	if s.err != nil {
+		return s.err
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *stalledStore) Save(ctx context.Context, db store.Database) error { return s.wait(ctx) }
+
+func (s *stalledStore) Put(ctx context.Context, key string, r io.Reader) error { return s.wait(ctx) }
+
+func (s *stalledStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return nil, s.wait(ctx)
+}
+
+func (s *stalledStore) Delete(ctx context.Context, key string) error { return s.wait(ctx) }
+
+func TestStoreTimeouts(t *testing.T) {
+	srv := newTestServer(t, 1)
+	stalled := &stalledStore{}
+	srv.cfg.StoreTimeout = 50 * time.Millisecond
+	srv.dataStore, srv.blobStore = stalled, stalled
+
+	if err := srv.Save(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("save: %v, want a timeout", err)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "pixel.png")
+	fw.Write([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"))
+	mw.Close()
+	start := time.Now()
+	resp, err := http.Post(srv.URL+"/articles/1/attachments", mw.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload Response
+	json.NewDecoder(resp.Body).Decode(&payload)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusGatewayTimeout || payload.Code != CodeStoreTimeout || time.Since(start) > 5*time.Second {
+		t.Errorf("upload: status %d, code %q after %s, want 504", resp.StatusCode, payload.Code, time.Since(start))
+	}
+
+	srv.articlesMutex.Lock()
+	srv.articles[0].Attachments = []model.Attachment{{ID: 1, Key: "articles/1/1", ContentType: "image/png", Filename: "pixel.png"}}
+	srv.articlesMutex.Unlock()
+	stalled.err = errors.New("connection refused")
+	if resp := call(t, "GET", srv.URL+"/articles/1/attachments/1", "", nil); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("download from a failing store: status %d, want 503", resp.StatusCode)
+	}
+}
+
+func TestRangeRequests(t *testing.T) {
+	srv := newTestServer(t, 1)
+	get := func(url string, header ...string) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", url, nil)
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	file := "\x89PNG\r\n\x1a\n" + strings.Repeat("0123456789", 10)
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "pixel.png")
+	io.WriteString(fw, file)
+	mw.Close()
+	resp, err := http.Post(srv.URL+"/articles/1/attachments", mw.FormDataContentType(), &body)
+	if err != nil || resp.StatusCode != http.StatusCreated {
+		t.Fatalf("upload: %v, status %d", err, resp.StatusCode)
+	}
+	resp.Body.Close()
+	attachment, _ := srv.findAttachment(1, 1)
+	modified := attachment.Created.UTC().Format(http.TimeFormat)
+
+	url := srv.URL + "/articles/1/attachments/1"
+	for _, test := range []struct {
+		header       []string
+		status       int
+		body, ranged string
+	}{
+		{nil, http.StatusOK, file, ""},
+		{[]string{"Range", "bytes=8-17"}, http.StatusPartialContent, "0123456789", "bytes 8-17/108"},
+		{[]string{"Range", "bytes=100-"}, http.StatusPartialContent, "23456789", "bytes 100-107/108"},
+		{[]string{"Range", "bytes=-4"}, http.StatusPartialContent, "6789", "bytes 104-107/108"},
+		{[]string{"Range", "bytes=0-1,4-5"}, http.StatusOK, file, ""},
+		{[]string{"Range", "bytes=0-3", "If-Range", modified}, http.StatusPartialContent, file[:4], "bytes 0-3/108"},
+		{[]string{"Range", "bytes=0-3", "If-Range", "Mon, 02 Jan 2006 15:04:05 GMT"}, http.StatusOK, file, ""},
+		{[]string{"Range", "bytes=200-"}, http.StatusRequestedRangeNotSatisfiable, "", "bytes */108"},
+	} {
+		resp, got := get(url, test.header...)
+		if resp.StatusCode != test.status || resp.Header.Get("Content-Range") != test.ranged || (test.body != "" && got != test.body) {
+			t.Errorf("%v: status %d, Content-Range %q, body %q", test.header, resp.StatusCode, resp.Header.Get("Content-Range"), got)
+		}
+	}
+
+	// Article content, e.g. the first bytes for a preview
+	srv.articlesMutex.Lock()
+	srv.articles[0].Content = "Hello, world"
+	srv.articlesMutex.Unlock()
+	if resp, got := get(srv.URL+"/articles/1/content", "Range", "bytes=0-4"); resp.StatusCode != http.StatusPartialContent || got != "Hello" {
+		t.Errorf("content range: status %d, body %q", resp.StatusCode, got)
+	}
+	if resp, got := get(srv.URL + "/articles/1/content"); resp.StatusCode != http.StatusOK || got != "Hello, world" || resp.Header.Get("Accept-Ranges") != "bytes" {
+		t.Errorf("content: status %d, body %q", resp.StatusCode, got)
+	}
+}
+
+func TestAttachmentDeduplication(t *testing.T) {
+	srv := newTestServer(t, 2)
+	upload := func(id int) model.Attachment {
+		t.Helper()
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, _ := mw.CreateFormFile("file", "pixel.png")
+		io.WriteString(fw, "\x89PNG\r\n\x1a\nsame bytes")
+		mw.Close()
+		var attachment model.Attachment
+		resp, err := http.Post(fmt.Sprintf("%s/articles/%d/attachments", srv.URL, id), mw.FormDataContentType(), &body)
+		if err != nil || resp.StatusCode != http.StatusCreated {
+			t.Fatalf("upload: %v, status %d", err, resp.StatusCode)
+		}
+		json.NewDecoder(resp.Body).Decode(&Response{Data: &attachment})
+		resp.Body.Close()
+		attachment, _ = srv.findAttachment(id, attachment.ID)
+		return attachment
+	}
+	blobExists := func(key string) bool {
+		blob, err := srv.blobStore.Get(context.Background(), key)
+		if err == nil {
+			blob.Close()
+		}
+		return err == nil
+	}
+
+	first, second := upload(1), upload(2)
+	if first.Key != second.Key || !strings.HasPrefix(first.Key, contentBlobPrefix) {
+		t.Fatalf("keys %q and %q, want the same content key", first.Key, second.Key)
+	}
+	if resp := call(t, "DELETE", fmt.Sprintf("%s/articles/1/attachments/%d", srv.URL, first.ID), "", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete: status %d", resp.StatusCode)
+	}
+	if !blobExists(first.Key) {
+		t.Fatal("shared blob deleted with the first attachment")
+	}
+	if resp := call(t, "DELETE", srv.URL+"/articles/2", "", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete article: status %d", resp.StatusCode)
+	}
+	if blobExists(first.Key) {
+		t.Error("blob kept after its last attachment was deleted")
+	}
+
+	// The GC job deletes blobs nothing refers to
+	kept := upload(1)
+	orphan := contentBlobPrefix + "0123"
+	srv.blobStore.Put(context.Background(), orphan, strings.NewReader("orphan"))
+	if err := srv.runBlobGCJob(context.Background(), jobs.Job{}); err != nil {
+		t.Fatal(err)
+	}
+	if blobExists(orphan) || !blobExists(kept.Key) {
+		t.Errorf("after GC: orphan exists %v, attachment blob exists %v", blobExists(orphan), blobExists(kept.Key))
+	}
+}
+
+func TestResumableUpload(t *testing.T) {
+	srv := newTestServer(t, 1)
+	patch := func(url string, offset int, chunk string, header ...string) (*http.Response, Response) {
+		t.Helper()
+		req, _ := http.NewRequest("PATCH", url, strings.NewReader(chunk))
+		req.Header.Set("Upload-Offset", fmt.Sprint(offset))
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var payload Response
+		json.NewDecoder(resp.Body).Decode(&payload)
+		return resp, payload
+	}
+	open := func(body string) string {
+		t.Helper()
+		var session UploadSession
+		if resp := call(t, "POST", srv.URL+"/articles/1/uploads", body, &session); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("open upload: status %d", resp.StatusCode)
+		}
+		return srv.URL + "/articles/1/uploads/" + session.ID
+	}
+
+	file := "\x89PNG\r\n\x1a\n" + strings.Repeat("0123456789", 30)
+	sum := sha256.Sum256([]byte(file))
+	url := open(fmt.Sprintf(`{"kind":"attachment","filename":"big.png","size":%d,"sha256":"%x"}`, len(file), sum))
+
+	if resp, _ := patch(url, 0, file[:100]); resp.StatusCode != http.StatusOK || resp.Header.Get("Upload-Offset") != "100" {
+		t.Fatalf("first chunk: status %d, offset %s", resp.StatusCode, resp.Header.Get("Upload-Offset"))
+	}
+	if resp, payload := patch(url, 0, file[:100]); resp.StatusCode != http.StatusConflict || resp.Header.Get("Upload-Offset") != "100" {
+		t.Errorf("chunk at a stale offset: status %d, %+v", resp.StatusCode, payload)
+	}
+	wrong := sha256.Sum256([]byte("something else"))
+	if resp, payload := patch(url, 100, file[100:200], "Upload-Checksum", "sha256 "+base64.StdEncoding.EncodeToString(wrong[:])); resp.StatusCode != http.StatusUnprocessableEntity || payload.Code != CodeChecksumMismatch {
+		t.Errorf("corrupt chunk: status %d, %+v", resp.StatusCode, payload)
+	}
+	var session UploadSession
+	if call(t, "GET", url, "", &session); session.Offset != 100 {
+		t.Errorf("offset after a refused chunk %d, want 100", session.Offset)
+	}
+	chunkSum := sha256.Sum256([]byte(file[100:]))
+	resp, payload := patch(url, 100, file[100:], "Upload-Checksum", "sha256 "+base64.StdEncoding.EncodeToString(chunkSum[:]))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("last chunk: status %d, %+v", resp.StatusCode, payload)
+	}
+	resp, err := http.Get(srv.URL + "/articles/1/attachments/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != file {
+		t.Errorf("uploaded attachment: status %d, %d bytes", resp.StatusCode, len(body))
+	}
+	if resp := call(t, "GET", url, "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("completed upload: status %d, want 404", resp.StatusCode)
+	}
+
+	// Content, whose checksum doesn't match what was sent
+	content := strings.Repeat("A long article. ", 50)
+	url = open(fmt.Sprintf(`{"kind":"content","size":%d,"sha256":"%x"}`, len(content), sum))
+	if resp, payload := patch(url, 0, content); resp.StatusCode != http.StatusUnprocessableEntity || payload.Code != CodeChecksumMismatch {
+		t.Errorf("content with the wrong checksum: status %d, %+v", resp.StatusCode, payload)
+	}
+	url = open(fmt.Sprintf(`{"kind":"content","size":%d}`, len(content)))
+	patch(url, 0, content[:10])
+	if resp, _ := patch(url, 10, content[10:]+"extra"); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("chunk past the size: status %d, want 413", resp.StatusCode)
+	}
+	if resp, _ := patch(url, 10, content[10:]); resp.StatusCode != http.StatusOK {
+		t.Errorf("last content chunk: status %d", resp.StatusCode)
+	}
+	if article, _ := srv.readArticle(1); article.Content != strings.TrimSpace(content) {
+		t.Errorf("content %q after the upload", article.Content)
+	}
+
+	// Sessions expire
+	srv.cfg.UploadSessionTTL = time.Millisecond
+	url = open(`{"kind":"content","size":10}`)
+	time.Sleep(5 * time.Millisecond)
+	if resp, _ := patch(url, 0, "0123456789"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expired upload: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestStoreBreaker(t *testing.T) {
+	cfg := testConfig(t, 1)
+	cfg.StoreBreakerFailures, cfg.StoreBreakerCooldown = 2, 50*time.Millisecond
+	srv := startTestServer(t, cfg)
+	working := srv.blobStore
+	srv.blobStore = &stalledStore{err: errors.New("connection refused")}
+	srv.articlesMutex.Lock()
+	srv.articles[0].Attachments = []model.Attachment{{ID: 1, Key: "articles/1/1", ContentType: "image/png", Filename: "pixel.png"}}
+	srv.articlesMutex.Unlock()
+
+	for i := range 3 {
+		resp := call(t, "GET", srv.URL+"/articles/1/attachments/1", "", nil)
+		if resp.StatusCode != http.StatusServiceUnavailable || (resp.Header.Get("Retry-After") != "") != (i == 2) {
+			t.Errorf("download %d: status %d, Retry-After %q", i+1, resp.StatusCode, resp.Header.Get("Retry-After"))
+		}
+	}
+	var readiness Readiness
+	if call(t, "GET", srv.URL+"/readyz", "", &readiness); !readiness.Ready || readiness.BlobStore.State != BreakerOpen ||
+		readiness.BlobStore.Failures != 2 || readiness.DataStore.State != BreakerClosed {
+		t.Errorf("readiness %+v, want the blob store breaker open", readiness)
+	}
+
+	// Once the store is back, the probe after the cooldown closes the breaker
+	srv.blobStore = working
+	time.Sleep(60 * time.Millisecond)
+	if resp := call(t, "GET", srv.URL+"/articles/1/attachments/1", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("probe: status %d, want 404 from the store", resp.StatusCode)
+	}
+	if status := srv.blobBreaker.status(); status.State != BreakerClosed || status.Failures != 0 {
+		t.Errorf("after the probe: %+v, want closed", status)
+	}
+}
+
+func TestWorkspaces(t *testing.T) {
+	srv := newTestServer(t, 1)
+	for _, name := range []string{"bob", "carol"} {
+		if _, err := srv.AddUser(name, "password", model.RoleEditor); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Basic credentials in the URL
+	as := func(user string) string { return strings.Replace(srv.URL, "://", "://"+user+":password@", 1) }
+
+	var ws model.Workspace
+	call(t, "POST", as("bob")+"/workspaces", `{"name":"Päivän uutiset","default_status":"draft","feed_title":"Uutiset"}`, &ws)
+	if ws.Slug != "paivan-uutiset" || ws.Members["bob"] != model.WorkspaceOwner {
+		t.Fatalf("created %+v, want slug paivan-uutiset owned by bob", ws)
+	}
+	wsURL := "/workspaces/" + ws.Slug
+
+	article := `{"title":"t","desc":"d","content":"c"}`
+	if resp := call(t, "POST", as("carol")+wsURL+"/articles", article, nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("non-member create: status %d, want 403", resp.StatusCode)
+	}
+	call(t, "PUT", as("bob")+wsURL+"/members/carol", `{"role":"editor"}`, nil)
+	var created model.Article
+	call(t, "POST", as("carol")+wsURL+"/articles", article, &created)
+	if created.Workspace != ws.Slug || created.Status != model.StatusDraft {
+		t.Fatalf("created %+v, want a draft in %s", created, ws.Slug)
+	}
+
+	// Drafts and members are shown to members only
+	var list []model.Article
+	if call(t, "GET", srv.URL+wsURL+"/articles", "", &list); len(list) != 0 {
+		t.Errorf("anonymous list has %d articles, want 0", len(list))
+	}
+	if call(t, "GET", as("carol")+wsURL+"/articles", "", &list); len(list) != 1 {
+		t.Errorf("member list has %d articles, want 1", len(list))
+	}
+	var public model.Workspace
+	if call(t, "GET", srv.URL+wsURL, "", &public); public.Members != nil {
+		t.Errorf("anonymous get shows members %v", public.Members)
+	}
+
+	// Changing the article needs a workspace role too
+	articleURL := fmt.Sprintf("/articles/%d", created.ID)
+	if resp := call(t, "PUT", srv.URL+articleURL, `{"status":"published"}`, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("anonymous update: status %d, want 401", resp.StatusCode)
+	}
+	if resp := call(t, "PUT", as("carol")+articleURL, `{"status":"published"}`, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("member update: status %d", resp.StatusCode)
+	}
+
+	resp, err := http.Get(srv.URL + wsURL + "/feed.rss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "<title>Uutiset</title>") || strings.Count(string(body), "<item>") != 1 {
+		t.Errorf("workspace feed:\n%s", body)
+	}
+
+	if resp := call(t, "DELETE", as("bob")+wsURL, "", nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("delete with articles: status %d, want 409", resp.StatusCode)
+	}
+	if resp := call(t, "GET", srv.URL+"/workspaces/missing/articles", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown workspace: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestQuotas(t *testing.T) {
+	srv := newTestServer(t, 0)
+	call(t, "POST", srv.URL+"/workspaces", `{"name":"blog"}`, nil)
+
+	var quota Quota
+	call(t, "PUT", srv.URL+"/admin/quotas/blog", `{"max_articles":1,"rate_limit":3}`, &quota)
+	if quota.Effective.MaxArticles != 1 || quota.Effective.MaxStorageBytes != 0 {
+		t.Fatalf("effective limits %+v", quota.Effective)
+	}
+
+	// Two creates and a list use up the rate limit
+	article := `{"title":"t","desc":"d","content":"c"}`
+	if resp := call(t, "POST", srv.URL+"/workspaces/blog/articles", article, nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("first create: status %d", resp.StatusCode)
+	}
+	if resp := call(t, "POST", srv.URL+"/workspaces/blog/articles", article, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("create over quota: status %d, want 403", resp.StatusCode)
+	}
+	call(t, "GET", srv.URL+"/workspaces/blog/articles", "", nil)
+	if resp := call(t, "GET", srv.URL+"/workspaces/blog/articles", "", nil); resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("request over rate limit: status %d, want 429 with Retry-After", resp.StatusCode)
+	}
+
+	call(t, "GET", srv.URL+"/admin/quotas/blog", "", &quota)
+	if quota.Usage.Articles != 1 || quota.Limits.RateLimit != 3 {
+		t.Errorf("quota %+v, want 1 article and own rate limit 3", quota)
+	}
+	if resp := call(t, "PUT", srv.URL+"/admin/quotas/blog", `{"max_articles":-2}`, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid limit: status %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestServerMode(t *testing.T) {
+	srv := newTestServer(t, 1)
+	article := `{"title":"t","desc":"d","content":"c"}`
+
+	call(t, "PUT", srv.URL+"/admin/mode", `{"mode":"read-only","retry_after":60}`, nil)
+	resp := call(t, "POST", srv.URL+"/articles", article, nil)
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != "60" {
+		t.Errorf("create in read-only mode: status %d, Retry-After %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	if resp := call(t, "GET", srv.URL+"/articles/1", "", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("read in read-only mode: status %d", resp.StatusCode)
+	}
+
+	call(t, "PUT", srv.URL+"/admin/mode", `{"mode":"maintenance"}`, nil)
+	if resp := call(t, "GET", srv.URL+"/articles/1", "", nil); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("read in maintenance mode: status %d, want 503", resp.StatusCode)
+	}
+	var mode ServerMode
+	if call(t, "GET", srv.URL+"/admin/mode", "", &mode); mode.Mode != config.ModeMaintenance {
+		t.Errorf("mode %q, want maintenance", mode.Mode)
+	}
+
+	call(t, "PUT", srv.URL+"/admin/mode", `{"mode":"normal"}`, nil)
+	if resp := call(t, "POST", srv.URL+"/articles", article, nil); resp.StatusCode != http.StatusCreated {
+		t.Errorf("create in normal mode: status %d", resp.StatusCode)
+	}
+}
+
+func TestRemoteShutdown(t *testing.T) {
+	srv := newTestServer(t, 1)
+
+	var status DrainStatus
+	call(t, "POST", srv.URL+"/admin/drain?grace=1h", "", &status)
+	if time.Until(status.ShutdownAt) < 59*time.Minute {
+		t.Errorf("drain shuts down at %v, want in an hour", status.ShutdownAt)
+	}
+	if resp := call(t, "GET", srv.URL+"/articles/1", "", nil); !resp.Close {
+		t.Error("draining server kept the connection open")
+	}
+	if resp := call(t, "POST", srv.URL+"/admin/drain?grace=soon", "", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid grace: status %d, want 400", resp.StatusCode)
+	}
+	select {
+	case <-srv.ShutdownRequested():
+		t.Fatal("shutdown requested before the drain ended")
+	default:
+	}
+
+	if resp := call(t, "POST", srv.URL+"/admin/shutdown", "", nil); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("shutdown: status %d", resp.StatusCode)
+	}
+	select {
+	case <-srv.ShutdownRequested():
+	case <-time.After(time.Second):
+		t.Error("shutdown not requested")
+	}
+}
+
+func TestReplication(t *testing.T) {
+	srv := newTestServer(t, 2)
+	// with what the API never shows, which replicas need all the same
+	srv.articlesMutex.Lock()
+	articles := slices.Clone(srv.articles)
+	articles[0].Attachments = []model.Attachment{{ID: 1, Filename: "a.png", Key: "articles/1/1", Variants: []string{"articles/1/1-100x100"}}}
+	articles[0].Embedding = &model.Embedding{Model: "m", Vector: []float32{1, 0}}
+	articles[0].ContentKeyID = "k1"
+	srv.articles = articles
+	srv.articlesMutex.Unlock()
+
+	// The primary streams a snapshot, then the changes
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/admin/replication", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	dec := gob.NewDecoder(resp.Body)
+	var msg ReplicationMessage
+	if err := dec.Decode(&msg); err != nil || msg.Type != ReplicationSnapshot || len(msg.Snapshot.Articles) != 2 {
+		t.Fatalf("first message: %v, %+v", err, msg)
+	}
+	if a := msg.Snapshot.Articles[0]; len(a.Attachments) != 1 || a.Attachments[0].Key != "articles/1/1" || len(a.Attachments[0].Variants) != 1 ||
+		a.Embedding == nil || a.ContentKeyID != "k1" {
+		t.Errorf("snapshot lost hidden fields: %+v", a)
+	}
+
+	// A replica following the primary ends up with all of it
+	replica := newTestServer(t, 0)
+	replica.cfg.ReplicaOf = srv.URL
+	var following sync.WaitGroup
+	following.Go(func() { replica.followPrimary(ctx, srv.URL) })
+	defer following.Wait()
+	call(t, "POST", srv.URL+"/articles", `{"title":"t","desc":"d","content":"c"}`, nil)
+	msg = ReplicationMessage{}
+	if err := dec.Decode(&msg); err != nil || msg.Type != EventArticleCreated || msg.Article.ID != 3 {
+		t.Fatalf("change: %v, %+v", err, msg)
+	}
+	// and the users and workspaces once a save finds them changed
+	if _, err := srv.AddUser("alice", "correct horse", model.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	srv.articlesMutex.Lock()
+	users := slices.Clone(srv.users)
+	users[0].PushSubscriptions = []model.PushSubscription{{Endpoint: "https://push.example/1", P256dh: "p256dh", Auth: "auth"}}
+	users[0].SavedSearches = []model.SavedSearch{{ID: 1, Query: "go", WebhookURL: "https://hook.example/", WebhookKey: "secret", Alerted: []int{1}}}
+	srv.users = users
+	srv.articlesMutex.Unlock()
+	if err := srv.saveArticles(); err != nil {
+		t.Fatal(err)
+	}
+	msg = ReplicationMessage{}
+	if err := dec.Decode(&msg); err != nil || msg.Type != ReplicationAccessUpdate || len(msg.Access.Users) != 1 ||
+		msg.Access.Users[0].Username != "alice" || msg.Access.Users[0].PasswordHash == "" {
+		t.Fatalf("access: %v, %+v", err, msg)
+	}
+
+	eventually(t, "the replica to get alice", func() bool {
+		replica.articlesMutex.RLock()
+		defer replica.articlesMutex.RUnlock()
+		return len(replica.users) == 1
+	})
+	replica.articlesMutex.RLock()
+	user, first, third := replica.users[0], replica.articles[0], replica.articles[2]
+	replica.articlesMutex.RUnlock()
+	if len(first.Attachments) != 1 || first.Attachments[0].Key != "articles/1/1" || first.Attachments[0].Variants[0] != "articles/1/1-100x100" ||
+		first.Embedding == nil || first.Embedding.Vector[0] != 1 || first.ContentKeyID != "k1" {
+		t.Errorf("replica article %+v", first)
+	}
+	if third.ID != 3 || third.Title != "t" {
+		t.Errorf("replica missed the change: %+v", third)
+	}
+	if len(user.PushSubscriptions) != 1 || user.PushSubscriptions[0].P256dh != "p256dh" || user.PushSubscriptions[0].Auth != "auth" {
+		t.Errorf("replica push subscriptions %+v", user.PushSubscriptions)
+	}
+	if len(user.SavedSearches) != 1 || user.SavedSearches[0].WebhookKey != "secret" || !slices.Equal(user.SavedSearches[0].Alerted, []int{1}) {
+		t.Errorf("replica saved searches %+v", user.SavedSearches)
+	}
+	cancel()
+
+	// A replica applies them to its articles, users and workspaces
+	snapshot := &ReplicationState{Articles: []model.Article{{ID: 2, Title: "two"}}, NextID: 3}
+	snapshot.Users = []model.User{{Username: "bob", Role: model.RoleEditor}}
+	snapshot.Workspaces = []model.Workspace{{Slug: "team", Members: map[string]string{"bob": model.WorkspaceViewer}}}
+	steps := []ReplicationMessage{
+		{Type: ReplicationSnapshot, Snapshot: snapshot},
+		{Type: EventArticleCreated, Article: &model.Article{ID: 5, Title: "five"}},
+		{Type: EventArticleUpdated, Article: &model.Article{ID: 2, Title: "TWO"}},
+		{Type: EventArticleDeleted, Article: &model.Article{ID: 5}},
+	}
+	for _, step := range steps {
+		if err := srv.applyReplication(step); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got model.Article
+	if resp := call(t, "GET", srv.URL+"/articles/2", "", &got); resp.StatusCode != http.StatusOK || got.Title != "TWO" || srv.ArticleCount() != 1 || srv.articleIDs.Peek() != 6 {
+		t.Errorf("replicated: %d articles, next ID %d, %+v", srv.ArticleCount(), srv.articleIDs.Peek(), got)
+	}
+	if ws, ok := srv.findWorkspace("team"); !srv.hasUsers() || !ok || ws.Members["bob"] != model.WorkspaceViewer {
+		t.Errorf("replicated workspace %+v, users %v", ws, srv.hasUsers())
+	}
+	access := ReplicationMessage{Type: ReplicationAccessUpdate, Access: &ReplicationAccess{Users: []model.User{{Username: "carol"}}}}
+	if err := srv.applyReplication(access); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := srv.findWorkspace("team"); ok || len(srv.users) != 1 || srv.users[0].Username != "carol" {
+		t.Errorf("access update: users %+v", srv.users)
+	}
+
+	// Replicas refuse changes and are ready once in sync
+	srv.cfg.ReplicaOf = "http://primary:8080"
+	if resp := call(t, "DELETE", srv.URL+"/articles/2", "", nil); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("replica delete: status %d", resp.StatusCode)
+	}
+	var readiness Readiness
+	if resp := call(t, "GET", srv.URL+"/readyz", "", &readiness); resp.StatusCode != http.StatusServiceUnavailable || readiness.Ready {
+		t.Errorf("unsynced replica: status %d, %+v", resp.StatusCode, readiness)
+	}
+	srv.replicaLastSync = time.Now()
+	if resp := call(t, "GET", srv.URL+"/readyz", "", &readiness); resp.StatusCode != http.StatusOK || readiness.Replica == nil {
+		t.Errorf("synced replica: status %d, %+v", resp.StatusCode, readiness)
+	}
+}
+
+// Reads while a writer changes an article every millisecond, holding
+// articlesMutex for 100µs of work: under articlesMutex as before the
+// snapshot, and from the snapshot. The reads are one article by ID, and the
+// published ones as the feeds list them. Run with several CPUs, e.g.
+// -cpu 1,4,16, to see readers wait for the writer and for each other.
+func BenchmarkArticleReads(b *testing.B) {
+	srv := newTestServer(b, 1000)
+	stop, writing := make(chan struct{}), make(chan struct{})
+	var writer sync.WaitGroup
+	writer.Go(func() {
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for n := 0; ; n++ {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			srv.articlesMutex.Lock()
+			for start := time.Now(); time.Since(start) < 100*time.Microsecond; {
+				srv.articles[n%len(srv.articles)].Title = fmt.Sprint("Title ", n)
+			}
+			srv.articlesMutex.Unlock()
+			if n == 10 {
+				close(writing)
+			}
+		}
+	})
+	b.Cleanup(func() {
+		close(stop)
+		writer.Wait()
+	})
+	// Time the reads with the writer at full speed
+	<-writing
+
+	byID := func(list []model.Article, id int) model.Article {
+		i, _ := slices.BinarySearchFunc(list, id, func(a model.Article, id int) int { return a.ID - id })
+		return list[i]
+	}
+	published := func(list []model.Article) (n int) {
+		for _, a := range list {
+			if a.IsPublished() {
+				n++
+			}
+		}
+		return n
+	}
+	locked := func(read func([]model.Article)) {
+		srv.articlesMutex.RLock()
+		defer srv.articlesMutex.RUnlock()
+		read(srv.articles)
+	}
+	snapshot := func(read func([]model.Article)) {
+		read(srv.readArticles())
+	}
+
+	for _, mode := range []struct {
+		name string
+		with func(func([]model.Article))
+	}{{"locked", locked}, {"snapshot", snapshot}} {
+		b.Run("get/"+mode.name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for id := 0; pb.Next(); id = (id + 1) % 1000 {
+					mode.with(func(list []model.Article) { byID(list, id+1) })
+				}
+			})
+		})
+		b.Run("feed/"+mode.name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					mode.with(func(list []model.Article) { published(list) })
+				}
+			})
+		})
+	}
+}
+
+// Encoding the listing of 10000 articles whole, as before, and streamed
+// through the pooled buffer; -benchmem shows the memory each holds
+func BenchmarkListingEncoding(b *testing.B) {
+	srv := newTestServer(b, 10000)
+	response := Response{Message: "Articles retrieved successfully", Data: srv.readArticles()}
+	b.Run("whole", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			json.NewEncoder(io.Discard).Encode(response)
+		}
+	})
+	b.Run("streamed", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			(jsonCodec{}).Encode(io.Discard, response)
+		}
+	})
+}
+
+// fakeLease is a leaderLease without expiry; tests hand it over by hand
+type fakeLease struct {
+	holder string
+}
+
+func (l *fakeLease) Acquire(holder string, ttl time.Duration) (string, error) {
+	if l.holder == "" {
+		l.holder = holder
+	}
+	return l.holder, nil
+}
+
+func (l *fakeLease) Renew(holder string, ttl time.Duration) (bool, error) {
+	return l.holder == holder, nil
+}
+
+func (l *fakeLease) Release(holder string) error {
+	if l.holder == holder {
+		l.holder = ""
+	}
+	return nil
+}
+
+func TestLeaderElection(t *testing.T) {
+	srv := newTestServer(t, 1)
+	lease := &fakeLease{holder: "http://a:8080"}
+	srv.cfg.ElectionURL, srv.cfg.ElectionTTL = "http://b:8080", time.Minute
+	srv.electionLease = lease
+
+	// Another node leads: follow it and refuse changes
+	srv.campaign(time.Now())
+	if !srv.replicating() || srv.primaryURL() != "http://a:8080" {
+		t.Fatalf("follower: replicating %v, primary %q", srv.replicating(), srv.primaryURL())
+	}
+	if resp := call(t, "POST", srv.URL+"/articles", `{"title":"t","desc":"d","content":"c"}`, nil); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("follower create: status %d", resp.StatusCode)
+	}
+
+	// The leader's lease expires: take over and accept changes
+	lease.holder = ""
+	srv.campaign(time.Now())
+	if srv.replicating() || !srv.electedLeader() {
+		t.Fatalf("after failover: replicating %v, leader %q", srv.replicating(), srv.electedPrimary())
+	}
+	if resp := call(t, "POST", srv.URL+"/articles", `{"title":"t","desc":"d","content":"c"}`, nil); resp.StatusCode != http.StatusCreated {
+		t.Errorf("leader create: status %d", resp.StatusCode)
+	}
+	var readiness Readiness
+	if call(t, "GET", srv.URL+"/readyz", "", &readiness); !readiness.Ready || readiness.Leader != "http://b:8080" {
+		t.Errorf("leader readiness: %+v", readiness)
+	}
+
+	// A leader that hasn't renewed its lease for long stops taking changes
+	// before the lease may have run out, and takes them again once renewed
+	srv.electionMutex.Lock()
+	srv.leaderRenewed = time.Now().Add(-srv.cfg.ElectionTTL + leaseMargin(srv.cfg.ElectionTTL)/2)
+	srv.electionMutex.Unlock()
+	if srv.electedLeader() || srv.primaryURL() != "" {
+		t.Errorf("leader near the end of its lease: leader %v, primary %q", srv.electedLeader(), srv.primaryURL())
+	}
+	if resp := call(t, "POST", srv.URL+"/articles", `{"title":"t","desc":"d","content":"c"}`, nil); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("create near the end of the lease: status %d", resp.StatusCode)
+	}
+	srv.campaign(time.Now())
+	if !srv.electedLeader() {
+		t.Errorf("renewed leader not leading")
+	}
+
+	// Another node took the lease: step down
+	lease.holder = "http://c:8080"
+	srv.campaign(time.Now())
+	srv.campaign(time.Now())
+	if !srv.replicating() || srv.primaryURL() != "http://c:8080" {
+		t.Errorf("deposed: replicating %v, primary %q", srv.replicating(), srv.primaryURL())
+	}
+
+	// Leaving hands the lease over
+	lease.holder = ""
+	srv.campaign(time.Now())
+	srv.resign()
+	if lease.holder != "" || srv.electedLeader() {
+		t.Errorf("resigned, lease held by %q", lease.holder)
+	}
+}
+
+func TestAdminRoutesRequireAdmin(t *testing.T) {
+	srv := newTestServer(t, 1)
+	if _, err := srv.AddUser("alice", "correct horse", model.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.AddUser("bob", "battery staple", model.RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		user, password string
+		want           int
+	}{
+		{"", "", http.StatusUnauthorized},
+		{"alice", "wrong", http.StatusUnauthorized},
+		{"bob", "battery staple", http.StatusForbidden},
+		{"alice", "correct horse", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", srv.URL+"/admin/export.zip", nil)
+		if tt.user != "" {
+			req.SetBasicAuth(tt.user, tt.password)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("user %q: status %d, want %d", tt.user, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestUserActivity(t *testing.T) {
+	srv := newTestServer(t, 0)
+	if _, err := srv.AddUser("alice", "horsehorse", model.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.AddUser("bob", "staplestaple", model.RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+	as := func(user, password string) string {
+		return strings.Replace(srv.URL, "http://", "http://"+user+":"+password+"@", 1)
+	}
+	bob := as("bob", "staplestaple")
+
+	call(t, "POST", bob+"/articles", `{"title":"First","desc":"One","content":"One"}`, nil)
+	call(t, "POST", bob+"/articles", `{"title":"Second","desc":"Two","content":"Two"}`, nil)
+	call(t, "PUT", bob+"/articles/1", `{"title":"First again"}`, nil)
+	call(t, "POST", srv.URL+"/articles", `{"title":"Anonymous","desc":"Three","content":"Three"}`, nil) // no one's
+	call(t, "DELETE", as("alice", "horsehorse")+"/articles/2", "", nil)
+
+	var page UserActivity
+	if resp := call(t, "GET", bob+"/users/bob/activity?page_size=2", "", &page); resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	if len(page.Entries) != 2 || page.Entries[0].Action != EventArticleUpdated || page.Entries[0].Title != "First again" ||
+		page.Entries[1].Action != EventArticleCreated || page.Entries[1].ArticleID != 2 || page.NextPageToken == "" {
+		t.Fatalf("first page %+v", page)
+	}
+	var last UserActivity
+	call(t, "GET", bob+"/users/bob/activity?page_size=2&page_token="+page.NextPageToken, "", &last)
+	if len(last.Entries) != 1 || last.Entries[0].Title != "First" || last.NextPageToken != "" {
+		t.Errorf("second page %+v", last)
+	}
+
+	var alice, forAdmin UserActivity
+	call(t, "GET", as("alice", "horsehorse")+"/users/alice/activity", "", &alice)
+	if len(alice.Entries) != 1 || alice.Entries[0].Action != EventArticleDeleted || alice.Entries[0].Title != "Second" {
+		t.Errorf("alice's activity %+v", alice)
+	}
+	call(t, "GET", as("alice", "horsehorse")+"/users/BOB/activity", "", &forAdmin)
+	if forAdmin.Username != "bob" || len(forAdmin.Entries) != 3 {
+		t.Errorf("bob's activity for an admin %+v", forAdmin)
+	}
+
+	for _, tt := range []struct {
+		url  string
+		want int
+	}{
+		{srv.URL + "/users/bob/activity", http.StatusUnauthorized},
+		{bob + "/users/alice/activity", http.StatusForbidden},
+		{as("alice", "horsehorse") + "/users/carol/activity", http.StatusNotFound},
+		{bob + "/users/bob/activity?page_token=bad", http.StatusBadRequest},
+	} {
+		if resp := call(t, "GET", tt.url, "", nil); resp.StatusCode != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.url, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestUserDataExportAndErasure(t *testing.T) {
+	srv := newTestServer(t, 0)
+	if _, err := srv.AddUser("alice", "horsehorse", model.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.AddUser("bob", "staplestaple", model.RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+	srv.articlesMutex.Lock()
+	srv.workspaces = append(srv.workspaces, model.Workspace{Slug: "team", Name: "Team", Members: map[string]string{"bob": model.WorkspaceEditor}})
+	srv.articlesMutex.Unlock()
+	alice := strings.Replace(srv.URL, "http://", "http://alice:horsehorse@", 1)
+	bob := strings.Replace(srv.URL, "http://", "http://bob:staplestaple@", 1)
+	call(t, "POST", bob+"/articles", `{"title":"First","desc":"One","content":"One"}`, nil)
+	call(t, "POST", bob+"/articles", `{"title":"Second","desc":"Two","content":"Two"}`, nil)
+	call(t, "POST", alice+"/articles", `{"title":"Third","desc":"Three","content":"Three"}`, nil)
+
+	resp, err := http.Get(alice + "/admin/users/BOB/export.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("status %d: %v", resp.StatusCode, err)
+	}
+	files := map[string][]byte{}
+	for _, f := range archive.File {
+		rc, _ := f.Open()
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+	var user UserData
+	var created []model.Article
+	json.Unmarshal(files["user.json"], &user)
+	json.Unmarshal(files["articles.json"], &created)
+	if user.Username != "bob" || user.Workspaces["team"] != model.WorkspaceEditor || len(created) != 2 || created[0].Title != "First" ||
+		files["activity.json"] == nil || files["manifest.json"] == nil {
+		t.Errorf("archive files %v, user %+v, %d articles", slices.Collect(maps.Keys(files)), user, len(created))
+	}
+
+	for _, tt := range []struct {
+		url  string
+		want int
+	}{
+		{alice + "/admin/users/bob/erase?articles=some", http.StatusBadRequest},
+		{alice + "/admin/users/alice/erase", http.StatusConflict},
+		{alice + "/admin/users/carol/erase", http.StatusNotFound},
+	} {
+		if resp := call(t, "POST", tt.url, "", nil); resp.StatusCode != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.url, resp.StatusCode, tt.want)
+		}
+	}
+
+	var erasure Erasure
+	if resp := call(t, "POST", alice+"/admin/users/bob/erase?articles=delete", "", &erasure); resp.StatusCode != http.StatusOK {
+		t.Fatalf("erase: status %d", resp.StatusCode)
+	}
+	if erasure.Subject != erasureSubject("bob") || erasure.By != "alice" || erasure.ArticlesDeleted != 2 || erasure.ActivityEntries != 2 || erasure.Workspaces != 1 {
+		t.Errorf("erasure %+v", erasure)
+	}
+	if n := srv.ArticleCount(); n != 1 {
+		t.Errorf("%d articles left, want alice's", n)
+	}
+	if _, found := srv.findUser("bob"); found {
+		t.Error("user kept")
+	}
+	if resp := call(t, "GET", bob+"/users/bob/activity", "", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("erased user signed in: status %d", resp.StatusCode)
+	}
+	if ws, _ := srv.findWorkspace("team"); len(ws.Members) != 0 {
+		t.Errorf("members %v", ws.Members)
+	}
+	var page UserActivity
+	call(t, "GET", alice+"/users/alice/activity", "", &page)
+	if len(page.Entries) == 0 || page.Entries[0].Action != actionUserErased || page.Entries[0].Subject != erasure.Subject {
+		t.Errorf("alice's activity %+v", page.Entries)
+	}
+}
+
+func TestAsyncImportJob(t *testing.T) {
+	srv := newTestServer(t, 0)
+	if err := srv.StartBackground(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.StopBackground(context.Background()) })
+
+	var job jobs.Job
+	body := `{"title":"One","desc":"d","content":"c"}` + "\n" + `{"title":"Two","desc":"d","content":"c"}`
+	resp := call(t, "POST", srv.URL+"/admin/import?async=true", body, &job)
+	if resp.StatusCode != http.StatusAccepted || resp.Header.Get("Location") != "/jobs/1" || job.Kind != "import" {
+		t.Fatalf("status %d, Location %q, job %+v", resp.StatusCode, resp.Header.Get("Location"), job)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); job.State != jobs.StateDone; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("job still %s", job.State)
+		}
+		call(t, "GET", srv.URL+"/jobs/1", "", &job)
+	}
+	var report ImportReport
+	if err := json.Unmarshal(job.Result, &report); err != nil || report.Created != 2 {
+		t.Errorf("result %s", job.Result)
+	}
+	if n := srv.ArticleCount(); n != 2 {
+		t.Errorf("%d articles after the import, want 2", n)
+	}
+}
+
+func TestCompactJob(t *testing.T) {
+	srv := newTestServer(t, 2)
+	if err := srv.StartBackground(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.StopBackground(context.Background()) })
+
+	var job jobs.Job
+	if resp := call(t, "POST", srv.URL+"/admin/compact", "", &job); resp.StatusCode != http.StatusAccepted || job.Kind != jobCompact {
+		t.Fatalf("status %d, job %+v", resp.StatusCode, job)
+	}
+	job = waitForJob(t, srv, job.ID)
+	var result CompactResult
+	if err := json.Unmarshal(job.Result, &result); err != nil || result.Backend != "gob" {
+		t.Errorf("result %s", job.Result)
+	}
+
+	at := time.Date(0, 1, 1, 3, 0, 0, 0, time.UTC)
+	for now, want := range map[time.Time]time.Time{
+		time.Date(2026, 3, 10, 1, 0, 0, 0, time.UTC):  time.Date(2026, 3, 10, 3, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 10, 3, 0, 0, 0, time.UTC):  time.Date(2026, 3, 11, 3, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC): time.Date(2026, 4, 1, 3, 0, 0, 0, time.UTC),
+	} {
+		if next := nextDailyRun(now, at); !next.Equal(want) {
+			t.Errorf("after %v: next run %v, want %v", now, next, want)
+		}
+	}
+}
+
+// Poll a job until it is done
+func waitForJob(t *testing.T, srv *testServer, id int) jobs.Job {
+	t.Helper()
+	var job jobs.Job
+	for deadline := time.Now().Add(5 * time.Second); job.State != jobs.StateDone; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("job %d still %s", id, job.State)
+		}
+		call(t, "GET", fmt.Sprintf("%s/jobs/%d", srv.URL, id), "", &job)
+	}
+	return job
+}
+
+func TestRetentionJob(t *testing.T) {
+	srv := newTestServer(t, 1)
+	srv.cfg.RetainViews = 48 * time.Hour
+	srv.cfg.RetainActivity = 0
+	if err := srv.StartBackground(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.StopBackground(context.Background()) })
+	now := time.Now()
+	srv.viewCounter.Record(1, now)
+	srv.viewCounter.Record(1, now.AddDate(0, 0, -5))
+	srv.viewCounter.Record(1, now.AddDate(0, 0, -6))
+
+	for _, dryRun := range []bool{true, false} {
+		var job jobs.Job
+		if resp := call(t, "POST", srv.URL+"/admin/retention?dry_run="+strconv.FormatBool(dryRun), "", &job); resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("status %d", resp.StatusCode)
+		}
+		job = waitForJob(t, srv, job.ID)
+		var report RetentionReport
+		if err := json.Unmarshal(job.Result, &report); err != nil || report.DryRun != dryRun || len(report.Rules) != 1 ||
+			report.Rules[0].Name != "views" || report.Rules[0].Removed != 2 {
+			t.Errorf("dry run %v: result %s", dryRun, job.Result)
+		}
+	}
+	if n := srv.viewCounter.Total(1); n != 1 {
+		t.Errorf("%d views kept, want the one within RETAIN_VIEWS", n)
+	}
+	if resp := call(t, "POST", srv.URL+"/admin/retention?dry_run=maybe", "", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid dry_run: status %d", resp.StatusCode)
+	}
+}
+
+// recordingMailer keeps the messages it is asked to send
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []notify.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg notify.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *recordingMailer) messages() []notify.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.sent)
+}
+
+func TestPublishNotification(t *testing.T) {
+	srv := newTestServer(t, 0)
+	recorder := &recordingMailer{}
+	templates, err := notify.LoadTemplates("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.mailer, srv.mailTemplates = recorder, templates
+	if err := srv.StartBackground(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.StopBackground(context.Background()) })
+	if _, err := srv.AddUser("bob", "battery staple", model.RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+
+	account := strings.Replace(srv.URL, "http://", "http://bob:battery%20staple@", 1) + "/account/notifications"
+	if resp := call(t, "GET", strings.Replace(account, "staple", "wrong", 1), "", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("wrong password: status %d", resp.StatusCode)
+	}
+	if resp := call(t, "PUT", account, `{"events":{"comment.posted":true}}`, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unknown event: status %d", resp.StatusCode)
+	}
+	var settings NotificationSettings
+	resp := call(t, "PUT", account, `{"email":"bob@example.com","events":{"backup.failed":true}}`, &settings)
+	if resp.StatusCode != http.StatusOK || settings.Email != "bob@example.com" ||
+		!settings.Events[notify.EventArticlePublished] || !settings.Events[notify.EventBackupFailed] || settings.Events[notify.EventJobFailed] {
+		t.Fatalf("status %d, settings %+v", resp.StatusCode, settings)
+	}
+
+	// Drafts are not announced, their first publication is
+	call(t, "POST", srv.URL+"/articles", `{"title":"Hello","desc":"d","content":"c","status":"draft"}`, nil)
+	call(t, "PUT", srv.URL+"/articles/1", `{"status":"published"}`, nil)
+	call(t, "PUT", srv.URL+"/articles/1", `{"status":"published"}`, nil)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(recorder.messages()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond) // catch duplicates
+	sent := recorder.messages()
+	if len(sent) != 1 || sent[0].To != "bob@example.com" || sent[0].Subject != "Published: Hello" {
+		t.Errorf("sent %+v", sent)
+	}
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	verifier := client.NewWebhookVerifier("0123456789abcdef")
+	var mu sync.Mutex
+	var received []client.WebhookDelivery
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivery, err := verifier.Read(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received = append(received, delivery)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	srv := newTestServer(t, 0)
+	srv.userWebhookClient = hook.Client() // the test server is on loopback
+	recorder := &recordingMailer{}
+	templates, err := notify.LoadTemplates("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.mailer, srv.mailTemplates = recorder, templates
+	if err := srv.StartBackground(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.StopBackground(context.Background()) })
+	srv.AddUser("bob", "battery staple", model.RoleEditor)
+	bob := strings.Replace(srv.URL, "http://", "http://bob:battery%20staple@", 1)
+	call(t, "PUT", bob+"/account/notifications", `{"email": "bob@example.com"}`, nil)
+
+	for _, invalid := range []string{
+		`{"events": {"article.published": ["sms"]}}`,
+		`{"events": {"comment.posted": ["email"]}}`,
+		`{"events": {"article.published": ["webhook"]}}`, // no webhook
+		`{"webhook_url": "` + hook.URL + `", "webhook_secret": "short"}`,
+	} {
+		if resp := call(t, "PUT", bob+"/account/preferences", invalid, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status %d", invalid, resp.StatusCode)
+		}
+	}
+	var prefs Preferences
+	body := `{"webhook_url": "` + hook.URL + `", "webhook_secret": "0123456789abcdef",
+		"events": {"article.published": ["webhook"], "user.mentioned": ["webhook", "email"]}}`
+	if resp := call(t, "PUT", bob+"/account/preferences", body, &prefs); resp.StatusCode != http.StatusOK || prefs.WebhookURL != hook.URL ||
+		!slices.Equal(prefs.Events[notify.EventArticlePublished], []string{"webhook"}) ||
+		!slices.Equal(prefs.Events[notify.EventMentioned], []string{"email", "webhook"}) || len(prefs.Events[notify.EventJobFailed]) != 0 {
+		t.Fatalf("status %d, preferences %+v", resp.StatusCode, prefs)
+	}
+	var settings NotificationSettings
+	if call(t, "GET", bob+"/account/notifications", "", &settings); settings.Events[notify.EventArticlePublished] || !settings.Events[notify.EventMentioned] {
+		t.Errorf("email settings %+v", settings)
+	}
+
+	// Bob hears of the publication by webhook, and of his mention by both;
+	// an email address isn't a mention
+	var article model.Article
+	call(t, "POST", srv.URL+"/articles", `{"title": "Hello", "desc": "Thanks, @bob.", "content": "Mail carol@bob.example"}`, &article)
+	call(t, "PUT", fmt.Sprintf("%s/articles/%d", srv.URL, article.ID), `{"content": "Still thanks @bob"}`, nil)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n >= 2 && len(recorder.messages()) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d deliveries, %d emails", n, len(recorder.messages()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond) // catch duplicates
+	if sent := recorder.messages(); len(sent) != 1 || sent[0].To != "bob@example.com" || sent[0].Subject != "You were mentioned in: Hello" {
+		t.Errorf("sent %+v", sent)
+	}
+	mu.Lock()
+	events := map[string]notify.Notification{}
+	for _, delivery := range received {
+		var n notify.Notification
+		json.Unmarshal(delivery.Data, &n)
+		events[delivery.Event] = n
+	}
+	if mention := events[notify.EventMentioned]; len(received) != 2 || mention.Recipient != "bob" || mention.Article == nil ||
+		mention.Article.ID != article.ID || mention.Article.Content != "" || events[notify.EventArticlePublished].Article == nil {
+		t.Errorf("received %+v", received)
+	}
+	mu.Unlock()
+
+	// Turning email on at /account/notifications adds it to the channels
+	call(t, "PUT", bob+"/account/notifications", `{"events": {"article.published": true}}`, nil)
+	if call(t, "GET", bob+"/account/preferences", "", &prefs); !slices.Equal(prefs.Events[notify.EventArticlePublished], []string{"email", "webhook"}) {
+		t.Errorf("preferences %+v", prefs)
+	}
+	if resp := call(t, "PUT", bob+"/account/preferences", `{"webhook_url": ""}`, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("removing a webhook in use: status %d", resp.StatusCode)
+	}
+}
+
+func TestDigests(t *testing.T) {
+	srv := newTestServer(t, 0)
+	recorder := &recordingMailer{}
+	templates, err := notify.LoadTemplates("", "https://blog.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.mailer, srv.mailTemplates = recorder, templates
+	if err := srv.StartBackground(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.StopBackground(context.Background()) })
+	srv.AddUser("bob", "battery staple", model.RoleEditor)
+	bob := strings.Replace(srv.URL, "http://", "http://bob:battery%20staple@", 1)
+	digests := func() []notify.Message { // bob hears of publications too
+		return slices.DeleteFunc(recorder.messages(), func(m notify.Message) bool { return !strings.HasPrefix(m.Subject, "Your weekly digest") })
+	}
+
+	call(t, "POST", srv.URL+"/articles", `{"title": "Old news", "desc": "d", "content": "c"}`, nil)
+	if resp := call(t, "PUT", bob+"/account/preferences", `{"digest": "weekly"}`, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("digest without an address: status %d", resp.StatusCode)
+	}
+	call(t, "PUT", bob+"/account/notifications", `{"email": "bob@example.com"}`, nil)
+	if resp := call(t, "PUT", bob+"/account/preferences", `{"digest": "hourly"}`, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown frequency: status %d", resp.StatusCode)
+	}
+	var prefs Preferences
+	if resp := call(t, "PUT", bob+"/account/preferences", `{"digest": "weekly"}`, &prefs); resp.StatusCode != http.StatusOK || prefs.Digest != "weekly" {
+		t.Fatalf("status %d, preferences %+v", resp.StatusCode, prefs)
+	}
+	call(t, "POST", srv.URL+"/articles", `{"title": "Fresh news", "desc": "d", "content": "c"}`, nil)
+	call(t, "POST", srv.URL+"/articles", `{"title": "Not yet", "desc": "d", "content": "c", "status": "draft"}`, nil)
+
+	// Not due before the Monday after subscribing
+	now := time.Now()
+	if report, err := srv.sendDigests(now); err != nil || report.Sent != 0 {
+		t.Errorf("early digest: %+v, %v", report, err)
+	}
+	later := now.AddDate(0, 0, 8)
+	if report, err := srv.sendDigests(later); err != nil || report != (DigestReport{Sent: 1, Articles: 1}) {
+		t.Fatalf("report %+v, %v", report, err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(digests()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	sent := digests()
+	if len(sent) != 1 || sent[0].To != "bob@example.com" || !strings.Contains(sent[0].Body, "Fresh news") ||
+		strings.Contains(sent[0].Body, "Old news") || strings.Contains(sent[0].Body, "Not yet") ||
+		!strings.HasPrefix(sent[0].Unsubscribe, "https://blog.example.com/digest/unsubscribe?") || !strings.Contains(sent[0].Body, sent[0].Unsubscribe) {
+		t.Fatalf("sent %+v", sent)
+	}
+	if report, _ := srv.sendDigests(later); report.Sent != 0 {
+		t.Errorf("sent twice: %+v", report)
+	}
+
+	link := srv.URL + strings.TrimPrefix(sent[0].Unsubscribe, "https://blog.example.com")
+	if resp := call(t, "GET", strings.Replace(link, "user=bob", "user=alice", 1), "", nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("link of another user: status %d", resp.StatusCode)
+	}
+	resp, err := http.Get(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(page), `<form method="post">`) {
+		t.Errorf("page: status %d, %s", resp.StatusCode, page)
+	}
+	// Still subscribed until the form is posted, as a mail client's one-click unsubscribe does
+	if call(t, "GET", bob+"/account/preferences", "", &prefs); prefs.Digest != "weekly" {
+		t.Errorf("preferences after GET %+v", prefs)
+	}
+	if resp, err = http.Post(link, "application/x-www-form-urlencoded", strings.NewReader("List-Unsubscribe=One-Click")); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if call(t, "GET", bob+"/account/preferences", "", &prefs); resp.StatusCode != http.StatusOK || prefs.Digest != "off" {
+		t.Errorf("unsubscribe: status %d, preferences %+v", resp.StatusCode, prefs)
+	}
+}
+
+func TestPushNotifications(t *testing.T) {
+	var mu sync.Mutex
+	var pushed []*http.Request
+	gone := false
+	service := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		pushed = append(pushed, r)
+		if gone {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer service.Close()
+
+	key, err := webpush.GenerateVAPID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VAPID_PRIVATE_KEY", key)
+	t.Setenv("PUSH_EVENTS", "article.published")
+	srv := newTestServer(t, 0)
+	srv.pushClient = service.Client() // the test server is on loopback
+	if err := srv.StartBackground(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.StopBackground(context.Background()) })
+	srv.AddUser("bob", "battery staple", model.RoleEditor)
+	bob := strings.Replace(srv.URL, "http://", "http://bob:battery%20staple@", 1)
+
+	var public PushKey
+	if resp := call(t, "GET", srv.URL+"/push/key", "", &public); resp.StatusCode != http.StatusOK || public.PublicKey == "" {
+		t.Fatalf("status %d, key %+v", resp.StatusCode, public)
+	}
+	browser, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subscription := func(endpoint string) string {
+		return fmt.Sprintf(`{"endpoint": %q, "keys": {"p256dh": %q, "auth": "AAECAwQFBgcICQoLDA0ODw"}}`,
+			endpoint, base64.RawURLEncoding.EncodeToString(browser.PublicKey().Bytes()))
+	}
+	if resp := call(t, "POST", srv.URL+"/push/subscribe", subscription(service.URL+"/1"), nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("anonymous subscription: status %d", resp.StatusCode)
+	}
+	for _, invalid := range []string{
+		subscription(strings.Replace(service.URL, "https:", "http:", 1) + "/1"),
+		`{"endpoint": "` + service.URL + `/1", "keys": {"p256dh": "bm90IGEga2V5", "auth": "AAECAwQFBgcICQoLDA0ODw"}}`,
+	} {
+		if resp := call(t, "POST", bob+"/push/subscribe", invalid, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status %d", invalid, resp.StatusCode)
+		}
+	}
+	var sub model.PushSubscription
+	if resp := call(t, "POST", bob+"/push/subscribe", subscription(service.URL+"/1"), &sub); resp.StatusCode != http.StatusCreated || sub.Endpoint != service.URL+"/1" {
+		t.Fatalf("status %d, subscription %+v", resp.StatusCode, sub)
+	}
+	if resp := call(t, "POST", bob+"/push/subscribe", subscription(service.URL+"/1"), nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("subscribing again: status %d", resp.StatusCode)
+	}
+	call(t, "POST", bob+"/push/subscribe", subscription(service.URL+"/2"), nil)
+	if resp := call(t, "POST", bob+"/push/unsubscribe", `{"endpoint": "`+service.URL+`/2"}`, nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("unsubscribing: status %d", resp.StatusCode)
+	}
+	if resp := call(t, "POST", bob+"/push/unsubscribe", `{"endpoint": "`+service.URL+`/2"}`, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unsubscribing again: status %d", resp.StatusCode)
+	}
+	var prefs Preferences
+	if call(t, "GET", bob+"/account/preferences", "", &prefs); !slices.Contains(prefs.Events[notify.EventArticlePublished], notify.ChannelPush) ||
+		slices.Contains(prefs.Events[notify.EventMentioned], notify.ChannelPush) {
+		t.Errorf("preferences %+v", prefs)
+	}
+
+	// A publication is pushed, encrypted and signed
+	waitPushes := func(n int) []*http.Request {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			mu.Lock()
+			got := slices.Clone(pushed)
+			mu.Unlock()
+			if len(got) >= n {
+				return got
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%d pushes, want %d", len(got), n)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	call(t, "POST", srv.URL+"/articles", `{"title": "Hello", "desc": "d", "content": "c"}`, nil)
+	got := waitPushes(1)
+	if r := got[0]; r.URL.Path != "/1" || r.Header.Get("Content-Encoding") != "aes128gcm" || r.Header.Get("TTL") != "86400" ||
+		!strings.HasPrefix(r.Header.Get("Authorization"), "vapid t=") || !strings.HasSuffix(r.Header.Get("Authorization"), "k="+public.PublicKey) {
+		t.Errorf("push %s %v", r.URL, r.Header)
+	}
+
+	// A subscription the push service says is gone is removed
+	mu.Lock()
+	gone = true
+	mu.Unlock()
+	call(t, "POST", srv.URL+"/articles", `{"title": "Again", "desc": "d", "content": "c"}`, nil)
+	waitPushes(2)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if user, _ := srv.findUser("bob"); len(user.PushSubscriptions) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("gone subscription kept")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestChatNotifications(t *testing.T) {
+	var mu sync.Mutex
+	var posted []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Text string }
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		posted = append(posted, body.Text)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	srv := newTestServer(t, 0)
+	cfg := srv.cfg
+	cfg.ChatWebhooks = []string{hook.URL + "/services/T0/B0/x"}
+	cfg.ChatEvents = []string{notify.EventArticlePublished, notify.EventImportCompleted}
+	if err := srv.initNotifier(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.StartBackground(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.StopBackground(context.Background()) })
+
+	call(t, "POST", srv.URL+"/articles", `{"title":"Hello","desc":"d","content":"c"}`, nil)
+	call(t, "POST", srv.URL+"/articles/import.csv", "title,desc,content\nOne,d,c\nTwo,d,\n", nil)
+
+	want := []string{"Import finished: Imported 1 articles, skipped 1 (first error: line 3: content is required)", "Published: Hello"}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		got := slices.Clone(posted)
+		mu.Unlock()
+		slices.Sort(got)
+		if slices.Equal(got, want) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("posted %q, want %q", got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPlugins(t *testing.T) {
+	srv := newTestServer(t, 0)
+	var updated []string
+	err := srv.AddPlugin(Plugin{
+		Name: "test",
+		BeforeCreate: func(ctx context.Context, req *model.CreateArticleRequest) error {
+			if strings.Contains(req.Title, "!!") {
+				return &ValidationError{Message: "Titles may not shout"}
+			}
+			if req.Desc == "" {
+				req.Desc = "Summary of " + req.Title
+			}
+			return nil
+		},
+		AfterUpdate: func(ctx context.Context, article model.Article) {
+			updated = append(updated, article.Title)
+		},
+		OnServeList: func(ctx context.Context, list []model.Article) []model.Article {
+			return slices.DeleteFunc(list, func(a model.Article) bool { return a.Status == model.StatusDraft })
+		},
+		Routes: []PluginRoute{{Method: "GET", Path: "/hello", Summary: "Say hello", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "hello")
+		})}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.AddPlugin(Plugin{Name: "clash", Routes: []PluginRoute{{Method: "GET", Path: "/articles", Handler: http.NotFoundHandler()}}}); err == nil {
+		t.Error("plugin replaced a built-in route")
+	}
+	srv.reroute()
+
+	if resp := call(t, "POST", srv.URL+"/articles", `{"title":"Hey!!","desc":"d","content":"c"}`, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("rejected create: status %d", resp.StatusCode)
+	}
+	var created model.Article
+	call(t, "POST", srv.URL+"/articles", `{"title":"Hello","content":"c"}`, &created)
+	if created.Desc != "Summary of Hello" {
+		t.Errorf("enriched article %+v", created)
+	}
+	call(t, "POST", srv.URL+"/articles", `{"title":"Draft","desc":"d","content":"c","status":"draft"}`, nil)
+	call(t, "PUT", srv.URL+"/articles/1", `{"title":"Hello again"}`, nil)
+	if !slices.Equal(updated, []string{"Hello again"}) {
+		t.Errorf("AfterUpdate saw %q", updated)
+	}
+
+	var list []model.Article
+	call(t, "GET", srv.URL+"/articles", "", &list)
+	var page ListArticlesResponse
+	call(t, "GET", srv.URL+"/articles?page_size=10", "", &page)
+	if len(list) != 1 || len(page.Articles) != 1 || list[0].ID != 1 {
+		t.Errorf("listed %+v and %+v, want only the published article", list, page.Articles)
+	}
+
+	resp, err := http.Get(srv.URL + "/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hello" {
+		t.Errorf("custom route answered %q", body)
+	}
+	var spec struct{ Paths map[string]any }
+	resp, _ = http.Get(srv.URL + "/openapi.json")
+	json.NewDecoder(resp.Body).Decode(&spec)
+	resp.Body.Close()
+	if spec.Paths["/hello"] == nil {
+		t.Error("custom route missing from the OpenAPI document")
+	}
+}
+
+func TestMiddlewarePipeline(t *testing.T) {
+	cfg := testConfig(t, 1)
+	cfg.MiddlewareDisable = []string{"admin"}
+	if _, err := New(cfg, &memStore{}); err == nil {
+		t.Error("required middleware disabled")
+	}
+	cfg.MiddlewareDisable = []string{"log", "compress"}
+	cfg.CORSOrigins = []string{"https://app.example.com"}
+	cfg.RateLimit, cfg.RateLimitBurst = 60, 3
+	srv := startTestServer(t, cfg)
+
+	// The preflight matches no route, so only whole-router stages see it
+	req, _ := http.NewRequest("OPTIONS", srv.URL+"/articles", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		resp.Header.Get("Access-Control-Allow-Headers") != "Content-Type" {
+		t.Errorf("preflight: status %d, headers %v", resp.StatusCode, resp.Header)
+	}
+
+	req, _ = http.NewRequest("GET", srv.URL+"/articles", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err = http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Access-Control-Allow-Origin") != "" || resp.Header.Get("Content-Encoding") != "" {
+		t.Errorf("other origin: status %d, headers %v", resp.StatusCode, resp.Header)
+	}
+
+	// CORS answered the preflight before the rate limit; three GETs use up the burst
+	call(t, "GET", srv.URL+"/articles/1", "", nil)
+	call(t, "GET", srv.URL+"/articles/1", "", nil)
+	resp = call(t, "GET", srv.URL+"/articles/1", "", nil)
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "1" {
+		t.Errorf("over the limit: status %d, Retry-After %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+}
+
+func TestInFlightLimit(t *testing.T) {
+	t.Setenv("MAX_IN_FLIGHT", "1")
+	t.Setenv("MAX_IN_FLIGHT_WAIT", "20ms")
+	srv := newTestServer(t, 1)
+
+	// A create waits for the write lock, holding the only slot
+	srv.articlesMutex.Lock()
+	created := make(chan int)
+	go func() {
+		resp, err := http.Post(srv.URL+"/articles", "application/json", strings.NewReader(`{"title":"t","desc":"d","content":"c"}`))
+		if err != nil {
+			created <- 0
+			return
+		}
+		resp.Body.Close()
+		created <- resp.StatusCode
+	}()
+
+	var resp *http.Response
+	for range 100 {
+		if resp = call(t, "GET", srv.URL+"/", "", nil); resp.StatusCode == http.StatusServiceUnavailable {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != "1" {
+		t.Errorf("over the limit: status %d, Retry-After %q, want 503 and 1", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	if resp := call(t, "GET", srv.URL+"/readyz", "", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("readiness over the limit: status %d, want 200", resp.StatusCode)
+	}
+
+	srv.articlesMutex.Unlock()
+	if status := <-created; status != http.StatusCreated {
+		t.Errorf("create holding the slot: status %d, want 201", status)
+	}
+	if resp := call(t, "GET", srv.URL+"/", "", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("after the create: status %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestInterceptors(t *testing.T) {
+	srv := newTestServer(t, 1)
+	var mu sync.Mutex
+	var completed []string
+	writes := func(r Route) bool { return r.Method != http.MethodGet }
+	err := srv.AddPlugin(Plugin{Name: "test", Interceptors: []Interceptor{
+		{
+			Name:   "read-only-fridays",
+			Routes: writes,
+			PreHandle: func(w http.ResponseWriter, inv *Invocation) bool {
+				if inv.Request.Header.Get("X-Friday") != "" {
+					http.Error(w, "No deploys on Friday", http.StatusServiceUnavailable)
+					return false
+				}
+				inv.Attributes["checked"] = true
+				return true
+			},
+			AfterCompletion: func(inv *Invocation) {
+				mu.Lock()
+				defer mu.Unlock()
+				completed = append(completed, fmt.Sprintf("%s %s %d %v", inv.Request.Method, inv.Route.Path, inv.Status, inv.Attributes["checked"]))
+			},
+		},
+		{
+			Name: "timing",
+			PostHandle: func(inv *Invocation) {
+				inv.Header.Set("X-Route", inv.Route.Path)
+			},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.reroute()
+
+	resp := call(t, "GET", srv.URL+"/articles/1", "", nil)
+	if resp.Header.Get("X-Route") != "/articles/{id}" {
+		t.Errorf("PostHandle header %q", resp.Header.Get("X-Route"))
+	}
+	call(t, "PUT", srv.URL+"/articles/1", `{"title":"New"}`, nil)
+
+	req, _ := http.NewRequest("DELETE", srv.URL+"/articles/1", nil)
+	req.Header.Set("X-Friday", "1")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("X-Route") != "" {
+		t.Errorf("stopped request: status %d, X-Route %q", resp.StatusCode, resp.Header.Get("X-Route"))
+	}
+	if n := srv.ArticleCount(); n != 1 {
+		t.Errorf("%d articles after the stopped delete", n)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"PUT /articles/{id} 200 true"}; !slices.Equal(completed, want) {
+		t.Errorf("completed %q, want %q", completed, want)
+	}
+}
+
+func TestPIIScan(t *testing.T) {
+	srv := newTestServer(t, 0)
+	srv.cfg.PIIScan = config.PIIFlag
+	if err := srv.initPII(srv.cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(method, url, body string) (envelope struct {
+		Data     model.Article
+		Warnings []Warning
+	}) {
+		t.Helper()
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			t.Fatalf("%s %s: %s", method, url, resp.Status)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+			t.Fatal(err)
+		}
+		return envelope
+	}
+
+	// Flagged data is kept
+	created := send("POST", srv.URL+"/articles",
+		`{"title":"Contact","desc":"Ask anna@example.com","content":"Call 040-1234567 or anna@example.com"}`)
+	want := []Warning{
+		{Code: WarningPIIDetected, Field: "desc", Kind: "email", Count: 1, Message: "Possible personal data found"},
+		{Code: WarningPIIDetected, Field: "content", Kind: "email", Count: 1, Message: "Possible personal data found"},
+		{Code: WarningPIIDetected, Field: "content", Kind: "phone", Count: 1, Message: "Possible personal data found"},
+	}
+	if !slices.Equal(created.Warnings, want) {
+		t.Errorf("create warnings %+v", created.Warnings)
+	}
+	if !strings.Contains(created.Data.Content, "040-1234567") {
+		t.Errorf("flagged content changed: %q", created.Data.Content)
+	}
+
+	// Redacted data is replaced; fields the update leaves alone aren't scanned
+	srv.cfg.PIIScan = config.PIIRedact
+	url := fmt.Sprintf("%s/articles/%d", srv.URL, created.Data.ID)
+	updated := send("PUT", url, `{"content":"HETU 131052-308T"}`)
+	if len(updated.Warnings) != 1 || updated.Warnings[0].Code != WarningPIIRedacted || updated.Warnings[0].Kind != "national_id" {
+		t.Errorf("update warnings %+v", updated.Warnings)
+	}
+	if updated.Data.Content != "HETU [redacted]" || updated.Data.Desc != "Ask anna@example.com" {
+		t.Errorf("updated to %q, %q", updated.Data.Desc, updated.Data.Content)
+	}
+	if clean := send("PUT", url, `{"title":"Nothing here"}`); clean.Warnings != nil {
+		t.Errorf("clean update warned %+v", clean.Warnings)
+	}
+}
+
+func TestSpamModeration(t *testing.T) {
+	srv := newTestServer(t, 0)
+	srv.cfg.SpamCheck, srv.cfg.SpamKeywords, srv.cfg.SpamThreshold = true, []string{"casino", "jackpot"}, 2
+	srv.initSpam(srv.cfg)
+	// A plugin check counts with the built-in ones
+	srv.AddPlugin(Plugin{Name: "shouting", SpamCheck: spam.CheckFunc(func(ctx context.Context, s spam.Submission) (int, string, error) {
+		if strings.ToUpper(s.Title) == s.Title {
+			return 1, "shouting", nil
+		}
+		return 0, "", nil
+	})})
+
+	create := func(url, title string) (model.Article, int) {
+		t.Helper()
+		var article model.Article
+		resp := call(t, "POST", url+"/articles", `{"title":"`+title+`","desc":"d","content":"Visit our casino"}`, &article)
+		return article, resp.StatusCode
+	}
+	held, status := create(srv.URL, "WIN BIG")
+	if status != http.StatusAccepted || held.Status != model.StatusDraft || held.Moderation == nil ||
+		held.Moderation.Score != 2 || !slices.Equal(held.Moderation.Reasons, []string{"keywords: casino", "shouting"}) {
+		t.Fatalf("held article: %d %+v %+v", status, held, held.Moderation)
+	}
+	if article, status := create(srv.URL, "Win big"); status != http.StatusCreated || article.Moderation != nil {
+		t.Errorf("one point: %d %+v", status, article.Moderation)
+	}
+	// Publishing a held article takes an approval
+	url := fmt.Sprintf("%s/articles/%d", srv.URL, held.ID)
+	if resp := call(t, "PUT", url, `{"status":"published"}`, nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("publishing a held article: %d", resp.StatusCode)
+	}
+	// nor does a refused publication change anything else
+	if resp := call(t, "PUT", url, `{"title":"Sneaky new title","status":"published"}`, nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("publishing a held article with a new title: %d", resp.StatusCode)
+	}
+	if article, _ := srv.readArticle(held.ID); article.Title != held.Title || article.Status != model.StatusDraft || article.Moderation == nil {
+		t.Errorf("held article changed by a refused update: %+v", article)
+	}
+
+	if _, err := srv.AddUser("alice", "correct horse", model.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	admin := strings.Replace(srv.URL, "http://", "http://alice:correct%20horse@", 1)
+	// Submissions with credentials aren't checked
+	if article, status := create(admin, "WIN BIG"); status != http.StatusCreated || article.Moderation != nil {
+		t.Errorf("admin's article: %d %+v", status, article.Moderation)
+	}
+	rejected, _ := create(srv.URL, "JACKPOT")
+
+	// Once there are users, only admins see held articles
+	var listed []model.Article
+	call(t, "GET", srv.URL+"/articles", "", &listed)
+	for _, a := range listed {
+		if a.Moderation != nil {
+			t.Errorf("anonymous listing has held article %d", a.ID)
+		}
+	}
+	for _, path := range []string{"", "/content", "/html"} {
+		if resp := call(t, "GET", url+path, "", nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("anonymous GET of held article%s: %d", path, resp.StatusCode)
+		}
+	}
+	var found []SearchResult
+	call(t, "GET", srv.URL+"/articles/search?q=casino", "", &found)
+	for _, result := range found {
+		if result.Article.Moderation != nil {
+			t.Errorf("anonymous search found held article %d", result.Article.ID)
+		}
+	}
+	if call(t, "GET", admin+"/articles", "", &listed); !slices.ContainsFunc(listed, func(a model.Article) bool { return a.ID == held.ID }) {
+		t.Error("admin's listing lacks the held article")
+	}
+	if resp := call(t, "GET", fmt.Sprintf("%s/articles/%d", admin, held.ID), "", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("admin's GET of held article: %d", resp.StatusCode)
+	}
+
+	var queue []model.Article
+	call(t, "GET", admin+"/admin/moderation", "", &queue)
+	if len(queue) != 2 || queue[0].ID != held.ID || queue[1].ID != rejected.ID {
+		t.Fatalf("moderation queue %+v", queue)
+	}
+	var approved model.Article
+	call(t, "POST", fmt.Sprintf("%s/admin/moderation/%d/approve", admin, held.ID), "", &approved)
+	if approved.Status != model.StatusPublished || approved.Moderation != nil || approved.Published.IsZero() {
+		t.Errorf("approved %+v", approved)
+	}
+	if resp := call(t, "POST", fmt.Sprintf("%s/admin/moderation/%d/approve", admin, held.ID), "", nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("approving twice: %d", resp.StatusCode)
+	}
+	call(t, "POST", fmt.Sprintf("%s/admin/moderation/%d/reject", admin, rejected.ID), "", nil)
+	if _, found := srv.readArticle(rejected.ID); found {
+		t.Error("rejected article kept")
+	}
+	if call(t, "GET", admin+"/admin/moderation", "", &queue); len(queue) != 0 {
+		t.Errorf("queue after moderation %+v", queue)
+	}
+}
+
+func TestCaptcha(t *testing.T) {
+	srv := newTestServer(t, 1)
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		fmt.Fprintf(w, `{"success":%t}`, r.Form.Get("response") == "passed" && r.Form.Get("secret") == "s3cret")
+	}))
+	srv.cfg.CaptchaProvider, srv.cfg.CaptchaSecret = "turnstile", "s3cret"
+	srv.initCaptcha(srv.cfg)
+	srv.captchaVerifier.Endpoint = provider.URL
+	srv.reroute()
+
+	post := func(url, token string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest("POST", url+"/articles", strings.NewReader(`{"title":"Hi","desc":"d","content":"c"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("X-Captcha-Token", token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body struct{ Code string }
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body.Code
+	}
+	if status, code := post(srv.URL, ""); status != http.StatusForbidden || code != CodeCaptchaRequired {
+		t.Errorf("no token: %d %s", status, code)
+	}
+	if status, code := post(srv.URL, "forged"); status != http.StatusForbidden || code != CodeCaptchaInvalid {
+		t.Errorf("bad token: %d %s", status, code)
+	}
+	if status, _ := post(srv.URL, "passed"); status != http.StatusCreated {
+		t.Errorf("good token: %d", status)
+	}
+	// Routes not in CAPTCHA_ROUTES, and users with credentials, need no token
+	if resp := call(t, "PUT", srv.URL+"/articles/1", `{"title":"Changed"}`, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("update: %d", resp.StatusCode)
+	}
+	if _, err := srv.AddUser("alice", "correct horse", model.RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := post(strings.Replace(srv.URL, "http://", "http://alice:correct%20horse@", 1), ""); status != http.StatusCreated {
+		t.Errorf("with credentials: %d", status)
+	}
+
+	// A provider that can't be asked lets nothing through
+	provider.Close()
+	if status, code := post(srv.URL, "passed"); status != http.StatusBadGateway || code != CodeUpstreamFailed {
+		t.Errorf("provider down: %d %s", status, code)
+	}
+}
+
+func TestSignedURLs(t *testing.T) {
+	srv := newTestServer(t, 1)
+	get := func(url string) (int, string, string) {
+		t.Helper()
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body struct{ Code string }
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body.Code, resp.Header.Get("Cache-Control")
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "pixel.png")
+	io.WriteString(fw, "\x89PNG\r\n\x1a\nprivate bytes")
+	mw.Close()
+	var attachment model.Attachment
+	resp, err := http.Post(srv.URL+"/articles/1/attachments?private=true", mw.FormDataContentType(), &body)
+	if err != nil || resp.StatusCode != http.StatusCreated {
+		t.Fatalf("upload: %v, status %d", err, resp.StatusCode)
+	}
+	json.NewDecoder(resp.Body).Decode(&Response{Data: &attachment})
+	resp.Body.Close()
+	if stored, _ := srv.findAttachment(1, attachment.ID); !attachment.Private || !stored.Private {
+		t.Fatalf("uploaded %+v, stored %+v", attachment, stored)
+	}
+	download := fmt.Sprintf("%s/articles/1/attachments/%d", srv.URL, attachment.ID)
+
+	// Open until the first user is added, but never to shared caches
+	if status, _, cc := get(download); status != http.StatusOK || cc != "private" {
+		t.Errorf("without users: %d, Cache-Control %q", status, cc)
+	}
+	if _, err := srv.AddUser("alice", "correct horse", model.RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+	authed := strings.Replace(srv.URL, "http://", "http://alice:correct%20horse@", 1)
+	if status, code, _ := get(download); status != http.StatusUnauthorized || code != CodeAuthenticationRequired {
+		t.Errorf("anonymous: %d %s", status, code)
+	}
+	if status, _, _ := get(strings.Replace(download, srv.URL, authed, 1)); status != http.StatusOK {
+		t.Errorf("with credentials: %d", status)
+	}
+
+	signURL := fmt.Sprintf("/articles/1/attachments/%d/signed-url", attachment.ID)
+	if resp := call(t, "POST", srv.URL+signURL, "", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("signing anonymously: %d", resp.StatusCode)
+	}
+	if resp := call(t, "POST", authed+signURL+"?expires_in=48h", "", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("past SIGNED_URL_TTL: %d", resp.StatusCode)
+	}
+	var signed SignedURL
+	if resp := call(t, "POST", authed+signURL+"?expires_in=10m", "", &signed); resp.StatusCode != http.StatusOK {
+		t.Fatalf("sign: %d", resp.StatusCode)
+	}
+	if left := time.Until(signed.Expires); left < 9*time.Minute || left > 10*time.Minute {
+		t.Errorf("expires in %s", left)
+	}
+	if status, _, _ := get(signed.URL); status != http.StatusOK {
+		t.Errorf("signed URL: %d", status)
+	}
+	if status, _, _ := get(signed.URL + "&w=1"); status == http.StatusUnauthorized || status == http.StatusForbidden {
+		t.Errorf("signed URL of a resized copy: %d", status)
+	}
+	if status, code, _ := get(strings.Replace(signed.URL, "signature=", "signature=x", 1)); status != http.StatusForbidden || code != CodeSignedURLInvalid {
+		t.Errorf("forged signature: %d %s", status, code)
+	}
+	expired := srv.urlSigner.Sign(signedResource(attachment.ID), time.Now().Add(-time.Minute))
+	if status, code, _ := get(download + "?" + expired.Encode()); status != http.StatusForbidden || code != CodeSignedURLExpired {
+		t.Errorf("expired signature: %d %s", status, code)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	verifier := client.NewWebhookVerifier("0123456789abcdef")
+	var mu sync.Mutex
+	var received []client.WebhookDelivery
+	var last *http.Request
+	var lastBody []byte
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		delivery, err := verifier.Read(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received = append(received, delivery)
+		last, lastBody = r, body
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	srv := newTestServer(t, 0)
+	file := filepath.Join(t.TempDir(), "webhooks.json")
+	os.WriteFile(file, []byte(`[{"url": "`+hook.URL+`", "secret": "0123456789abcdef", "events": ["article.created", "article.deleted"]}]`), 0o600)
+	cfg := srv.cfg
+	cfg.WebhooksFile = file
+	if err := srv.initWebhooks(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.StartBackground(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.StopBackground(context.Background()) })
+
+	var created model.Article
+	call(t, "POST", srv.URL+"/articles", `{"title":"Hello","desc":"d","content":"c"}`, &created)
+	call(t, "PUT", fmt.Sprintf("%s/articles/%d", srv.URL, created.ID), `{"title":"Changed"}`, nil)
+	call(t, "DELETE", fmt.Sprintf("%s/articles/%d", srv.URL, created.ID), "", nil)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("received %d deliveries", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	slices.SortFunc(received, func(a, b client.WebhookDelivery) int { return a.Time.Compare(b.Time) })
+	if len(received) != 2 || received[0].Event != EventArticleCreated || received[1].Event != EventArticleDeleted || received[0].ID == received[1].ID {
+		t.Fatalf("received %+v", received)
+	}
+	if article, err := received[0].Article(); err != nil || article.Title != "Hello" {
+		t.Errorf("article %+v, %v", article, err)
+	}
+
+	// A captured delivery can't be replayed, nor signed with another secret
+	if err := verifier.Verify(last.Header, lastBody); !errors.Is(err, client.ErrWebhookReplay) {
+		t.Errorf("replay: %v", err)
+	}
+	if err := client.NewWebhookVerifier("another secret!!").Verify(last.Header, lastBody); !errors.Is(err, client.ErrWebhookSignature) {
+		t.Errorf("other secret: %v", err)
+	}
+}
+
+func TestConfidentialArticles(t *testing.T) {
+	srv := newTestServer(t, 1)
+	srv.articlesMutex.Lock()
+	srv.articles[0].Status, srv.articles[0].Published = model.StatusPublished, time.Now() // for the feed; seeding may make a draft
+	srv.articlesMutex.Unlock()
+	secret := `{"title": "Plans", "desc": "Next year", "content": "The secret plans", "confidential": true}`
+	if resp := call(t, "POST", srv.URL+"/articles", secret, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("confidential without keys: %d", resp.StatusCode)
+	}
+
+	file := filepath.Join(t.TempDir(), "keys.json")
+	os.WriteFile(file, []byte(`[{"id": "k1", "key": "`+base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))+`"}]`), 0o600)
+	srv.cfg.EncryptionKeysFile = file
+	if err := srv.initEncryption(srv.cfg); err != nil {
+		t.Fatal(err)
+	}
+	var plans model.Article
+	if resp := call(t, "POST", srv.URL+"/articles", secret, &plans); resp.StatusCode != http.StatusCreated || !plans.Confidential {
+		t.Fatalf("create: %d, %+v", resp.StatusCode, plans)
+	}
+	srv.AddUser("alice", "correct horse", model.RoleEditor)
+	srv.AddUser("bob", "battery staple", model.RoleEditor)
+	alice := strings.Replace(srv.URL, "http://", "http://alice:correct%20horse@", 1)
+	bob := strings.Replace(srv.URL, "http://", "http://bob:battery%20staple@", 1)
+
+	// A workspace's articles are confidential with it, readable by members
+	if resp := call(t, "POST", alice+"/workspaces", `{"slug": "board", "name": "Board", "confidential": true}`, nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create workspace: %d", resp.StatusCode)
+	}
+	var minutes model.Article
+	call(t, "POST", alice+"/workspaces/board/articles", `{"title": "Minutes", "desc": "Meeting", "content": "Board minutes"}`, &minutes)
+	if !minutes.Confidential {
+		t.Fatalf("workspace article %+v", minutes)
+	}
+
+	content := func(base string, id int) string {
+		t.Helper()
+		var a model.Article
+		call(t, "GET", fmt.Sprintf("%s/articles/%d", base, id), "", &a)
+		return a.Content
+	}
+	for _, c := range []struct {
+		base           string
+		plans, minutes string
+	}{
+		{srv.URL, "", ""},
+		{alice, "The secret plans", "Board minutes"},
+		{bob, "The secret plans", ""},
+	} {
+		if got := content(c.base, plans.ID); got != c.plans {
+			t.Errorf("%s: plans content %q, want %q", c.base, got, c.plans)
+		}
+		if got := content(c.base, minutes.ID); got != c.minutes {
+			t.Errorf("%s: minutes content %q, want %q", c.base, got, c.minutes)
+		}
+	}
+
+	var list []model.Article
+	call(t, "GET", srv.URL+"/articles", "", &list)
+	for _, a := range list {
+		if a.Confidential && a.Content != "" {
+			t.Errorf("listing shows article %d content %q", a.ID, a.Content)
+		}
+	}
+	markdown := fmt.Sprintf("/articles/%d/content", minutes.ID)
+	for base, want := range map[string]int{srv.URL: http.StatusUnauthorized, bob: http.StatusForbidden, alice: http.StatusOK} {
+		if resp := call(t, "GET", base+markdown, "", nil); resp.StatusCode != want {
+			t.Errorf("%s: content status %d, want %d", base, resp.StatusCode, want)
+		}
+	}
+	resp, err := http.Get(srv.URL + "/feed.rss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if feed, _ := io.ReadAll(resp.Body); !strings.Contains(string(feed), "<item>") || strings.Contains(string(feed), "Plans") {
+		t.Errorf("feed %s", feed)
+	}
+}
+
+func TestAdminDashboard(t *testing.T) {
+	srv := newTestServer(t, 1)
+	srv.AddUser("alice", "correct horse", model.RoleAdmin)
+	srv.AddUser("bob", "battery staple", model.RoleEditor)
+
+	// The page and its script load without credentials; the script asks
+	for _, path := range []string{"/admin", "/admin-ui/app.js", "/admin-ui/style.css"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: %d", path, resp.StatusCode)
+		}
+	}
+	if resp, _ := http.Get(srv.URL + "/admin-ui/missing.js"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing asset: %d", resp.StatusCode)
+	}
+
+	users := srv.URL + "/admin/users"
+	if resp := call(t, "GET", users, "", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("users without credentials: %d", resp.StatusCode)
+	}
+	bob := strings.Replace(users, "http://", "http://bob:battery%20staple@", 1)
+	if resp := call(t, "GET", bob, "", nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("users for an editor: %d", resp.StatusCode)
+	}
+	resp, err := http.Get(strings.Replace(users, "http://", "http://alice:correct%20horse@", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	var list []UserSummary
+	json.Unmarshal(body, &Response{Data: &list})
+	if len(list) != 2 || list[0].Username != "alice" || list[1].Role != model.RoleEditor {
+		t.Errorf("users: %s", body)
+	}
+	if bytes.Contains(body, []byte("pbkdf2")) {
+		t.Error("users list the password hashes")
+	}
+}
+
+func TestBlogPages(t *testing.T) {
+	srv := newTestServer(t, 0)
+	if resp, _ := http.Get(srv.URL + "/blog"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("blog without BLOG: %d", resp.StatusCode)
+	}
+	srv.cfg.Blog = true
+	srv.reroute()
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	var article, draft model.Article
+	call(t, "POST", srv.URL+"/articles", `{"title": "Fish & chips", "desc": "About <fish>", "content": "**Bold** and <b>raw</b>"}`, &article)
+	call(t, "POST", srv.URL+"/articles", `{"title": "Unfinished", "desc": "Draft", "content": "Later", "status": "draft"}`, &draft)
+
+	status, body := get("/blog")
+	if status != http.StatusOK || !strings.Contains(body, `href="/blog/`+article.Slug+`"`) || strings.Contains(body, "Unfinished") {
+		t.Errorf("index: %d\n%s", status, body)
+	}
+	if !strings.Contains(body, "Fish &amp; chips") || strings.Contains(body, "<fish>") {
+		t.Errorf("index doesn't escape:\n%s", body)
+	}
+	status, body = get("/blog/" + article.Slug)
+	if status != http.StatusOK || !strings.Contains(body, "<strong>Bold</strong>") || !strings.Contains(body, "and <b>raw</b>") {
+		t.Errorf("article: %d\n%s", status, body)
+	}
+	if status, _ := get("/blog/" + draft.Slug); status != http.StatusNotFound {
+		t.Errorf("draft: %d", status)
+	}
+	if status, _ := get("/blog?page=2"); status != http.StatusNotFound {
+		t.Errorf("page past the end: %d", status)
+	}
+	if status, body := get("/blog/assets/style.css"); status != http.StatusOK || body == "" {
+		t.Errorf("stylesheet: %d", status)
+	}
+	if status, _ := get("/blog/assets/layout.html"); status != http.StatusNotFound {
+		t.Errorf("template served as an asset: %d", status)
+	}
+}
+
+func TestBlogTheme(t *testing.T) {
+	srv := newTestServer(t, 0)
+	theme := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(theme, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("layout.html", `{{define "layout"}}<main class="branded">{{template "content" .}}</main>{{end}}`)
+	write("logo.svg", `<svg xmlns="http://www.w3.org/2000/svg"/>`)
+	srv.cfg.Blog = true
+	srv.cfg.ThemeDir = theme
+	if err := srv.initBlog(srv.cfg); err != nil {
+		t.Fatal(err)
+	}
+	srv.reroute()
+	get := func(path string) (*http.Response, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	if resp, body := get("/blog"); resp.StatusCode != http.StatusOK || !strings.Contains(body, `<main class="branded">`) {
+		t.Errorf("themed index: %d\n%s", resp.StatusCode, body)
+	}
+	if resp, body := get("/blog/assets/logo.svg"); resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/svg+xml" || !strings.Contains(body, "<svg") {
+		t.Errorf("theme asset: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if resp, _ := get("/blog/assets/style.css"); resp.StatusCode != http.StatusOK {
+		t.Errorf("embedded asset under a theme: %d", resp.StatusCode)
+	}
+
+	write("layout.html", `{{define "layout"}}<main class="rebranded">{{template "content" .}}</main>{{end}}`)
+	if err := srv.reloadBlogTemplates(); err != nil {
+		t.Fatal(err)
+	}
+	if _, body := get("/blog"); !strings.Contains(body, `<main class="rebranded">`) {
+		t.Errorf("not reloaded:\n%s", body)
+	}
+	write("layout.html", `{{define "layout"}}{{if}}{{end}}`)
+	if err := srv.reloadBlogTemplates(); err == nil {
+		t.Error("broken template reloaded")
+	}
+	if _, body := get("/blog"); !strings.Contains(body, `<main class="rebranded">`) {
+		t.Errorf("broken template replaced the working one:\n%s", body)
+	}
+	if stamp := themeStamp(theme); stamp == themeStamp(t.TempDir()) {
+		t.Errorf("theme stamp %q", stamp)
+	}
+}
+
+func TestArticleTranslations(t *testing.T) {
+	srv := newTestServer(t, 0)
+	getIn := func(path, lang string) model.Article {
+		t.Helper()
+		req, _ := http.NewRequest("GET", srv.URL+path, nil)
+		req.Header.Set("Accept-Language", lang)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var envelope struct{ Data model.Article }
+		json.NewDecoder(resp.Body).Decode(&envelope)
+		return envelope.Data
+	}
+
+	var article model.Article
+	call(t, "POST", srv.URL+"/articles", `{"title": "Hello", "desc": "Greeting", "content": "Hello world"}`, &article)
+	url := fmt.Sprintf("%s/articles/%d/translations", srv.URL, article.ID)
+	if resp := call(t, "PUT", url+"/fi", `{"title": "Hei", "desc": "Tervehdys", "content": "Hei maailma"}`, nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("add translation: %d", resp.StatusCode)
+	}
+	if resp := call(t, "PUT", url+"/en", `{"title": "Hi", "desc": "Hi", "content": "Hi"}`, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("translation into the article's language: %d", resp.StatusCode)
+	}
+	if resp := call(t, "PUT", url+"/not_a_tag", `{"title": "Hi", "desc": "Hi", "content": "Hi"}`, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid tag: %d", resp.StatusCode)
+	}
+
+	path := fmt.Sprintf("/articles/%d", article.ID)
+	if a := getIn(path, "fi-FI, en;q=0.5"); a.Title != "Hei" || a.Content != "Hei maailma" || a.Language != "fi" {
+		t.Errorf("fi: %+v", a)
+	}
+	if a := getIn(path+"?lang=fi", ""); a.Title != "Hei" {
+		t.Errorf("?lang=fi: %+v", a)
+	}
+	if a := getIn(path, ""); a.Title != "Hello" || a.Language != "" || a.Translations["fi"].Title != "Hei" {
+		t.Errorf("no language: %+v", a)
+	}
+	if a := getIn("/articles/by-slug/"+article.Slug, "sv, en;q=0.8"); a.Title != "Hello" {
+		t.Errorf("sv falls back to the article: %+v", a)
+	}
+
+	var states ArticleTranslations
+	call(t, "GET", url, "", &states)
+	if states.Language != "en" || len(states.Translations) != 1 || states.Translations[0].State != TranslationCurrent {
+		t.Errorf("states: %+v", states)
+	}
+	call(t, "PUT", srv.URL+path, `{"content": "Hello, world"}`, nil)
+	call(t, "GET", url, "", &states)
+	if states.Translations[0].State != TranslationOutdated {
+		t.Errorf("after a change: %+v", states)
+	}
+	var missing []ArticleTranslations
+	call(t, "GET", srv.URL+"/admin/translations?language=sv", "", &missing)
+	if len(missing) != 1 || missing[0].Translations[0].State != TranslationMissing {
+		t.Errorf("missing sv: %+v", missing)
+	}
+	call(t, "GET", srv.URL+"/admin/translations?language=fi", "", &missing)
+	if len(missing) != 1 || missing[0].Translations[0].State != TranslationOutdated {
+		t.Errorf("outdated fi: %+v", missing)
+	}
+
+	if resp := call(t, "DELETE", url+"/fi", "", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("delete: %d", resp.StatusCode)
+	}
+	if resp := call(t, "DELETE", url+"/fi", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("delete again: %d", resp.StatusCode)
+	}
+	if a := getIn(path, "fi"); a.Title != "Hello" || a.Translations != nil {
+		t.Errorf("after delete: %+v", a)
+	}
+}
+
+func TestLanguageDetection(t *testing.T) {
+	srv := newTestServer(t, 0)
+	srv.cfg.DetectLanguage = true
+
+	var fi, en, short model.Article
+	call(t, "POST", srv.URL+"/articles", `{"title": "Kesä", "desc": "Kesä on ollut lämmin", "content": "Kesä on tänä vuonna ollut lämmin, ja se on näkynyt myös puistoissa, kun ihmiset ovat viettäneet niissä iltoja."}`, &fi)
+	call(t, "POST", srv.URL+"/articles", `{"title": "Summer", "desc": "A warm one", "content": "The summer has been warm this year, and it was easy to see that in the parks when people were there in the evenings."}`, &en)
+	call(t, "POST", srv.URL+"/articles", `{"title": "Hi", "desc": "Hi", "content": "Hi"}`, &short)
+	if fi.Language != "fi" || en.Language != "en" || short.Language != "" {
+		t.Errorf("detected %q, %q and %q", fi.Language, en.Language, short.Language)
+	}
+
+	var list []model.Article
+	call(t, "GET", srv.URL+"/articles?language=fi", "", &list)
+	if len(list) != 1 || list[0].ID != fi.ID {
+		t.Errorf("?language=fi: %+v", list)
+	}
+	var page ListArticlesResponse
+	call(t, "GET", srv.URL+"/articles?language=en&page_size=1", "", &page)
+	if len(page.Articles) != 1 || page.Articles[0].ID != en.ID || page.NextPageToken == "" {
+		t.Errorf("en page: %+v", page) // short counts as FEED_LANGUAGE en
+	}
+	if resp := call(t, "GET", srv.URL+"/articles?language=x", "", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid language: %d", resp.StatusCode)
+	}
+
+	var updated model.Article
+	call(t, "PUT", fmt.Sprintf("%s/articles/%d", srv.URL, en.ID), `{"content": "Det var en gång en katt som bodde på landet, och den hade inte så mycket att göra men var nöjd med det."}`, &updated)
+	if updated.Language != "sv" {
+		t.Errorf("after a change into Swedish: %q", updated.Language)
+	}
+	call(t, "PUT", fmt.Sprintf("%s/articles/%d", srv.URL, en.ID), `{"content": "Ett annat innehåll", "language": "nb"}`, &updated)
+	if updated.Language != "nb" {
+		t.Errorf("language given: %q", updated.Language)
+	}
+}
+
+func TestSummarizer(t *testing.T) {
+	srv := newTestServer(t, 0)
+	if resp := call(t, "POST", srv.URL+"/articles/1/summarize", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("without a summarizer: %d", resp.StatusCode)
+	}
+
+	var asked []summarize.Request
+	fail := false
+	srv.AddPlugin(Plugin{Name: "first-line", Summarizer: summarize.SummarizerFunc(func(ctx context.Context, req summarize.Request) (string, error) {
+		if fail {
+			return "", errors.New("model overloaded")
+		}
+		asked = append(asked, req)
+		first, _, _ := strings.Cut(req.Content, "\n")
+		return "<b>" + first + "</b>", nil
+	})})
+	srv.reroute() // with the route
+	srv.cfg.SummarizeOnCreate = true
+
+	var article model.Article
+	if resp := call(t, "POST", srv.URL+"/articles", `{"title": "Tides", "content": "The sea rises twice a day.\nMore on that."}`, &article); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create without desc: %d", resp.StatusCode)
+	}
+	if article.Desc != "The sea rises twice a day." || len(asked) != 1 || asked[0].Language != "en" {
+		t.Errorf("summary on create: %q, asked %+v", article.Desc, asked)
+	}
+	var given model.Article
+	call(t, "POST", srv.URL+"/articles", `{"title": "Moon", "desc": "Mine", "content": "It pulls."}`, &given)
+	if given.Desc != "Mine" || len(asked) != 1 {
+		t.Errorf("desc given: %q, asked %d times", given.Desc, len(asked))
+	}
+
+	call(t, "PUT", fmt.Sprintf("%s/articles/%d", srv.URL, article.ID), `{"content": "Tides follow the moon.\nAnd the sun."}`, nil)
+	var summarized model.Article
+	if resp := call(t, "POST", fmt.Sprintf("%s/articles/%d/summarize", srv.URL, article.ID), "", &summarized); resp.StatusCode != http.StatusOK {
+		t.Fatalf("summarize: %d", resp.StatusCode)
+	}
+	if summarized.Desc != "Tides follow the moon." {
+		t.Errorf("summarized: %q", summarized.Desc)
+	}
+
+	fail = true
+	if resp := call(t, "POST", fmt.Sprintf("%s/articles/%d/summarize", srv.URL, article.ID), "", nil); resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("failing summarizer: %d", resp.StatusCode)
+	}
+	if resp := call(t, "POST", srv.URL+"/articles", `{"title": "Waves", "content": "They break."}`, nil); resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("create with a failing summarizer: %d", resp.StatusCode)
+	}
+	if resp := call(t, "POST", srv.URL+"/articles/999/summarize", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing article: %d", resp.StatusCode)
+	}
+}
+
+func TestArticleTags(t *testing.T) {
+	srv := newTestServer(t, 0)
+	content := "Goroutines are cheap, so a server runs one goroutine per request. Channels connect goroutines, and a closed channel tells every goroutine to stop."
+
+	var body struct {
+		Data          model.Article `json:"data"`
+		SuggestedTags []string      `json:"suggested_tags"`
+	}
+	send := func(method, url, payload string) int {
+		t.Helper()
+		body.Data, body.SuggestedTags = model.Article{}, nil
+		req, _ := http.NewRequest(method, url, strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode
+	}
+
+	// Tags as given, and no suggestions without AUTO_TAGS
+	send("POST", srv.URL+"/articles", `{"title": "Go", "desc": "Concurrency", "content": "`+content+`", "tags": [" Go ", "go", "Concurrency  Basics"]}`)
+	if !slices.Equal(body.Data.Tags, []string{"go", "concurrency basics"}) || body.SuggestedTags != nil {
+		t.Errorf("tags %q, suggested %q", body.Data.Tags, body.SuggestedTags)
+	}
+	id := body.Data.ID
+	if status := send("POST", srv.URL+"/articles", `{"title": "T", "desc": "D", "content": "C", "tags": ["`+strings.Repeat("x", 51)+`"]}`); status != http.StatusBadRequest {
+		t.Errorf("long tag: %d", status)
+	}
+
+	srv.cfg.AutoTags = config.AutoTagsSuggest
+	send("PUT", fmt.Sprintf("%s/articles/%d", srv.URL, id), `{"content": "`+content+` More goroutines."}`)
+	if len(body.SuggestedTags) == 0 || body.SuggestedTags[0] != "goroutines" || slices.Contains(body.SuggestedTags, "go") {
+		t.Errorf("suggested on update: %q", body.SuggestedTags)
+	}
+	send("PUT", fmt.Sprintf("%s/articles/%d", srv.URL, id), `{"tags": []}`)
+	if body.Data.Tags != nil || body.SuggestedTags != nil {
+		t.Errorf("tags cleared: %q, suggested %q", body.Data.Tags, body.SuggestedTags)
+	}
+
+	srv.cfg.AutoTags = config.AutoTagsApply
+	send("POST", srv.URL+"/articles", `{"title": "Go", "desc": "Concurrency", "content": "`+content+`"}`)
+	if len(body.Data.Tags) == 0 || body.Data.Tags[0] != "goroutine" { // as often as goroutines
+		t.Errorf("applied: %q", body.Data.Tags)
+	}
+	send("POST", srv.URL+"/articles", `{"title": "Go", "desc": "Concurrency", "content": "`+content+`", "tags": ["mine"]}`)
+	if !slices.Equal(body.Data.Tags, []string{"mine"}) || len(body.SuggestedTags) == 0 {
+		t.Errorf("tags given: %q, suggested %q", body.Data.Tags, body.SuggestedTags)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	srv := newTestServer(t, 0)
+	var dogs, markets model.Article
+	call(t, "POST", srv.URL+"/articles", `{"title": "Next door", "desc": "Neighbours", "content": "My puppy and the dog next door play all day.", "tags": ["dogs"]}`, &dogs)
+	call(t, "POST", srv.URL+"/articles", `{"title": "Markets", "desc": "A bad day", "content": "The stock market fell, and shares in the bank with it. The dog days are here."}`, &markets)
+
+	search := func(query string) ([]SearchResult, int) {
+		t.Helper()
+		var results []SearchResult
+		resp := call(t, "GET", srv.URL+"/articles/search?"+query, "", &results)
+		return results, resp.StatusCode
+	}
+	results, _ := search("q=dog")
+	if len(results) != 2 || results[0].Article.ID != dogs.ID || results[0].Score <= results[1].Score {
+		t.Errorf("dog: %+v", results) // the tag and the content of the first
+	}
+	if results, _ := search("q=dog+stock"); len(results) != 1 || results[0].Article.ID != markets.ID {
+		t.Errorf("every word: %+v", results)
+	}
+	if results, _ := search("q=kitten"); len(results) != 0 {
+		t.Errorf("no match: %+v", results)
+	}
+	for _, query := range []string{"", "q=dog&mode=fuzzy", "q=dog&limit=0", "q=kitten&mode=semantic"} {
+		if _, status := search(query); status != http.StatusBadRequest {
+			t.Errorf("%q: %d", query, status)
+		}
+	}
+
+	// Vectors of how much a text is about pets, money and weather
+	concepts := [][]string{{"cat", "kitten", "dog", "puppy", "pet"}, {"stock", "market", "shares", "bank", "money"}, {"rain", "storm", "sun", "weather"}}
+	fail := false
+	srv.AddPlugin(Plugin{Name: "concepts", Embedder: embeddings.EmbedderFunc(func(ctx context.Context, texts []string) ([][]float32, error) {
+		if fail {
+			return nil, errors.New("quota exceeded")
+		}
+		vectors := make([][]float32, len(texts))
+		for i, text := range texts {
+			vectors[i] = make([]float32, len(concepts))
+			for _, word := range searchWords(text) {
+				for c, words := range concepts {
+					if slices.Contains(words, word) {
+						vectors[i][c]++
+					}
+				}
+			}
+		}
+		return vectors, nil
+	})})
+	if err := srv.embedArticles(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	results, _ = search("q=kitten&mode=semantic")
+	if len(results) != 2 || results[0].Article.ID != dogs.ID || results[0].Score < 0.99 {
+		t.Errorf("semantic: %+v", results)
+	}
+
+	// A change makes the embedding again
+	before, _ := srv.readArticle(markets.ID)
+	call(t, "PUT", fmt.Sprintf("%s/articles/%d", srv.URL, markets.ID), `{"content": "Rain and a storm all week."}`, nil)
+	srv.embedArticles(t.Context())
+	if after, _ := srv.readArticle(markets.ID); after.Embedding == nil || after.Embedding.Source == before.Embedding.Source || after.Embedding.Vector[2] != 2 {
+		t.Errorf("embedding after a change: %+v", after.Embedding)
+	}
+	if results, _ := search("q=weather&mode=semantic&limit=1"); len(results) != 1 || results[0].Article.ID != markets.ID {
+		t.Errorf("after a change: %+v", results)
+	}
+
+	fail = true
+	if _, status := search("q=kitten&mode=semantic"); status != http.StatusBadGateway {
+		t.Errorf("failing embedder: %d", status)
+	}
+}
+
+func TestSearchCorrection(t *testing.T) {
+	srv := newTestServer(t, 0)
+	var goroutines model.Article
+	call(t, "POST", srv.URL+"/articles", `{"title": "Goroutines", "desc": "Concurrency in Go", "content": "Goroutines are cheap. Start goroutines for concurrent work."}`, &goroutines)
+	call(t, "POST", srv.URL+"/articles", `{"title": "Channels", "desc": "Concurrency in Go", "content": "Channels connect goroutines."}`, nil)
+
+	search := func(q string) ([]SearchResult, string) {
+		t.Helper()
+		var body struct {
+			Data       []SearchResult `json:"data"`
+			DidYouMean string         `json:"did_you_mean"`
+		}
+		resp, err := http.Get(srv.URL + "/articles/search?q=" + strings.ReplaceAll(q, " ", "+"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		json.NewDecoder(resp.Body).Decode(&body)
+		return body.Data, body.DidYouMean
+	}
+	if results, suggestion := search("goroutnes"); suggestion != "goroutines" || len(results) != 2 {
+		t.Errorf("no results: %q, %+v", suggestion, results)
+	}
+	if results, suggestion := search("goroutines chanels"); suggestion != "goroutines channels" || len(results) != 1 || results[0].Article.Title != "Channels" {
+		t.Errorf("a word misspelt: %q, %+v", suggestion, results)
+	}
+	if _, suggestion := search("gorout"); suggestion != "" {
+		t.Errorf("a prefix: %q", suggestion)
+	}
+	if results, suggestion := search("kubernetes"); suggestion != "" || len(results) != 0 {
+		t.Errorf("nothing close: %q, %+v", suggestion, results)
+	}
+}
+
+func TestSearchAnalytics(t *testing.T) {
+	srv := newTestServer(t, 0)
+	call(t, "POST", srv.URL+"/articles", `{"title": "Raft", "desc": "Consensus", "content": "Raft elects a leader."}`, nil)
+	for _, q := range []string{"raft", "Raft", "raft+leader", "kubernetes", "KUBERNETES", "wasm", "raft&limit=0"} {
+		call(t, "GET", srv.URL+"/articles/search?q="+q, "", nil)
+	}
+
+	var result SearchAnalytics
+	if resp := call(t, "GET", srv.URL+"/admin/search-analytics?limit=2", "", &result); resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	// The invalid request isn't counted
+	if result.Searches != 6 || result.ZeroResultSearches != 3 {
+		t.Errorf("%d searches, %d with no results", result.Searches, result.ZeroResultSearches)
+	}
+	if top := result.TopQueries; len(top) != 2 || top[0].Query != "kubernetes" || top[0].Searches != 2 || top[1].Query != "raft" || top[1].Hits != 1 {
+		t.Errorf("top queries %+v", top)
+	}
+	if zero := result.TopZeroResultQueries; len(zero) != 2 || zero[0].Query != "kubernetes" || zero[0].ZeroResultSearches != 2 || zero[1].Query != "wasm" {
+		t.Errorf("top zero-result queries %+v", zero)
+	}
+
+	if call(t, "GET", srv.URL+"/admin/search-analytics?to=2000-01-01", "", &result); result.Searches != 0 || len(result.TopQueries) != 0 {
+		t.Errorf("before any search: %+v", result)
+	}
+	for _, query := range []string{"limit=101", "from=yesterday", "from=2026-02-01&to=2026-01-01"} {
+		if resp := call(t, "GET", srv.URL+"/admin/search-analytics?"+query, "", nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status %d", query, resp.StatusCode)
+		}
+	}
+}
+
+func TestSavedSearches(t *testing.T) {
+	verifier := client.NewWebhookVerifier("0123456789abcdef")
+	var mu sync.Mutex
+	var received []client.WebhookDelivery
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivery, err := verifier.Read(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received = append(received, delivery)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	srv := newTestServer(t, 0)
+	srv.searchAlertClient = hook.Client() // the test server is on loopback
+	recorder := &recordingMailer{}
+	templates, err := notify.LoadTemplates("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.mailer, srv.mailTemplates = recorder, templates
+	if err := srv.StartBackground(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.StopBackground(context.Background()) })
+	srv.AddUser("bob", "battery staple", model.RoleEditor)
+	bob := strings.Replace(srv.URL, "http://", "http://bob:battery%20staple@", 1)
+	alerts := func() []notify.Message { // bob hears of publications too
+		return slices.DeleteFunc(recorder.messages(), func(m notify.Message) bool { return !strings.HasPrefix(m.Subject, "New articles") })
+	}
+
+	var old model.Article
+	call(t, "POST", srv.URL+"/articles", `{"title": "Raft basics", "desc": "Consensus", "content": "Leaders and terms."}`, &old)
+
+	search := `{"name": "Consensus", "query": "raft", "email": true, "webhook_url": "` + hook.URL + `", "webhook_secret": "0123456789abcdef"}`
+	if resp := call(t, "POST", srv.URL+"/account/searches", search, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("without credentials: status %d", resp.StatusCode)
+	}
+	if resp := call(t, "POST", bob+"/account/searches", search, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("email alerts without an address: status %d", resp.StatusCode)
+	}
+	call(t, "PUT", bob+"/account/notifications", `{"email": "bob@example.com"}`, nil)
+	for _, invalid := range []string{`{"name": "x", "query": "?!"}`, `{"name": "x", "query": "raft", "webhook_url": "` + hook.URL + `", "webhook_secret": "short"}`} {
+		if resp := call(t, "POST", bob+"/account/searches", invalid, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status %d", invalid, resp.StatusCode)
+		}
+	}
+	var saved model.SavedSearch
+	if resp := call(t, "POST", bob+"/account/searches", search, &saved); resp.StatusCode != http.StatusCreated || saved.ID != 1 || saved.WebhookURL != hook.URL {
+		t.Fatalf("create: status %d, %+v", resp.StatusCode, saved)
+	}
+
+	// Only the articles published since the search was saved are new to it
+	var practice model.Article
+	call(t, "POST", srv.URL+"/articles", `{"title": "Raft in practice", "desc": "Consensus", "content": "Running it."}`, &practice)
+	call(t, "POST", srv.URL+"/articles", `{"title": "Raft internals", "desc": "Consensus", "content": "Not yet.", "status": "draft"}`, nil)
+	call(t, "POST", srv.URL+"/articles", `{"title": "Paxos", "desc": "Consensus", "content": "The other one."}`, nil)
+	call(t, "PUT", fmt.Sprintf("%s/articles/%d", srv.URL, old.ID), `{"content": "Leaders, terms and logs."}`, nil)
+	srv.checkSavedSearches(time.Now())
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n > 0 && len(alerts()) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d deliveries, %d emails", n, len(alerts()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	sent := alerts()
+	if len(sent) != 1 || sent[0].To != "bob@example.com" || sent[0].Subject != `New articles for "Consensus"` ||
+		!strings.Contains(sent[0].Body, "Raft in practice") || strings.Contains(sent[0].Body, "Raft basics") {
+		t.Errorf("sent %+v", sent)
+	}
+	mu.Lock()
+	var alert struct {
+		Search   model.SavedSearch `json:"search"`
+		Articles []model.Article   `json:"articles"`
+	}
+	json.Unmarshal(received[0].Data, &alert)
+	if len(received) != 1 || received[0].Event != notify.EventSearchMatched || alert.Search.Name != "Consensus" ||
+		len(alert.Articles) != 1 || alert.Articles[0].ID != practice.ID || alert.Articles[0].Content != "" {
+		t.Errorf("received %+v: %+v", received, alert)
+	}
+	mu.Unlock()
+
+	// A change doesn't alert about an article again
+	call(t, "PUT", fmt.Sprintf("%s/articles/%d", srv.URL, practice.ID), `{"content": "Running it well."}`, nil)
+	srv.checkSavedSearches(time.Now())
+	time.Sleep(50 * time.Millisecond)
+	if n := len(alerts()); n != 1 {
+		t.Errorf("%d emails after a change", n)
+	}
+
+	var results []SearchResult
+	if call(t, "GET", bob+"/account/searches/1/results", "", &results); len(results) != 3 {
+		t.Errorf("results %+v", results)
+	}
+	var updated model.SavedSearch
+	if resp := call(t, "PUT", bob+"/account/searches/1", `{"name": "Raft", "query": "raft", "webhook_url": "`+hook.URL+`"}`, &updated); resp.StatusCode != http.StatusOK ||
+		updated.Name != "Raft" || updated.Email || updated.WebhookURL != hook.URL {
+		t.Errorf("update keeping the secret: status %d, %+v", resp.StatusCode, updated)
+	}
+	var list []model.SavedSearch
+	if call(t, "GET", bob+"/account/searches", "", &list); len(list) != 1 || list[0].Name != "Raft" {
+		t.Errorf("list %+v", list)
+	}
+	if resp := call(t, "DELETE", bob+"/account/searches/1", "", nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("delete: status %d", resp.StatusCode)
+	}
+	if resp := call(t, "GET", bob+"/account/searches/1/results", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("deleted: status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"go-spring/internal/model"
+	"go-spring/internal/store"
+)
+
+type attachmentsState struct {
+	// Attachment files live in the blob store; metadata is kept on the article
+	blobStore store.BlobStore
+
+	attachmentIDs store.Sequence
+}
+
+// Parse the {id} and {attachmentId} route variables
+func (app *App) attachmentRouteIDs(r *http.Request) (articleID, attachmentID int, err error) {
+	if articleID, err = app.articleRouteID(r); err != nil {
+		return 0, 0, errors.New("Invalid article ID")
+	}
+	if v, ok := mux.Vars(r)["attachmentId"]; ok {
+		if attachmentID, err = strconv.Atoi(v); err != nil {
+			return 0, 0, errors.New("Invalid attachment ID")
+		}
+	}
+	return articleID, attachmentID, nil
+}
+
+// Find an article index by ID; caller must hold articlesMutex
+func (app *App) findArticleIndex(id int) int {
+	for i, article := range app.articles {
+		if article.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Remove attachment files and their cached variants from the blob store,
+// once they have been removed from articles; a file other attachments
+// share stays, see dedup.go. Callers hold articlesMutex, so each delete
+// gets STORE_TIMEOUT at most; a blob left behind is only logged.
+func (app *App) deleteAttachmentBlobs(list []model.Attachment) {
+	refs := app.blobReferences()
+	for _, attachment := range list {
+		keys := attachment.Variants
+		if refs[attachment.Key] == 0 {
+			keys = append([]string{attachment.Key}, keys...)
+		}
+		for _, key := range keys {
+			if err := app.deleteBlob(context.Background(), key); err != nil {
+				log.Printf("Warning: Failed to delete attachment blob %s: %v", key, err)
+			}
+		}
+	}
+}
+
+// attachmentError carries the HTTP status an attachment failure maps to
+type attachmentError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *attachmentError) Error() string {
+	return e.Message
+}
+
+func (app *App) writeAttachmentError(w http.ResponseWriter, r *http.Request, err error) {
+	var attErr *attachmentError
+	if errors.As(err, &attErr) {
+		app.writeError(w, r, attErr.Status, attErr.Code, attErr.Message)
+		return
+	}
+	app.writeStoreError(w, r, err, "Failed to store attachment")
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Validate and store a file as a new attachment of an article. The type is
+// detected from the content rather than trusted from the client. If onStored
+// is set it is called under the write lock to update the article further.
+func (app *App) storeAttachment(ctx context.Context, articleID int, filename string, src io.Reader, allowedTypes []string, onStored func(*model.Article, model.Attachment)) (model.Attachment, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return model.Attachment{}, &attachmentError{http.StatusBadRequest, CodeInvalidFile, "Failed to read upload"}
+	}
+	head = head[:n]
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if !slices.Contains(allowedTypes, contentType) || !slices.Contains(app.cfg.AttachmentTypes, contentType) {
+		return model.Attachment{}, &attachmentError{http.StatusUnsupportedMediaType, CodeUnsupportedType, fmt.Sprintf("File type %s is not allowed", contentType)}
+	}
+
+	// Spooled to a file to hash it, which names the blob
+	spool, err := os.CreateTemp("", "attachment-*")
+	if err != nil {
+		return model.Attachment{}, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	sum := sha256.New()
+	// Read at most one byte past the limit so oversized files are detected
+	body := io.LimitReader(io.MultiReader(bytes.NewReader(head), src), app.cfg.AttachmentMaxBytes+1)
+	size, err := io.Copy(io.MultiWriter(spool, sum), body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return model.Attachment{}, &attachmentError{http.StatusRequestEntityTooLarge, CodeFileTooLarge, "File too large"}
+		}
+		return model.Attachment{}, err
+	}
+	if size > app.cfg.AttachmentMaxBytes {
+		return model.Attachment{}, &attachmentError{http.StatusRequestEntityTooLarge, CodeFileTooLarge, "File too large"}
+	}
+
+	attachment := model.Attachment{
+		ID:          app.attachmentIDs.Next(),
+		Filename:    cleanFilename(filename),
+		ContentType: contentType,
+		Size:        size,
+		Key:         contentBlobKey(sum),
+		Created:     time.Now(),
+	}
+
+	// Stored unless an attachment has it already; pending keeps it from
+	// being deleted until the attachment is recorded
+	app.articlesMutex.Lock()
+	exists := app.attachmentBlobExists(attachment.Key)
+	app.pendingBlobs[attachment.Key]++
+	app.articlesMutex.Unlock()
+	if !exists {
+		if _, err = spool.Seek(0, io.SeekStart); err == nil {
+			err = app.putBlob(ctx, attachment.Key, spool)
+		}
+	}
+
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+	if app.pendingBlobs[attachment.Key]--; app.pendingBlobs[attachment.Key] == 0 {
+		delete(app.pendingBlobs, attachment.Key)
+	}
+	if err != nil {
+		return model.Attachment{}, err
+	}
+
+	// The article may have been deleted while the file was being stored
+	i := app.findArticleIndex(articleID)
+	if i < 0 {
+		app.releaseBlob(ctx, attachment.Key)
+		return model.Attachment{}, &attachmentError{http.StatusNotFound, CodeArticleNotFound, "Article not found"}
+	}
+	if err := app.checkStorageQuota(i, attachment.Size); err != nil {
+		app.releaseBlob(ctx, attachment.Key)
+		return model.Attachment{}, err
+	}
+	app.articles[i].Attachments = append(app.articles[i].Attachments, attachment)
+	if onStored != nil {
+		onStored(&app.articles[i], attachment)
+	}
+	app.publishArticleEvent(ctx, EventArticleUpdated, app.articles[i])
+
+	// Save to file
+	app.scheduleSave()
+
+	return attachment, nil
+}
+
+// GET /articles/{id}/attachments - List an article's attachments
+func (app *App) getAttachments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, _, err := app.attachmentRouteIDs(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, err.Error())
+		return
+	}
+
+	app.articlesMutex.RLock()
+	defer app.articlesMutex.RUnlock()
+
+	i := app.findArticleIndex(id)
+	if i < 0 {
+		app.writeError(w, r, http.StatusNotFound, CodeArticleNotFound, "Article not found")
+		return
+	}
+
+	list := app.articles[i].Attachments
+	if list == nil {
+		list = []model.Attachment{}
+	}
+	response := Response{
+		Message: "Attachments retrieved successfully",
+		Data:    list,
+	}
+	app.writeResponse(w, r, http.StatusOK, response)
+}
+
+// POST /articles/{id}/attachments - Upload a file (multipart field "file");
+// ?private=true keeps it from anyone without access or a signed URL
+func (app *App) uploadAttachment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, _, err := app.attachmentRouteIDs(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, err.Error())
+		return
+	}
+	private, err := strconv.ParseBool(r.URL.Query().Get("private"))
+	if err != nil && r.URL.Query().Has("private") {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "private must be true or false")
+		return
+	}
+
+	app.articlesMutex.RLock()
+	exists := app.findArticleIndex(id) >= 0
+	app.articlesMutex.RUnlock()
+	if !exists {
+		app.writeError(w, r, http.StatusNotFound, CodeArticleNotFound, "Article not found")
+		return
+	}
+
+	// Leave some room for the multipart envelope around the file itself
+	r.Body = http.MaxBytesReader(w, r.Body, app.cfg.AttachmentMaxBytes+1<<20)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			app.writeError(w, r, http.StatusRequestEntityTooLarge, CodeFileTooLarge, "File too large")
+			return
+		}
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidFile, "Missing file upload (multipart field \"file\")")
+		return
+	}
+	defer file.Close()
+
+	var onStored func(*model.Article, model.Attachment)
+	if private {
+		onStored = func(article *model.Article, _ model.Attachment) {
+			article.Attachments[len(article.Attachments)-1].Private = true
+		}
+	}
+	attachment, err := app.storeAttachment(r.Context(), id, header.Filename, file, app.cfg.AttachmentTypes, onStored)
+	if err != nil {
+		app.writeAttachmentError(w, r, err)
+		return
+	}
+	attachment.Private = private
+	app.enqueueThumbnails(id, attachment)
+
+	response := Response{
+		Message: "Attachment uploaded successfully",
+		Data:    attachment,
+	}
+
+	app.writeResponse(w, r, http.StatusCreated, response)
+}
+
+// GET /articles/{id}/attachments/{attachmentId} - Download an attachment, or
+// the range of it a Range header asks for
+func (app *App) serveAttachment(w http.ResponseWriter, r *http.Request) {
+	id, attachmentID, err := app.attachmentRouteIDs(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, err.Error())
+		return
+	}
+
+	attachment, ok := app.findAttachment(id, attachmentID)
+	if !ok {
+		app.writeError(w, r, http.StatusNotFound, CodeAttachmentNotFound, "Attachment not found")
+		return
+	}
+	if !app.allowAttachment(w, r, id, attachment) {
+		return
+	}
+
+	app.writeAttachment(w, r, attachment)
+}
+
+// Stream a blob with download headers
+func (app *App) writeBlob(w http.ResponseWriter, r *http.Request, key, contentType, filename string) {
+	blob, err := app.getBlob(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, store.ErrBlobNotFound) {
+			app.writeError(w, r, http.StatusNotFound, CodeAttachmentNotFound, "Attachment not found")
+			return
+		}
+		app.writeStoreError(w, r, fmt.Errorf("blob %s: %w", key, err), "Failed to read attachment")
+		return
+	}
+	defer blob.Close()
+
+	setDownloadHeaders(w, contentType, filename)
+	io.Copy(w, blob)
+}
+
+func setDownloadHeaders(w http.ResponseWriter, contentType, filename string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+}
+
+// DELETE /articles/{id}/attachments/{attachmentId} - Delete an attachment
+func (app *App) deleteAttachment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, attachmentID, err := app.attachmentRouteIDs(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, err.Error())
+		return
+	}
+
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+
+	i := app.findArticleIndex(id)
+	if i < 0 {
+		app.writeError(w, r, http.StatusNotFound, CodeArticleNotFound, "Article not found")
+		return
+	}
+
+	for j, attachment := range app.articles[i].Attachments {
+		if attachment.ID == attachmentID {
+			// Readers may share the attachments, see readArticles
+			app.articles[i].Attachments = slices.Delete(slices.Clone(app.articles[i].Attachments), j, j+1)
+			app.deleteAttachmentBlobs([]model.Attachment{attachment})
+			if cover := app.articles[i].CoverImage; cover != nil && cover.AttachmentID == attachmentID {
+				app.articles[i].CoverImage = nil
+			}
+			app.publishArticleEvent(r.Context(), EventArticleUpdated, app.articles[i])
+
+			// Save to file
+			app.scheduleSave()
+
+			response := Response{
+				Message: "Attachment deleted successfully",
+			}
+			app.writeResponse(w, r, http.StatusOK, response)
+			return
+		}
+	}
+
+	app.writeError(w, r, http.StatusNotFound, CodeAttachmentNotFound, "Attachment not found")
+}
+
+// Look up an attachment's metadata
+func (app *App) findAttachment(articleID, attachmentID int) (model.Attachment, bool) {
+	app.articlesMutex.RLock()
+	defer app.articlesMutex.RUnlock()
+
+	i := app.findArticleIndex(articleID)
+	if i < 0 {
+		return model.Attachment{}, false
+	}
+	for _, attachment := range app.articles[i].Attachments {
+		if attachment.ID == attachmentID {
+			return attachment, true
+		}
+	}
+	return model.Attachment{}, false
+}
+
+// Keep only the base name of an uploaded file, without control characters
+func cleanFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == "/" {
+		return "file"
+	}
+	return name
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go-spring/internal/config"
+	"go-spring/internal/notify"
+)
+
+type backgroundState struct {
+	// Background work of a running server: the saver, which writes changes to
+	// the data store, the job queue workers, the savers of view counts and
+	// activity, the trending ranking, the schedules of the nightly compact,
+	// retention and digest jobs, the Redis invalidation subscriber, a replica's change
+	// feed and leader election, and in the dev profile the theme reloader. The server starts it with StartBackground and
+	// stops it with StopBackground. Commands that use the handlers without a
+	// server call Save instead.
+	backgroundMutex sync.Mutex
+	saveRequests    chan struct{} // nil while the saver is not running
+	backgroundStop  context.CancelFunc
+	backgroundDone  sync.WaitGroup
+}
+
+// StartBackground starts the saver, the job workers, with the Redis
+// response cache the subscriber to invalidations from other replicas, with
+// REPLICA_OF the follower of the primary's change feed, and with
+// ELECTION_URL the campaign for the lease of the primary along with it. They
+// run until StopBackground; ctx is not used for their lifetime.
+func (app *App) StartBackground(ctx context.Context) error {
+	app.backgroundMutex.Lock()
+	defer app.backgroundMutex.Unlock()
+	if app.saveRequests != nil {
+		return errors.New("background work already started")
+	}
+
+	if app.jobQueue != nil {
+		if err := app.jobQueue.Start(ctx); err != nil {
+			return err
+		}
+	}
+	requests := make(chan struct{}, 1)
+	app.saveRequests = requests
+	app.backgroundDone.Go(func() { app.runSaver(requests) })
+
+	bgCtx, cancel := context.WithCancel(context.Background())
+	app.backgroundStop = cancel
+	if app.viewCounter != nil {
+		app.backgroundDone.Go(func() { app.runAnalyticsSaver(bgCtx) })
+		if app.cfg.TrendingInterval > 0 {
+			app.backgroundDone.Go(func() { app.runTrending(bgCtx) })
+		}
+	}
+	if at, err := time.Parse("15:04", app.cfg.CompactAt); err == nil && app.jobQueue != nil {
+		app.backgroundDone.Go(func() { runNightly(bgCtx, at, app.queueCompact) })
+	}
+	if at, err := time.Parse("15:04", app.cfg.RetentionAt); err == nil && app.jobQueue != nil && app.retentionRules() {
+		app.backgroundDone.Go(func() { runNightly(bgCtx, at, func() { app.enqueueJob(jobRetention, retentionJob{}) }) })
+	}
+	if at, err := time.Parse("15:04", app.cfg.DigestAt); err == nil && app.jobQueue != nil && app.mailer != nil {
+		app.backgroundDone.Go(func() { runNightly(bgCtx, at, func() { app.enqueueJob(jobDigest, struct{}{}) }) })
+	}
+	if app.activityLog != nil {
+		app.backgroundDone.Go(func() { app.runActivitySaver(bgCtx) })
+	}
+	if app.searchLog != nil {
+		app.backgroundDone.Go(func() { app.runSearchLogSaver(bgCtx) })
+	}
+	if app.cfg.SavedSearchInterval > 0 {
+		app.backgroundDone.Go(func() { app.runSearchAlerts(bgCtx) })
+	}
+	if cache, ok := app.responseCache.(*redisCache); ok {
+		app.backgroundDone.Go(func() {
+			cache.subscribe(bgCtx, func() {
+				app.cacheGeneration.Add(1) // responses being rendered may be stale
+			})
+		})
+	}
+	if app.electing() {
+		app.backgroundDone.Go(func() { app.runElection(bgCtx) })
+	}
+	if app.cfg.ReplicaOf != "" || app.electing() {
+		app.backgroundDone.Go(func() { app.runReplica(bgCtx) })
+	}
+	if embedder, _ := app.activeEmbedder(); embedder != nil {
+		app.backgroundDone.Go(func() { app.runEmbedder(bgCtx) })
+	}
+	if app.cfg.Profile == config.ProfileDev && app.cfg.Blog && app.cfg.ThemeDir != "" {
+		app.backgroundDone.Go(func() { app.runThemeReloader(bgCtx) })
+	}
+	return nil
+}
+
+// StopBackground stops the job workers and the subscriber, then waits for
+// the saver to write the last changes, or for ctx to be done. Jobs that were interrupted run again after the next start.
+func (app *App) StopBackground(ctx context.Context) error {
+	app.backgroundMutex.Lock()
+	if app.saveRequests == nil {
+		app.backgroundMutex.Unlock()
+		return nil
+	}
+	app.backgroundMutex.Unlock()
+	// Jobs change articles, so the workers stop before the saver
+	var err error
+	if app.jobQueue != nil {
+		err = app.jobQueue.Stop(ctx)
+	}
+
+	app.backgroundMutex.Lock()
+	close(app.saveRequests)
+	app.saveRequests = nil
+	app.backgroundStop()
+	app.backgroundMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		app.backgroundDone.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		return errors.Join(err, ctx.Err())
+	}
+}
+
+// Ask the saver to write the articles. Writers call this while holding
+// articlesMutex, so the save happens after they unlock; changes made while
+// a save is running are written by the next one.
+func (app *App) scheduleSave() {
+	app.backgroundMutex.Lock()
+	defer app.backgroundMutex.Unlock()
+	select {
+	case app.saveRequests <- struct{}{}:
+	default: // a save is already pending, or the saver is not running
+	}
+}
+
+// Write the store once per request until the channel is closed. The first
+// failure after a success is announced, the ones after it only logged.
+func (app *App) runSaver(requests <-chan struct{}) {
+	failing := false
+	for range requests {
+		err := app.saveArticles()
+		if err != nil {
+			log.Printf("Warning: Failed to save articles: %v", err)
+			if !failing {
+				app.Notify(context.Background(), notify.Notification{
+					Event: notify.EventStoreFailed, Error: err.Error(), Detail: app.redactedStore(),
+				})
+			}
+		}
+		failing = err != nil
+	}
+}
+
+// STORE without the password of a database URL
+func (app *App) redactedStore() string {
+	return redactedURL(app.cfg.Store)
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"go-spring/internal/config"
+	"go-spring/internal/model"
+)
+
+// Public HTML pages (BLOG=true), so a small site needs no frontend of its
+// own: /blog lists the published articles, newest first and FEED_COUNT to
+// a page, and /blog/{slug} shows one. The pages go through html/template,
+// which escapes everything but the article HTML the Markdown renderer made.
+// They are public like the feeds, so confidential articles are left out
+// once there are users, and they stay in the response cache until an
+// article changes.
+//
+// A theme brands the pages: the files of THEME_DIR take the place of the
+// embedded ones of the same name, templates and assets alike, and files
+// the embedded theme lacks are served as assets too. In the dev profile
+// the templates are parsed again when a file of the theme changes.
+
+// Blog templates and assets, compiled into the binary
+//
+//go:embed static/blog
+var blogAssets embed.FS
+
+func blogEnabled(cfg config.Config) bool { return cfg.Blog }
+
+// The pages of the blog, each layout.html with the page's own templates
+var blogPages = []string{"index.html", "article.html", "error.html"}
+
+type blogState struct {
+	// The files of the theme, and its parsed pages; set by initBlog
+	blogTheme     fs.FS
+	blogTemplates atomic.Pointer[map[string]*template.Template]
+}
+
+// Parse the templates of the theme, the embedded one with THEME_DIR over it
+func (app *App) initBlog(cfg config.Config) error {
+	embedded, _ := fs.Sub(blogAssets, "static/blog")
+	app.blogTheme = embedded
+	if cfg.ThemeDir != "" {
+		if _, err := os.Stat(cfg.ThemeDir); err != nil {
+			return err
+		}
+		app.blogTheme = overlayFS{top: os.DirFS(cfg.ThemeDir), base: embedded}
+	}
+	pages, err := parseBlogTemplates(app.blogTheme)
+	if err != nil {
+		return err
+	}
+	app.blogTemplates.Store(&pages)
+	return nil
+}
+
+func parseBlogTemplates(fsys fs.FS) (map[string]*template.Template, error) {
+	pages := map[string]*template.Template{}
+	for _, page := range blogPages {
+		t, err := template.ParseFS(fsys, "layout.html", page)
+		if err != nil {
+			return nil, err
+		}
+		pages[page] = t
+	}
+	return pages, nil
+}
+
+// overlayFS reads a file from top if it is there, else from base
+type overlayFS struct{ top, base fs.FS }
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if f, err := o.top.Open(name); err == nil {
+		return f, nil
+	}
+	return o.base.Open(name)
+}
+
+// Parse the templates again every second that a file of THEME_DIR has
+// changed, until ctx is done; the dev profile's hot reload
+func (app *App) runThemeReloader(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	last := themeStamp(app.cfg.ThemeDir)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if stamp := themeStamp(app.cfg.ThemeDir); stamp != last {
+			last = stamp
+			app.reloadBlogTemplates()
+		}
+	}
+}
+
+// The number of files in dir and the latest time one was changed, which
+// differ once a file is added, removed or written
+func themeStamp(dir string) string {
+	files, latest := 0, time.Time{}
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			files++
+			if info.ModTime().After(latest) {
+				latest = info.ModTime()
+			}
+		}
+		return nil
+	})
+	return fmt.Sprintf("%d %d", files, latest.UnixNano())
+}
+
+// Use the theme's templates as they are now, and drop the pages cached
+// with the old ones; a template that doesn't parse keeps the old ones
+func (app *App) reloadBlogTemplates() error {
+	pages, err := parseBlogTemplates(app.blogTheme)
+	if err != nil {
+		log.Printf("Theme %s: %v", app.cfg.ThemeDir, err)
+		return err
+	}
+	app.blogTemplates.Store(&pages)
+	if app.responseCache != nil {
+		app.invalidateResponseCache()
+	}
+	log.Printf("Reloaded theme %s", app.cfg.ThemeDir)
+	return nil
+}
+
+// What the templates get
+type blogPage struct {
+	Site     blogSite
+	Articles []blogArticle // of the index page
+	Article  *blogArticle  // of an article page
+
+	PrevPage, NextPage int // of the index, 0 if there is none
+	Error              string
+}
+
+type blogSite struct {
+	Title, Description, Language string
+}
+
+type blogArticle struct {
+	Title, Desc, Slug string
+	Published         time.Time
+	CoverURL          string
+	Content           template.HTML // only on the article's own page
+}
+
+func newBlogArticle(article model.Article) blogArticle {
+	a := blogArticle{Title: article.Title, Desc: article.Desc, Slug: article.Slug, Published: publishedAt(article)}
+	if article.CoverImage != nil {
+		a.CoverURL = article.CoverImage.URL
+	}
+	return a
+}
+
+// GET /blog?page=N - HTML page of the latest published articles
+func (app *App) getBlogIndex(w http.ResponseWriter, r *http.Request) {
+	list := app.publishedArticles("")
+	page := 1
+	if v := r.URL.Query().Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			app.writeBlogError(w, r, http.StatusBadRequest, "Invalid page")
+			return
+		}
+		page = n
+	}
+	size := app.cfg.FeedCount
+	pages := max((len(list)+size-1)/size, 1)
+	if page > pages {
+		app.writeBlogError(w, r, http.StatusNotFound, "Page not found")
+		return
+	}
+	start := (page - 1) * size
+
+	data := blogPage{Site: app.blogSiteOf(), Articles: []blogArticle{}}
+	for _, article := range list[start:min(start+size, len(list))] {
+		data.Articles = append(data.Articles, newBlogArticle(article))
+	}
+	if page > 1 {
+		data.PrevPage = page - 1
+	}
+	if page < pages {
+		data.NextPage = page + 1
+	}
+	app.writeBlogPage(w, http.StatusOK, "index.html", data)
+}
+
+// GET /blog/{slug} - HTML page of a published article
+func (app *App) getBlogArticle(w http.ResponseWriter, r *http.Request) {
+	article, ok := app.findArticleBySlug(mux.Vars(r)["slug"])
+	if !ok || !article.IsPublished() || article.Confidential && app.hasUsers() {
+		app.writeBlogError(w, r, http.StatusNotFound, "Article not found")
+		return
+	}
+	a := newBlogArticle(article)
+	a.Content = template.HTML(app.renderArticleContent(article)) // the renderer escapes raw HTML
+	app.writeBlogPage(w, http.StatusOK, "article.html", blogPage{Site: app.blogSiteOf(), Article: &a})
+}
+
+// GET /blog/assets/{asset} - Stylesheet and other files of the theme
+func (app *App) serveBlogAsset(w http.ResponseWriter, r *http.Request) {
+	asset := mux.Vars(r)["asset"]
+	if path.Ext(asset) == ".html" {
+		http.NotFound(w, r) // templates aren't assets
+		return
+	}
+	serveAsset(w, r, app.blogTheme, ".", asset)
+}
+
+// The blog is titled as the feed is
+func (app *App) blogSiteOf() blogSite {
+	return blogSite{Title: app.cfg.FeedTitle, Description: app.cfg.FeedDescription, Language: app.cfg.FeedLanguage}
+}
+
+func (app *App) writeBlogError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	app.writeBlogPage(w, status, "error.html", blogPage{Site: app.blogSiteOf(), Error: message})
+}
+
+// Render a page whole before writing it, so a template error is a 500
+// rather than half a page
+func (app *App) writeBlogPage(w http.ResponseWriter, status int, page string, data blogPage) {
+	var buf bytes.Buffer
+	if err := (*app.blogTemplates.Load())[page].ExecuteTemplate(&buf, "layout", data); err != nil {
+		log.Printf("Blog page %s: %v", page, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
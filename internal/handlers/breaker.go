@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// A circuit breaker stands in front of each store. After
+// STORE_BREAKER_FAILURES failures in a row it opens: operations fail at
+// once, requests with a 503, instead of each waiting out STORE_TIMEOUT on a
+// backend that is down. After STORE_BREAKER_COOLDOWN one operation is let
+// through as a probe; if it succeeds the breaker closes again, otherwise it
+// stays open for another cooldown.
+
+// Breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open" // a probe is running
+)
+
+// breakerOpenError is how an operation fails while its store's breaker is
+// open
+type breakerOpenError struct {
+	name       string
+	retryAfter time.Duration // until the next probe, at least a second
+}
+
+func (e *breakerOpenError) Error() string {
+	return "the " + e.name + " is failing, circuit breaker open"
+}
+
+// BreakerStatus is the state of a store's breaker, in /readyz
+type BreakerStatus struct {
+	State     string    `json:"state"`
+	Failures  int       `json:"failures"` // in a row
+	LastError string    `json:"last_error,omitempty"`
+	RetryAt   time.Time `json:"retry_at,omitzero"` // when an open breaker lets a probe through
+}
+
+type circuitBreaker struct {
+	name     string        // for the log
+	limit    int           // STORE_BREAKER_FAILURES, 0 for no breaker
+	cooldown time.Duration // STORE_BREAKER_COOLDOWN
+
+	mutex     sync.Mutex
+	state     string
+	failures  int
+	lastError string
+	retryAt   time.Time
+}
+
+// The outcome of an operation the breaker let through
+type breakerResult int
+
+const (
+	breakerSuccess breakerResult = iota
+	breakerFailure
+	breakerIgnore // failed for reasons of its own, such as the client leaving
+)
+
+type breakerState struct {
+	dataBreaker *circuitBreaker
+	blobBreaker *circuitBreaker
+}
+
+// Whether an operation may go ahead, a *breakerOpenError if not; every one
+// allowed must be ended with end. An open breaker lets the first operation
+// after the cooldown through as the probe.
+func (b *circuitBreaker) allow(now time.Time) error {
+	if b.limit <= 0 {
+		return nil
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	switch {
+	case b.state == BreakerClosed:
+		return nil
+	case b.state == BreakerOpen && !now.Before(b.retryAt):
+		b.state = BreakerHalfOpen
+		log.Printf("Probing the %s", b.name)
+		return nil
+	}
+	return &breakerOpenError{name: b.name, retryAfter: max(b.retryAt.Sub(now), time.Second)}
+}
+
+func (b *circuitBreaker) end(result breakerResult, err error, now time.Time) {
+	if b.limit <= 0 {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	switch result {
+	case breakerSuccess:
+		if b.state != BreakerClosed {
+			log.Printf("The %s has recovered, circuit breaker closed", b.name)
+		}
+		b.state, b.failures, b.lastError = BreakerClosed, 0, ""
+	case breakerFailure:
+		b.failures++
+		b.lastError = err.Error()
+		if b.state == BreakerHalfOpen || b.failures >= b.limit {
+			if b.state == BreakerClosed {
+				log.Printf("Warning: The %s failed %d times in a row, circuit breaker open: %v", b.name, b.failures, err)
+			}
+			b.state, b.retryAt = BreakerOpen, now.Add(b.cooldown)
+		}
+	case breakerIgnore:
+		if b.state == BreakerHalfOpen {
+			b.state = BreakerOpen // the next operation probes
+		}
+	}
+}
+
+func (b *circuitBreaker) status() BreakerStatus {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	status := BreakerStatus{State: b.state, Failures: b.failures, LastError: b.lastError}
+	if b.state == BreakerOpen {
+		status.RetryAt = b.retryAt
+	}
+	return status
+}
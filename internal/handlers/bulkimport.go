@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"go-spring/internal/jobs"
+	"go-spring/internal/model"
+)
+
+// A decoded import record and the line it starts on
+type importRecord struct {
+	Line    int
+	Request model.CreateArticleRequest
+}
+
+// POST /admin/import - Create articles from a JSON array or NDJSON file
+//
+// The body is the file itself or a multipart upload in field "file"; the
+// NDJSON export can be fed back in as is (id and timestamps are ignored).
+// Every record is validated before anything is stored, and invalid records
+// are skipped. With ?dry_run=true nothing is stored and the report shows what
+// an import would do. With ?async=true the import runs as a job and the
+// report is its result.
+func (app *App) importArticlesJSON(w http.ResponseWriter, r *http.Request) {
+	dryRun, err := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	if err != nil && r.URL.Query().Has("dry_run") {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "dry_run must be true or false")
+		return
+	}
+	data, err := readImportFile(w, r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidFile, err.Error())
+		return
+	}
+	if wantsAsync(r) {
+		app.acceptImportJob(w, r, "json", data, dryRun)
+		return
+	}
+	report := app.ImportJSONFile(r.Context(), data, dryRun)
+	app.writeResponse(w, r, http.StatusOK, Response{Message: ImportMessage(report, "articles"), Data: report})
+}
+
+// Validate and, unless dryRun, store the records of a JSON or NDJSON file
+func (app *App) ImportJSONFile(ctx context.Context, data []byte, dryRun bool) ImportReport {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	report := ImportReport{DryRun: dryRun, Errors: []ImportError{}}
+	var records []importRecord
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		records = decodeJSONArrayRecords(data, &report)
+	} else {
+		records = decodeNDJSONRecords(data, &report)
+	}
+
+	// Validate everything first so a file is never half checked
+	valid := make([]model.Article, 0, len(records))
+	for _, rec := range records {
+		article, err := app.newArticle(ctx, rec.Request)
+		if err != nil {
+			report.Errors = append(report.Errors, ImportError{rec.Line, err.Error()})
+			report.Skipped++
+			continue
+		}
+		valid = append(valid, article)
+	}
+	slices.SortStableFunc(report.Errors, func(a, b ImportError) int { return a.Line - b.Line })
+
+	app.storeImported(ctx, &report, valid)
+	return report
+}
+
+// Count the validated articles and store them unless this is a dry run
+func (app *App) storeImported(ctx context.Context, report *ImportReport, valid []model.Article) {
+	report.Created = len(valid)
+	if report.DryRun {
+		return
+	}
+	for i, article := range valid {
+		report.IDs = append(report.IDs, model.ArticleID(app.insertArticle(ctx, article).ID))
+		jobs.ReportProgress(ctx, i+1, len(valid))
+	}
+	app.notifyImported(ctx, *report)
+}
+
+// Summary line for an import response
+func ImportMessage(report ImportReport, noun string) string {
+	if report.DryRun {
+		return fmt.Sprintf("Dry run: would import %d %s, skip %d", report.Created, noun, report.Skipped)
+	}
+	return fmt.Sprintf("Imported %d %s, skipped %d", report.Created, noun, report.Skipped)
+}
+
+// Decode one record per non-blank line
+func decodeNDJSONRecords(data []byte, report *ImportReport) []importRecord {
+	var records []importRecord
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var req model.CreateArticleRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			report.Errors = append(report.Errors, ImportError{i + 1, importJSONError(err)})
+			report.Skipped++
+			continue
+		}
+		records = append(records, importRecord{i + 1, req})
+	}
+	return records
+}
+
+// Decode the elements of a top-level array. A syntax error ends the file,
+// since the decoder can't find the next element after it.
+func decodeJSONArrayRecords(data []byte, report *ImportReport) []importRecord {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil { // [
+		report.Errors = append(report.Errors, ImportError{1, importJSONError(err)})
+		return nil
+	}
+
+	var records []importRecord
+	for dec.More() {
+		line := lineAt(data, dec.InputOffset())
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			report.Errors = append(report.Errors, ImportError{line, importJSONError(err)})
+			report.Skipped++
+			return records
+		}
+		var req model.CreateArticleRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			report.Errors = append(report.Errors, ImportError{line, importJSONError(err)})
+			report.Skipped++
+			continue
+		}
+		records = append(records, importRecord{line, req})
+	}
+	if _, err := dec.Token(); err != nil && err != io.EOF { // ]
+		report.Errors = append(report.Errors, ImportError{lineAt(data, dec.InputOffset()), importJSONError(err)})
+	}
+	return records
+}
+
+// Line number of the next value at or after offset
+func lineAt(data []byte, offset int64) int {
+	i := int(offset)
+	for i < len(data) && bytes.IndexByte([]byte(" \t\r\n,"), data[i]) >= 0 {
+		i++
+	}
+	return bytes.Count(data[:i], []byte("\n")) + 1
+}
+
+// Describe a decoding error without the Go type names
+func importJSONError(err error) string {
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+		return fmt.Sprintf("%s: expected %s, got %s", typeErr.Field, typeErr.Type.Kind(), typeErr.Value)
+	}
+	return "Invalid JSON: " + err.Error()
+}
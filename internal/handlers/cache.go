@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-spring/internal/config"
+)
+
+// ResponseCache stores rendered GET responses of routes marked Cached.
+// Entries are dropped on every article change.
+type ResponseCache interface {
+	Get(key string) (CachedResponse, bool)
+	Set(key string, resp CachedResponse)
+	Clear()
+}
+
+// CachedResponse is a captured 200 response
+type CachedResponse struct {
+	ContentType string
+	Language    string // Content-Language, if any
+	Body        []byte
+	Expires     time.Time
+}
+
+type cacheState struct {
+	// responseCache is nil when caching is disabled (RESPONSE_CACHE_TTL=0)
+	responseCache   ResponseCache
+	cacheGeneration atomic.Uint64 // bumped on invalidation, see cacheResponses
+}
+
+// Set up the response cache and clear it whenever an article changes
+func (app *App) initResponseCache(cfg config.Config) error {
+	if cfg.ResponseCacheTTL <= 0 {
+		return nil
+	}
+	switch cfg.ResponseCacheBackend {
+	case "memory":
+		app.responseCache = newMemoryCache(cfg.ResponseCacheMaxEntries, cfg.ResponseCacheTTL)
+	case "redis":
+		cache, err := newRedisCache(cfg)
+		if err != nil {
+			return err
+		}
+		app.responseCache = cache
+	default:
+		return fmt.Errorf("unknown RESPONSE_CACHE %q (want memory or redis)", cfg.ResponseCacheBackend)
+	}
+	app.events.Listen(func(ArticleEvent) {
+		app.invalidateResponseCache()
+	})
+	return nil
+}
+
+func (app *App) invalidateResponseCache() {
+	app.cacheGeneration.Add(1)
+	app.responseCache.Clear()
+}
+
+// Serve a GET route from the response cache, filling it on a miss. The key
+// covers the path, the query parameters (in canonical order), the host (used
+// in absolute feed links), the negotiated representation and the
+// Accept-Language header as sent, since articles pick among their own
+// translations by it.
+// Requests with credentials may see confidential content and go past it.
+func (app *App) cacheResponses(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if app.responseCache == nil || r.Method != http.MethodGet || r.Header.Get("Authorization") != "" {
+			next(w, r)
+			return
+		}
+
+		key := r.Host + r.URL.Path + "?" + r.URL.Query().Encode() + "|" + negotiateCodec(r).ContentType() + "|" + r.Header.Get("Accept-Language")
+		if cached, ok := app.responseCache.Get(key); ok {
+			w.Header().Set("Content-Type", cached.ContentType)
+			if cached.Language != "" {
+				w.Header().Set("Content-Language", cached.Language)
+			}
+			w.Header().Add("Vary", "Accept")
+			w.Header().Add("Vary", "Accept-Language")
+			w.Header().Set("X-Cache", "HIT")
+			w.Write(cached.Body)
+			return
+		}
+
+		// A response rendered while an article changed may already be stale
+		generation := app.cacheGeneration.Load()
+		w.Header().Set("X-Cache", "MISS")
+		cw := &captureWriter{ResponseWriter: w, status: http.StatusOK}
+		next(cw, r)
+
+		if cw.status == http.StatusOK && app.cacheGeneration.Load() == generation {
+			app.responseCache.Set(key, CachedResponse{
+				ContentType: w.Header().Get("Content-Type"),
+				Language:    w.Header().Get("Content-Language"),
+				Body:        cw.body.Bytes(),
+			})
+		}
+	}
+}
+
+// captureWriter passes a response through while keeping a copy of the body
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (cw *captureWriter) WriteHeader(status int) {
+	cw.status = status
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	cw.body.Write(b)
+	return cw.ResponseWriter.Write(b)
+}
+
+// memoryCache is an in-process LRU cache with a fixed time to live
+type memoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	order      *list.List // front is most recently used
+	items      map[string]*list.Element
+}
+
+type memoryCacheEntry struct {
+	key  string
+	resp CachedResponse
+}
+
+func newMemoryCache(maxEntries int, ttl time.Duration) *memoryCache {
+	return &memoryCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		order:      list.New(),
+		items:      map[string]*list.Element{},
+	}
+}
+
+func (c *memoryCache) Get(key string) (CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return CachedResponse{}, false
+	}
+	entry := el.Value.(*memoryCacheEntry)
+	if time.Now().After(entry.resp.Expires) {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return CachedResponse{}, false
+	}
+	c.order.MoveToFront(el)
+	return entry.resp, true
+}
+
+func (c *memoryCache) Set(key string, resp CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if resp.Expires.IsZero() {
+		resp.Expires = time.Now().Add(c.ttl)
+	}
+	if el, ok := c.items[key]; ok {
+		el.Value.(*memoryCacheEntry).resp = resp
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&memoryCacheEntry{key, resp})
+
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*memoryCacheEntry).key)
+	}
+}
+
+func (c *memoryCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	clear(c.items)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// Resolve the Cache-Control policy of a route: CACHE_CONTROL entries for
+// "METHOD /path" or "/path" (GET) win over the route table, and "*" applies
+// to GET routes that have no policy of their own. "none" removes a policy.
+func cacheControlPolicy(route Route, overrides map[string]string) string {
+	policy, ok := overrides[route.Method+" "+route.Path]
+	if !ok {
+		policy, ok = overrides["* "+route.Path]
+	}
+	if !ok && route.CacheControl == "" && route.Method == http.MethodGet {
+		policy, ok = overrides["*"]
+	}
+	if !ok {
+		policy = route.CacheControl
+	}
+	if policy == "none" {
+		return ""
+	}
+	return policy
+}
+
+// Set Cache-Control on successful responses that do not set their own
+func withCacheControl(policy string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next(&cacheControlWriter{ResponseWriter: w, policy: policy}, r)
+	}
+}
+
+type cacheControlWriter struct {
+	http.ResponseWriter
+	policy      string
+	wroteHeader bool
+}
+
+func (cw *cacheControlWriter) WriteHeader(status int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		h := cw.Header()
+		if (status < 300 || status == http.StatusNotModified) && h.Get("Cache-Control") == "" {
+			h.Set("Cache-Control", cw.policy)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *cacheControlWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *cacheControlWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+
+	"go-spring/internal/captcha"
+	"go-spring/internal/config"
+)
+
+// CAPTCHA checks (CAPTCHA_PROVIDER). A request to one of CAPTCHA_ROUTES
+// without valid credentials needs the token of the provider's widget in
+// the X-Captcha-Token header, which is verified with the provider before
+// the handler runs.
+
+// The header that carries the token
+const captchaHeader = "X-Captcha-Token"
+
+type captchaState struct {
+	captchaVerifier captcha.Verifier
+}
+
+func (app *App) initCaptcha(cfg config.Config) {
+	app.captchaVerifier = captcha.Verifier{Provider: cfg.CaptchaProvider, Secret: cfg.CaptchaSecret}
+}
+
+// The routes of CAPTCHA_ROUTES
+func (app *App) captchaRoutes(r Route) bool {
+	return slices.Contains(app.cfg.CaptchaRoutes, r.Method+" "+r.Path)
+}
+
+// Verify the CAPTCHA token of a request without credentials. A provider
+// that can't be reached fails the request: letting it through would open
+// the route to bots whenever the provider is down.
+func (app *App) requireCaptcha(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := app.requestUser(r); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token := r.Header.Get(captchaHeader)
+		if token == "" {
+			app.writeError(w, r, http.StatusForbidden, CodeCaptchaRequired, "CAPTCHA token required")
+			return
+		}
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		switch err := app.captchaVerifier.Verify(r.Context(), token, ip); {
+		case errors.Is(err, captcha.ErrInvalid):
+			app.writeError(w, r, http.StatusForbidden, CodeCaptchaInvalid, "CAPTCHA verification failed")
+		case err != nil:
+			log.Printf("Warning: CAPTCHA check: %v", err)
+			app.writeError(w, r, http.StatusBadGateway, CodeUpstreamFailed, "CAPTCHA could not be verified")
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
@@ -0,0 +1,459 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-spring/internal/model"
+)
+
+// Codec encodes response envelopes and decodes request bodies in one
+// representation. Codecs are registered by media type and chosen from the
+// Accept (responses) and Content-Type (requests) headers.
+type Codec interface {
+	ContentType() string
+	Encode(w io.Writer, v any) error
+	Decode(r io.Reader, v any) error
+}
+
+var (
+	codecs       = map[string]Codec{} // by media type
+	defaultCodec Codec
+)
+
+func registerCodec(codec Codec, mediaTypes ...string) {
+	for _, mediaType := range append(mediaTypes, codec.ContentType()) {
+		codecs[mediaType] = codec
+	}
+}
+
+func init() {
+	defaultCodec = jsonCodec{}
+	registerCodec(defaultCodec)
+	registerCodec(jsonAPICodec{})
+	registerCodec(xmlCodec{}, "text/xml")
+	registerCodec(yamlCodec{}, "application/x-yaml", "text/yaml")
+	registerCodec(msgpackCodec{}, "application/x-msgpack", "application/vnd.msgpack")
+}
+
+// Pick the response codec for the Accept header, preferring higher q values
+// and falling back to JSON
+func negotiateCodec(r *http.Request) Codec {
+	type candidate struct {
+		codec Codec
+		q     float64
+	}
+	var candidates []candidate
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(accept)
+		if err != nil {
+			continue
+		}
+		codec, ok := codecs[mediaType]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if v, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			q = v
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{codec, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return defaultCodec
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].codec
+}
+
+// Pick the request codec for the Content-Type header, falling back to JSON
+func requestCodec(r *http.Request) Codec {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if codec, ok := codecs[mediaType]; ok {
+		return codec
+	}
+	return defaultCodec
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string { return "application/json" }
+
+// Response envelopes with a list in Data are written an element at a time,
+// see streamJSONResponse; anything else is encoded whole in a pooled buffer
+func (jsonCodec) Encode(w io.Writer, v any) error {
+	if response, ok := v.(Response); ok {
+		if list := reflect.ValueOf(response.Data); streamable(response, list) {
+			return streamJSONResponse(w, response, list)
+		}
+	}
+	buf := getJSONBuffer()
+	defer putJSONBuffer(buf)
+	if err := buf.enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (jsonCodec) Decode(r io.Reader, v any) error { return json.NewDecoder(r).Decode(v) }
+
+// xmlCodec writes the JSON field names as elements under <response>;
+// arrays become repeated <item> elements. Keys that can't be element
+// names, such as the category names of /stats, become <entry key="...">.
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string { return "application/xml" }
+
+func (xmlCodec) Encode(w io.Writer, v any) error {
+	value, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	io.WriteString(w, xml.Header)
+	if err := encodeXMLValue(enc, xmlElement("response"), value); err != nil {
+		return err
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+func encodeXMLValue(enc *xml.Encoder, start xml.StartElement, value any) error {
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case orderedMap:
+		for _, field := range v {
+			child := xmlElement(field.Key)
+			if !xmlElementName(field.Key) {
+				child = xmlElement("entry")
+				child.Attr = []xml.Attr{{Name: xml.Name{Local: "key"}, Value: field.Key}}
+			}
+			if err := encodeXMLValue(enc, child, field.Value); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if err := encodeXMLValue(enc, xmlElement("item"), item); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		if err := enc.EncodeToken(xml.CharData(fmt.Sprint(v))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+func xmlElement(name string) xml.StartElement {
+	return xml.StartElement{Name: xml.Name{Local: name}}
+}
+
+// Whether a key can be written as an element name: ASCII letters, digits,
+// '_', '-' and '.', not starting with a digit, '-', '.' or "xml". Neither
+// can "item" and "entry", which would read back as a list item or entry.
+func xmlElementName(key string) bool {
+	if key == "" || key == "item" || key == "entry" || strings.HasPrefix(strings.ToLower(key), "xml") {
+		return false
+	}
+	for i, c := range []byte(key) {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', c == '_':
+		case i > 0 && ('0' <= c && c <= '9' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (xmlCodec) Decode(r io.Reader, v any) error {
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			value, err := decodeXMLElement(dec, start)
+			if err != nil {
+				return err
+			}
+			return assignGeneric(reflect.ValueOf(v).Elem(), value)
+		}
+	}
+}
+
+// Decode an element into a string (text only), a map of child elements
+// (by the key of <entry> elements), or a list when all children are <item>
+func decodeXMLElement(dec *xml.Decoder, start xml.StartElement) (any, error) {
+	var text strings.Builder
+	fields := map[string]any{}
+	var items []any
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			text.Write(t)
+		case xml.StartElement:
+			child, err := decodeXMLElement(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			switch key, keyed := xmlEntryKey(t); {
+			case keyed:
+				fields[key] = child
+			case t.Name.Local == "item":
+				items = append(items, child)
+			default:
+				fields[t.Name.Local] = child
+			}
+		case xml.EndElement:
+			switch {
+			case len(items) > 0:
+				return items, nil
+			case len(fields) > 0:
+				return fields, nil
+			}
+			return text.String(), nil
+		}
+	}
+}
+
+// The key of an <entry key="..."> element
+func xmlEntryKey(start xml.StartElement) (string, bool) {
+	if start.Name.Local != "entry" {
+		return "", false
+	}
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "key" {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+// orderedMap keeps the field order of a JSON object so that other
+// representations list fields in the same order as the JSON output
+type orderedMap []orderedField
+
+type orderedField struct {
+	Key   string
+	Value any
+}
+
+// Convert a value to its generic JSON form: nil, bool, json.Number, string,
+// []any or orderedMap. Field names and omitempty follow the json tags.
+func toGeneric(v any) (any, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	return readGeneric(dec)
+}
+
+func readGeneric(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		m := orderedMap{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := readGeneric(dec)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, orderedField{key.(string), value})
+		}
+		_, err = dec.Token() // closing brace
+		return m, err
+	case json.Delim('['):
+		list := []any{}
+		for dec.More() {
+			value, err := readGeneric(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err = dec.Token() // closing bracket
+		return list, err
+	}
+	return tok, nil
+}
+
+// Store a decoded generic value (maps, lists and scalars, where scalars may
+// still be text as in XML and YAML) into a typed destination, matching
+// struct fields by their json names
+func assignGeneric(dst reflect.Value, src any) error {
+	if src == nil {
+		return nil
+	}
+	if dst.Type() == articleIDType {
+		id, err := model.ParseArticleID(strings.TrimSpace(fmt.Sprint(src)))
+		if err != nil {
+			return err
+		}
+		dst.SetInt(int64(id))
+		return nil
+	}
+	if dst.Type() == timeType {
+		s, ok := src.(string)
+		if !ok {
+			return fmt.Errorf("expected a time, got %T", src)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return err
+		}
+		dst.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	// Binary data, from MessagePack, or base64 as encoding/json writes it
+	if b, ok := src.([]byte); ok && dst.Kind() == reflect.String {
+		dst.SetString(string(b))
+		return nil
+	}
+	if dst.Kind() == reflect.Slice && dst.Type().Elem().Kind() == reflect.Uint8 {
+		switch s := src.(type) {
+		case []byte:
+			dst.SetBytes(bytes.Clone(s))
+			return nil
+		case string:
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return fmt.Errorf("expected base64, got %q", s)
+			}
+			dst.SetBytes(b)
+			return nil
+		}
+	}
+
+	switch dst.Kind() {
+	case reflect.Pointer:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return assignGeneric(dst.Elem(), src)
+	case reflect.Interface:
+		dst.Set(reflect.ValueOf(src))
+	case reflect.String:
+		dst.SetString(fmt.Sprint(src))
+	case reflect.Bool:
+		switch s := src.(type) {
+		case bool:
+			dst.SetBool(s)
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(s))
+			if err != nil {
+				return fmt.Errorf("expected a boolean, got %q", s)
+			}
+			dst.SetBool(b)
+		default:
+			return fmt.Errorf("expected a boolean, got %T", src)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(fmt.Sprint(src)), 10, 64)
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %v", src)
+		}
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(strings.TrimSpace(fmt.Sprint(src)), 10, 64)
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %v", src)
+		}
+		dst.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(fmt.Sprint(src)), 64)
+		if err != nil {
+			return fmt.Errorf("expected a number, got %v", src)
+		}
+		dst.SetFloat(f)
+	case reflect.Slice:
+		list, ok := src.([]any)
+		if !ok {
+			list = []any{src} // a single XML child
+		}
+		out := reflect.MakeSlice(dst.Type(), len(list), len(list))
+		for i, item := range list {
+			if err := assignGeneric(out.Index(i), item); err != nil {
+				return err
+			}
+		}
+		dst.Set(out)
+	case reflect.Map:
+		fields, ok := src.(map[string]any)
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("expected an object, got %T", src)
+		}
+		out := reflect.MakeMapWithSize(dst.Type(), len(fields))
+		for key, value := range fields {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			if err := assignGeneric(elem, value); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			out.SetMapIndex(reflect.ValueOf(key).Convert(dst.Type().Key()), elem)
+		}
+		dst.Set(out)
+	case reflect.Struct:
+		fields, ok := src.(map[string]any)
+		if !ok {
+			return fmt.Errorf("expected an object, got %T", src)
+		}
+		for i := range dst.NumField() {
+			f := dst.Type().Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" || !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			for key, value := range fields {
+				if strings.EqualFold(key, name) {
+					if err := assignGeneric(dst.Field(i), value); err != nil {
+						return fmt.Errorf("%s: %w", name, err)
+					}
+				}
+			}
+		}
+	default:
+		return errors.New("unsupported field type " + dst.Type().String())
+	}
+	return nil
+}
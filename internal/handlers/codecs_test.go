@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestXMLMapKeys(t *testing.T) {
+	// Category, workspace and language names of /stats are user-given
+	data := map[string]int{"1x": 2, "a b<": 1, "item": 3, "entry": 4, "xmlns": 5, "ünïcode": 6, "": 7, "fine.name-1": 8}
+	var b bytes.Buffer
+	if err := (xmlCodec{}).Encode(&b, Response{Message: "ok", Data: data}); err != nil {
+		t.Fatal(err)
+	}
+	// Well-formed for any XML parser
+	dec := xml.NewDecoder(bytes.NewReader(b.Bytes()))
+	for {
+		if _, err := dec.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("%v in\n%s", err, b.String())
+		}
+	}
+	if !strings.Contains(b.String(), `<entry key="a b&lt;">1</entry>`) || !strings.Contains(b.String(), "<fine.name-1>8</fine.name-1>") {
+		t.Errorf("encoded as\n%s", b.String())
+	}
+
+	var got struct {
+		Data map[string]int `json:"data"`
+	}
+	if err := (xmlCodec{}).Decode(&b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Data, data) {
+		t.Errorf("decoded %v, want %v", got.Data, data)
+	}
+}
+
+func TestXMLElementName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"title": true, "next_page_token": true, "user.mentioned": true, "a-1": true, "_x": true,
+		"": false, "1x": false, "-a": false, ".a": false, "a b": false, "a<": false, "a:b": false,
+		"XMLish": false, "item": false, "entry": false, "å": false,
+	} {
+		if got := xmlElementName(name); got != want {
+			t.Errorf("xmlElementName(%q) = %v", name, got)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Response encodings in order of preference. Brotli would slot in here, but
+// the standard library only ships gzip and deflate encoders.
+var compressEncodings = []struct {
+	Name      string
+	NewWriter func(io.Writer) io.WriteCloser
+}{
+	{"gzip", func(w io.Writer) io.WriteCloser {
+		gz, _ := gzip.NewWriterLevel(w, gzip.DefaultCompression)
+		return gz
+	}},
+	{"deflate", func(w io.Writer) io.WriteCloser {
+		fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return fw
+	}},
+}
+
+// compressResponses compresses responses whose content type matches
+// COMPRESSION_TYPES once they reach COMPRESSION_MIN_BYTES
+func (app *App) compressResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: acceptedEncoding(r), status: http.StatusOK,
+			minBytes: app.cfg.CompressionMinBytes, types: app.cfg.CompressionTypes}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// Pick the best supported encoding from Accept-Encoding, or "" for none.
+// Ties go to the earlier entry in compressEncodings.
+func acceptedEncoding(r *http.Request) string {
+	best, bestRank, bestQ := "", len(compressEncodings), 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, _ = strconv.ParseFloat(v, 64)
+		}
+		for rank, enc := range compressEncodings {
+			if !strings.EqualFold(name, enc.Name) && name != "*" {
+				continue
+			}
+			if q > bestQ || (q == bestQ && q > 0 && rank < bestRank) {
+				best, bestRank, bestQ = enc.Name, rank, q
+			}
+		}
+	}
+	return best
+}
+
+// Whether responses of this content type are worth compressing, matching
+// one of types
+func compressibleType(contentType string, types []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range types {
+		if ok, _ := path.Match(pattern, mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter holds back the start of a response until it knows whether
+// the body is large enough to compress
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	minBytes    int64    // COMPRESSION_MIN_BYTES
+	types       []string // COMPRESSION_TYPES
+	status      int
+	wroteHeader bool // WriteHeader was called by the handler
+	decided     bool // headers were sent downstream
+	buf         bytes.Buffer
+	out         io.WriteCloser // compressor, nil when passing through
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader || cw.decided {
+		return
+	}
+	cw.status = status
+	cw.wroteHeader = true
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.decided {
+		cw.buf.Write(b)
+		if int64(cw.buf.Len()) >= cw.minBytes {
+			cw.decide(true)
+		}
+		return len(b), nil
+	}
+	if cw.out != nil {
+		return cw.out.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Send headers downstream, compressing if the response qualifies and big
+// is set (the buffered body reached the size threshold)
+func (cw *compressWriter) decide(big bool) {
+	cw.decided = true
+	h := cw.Header()
+	compressible := compressibleType(h.Get("Content-Type"), cw.types) &&
+		h.Get("Content-Encoding") == "" && h.Get("Content-Range") == "" &&
+		cw.status != http.StatusPartialContent
+	if compressible {
+		h.Add("Vary", "Accept-Encoding")
+	}
+
+	if compressible && big && cw.encoding != "" {
+		for _, enc := range compressEncodings {
+			if enc.Name == cw.encoding {
+				h.Set("Content-Encoding", enc.Name)
+				h.Del("Content-Length")
+				h.Del("Accept-Ranges")
+				cw.out = enc.NewWriter(cw.ResponseWriter)
+			}
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if cw.buf.Len() > 0 {
+		data := cw.buf.Bytes()
+		if cw.out != nil {
+			cw.out.Write(data)
+		} else {
+			cw.ResponseWriter.Write(data)
+		}
+		cw.buf.Reset()
+	}
+}
+
+// On the first flush of a streaming response, compress only if enough has
+// been written already; small events (e.g. SSE) are sent as they are
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide(int64(cw.buf.Len()) >= cw.minBytes)
+	}
+	if f, ok := cw.out.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) Close() {
+	if !cw.decided {
+		cw.decide(false)
+	}
+	if cw.out != nil {
+		cw.out.Close()
+	}
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+
+	"go-spring/internal/config"
+	"go-spring/internal/keyring"
+	"go-spring/internal/kms"
+	"go-spring/internal/model"
+	"go-spring/internal/store"
+)
+
+// Confidential articles (ENCRYPTION_KEYS_FILE). An article created or
+// updated with "confidential": true, or any article of a workspace that is
+// confidential, has its content encrypted with a data key before it is
+// written to the store, see the store package. Readers see the content
+// only if they may: users, and for a workspace article its members. Others
+// get the article with its content left out, and feeds skip it. Until the
+// first user is added everyone may, as with the other restricted routes.
+
+type confidentialState struct {
+	// Encrypts confidential content; nil when ENCRYPTION_KEYS_FILE is unset
+	contentKeys *keyring.Keyring
+}
+
+// Load the data keys of ENCRYPTION_KEYS_FILE, a JSON array of
+// keyring.Key whose first key encrypts. With KMS_KEY they are unwrapped
+// with the KMS, and any stored in plaintext or wrapped with an old version
+// of the master key are wrapped anew and the file rewritten.
+func loadEncryptionKeys(cfg config.Config) (*keyring.Keyring, error) {
+	if cfg.EncryptionKeysFile == "" {
+		return nil, nil
+	}
+	wrapper, err := keyWrapper(cfg)
+	if err != nil {
+		return nil, err
+	}
+	keys, rewrapped, err := keyring.LoadFile(context.Background(), cfg.EncryptionKeysFile, wrapper)
+	if err != nil {
+		return nil, err
+	}
+	if rewrapped > 0 {
+		log.Printf("Wrapped %d keys of %s with %s", rewrapped, cfg.EncryptionKeysFile, cfg.KMSKey)
+	}
+	return keys, nil
+}
+
+// Load the data keys and have the data store compress and encrypt content
+// with the configured settings
+func (app *App) initEncryption(cfg config.Config) error {
+	keys, err := loadEncryptionKeys(cfg)
+	if err != nil {
+		return fmt.Errorf("load ENCRYPTION_KEYS_FILE: %w", err)
+	}
+	app.contentKeys = keys
+	store.SetContentOptions(app.dataStore, contentOptions(cfg, keys))
+	return nil
+}
+
+// The content options of cfg, encrypting with keys if there are any
+func contentOptions(cfg config.Config, keys *keyring.Keyring) store.ContentOptions {
+	opts := store.ContentOptions{Compression: cfg.StoreCompression}
+	if keys != nil {
+		opts.Cipher = keys
+	}
+	return opts
+}
+
+// The KMS of KMS_KEY, nil if it is unset
+func keyWrapper(cfg config.Config) (keyring.Wrapper, error) {
+	if cfg.KMSKey == "" {
+		return nil, nil
+	}
+	return kms.New(cfg)
+}
+
+// ContentOptions are how the server compresses and encrypts content, for
+// commands that open a store themselves
+func ContentOptions(cfg config.Config) (store.ContentOptions, error) {
+	keys, err := loadEncryptionKeys(cfg)
+	if err != nil {
+		return store.ContentOptions{}, fmt.Errorf("load ENCRYPTION_KEYS_FILE: %w", err)
+	}
+	return contentOptions(cfg, keys), nil
+}
+
+// RewrapEncryptionKeys wraps the data keys of ENCRYPTION_KEYS_FILE that are
+// in plaintext or wrapped with an old version of KMS_KEY anew, and returns
+// how many it did. The keys themselves stay the same.
+func RewrapEncryptionKeys(ctx context.Context, cfg config.Config) (int, error) {
+	wrapper, err := keyWrapper(cfg)
+	if err != nil {
+		return 0, err
+	}
+	if wrapper == nil {
+		return 0, errors.New("KMS_KEY is not set")
+	}
+	_, rewrapped, err := keyring.LoadFile(ctx, cfg.EncryptionKeysFile, wrapper)
+	return rewrapped, err
+}
+
+// AddEncryptionKey makes a new data key the first, encrypting, key of
+// ENCRYPTION_KEYS_FILE, wrapped with KMS_KEY if it is set
+func AddEncryptionKey(ctx context.Context, cfg config.Config, id string) error {
+	wrapper, err := keyWrapper(cfg)
+	if err != nil {
+		return err
+	}
+	return keyring.AddKey(ctx, cfg.EncryptionKeysFile, id, wrapper)
+}
+
+// Make an article confidential when asked to or when its workspace is,
+// which takes encryption keys. The caller holds articlesMutex.
+func (app *App) setConfidential(article *model.Article, confidential bool) error {
+	if !confidential && article.Workspace != "" {
+		i := slices.IndexFunc(app.workspaces, func(ws model.Workspace) bool { return ws.Slug == article.Workspace })
+		confidential = i >= 0 && app.workspaces[i].Confidential
+	}
+	if confidential && app.contentKeys == nil {
+		return errNoContentKeys
+	}
+	article.Confidential = confidential
+	return nil
+}
+
+var errNoContentKeys = &ValidationError{Message: "Confidential articles need ENCRYPTION_KEYS_FILE"}
+
+// Make a workspace confidential or not. Its articles become confidential
+// with it, and stay so when it no longer is; the caller holds
+// articlesMutex.
+func (app *App) setWorkspaceConfidential(ws *model.Workspace, confidential bool) error {
+	if confidential && app.contentKeys == nil {
+		return errNoContentKeys
+	}
+	ws.Confidential = confidential
+	for i := range app.articles {
+		if confidential && app.articles[i].Workspace == ws.Slug {
+			app.articles[i].Confidential = true
+		}
+	}
+	return nil
+}
+
+// Which confidential articles a reader may read: a user those outside
+// workspaces and those of the workspaces they are a viewer of, someone
+// without credentials none
+func (app *App) confidentialReader(user model.User, ok bool) func(model.Article) bool {
+	open := !app.hasUsers()
+	return func(article model.Article) bool {
+		if open || !ok {
+			return open
+		}
+		if article.Workspace == "" {
+			return true
+		}
+		ws, found := app.findWorkspace(article.Workspace)
+		return !found || ws.Allows(user, model.WorkspaceViewer)
+	}
+}
+
+// The reader of a request, by the user of its credentials, which are
+// checked only once a confidential article needs them
+func (app *App) requestReader(r *http.Request) func(model.Article) bool {
+	mayRead := sync.OnceValue(func() func(model.Article) bool { return app.confidentialReader(app.requestUser(r)) })
+	return func(article model.Article) bool { return mayRead()(article) }
+}
+
+// Leave out the content of the confidential articles mayRead refuses,
+// copying list before changing it
+func withholdContent(list []model.Article, mayRead func(model.Article) bool) []model.Article {
+	copied := false
+	for i, article := range list {
+		if !article.Confidential || mayRead(article) {
+			continue
+		}
+		if !copied {
+			list, copied = slices.Clone(list), true
+		}
+		list[i] = withoutContent(article)
+	}
+	return list
+}
+
+// An article as the request may see it
+func (app *App) withheldArticle(r *http.Request, article model.Article) model.Article {
+	if article.Confidential && !app.requestReader(r)(article) {
+		article = withoutContent(article)
+	}
+	return article
+}
+
+// The article without its content or that of its translations
+func withoutContent(article model.Article) model.Article {
+	article.Content = ""
+	if len(article.Translations) > 0 {
+		article.Translations = maps.Clone(article.Translations)
+		for lang, t := range article.Translations {
+			t.Content = ""
+			article.Translations[lang] = t
+		}
+	}
+	return article
+}
+
+// Check that the request may read the content of an article, answering
+// 401 or 403 if not
+func (app *App) allowContent(w http.ResponseWriter, r *http.Request, article model.Article) bool {
+	if !article.Confidential {
+		return true
+	}
+	// Shared caches must not hand confidential content to others
+	w.Header().Set("Cache-Control", "private")
+	if !app.hasUsers() {
+		return true
+	}
+	user, ok := app.basicAuthUser(w, r)
+	if !ok {
+		return false
+	}
+	if !app.confidentialReader(user, true)(article) {
+		app.writeError(w, r, http.StatusForbidden, CodeForbidden, "Workspace role required: "+model.WorkspaceViewer)
+		return false
+	}
+	return true
+}
+
+// gRPC calls carry no credentials, so they get confidential content only
+// while there are no users
+func (app *App) grpcWithheld(list []model.Article) []model.Article {
+	return withholdContent(list, app.confidentialReader(model.User{}, false))
+}
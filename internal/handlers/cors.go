@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Methods and response headers that cross-origin clients may use
+const (
+	corsAllowMethods  = "GET, POST, PUT, PATCH, DELETE"
+	corsExposeHeaders = "Location, Retry-After, Preference-Applied, X-Cache, Content-Range, Upload-Offset, Upload-Length, Upload-Expires"
+)
+
+// allowCORS lets browsers on the CORS_ORIGINS origins ("*" for any) call the
+// API, and answers their preflight requests
+func (app *App) allowCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+		if origin == "" || !app.corsAllowed(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Allow-Credentials", "true")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", corsAllowMethods)
+			if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+				h.Set("Access-Control-Allow-Headers", headers)
+			}
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", corsExposeHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *App) corsAllowed(origin string) bool {
+	return slices.Contains(app.cfg.CORSOrigins, "*") || slices.Contains(app.cfg.CORSOrigins, strings.ToLower(origin))
+}
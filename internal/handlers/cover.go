@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+
+	"go-spring/internal/model"
+)
+
+// Body of PUT /articles/{id}/cover
+type CoverRequest struct {
+	AttachmentID int    `json:"attachment_id"`
+	URL          string `json:"url" validate:"url"`
+	Download     *bool  `json:"download"` // copy the external image into attachment storage
+}
+
+var coverImageTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+func attachmentCover(attachment model.Attachment, sourceURL string) *model.CoverImage {
+	return &model.CoverImage{
+		AttachmentID: attachment.ID,
+		URL:          fmt.Sprintf("/attachments/%d", attachment.ID),
+		SourceURL:    sourceURL,
+	}
+}
+
+// PUT /articles/{id}/cover - Set the cover from an attachment or external URL
+func (app *App) setCoverImage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := app.articleRouteID(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid article ID")
+		return
+	}
+
+	var req CoverRequest
+	if err := decodeBody(r, &req); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	if err := validateRequest(req); err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+	if (req.AttachmentID == 0) == (req.URL == "") {
+		app.writeError(w, r, http.StatusBadRequest, CodeValidationFailed, "Exactly one of attachment_id or url is required")
+		return
+	}
+
+	var cover *model.CoverImage
+	if req.URL != "" {
+		u, err := parseExternalURL(req.URL)
+		if err != nil {
+			app.writeError(w, r, http.StatusBadRequest, CodeInvalidURL, "Invalid cover URL: "+err.Error())
+			return
+		}
+
+		download := app.cfg.CoverDownload
+		if req.Download != nil {
+			download = *req.Download
+		}
+		if download {
+			attachment, err := app.downloadCover(r, id, u.String())
+			if err != nil {
+				app.writeAttachmentError(w, r, err)
+				return
+			}
+			cover = attachmentCover(attachment, u.String())
+		} else {
+			cover = &model.CoverImage{URL: u.String()}
+		}
+	}
+
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+
+	i := app.findArticleIndex(id)
+	if i < 0 {
+		app.writeError(w, r, http.StatusNotFound, CodeArticleNotFound, "Article not found")
+		return
+	}
+
+	if cover == nil {
+		for _, attachment := range app.articles[i].Attachments {
+			if attachment.ID == req.AttachmentID {
+				if !slices.Contains(coverImageTypes, attachment.ContentType) {
+					app.writeError(w, r, http.StatusUnsupportedMediaType, CodeUnsupportedType, "Attachment is not an image")
+					return
+				}
+				cover = attachmentCover(attachment, "")
+				break
+			}
+		}
+		if cover == nil {
+			app.writeError(w, r, http.StatusNotFound, CodeAttachmentNotFound, "Attachment not found")
+			return
+		}
+	}
+
+	app.articles[i].CoverImage = cover
+	app.publishArticleEvent(r.Context(), EventArticleUpdated, app.articles[i])
+
+	// Save to file
+	app.scheduleSave()
+
+	response := Response{
+		Message: "Cover image updated successfully",
+		Data:    app.articles[i],
+	}
+	app.writeResponse(w, r, http.StatusOK, response)
+}
+
+// POST /articles/{id}/cover - Upload an image and make it the cover
+func (app *App) uploadCoverImage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := app.articleRouteID(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid article ID")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, app.cfg.AttachmentMaxBytes+1<<20)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			app.writeError(w, r, http.StatusRequestEntityTooLarge, CodeFileTooLarge, "File too large")
+			return
+		}
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidFile, "Missing file upload (multipart field \"file\")")
+		return
+	}
+	defer file.Close()
+
+	var updated model.Article
+	_, err = app.storeAttachment(r.Context(), id, header.Filename, file, coverImageTypes, func(article *model.Article, attachment model.Attachment) {
+		article.CoverImage = attachmentCover(attachment, "")
+		updated = *article
+	})
+	if err != nil {
+		app.writeAttachmentError(w, r, err)
+		return
+	}
+
+	response := Response{
+		Message: "Cover image updated successfully",
+		Data:    updated,
+	}
+	app.writeResponse(w, r, http.StatusCreated, response)
+}
+
+// DELETE /articles/{id}/cover - Remove the cover (an uploaded image stays as attachment)
+func (app *App) deleteCoverImage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := app.articleRouteID(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid article ID")
+		return
+	}
+
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+
+	i := app.findArticleIndex(id)
+	if i < 0 {
+		app.writeError(w, r, http.StatusNotFound, CodeArticleNotFound, "Article not found")
+		return
+	}
+	app.articles[i].CoverImage = nil
+	app.publishArticleEvent(r.Context(), EventArticleUpdated, app.articles[i])
+
+	// Save to file
+	app.scheduleSave()
+
+	response := Response{
+		Message: "Cover image removed successfully",
+	}
+	app.writeResponse(w, r, http.StatusOK, response)
+}
+
+// Fetch an external image and store it as an attachment of the article
+func (app *App) downloadCover(r *http.Request, articleID int, rawURL string) (model.Attachment, error) {
+	u, err := parseExternalURL(rawURL)
+	if err != nil {
+		return model.Attachment{}, &attachmentError{http.StatusBadRequest, CodeInvalidURL, "Invalid cover URL: " + err.Error()}
+	}
+	resp, err := fetchExternal(r.Context(), u)
+	if err != nil {
+		return model.Attachment{}, &attachmentError{http.StatusBadGateway, CodeUpstreamFailed, "Failed to download cover image: " + err.Error()}
+	}
+	defer resp.Body.Close()
+
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		name = "cover"
+	}
+	return app.storeAttachment(r.Context(), articleID, name, resp.Body, coverImageTypes, nil)
+}
@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"go-spring/internal/config"
+	"go-spring/internal/model"
+)
+
+// Columns of the CSV export, in order; importable ones are listed in csvImportFields
+var csvColumns = []string{
+	"id", "title", "desc", "content", "status", "published", "created", "updated",
+	"pinned", "featured", "source_url",
+}
+
+// Value of one export column
+func csvValue(article model.Article, column string) string {
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	switch column {
+	case "id":
+		return article.PublicID()
+	case "title":
+		return article.Title
+	case "desc":
+		return article.Desc
+	case "content":
+		return article.Content
+	case "status":
+		return article.Status
+	case "published":
+		return formatTime(article.Published)
+	case "created":
+		return formatTime(article.Created)
+	case "updated":
+		return formatTime(article.Updated)
+	case "pinned":
+		return strconv.FormatBool(article.Pinned)
+	case "featured":
+		return strconv.FormatBool(article.Featured)
+	case "source_url":
+		return article.SourceURL
+	}
+	return ""
+}
+
+// GET /articles/export.csv - Stream all articles as CSV
+//
+// ?columns=title,desc selects and orders columns, ?delimiter=semicolon changes the
+// separator and ?bom=true prefixes a UTF-8 byte order mark for Excel.
+func (app *App) exportArticlesCSV(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	columns := csvColumns
+	if v := query.Get("columns"); v != "" {
+		columns = config.SplitList(v)
+		for _, column := range columns {
+			if !slices.Contains(csvColumns, column) {
+				app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "Unknown column: "+column)
+				return
+			}
+		}
+	}
+	delimiter, err := csvDelimiter(query.Get("delimiter"))
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="articles.csv"`)
+	if query.Get("bom") == "true" {
+		io.WriteString(w, "\ufeff")
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = delimiter
+	cw.Write(columns)
+	row := make([]string, len(columns))
+	flusher, _ := w.(http.Flusher)
+	afterID := 0
+	for {
+		batch := app.articlesAfter(afterID, ExportBatchSize, nil)
+		for _, article := range batch {
+			for i, column := range columns {
+				row[i] = csvValue(article, column)
+			}
+			cw.Write(row)
+		}
+		cw.Flush()
+		if cw.Error() != nil || len(batch) < ExportBatchSize || r.Context().Err() != nil {
+			return
+		}
+		afterID = batch[len(batch)-1].ID
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// Parse a delimiter parameter: a single character, "tab" or "semicolon"
+// (a bare ; is not allowed in query strings)
+func csvDelimiter(v string) (rune, error) {
+	switch v {
+	case "":
+		return ',', nil
+	case "tab", `\t`:
+		return '\t', nil
+	case "semicolon":
+		return ';', nil
+	}
+	r, size := utf8.DecodeRuneInString(v)
+	if size != len(v) || r == '"' || r == '\r' || r == '\n' {
+		return 0, errors.New("delimiter must be a single character")
+	}
+	return r, nil
+}
+
+// ImportReport summarizes a bulk import; Line numbers refer to the source file
+type ImportReport struct {
+	DryRun  bool              `json:"dry_run,omitempty"`
+	Created int               `json:"created"`
+	Skipped int               `json:"skipped"`
+	IDs     []model.ArticleID `json:"ids,omitempty"` // IDs of the created articles
+	Errors  []ImportError     `json:"errors"`
+}
+
+type ImportError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// Largest file accepted by the import endpoints
+const importMaxBytes = 32 << 20
+
+// Article fields that can be filled from CSV columns
+var csvImportFields = []string{"title", "desc", "content", "status", "pinned", "featured"}
+
+// POST /articles/import.csv - Create articles from CSV rows
+//
+// The body is the CSV file itself or a multipart upload in field "file". The
+// header row names the columns; ?map=Otsikko:title,Kuvaus:desc maps other
+// column names to fields. Delimiter (comma, semicolon or tab) and encoding
+// (UTF-8 or UTF-16 with BOM, else Windows-1252 if not valid UTF-8) are
+// detected unless ?delimiter= or ?encoding= are given. Each row is validated
+// on its own; rows with errors are reported and skipped.
+func (app *App) importArticlesCSV(w http.ResponseWriter, r *http.Request) {
+	data, err := readImportFile(w, r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidFile, err.Error())
+		return
+	}
+	text, err := DecodeImportText(data, r.URL.Query().Get("encoding"))
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, err.Error())
+		return
+	}
+
+	delimiter := DetectDelimiter(text)
+	if v := r.URL.Query().Get("delimiter"); v != "" {
+		if delimiter, err = csvDelimiter(v); err != nil {
+			app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, err.Error())
+			return
+		}
+	}
+
+	mapping := map[string]string{}
+	for _, pair := range strings.Split(r.URL.Query().Get("map"), ",") {
+		if column, field, ok := strings.Cut(pair, ":"); ok {
+			mapping[strings.ToLower(strings.TrimSpace(column))] = strings.ToLower(strings.TrimSpace(field))
+		}
+	}
+
+	report, err := app.ImportCSVText(r.Context(), text, delimiter, mapping)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidFile, err.Error())
+		return
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: ImportMessage(report, "articles"), Data: report})
+}
+
+// Create an article from each row of decoded CSV text. mapping renames
+// (lower-cased) header columns to fields. Row errors go into the report; an
+// error is returned when the header is unusable.
+func (app *App) ImportCSVText(ctx context.Context, text string, delimiter rune, mapping map[string]string) (ImportReport, error) {
+	cr := csv.NewReader(strings.NewReader(text))
+	cr.Comma = delimiter
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return ImportReport{}, errors.New("Missing CSV header row")
+	}
+
+	// Column index of each field
+	fields := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if field, ok := mapping[name]; ok {
+			name = field
+		}
+		if slices.Contains(csvImportFields, name) {
+			fields[name] = i
+		}
+	}
+	if len(fields) == 0 {
+		return ImportReport{}, errors.New("No importable columns; expected some of: " + strings.Join(csvImportFields, ", "))
+	}
+
+	report := ImportReport{Errors: []ImportError{}}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				report.Errors = append(report.Errors, ImportError{parseErr.StartLine, parseErr.Err.Error()})
+				report.Skipped++
+				continue
+			}
+			return report, err
+		}
+		line, _ := cr.FieldPos(0)
+
+		req, err := csvArticleRequest(record, fields)
+		if err == nil {
+			// Not articleService.Create, which would announce every row
+			var article model.Article
+			if article, err = app.newArticle(ctx, req); err == nil {
+				report.Created++
+				report.IDs = append(report.IDs, model.ArticleID(app.insertArticle(ctx, article).ID))
+				continue
+			}
+		}
+		report.Errors = append(report.Errors, ImportError{line, err.Error()})
+		report.Skipped++
+	}
+
+	app.notifyImported(ctx, report)
+	return report, nil
+}
+
+// Build a create request from one CSV record
+func csvArticleRequest(record []string, fields map[string]int) (model.CreateArticleRequest, error) {
+	get := func(field string) string {
+		if i, ok := fields[field]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	req := model.CreateArticleRequest{
+		Title:   get("title"),
+		Desc:    get("desc"),
+		Content: get("content"),
+		Status:  strings.ToLower(get("status")),
+	}
+	var err error
+	if req.Pinned, err = parseFlag(get("pinned")); err != nil {
+		return req, fmt.Errorf("pinned: %w", err)
+	}
+	if req.Featured, err = parseFlag(get("featured")); err != nil {
+		return req, fmt.Errorf("featured: %w", err)
+	}
+	return req, nil
+}
+
+// Parse a spreadsheet-style boolean; empty means false
+func parseFlag(v string) (bool, error) {
+	switch strings.ToLower(v) {
+	case "", "0", "false", "no", "n":
+		return false, nil
+	case "1", "true", "yes", "y", "x":
+		return true, nil
+	}
+	return false, fmt.Errorf("expected true or false, got %q", v)
+}
+
+// Read an import file from a multipart "file" field or the raw body
+func readImportFile(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, importMaxBytes)
+	src := io.Reader(r.Body)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, errors.New(`Missing file upload (multipart field "file")`)
+		}
+		defer file.Close()
+		src = file
+	}
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, errors.New("Import file too large or unreadable")
+	}
+	return data, nil
+}
+
+// Convert an import file to UTF-8 text. Without an explicit encoding, byte
+// order marks select UTF-8 or UTF-16 and invalid UTF-8 falls back to
+// Windows-1252, the usual encoding of spreadsheets saved on Windows.
+func DecodeImportText(data []byte, encoding string) (string, error) {
+	switch strings.ToLower(strings.ReplaceAll(encoding, "_", "-")) {
+	case "":
+		switch {
+		case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+			return DecodeImportText(data[3:], "utf-8")
+		case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+			return decodeUTF16(data[2:], binary.LittleEndian), nil
+		case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+			return decodeUTF16(data[2:], binary.BigEndian), nil
+		case utf8.Valid(data):
+			return string(data), nil
+		}
+		return decodeWindows1252(data), nil
+	case "utf-8", "utf8":
+		if !utf8.Valid(data) {
+			return "", errors.New("File is not valid UTF-8; pass ?encoding=windows-1252 or latin1")
+		}
+		return string(bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})), nil
+	case "utf-16le", "utf-16":
+		return decodeUTF16(bytes.TrimPrefix(data, []byte{0xFF, 0xFE}), binary.LittleEndian), nil
+	case "utf-16be":
+		return decodeUTF16(bytes.TrimPrefix(data, []byte{0xFE, 0xFF}), binary.BigEndian), nil
+	case "windows-1252", "cp1252":
+		return decodeWindows1252(data), nil
+	case "latin1", "iso-8859-1":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes), nil
+	}
+	return "", fmt.Errorf("Unsupported encoding %q", encoding)
+}
+
+func decodeUTF16(data []byte, order binary.ByteOrder) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// Windows-1252 differs from Latin-1 only in 0x80-0x9F
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+func decodeWindows1252(data []byte) string {
+	var b strings.Builder
+	for _, c := range data {
+		if c >= 0x80 && c < 0xA0 {
+			b.WriteRune(windows1252[c-0x80])
+		} else {
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
+
+// Guess the delimiter from the header line: comma, semicolon (spreadsheets
+// in locales with decimal commas) or tab
+func DetectDelimiter(text string) rune {
+	header, _, _ := strings.Cut(text, "\n")
+	best, count := ',', strings.Count(header, ",")
+	for _, d := range []rune{';', '\t'} {
+		if n := strings.Count(header, string(d)); n > count {
+			best, count = d, n
+		}
+	}
+	return best
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go-spring/internal/model"
+)
+
+// Fetch a CSV export as text
+func exportCSV(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/csv") {
+		t.Fatalf("export: status %d, %s", resp.StatusCode, data)
+	}
+	return string(data)
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	srv := newTestServer(t, 0)
+	fixtures := []string{
+		`{"title":"Commas, and \"quotes\"","desc":"d; with a semicolon","content":"Line one\nLine two\n\n\"Quoted\" paragraph","pinned":true}`,
+		`{"title":"Ääkköset – ja €","desc":"Tab\tinside","content":"Windows\r\nline ends","status":"draft","featured":true}`,
+		`{"title":"Plain","desc":"d","content":"c"}`,
+	}
+	for _, body := range fixtures {
+		if resp := call(t, "POST", srv.URL+"/articles", body, nil); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("create: status %d", resp.StatusCode)
+		}
+	}
+	var want []model.Article
+	call(t, "GET", srv.URL+"/articles", "", &want)
+
+	for _, query := range []string{"", "?delimiter=semicolon&bom=true", "?delimiter=tab"} {
+		export := exportCSV(t, srv.URL+"/articles/export.csv"+query)
+		rows, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(export, "\ufeff"))).ReadAll()
+		if query == "" && (err != nil || len(rows) != 4 || strings.Join(rows[0], ",") != strings.Join(csvColumns, ",")) {
+			t.Errorf("export %q: %v", export, err)
+		}
+
+		// A fresh server imports the export as it was
+		srv := newTestServer(t, 0)
+		var report ImportReport
+		if resp := call(t, "POST", srv.URL+"/articles/import.csv", export, &report); resp.StatusCode != http.StatusOK || report.Created != 3 || len(report.Errors) != 0 {
+			t.Fatalf("%s: import status %d, report %+v", query, resp.StatusCode, report)
+		}
+		var got []model.Article
+		call(t, "GET", srv.URL+"/articles", "", &got)
+		if len(got) != len(want) {
+			t.Fatalf("%s: %d articles, want %d", query, len(got), len(want))
+		}
+		for i := range want {
+			// csv.Reader turns \r\n in quoted fields into \n
+			content := strings.ReplaceAll(want[i].Content, "\r\n", "\n")
+			if got[i].Title != want[i].Title || got[i].Desc != want[i].Desc || got[i].Content != content ||
+				got[i].Status != want[i].Status || got[i].Pinned != want[i].Pinned || got[i].Featured != want[i].Featured {
+				t.Errorf("%s: article %d\n got %+v\nwant %+v", query, i+1, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestCSVImport(t *testing.T) {
+	srv := newTestServer(t, 0)
+
+	text := "Title,Desc,Content,Pinned,Extra\n" +
+		"\"Quoted, title\",d,\"First line\n\nSecond \"\"quoted\"\" line\",yes,ignored\n" +
+		"No content,d,,,\n" +
+		"Bad flag,d,c,maybe,\n" +
+		"Bare \"quote,d,c,,\n" +
+		"  Trimmed  ,d,c,x,\n"
+	var report ImportReport
+	if resp := call(t, "POST", srv.URL+"/articles/import.csv", text, &report); resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	if report.Created != 2 || report.Skipped != 3 || len(report.Errors) != 3 {
+		t.Fatalf("report %+v", report)
+	}
+	for i, want := range []struct {
+		line int
+		text string
+	}{{5, "content"}, {6, "pinned"}, {7, "quote"}} {
+		if got := report.Errors[i]; got.Line != want.line || !strings.Contains(got.Message, want.text) {
+			t.Errorf("error %d: %+v, want line %d about %s", i, got, want.line, want.text)
+		}
+	}
+
+	var first, last model.Article
+	call(t, "GET", srv.URL+"/articles/1", "", &first)
+	call(t, "GET", srv.URL+"/articles/2", "", &last)
+	if first.Title != "Quoted, title" || first.Content != "First line\n\nSecond \"quoted\" line" || !first.Pinned || first.Status != model.StatusPublished {
+		t.Errorf("first %+v", first)
+	}
+	if last.Title != "Trimmed" || !last.Pinned {
+		t.Errorf("last %+v", last)
+	}
+
+	// Semicolons and Windows-1252 are detected; ?map renames columns
+	text = "Otsikko;Kuvaus;Sis\xe4lt\xf6\n\x93Lainaus\x94;kuvaus;teksti \x80\n"
+	resp := call(t, "POST", srv.URL+"/articles/import.csv?map=otsikko:title,kuvaus:desc,sis%C3%A4lt%C3%B6:content", text, &report)
+	if resp.StatusCode != http.StatusOK || report.Created != 1 {
+		t.Fatalf("mapped import: status %d, report %+v", resp.StatusCode, report)
+	}
+	var mapped model.Article
+	call(t, "GET", srv.URL+"/articles/3", "", &mapped)
+	if mapped.Title != "“Lainaus”" || mapped.Content != "teksti €" {
+		t.Errorf("mapped %+v", mapped)
+	}
+}
+
+func TestCSVErrors(t *testing.T) {
+	srv := newTestServer(t, 0)
+	if resp := call(t, "POST", srv.URL+"/articles", `{"title":"Hello","desc":"First","content":"Some text"}`, nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create: status %d", resp.StatusCode)
+	}
+
+	for name, tc := range map[string]struct{ method, url, body string }{
+		"empty file":       {"POST", "/articles/import.csv", ""},
+		"no known columns": {"POST", "/articles/import.csv", "name,body\na,b\n"},
+		"bad encoding":     {"POST", "/articles/import.csv?encoding=ebcdic", "title\nt\n"},
+		"invalid UTF-8":    {"POST", "/articles/import.csv?encoding=utf-8", "title\n\xff\n"},
+		"long delimiter":   {"POST", "/articles/import.csv?delimiter=ab", "title\nt\n"},
+		"quote delimiter":  {"GET", "/articles/export.csv?delimiter=%22", ""},
+		"unknown column":   {"GET", "/articles/export.csv?columns=title,secret", ""},
+	} {
+		if resp := call(t, tc.method, srv.URL+tc.url, tc.body, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status %d", name, resp.StatusCode)
+		}
+	}
+
+	// Selected columns in the order asked
+	export := exportCSV(t, srv.URL+"/articles/export.csv?columns=status,id")
+	if want := "status,id\npublished,1\n"; export != want {
+		t.Errorf("export %q, want %q", export, want)
+	}
+}
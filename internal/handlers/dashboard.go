@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"embed"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// The admin dashboard is a page and a script, like the API explorer, that
+// work through the API: it lists, searches, creates and edits articles,
+// shows what users did and runs export jobs for backups. The page itself is
+// public, so the browser doesn't ask for a password before the script can;
+// the script signs in with Basic credentials it keeps for the session, and
+// the API decides what they allow.
+
+// Admin dashboard assets, compiled into the binary
+//
+//go:embed static/admin
+var dashboardAssets embed.FS
+
+// GET /admin and /admin-ui/{asset} - Admin dashboard
+func serveDashboard(w http.ResponseWriter, r *http.Request) {
+	asset := mux.Vars(r)["asset"]
+	if asset == "" {
+		asset = "index.html"
+	}
+	serveAsset(w, r, dashboardAssets, "static/admin", asset)
+}
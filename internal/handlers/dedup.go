@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"encoding/hex"
+	"hash"
+	"log"
+	"net/http"
+
+	"go-spring/internal/jobs"
+)
+
+// Attachment files are stored by the SHA-256 of their content, under
+// blobs/sha256/<hex>, so the same file uploaded to several articles, or
+// twice to one, is stored once. Attachments with the same content share
+// the blob, which is deleted with the last of them: the references are
+// counted from the articles themselves, under articlesMutex, plus the
+// uploads between storing a blob and recording its attachment. Those
+// uploads write the blob even when it exists, unless an attachment refers
+// to it already, so a failed upload of the same file never leaves another
+// one pointing at nothing. Thumbnails stay per attachment.
+//
+// Files stored before keep their articles/<id>/<n> keys. Blobs that lost
+// their attachment some other way, a crash between storing and saving or a
+// failed delete, are removed by the blob GC job, POST /admin/blobs/gc.
+
+const contentBlobPrefix = "blobs/sha256/"
+
+type dedupState struct {
+	// Uploads between storing their blob and recording the attachment, by key;
+	// guarded by articlesMutex
+	pendingBlobs map[string]int
+}
+
+// Kind of the job that deletes blobs nothing refers to
+const jobBlobGC = "blob-gc"
+
+// BlobGCResult is the result of a blob GC job
+type BlobGCResult struct {
+	Scanned    int   `json:"scanned"`
+	Deleted    int   `json:"deleted"`
+	FreedBytes int64 `json:"freed_bytes"`
+}
+
+// The key of the blob with the content whose hash is sum
+func contentBlobKey(sum hash.Hash) string {
+	return contentBlobPrefix + hex.EncodeToString(sum.Sum(nil))
+}
+
+// How many attachments and pending uploads refer to each blob; the caller
+// holds articlesMutex
+func (app *App) blobReferences() map[string]int {
+	refs := make(map[string]int, len(app.pendingBlobs))
+	for key, n := range app.pendingBlobs {
+		refs[key] += n
+	}
+	for _, article := range app.articles {
+		for _, attachment := range article.Attachments {
+			refs[attachment.Key]++
+		}
+	}
+	return refs
+}
+
+// Whether an attachment refers to key, so its blob exists; the caller
+// holds articlesMutex
+func (app *App) attachmentBlobExists(key string) bool {
+	for _, article := range app.articles {
+		for _, attachment := range article.Attachments {
+			if attachment.Key == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Delete the blob of an upload that failed unless something else refers to
+// it; the caller holds articlesMutex
+func (app *App) releaseBlob(ctx context.Context, key string) {
+	if app.blobReferences()[key] == 0 {
+		app.deleteBlob(ctx, key)
+	}
+}
+
+// POST /admin/blobs/gc - Delete attachment files nothing refers to
+func (app *App) startBlobGC(w http.ResponseWriter, r *http.Request) {
+	app.acceptJob(w, r, jobBlobGC, struct{}{})
+}
+
+// Delete the content blobs no attachment or upload refers to
+func (app *App) runBlobGCJob(ctx context.Context, job jobs.Job) error {
+	blobs, err := app.listBlobs(ctx, contentBlobPrefix)
+	if err != nil {
+		return err
+	}
+	result := BlobGCResult{Scanned: len(blobs)}
+
+	app.articlesMutex.RLock()
+	refs := app.blobReferences()
+	app.articlesMutex.RUnlock()
+	for _, blob := range blobs {
+		if refs[blob.Key] > 0 || !app.deleteOrphan(ctx, blob.Key) {
+			continue
+		}
+		result.Deleted++
+		result.FreedBytes += blob.Size
+	}
+	return jobs.SetResult(ctx, result)
+}
+
+// Delete the blob key if it is still unreferenced. Under the lock, so no
+// upload records an attachment of it in between.
+func (app *App) deleteOrphan(ctx context.Context, key string) bool {
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+	if app.pendingBlobs[key] > 0 || app.attachmentBlobExists(key) {
+		return false
+	}
+	if err := app.deleteBlob(ctx, key); err != nil {
+		log.Printf("Warning: Failed to delete orphaned blob %s: %v", key, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"log"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"go-spring/internal/config"
+	"go-spring/internal/jobs"
+	"go-spring/internal/model"
+	"go-spring/internal/notify"
+	"go-spring/internal/urlsign"
+)
+
+// Digests. A user who chooses a daily or weekly digest in
+// /account/preferences is emailed a summary of the articles published
+// since their last one, those they may read, by the digest job that runs
+// every day at DIGEST_AT. Weekly digests go out on Mondays, or on the
+// first run after a Monday missed. A digest with nothing new isn't sent.
+// Each has a signed link, good for a year, that unsubscribes without
+// credentials.
+
+// Kind of the job that sends the digests that are due
+const jobDigest = "digest"
+
+// How long the unsubscribe link of a digest works
+const digestLinkTTL = 365 * 24 * time.Hour
+
+// DigestReport is the result of a digest job
+type DigestReport struct {
+	Sent     int `json:"sent"`
+	Articles int `json:"articles"` // in all of them
+}
+
+// Register the digest job
+func (app *App) initDigests(cfg config.Config) {
+	if app.jobQueue != nil {
+		app.jobQueue.Handle(jobDigest, app.runDigestJob)
+	}
+}
+
+// Send the digests that are due
+func (app *App) runDigestJob(ctx context.Context, job jobs.Job) error {
+	report, err := app.sendDigests(time.Now())
+	if err != nil {
+		return err
+	}
+	return jobs.SetResult(ctx, report)
+}
+
+// The time the latest digest of frequency was due at, at or before now:
+// today's DIGEST_AT, or yesterday's before it, and for weekly digests that
+// of the Monday of its week
+func digestDue(now time.Time, frequency string, at time.Time) time.Time {
+	due := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+	if due.After(now) {
+		due = due.AddDate(0, 0, -1)
+	}
+	if frequency == model.DigestWeekly {
+		due = due.AddDate(0, 0, -(int(due.Weekday())+6)%7)
+	}
+	return due
+}
+
+// A user's digest, to send
+type digest struct {
+	user     model.User
+	articles []model.Article
+}
+
+// Queue the email of each user whose digest is due at now, with the
+// published articles they may read that came out since their last one
+func (app *App) sendDigests(now time.Time) (DigestReport, error) {
+	var report DigestReport
+	if app.replicating() {
+		return report, nil
+	}
+	if app.mailer == nil || app.mailTemplates == nil {
+		return report, nil // email was turned off since the job was queued
+	}
+	// Without DIGEST_AT digests are due at midnight
+	at, _ := time.Parse("15:04", app.cfg.DigestAt)
+
+	published := slices.DeleteFunc(slices.Clone(app.readArticles()), func(a model.Article) bool { return !a.IsPublished() })
+	slices.SortStableFunc(published, func(a, b model.Article) int { return publishedAt(a).Compare(publishedAt(b)) })
+	var due []digest
+	for _, user := range app.Users() {
+		if user.Digest == "" || user.Email == "" || !user.DigestSent.Before(digestDue(now, user.Digest, at)) {
+			continue
+		}
+		mayRead := app.confidentialReader(user, true)
+		d := digest{user: user}
+		for _, article := range published {
+			if when := publishedAt(article); when.After(user.DigestSent) && !when.After(now) && (!article.Confidential || mayRead(article)) {
+				d.articles = append(d.articles, withoutContent(article))
+			}
+		}
+		due = append(due, d)
+	}
+	if len(due) == 0 {
+		return report, nil
+	}
+
+	// Remember what was covered before queueing, so nothing is sent twice
+	app.articlesMutex.Lock()
+	for i := range app.users {
+		if slices.ContainsFunc(due, func(d digest) bool { return d.user.Username == app.users[i].Username }) {
+			app.users[i].DigestSent = now
+		}
+	}
+	app.articlesMutex.Unlock()
+	app.scheduleSave()
+
+	for _, d := range due {
+		if len(d.articles) == 0 {
+			continue
+		}
+		n := notify.Notification{Event: notify.EventDigest, Articles: d.articles, Detail: d.user.Digest, Time: now,
+			Unsubscribe: app.digestUnsubscribePath(d.user.Username, now.Add(digestLinkTTL))}
+		msg, err := app.mailTemplates.Render(n, d.user)
+		if err != nil {
+			log.Printf("Warning: Failed to render %s email: %v", n.Event, err)
+			continue
+		}
+		app.enqueueJob(jobEmail, msg)
+		report.Sent++
+		report.Articles += len(d.articles)
+	}
+	return report, nil
+}
+
+// What an unsubscribe link grants: ending the digest of the user
+func digestResource(username string) string {
+	return "digest/" + strings.ToLower(username)
+}
+
+// The path and query of a link that unsubscribes the user until expires
+func (app *App) digestUnsubscribePath(username string, expires time.Time) string {
+	query := app.urlSigner.Sign(digestResource(username), expires.Truncate(time.Second))
+	query.Set("user", username)
+	return "/digest/unsubscribe?" + query.Encode()
+}
+
+// Check the signature of an unsubscribe link, answering 403 if it is
+// wrong or expired, and return the user it is for
+func (app *App) digestLinkUser(w http.ResponseWriter, r *http.Request) (model.User, bool) {
+	query := r.URL.Query()
+	switch err := app.urlSigner.Verify(digestResource(query.Get("user")), query, time.Now()); {
+	case errors.Is(err, urlsign.ErrExpired):
+		app.writeError(w, r, http.StatusForbidden, CodeSignedURLExpired, "Signed URL has expired")
+		return model.User{}, false
+	case err != nil:
+		app.writeError(w, r, http.StatusForbidden, CodeSignedURLInvalid, "Invalid URL signature")
+		return model.User{}, false
+	}
+	user, found := app.findUser(query.Get("user"))
+	if !found {
+		app.writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return model.User{}, false
+	}
+	return user, true
+}
+
+// The page of an unsubscribe link: a button that posts to it, since mail
+// scanners follow links and mustn't unsubscribe anyone
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width"><title>Unsubscribe</title></head>
+<body>
+{{if .Done}}<p>{{.Username}} no longer gets the digest.</p>
+{{else if .Digest}}<form method="post"><p>Stop sending {{.Username}} the {{.Digest}} digest?</p><button type="submit">Unsubscribe</button></form>
+{{else}}<p>{{.Username}} doesn't get the digest.</p>
+{{end}}</body>
+</html>
+`))
+
+func writeUnsubscribePage(w http.ResponseWriter, user model.User, done bool) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	unsubscribePage.Execute(w, struct {
+		Username, Digest string
+		Done             bool
+	}{user.Username, user.Digest, done})
+}
+
+// GET /digest/unsubscribe - The page of the unsubscribe link of a digest
+func (app *App) getDigestUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	if user, ok := app.digestLinkUser(w, r); ok {
+		writeUnsubscribePage(w, user, false)
+	}
+}
+
+// POST /digest/unsubscribe - End the digest of the user of a signed link,
+// from its page or with a mail client's one-click unsubscribe
+func (app *App) postDigestUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.digestLinkUser(w, r)
+	if !ok {
+		return
+	}
+	if _, err := app.SetPreferences(user.Username, PreferencesRequest{Digest: "off"}); err != nil {
+		app.writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+	// The button of the page, or a mail client's one-click unsubscribe
+	// (RFC 8058), posts a form
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/x-www-form-urlencoded" {
+		writeUnsubscribePage(w, user, true)
+		return
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Unsubscribed from the digest"})
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"embed"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/gorilla/mux"
+)
+
+// API explorer assets, compiled into the binary
+//
+//go:embed static/docs
+var docsAssets embed.FS
+
+// GET /docs and /docs/{asset} - Interactive API explorer backed by /openapi.json
+func serveDocs(w http.ResponseWriter, r *http.Request) {
+	asset := mux.Vars(r)["asset"]
+	if asset == "" {
+		asset = "index.html"
+	}
+
+	serveAsset(w, r, docsAssets, "static/docs", asset)
+}
+
+// Write a file of an embedded directory, with the type of its extension
+func serveAsset(w http.ResponseWriter, r *http.Request, assets fs.FS, dir, asset string) {
+	data, err := fs.ReadFile(assets, path.Join(dir, path.Clean("/"+asset)))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch path.Ext(asset) {
+	case ".html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	case ".css":
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	case ".js":
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	default:
+		if t := mime.TypeByExtension(path.Ext(asset)); t != "" {
+			w.Header().Set("Content-Type", t)
+		}
+	}
+	w.Write(data)
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-spring/internal/config"
+	"go-spring/internal/notify"
+)
+
+// With ELECTION_URL the primary and its replicas elect the primary through
+// a lease in Redis: the node holding it takes writes and streams its change
+// feed, the others follow the holder's URL as replicas. The leader renews
+// the lease every third of ELECTION_TTL. When it dies the lease expires, the
+// first replica to take the lease over is promoted and announces the
+// failover.
+
+// leaderLease is the lock the nodes compete for
+type leaderLease interface {
+	// Acquire takes the lease for holder if it is free and returns who
+	// holds it, "" if nobody
+	Acquire(holder string, ttl time.Duration) (string, error)
+	// Renew extends the lease if holder still has it
+	Renew(holder string, ttl time.Duration) (bool, error)
+	// Release gives up the lease if holder has it
+	Release(holder string) error
+}
+
+type electionState struct {
+	electionMutex sync.Mutex
+	electionLease leaderLease // nil without ELECTION_URL
+	leaderURL     string      // the holder as last seen, ELECTION_URL while leading
+	leaderRenewed time.Time   // when this node last got or renewed the lease
+}
+
+// Set up the election from ELECTION_URL
+func (app *App) initElection(cfg config.Config) error {
+	app.electionMutex.Lock()
+	defer app.electionMutex.Unlock()
+	app.electionLease, app.leaderURL, app.leaderRenewed = nil, "", time.Time{}
+	if cfg.ElectionURL == "" {
+		return nil
+	}
+	if cfg.ReplicaOf != "" {
+		return errors.New("set either ELECTION_URL or REPLICA_OF: elected replicas follow the leader")
+	}
+	client, err := newRedisClient(cfg.RedisURL)
+	if err != nil {
+		return err
+	}
+	app.electionLease = redisLease{client: client, key: cfg.ElectionKey}
+	return nil
+}
+
+// Whether the primary is elected
+func (app *App) electing() bool {
+	return app.electionLease != nil
+}
+
+// Whether this node won the election and its lease is still good. The
+// leader stops taking writes a margin before the lease would run out, as
+// its clock and Redis's may disagree and the lease may already have
+// expired by then.
+func (app *App) electedLeader() bool {
+	app.electionMutex.Lock()
+	defer app.electionMutex.Unlock()
+	return app.leaderURL != "" && app.leaderURL == app.cfg.ElectionURL &&
+		time.Since(app.leaderRenewed) < app.cfg.ElectionTTL-leaseMargin(app.cfg.ElectionTTL)
+}
+
+// How long before the lease ends the leader stops taking writes: a sixth of
+// ELECTION_TTL, so a leader renewing every third of it is never near
+func leaseMargin(ttl time.Duration) time.Duration {
+	return ttl / 6
+}
+
+// The elected primary, "" while none is known
+func (app *App) electedPrimary() string {
+	app.electionMutex.Lock()
+	defer app.electionMutex.Unlock()
+	return app.leaderURL
+}
+
+// Campaign every third of ELECTION_TTL until ctx is done, then hand the
+// lease over
+func (app *App) runElection(ctx context.Context) {
+	ticker := time.NewTicker(app.cfg.ElectionTTL / 3)
+	defer ticker.Stop()
+	for {
+		app.campaign(time.Now())
+		select {
+		case <-ctx.Done():
+			app.resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// One round of the election: the leader renews its lease, the others try
+// to take it
+func (app *App) campaign(now time.Time) {
+	self := app.cfg.ElectionURL
+	app.electionMutex.Lock()
+	previous, renewed := app.leaderURL, app.leaderRenewed
+	app.electionMutex.Unlock()
+
+	leader := previous
+	if previous == self {
+		ok, err := app.electionLease.Renew(self, app.cfg.ElectionTTL)
+		switch {
+		case ok:
+			renewed = now
+		case err == nil:
+			leader = "" // another node has taken it
+		case now.Sub(renewed) >= app.cfg.ElectionTTL:
+			// Another node may have it by now; stop taking writes
+			log.Printf("Warning: Renewing the leader lease: %v", err)
+			leader = ""
+		default:
+			log.Printf("Warning: Renewing the leader lease: %v", err)
+		}
+	} else {
+		holder, err := app.electionLease.Acquire(self, app.cfg.ElectionTTL)
+		if err != nil {
+			log.Printf("Warning: Leader election: %v", err)
+			return // keep following the last known leader
+		}
+		leader = holder
+		if holder == self {
+			renewed = now
+		}
+	}
+
+	app.electionMutex.Lock()
+	app.leaderURL, app.leaderRenewed = leader, renewed
+	app.electionMutex.Unlock()
+	if leader != previous {
+		app.leaderChanged(previous, leader)
+	}
+}
+
+func (app *App) leaderChanged(previous, leader string) {
+	self := app.cfg.ElectionURL
+	switch {
+	case leader == self:
+		log.Printf("Elected primary")
+		// A replica taking over from another node is a failover
+		if previous != "" {
+			app.Notify(context.Background(), notify.Notification{
+				Event: notify.EventFailover, Detail: redactedURL(self),
+				Error: fmt.Sprintf("previous primary %s", redactedURL(previous)),
+			})
+		}
+	case previous == self:
+		log.Printf("Warning: Lost the leader lease, following as a replica")
+	case leader != "":
+		log.Printf("Following primary %s", redactedURL(leader))
+	}
+	app.restartReplication()
+}
+
+// Give up the lease, so that a replica takes over without waiting for it
+// to expire
+func (app *App) resign() {
+	if !app.electedLeader() {
+		return
+	}
+	if err := app.electionLease.Release(app.cfg.ElectionURL); err != nil {
+		log.Printf("Warning: Releasing the leader lease: %v", err)
+	}
+	app.electionMutex.Lock()
+	app.leaderURL = ""
+	app.electionMutex.Unlock()
+}
+
+// redisLease is a leaderLease in Redis: a key holding the leader's URL,
+// with the lease duration as its expiry
+type redisLease struct {
+	client *redisClient
+	key    string
+}
+
+// Compare and extend, or delete, in one step
+const (
+	renewLeaseScript   = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) end return 0`
+	releaseLeaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) end return 0`
+)
+
+func (l redisLease) Acquire(holder string, ttl time.Duration) (string, error) {
+	reply, err := l.client.Do("SET", l.key, holder, "NX", "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return "", err
+	}
+	if reply == "OK" {
+		return holder, nil
+	}
+	reply, err = l.client.Do("GET", l.key)
+	leader, _ := reply.(string) // nil if it expired in between
+	return leader, err
+}
+
+func (l redisLease) Renew(holder string, ttl time.Duration) (bool, error) {
+	reply, err := l.client.Do("EVAL", renewLeaseScript, "1", l.key, holder, strconv.FormatInt(ttl.Milliseconds(), 10))
+	return reply == int64(1), err
+}
+
+func (l redisLease) Release(holder string) error {
+	_, err := l.client.Do("EVAL", releaseLeaseScript, "1", l.key, holder)
+	return err
+}
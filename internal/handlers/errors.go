@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+// Error codes are the stable, machine-readable part of an error payload
+// ("code"); the client package exports the same constants. The message
+// next to a code may change, the code won't. Errors without a code of
+// their own, e.g. from plugins calling http.Error, get the status text in
+// upper snake case (NOT_FOUND, BAD_REQUEST).
+const (
+	CodeBadRequest       = "BAD_REQUEST"
+	CodeValidationFailed = "VALIDATION_FAILED"
+	CodeInvalidBody      = "INVALID_BODY"      // not decodable in its Content-Type
+	CodeInvalidID        = "INVALID_ID"        // a path ID that isn't a number
+	CodeInvalidParameter = "INVALID_PARAMETER" // a query parameter
+	CodeInvalidURL       = "INVALID_URL"       // an external URL that can't be fetched from
+	CodeInvalidFile      = "INVALID_FILE"      // an upload or import file that can't be read
+	CodeFileTooLarge     = "FILE_TOO_LARGE"
+	CodeUnsupportedType  = "UNSUPPORTED_MEDIA_TYPE"
+	CodeUnreadablePage   = "UNREADABLE_PAGE" // an imported page with no article in it
+	CodeImageFailed      = "IMAGE_PROCESSING_FAILED"
+	CodeChecksumMismatch = "CHECKSUM_MISMATCH" // an upload that isn't what the client said it sent
+
+	CodeAuthenticationRequired = "AUTHENTICATION_REQUIRED"
+	CodeInvalidCredentials     = "INVALID_CREDENTIALS"
+	CodeInvalidSignature       = "INVALID_SIGNATURE" // a signed request with an unknown or expired key, wrong signature or stale timestamp
+	CodeForbidden              = "FORBIDDEN"
+	CodeCaptchaRequired        = "CAPTCHA_REQUIRED" // a route that needs a CAPTCHA token without credentials
+	CodeCaptchaInvalid         = "CAPTCHA_INVALID"
+	CodeSignedURLExpired       = "SIGNED_URL_EXPIRED" // a signed attachment URL past its expiry
+	CodeSignedURLInvalid       = "SIGNED_URL_INVALID"
+
+	CodeNotFound                 = "NOT_FOUND" // a route, or a page of a feed
+	CodeArticleNotFound          = "ARTICLE_NOT_FOUND"
+	CodeAttachmentNotFound       = "ATTACHMENT_NOT_FOUND"
+	CodeUploadNotFound           = "UPLOAD_NOT_FOUND" // unknown or expired
+	CodeJobNotFound              = "JOB_NOT_FOUND"
+	CodeUserNotFound             = "USER_NOT_FOUND"
+	CodeWorkspaceNotFound        = "WORKSPACE_NOT_FOUND"
+	CodeTranslationNotFound      = "TRANSLATION_NOT_FOUND"
+	CodeSavedSearchNotFound      = "SAVED_SEARCH_NOT_FOUND"
+	CodePushSubscriptionNotFound = "PUSH_SUBSCRIPTION_NOT_FOUND"
+	CodeMethodNotAllowed         = "METHOD_NOT_ALLOWED"
+
+	CodeConflict            = "CONFLICT" // the resource isn't in a state that allows the request
+	CodeRequestInProgress   = "REQUEST_IN_PROGRESS"
+	CodeRateLimited         = "RATE_LIMITED"
+	CodeQuotaExceeded       = "QUOTA_EXCEEDED"
+	CodeRangeNotSatisfiable = "RANGE_NOT_SATISFIABLE" // a Range header past the end
+
+	CodeInternal       = "INTERNAL_SERVER_ERROR"
+	CodeUpstreamFailed = "UPSTREAM_FAILED" // fetching an external URL failed
+	CodeUnavailable    = "SERVICE_UNAVAILABLE"
+	CodeStoreTimeout   = "STORE_TIMEOUT" // the data or blob store didn't answer in time
+)
+
+// The code of an error that doesn't name one
+func statusCode(status int) string {
+	return strings.ToUpper(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+}
+
+// Write an error payload in the representation the client asked for: the
+// status text as message, the message as error, and the code
+func (app *App) writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	app.writeErrorResponse(w, r, status, Response{Message: http.StatusText(status), Error: message, Code: code})
+}
+
+// Write a 400 for invalid input, with the invalid fields if any
+func (app *App) writeValidationError(w http.ResponseWriter, r *http.Request, err *ValidationError) {
+	app.writeErrorResponse(w, r, http.StatusBadRequest, Response{Message: "Validation failed", Error: err.Message, Code: CodeValidationFailed, Fields: err.Fields})
+}
+
+func (app *App) writeErrorResponse(w http.ResponseWriter, r *http.Request, status int, response Response) {
+	w.Header().Del("Content-Length")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, ok := negotiateCodec(r).(jsonAPICodec); ok {
+		app.localize(w, r, &response)
+		writeJSONAPIError(w, status, response)
+		return
+	}
+	app.writeResponse(w, r, status, response)
+}
+
+// errorPayloads rewrites plain-text error responses (from http.Error) into
+// error payloads, so that every error has a code
+func (app *App) errorPayloads(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ew := &errorWriter{ResponseWriter: w}
+		next.ServeHTTP(ew, r)
+		if ew.status != 0 {
+			app.writeError(ew.ResponseWriter, r, ew.status, statusCode(ew.status), strings.TrimSpace(ew.body.String()))
+		}
+	})
+}
+
+type errorWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (ew *errorWriter) WriteHeader(status int) {
+	if status >= 400 && strings.HasPrefix(ew.Header().Get("Content-Type"), "text/plain") {
+		ew.status = status // captured, rewritten when the handler returns
+		return
+	}
+	ew.ResponseWriter.WriteHeader(status)
+}
+
+func (ew *errorWriter) Write(b []byte) (int, error) {
+	if ew.status != 0 {
+		return ew.body.Write(b)
+	}
+	return ew.ResponseWriter.Write(b)
+}
+
+func (ew *errorWriter) Flush() {
+	if f, ok := ew.ResponseWriter.(http.Flusher); ok && ew.status == 0 {
+		f.Flush()
+	}
+}
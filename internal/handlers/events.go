@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go-spring/internal/model"
+)
+
+// Article event types
+const (
+	EventArticleCreated = "article.created"
+	EventArticleUpdated = "article.updated"
+	EventArticleDeleted = "article.deleted"
+)
+
+// ArticleEvent describes a change to an article
+type ArticleEvent struct {
+	Type    string        `json:"type" proto:"1"`
+	Article model.Article `json:"article" proto:"2"`
+	Time    time.Time     `json:"time" proto:"3"`
+
+	// Username of who made the change, if known; kept from subscribers
+	Actor string `json:"-"`
+}
+
+// EventBus fans out events, such as article changes, to in-process
+// subscribers. Publishing never blocks: a subscriber that falls behind
+// misses events rather than stalling the writer (which usually holds
+// articlesMutex).
+type EventBus[E any] struct {
+	mu        sync.RWMutex
+	subs      map[int]chan E
+	listeners map[int]func(E)
+	nextID    int
+}
+
+func NewEventBus[E any]() *EventBus[E] {
+	return &EventBus[E]{subs: map[int]chan E{}, listeners: map[int]func(E){}}
+}
+
+type eventsState struct {
+	events *EventBus[ArticleEvent]
+}
+
+// Subscribe returns a channel of events and a function to unsubscribe
+func (b *EventBus[E]) Subscribe(buffer int) (<-chan E, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	ch := make(chan E, buffer)
+	b.subs[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subs, id)
+			close(ch)
+		})
+	}
+}
+
+// Listen registers fn to be called synchronously on every Publish, for
+// consumers such as caches that must never miss an event. fn runs on the
+// writer's goroutine and must not block. The returned function removes it.
+func (b *EventBus[E]) Listen(fn func(E)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.listeners[id] = fn
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.listeners, id)
+	}
+}
+
+func (b *EventBus[E]) Publish(event E) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, fn := range b.listeners {
+		fn(event)
+	}
+
+	for _, ch := range b.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Publish a change to an article on the global bus, made by the user in
+// ctx if there is one
+func (app *App) publishArticleEvent(ctx context.Context, eventType string, article model.Article) {
+	user, _ := ctx.Value(userKey{}).(model.User)
+	app.events.Publish(ArticleEvent{Type: eventType, Article: article, Time: time.Now(), Actor: user.Username})
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"go-spring/internal/jobs"
+	"go-spring/internal/model"
+)
+
+// Articles are copied out of the store in batches so an export never holds
+// the lock for long or keeps more than one batch in memory
+const ExportBatchSize = 500
+
+// GET /articles/export.ndjson - Stream every article as one JSON object per line
+func (app *App) exportArticlesNDJSON(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="articles.ndjson"`)
+
+	flusher, _ := w.(http.Flusher)
+	app.WriteArticlesNDJSON(r.Context(), w, func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	})
+}
+
+// Write every article as NDJSON, calling flush after each batch
+func (app *App) WriteArticlesNDJSON(ctx context.Context, w io.Writer, flush func()) error {
+	enc := json.NewEncoder(w) // Encode terminates each value with a newline
+	afterID := 0
+	for {
+		batch := app.articlesAfter(afterID, ExportBatchSize, nil)
+		for _, article := range batch {
+			if err := enc.Encode(article); err != nil {
+				return err // client went away
+			}
+		}
+		if len(batch) < ExportBatchSize {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		afterID = batch[len(batch)-1].ID
+		flush()
+	}
+}
+
+// ExportManifest is manifest.json, the last entry of the export archive
+type ExportManifest struct {
+	Format      string       `json:"format"`
+	Version     int          `json:"version"`
+	Created     time.Time    `json:"created"`
+	Articles    int          `json:"articles"`
+	Attachments int          `json:"attachments"`
+	Files       []ExportFile `json:"files"`
+	Missing     []string     `json:"missing,omitempty"` // attachments whose blob could not be read
+}
+
+type ExportFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ExportMetadata is metadata.json: store counters needed to restore a backup
+type ExportMetadata struct {
+	NextID           int            `json:"next_id"`
+	NextAttachmentID int            `json:"next_attachment_id"`
+	Statuses         map[string]int `json:"statuses"` // article count per status
+}
+
+// GET /admin/export.zip - Download everything as one zip archive
+//
+// The archive holds articles.json (all articles, with attachment and cover
+// metadata), every attachment as attachments/{article}/{attachment}/{name},
+// metadata.json and finally manifest.json listing each file with its size
+// and SHA-256. The store keeps no revision history, so articles are exported
+// as they are now. Everything is streamed; nothing is staged on disk.
+func (app *App) exportArchive(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UTC()
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, ExportArchiveName(now)))
+
+	if err := app.WriteExportArchive(r.Context(), w, now); err != nil {
+		// Headers are long gone; the client sees a truncated archive
+		log.Printf("Warning: export archive failed: %v", err)
+	}
+}
+
+func ExportArchiveName(t time.Time) string {
+	return "go-spring-export-" + t.Format("20060102-150405") + ".zip"
+}
+
+// Write the export archive described at exportArchive
+func (app *App) WriteExportArchive(ctx context.Context, w io.Writer, now time.Time) error {
+	z := newExportZip(w, now, "go-spring-export")
+	metadata := ExportMetadata{Statuses: map[string]int{}}
+
+	var attachments []model.Attachment
+	var attachmentPaths []string
+	err := z.add("articles.json", func(out io.Writer) error {
+		io.WriteString(out, "[")
+		afterID := 0
+		for {
+			batch := app.articlesAfter(afterID, ExportBatchSize, nil)
+			for _, article := range batch {
+				if z.manifest.Articles > 0 {
+					io.WriteString(out, ",")
+				}
+				io.WriteString(out, "\n")
+				data, err := json.Marshal(article)
+				if err != nil {
+					return err
+				}
+				if _, err := out.Write(data); err != nil {
+					return err
+				}
+				z.manifest.Articles++
+				metadata.Statuses[article.Status]++
+				for _, a := range article.Attachments {
+					attachments = append(attachments, a)
+					attachmentPaths = append(attachmentPaths, fmt.Sprintf("attachments/%d/%d/%s", article.ID, a.ID, a.Filename))
+				}
+			}
+			if len(batch) < ExportBatchSize {
+				break
+			}
+			afterID = batch[len(batch)-1].ID
+		}
+		_, err := io.WriteString(out, "\n]\n")
+		return err
+	})
+	if err == nil {
+		err = app.addAttachments(ctx, z, attachments, attachmentPaths)
+	}
+	if err == nil {
+		metadata.NextID, metadata.NextAttachmentID = app.articleIDs.Peek(), app.attachmentIDs.Peek()
+		err = z.add("metadata.json", func(out io.Writer) error {
+			return writeIndentedJSON(out, metadata)
+		})
+	}
+	if err == nil {
+		err = z.close()
+	}
+	return err
+}
+
+// exportZip writes the entries of an archive and the manifest that lists
+// them
+type exportZip struct {
+	zw       *zip.Writer
+	now      time.Time
+	manifest *ExportManifest
+}
+
+func newExportZip(w io.Writer, now time.Time, format string) exportZip {
+	manifest := &ExportManifest{Format: format, Version: 1, Created: now, Files: []ExportFile{}}
+	return exportZip{zw: zip.NewWriter(w), now: now, manifest: manifest}
+}
+
+// Write one archive entry, recording its size and checksum
+func (z exportZip) add(name string, write func(io.Writer) error) error {
+	fw, err := z.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: z.now})
+	if err != nil {
+		return err
+	}
+	sum := sha256.New()
+	cw := &countingWriter{w: io.MultiWriter(fw, sum)}
+	if err := write(cw); err != nil {
+		return err
+	}
+	z.manifest.Files = append(z.manifest.Files, ExportFile{name, cw.n, hex.EncodeToString(sum.Sum(nil))})
+	return nil
+}
+
+// Copy the files of attachments to paths, listing those that can't be read
+// as missing
+func (app *App) addAttachments(ctx context.Context, z exportZip, attachments []model.Attachment, paths []string) error {
+	for i, a := range attachments {
+		jobs.ReportProgress(ctx, i, len(attachments))
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		blob, err := app.getBlob(ctx, a.Key)
+		if err != nil {
+			log.Printf("Warning: export skipped attachment %d: %v", a.ID, err)
+			z.manifest.Missing = append(z.manifest.Missing, paths[i])
+			continue
+		}
+		err = z.add(paths[i], func(out io.Writer) error {
+			_, err := io.Copy(out, blob)
+			return err
+		})
+		blob.Close()
+		if err != nil {
+			return err
+		}
+		z.manifest.Attachments++
+	}
+	return nil
+}
+
+// Write the manifest and finish the archive. The manifest describes the
+// files before it, so it isn't listed itself.
+func (z exportZip) close() error {
+	fw, err := z.zw.CreateHeader(&zip.FileHeader{Name: "manifest.json", Method: zip.Deflate, Modified: z.now})
+	if err == nil {
+		err = writeIndentedJSON(fw, z.manifest)
+	}
+	if err == nil {
+		err = z.zw.Close()
+	}
+	return err
+}
+
+func writeIndentedJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go-spring/internal/model"
+)
+
+// Articles 1 to n, put straight into the store
+func fillArticles(srv *testServer, n int) {
+	articles := make([]model.Article, n)
+	for i := range articles {
+		articles[i] = model.Article{ID: i + 1, Title: fmt.Sprintf("Article %d", i+1), Desc: "d", Content: "c",
+			Status: model.StatusPublished, Created: time.Now(), Updated: time.Now(), Published: time.Now()}
+	}
+	srv.articlesMutex.Lock()
+	srv.articles = articles
+	srv.articlesMutex.Unlock()
+}
+
+func TestExportNDJSON(t *testing.T) {
+	srv := newTestServer(t, 0)
+	n := 2*ExportBatchSize + 1
+	fillArticles(srv, n)
+
+	resp, err := http.Get(srv.URL + "/articles/export.ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || ct != "application/x-ndjson" ||
+		!strings.Contains(resp.Header.Get("Content-Disposition"), "articles.ndjson") {
+		t.Fatalf("status %d, %s, %s", resp.StatusCode, ct, resp.Header.Get("Content-Disposition"))
+	}
+	// One article a line, in ID order, across the batches
+	lines := bufio.NewScanner(resp.Body)
+	count := 0
+	for lines.Scan() {
+		var article model.Article
+		if err := json.Unmarshal(lines.Bytes(), &article); err != nil {
+			t.Fatalf("line %d: %v in %s", count+1, err, lines.Bytes())
+		}
+		if count++; article.ID != count {
+			t.Fatalf("line %d has article %d", count, article.ID)
+		}
+	}
+	if count != n {
+		t.Errorf("%d lines, want %d", count, n)
+	}
+
+	// Each full batch is flushed as it's written, and a canceled export
+	// stops at the end of a batch
+	var out strings.Builder
+	flushed := 0
+	if err := srv.WriteArticlesNDJSON(t.Context(), &out, func() { flushed++ }); err != nil || flushed != 2 || strings.Count(out.String(), "\n") != n {
+		t.Errorf("flushed %d times, %d lines, %v", flushed, strings.Count(out.String(), "\n"), err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	out.Reset()
+	err = srv.WriteArticlesNDJSON(ctx, &out, cancel)
+	if err != context.Canceled || strings.Count(out.String(), "\n") != 2*ExportBatchSize {
+		t.Errorf("canceled after %d lines: %v", strings.Count(out.String(), "\n"), err)
+	}
+
+	// An empty store is an empty export
+	fillArticles(srv, 0)
+	out.Reset()
+	if err := srv.WriteArticlesNDJSON(t.Context(), &out, func() {}); err != nil || out.Len() != 0 {
+		t.Errorf("empty export %q, %v", out.String(), err)
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"go-spring/internal/model"
+)
+
+// FeaturedOrderRequest is the body of PUT /articles/featured/order
+type FeaturedOrderRequest struct {
+	IDs []model.ArticleID `json:"ids"`
+}
+
+// Sort featured articles by their explicit position, unordered ones last (newest first)
+func sortFeatured(list []model.Article) {
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.FeaturedOrder != b.FeaturedOrder {
+			if a.FeaturedOrder == 0 {
+				return false
+			}
+			if b.FeaturedOrder == 0 {
+				return true
+			}
+			return a.FeaturedOrder < b.FeaturedOrder
+		}
+		return a.Created.After(b.Created)
+	})
+}
+
+// GET /articles/featured - Get featured articles in curated order
+func (app *App) getFeaturedArticles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	featured := []model.Article{}
+	for _, article := range app.readArticles() {
+		if article.Featured {
+			featured = append(featured, article)
+		}
+	}
+	sortFeatured(featured)
+
+	response := Response{
+		Message: "Featured articles retrieved successfully",
+		Data:    withholdContent(app.withoutHeld(r, featured), app.requestReader(r)),
+	}
+
+	app.writeResponse(w, r, http.StatusOK, response)
+}
+
+// PUT /articles/featured/order - Replace the featured list with the given IDs, in order.
+// The list spans the workspaces, and unlisted articles are un-featured in
+// all of them, so the route is for admins.
+func (app *App) setFeaturedOrder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req FeaturedOrderRequest
+	if err := decodeBody(r, &req); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+
+	// Validate everything before touching any article
+	positions := make(map[int]int, len(req.IDs))
+	for i, id := range req.IDs {
+		if _, dup := positions[int(id)]; dup {
+			app.writeError(w, r, http.StatusBadRequest, CodeValidationFailed, "Duplicate article ID in order")
+			return
+		}
+		positions[int(id)] = i + 1
+	}
+	found := 0
+	for _, article := range app.articles {
+		if _, ok := positions[article.ID]; ok {
+			found++
+		}
+	}
+	if found != len(positions) {
+		app.writeError(w, r, http.StatusNotFound, CodeArticleNotFound, "Article not found")
+		return
+	}
+
+	featured := []model.Article{}
+	for i := range app.articles {
+		before := app.articles[i]
+		if pos, ok := positions[app.articles[i].ID]; ok {
+			app.articles[i].Featured = true
+			app.articles[i].FeaturedOrder = pos
+			featured = append(featured, app.articles[i])
+		} else {
+			app.articles[i].Featured = false
+			app.articles[i].FeaturedOrder = 0
+		}
+		if app.articles[i].Featured != before.Featured || app.articles[i].FeaturedOrder != before.FeaturedOrder {
+			app.publishArticleEvent(r.Context(), EventArticleUpdated, app.articles[i])
+		}
+	}
+	sortFeatured(featured)
+
+	// Save to file
+	app.scheduleSave()
+
+	response := Response{
+		Message: "Featured order updated successfully",
+		Data:    featured,
+	}
+	app.writeResponse(w, r, http.StatusOK, response)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go-spring/internal/model"
+)
+
+// The featured list spans the workspaces, so only admins set it
+func TestFeaturedOrderNeedsAdmin(t *testing.T) {
+	srv := newTestServer(t, 0)
+	srv.AddUser("alice", "correct horse", model.RoleAdmin)
+	srv.AddUser("bob", "battery staple", model.RoleEditor)
+	as := func(user, password string) string {
+		return strings.Replace(srv.URL, "://", "://"+user+":"+strings.ReplaceAll(password, " ", "%20")+"@", 1)
+	}
+	bob, alice := as("bob", "battery staple"), as("alice", "correct horse")
+
+	// bob owns a workspace, and its article is featured by its editors
+	var ws model.Workspace
+	call(t, "POST", bob+"/workspaces", `{"name":"blog","default_status":"published"}`, &ws)
+	var article model.Article
+	call(t, "POST", bob+"/workspaces/"+ws.Slug+"/articles", `{"title":"Ours","desc":"First","content":"Some text"}`, &article)
+	if resp := call(t, "PUT", fmt.Sprintf("%s/articles/%d", bob, article.ID), `{"title":"Ours","desc":"First","content":"Some text","featured":true}`, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("feature own article: status %d", resp.StatusCode)
+	}
+
+	// Setting the order would un-feature it, or another workspace's articles
+	order := fmt.Sprintf(`{"ids":[%d]}`, article.ID)
+	for url, want := range map[string]int{srv.URL: http.StatusUnauthorized, bob: http.StatusForbidden, alice: http.StatusOK} {
+		if resp := call(t, "PUT", url+"/articles/featured/order", order, nil); resp.StatusCode != want {
+			t.Errorf("%s: status %d, want %d", url, resp.StatusCode, want)
+		}
+	}
+	call(t, "PUT", bob+"/articles/featured/order", `{"ids":[]}`, nil)
+	var featured []model.Article
+	if call(t, "GET", srv.URL+"/articles/featured", "", &featured); len(featured) != 1 || featured[0].ID != article.ID {
+		t.Errorf("after an editor's order: %+v", featured)
+	}
+}
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"go-spring/internal/model"
+)
+
+// RSS 2.0 document types
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Generator     string    `xml:"generator"`
+	SelfLink      rssLink   `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+// Published articles of the workspace, or of every workspace if ws is
+// empty, newest first. Feeds are public, so confidential articles are left
+// out once there are users.
+func (app *App) publishedArticles(ws string) []model.Article {
+	list := []model.Article{}
+	open := !app.hasUsers()
+	for _, article := range app.readArticles() {
+		if article.IsPublished() && (ws == "" || article.Workspace == ws) && (open || !article.Confidential) {
+			list = append(list, article)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return publishedAt(list[i]).After(publishedAt(list[j]))
+	})
+	return list
+}
+
+// Publication time, falling back to creation for articles stored before statuses existed
+func publishedAt(article model.Article) time.Time {
+	if !article.Published.IsZero() {
+		return article.Published
+	}
+	return article.Created
+}
+
+// Absolute base URL for links: PUBLIC_URL if configured, otherwise the request host
+func (app *App) publicBaseURL(r *http.Request) string {
+	if app.cfg.PublicURL != "" {
+		return strings.TrimRight(app.cfg.PublicURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func articleURL(base string, article model.Article) string {
+	return base + "/articles/" + article.PublicID()
+}
+
+// feedSource is what a feed is made of: channel metadata and the published
+// articles, newest first
+type feedSource struct {
+	Title, Description, Language string
+	Link                         string // the site the feed is of
+	Path                         string // of the feed, without the extension
+	Articles                     []model.Article
+}
+
+// The feed of the whole site, from the FEED_* settings
+func (app *App) siteFeed(r *http.Request) feedSource {
+	base := app.publicBaseURL(r)
+	return feedSource{
+		Title:       app.cfg.FeedTitle,
+		Description: app.cfg.FeedDescription,
+		Language:    app.cfg.FeedLanguage,
+		Link:        firstNonEmpty(app.cfg.FeedLink, base),
+		Path:        "/feed",
+		Articles:    app.publishedArticles(""),
+	}
+}
+
+// The feed of the {ws} workspace, falling back to the FEED_* settings for
+// metadata the workspace doesn't set
+func (app *App) workspaceFeed(w http.ResponseWriter, r *http.Request) (feedSource, bool) {
+	ws, ok := app.findWorkspace(mux.Vars(r)["ws"])
+	if !ok {
+		app.writeError(w, r, http.StatusNotFound, CodeWorkspaceNotFound, "Workspace not found")
+		return feedSource{}, false
+	}
+	base := app.publicBaseURL(r)
+	return feedSource{
+		Title:       firstNonEmpty(ws.FeedTitle, ws.Name),
+		Description: firstNonEmpty(ws.FeedDescription, ws.Description, app.cfg.FeedDescription),
+		Language:    firstNonEmpty(ws.FeedLanguage, app.cfg.FeedLanguage),
+		Link:        base + "/workspaces/" + ws.Slug + "/articles",
+		Path:        "/workspaces/" + ws.Slug + "/feed",
+		Articles:    app.publishedArticles(ws.Slug),
+	}, true
+}
+
+// GET /feed.rss - RSS 2.0 feed of the latest published articles
+func (app *App) getRSSFeed(w http.ResponseWriter, r *http.Request) {
+	app.writeRSSFeed(w, r, app.siteFeed(r))
+}
+
+// GET /workspaces/{ws}/feed.rss - RSS 2.0 feed of a workspace
+func (app *App) getWorkspaceRSSFeed(w http.ResponseWriter, r *http.Request) {
+	if src, ok := app.workspaceFeed(w, r); ok {
+		app.writeRSSFeed(w, r, src)
+	}
+}
+
+func (app *App) writeRSSFeed(w http.ResponseWriter, r *http.Request, src feedSource) {
+	base := app.publicBaseURL(r)
+	list := src.Articles
+	if len(list) > app.cfg.FeedCount {
+		list = list[:app.cfg.FeedCount]
+	}
+
+	feed := rssFeed{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       src.Title,
+			Link:        src.Link,
+			Description: src.Description,
+			Language:    src.Language,
+			Generator:   "go-spring",
+			SelfLink:    rssLink{Href: base + src.Path + ".rss", Rel: "self", Type: "application/rss+xml"},
+			Items:       []rssItem{},
+		},
+	}
+	if len(list) > 0 {
+		feed.Channel.LastBuildDate = publishedAt(list[0]).Format(time.RFC1123Z)
+	}
+
+	for _, article := range list {
+		link := articleURL(base, article)
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       article.Title,
+			Link:        link,
+			Description: article.Desc,
+			GUID:        rssGUID{Value: link, IsPermaLink: true},
+			PubDate:     publishedAt(article).Format(time.RFC1123Z),
+		})
+	}
+
+	writeXML(w, "application/rss+xml; charset=utf-8", feed)
+}
+
+func writeXML(w http.ResponseWriter, contentType string, v interface{}) {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, "Failed to encode feed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(xml.Header))
+	w.Write(out)
+}
+
+// Atom (RFC 4287) document types
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+	Summary   string     `xml:"summary,omitempty"`
+	Links     []atomLink `xml:"link"`
+}
+
+// GET /feed.atom?page=N - Atom feed of published articles, paged newest first
+func (app *App) getAtomFeed(w http.ResponseWriter, r *http.Request) {
+	app.writeAtomFeed(w, r, app.siteFeed(r))
+}
+
+// GET /workspaces/{ws}/feed.atom?page=N - Atom feed of a workspace
+func (app *App) getWorkspaceAtomFeed(w http.ResponseWriter, r *http.Request) {
+	if src, ok := app.workspaceFeed(w, r); ok {
+		app.writeAtomFeed(w, r, src)
+	}
+}
+
+func (app *App) writeAtomFeed(w http.ResponseWriter, r *http.Request, src feedSource) {
+	base := app.publicBaseURL(r)
+	list := src.Articles
+
+	page := 1
+	if v := r.URL.Query().Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid page")
+			return
+		}
+		page = n
+	}
+	size := app.cfg.FeedCount
+	pages := max((len(list)+size-1)/size, 1)
+	if page > pages {
+		app.writeError(w, r, http.StatusNotFound, CodeNotFound, "Page not found")
+		return
+	}
+	start := (page - 1) * size
+	entries := list[start:min(start+size, len(list))]
+
+	pageURL := func(n int) string {
+		if n == 1 {
+			return base + src.Path + ".atom"
+		}
+		return fmt.Sprintf("%s%s.atom?page=%d", base, src.Path, n)
+	}
+
+	feed := atomFeed{
+		ID:      base + src.Path + ".atom",
+		Title:   src.Title,
+		Author:  atomPerson{Name: src.Title},
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: pageURL(page), Rel: "self", Type: "application/atom+xml"},
+			{Href: src.Link, Rel: "alternate"},
+			{Href: pageURL(1), Rel: "first"},
+			{Href: pageURL(pages), Rel: "last"},
+		},
+		Entries: []atomEntry{},
+	}
+	if page > 1 {
+		feed.Links = append(feed.Links, atomLink{Href: pageURL(page - 1), Rel: "previous"})
+	}
+	if page < pages {
+		feed.Links = append(feed.Links, atomLink{Href: pageURL(page + 1), Rel: "next"})
+	}
+
+	// The feed is as fresh as its most recently changed entry
+	var latest time.Time
+	for _, article := range entries {
+		link := articleURL(base, article)
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:        link,
+			Title:     article.Title,
+			Updated:   article.Updated.UTC().Format(time.RFC3339),
+			Published: publishedAt(article).UTC().Format(time.RFC3339),
+			Summary:   article.Desc,
+			Links:     []atomLink{{Href: link, Rel: "alternate"}},
+		})
+		if article.Updated.After(latest) {
+			latest = article.Updated
+		}
+	}
+	if !latest.IsZero() {
+		feed.Updated = latest.UTC().Format(time.RFC3339)
+	}
+
+	writeXML(w, "application/atom+xml; charset=utf-8", feed)
+}
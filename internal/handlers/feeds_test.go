@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"go-spring/internal/model"
+)
+
+// Get an Atom feed page, answering the status and the decoded feed
+func getAtom(t *testing.T, url string) (int, atomFeed) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var feed atomFeed
+	if resp.StatusCode == http.StatusOK {
+		if ct := resp.Header.Get("Content-Type"); ct != "application/atom+xml; charset=utf-8" {
+			t.Errorf("%s: content type %s", url, ct)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		if err := xml.Unmarshal(data, &feed); err != nil {
+			t.Fatalf("%s: %v in %s", url, err, data)
+		}
+	}
+	return resp.StatusCode, feed
+}
+
+// The href of the feed's link with rel, or ""
+func (feed atomFeed) link(rel string) string {
+	for _, link := range feed.Links {
+		if link.Rel == rel {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+func TestAtomFeedPaging(t *testing.T) {
+	srv := newTestServer(t, 0)
+	srv.cfg.FeedCount, srv.cfg.PublicURL = 2, "https://news.example"
+
+	// Empty, the feed is a first page without entries
+	if status, feed := getAtom(t, srv.URL+"/feed.atom"); status != http.StatusOK || len(feed.Entries) != 0 || feed.link("last") != "https://news.example/feed.atom" {
+		t.Errorf("empty feed: %d %+v", status, feed)
+	}
+
+	// Five published a day apart, the first changed most recently, and a
+	// draft, which isn't listed
+	for i := range 5 {
+		call(t, "POST", srv.URL+"/articles", fmt.Sprintf(`{"title":"Day %d","desc":"First","content":"Some text"}`, i+1), nil)
+	}
+	call(t, "POST", srv.URL+"/articles", `{"title":"Draft","desc":"First","content":"Some text","status":"draft"}`, nil)
+	day := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	srv.articlesMutex.Lock()
+	articles := slices.Clone(srv.articles)
+	for i := range articles {
+		articles[i].Published = day.AddDate(0, 0, i)
+		articles[i].Updated = day.AddDate(0, 0, i)
+	}
+	articles[0].Updated = day.AddDate(0, 1, 0)
+	srv.articles = articles
+	srv.articlesMutex.Unlock()
+
+	titles := func(feed atomFeed) (list []string) {
+		for _, entry := range feed.Entries {
+			list = append(list, entry.Title)
+		}
+		return list
+	}
+	status, first := getAtom(t, srv.URL+"/feed.atom")
+	if status != http.StatusOK || !slices.Equal(titles(first), []string{"Day 5", "Day 4"}) {
+		t.Fatalf("page 1: %d %v", status, titles(first))
+	}
+	for rel, want := range map[string]string{
+		"self":     "https://news.example/feed.atom",
+		"first":    "https://news.example/feed.atom",
+		"next":     "https://news.example/feed.atom?page=2",
+		"last":     "https://news.example/feed.atom?page=3",
+		"previous": "",
+	} {
+		if got := first.link(rel); got != want {
+			t.Errorf("page 1 %s link %q, want %q", rel, got, want)
+		}
+	}
+	if first.Updated != "2025-03-05T12:00:00Z" || first.Entries[0].Published != "2025-03-05T12:00:00Z" || first.Entries[0].ID != "https://news.example/articles/5" {
+		t.Errorf("page 1 updated %s, entry %+v", first.Updated, first.Entries[0])
+	}
+
+	// The last page links back, and is as fresh as its own entries
+	status, last := getAtom(t, srv.URL+"/feed.atom?page=3")
+	if status != http.StatusOK || !slices.Equal(titles(last), []string{"Day 1"}) || last.link("next") != "" || last.link("previous") != "https://news.example/feed.atom?page=2" {
+		t.Errorf("page 3: %d %v %+v", status, titles(last), last.Links)
+	}
+	if last.Updated != "2025-04-01T12:00:00Z" {
+		t.Errorf("page 3 updated %s", last.Updated)
+	}
+	for page, want := range map[string]int{"4": http.StatusNotFound, "0": http.StatusBadRequest, "x": http.StatusBadRequest} {
+		if status, _ := getAtom(t, srv.URL+"/feed.atom?page="+page); status != want {
+			t.Errorf("page %s: status %d, want %d", page, status, want)
+		}
+	}
+
+	// A workspace has a feed of its own articles
+	srv.articlesMutex.Lock()
+	articles = slices.Clone(srv.articles)
+	articles[1].Workspace = "news"
+	srv.articles = articles
+	srv.workspaces = append(slices.Clone(srv.workspaces), model.Workspace{Slug: "news", Name: "News"})
+	srv.articlesMutex.Unlock()
+	if status, feed := getAtom(t, srv.URL+"/workspaces/news/feed.atom"); status != http.StatusOK || !slices.Equal(titles(feed), []string{"Day 2"}) || feed.Title != "News" {
+		t.Errorf("workspace feed: %d %v %q", status, titles(feed), feed.Title)
+	}
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go-spring/internal/config"
+	"go-spring/internal/model"
+)
+
+// gRPC status codes used by the article service
+const (
+	grpcOK              = 0
+	grpcInvalidArgument = 3
+	grpcNotFound        = 5
+	grpcUnimplemented   = 12
+	grpcInternal        = 13
+	grpcUnavailable     = 14
+	grpcUnauthenticated = 16
+)
+
+const grpcServicePrefix = "/gospring.v1.ArticleService/"
+
+// grpcError is returned by method implementations to set the call status
+type grpcError struct {
+	Code    int
+	Message string
+}
+
+func (e *grpcError) Error() string {
+	return e.Message
+}
+
+// Map article operation errors to gRPC statuses
+func toGRPCError(err error) error {
+	var validation *ValidationError
+	switch {
+	case errors.As(err, &validation):
+		return &grpcError{grpcInvalidArgument, validation.Message}
+	case errors.Is(err, ErrArticleNotFound):
+		return &grpcError{grpcNotFound, "article not found"}
+	}
+	return err
+}
+
+// Unary methods take and return encoded protobuf messages
+type grpcUnaryMethod func(ctx context.Context, req []byte) ([]byte, error)
+
+func (app *App) grpcUnaryMethods() map[string]grpcUnaryMethod {
+	return map[string]grpcUnaryMethod{
+		"GetArticle": grpcUnary(func(ctx context.Context, req grpcIDRequest) (model.Article, error) {
+			article, err := app.articleService.Get(app.grpcReaderContext(ctx), req.ID)
+			return app.grpcWithheld([]model.Article{article})[0], err
+		}),
+		"ListArticles": grpcUnary(func(ctx context.Context, req ListArticlesRequest) (ListArticlesResponse, error) {
+			resp, err := app.articleService.List(app.grpcReaderContext(ctx), req)
+			resp.Articles = app.grpcWithheld(resp.Articles)
+			return resp, err
+		}),
+		"CreateArticle": grpcUnary(func(ctx context.Context, req model.CreateArticleRequest) (model.Article, error) {
+			return app.articleService.Create(ctx, req)
+		}),
+		"UpdateArticle": grpcUnary(func(ctx context.Context, req model.ArticleUpdate) (model.Article, error) {
+			return app.articleService.Update(ctx, req)
+		}),
+		"DeleteArticle": grpcUnary(func(ctx context.Context, req grpcIDRequest) (struct{}, error) {
+			return struct{}{}, app.articleService.Delete(ctx, req.ID) // google.protobuf.Empty
+		}),
+	}
+}
+
+// The methods read-only mode refuses
+var grpcChanges = []string{"CreateArticle", "UpdateArticle", "DeleteArticle"}
+
+// GRPCServer returns the gRPC server (HTTP/2 without TLS) for the caller to
+// serve on a listener
+func (app *App) GRPCServer() *http.Server {
+	srv := &http.Server{Handler: http.HandlerFunc(app.serveGRPC)}
+	srv.Protocols = new(http.Protocols)
+	srv.Protocols.SetUnencryptedHTTP2(true)
+	return srv
+}
+
+func (app *App) serveGRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		app.writeError(w, r, http.StatusUnsupportedMediaType, CodeUnsupportedType, "gRPC requests only")
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+
+	method, ok := strings.CutPrefix(r.URL.Path, grpcServicePrefix)
+	if !ok {
+		writeGRPCStatus(w, grpcUnimplemented, "unknown service")
+		return
+	}
+
+	if mode := app.currentMode(); mode.Mode == config.ModeMaintenance || mode.Mode == config.ModeReadOnly && slices.Contains(grpcChanges, method) {
+		writeGRPCStatus(w, grpcUnavailable, "server is in "+mode.Mode+" mode")
+		return
+	}
+
+	// With scoped signing keys, changes need a user as over REST; calls
+	// can't be signed, so that takes Basic credentials in the metadata
+	ctx := r.Context()
+	if app.cfg.SigningKeysFile != "" && app.hasUsers() && slices.Contains(grpcChanges, method) {
+		user, ok := app.requestUser(r)
+		if !ok {
+			writeGRPCStatus(w, grpcUnauthenticated, "changes need Basic credentials")
+			return
+		}
+		ctx = context.WithValue(ctx, userKey{}, user)
+	}
+
+	req, err := readGRPCMessage(r.Body, app.cfg.AttachmentMaxBytes)
+	if err != nil {
+		writeGRPCStatus(w, grpcInvalidArgument, err.Error())
+		return
+	}
+
+	if method == "WatchArticles" {
+		err = app.grpcWatchArticles(ctx, w)
+	} else if fn, ok := app.grpcUnaryMethods()[method]; ok {
+		var resp []byte
+		if resp, err = fn(ctx, req); err == nil {
+			writeGRPCMessage(w, resp)
+		}
+	} else {
+		err = &grpcError{grpcUnimplemented, "unknown method " + method}
+	}
+
+	var status *grpcError
+	switch {
+	case err == nil:
+		writeGRPCStatus(w, grpcOK, "")
+	case errors.As(err, &status):
+		writeGRPCStatus(w, status.Code, status.Message)
+	default:
+		log.Printf("Error: gRPC %s: %v", method, err)
+		writeGRPCStatus(w, grpcInternal, "internal error")
+	}
+}
+
+// Read one length-prefixed message of at most limit bytes from a request
+// body
+func readGRPCMessage(body io.Reader, limit int64) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(body, header[:]); err != nil {
+		if err == io.EOF {
+			return nil, nil // empty request message
+		}
+		return nil, errors.New("malformed request")
+	}
+	if header[0] != 0 {
+		return nil, errors.New("compressed messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if int64(size) > limit {
+		return nil, errors.New("request message too large")
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(body, msg); err != nil {
+		return nil, errors.New("malformed request")
+	}
+	return msg, nil
+}
+
+func writeGRPCMessage(w http.ResponseWriter, msg []byte) {
+	var header [5]byte
+	binary.BigEndian.PutUint32(header[1:], uint32(len(msg)))
+	w.Write(header[:])
+	w.Write(msg)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func writeGRPCStatus(w http.ResponseWriter, code int, message string) {
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if message != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", url.PathEscape(message))
+	}
+}
+
+// grpcIDRequest is GetArticleRequest and DeleteArticleRequest
+type grpcIDRequest struct {
+	ID int `proto:"1"`
+}
+
+// Adapt an ArticleService call to a unary method, decoding the request and
+// encoding the response with the proto tags of the shared types
+func grpcUnary[Req, Resp any](call func(context.Context, Req) (Resp, error)) grpcUnaryMethod {
+	return func(ctx context.Context, data []byte) ([]byte, error) {
+		var req Req
+		if err := unmarshalProto(data, &req); err != nil {
+			return nil, &grpcError{grpcInvalidArgument, err.Error()}
+		}
+		resp, err := call(ctx, req)
+		if err != nil {
+			return nil, toGRPCError(err)
+		}
+		return marshalProto(resp), nil
+	}
+}
+
+// Stream article events until the client goes away
+func (app *App) grpcWatchArticles(ctx context.Context, w http.ResponseWriter) error {
+	ch, unsubscribe := app.articleService.Watch(ctx)
+	defer unsubscribe()
+
+	// Send headers right away so clients know the stream is established
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-ch:
+			if isHeld(event.Article) && app.hasUsers() {
+				continue
+			}
+			event.Article = app.grpcWithheld([]model.Article{event.Article})[0]
+			writeGRPCMessage(w, marshalProto(event))
+		}
+	}
+}
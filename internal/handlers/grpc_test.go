@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"go-spring/internal/model"
+)
+
+// Start the gRPC server on a local port and return an HTTP/2 client for it
+func newGRPCServer(t *testing.T, srv *testServer) (string, *http.Client) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := srv.GRPCServer()
+	go grpcServer.Serve(ln)
+	t.Cleanup(func() { grpcServer.Close() })
+
+	transport := &http.Transport{Protocols: new(http.Protocols)}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	t.Cleanup(transport.CloseIdleConnections)
+	return "http://" + ln.Addr().String(), &http.Client{Transport: transport}
+}
+
+// Make a unary call, decoding the response message into out, and return the
+// call's gRPC status and message
+func grpcCall(t *testing.T, client *http.Client, base, method string, req, out any) (int, string) {
+	t.Helper()
+	return grpcCallWith(t, client, base, method, nil, req, out)
+}
+
+// grpcCall with metadata
+func grpcCallWith(t *testing.T, client *http.Client, base, method string, metadata http.Header, req, out any) (int, string) {
+	t.Helper()
+	msg := marshalProto(req)
+	body := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(msg)))
+	body = append(body, msg...)
+
+	httpReq, err := http.NewRequest("POST", base+grpcServicePrefix+method, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range metadata {
+		httpReq.Header[name] = values
+	}
+	httpReq.Header.Set("Content-Type", "application/grpc")
+	httpReq.Header.Set("TE", "trailers")
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 || resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s: %s, status %d", method, resp.Proto, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > 0 {
+		got, err := readGRPCMessage(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		if err := unmarshalProto(got, out); err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+	}
+	code, err := strconv.Atoi(resp.Trailer.Get("Grpc-Status"))
+	if err != nil {
+		t.Fatalf("%s: grpc-status %q", method, resp.Trailer.Get("Grpc-Status"))
+	}
+	message, _ := url.PathUnescape(resp.Trailer.Get("Grpc-Message"))
+	return code, message
+}
+
+func TestGRPC(t *testing.T) {
+	srv := newTestServer(t, 2)
+	base, client := newGRPCServer(t, srv)
+
+	var want model.Article
+	call(t, "GET", srv.URL+"/articles/1", "", &want)
+	var got model.Article
+	if code, msg := grpcCall(t, client, base, "GetArticle", grpcIDRequest{ID: 1}, &got); code != grpcOK || got.ID != 1 || got.Title != want.Title || !got.Created.Equal(want.Created) {
+		t.Errorf("GetArticle: status %d %q, %+v", code, msg, got)
+	}
+
+	var created model.Article
+	req := model.CreateArticleRequest{Title: "Over gRPC", Desc: "d", Content: "c", Tags: []string{"grpc"}}
+	if code, msg := grpcCall(t, client, base, "CreateArticle", req, &created); code != grpcOK || created.ID == 0 || created.Title != "Over gRPC" {
+		t.Errorf("CreateArticle: status %d %q, %+v", code, msg, created)
+	}
+
+	var list ListArticlesResponse
+	if code, msg := grpcCall(t, client, base, "ListArticles", ListArticlesRequest{PageSize: 2}, &list); code != grpcOK || len(list.Articles) != 2 || list.NextPageToken == "" {
+		t.Errorf("ListArticles: status %d %q, %d articles, next %q", code, msg, len(list.Articles), list.NextPageToken)
+	}
+}
+
+func TestGRPCErrors(t *testing.T) {
+	srv := newTestServer(t, 1)
+	base, client := newGRPCServer(t, srv)
+
+	for _, tc := range []struct {
+		method string
+		req    any
+		code   int
+		msg    string
+	}{
+		{"GetArticle", grpcIDRequest{ID: 99}, grpcNotFound, "article not found"},
+		{"DeleteArticle", grpcIDRequest{ID: 99}, grpcNotFound, "article not found"},
+		{"CreateArticle", model.CreateArticleRequest{Title: "no description"}, grpcInvalidArgument, ""},
+		{"RenameArticle", grpcIDRequest{ID: 1}, grpcUnimplemented, "unknown method RenameArticle"},
+	} {
+		var out model.Article
+		code, msg := grpcCall(t, client, base, tc.method, tc.req, &out)
+		if code != tc.code || tc.msg != "" && msg != tc.msg || msg == "" {
+			t.Errorf("%s: status %d %q, want %d %q", tc.method, code, msg, tc.code, tc.msg)
+		}
+		if out.ID != 0 {
+			t.Errorf("%s: a message with the error: %+v", tc.method, out)
+		}
+	}
+
+	// Other requests get an HTTP error
+	resp, err := client.Post(base+grpcServicePrefix+"GetArticle", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("JSON request: status %d", resp.StatusCode)
+	}
+}
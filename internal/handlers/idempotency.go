@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// How long a POST response is replayed for a repeated Idempotency-Key
+const idempotencyTTL = 24 * time.Hour
+
+// idempotentResponse is the outcome of the first request with a key; done
+// is closed once the handler has finished
+type idempotentResponse struct {
+	done    chan struct{}
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+type idempotencyState struct {
+	idempotencyMutex     sync.Mutex
+	idempotentResponses  map[string]*idempotentResponse
+	idempotencyLastSweep time.Time
+}
+
+// Make POST routes safe to retry: the first request with an Idempotency-Key
+// header runs as usual, and later requests with the same key (and the same
+// method, path and user) get its response again instead of creating a
+// second article. Server errors are not kept, so a retry after a 5xx runs
+// the handler again. A retry that arrives while the first request is still
+// running gets 409 Conflict.
+func (app *App) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		username, _, _ := r.BasicAuth()
+		if user, ok := currentUser(r); ok {
+			username = user.Username // a signed request
+		}
+		key = username + "|" + r.Method + " " + r.URL.Path + "|" + key
+
+		app.idempotencyMutex.Lock()
+		now := time.Now()
+		if now.Sub(app.idempotencyLastSweep) > time.Minute {
+			for k, resp := range app.idempotentResponses {
+				if !resp.expires.IsZero() && now.After(resp.expires) {
+					delete(app.idempotentResponses, k)
+				}
+			}
+			app.idempotencyLastSweep = now
+		}
+		previous, ok := app.idempotentResponses[key]
+		if !ok {
+			previous = &idempotentResponse{done: make(chan struct{})}
+			app.idempotentResponses[key] = previous
+		}
+		app.idempotencyMutex.Unlock()
+
+		if ok {
+			select {
+			case <-previous.done:
+			default:
+				app.writeError(w, r, http.StatusConflict, CodeRequestInProgress, "A request with this Idempotency-Key is still in progress")
+				return
+			}
+			for name, values := range previous.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(previous.status)
+			w.Write(previous.body)
+			return
+		}
+
+		cw := &captureWriter{ResponseWriter: w, status: http.StatusOK}
+		next(cw, r)
+
+		app.idempotencyMutex.Lock()
+		if cw.status >= 500 {
+			delete(app.idempotentResponses, key)
+		} else {
+			previous.status = cw.status
+			// Encoding headers are set again by compressResponses on replay
+			previous.header = w.Header().Clone()
+			for _, name := range []string{"Content-Encoding", "Content-Length", "Vary"} {
+				previous.header.Del(name)
+			}
+			previous.body = cw.body.Bytes()
+			previous.expires = time.Now().Add(idempotencyTTL)
+		}
+		app.idempotencyMutex.Unlock()
+		close(previous.done)
+	}
+}
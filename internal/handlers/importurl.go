@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"go-spring/internal/config"
+	"go-spring/internal/markdown"
+	"go-spring/internal/model"
+)
+
+// Largest page we are willing to download for import
+const maxImportPageBytes = 5 << 20
+
+// Body of POST /articles/import-url
+type ImportURLRequest struct {
+	URL string `json:"url" validate:"required,url"`
+}
+
+// PageExtract is the readable part of a web page
+type PageExtract struct {
+	Title   string
+	Desc    string
+	Content string // Markdown
+}
+
+var (
+	titleTagRe   = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	metaTagRe    = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attrValueRe  = regexp.MustCompile(`(?is)\b(name|property|content)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+	noiseBlockRe = regexp.MustCompile(`(?is)<(script|style|noscript|template|svg|nav|header|footer|aside|form|iframe)\b.*?</(script|style|noscript|template|svg|nav|header|footer|aside|form|iframe)\s*>`)
+	containerRe  = regexp.MustCompile(`(?is)<(article|main)\b[^>]*>(.*?)</(article|main)\s*>`)
+	bodyRe       = regexp.MustCompile(`(?is)<body\b[^>]*>(.*)</body\s*>`)
+	blockRe      = regexp.MustCompile(`(?is)<(h[1-6]|p|li|pre|blockquote)\b[^>]*>(.*?)</(h[1-6]|p|li|pre|blockquote)\s*>`)
+	whitespaceRe = regexp.MustCompile(`\s+`)
+)
+
+// POST /articles/import-url - Fetch a web page and create a draft article from it
+func (app *App) importArticleFromURL(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req ImportURLRequest
+	if err := decodeBody(r, &req); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	if err := validateRequest(req); err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+	u, err := parseExternalURL(req.URL)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidURL, "Invalid URL: "+err.Error())
+		return
+	}
+
+	resp, err := fetchExternal(r.Context(), u)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadGateway, CodeUpstreamFailed, "Failed to fetch page: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		app.writeError(w, r, http.StatusUnprocessableEntity, CodeUnreadablePage, "URL does not point to an HTML page")
+		return
+	}
+	page, err := io.ReadAll(io.LimitReader(resp.Body, maxImportPageBytes))
+	if err != nil {
+		app.writeError(w, r, http.StatusBadGateway, CodeUpstreamFailed, "Failed to fetch page: "+err.Error())
+		return
+	}
+
+	extract := extractReadable(string(page))
+	article := model.Article{
+		Title:     extract.Title,
+		Desc:      extract.Desc,
+		Content:   extract.Content,
+		Status:    model.StatusDraft,
+		SourceURL: u.String(),
+	}
+	app.sanitizeArticle(&article.Title, &article.Desc, &article.Content)
+	if article.Title == "" || article.Content == "" {
+		app.writeError(w, r, http.StatusUnprocessableEntity, CodeUnreadablePage, "Could not find a title and readable content on the page")
+		return
+	}
+
+	article = app.insertArticle(r.Context(), article)
+
+	response := Response{
+		Message: "Draft article imported successfully",
+		Data:    article,
+	}
+
+	app.writeResponse(w, r, http.StatusCreated, response)
+}
+
+// Extract title, description and main content from an HTML page. This is a
+// lightweight readability heuristic: prefer <article>/<main>, drop navigation
+// and boilerplate, and keep headings, paragraphs, lists, quotes and code.
+func extractReadable(page string) PageExtract {
+	meta := pageMeta(page)
+
+	var extract PageExtract
+	extract.Title = firstNonEmpty(meta["og:title"], meta["twitter:title"])
+	if extract.Title == "" {
+		if m := titleTagRe.FindStringSubmatch(page); m != nil {
+			extract.Title = cleanText(m[1])
+		}
+	}
+	extract.Desc = firstNonEmpty(meta["description"], meta["og:description"], meta["twitter:description"])
+
+	body := noiseBlockRe.ReplaceAllString(page, "")
+
+	// Pick the container with the most text; fall back to the whole body
+	main := ""
+	for _, m := range containerRe.FindAllStringSubmatch(body, -1) {
+		if len(cleanText(m[2])) > len(cleanText(main)) {
+			main = m[2]
+		}
+	}
+	if main == "" {
+		if m := bodyRe.FindStringSubmatch(body); m != nil {
+			main = m[1]
+		} else {
+			main = body
+		}
+	}
+
+	var blocks []string
+	for _, m := range blockRe.FindAllStringSubmatch(main, -1) {
+		tag := strings.ToLower(m[1])
+		if tag == "pre" {
+			code := html.UnescapeString(markdown.Sanitize(m[2], config.SanitizePlain))
+			blocks = append(blocks, "```\n"+strings.Trim(code, "\n")+"\n```")
+			continue
+		}
+		text := cleanText(m[2])
+		if text == "" {
+			continue
+		}
+		switch {
+		case tag[0] == 'h':
+			if text == extract.Title && len(blocks) == 0 {
+				continue // the page title repeated as the first heading
+			}
+			blocks = append(blocks, strings.Repeat("#", int(tag[1]-'0'))+" "+text)
+		case tag == "li":
+			blocks = append(blocks, "- "+text)
+		case tag == "blockquote":
+			blocks = append(blocks, "> "+text)
+		default:
+			blocks = append(blocks, text)
+		}
+		if extract.Title == "" && tag == "h1" {
+			extract.Title = text
+		}
+	}
+
+	// Keep consecutive list items together
+	var b strings.Builder
+	for i, block := range blocks {
+		if i > 0 {
+			if strings.HasPrefix(block, "- ") && strings.HasPrefix(blocks[i-1], "- ") {
+				b.WriteString("\n")
+			} else {
+				b.WriteString("\n\n")
+			}
+		}
+		b.WriteString(block)
+	}
+	extract.Content = b.String()
+
+	if extract.Desc == "" {
+		for _, block := range blocks {
+			if !strings.HasPrefix(block, "#") && !strings.HasPrefix(block, "- ") && !strings.HasPrefix(block, "```") {
+				extract.Desc = truncateText(block, 200)
+				break
+			}
+		}
+	}
+
+	return extract
+}
+
+// Collect <meta name|property=... content=...> values, keyed by lower-cased name
+func pageMeta(page string) map[string]string {
+	meta := map[string]string{}
+	for _, tag := range metaTagRe.FindAllString(page, -1) {
+		var name, content string
+		for _, m := range attrValueRe.FindAllStringSubmatch(tag, -1) {
+			value := m[2] + m[3] + m[4]
+			switch strings.ToLower(m[1]) {
+			case "name", "property":
+				name = strings.ToLower(value)
+			case "content":
+				content = value
+			}
+		}
+		if name != "" && meta[name] == "" {
+			meta[name] = cleanText(content)
+		}
+	}
+	return meta
+}
+
+// Strip tags, decode entities and collapse whitespace
+func cleanText(s string) string {
+	s = html.UnescapeString(markdown.Sanitize(s, config.SanitizePlain))
+	return strings.TrimSpace(whitespaceRe.ReplaceAllString(s, " "))
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Shorten text to at most n runes, cutting at a word boundary
+func truncateText(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)[:n]
+	cut := string(runes)
+	if i := strings.LastIndex(cut, " "); i > n/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:") + "…"
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"go-spring/internal/model"
+)
+
+const importPage = `<!DOCTYPE html>
+<html><head>
+<title>Site | Spring cleaning</title>
+<meta property="og:title" content="Spring cleaning">
+<meta name="description" content="How to tidy &amp; sort">
+<script>var tracking = "<p>not content</p>";</script>
+</head><body>
+<nav><p>Home</p><p>About</p></nav>
+<aside><p>Related reading that is longer than anything else on the page, by far and away</p></aside>
+<article>
+<h1>Spring cleaning</h1>
+<p>Start with the <b>attic</b>,
+  then the cellar.</p>
+<h2>Tools</h2>
+<ul><li>Broom</li><li>Bucket &amp; mop</li></ul>
+<blockquote>Less is more.</blockquote>
+<pre>if dirty {
+	clean()
+}</pre>
+</article>
+<footer><p>© Site</p></footer>
+</body></html>`
+
+func TestExtractReadable(t *testing.T) {
+	got := extractReadable(importPage)
+	want := PageExtract{
+		Title: "Spring cleaning",
+		Desc:  "How to tidy & sort",
+		Content: "Start with the attic, then the cellar.\n\n## Tools\n\n- Broom\n- Bucket & mop\n\n> Less is more.\n\n" +
+			"```\nif dirty {\n\tclean()\n}\n```",
+	}
+	if got != want {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+
+	// Without meta tags: the <title>, the first paragraph, and the body
+	// when there is no <article> or <main>
+	got = extractReadable(`<html><head><title> Plain  page </title></head><body><h1>Heading</h1><p>` + strings.Repeat("word ", 60) + `</p></body></html>`)
+	if got.Title != "Plain page" || !strings.HasPrefix(got.Content, "# Heading\n\nword word") || !strings.HasSuffix(got.Desc, "…") || len([]rune(got.Desc)) > 201 {
+		t.Errorf("plain page %+v", got)
+	}
+	if got := extractReadable(`<html><body><h1>Only a title</h1></body></html>`); got.Title != "Only a title" || got.Content != "# Only a title" {
+		t.Errorf("title only %+v", got)
+	}
+}
+
+func TestImportFromURL(t *testing.T) {
+	srv := newTestServer(t, 0)
+	// The server won't fetch from internal addresses
+	if resp := call(t, "POST", srv.URL+"/articles/import-url", `{"url":"http://127.0.0.1:1/page"}`, nil); resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("internal address: status %d", resp.StatusCode)
+	}
+
+	site := externalSite(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/post":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(importPage))
+		case "/empty":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><nav><p>Menu</p></nav></body></html>`))
+		case "/data.json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"title":"x"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	var article model.Article
+	if resp := call(t, "POST", srv.URL+"/articles/import-url", `{"url":"`+site.URL+`/post"}`, &article); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("import: status %d", resp.StatusCode)
+	}
+	if article.Title != "Spring cleaning" || article.Status != model.StatusDraft || article.SourceURL != site.URL+"/post" || !strings.Contains(article.Content, "- Bucket & mop") {
+		t.Errorf("imported %+v", article)
+	}
+
+	for body, want := range map[string]int{
+		`{"url":"` + site.URL + `/empty"}`:     http.StatusUnprocessableEntity,
+		`{"url":"` + site.URL + `/data.json"}`: http.StatusUnprocessableEntity,
+		`{"url":"` + site.URL + `/missing"}`:   http.StatusBadGateway,
+		`{"url":"file:///etc/passwd"}`:         http.StatusBadRequest,
+		`{"url":""}`:                           http.StatusBadRequest,
+		`not json`:                             http.StatusBadRequest,
+	} {
+		if resp := call(t, "POST", srv.URL+"/articles/import-url", body, nil); resp.StatusCode != want {
+			t.Errorf("%s: status %d, want %d", body, resp.StatusCode, want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+)
+
+// How long a client turned away by limitInFlight should wait before trying
+// again, in seconds
+const inFlightRetryAfter = "1"
+
+// limitInFlight serves at most MAX_IN_FLIGHT requests at once. A request
+// over the limit waits up to MAX_IN_FLIGHT_WAIT for another to finish, then
+// gets 503 with Retry-After, so that a burst is turned away at the door
+// instead of piling up on articlesMutex, the store and the heap. Readiness
+// probes, the replication stream and the control routes always get in.
+func (app *App) limitInFlight(next http.Handler) http.Handler {
+	slots := make(chan struct{}, app.cfg.MaxInFlight)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/readyz" || r.URL.Path == "/admin/replication" || controlPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		select {
+		case slots <- struct{}{}:
+		default:
+			wait := time.NewTimer(app.cfg.MaxInFlightWait)
+			defer wait.Stop()
+			select {
+			case slots <- struct{}{}:
+			case <-wait.C:
+				w.Header().Set("Retry-After", inFlightRetryAfter)
+				app.writeError(w, r, http.StatusServiceUnavailable, CodeUnavailable, "Server is overloaded")
+				return
+			case <-r.Context().Done():
+				return // the client gave up waiting
+			}
+		}
+		defer func() { <-slots }()
+		next.ServeHTTP(w, r)
+	})
+}
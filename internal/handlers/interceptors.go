@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Interceptor decorates the handlers of a group of routes, like a Spring MVC
+// HandlerInterceptor. Interceptors run inside the middleware pipeline, after
+// authentication and around idempotent replays and cached responses, in
+// registration order; every callback is optional.
+type Interceptor struct {
+	Name string
+
+	// Routes selects the routes to intercept; nil intercepts every route
+	Routes func(Route) bool
+
+	// PreHandle runs before the handler. It may replace inv.Request, e.g.
+	// to add a context value. Returning false stops the request: the
+	// interceptor has written the response, and neither the handler nor
+	// the later interceptors run.
+	PreHandle func(w http.ResponseWriter, inv *Invocation) bool
+
+	// PostHandle runs, in reverse order, once the handler has chosen its
+	// status and before the headers are sent, so it may still add headers
+	PostHandle func(inv *Invocation)
+
+	// AfterCompletion runs, in reverse order, when the response is complete
+	// or the handler panicked, for every interceptor whose PreHandle let
+	// the request through
+	AfterCompletion func(inv *Invocation)
+}
+
+// Invocation is one intercepted request
+type Invocation struct {
+	Route   Route
+	Request *http.Request
+	Header  http.Header // response headers
+	Status  int         // response status, 0 until the handler writes
+	Size    int64       // bytes of body written
+	Start   time.Time
+	Elapsed time.Duration // set before AfterCompletion
+	Panic   any           // what the handler panicked with, re-raised after AfterCompletion
+
+	// Values that interceptors pass from PreHandle to their later callbacks
+	Attributes map[string]any
+}
+
+// Built-in interceptors, before those of plugins
+var builtinInterceptors = []Interceptor{
+	{Name: "audit", Routes: auditedRoute, AfterCompletion: auditRequest},
+}
+
+// The interceptors turned on by the configuration, then those of plugins
+func (app *App) activeInterceptors() []Interceptor {
+	var active []Interceptor
+	if app.cfg.AuditLog {
+		active = append(active, builtinInterceptors...)
+	}
+	for _, p := range app.registeredPlugins() {
+		active = append(active, p.Interceptors...)
+	}
+	return active
+}
+
+// The interceptors that select a route
+func (app *App) routeInterceptors(route Route) []Interceptor {
+	return slices.DeleteFunc(app.activeInterceptors(), func(ic Interceptor) bool {
+		return ic.Routes != nil && !ic.Routes(route)
+	})
+}
+
+// Run a route's handler inside its interceptors
+func (app *App) intercept(route Route, next http.Handler) http.Handler {
+	chain := app.routeInterceptors(route)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inv := &Invocation{Route: route, Request: r, Header: w.Header(), Start: time.Now(), Attributes: map[string]any{}}
+		iw := &interceptWriter{ResponseWriter: w, inv: inv} // no PostHandle for a response written by PreHandle
+
+		passed := 0 // interceptors whose PreHandle let the request through
+		defer func() {
+			inv.Panic = recover()
+			inv.Elapsed = time.Since(inv.Start)
+			for _, ic := range slices.Backward(chain[:passed]) {
+				if ic.AfterCompletion != nil {
+					ic.AfterCompletion(inv)
+				}
+			}
+			if inv.Panic != nil {
+				panic(inv.Panic)
+			}
+		}()
+
+		for _, ic := range chain {
+			if ic.PreHandle != nil && !ic.PreHandle(iw, inv) {
+				iw.finish()
+				return
+			}
+			passed++
+		}
+		iw.chain = chain
+		next.ServeHTTP(iw, inv.Request)
+		iw.finish()
+	})
+}
+
+// interceptWriter runs PostHandle when the status is chosen and records
+// the response for the Invocation
+type interceptWriter struct {
+	http.ResponseWriter
+	inv         *Invocation
+	chain       []Interceptor
+	wroteHeader bool
+}
+
+func (iw *interceptWriter) WriteHeader(status int) {
+	if iw.wroteHeader {
+		return
+	}
+	iw.wroteHeader = true
+	iw.inv.Status = status
+	for _, ic := range slices.Backward(iw.chain) {
+		if ic.PostHandle != nil {
+			ic.PostHandle(iw.inv)
+		}
+	}
+	iw.ResponseWriter.WriteHeader(status)
+}
+
+func (iw *interceptWriter) Write(b []byte) (int, error) {
+	iw.WriteHeader(http.StatusOK)
+	n, err := iw.ResponseWriter.Write(b)
+	iw.inv.Size += int64(n)
+	return n, err
+}
+
+func (iw *interceptWriter) Flush() {
+	iw.WriteHeader(http.StatusOK)
+	if f, ok := iw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// A handler that wrote nothing sends 200 with no body
+func (iw *interceptWriter) finish() {
+	iw.WriteHeader(http.StatusOK)
+}
+
+// Changes and admin operations are audited (AUDIT_LOG)
+func auditedRoute(r Route) bool {
+	return r.Method != http.MethodGet || adminRoutes(r)
+}
+
+// Log who did what, and how it ended
+func auditRequest(inv *Invocation) {
+	user := "-"
+	if u, ok := currentUser(inv.Request); ok {
+		user = u.Username
+	}
+	outcome := http.StatusText(inv.Status)
+	if inv.Panic != nil {
+		outcome = "panic"
+	}
+	log.Printf("Audit: %s %s %s from %s: %d %s", user, inv.Request.Method, inv.Request.URL.Path, inv.Request.RemoteAddr, inv.Status, outcome)
+}
@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"go-spring/internal/config"
+	"go-spring/internal/jobs"
+	"go-spring/internal/model"
+)
+
+type jobsState struct {
+	// Queue of slow work, opened by New and run between StartBackground and
+	// StopBackground; nil for commands that run without a server
+	jobQueue *jobs.Queue
+}
+
+// Kinds of background jobs
+const (
+	jobThumbnail = "thumbnail"
+	jobImport    = "import"
+	jobExport    = "export"
+)
+
+// Payload of a thumbnail job: one variant of an uploaded image
+type thumbnailJob struct {
+	ArticleID    int `json:"article_id"`
+	AttachmentID int `json:"attachment_id"`
+	Width        int `json:"width"`
+	Height       int `json:"height"`
+}
+
+// Open the job queue (JOBS_FILE) and register the job handlers
+func (app *App) initJobQueue(cfg config.Config) error {
+	path := cfg.JobsFile
+	if path == "off" {
+		path = ""
+	}
+	queue, err := jobs.Open(path, jobs.Options{
+		Workers:     cfg.JobWorkers,
+		MaxAttempts: cfg.JobMaxAttempts,
+		OnRemove:    app.deleteJobFiles,
+		OnDead:      app.notifyJobDead,
+	})
+	if err != nil {
+		return err
+	}
+	queue.Handle(jobThumbnail, app.runThumbnailJob)
+	queue.Handle(jobImport, app.runImportJob)
+	queue.Handle(jobExport, app.runExportJob)
+	queue.Handle(jobBlobGC, app.runBlobGCJob)
+	queue.Handle(jobCompact, app.runCompactJob)
+	queue.Handle(jobRetention, app.runRetentionJob)
+	app.jobQueue = queue
+	return nil
+}
+
+// Queue a job, logging instead of failing the request if that doesn't work
+func (app *App) enqueueJob(kind string, payload any) {
+	if app.jobQueue == nil {
+		return
+	}
+	if job, err := app.jobQueue.Enqueue(kind, payload); err != nil {
+		log.Printf("Warning: Failed to queue %s job %d: %v", kind, job.ID, err)
+	}
+}
+
+// Queue the THUMBNAIL_SIZES variants of a freshly uploaded image
+func (app *App) enqueueThumbnails(articleID int, attachment model.Attachment) {
+	if !slices.Contains(resizableTypes, attachment.ContentType) {
+		return
+	}
+	for _, size := range app.cfg.ThumbnailSizes {
+		width, height, ok := parseThumbnailSize(size)
+		if !ok {
+			log.Printf("Warning: invalid THUMBNAIL_SIZES entry %q", size)
+			continue
+		}
+		app.enqueueJob(jobThumbnail, thumbnailJob{articleID, attachment.ID, width, height})
+	}
+}
+
+// Generate and store a variant unless it exists or the attachment is gone
+func (app *App) runThumbnailJob(ctx context.Context, job jobs.Job) error {
+	var t thumbnailJob
+	if err := json.Unmarshal(job.Payload, &t); err != nil {
+		return err
+	}
+	attachment, ok := app.findAttachment(t.ArticleID, t.AttachmentID)
+	if !ok {
+		return nil
+	}
+	key := fmt.Sprintf("thumbs/%d/%dx%d", attachment.ID, t.Width, t.Height)
+	if slices.Contains(attachment.Variants, key) {
+		return nil
+	}
+	data, err := app.generateThumbnail(ctx, attachment, t.Width, t.Height)
+	if err != nil {
+		return err
+	}
+	if err := app.putBlob(ctx, key, bytes.NewReader(data)); err != nil {
+		return err
+	}
+	app.recordVariant(t.ArticleID, attachment.ID, key)
+	return nil
+}
+
+// Payload of an import job; the uploaded file waits in blob storage
+type importJob struct {
+	Format string `json:"format"` // json or wordpress
+	Blob   string `json:"blob"`
+	DryRun bool   `json:"dry_run"`
+}
+
+// ExportResult is the result of an export job
+type ExportResult struct {
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+	Download string `json:"download"` // path of the archive, see GET /jobs/{id}/download
+}
+
+// Whether the client would rather poll a job than wait for the response:
+// ?async=true or Prefer: respond-async (RFC 7240)
+func wantsAsync(r *http.Request) bool {
+	if async, _ := strconv.ParseBool(r.URL.Query().Get("async")); async {
+		return true
+	}
+	for _, prefer := range r.Header.Values("Prefer") {
+		for pref := range strings.SplitSeq(prefer, ",") {
+			if strings.EqualFold(strings.TrimSpace(pref), "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Queue a job for the request and answer 202 Accepted with the job, whose
+// progress and result the client polls at the Location
+func (app *App) acceptJob(w http.ResponseWriter, r *http.Request, kind string, payload any) {
+	if app.jobQueue == nil {
+		app.writeError(w, r, http.StatusServiceUnavailable, CodeUnavailable, "Background jobs are not available")
+		return
+	}
+	job, err := app.jobQueue.Enqueue(kind, payload)
+	if err != nil {
+		log.Printf("Error: queue %s job: %v", kind, err)
+		app.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Internal server error")
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/jobs/%d", job.ID))
+	if r.Header.Get("Prefer") != "" {
+		w.Header().Set("Preference-Applied", "respond-async")
+	}
+	app.writeResponse(w, r, http.StatusAccepted, Response{Message: "Job accepted", Data: job})
+}
+
+// Keep an uploaded import file and queue the import
+func (app *App) acceptImportJob(w http.ResponseWriter, r *http.Request, format string, data []byte, dryRun bool) {
+	key := "jobs/import-" + rand.Text()
+	if err := app.putBlob(r.Context(), key, bytes.NewReader(data)); err != nil {
+		app.writeStoreError(w, r, err, "Failed to store import file")
+		return
+	}
+	app.acceptJob(w, r, jobImport, importJob{Format: format, Blob: key, DryRun: dryRun})
+}
+
+// Import a stored file; the ImportReport is the job result
+func (app *App) runImportJob(ctx context.Context, job jobs.Job) error {
+	var imp importJob
+	if err := json.Unmarshal(job.Payload, &imp); err != nil {
+		return jobs.Permanent(err)
+	}
+	blob, err := app.getBlob(ctx, imp.Blob)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(blob)
+	blob.Close()
+	if err != nil {
+		return err
+	}
+
+	var report ImportReport
+	switch imp.Format {
+	case "wordpress":
+		if report, err = app.ImportWXRFile(ctx, data, imp.DryRun); err != nil {
+			return jobs.Permanent(err)
+		}
+	default:
+		report = app.ImportJSONFile(ctx, data, imp.DryRun)
+	}
+	app.deleteBlob(ctx, imp.Blob)
+	return jobs.SetResult(ctx, report)
+}
+
+// Where an export job keeps its archive
+func exportBlobKey(job jobs.Job) string {
+	return fmt.Sprintf("jobs/%d/%s", job.ID, ExportArchiveName(job.Created.UTC()))
+}
+
+// Write the export archive to blob storage for GET /jobs/{id}/download
+func (app *App) runExportJob(ctx context.Context, job jobs.Job) error {
+	now := job.Created.UTC()
+	pr, pw := io.Pipe()
+	cw := &countingWriter{w: pw}
+	go func() {
+		pw.CloseWithError(app.WriteExportArchive(ctx, cw, now))
+	}()
+	if err := app.putBlob(ctx, exportBlobKey(job), pr); err != nil {
+		pr.CloseWithError(err)
+		return err
+	}
+	return jobs.SetResult(ctx, ExportResult{
+		Filename: ExportArchiveName(now),
+		Size:     cw.n,
+		Download: fmt.Sprintf("/jobs/%d/download", job.ID),
+	})
+}
+
+// Delete what a job left in blob storage once the job is dropped
+func (app *App) deleteJobFiles(job jobs.Job) {
+	switch job.Kind {
+	case jobExport:
+		app.deleteBlob(context.Background(), exportBlobKey(job))
+	case jobImport:
+		var imp importJob
+		if json.Unmarshal(job.Payload, &imp) == nil {
+			app.deleteBlob(context.Background(), imp.Blob)
+		}
+	}
+}
+
+// POST /admin/export - Build the export archive in the background
+func (app *App) startExport(w http.ResponseWriter, r *http.Request) {
+	app.acceptJob(w, r, jobExport, struct{}{})
+}
+
+// Look up the job named in the route
+func (app *App) routeJob(w http.ResponseWriter, r *http.Request) (jobs.Job, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid job ID")
+		return jobs.Job{}, false
+	}
+	if app.jobQueue == nil {
+		app.writeError(w, r, http.StatusNotFound, CodeJobNotFound, "Job not found")
+		return jobs.Job{}, false
+	}
+	job, err := app.jobQueue.Get(id)
+	if err != nil {
+		app.writeError(w, r, http.StatusNotFound, CodeJobNotFound, "Job not found")
+		return jobs.Job{}, false
+	}
+	return job, true
+}
+
+// GET /jobs/{id} - Poll the state, progress and result of a job
+func (app *App) getJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := app.routeJob(w, r)
+	if !ok {
+		return
+	}
+	if job.State == jobs.StatePending || job.State == jobs.StateRunning {
+		w.Header().Set("Retry-After", "1")
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Job retrieved successfully", Data: job})
+}
+
+// GET /jobs/{id}/download - Download the archive of a finished export job
+func (app *App) downloadJobResult(w http.ResponseWriter, r *http.Request) {
+	job, ok := app.routeJob(w, r)
+	if !ok {
+		return
+	}
+	if job.Kind != jobExport {
+		app.writeError(w, r, http.StatusNotFound, CodeNotFound, "Job has no download")
+		return
+	}
+	if job.State != jobs.StateDone {
+		app.writeError(w, r, http.StatusConflict, CodeConflict, "Export is not finished")
+		return
+	}
+	app.writeBlob(w, r, exportBlobKey(job), "application/zip", ExportArchiveName(job.Created.UTC()))
+}
+
+// GET /admin/jobs - List queued, running, finished and dead jobs
+func (app *App) listJobs(w http.ResponseWriter, r *http.Request) {
+	state := jobs.State(r.URL.Query().Get("state"))
+	switch state {
+	case "", jobs.StatePending, jobs.StateRunning, jobs.StateDone, jobs.StateDead:
+	default:
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "state must be pending, running, done or dead")
+		return
+	}
+	list := []jobs.Job{}
+	if app.jobQueue != nil {
+		list = append(list, app.jobQueue.List(state)...)
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Jobs retrieved successfully", Data: list})
+}
+
+// POST /admin/jobs/{id}/retry - Queue a dead job again
+func (app *App) retryJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid job ID")
+		return
+	}
+	if app.jobQueue == nil {
+		app.writeError(w, r, http.StatusNotFound, CodeJobNotFound, "Job not found")
+		return
+	}
+	job, err := app.jobQueue.Retry(id)
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		app.writeError(w, r, http.StatusNotFound, CodeJobNotFound, "Job not found")
+	case errors.Is(err, jobs.ErrNotDead):
+		app.writeError(w, r, http.StatusConflict, CodeConflict, err.Error())
+	case err != nil:
+		log.Printf("Error: retry job %d: %v", id, err)
+		app.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Internal server error")
+	default:
+		app.writeResponse(w, r, http.StatusOK, Response{Message: "Job queued for retry", Data: job})
+	}
+}
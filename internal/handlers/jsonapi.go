@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"go-spring/internal/model"
+)
+
+// JSON:API representation (https://jsonapi.org), chosen with
+// Accept: application/vnd.api+json
+const jsonAPIMediaType = "application/vnd.api+json"
+
+type jsonAPIDocument struct {
+	Data     any               `json:"data,omitempty"`
+	Included []jsonAPIResource `json:"included,omitempty"`
+	Errors   []jsonAPIError    `json:"errors,omitempty"`
+	Links    map[string]string `json:"links,omitempty"`
+	Meta     map[string]any    `json:"meta,omitempty"`
+	JSONAPI  map[string]string `json:"jsonapi"`
+}
+
+type jsonAPIResource struct {
+	Type          string                         `json:"type"`
+	ID            string                         `json:"id"`
+	Attributes    map[string]json.RawMessage     `json:"attributes,omitempty"`
+	Relationships map[string]jsonAPIRelationship `json:"relationships,omitempty"`
+	Links         map[string]string              `json:"links,omitempty"`
+}
+
+type jsonAPIIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type jsonAPIRelationship struct {
+	Data any `json:"data"` // identifier, []identifier or nil
+}
+
+type jsonAPIError struct {
+	Status string              `json:"status"`
+	Code   string              `json:"code,omitempty"`
+	Title  string              `json:"title"`
+	Detail string              `json:"detail,omitempty"`
+	Source *jsonAPIErrorSource `json:"source,omitempty"`
+	Meta   map[string]string   `json:"meta,omitempty"`
+}
+
+type jsonAPIErrorSource struct {
+	Pointer string `json:"pointer"`
+}
+
+// jsonAPICodec is the Codec for JSON:API documents
+type jsonAPICodec struct{}
+
+func (jsonAPICodec) ContentType() string { return jsonAPIMediaType }
+
+// Encode a response envelope as a JSON:API document
+func (jsonAPICodec) Encode(w io.Writer, v any) error {
+	response, ok := v.(Response)
+	if !ok {
+		response = Response{Data: v}
+	}
+
+	doc := jsonAPIDocument{JSONAPI: map[string]string{"version": "1.1"}, Meta: map[string]any{}, Links: response.Links}
+	if response.Message != "" {
+		doc.Meta["message"] = response.Message
+	}
+	if len(response.Warnings) > 0 {
+		doc.Meta["warnings"] = response.Warnings
+	}
+	if len(response.SuggestedTags) > 0 {
+		doc.Meta["suggested_tags"] = response.SuggestedTags
+	}
+	if response.DidYouMean != "" {
+		doc.Meta["did_you_mean"] = response.DidYouMean
+	}
+
+	switch data := response.Data.(type) {
+	case model.Article:
+		doc.Data, doc.Included = articleResource(data, nil)
+	case RenderedArticle:
+		doc.Data, doc.Included = articleResource(data.Article, data)
+	case []model.Article:
+		doc.Data, doc.Included = articleResources(data)
+	case ListArticlesResponse:
+		doc.Data, doc.Included = articleResources(data.Articles)
+		if data.NextPageToken != "" {
+			doc.Meta["next_page_token"] = data.NextPageToken
+		}
+	case model.Attachment:
+		doc.Data = attachmentResource(data)
+	case []model.Attachment:
+		list := make([]jsonAPIResource, len(data))
+		for i, attachment := range data {
+			list[i] = attachmentResource(attachment)
+		}
+		doc.Data = list
+	case nil:
+	default:
+		// Not a resource; pass it through as metadata
+		doc.Meta["data"] = data
+	}
+
+	return json.NewEncoder(w).Encode(doc)
+}
+
+// Decode the attributes of a JSON:API request document into v
+func (jsonAPICodec) Decode(r io.Reader, v any) error {
+	var doc struct {
+		Data *struct {
+			Type       string          `json:"type"`
+			Attributes json.RawMessage `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return err
+	}
+	if doc.Data == nil || doc.Data.Attributes == nil {
+		return errors.New("missing data.attributes")
+	}
+	return json.Unmarshal(doc.Data.Attributes, v)
+}
+
+// Links of a page of a paged listing: the page itself and, unless it is
+// the last, the next one with the same query
+func pageLinks(r *http.Request, nextToken string) map[string]string {
+	links := map[string]string{"self": r.URL.RequestURI()}
+	if nextToken != "" {
+		query := r.URL.Query()
+		query.Set("page_token", nextToken)
+		links["next"] = r.URL.Path + "?" + query.Encode()
+	}
+	return links
+}
+
+func articleResources(list []model.Article) ([]jsonAPIResource, []jsonAPIResource) {
+	data := make([]jsonAPIResource, 0, len(list))
+	var included []jsonAPIResource
+	for _, article := range list {
+		resource, attachments := articleResource(article, nil)
+		data = append(data, resource)
+		included = append(included, attachments...)
+	}
+	return data, included
+}
+
+// Build an article resource with its attachments as included resources.
+// attrs overrides the value whose fields become attributes (e.g. RenderedArticle).
+func articleResource(article model.Article, attrs any) (jsonAPIResource, []jsonAPIResource) {
+	if attrs == nil {
+		attrs = article
+	}
+	id := article.PublicID()
+	resource := jsonAPIResource{
+		Type:       "articles",
+		ID:         id,
+		Attributes: jsonAPIAttributes(attrs, "attachments"),
+		Links:      map[string]string{"self": "/articles/" + id},
+	}
+
+	refs := make([]jsonAPIIdentifier, 0, len(article.Attachments))
+	var included []jsonAPIResource
+	for _, attachment := range article.Attachments {
+		a := attachmentResource(attachment)
+		a.Links = map[string]string{"self": "/articles/" + id + "/attachments/" + a.ID}
+		refs = append(refs, jsonAPIIdentifier{a.Type, a.ID})
+		included = append(included, a)
+	}
+	resource.Relationships = map[string]jsonAPIRelationship{"attachments": {Data: refs}}
+
+	return resource, included
+}
+
+func attachmentResource(attachment model.Attachment) jsonAPIResource {
+	return jsonAPIResource{
+		Type:       "attachments",
+		ID:         strconv.Itoa(attachment.ID),
+		Attributes: jsonAPIAttributes(attachment),
+	}
+}
+
+// Turn a value's JSON fields into resource attributes, leaving out the ID
+// and any fields exposed as relationships
+func jsonAPIAttributes(v any, relationships ...string) map[string]json.RawMessage {
+	raw, _ := json.Marshal(v)
+	var attrs map[string]json.RawMessage
+	json.Unmarshal(raw, &attrs)
+	delete(attrs, "id")
+	for _, name := range relationships {
+		delete(attrs, name)
+	}
+	return attrs
+}
+
+// Write an error document; a validation error gets one error object per
+// invalid field, pointing at the attribute
+func writeJSONAPIError(w http.ResponseWriter, status int, response Response) {
+	base := jsonAPIError{Status: strconv.Itoa(status), Code: response.Code, Title: http.StatusText(status)}
+	doc := jsonAPIDocument{JSONAPI: map[string]string{"version": "1.1"}}
+	for _, field := range response.Fields {
+		e := base
+		e.Detail = field.Message
+		e.Source = &jsonAPIErrorSource{Pointer: "/data/attributes/" + field.Field}
+		e.Meta = map[string]string{"rule": field.Rule}
+		doc.Errors = append(doc.Errors, e)
+	}
+	if len(doc.Errors) == 0 {
+		base.Detail = response.Error
+		doc.Errors = []jsonAPIError{base}
+	}
+	w.Header().Set("Content-Type", jsonAPIMediaType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(doc)
+}
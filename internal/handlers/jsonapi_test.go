@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-spring/internal/model"
+)
+
+// Send a request in JSON:API and decode the response document
+func callJSONAPI(t *testing.T, method, url, body string) (int, jsonAPITestDocument) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", jsonAPIMediaType)
+	if body != "" {
+		req.Header.Set("Content-Type", jsonAPIMediaType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if ct := resp.Header.Get("Content-Type"); ct != jsonAPIMediaType {
+		t.Fatalf("%s %s: Content-Type %q", method, url, ct)
+	}
+	var doc jsonAPITestDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("%s %s: %v in %s", method, url, err, data)
+	}
+	return resp.StatusCode, doc
+}
+
+// jsonAPITestDocument is a response document as a client reads it
+type jsonAPITestDocument struct {
+	Data     json.RawMessage   `json:"data"`
+	Included []jsonAPIResource `json:"included"`
+	Errors   []jsonAPIError    `json:"errors"`
+	Links    map[string]string `json:"links"`
+	Meta     map[string]any    `json:"meta"`
+	JSONAPI  map[string]string `json:"jsonapi"`
+}
+
+func (doc jsonAPITestDocument) resource(t *testing.T) jsonAPIResource {
+	t.Helper()
+	var resource jsonAPIResource
+	if err := json.Unmarshal(doc.Data, &resource); err != nil {
+		t.Fatalf("data %s: %v", doc.Data, err)
+	}
+	return resource
+}
+
+func (doc jsonAPITestDocument) resources(t *testing.T) []jsonAPIResource {
+	t.Helper()
+	var list []jsonAPIResource
+	if err := json.Unmarshal(doc.Data, &list); err != nil {
+		t.Fatalf("data %s: %v", doc.Data, err)
+	}
+	return list
+}
+
+func TestJSONAPIDocument(t *testing.T) {
+	created := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	article := model.Article{ID: 3, Title: "Resources", Desc: "d", Content: "c", Status: model.StatusPublished, Created: created,
+		Attachments: []model.Attachment{{ID: 1, Filename: "a.png", ContentType: "image/png", Size: 10}, {ID: 2, Filename: "b.pdf"}}}
+	var buf bytes.Buffer
+	if err := (jsonAPICodec{}).Encode(&buf, Response{Message: "Article retrieved successfully", Data: article}); err != nil {
+		t.Fatal(err)
+	}
+	var doc jsonAPITestDocument
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.JSONAPI["version"] != "1.1" || doc.Meta["message"] != "Article retrieved successfully" || doc.Errors != nil || doc.Links != nil {
+		t.Errorf("document %s", buf.Bytes())
+	}
+	resource := doc.resource(t)
+	if resource.Type != "articles" || resource.ID != "3" || resource.Links["self"] != "/articles/3" {
+		t.Errorf("resource %+v", resource)
+	}
+	if _, ok := resource.Attributes["id"]; ok {
+		t.Error("id among the attributes")
+	}
+	if _, ok := resource.Attributes["attachments"]; ok {
+		t.Error("attachments among the attributes")
+	}
+	if string(resource.Attributes["title"]) != `"Resources"` || string(resource.Attributes["created"]) != `"2026-10-16T09:00:00Z"` {
+		t.Errorf("attributes %v", resource.Attributes)
+	}
+
+	// Attachments are related and included
+	refs, _ := json.Marshal(resource.Relationships["attachments"].Data)
+	if string(refs) != `[{"id":"1","type":"attachments"},{"id":"2","type":"attachments"}]` {
+		t.Errorf("relationships %s", refs)
+	}
+	if len(doc.Included) != 2 || doc.Included[0].Type != "attachments" || doc.Included[0].ID != "1" ||
+		doc.Included[0].Links["self"] != "/articles/3/attachments/1" || string(doc.Included[1].Attributes["filename"]) != `"b.pdf"` {
+		t.Errorf("included %+v", doc.Included)
+	}
+
+	// Without attachments the relationship is an empty list, not null
+	buf.Reset()
+	(jsonAPICodec{}).Encode(&buf, []model.Article{{ID: 4}})
+	if !strings.Contains(buf.String(), `"relationships":{"attachments":{"data":[]}}`) || strings.Contains(buf.String(), "included") {
+		t.Errorf("list %s", buf.Bytes())
+	}
+
+	// Other data is passed through as metadata
+	buf.Reset()
+	(jsonAPICodec{}).Encode(&buf, Response{Message: "Stats", Data: map[string]int{"total": 2}})
+	var stats jsonAPITestDocument
+	if err := json.Unmarshal(buf.Bytes(), &stats); err != nil || stats.Data != nil || stats.Meta["data"].(map[string]any)["total"] != 2.0 {
+		t.Errorf("stats %s", buf.Bytes())
+	}
+}
+
+func TestJSONAPIRequests(t *testing.T) {
+	srv := newTestServer(t, 1)
+
+	// Write requests take their body from data.attributes
+	status, doc := callJSONAPI(t, "POST", srv.URL+"/articles", `{"data":{"type":"articles","attributes":{"title":"Posted","desc":"d","content":"c"}}}`)
+	if resource := doc.resource(t); status != http.StatusCreated || resource.Type != "articles" || resource.ID != "2" || string(resource.Attributes["title"]) != `"Posted"` {
+		t.Errorf("create: status %d, %+v", status, resource)
+	}
+	status, doc = callJSONAPI(t, "PUT", srv.URL+"/articles/2", `{"data":{"type":"articles","id":"2","attributes":{"title":"Put"}}}`)
+	if resource := doc.resource(t); status != http.StatusOK || string(resource.Attributes["title"]) != `"Put"` || string(resource.Attributes["desc"]) != `"d"` {
+		t.Errorf("update: status %d, %+v", status, resource)
+	}
+
+	status, doc = callJSONAPI(t, "GET", srv.URL+"/articles", "")
+	if list := doc.resources(t); status != http.StatusOK || len(list) != 2 || list[1].ID != "2" || doc.Links != nil {
+		t.Errorf("list: status %d, %d resources, links %v", status, len(list), doc.Links)
+	}
+}
+
+func TestJSONAPIErrors(t *testing.T) {
+	srv := newTestServer(t, 1)
+
+	// One error per invalid field, pointing at the attribute
+	status, doc := callJSONAPI(t, "POST", srv.URL+"/articles", `{"data":{"type":"articles","attributes":{"title":"t","status":"archived"}}}`)
+	if status != http.StatusBadRequest || len(doc.Errors) != 3 || doc.Data != nil {
+		t.Fatalf("status %d, errors %+v", status, doc.Errors)
+	}
+	pointers := map[string]string{}
+	for _, e := range doc.Errors {
+		if e.Status != "400" || e.Code != CodeValidationFailed || e.Title != "Bad Request" || e.Source == nil {
+			t.Fatalf("error %+v", e)
+		}
+		pointers[e.Source.Pointer] = e.Meta["rule"]
+	}
+	for pointer, rule := range map[string]string{"/data/attributes/desc": "required", "/data/attributes/content": "required", "/data/attributes/status": "oneof"} {
+		if pointers[pointer] != rule {
+			t.Errorf("%s: rule %q, want %q", pointer, pointers[pointer], rule)
+		}
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+		code               string
+	}{
+		{"GET", "/articles/99", "", http.StatusNotFound, CodeArticleNotFound},
+		{"POST", "/articles", `{"title":"not a document"}`, http.StatusBadRequest, CodeInvalidBody},
+		{"POST", "/articles", `{"data":{"type":"articles"}}`, http.StatusBadRequest, CodeInvalidBody},
+		{"GET", "/articles?page_token=bad", "", http.StatusBadRequest, CodeValidationFailed},
+	} {
+		status, doc := callJSONAPI(t, tc.method, srv.URL+tc.path, tc.body)
+		if status != tc.status || len(doc.Errors) != 1 || doc.Errors[0].Code != tc.code || doc.Errors[0].Status != strconv.Itoa(tc.status) ||
+			doc.Errors[0].Detail == "" || doc.Errors[0].Source != nil {
+			t.Errorf("%s %s: status %d, errors %+v", tc.method, tc.path, status, doc.Errors)
+		}
+	}
+}
+
+func TestJSONAPIPagination(t *testing.T) {
+	srv := newTestServer(t, 5)
+
+	var ids []string
+	url := srv.URL + "/articles?page_size=2&language="
+	for pages := 0; url != ""; pages++ {
+		if pages == 5 {
+			t.Fatal("no last page")
+		}
+		status, doc := callJSONAPI(t, "GET", url, "")
+		if status != http.StatusOK {
+			t.Fatalf("%s: status %d", url, status)
+		}
+		if self := srv.URL + doc.Links["self"]; self != url {
+			t.Errorf("self link %s, want %s", self, url)
+		}
+		for _, resource := range doc.resources(t) {
+			ids = append(ids, resource.ID)
+		}
+
+		// The next link keeps the query and carries the token of meta
+		url = ""
+		if next := doc.Links["next"]; next != "" {
+			if token, _ := doc.Meta["next_page_token"].(string); token == "" || !strings.Contains(next, "page_token="+token) || !strings.Contains(next, "page_size=2") {
+				t.Errorf("next link %s, meta %v", next, doc.Meta)
+			}
+			url = srv.URL + next
+		} else if _, ok := doc.Meta["next_page_token"]; ok {
+			t.Errorf("next_page_token without a next link")
+		}
+	}
+	if strings.Join(ids, ",") != "1,2,3,4,5" {
+		t.Errorf("pages walked %v", ids)
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// Encoding a listing whole holds the JSON of every article in memory at
+// once, twice over while the encoder's buffer grows, and leaves it all to
+// the garbage collector. Instead the JSON codec writes the envelope of a
+// list an element at a time through a pooled buffer, passing it on every
+// jsonFlushBytes; the bytes are the same as json.Encoder's. An element
+// that fails to encode cuts the response short, where the whole encoding
+// would have written nothing: writeResponse logs it either way.
+
+const (
+	jsonFlushBytes      = 32 << 10 // written out when the buffer holds this much
+	maxPooledJSONBuffer = 64 << 10 // larger buffers are left to the collector
+)
+
+// jsonBuffer is a buffer with an encoder writing to it, pooled to spare
+// allocating both for every response
+type jsonBuffer struct {
+	bytes.Buffer
+	enc *json.Encoder
+}
+
+var jsonBuffers = sync.Pool{New: func() any {
+	b := &jsonBuffer{}
+	b.enc = json.NewEncoder(&b.Buffer)
+	return b
+}}
+
+func getJSONBuffer() *jsonBuffer {
+	b := jsonBuffers.Get().(*jsonBuffer)
+	b.Reset()
+	return b
+}
+
+func putJSONBuffer(b *jsonBuffer) {
+	if b.Cap() <= maxPooledJSONBuffer {
+		jsonBuffers.Put(b)
+	}
+}
+
+// Append v without the newline the encoder ends it with
+func (b *jsonBuffer) encode(v any) error {
+	if err := b.enc.Encode(v); err != nil {
+		return err
+	}
+	b.Truncate(b.Len() - 1)
+	return nil
+}
+
+var (
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// Whether response is a message and a list that encodes as a JSON array of
+// its elements, with no other fields: not nil (null), not bytes (base64),
+// nor a list type that marshals itself
+func streamable(response Response, list reflect.Value) bool {
+	if response.Error != "" || response.Code != "" || len(response.Fields) > 0 ||
+		len(response.Warnings) > 0 || len(response.SuggestedTags) > 0 || response.DidYouMean != "" {
+		return false
+	}
+	if list.Kind() != reflect.Slice || list.IsNil() || list.Type().Elem().Kind() == reflect.Uint8 {
+		return false
+	}
+	t := reflect.PointerTo(list.Type())
+	return !t.Implements(jsonMarshalerType) && !t.Implements(textMarshalerType)
+}
+
+func streamJSONResponse(w io.Writer, response Response, list reflect.Value) error {
+	buf := getJSONBuffer()
+	defer putJSONBuffer(buf)
+	buf.WriteString(`{"message":`)
+	if err := buf.encode(response.Message); err != nil {
+		return err
+	}
+	buf.WriteString(`,"data":[`)
+	for i := range list.Len() {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		// By pointer, as the encoder finds the elements of a slice, so
+		// MarshalJSON methods on pointers apply
+		if err := buf.encode(list.Index(i).Addr().Interface()); err != nil {
+			return err
+		}
+		if buf.Len() >= jsonFlushBytes {
+			if _, err := w.Write(buf.Bytes()); err != nil {
+				return err
+			}
+			buf.Reset()
+		}
+	}
+	buf.WriteString("]}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
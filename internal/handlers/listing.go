@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+
+	"go-spring/internal/model"
+)
+
+// GET /articles is the busiest route, and encoding the whole article set
+// through reflection is most of its cost. The data of the common listings,
+// the full list and the pages clients walk, is kept as marshaled JSON and
+// written out behind an envelope with the localized message, so repeated
+// reads encode only the message. This works under the response cache:
+// that one keeps whole responses per language, host and representation for
+// RESPONSE_CACHE_TTL, these are shared by all of them.
+//
+// The listings are dropped on every article event. They are also tied to
+// the snapshot they were encoded from, as events are published before the
+// writer releases articlesMutex and a reader may still see the old
+// snapshot. Only the JSON representation is kept, and only while no plugin
+// has an OnServeList hook, which may change a listing per request.
+
+// How many listings are kept at most; the full list and the first pages
+// are asked for first and so get in
+const listingCacheEntries = 64
+
+type listingState struct {
+	listingsMutex sync.Mutex
+	listings      struct {
+		snapshot *[]model.Article
+		data     map[string][]byte
+	}
+}
+
+func (app *App) clearListings() {
+	app.listingsMutex.Lock()
+	defer app.listingsMutex.Unlock()
+	app.listings.snapshot = nil
+	clear(app.listings.data)
+}
+
+// Whether a request's listing can come from the cache. The cache holds
+// what readers without credentials see, see confidential.go.
+func (app *App) cachedListing(r *http.Request) bool {
+	if negotiateCodec(r) != defaultCodec || r.Header.Get("Authorization") != "" {
+		return false
+	}
+	for _, p := range app.registeredPlugins() {
+		if p.OnServeList != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// The JSON of the listing key, from the cache or marshaled from what build
+// returns
+func (app *App) listingJSON(key string, build func() (any, error)) ([]byte, error) {
+	snapshot := app.loadSnapshot()
+	app.listingsMutex.Lock()
+	data, ok := app.listings.data[key]
+	ok = ok && app.listings.snapshot == snapshot
+	app.listingsMutex.Unlock()
+	if ok {
+		return data, nil
+	}
+
+	// build may see a newer snapshot, but never an older one; what it
+	// returns is only served while snapshot is current
+	v, err := build()
+	if err != nil {
+		return nil, err
+	}
+	if data, err = json.Marshal(v); err != nil {
+		return nil, err
+	}
+	app.listingsMutex.Lock()
+	defer app.listingsMutex.Unlock()
+	if app.listings.snapshot != snapshot {
+		app.listings.snapshot = snapshot
+		clear(app.listings.data)
+	}
+	if len(app.listings.data) < listingCacheEntries {
+		app.listings.data[key] = data
+	}
+	return data, nil
+}
+
+// Write a 200 JSON envelope around data that is JSON already, the same
+// bytes writeResponse would write. data is written as it is, not copied.
+func (app *App) writeListing(w http.ResponseWriter, r *http.Request, message string, data []byte) {
+	response := Response{Message: message}
+	app.localize(w, r, &response)
+	buf := getJSONBuffer()
+	defer putJSONBuffer(buf)
+	buf.WriteString(`{"message":`)
+	if err := buf.encode(response.Message); err != nil {
+		log.Printf("Error: encoding listing message: %v", err)
+		return
+	}
+	buf.WriteString(`,"data":`)
+
+	w.Header().Set("Content-Type", defaultCodec.ContentType())
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+	w.Write(data)
+	io.WriteString(w, "}\n")
+}
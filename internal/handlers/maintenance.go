@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go-spring/internal/jobs"
+	"go-spring/internal/store"
+)
+
+// Kind of the job that compacts the data store
+const jobCompact = "compact"
+
+// CompactResult is the result of a compact job. The sizes are left out for
+// stores that can't tell theirs.
+type CompactResult struct {
+	Backend        string `json:"backend"` // gob, sqlite or postgres
+	BytesBefore    int64  `json:"bytes_before,omitempty"`
+	BytesAfter     int64  `json:"bytes_after,omitempty"`
+	ReclaimedBytes int64  `json:"reclaimed_bytes"`
+	RewrappedKeys  int    `json:"rewrapped_keys,omitempty"` // data keys wrapped anew after a KMS key rotation
+}
+
+// POST /admin/compact - Compact the data store in the background
+func (app *App) startCompact(w http.ResponseWriter, r *http.Request) {
+	app.acceptJob(w, r, jobCompact, struct{}{})
+}
+
+// Write the data whole with the current STORE_COMPRESSION and encryption
+// key, rebuild the indexes and vacuum a SQL database, and wrap the data
+// keys again if the KMS key has rotated
+func (app *App) runCompactJob(ctx context.Context, job jobs.Job) error {
+	if app.dataStore == nil {
+		return errors.New("no data store")
+	}
+	if store.IsReadOnly(app.dataStore) {
+		return store.ErrReadOnly
+	}
+	result := CompactResult{Backend: storeBackend(app.cfg.Store)}
+	before, sized := store.Size(ctx, app.dataStore)
+
+	if app.contentKeys != nil && app.cfg.KMSKey != "" {
+		var err error
+		if result.RewrappedKeys, err = RewrapEncryptionKeys(ctx, app.cfg); err != nil {
+			return err
+		}
+	}
+
+	if err := app.saveArticles(); err != nil {
+		return err
+	}
+	// Not while the saver writes; without STORE_TIMEOUT, as a vacuum of a
+	// large database takes longer than any save
+	app.saveMutex.Lock()
+	err := store.Vacuum(ctx, app.dataStore)
+	app.saveMutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if after, ok := store.Size(ctx, app.dataStore); sized && ok {
+		result.BytesBefore, result.BytesAfter = before, after
+		result.ReclaimedBytes = max(before-after, 0)
+	}
+	return jobs.SetResult(ctx, result)
+}
+
+// Call queue every night at the local time of at until ctx is done
+func runNightly(ctx context.Context, at time.Time, queue func()) {
+	for {
+		timer := time.NewTimer(time.Until(nextDailyRun(time.Now(), at)))
+		select {
+		case <-timer.C:
+			queue()
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// Queue a compact job, unless the store can't be written
+func (app *App) queueCompact() {
+	if !store.IsReadOnly(app.dataStore) {
+		app.enqueueJob(jobCompact, struct{}{})
+	}
+}
+
+// The first time after now at the hour and minute of at, local time
+func nextDailyRun(now, at time.Time) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
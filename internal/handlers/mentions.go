@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"go-spring/internal/model"
+	"go-spring/internal/notify"
+)
+
+// A published article that mentions a user as @username notifies them
+// (user.mentioned) through the channels of their preferences. Only new
+// mentions count: those of an article as it is published, and those added
+// to a published one. The user who made the change isn't told, and neither
+// are users who may not read a confidential article.
+
+// An @ not preceded by a word character, dot or @, so email addresses
+// don't count, and the username characters after it
+var mentionRe = regexp.MustCompile(`(?:^|[^\w@.])@([a-zA-Z0-9._-]{1,64})`)
+
+// The users a published article mentions, each once, in the order of
+// their first mention; none for a draft
+func mentionedUsers(article model.Article, known []model.User) []model.User {
+	if !article.IsPublished() {
+		return nil
+	}
+	var mentioned []model.User
+	text := article.Title + "\n" + article.Desc + "\n" + article.Content
+	for _, match := range mentionRe.FindAllStringSubmatch(text, -1) {
+		// A sentence may end right after the name
+		for _, name := range []string{match[1], strings.TrimRight(match[1], "._-")} {
+			i := indexOfUser(known, name)
+			if i < 0 {
+				continue
+			}
+			if indexOfUser(mentioned, known[i].Username) < 0 {
+				mentioned = append(mentioned, known[i])
+			}
+			break
+		}
+	}
+	return mentioned
+}
+
+// The index of the user named name, -1 if there is none
+func indexOfUser(list []model.User, name string) int {
+	for i, u := range list {
+		if strings.EqualFold(u.Username, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Notify the users after mentions and before didn't; before is the zero
+// article for a new one
+func (app *App) notifyMentions(ctx context.Context, before, after model.Article) {
+	known := app.Users()
+	if len(known) == 0 {
+		return
+	}
+	actor, _ := ctx.Value(userKey{}).(model.User)
+	previous := mentionedUsers(before, known)
+	for _, user := range mentionedUsers(after, known) {
+		if indexOfUser(previous, user.Username) >= 0 || strings.EqualFold(user.Username, actor.Username) {
+			continue
+		}
+		if after.Confidential && !app.confidentialReader(user, true)(after) {
+			continue
+		}
+		article := withoutContent(after)
+		app.Notify(ctx, notify.Notification{Event: notify.EventMentioned, Article: &article, Recipient: user.Username})
+	}
+}
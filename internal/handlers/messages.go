@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"go-spring/internal/config"
+	"go-spring/internal/i18n"
+)
+
+type messagesState struct {
+	// The bundled translations with those of MESSAGES_DIR; set up by initMessages
+	messageCatalog i18n.Catalog
+}
+
+func (app *App) initMessages(cfg config.Config) error {
+	app.messageCatalog = i18n.Bundled()
+	if cfg.MessagesDir == "" {
+		return nil
+	}
+	catalog, err := i18n.Load(app.messageCatalog, cfg.MessagesDir)
+	if err != nil {
+		return err
+	}
+	app.messageCatalog = catalog
+	return nil
+}
+
+// The message sources in lookup order: those of plugins, then the catalog
+func (app *App) messageSources() []i18n.Source {
+	var sources []i18n.Source
+	for _, p := range app.registeredPlugins() {
+		if p.Messages != nil {
+			sources = append(sources, p.Messages)
+		}
+	}
+	return append(sources, app.messageCatalog)
+}
+
+// The language of the messages for a request (Accept-Language)
+func (app *App) requestLanguage(r *http.Request) string {
+	header := r.Header.Get("Accept-Language")
+	if header == "" {
+		return i18n.Default
+	}
+	var available []string
+	for _, source := range app.messageSources() {
+		available = append(available, source.Languages()...)
+	}
+	return i18n.Negotiate(header, available)
+}
+
+// Translate a message, or just its part before ": " when the rest is
+// detail such as an error; messages without a translation stay in English
+func (app *App) translate(lang, message string) string {
+	if message == "" {
+		return ""
+	}
+	sources := app.messageSources()
+	lookup := func(s string) (string, bool) {
+		for _, source := range sources {
+			if t, ok := source.Translate(lang, s); ok {
+				return t, true
+			}
+		}
+		return s, false
+	}
+	if t, ok := lookup(message); ok {
+		return t
+	}
+	if prefix, detail, ok := strings.Cut(message, ": "); ok {
+		if t, ok := lookup(prefix); ok {
+			return t + ": " + detail
+		}
+	}
+	return message
+}
+
+// Translate the messages of a response into the request's language
+func (app *App) localize(w http.ResponseWriter, r *http.Request, response *Response) {
+	lang := app.requestLanguage(r)
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
+
+	response.Message = app.translate(lang, response.Message)
+	if len(response.Warnings) > 0 {
+		warnings := slices.Clone(response.Warnings)
+		for i := range warnings {
+			warnings[i].Message = app.translate(lang, warnings[i].Message)
+		}
+		response.Warnings = warnings
+	}
+	if len(response.Fields) == 0 {
+		response.Error = app.translate(lang, response.Error)
+		return
+	}
+	fields := slices.Clone(response.Fields)
+	messages := make([]string, len(fields))
+	for i := range fields {
+		fields[i].Message = fields[i].Localize(func(s string) string { return app.translate(lang, s) })
+		messages[i] = fields[i].Message
+	}
+	response.Fields, response.Error = fields, strings.Join(messages, "; ")
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"go-spring/internal/config"
+)
+
+// Middleware is one stage of the request pipeline. Router applies the
+// stages of middlewarePipeline in order, the first one outermost.
+type Middleware struct {
+	Name string
+
+	// Routes selects the routes the stage wraps; nil wraps the whole router,
+	// so the stage also sees requests that match no route (CORS preflights,
+	// 404s). Stages for the whole router run before the per-route ones.
+	Routes func(Route) bool
+
+	// Enabled reports whether the configuration turns the stage on; nil is
+	// always on. MIDDLEWARE_DISABLE turns off any stage that isn't Required.
+	Enabled  func(config.Config) bool
+	Required bool
+
+	// Wrap returns the handler with the stage around next. For per-route
+	// stages route is the route being wrapped, else the zero Route.
+	Wrap func(route Route, next http.Handler) http.Handler
+}
+
+// Route groups for per-route stages
+var (
+	adminRoutes = func(r Route) bool {
+		return r.admin || strings.HasPrefix(r.Path, "/admin/") || strings.HasPrefix(r.Path, "/jobs/")
+	}
+	// The push subscriptions of the signed-in user too; the public key is open
+	accountRoutes = func(r Route) bool {
+		return strings.HasPrefix(r.Path, "/account/") || (strings.HasPrefix(r.Path, "/push/") && r.Method == http.MethodPost)
+	}
+	postRoutes   = func(r Route) bool { return r.Method == http.MethodPost }
+	cachedRoutes = func(r Route) bool { return r.Cached }
+)
+
+// The request pipeline, outermost first. Features that wrap requests plug
+// in here rather than in Router.
+func (app *App) middlewarePipeline() []Middleware {
+	return []Middleware{
+		{Name: "log", Wrap: func(_ Route, next http.Handler) http.Handler { return logRequests(next) }},
+		{Name: "credentials", Wrap: func(_ Route, next http.Handler) http.Handler { return withAuthCache(next) }, Required: true},
+		{Name: "cors", Wrap: func(_ Route, next http.Handler) http.Handler { return app.allowCORS(next) },
+			Enabled: func(cfg config.Config) bool { return len(cfg.CORSOrigins) > 0 }},
+		{Name: "ratelimit", Wrap: func(_ Route, next http.Handler) http.Handler { return app.limitRate(next) },
+			Enabled: func(cfg config.Config) bool { return cfg.RateLimit > 0 }},
+		{Name: "inflight", Wrap: func(_ Route, next http.Handler) http.Handler { return app.limitInFlight(next) },
+			Enabled: func(cfg config.Config) bool { return cfg.MaxInFlight > 0 }},
+		{Name: "compress", Wrap: func(_ Route, next http.Handler) http.Handler { return app.compressResponses(next) },
+			Enabled: func(cfg config.Config) bool { return cfg.Compression }},
+		{Name: "errors", Wrap: func(_ Route, next http.Handler) http.Handler { return app.errorPayloads(next) }},
+		{Name: "drain", Wrap: func(_ Route, next http.Handler) http.Handler { return app.closeWhenDraining(next) }, Required: true},
+		{Name: "mode", Wrap: func(_ Route, next http.Handler) http.Handler { return app.refuseInMode(next) }, Required: true},
+		{Name: "signatures", Wrap: func(_ Route, next http.Handler) http.Handler { return app.verifySignatures(next) },
+			Enabled: func(cfg config.Config) bool { return cfg.SigningKeysFile != "" }, Required: true},
+
+		{Name: "cache-control", Routes: func(r Route) bool { return cacheControlPolicy(r, app.cfg.CacheControl) != "" },
+			Wrap: func(r Route, next http.Handler) http.Handler {
+				return withCacheControl(cacheControlPolicy(r, app.cfg.CacheControl), next.ServeHTTP)
+			}},
+		{Name: "quotas", Routes: quotaRoutes,
+			Wrap: func(_ Route, next http.Handler) http.Handler { return app.limitWorkspaceRate(next.ServeHTTP) }},
+		{Name: "scopes", Routes: func(Route) bool { return true }, Required: true,
+			Enabled: func(cfg config.Config) bool { return cfg.SigningKeysFile != "" },
+			Wrap:    app.requireScope},
+		{Name: "admin", Routes: adminRoutes, Required: true,
+			Wrap: func(_ Route, next http.Handler) http.Handler { return app.requireAdmin(next.ServeHTTP) }},
+		{Name: "account", Routes: accountRoutes, Required: true,
+			Wrap: func(_ Route, next http.Handler) http.Handler { return app.requireUser(next.ServeHTTP) }},
+		{Name: "workspaces", Routes: workspaceRoutes, Required: true,
+			Wrap: func(r Route, next http.Handler) http.Handler { return app.requireWorkspaceRole(r, next.ServeHTTP) }},
+		{Name: "actor", Routes: actorRoutes,
+			Wrap: func(_ Route, next http.Handler) http.Handler { return app.identifyActor(next) }},
+		{Name: "captcha", Routes: app.captchaRoutes,
+			Enabled: func(cfg config.Config) bool { return cfg.CaptchaProvider != "" },
+			Wrap:    func(_ Route, next http.Handler) http.Handler { return app.requireCaptcha(next) }},
+		{Name: "interceptors", Routes: func(r Route) bool { return len(app.routeInterceptors(r)) > 0 }, Wrap: app.intercept},
+		{Name: "idempotency", Routes: postRoutes,
+			Wrap: func(_ Route, next http.Handler) http.Handler { return app.idempotent(next.ServeHTTP) }},
+		{Name: "analytics", Routes: viewRoutes,
+			Wrap: func(_ Route, next http.Handler) http.Handler { return app.countViews(next) }},
+		{Name: "cache", Routes: cachedRoutes,
+			Wrap: func(_ Route, next http.Handler) http.Handler { return app.cacheResponses(next.ServeHTTP) }},
+	}
+}
+
+// Check MIDDLEWARE_DISABLE against the pipeline
+func (app *App) checkMiddlewareConfig(cfg config.Config) error {
+	for _, name := range cfg.MiddlewareDisable {
+		i := slices.IndexFunc(app.middlewarePipeline(), func(m Middleware) bool { return m.Name == name })
+		switch {
+		case i < 0:
+			return fmt.Errorf("MIDDLEWARE_DISABLE: unknown middleware %q", name)
+		case app.middlewarePipeline()[i].Required:
+			return fmt.Errorf("MIDDLEWARE_DISABLE: %s can't be disabled", name)
+		}
+	}
+	return nil
+}
+
+// The stages turned on by the configuration, then those of plugins
+func (app *App) activeMiddleware(cfg config.Config) []Middleware {
+	var active []Middleware
+	for _, m := range app.middlewarePipeline() {
+		if (m.Enabled == nil || m.Enabled(cfg)) && (m.Required || !slices.Contains(cfg.MiddlewareDisable, m.Name)) {
+			active = append(active, m)
+		}
+	}
+	for _, p := range app.registeredPlugins() {
+		for _, m := range p.Middleware {
+			if m.Enabled == nil || m.Enabled(cfg) {
+				active = append(active, m)
+			}
+		}
+	}
+	return active
+}
+
+// Wrap a route's handler in the per-route stages that select it
+func wrapRoute(stages []Middleware, route Route, handler http.Handler) http.Handler {
+	for _, m := range slices.Backward(stages) {
+		if m.Routes != nil && m.Routes(route) {
+			handler = m.Wrap(route, handler)
+		}
+	}
+	return handler
+}
+
+// Wrap the router in the stages for every request
+func wrapRouter(stages []Middleware, router http.Handler) http.Handler {
+	for _, m := range slices.Backward(stages) {
+		if m.Routes == nil {
+			router = m.Wrap(Route{}, router)
+		}
+	}
+	return router
+}
+
+// Log one line per request: method, path, status, size and duration
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		lw := &logWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(lw, r)
+		log.Printf("%s %s %d %dB %s", r.Method, r.URL.RequestURI(), lw.status, lw.size, time.Since(start).Round(time.Microsecond))
+	})
+}
+
+type logWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (lw *logWriter) WriteHeader(status int) {
+	lw.status = status
+	lw.ResponseWriter.WriteHeader(status)
+}
+
+func (lw *logWriter) Write(b []byte) (int, error) {
+	n, err := lw.ResponseWriter.Write(b)
+	lw.size += int64(n)
+	return n, err
+}
+
+func (lw *logWriter) Flush() {
+	if f, ok := lw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-spring/internal/config"
+	"go-spring/internal/store"
+)
+
+// ServerMode is what the server accepts: in read-only mode changes answer
+// 503 Service Unavailable with Retry-After, in maintenance mode everything
+// but the admin routes does. Used during migrations, restores and store
+// failovers.
+type ServerMode struct {
+	Mode       string    `json:"mode"`
+	RetryAfter int       `json:"retry_after"` // seconds refused clients are told to wait
+	Since      time.Time `json:"since"`
+}
+
+// ModeRequest is the body of PUT /admin/mode
+type ModeRequest struct {
+	Mode       string `json:"mode" validate:"required,oneof=normal read-only maintenance"`
+	RetryAfter int    `json:"retry_after" validate:"min=0"` // seconds; MODE_RETRY_AFTER if 0
+}
+
+type modeState struct {
+	modeMutex  sync.RWMutex
+	serverMode ServerMode
+}
+
+// Start in SERVER_MODE, or read-only on a store that can't be saved
+func (app *App) initMode(cfg config.Config) {
+	mode := cfg.Mode
+	if store.IsReadOnly(app.dataStore) {
+		mode = config.ModeReadOnly
+	}
+	app.setMode(mode, 0)
+}
+
+// Switch the server to mode; retryAfter of 0 is MODE_RETRY_AFTER
+func (app *App) setMode(mode string, retryAfter int) ServerMode {
+	if mode == "" {
+		mode = config.ModeNormal
+	}
+	if retryAfter == 0 {
+		retryAfter = int(app.cfg.ModeRetryAfter.Seconds())
+	}
+	app.modeMutex.Lock()
+	defer app.modeMutex.Unlock()
+	app.serverMode = ServerMode{Mode: mode, RetryAfter: retryAfter, Since: time.Now()}
+	return app.serverMode
+}
+
+func (app *App) currentMode() ServerMode {
+	app.modeMutex.RLock()
+	defer app.modeMutex.RUnlock()
+	return app.serverMode
+}
+
+// Whether the mode refuses a request; reads aren't changes, and the mode
+// can always be switched back or the server shut down
+func modeRefuses(mode, method, path string) bool {
+	switch {
+	case controlPath(path):
+		return false
+	case mode == config.ModeMaintenance:
+		return !adminPath(path)
+	case mode == config.ModeReadOnly:
+		return isChange(method)
+	}
+	return false
+}
+
+// Whether a request with method may change data
+func isChange(method string) bool {
+	return method != http.MethodGet && method != http.MethodHead && method != http.MethodOptions
+}
+
+// The admin routes that control this server rather than change data
+func controlPath(path string) bool {
+	return path == "/admin/mode" || path == "/admin/shutdown" || path == "/admin/drain"
+}
+
+// The admin routes that stay up in maintenance mode
+func adminPath(path string) bool {
+	return adminRoutes(Route{Path: path})
+}
+
+// refuseInMode answers 503 to the requests the server mode refuses, and to
+// changes on a replica
+func (app *App) refuseInMode(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.replicating() && isChange(r.Method) && !controlPath(r.URL.Path) {
+			app.writeError(w, r, http.StatusServiceUnavailable, CodeUnavailable, "Server is a read-only replica")
+			return
+		}
+		mode := app.currentMode()
+		if !modeRefuses(mode.Mode, r.Method, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(mode.RetryAfter))
+		if mode.Mode == config.ModeReadOnly {
+			app.writeError(w, r, http.StatusServiceUnavailable, CodeUnavailable, "Server is read-only")
+		} else {
+			app.writeError(w, r, http.StatusServiceUnavailable, CodeUnavailable, "Server is down for maintenance")
+		}
+	})
+}
+
+// GET /admin/mode - Show the server mode
+func (app *App) getMode(w http.ResponseWriter, r *http.Request) {
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Server mode retrieved successfully", Data: app.currentMode()})
+}
+
+// PUT /admin/mode - Switch the server mode
+func (app *App) putMode(w http.ResponseWriter, r *http.Request) {
+	var req ModeRequest
+	if err := decodeBody(r, &req); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	if err := validateRequest(req); err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+	if req.Mode == config.ModeNormal && store.IsReadOnly(app.dataStore) {
+		app.writeError(w, r, http.StatusConflict, CodeConflict, "Data store is locked by another process")
+		return
+	}
+	mode := app.setMode(req.Mode, req.RetryAfter)
+	log.Printf("Server mode: %s", mode.Mode)
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Server mode updated successfully", Data: mode})
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// msgpackCodec implements MessagePack (https://msgpack.org). Responses use
+// the same field names as JSON; extension types are skipped when decoding.
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string { return "application/msgpack" }
+
+func (msgpackCodec) Encode(w io.Writer, v any) error {
+	value, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(appendMsgpack(nil, value))
+	return err
+}
+
+func appendMsgpack(b []byte, value any) []byte {
+	switch v := value.(type) {
+	case nil:
+		return append(b, 0xc0)
+	case bool:
+		if v {
+			return append(b, 0xc3)
+		}
+		return append(b, 0xc2)
+	case json.Number:
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return appendMsgpackInt(b, n)
+		}
+		if n, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			b = append(b, 0xcf) // above MaxInt64
+			return binary.BigEndian.AppendUint64(b, n)
+		}
+		f, _ := strconv.ParseFloat(string(v), 64)
+		b = append(b, 0xcb)
+		return binary.BigEndian.AppendUint64(b, math.Float64bits(f))
+	case string:
+		b = appendMsgpackHeader(b, len(v), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		return append(b, v...)
+	case []any:
+		b = appendMsgpackHeader(b, len(v), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, item := range v {
+			b = appendMsgpack(b, item)
+		}
+		return b
+	case orderedMap:
+		b = appendMsgpackHeader(b, len(v), 0x80, 15, 0, 0xde, 0xdf)
+		for _, field := range v {
+			b = appendMsgpack(b, field.Key)
+			b = appendMsgpack(b, field.Value)
+		}
+		return b
+	}
+	panic(fmt.Sprintf("msgpack: unexpected %T", value))
+}
+
+func appendMsgpackInt(b []byte, n int64) []byte {
+	switch {
+	case n >= 0 && n <= 127:
+		return append(b, byte(n))
+	case n < 0 && n >= -32:
+		return append(b, byte(n))
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		b = append(b, 0xd2)
+		return binary.BigEndian.AppendUint32(b, uint32(n))
+	}
+	b = append(b, 0xd3)
+	return binary.BigEndian.AppendUint64(b, uint64(n))
+}
+
+// Write a string, array or map header: the fix form for short lengths,
+// then the 8 (strings only), 16 and 32 bit forms
+func appendMsgpackHeader(b []byte, n int, fix byte, fixMax int, code8, code16, code32 byte) []byte {
+	switch {
+	case n <= fixMax:
+		return append(b, fix|byte(n))
+	case code8 != 0 && n <= math.MaxUint8:
+		return append(b, code8, byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, code16)
+		return binary.BigEndian.AppendUint16(b, uint16(n))
+	}
+	b = append(b, code32)
+	return binary.BigEndian.AppendUint32(b, uint32(n))
+}
+
+func (msgpackCodec) Decode(r io.Reader, v any) error {
+	value, err := readMsgpack(bufio.NewReader(r), 0)
+	if err != nil {
+		return err
+	}
+	return assignGeneric(reflect.ValueOf(v).Elem(), value)
+}
+
+var errMsgpackDepth = errors.New("msgpack: nesting too deep")
+
+// Read one value into nil, bool, int64, uint64, float64, string, []byte,
+// []any or map[string]any
+func readMsgpack(r *bufio.Reader, depth int) (any, error) {
+	if depth > 64 {
+		return nil, errMsgpackDepth
+	}
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return readMsgpackString(r, int(c&0x1f))
+	case c&0xf0 == 0x90:
+		return readMsgpackList(r, int(c&0x0f), depth)
+	case c&0xf0 == 0x80:
+		return readMsgpackMap(r, int(c&0x0f), depth)
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := readMsgpackUint(r, 1<<(c-0xcc))
+		return n, err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		n, err := readMsgpackUint(r, size)
+		shift := 64 - 8*size
+		return int64(n<<shift) >> shift, err // sign extend
+	case 0xca:
+		n, err := readMsgpackUint(r, 4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := readMsgpackUint(r, 8)
+		return math.Float64frombits(n), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := readMsgpackUint(r, 1<<(c-0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackString(r, int(n))
+	case 0xc4, 0xc5, 0xc6:
+		n, err := readMsgpackUint(r, 1<<(c-0xc4))
+		if err != nil {
+			return nil, err
+		}
+		s, err := readMsgpackString(r, int(n))
+		if err != nil {
+			return nil, err
+		}
+		return []byte(s.(string)), nil
+	case 0xdc, 0xdd:
+		n, err := readMsgpackUint(r, 2<<(c-0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackList(r, int(n), depth)
+	case 0xde, 0xdf:
+		n, err := readMsgpackUint(r, 2<<(c-0xde))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackMap(r, int(n), depth)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		// fixext: type byte plus 1 to 16 data bytes
+		_, err := r.Discard(1 + 1<<(c-0xd4))
+		return nil, err
+	case 0xc7, 0xc8, 0xc9:
+		n, err := readMsgpackUint(r, 1<<(c-0xc7))
+		if err == nil {
+			_, err = r.Discard(1 + int(n))
+		}
+		return nil, err
+	}
+	return nil, fmt.Errorf("msgpack: invalid type byte 0x%02x", c)
+}
+
+func readMsgpackUint(r *bufio.Reader, size int) (uint64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[8-size:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+// Read a string of n bytes, growing the buffer as the bytes arrive rather
+// than trusting n, so a short body can't claim a huge string
+func readMsgpackString(r *bufio.Reader, n int) (any, error) {
+	var buf strings.Builder
+	if _, err := io.CopyN(&buf, r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.String(), nil
+}
+
+func readMsgpackList(r *bufio.Reader, n int, depth int) (any, error) {
+	list := []any{}
+	for range n {
+		item, err := readMsgpack(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+func readMsgpackMap(r *bufio.Reader, n int, depth int) (any, error) {
+	m := map[string]any{}
+	for range n {
+		key, err := readMsgpack(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		value, err := readMsgpack(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(key)] = value
+	}
+	return m, nil
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	doc := newCodecDoc()
+	got := roundTrip(t, msgpackCodec{}, doc)
+	if !reflect.DeepEqual(got, doc) {
+		t.Errorf("round trip\n got %+v\nwant %+v", got, doc)
+	}
+}
+
+func TestMsgpackEncode(t *testing.T) {
+	long := func(n int) string { return strings.Repeat("x", n) }
+	for _, tt := range []struct {
+		value any
+		want  []byte // the start of the encoding
+	}{
+		{nil, []byte{0xc0}},
+		{true, []byte{0xc3}},
+		{false, []byte{0xc2}},
+		{json.Number("0"), []byte{0x00}},
+		{json.Number("127"), []byte{0x7f}},
+		{json.Number("128"), []byte{0xd2, 0, 0, 0, 0x80}},
+		{json.Number("-32"), []byte{0xe0}},
+		{json.Number("-33"), []byte{0xd2, 0xff, 0xff, 0xff, 0xdf}},
+		{json.Number("2147483648"), []byte{0xd3, 0, 0, 0, 0, 0x80, 0, 0, 0}},
+		{json.Number("-9223372036854775808"), []byte{0xd3, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{json.Number("18446744073709551615"), []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{json.Number("1.5"), []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"", []byte{0xa0}},
+		{long(31), []byte{0xbf, 'x'}},
+		{long(32), []byte{0xd9, 32, 'x'}},
+		{long(256), []byte{0xda, 1, 0, 'x'}},
+		{long(65536), []byte{0xdb, 0, 1, 0, 0, 'x'}},
+		{make([]any, 15), []byte{0x9f, 0xc0}},
+		{make([]any, 16), []byte{0xdc, 0, 16, 0xc0}},
+		{orderedMap{{"a", nil}}, []byte{0x81, 0xa1, 'a', 0xc0}},
+		{make(orderedMap, 16), []byte{0xde, 0, 16, 0xa0, 0xc0}},
+	} {
+		if got := appendMsgpack(nil, tt.value); !bytes.HasPrefix(got, tt.want) {
+			t.Errorf("%.20v encoded % x, want % x...", tt.value, got[:min(len(got), 12)], tt.want)
+		}
+	}
+}
+
+func TestMsgpackDecode(t *testing.T) {
+	read := func(b ...byte) (any, error) { return readMsgpack(bufio.NewReader(bytes.NewReader(b)), 0) }
+	for _, tt := range []struct {
+		in   []byte
+		want any
+	}{
+		{[]byte{0xcc, 0xff}, uint64(255)},
+		{[]byte{0xcd, 0xff, 0xff}, uint64(65535)},
+		{[]byte{0xd0, 0x80}, int64(-128)},
+		{[]byte{0xd1, 0x80, 0x00}, int64(-32768)},
+		{[]byte{0xca, 0x3f, 0xc0, 0, 0}, 1.5},
+		{[]byte{0xc4, 3, 0, 0xff, 'a'}, []byte{0, 0xff, 'a'}},
+		{[]byte{0xc5, 0, 1, 0x80}, []byte{0x80}},
+		{[]byte{0x92, 0x91, 0x01, 0x80}, []any{[]any{int64(1)}, map[string]any{}}},
+		{[]byte{0x82, 0x01, 0xa1, 'a', 0xa1, 'b', 0xc4, 1, 'c'}, map[string]any{"1": "a", "b": []byte("c")}},
+		// Extensions are skipped
+		{[]byte{0x92, 0xd4, 0x01, 0x02, 0xc7, 0x02, 0x01, 0xaa, 0xbb}, []any{nil, nil}},
+	} {
+		got, err := read(tt.in...)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("% x decoded %#v, %v; want %#v", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range [][]byte{
+		{},
+		{0xc1},                         // never used
+		{0xa3, 'a', 'b'},               // truncated string
+		{0xcd, 0x01},                   // truncated integer
+		{0x92, 0x01},                   // missing array item
+		{0x81, 0xa1, 'a'},              // missing map value
+		{0xdb, 0xff, 0xff, 0xff, 0xff}, // longer than the body
+	} {
+		if _, err := read(bad...); err == nil {
+			t.Errorf("% x accepted", bad)
+		}
+	}
+	deep := append(bytes.Repeat([]byte{0x91}, 100), 0xc0)
+	if _, err := read(deep...); !errors.Is(err, errMsgpackDepth) {
+		t.Errorf("deep nesting: %v", err)
+	}
+	if _, err := read(0xa3, 'a'); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated: %v", err)
+	}
+
+	var doc codecDoc
+	if err := (msgpackCodec{}).Decode(bytes.NewReader([]byte{0x81, 0xa5, 'c', 'o', 'u', 'n', 't', 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}), &doc); err == nil {
+		t.Errorf("fraction accepted as an integer: %+v", doc)
+	}
+	if err := (msgpackCodec{}).Decode(bytes.NewReader([]byte{0x81, 0xa3, 'b', 'i', 'g', 0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}), &doc); err == nil {
+		t.Errorf("uint64 max accepted as an int64: %+v", doc)
+	}
+}
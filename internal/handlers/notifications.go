@@ -0,0 +1,638 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go-spring/internal/config"
+	"go-spring/internal/ids"
+	"go-spring/internal/jobs"
+	"go-spring/internal/model"
+	"go-spring/internal/notify"
+	"go-spring/internal/validate"
+	"go-spring/internal/webhooks"
+)
+
+type notificationsState struct {
+	// Notifications are published on their own event bus; the senders to
+	// users and to chat listen to it and queue jobs for the slow part
+	notifications *EventBus[notify.Notification]
+
+	// Delivery settings, set up by initNotifier. mailer is nil without
+	// SMTP_URL and chatWebhooks empty without CHAT_WEBHOOKS; templates is nil
+	// when both are off.
+	mailer        notify.Mailer
+	chatWebhooks  []notify.Webhook
+	chatEvents    []string
+	mailTemplates *notify.Templates
+
+	// Client for posting to chat webhooks. Unlike externalClient it may reach
+	// internal hosts, since the URLs come from the operator's configuration.
+	webhookClient *http.Client
+
+	// Client for posting to the webhooks users chose for their notifications,
+	// which mustn't reach internal hosts
+	userWebhookClient *http.Client
+
+	// Whether any user has a notification webhook or a push subscription, so
+	// notifications are worth queueing without email. Kept by noteUserEndpoints, as the listeners may
+	// not look at users themselves while a writer holds articlesMutex.
+	userEndpoints atomic.Bool
+}
+
+// Kinds of notification jobs: one per event, which queues one email,
+// webhook post or push per recipient, so a failing address is retried on
+// its own; and one post per chat webhook. The push job is in push.go.
+const (
+	jobNotify      = "notify"
+	jobEmail       = "email"
+	jobUserWebhook = "notify-webhook"
+	jobChat        = "chat"
+)
+
+// Payload of a chat job
+type chatPost struct {
+	Webhook notify.Webhook `json:"webhook"`
+	Text    string         `json:"text"`
+}
+
+// Configure the SMTP mailer (SMTP_URL), the chat webhooks (CHAT_WEBHOOKS),
+// Web Push (VAPID_PRIVATE_KEY) and the templates, and register the
+// notification job handlers
+func (app *App) initNotifier(cfg config.Config) error {
+	app.mailer, app.chatWebhooks, app.chatEvents, app.mailTemplates = nil, nil, nil, nil
+	app.pushKey, app.pushEvents = nil, nil
+	if app.jobQueue != nil {
+		app.jobQueue.Handle(jobNotify, app.runNotifyJob)
+		app.jobQueue.Handle(jobEmail, app.runEmailJob)
+		app.jobQueue.Handle(jobUserWebhook, app.runUserWebhookJob)
+		app.jobQueue.Handle(jobChat, app.runChatJob)
+		app.jobQueue.Handle(jobPush, app.runPushJob)
+	}
+
+	var webhooks []notify.Webhook
+	for _, raw := range cfg.ChatWebhooks {
+		webhook, err := notify.ParseWebhook(raw)
+		if err != nil {
+			return fmt.Errorf("CHAT_WEBHOOKS: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	for _, event := range cfg.ChatEvents {
+		if !notify.ValidEvent(event) {
+			return fmt.Errorf("CHAT_EVENTS: unknown event %q, expected some of %s", event, strings.Join(notify.Events, ", "))
+		}
+	}
+	var m notify.Mailer
+	if cfg.SMTPURL != "" {
+		var err error
+		if m, err = notify.NewSMTPMailer(cfg.SMTPURL, cfg.MailFrom); err != nil {
+			return err
+		}
+	}
+	push, err := parsePush(cfg)
+	if err != nil {
+		return err
+	}
+	if m == nil && len(webhooks) == 0 && push == nil {
+		return nil
+	}
+	templates, err := notify.LoadTemplates(cfg.MailTemplates, cfg.PublicURL)
+	if err != nil {
+		return err
+	}
+	app.mailer, app.chatWebhooks, app.chatEvents, app.mailTemplates = m, webhooks, cfg.ChatEvents, templates
+	app.pushKey, app.pushEvents = push, cfg.PushEvents
+	return nil
+}
+
+// Notify emails the users who want to hear about an event, posts it to
+// their webhooks or pushes it to their browsers, as their preferences say, and posts it to the chat
+// webhooks. With the job queue it only publishes the notification, and the
+// messages are sent in the background, so Notify may be called while
+// holding articlesMutex; commands without a server send them before
+// returning.
+func (app *App) Notify(ctx context.Context, n notify.Notification) {
+	if app.mailTemplates == nil && !app.userEndpoints.Load() {
+		return
+	}
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+	if app.jobQueue != nil {
+		app.notifications.Publish(n)
+		return
+	}
+	if app.mailer != nil {
+		for _, msg := range app.renderNotification(n, app.Users()) {
+			if err := app.mailer.Send(ctx, msg); err != nil {
+				log.Printf("Warning: Failed to email %s about %s: %v", msg.To, n.Event, err)
+			}
+		}
+	}
+	for _, post := range app.userWebhookPosts(n, app.Users()) {
+		if err := app.deliverUserWebhook(ctx, post); err != nil {
+			log.Printf("Warning: Failed to post %s to the webhook of %s: %v", n.Event, post.Username, err)
+		}
+	}
+	for _, d := range app.pushDeliveries(n, app.Users()) {
+		if err := app.push(ctx, d); err != nil {
+			log.Printf("Warning: Failed to push %s to %s: %v", n.Event, d.Username, err)
+		}
+	}
+	for _, post := range app.chatPosts(n) {
+		if err := post.Webhook.Post(ctx, app.webhookClient, post.Text); err != nil {
+			log.Printf("Warning: Failed to post %s to chat: %v", n.Event, err)
+		}
+	}
+}
+
+// Queue the emails, webhook posts and pushes of a notification; the job
+// looks up the recipients
+func (app *App) queueUserNotifications(n notify.Notification) {
+	if app.mailer != nil || app.userEndpoints.Load() {
+		app.enqueueJob(jobNotify, n)
+	}
+}
+
+// Queue a post to each chat webhook that the event is sent to
+func (app *App) queueChatPosts(n notify.Notification) {
+	for _, post := range app.chatPosts(n) {
+		app.enqueueJob(jobChat, post)
+	}
+}
+
+// The chat posts of a notification, none if CHAT_EVENTS leaves it out or
+// it is for one user only
+func (app *App) chatPosts(n notify.Notification) []chatPost {
+	if len(app.chatWebhooks) == 0 || !slices.Contains(app.chatEvents, n.Event) || n.Recipient != "" {
+		return nil
+	}
+	text, err := app.mailTemplates.Chat(n)
+	if err != nil {
+		log.Printf("Warning: Failed to render %s chat message: %v", n.Event, err)
+		return nil
+	}
+	posts := make([]chatPost, len(app.chatWebhooks))
+	for i, webhook := range app.chatWebhooks {
+		posts[i] = chatPost{webhook, text}
+	}
+	return posts
+}
+
+// Whether the notification is for user at all, whichever the channel
+func notificationFor(n notify.Notification, user model.User) bool {
+	return n.Recipient == "" || strings.EqualFold(n.Recipient, user.Username)
+}
+
+// The messages for the users who want the notification
+func (app *App) renderNotification(n notify.Notification, recipients []model.User) []notify.Message {
+	if app.mailer == nil {
+		return nil
+	}
+	var messages []notify.Message
+	for _, user := range recipients {
+		if !notificationFor(n, user) || !notify.Wants(user, n.Event) {
+			continue
+		}
+		msg, err := app.mailTemplates.Render(n, user)
+		if err != nil {
+			log.Printf("Warning: Failed to render %s email: %v", n.Event, err)
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// Payload of a user webhook job. It names the user rather than carrying
+// the webhook's secret, which stays out of the job file.
+type userWebhookPost struct {
+	Username string            `json:"username"`
+	URL      string            `json:"url"`
+	Delivery webhooks.Delivery `json:"delivery"`
+}
+
+// The webhook posts for the users who want the notification through their
+// webhook. They carry no article content, which the user may not be
+// allowed to read.
+func (app *App) userWebhookPosts(n notify.Notification, recipients []model.User) []userWebhookPost {
+	var posts []userWebhookPost
+	for _, user := range recipients {
+		if user.NotifyWebhookURL == "" || !notificationFor(n, user) || !slices.Contains(app.channelsFor(user, n.Event), notify.ChannelWebhook) {
+			continue
+		}
+		if posts == nil {
+			if n.Article != nil {
+				article := withoutContent(*n.Article)
+				n.Article = &article
+			}
+			n.Articles = slices.Clone(n.Articles)
+			for i := range n.Articles {
+				n.Articles[i] = withoutContent(n.Articles[i])
+			}
+		}
+		data, err := json.Marshal(n)
+		if err != nil {
+			log.Printf("Warning: Failed to encode %s notification: %v", n.Event, err)
+			return nil
+		}
+		delivery := webhooks.Delivery{ID: ids.NewULID(n.Time), Event: n.Event, Time: n.Time.UTC(), Data: data}
+		posts = append(posts, userWebhookPost{Username: user.Username, URL: user.NotifyWebhookURL, Delivery: delivery})
+	}
+	return posts
+}
+
+// Post to the user's webhook, unless they changed it since
+func (app *App) deliverUserWebhook(ctx context.Context, post userWebhookPost) error {
+	user, found := app.findUser(post.Username)
+	if !found || user.NotifyWebhookURL != post.URL {
+		return nil // erased, or the webhook changed, since the post was made
+	}
+	subscription := webhooks.Subscription{URL: post.URL, Secret: user.NotifyWebhookKey}
+	return subscription.Deliver(ctx, app.userWebhookClient, post.Delivery)
+}
+
+// Queue an email, webhook post or push to each user who wants the
+// notification
+func (app *App) runNotifyJob(ctx context.Context, job jobs.Job) error {
+	var n notify.Notification
+	if err := json.Unmarshal(job.Payload, &n); err != nil {
+		return jobs.Permanent(err)
+	}
+	recipients := app.Users()
+	// Without a mailer email was turned off since the job was queued
+	for _, msg := range app.renderNotification(n, recipients) {
+		app.enqueueJob(jobEmail, msg)
+	}
+	for _, post := range app.userWebhookPosts(n, recipients) {
+		app.enqueueJob(jobUserWebhook, post)
+	}
+	for _, d := range app.pushDeliveries(n, recipients) {
+		app.enqueueJob(jobPush, d)
+	}
+	return nil
+}
+
+// Send one email
+func (app *App) runEmailJob(ctx context.Context, job jobs.Job) error {
+	var msg notify.Message
+	if err := json.Unmarshal(job.Payload, &msg); err != nil {
+		return jobs.Permanent(err)
+	}
+	if app.mailer == nil {
+		return jobs.Permanent(errors.New("email is not configured (SMTP_URL)"))
+	}
+	return app.mailer.Send(ctx, msg)
+}
+
+// Post one notification to a user's webhook
+func (app *App) runUserWebhookJob(ctx context.Context, job jobs.Job) error {
+	var post userWebhookPost
+	if err := json.Unmarshal(job.Payload, &post); err != nil {
+		return jobs.Permanent(err)
+	}
+	return app.deliverUserWebhook(ctx, post)
+}
+
+// Post one chat message
+func (app *App) runChatJob(ctx context.Context, job jobs.Job) error {
+	var post chatPost
+	if err := json.Unmarshal(job.Payload, &post); err != nil {
+		return jobs.Permanent(err)
+	}
+	return post.Webhook.Post(ctx, app.webhookClient, post.Text)
+}
+
+// Tell admins about a job that gave up. Failed notifications aren't
+// reported, since reporting them would likely fail too.
+func (app *App) notifyJobDead(job jobs.Job) {
+	if job.Kind == jobNotify || job.Kind == jobEmail || job.Kind == jobUserWebhook || job.Kind == jobChat || job.Kind == jobPush {
+		return
+	}
+	app.Notify(context.Background(), notify.Notification{
+		Event:  notify.EventJobFailed,
+		Error:  job.LastError,
+		Detail: fmt.Sprintf("%s job %d", job.Kind, job.ID),
+	})
+}
+
+// Email about a newly published article
+func (app *App) notifyPublished(article model.Article) {
+	app.Notify(context.Background(), notify.Notification{Event: notify.EventArticlePublished, Article: &article})
+}
+
+// Announce a finished import, with its first error if rows were skipped
+func (app *App) notifyImported(ctx context.Context, report ImportReport) {
+	n := notify.Notification{Event: notify.EventImportCompleted, Detail: ImportMessage(report, "articles")}
+	if len(report.Errors) > 0 {
+		n.Error = fmt.Sprintf("line %d: %s", report.Errors[0].Line, report.Errors[0].Message)
+	}
+	app.Notify(ctx, n)
+}
+
+// NotificationSettings are a user's email address and event preferences
+type NotificationSettings struct {
+	Email  string          `json:"email"`
+	Events map[string]bool `json:"events"` // every event, whether the user is emailed about it
+}
+
+// NotificationSettingsRequest changes the email address (if set) and
+// overrides the default of the listed events
+type NotificationSettingsRequest struct {
+	Email  *string         `json:"email,omitempty" validate:"email"`
+	Events map[string]bool `json:"events,omitempty"`
+}
+
+// Check the tags, and that the events exist
+func (req NotificationSettingsRequest) validate() error {
+	errs := validate.Struct(req)
+	for event := range req.Events {
+		if !notify.ValidEvent(event) {
+			errs = append(errs, validate.FieldError{
+				Field:   "events",
+				Rule:    "oneof",
+				Message: fmt.Sprintf("events has unknown event %q, expected some of %s", event, strings.Join(notify.Events, ", ")),
+			})
+			break
+		}
+	}
+	if errs != nil {
+		return &ValidationError{Message: errs.Error(), Fields: errs}
+	}
+	return nil
+}
+
+func notificationSettings(user model.User) NotificationSettings {
+	settings := NotificationSettings{Email: user.Email, Events: map[string]bool{}}
+	probe := user
+	probe.Email = "-" // Wants says no to everything without an address
+	for _, event := range notify.Events {
+		settings.Events[event] = notify.Wants(probe, event)
+	}
+	return settings
+}
+
+// SetNotifications changes a user's email address (unless nil) and event
+// preferences. The server saves the change in the background; commands
+// call Save.
+func (app *App) SetNotifications(username string, email *string, events map[string]bool) (model.User, error) {
+	if err := (NotificationSettingsRequest{Email: email, Events: events}).validate(); err != nil {
+		return model.User{}, err
+	}
+
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+	for i := range app.users {
+		if !strings.EqualFold(app.users[i].Username, username) {
+			continue
+		}
+		if email != nil {
+			app.users[i].Email = *email
+		}
+		if len(events) > 0 {
+			// A new map, as copies from Users may still be reading the old one
+			prefs := maps.Clone(app.users[i].Notifications)
+			if prefs == nil {
+				prefs = map[string]bool{}
+			}
+			maps.Copy(prefs, events)
+			app.users[i].Notifications = prefs
+			// Channels chosen for an event win over Notifications, so
+			// turn email on or off in them too
+			var channels map[string][]string
+			for event, on := range events {
+				chosen, ok := app.users[i].Preferences[event]
+				if !ok || slices.Contains(chosen, notify.ChannelEmail) == on {
+					continue
+				}
+				if channels == nil {
+					channels = maps.Clone(app.users[i].Preferences)
+				}
+				if on {
+					channels[event] = slices.Sorted(slices.Values(append(slices.Clone(chosen), notify.ChannelEmail)))
+				} else {
+					channels[event] = slices.DeleteFunc(slices.Clone(chosen), func(c string) bool { return c == notify.ChannelEmail })
+				}
+			}
+			if channels != nil {
+				app.users[i].Preferences = channels
+			}
+		}
+		app.scheduleSave()
+		return app.users[i], nil
+	}
+	return model.User{}, fmt.Errorf("user %q not found", username)
+}
+
+// GET /account/notifications - Show the email preferences of the signed-in user
+func (app *App) getNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Notification settings retrieved successfully", Data: notificationSettings(user)})
+}
+
+// PUT /account/notifications - Change the email preferences of the signed-in user
+func (app *App) setNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	var req NotificationSettingsRequest
+	if err := decodeBody(r, &req); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	updated, err := app.SetNotifications(user.Username, req.Email, req.Events)
+	var invalid *ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		app.writeValidationError(w, r, invalid)
+		return
+	case err != nil:
+		app.writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Notification settings updated successfully", Data: notificationSettings(updated)})
+}
+
+// Preferences are the channels a user is sent each event through, and how
+// often they get the digest
+type Preferences struct {
+	Email      string              `json:"email"`       // of the email channel, set at /account/notifications
+	WebhookURL string              `json:"webhook_url"` // of the webhook channel
+	Events     map[string][]string `json:"events"`      // every event, and its channels
+	Digest     string              `json:"digest"`      // off, daily or weekly
+}
+
+// PreferencesRequest chooses the channels of the listed events, an empty
+// list for none, and changes the webhook and the digest (if set). A new
+// webhook URL needs a secret to sign its posts with; an empty one removes
+// the webhook.
+type PreferencesRequest struct {
+	Events        map[string][]string `json:"events,omitempty"`
+	WebhookURL    *string             `json:"webhook_url,omitempty" validate:"url"`
+	WebhookSecret string              `json:"webhook_secret,omitempty"`
+	Digest        string              `json:"digest,omitempty" validate:"oneof=off daily weekly"`
+}
+
+// Check the tags, that the events and channels exist, that push is
+// configured if chosen, that the user has a webhook with a secret if any
+// event goes to it, and an email address for a digest
+func (app *App) validatePreferences(req PreferencesRequest, user model.User) error {
+	errs := validate.Struct(req)
+	if (req.Digest == model.DigestDaily || req.Digest == model.DigestWeekly) && user.Email == "" {
+		errs = append(errs, validate.FieldError{Field: "digest", Rule: "required",
+			Message: "digest needs an email address, set at /account/notifications"})
+	}
+	for event, channels := range req.Events {
+		if !notify.ValidEvent(event) {
+			errs = append(errs, validate.FieldError{Field: "events", Rule: "oneof",
+				Message: fmt.Sprintf("events has unknown event %q, expected some of %s", event, strings.Join(notify.Events, ", "))})
+			break
+		}
+		if i := slices.IndexFunc(channels, func(c string) bool { return !notify.ValidChannel(c) }); i >= 0 {
+			errs = append(errs, validate.FieldError{Field: "events", Rule: "oneof",
+				Message: fmt.Sprintf("events has unknown channel %q, expected some of %s", channels[i], strings.Join(notify.Channels, ", "))})
+			break
+		}
+		if app.pushKey == nil && slices.Contains(channels, notify.ChannelPush) {
+			errs = append(errs, validate.FieldError{Field: "events", Rule: "oneof",
+				Message: "events has channel push, but Web Push is not configured (VAPID_PRIVATE_KEY)"})
+			break
+		}
+	}
+	if errs == nil {
+		url, key := preferredWebhook(req, user)
+		switch {
+		case url != "" && len(key) < webhooks.MinSecretLength:
+			errs = append(errs, validate.FieldError{Field: "webhook_secret", Rule: "min",
+				Message: fmt.Sprintf("webhook_secret must be at least %d characters", webhooks.MinSecretLength)})
+		case url == "" && slices.ContainsFunc(notify.Events, func(event string) bool {
+			return slices.Contains(app.preferredChannels(req, user, event), notify.ChannelWebhook)
+		}):
+			errs = append(errs, validate.FieldError{Field: "webhook_url", Rule: "required",
+				Message: "webhook_url is required for the webhook channel"})
+		}
+	}
+	if errs != nil {
+		return &ValidationError{Message: errs.Error(), Fields: errs}
+	}
+	return nil
+}
+
+// The webhook URL and secret the user has after req
+func preferredWebhook(req PreferencesRequest, user model.User) (string, string) {
+	if req.WebhookURL == nil {
+		return user.NotifyWebhookURL, user.NotifyWebhookKey
+	}
+	url := strings.TrimSpace(*req.WebhookURL)
+	if url == "" {
+		return "", ""
+	}
+	if req.WebhookSecret == "" && url == user.NotifyWebhookURL {
+		return url, user.NotifyWebhookKey
+	}
+	return url, req.WebhookSecret
+}
+
+// The channels the user has for event after req
+func (app *App) preferredChannels(req PreferencesRequest, user model.User, event string) []string {
+	if channels, ok := req.Events[event]; ok {
+		return channels
+	}
+	return app.channelsFor(user, event)
+}
+
+func (app *App) preferences(user model.User) Preferences {
+	prefs := Preferences{Email: user.Email, WebhookURL: user.NotifyWebhookURL, Events: map[string][]string{}, Digest: cmp.Or(user.Digest, "off")}
+	for _, event := range notify.Events {
+		prefs.Events[event] = slices.Concat([]string{}, app.channelsFor(user, event))
+	}
+	return prefs
+}
+
+// SetPreferences changes the channels and the webhook of a user's
+// notifications. The server saves the change in the background; commands
+// call Save.
+func (app *App) SetPreferences(username string, req PreferencesRequest) (model.User, error) {
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+	i := slices.IndexFunc(app.users, func(u model.User) bool { return strings.EqualFold(u.Username, username) })
+	if i < 0 {
+		return model.User{}, fmt.Errorf("user %q not found", username)
+	}
+	if err := app.validatePreferences(req, app.users[i]); err != nil {
+		return model.User{}, err
+	}
+	app.users[i].NotifyWebhookURL, app.users[i].NotifyWebhookKey = preferredWebhook(req, app.users[i])
+	if len(req.Events) > 0 {
+		// A new map, as copies from Users may still be reading the old one
+		channels := maps.Clone(app.users[i].Preferences)
+		if channels == nil {
+			channels = map[string][]string{}
+		}
+		for event, chosen := range req.Events {
+			chosen = slices.Compact(slices.Sorted(slices.Values(chosen)))
+			channels[event] = slices.Concat([]string{}, chosen)
+		}
+		app.users[i].Preferences = channels
+	}
+	if req.Digest != "" {
+		digest := req.Digest
+		if digest == "off" {
+			digest = ""
+		}
+		// A new digest covers what is published from now on
+		if app.users[i].Digest == "" && digest != "" {
+			app.users[i].DigestSent = time.Now()
+		}
+		app.users[i].Digest = digest
+	}
+	app.noteUserEndpoints()
+	app.scheduleSave()
+	return app.users[i], nil
+}
+
+// Remember whether any user has a notification webhook or a push
+// subscription; the caller holds articlesMutex
+func (app *App) noteUserEndpoints() {
+	app.userEndpoints.Store(slices.ContainsFunc(app.users, func(u model.User) bool {
+		return u.NotifyWebhookURL != "" || len(u.PushSubscriptions) > 0
+	}))
+}
+
+// GET /account/preferences - Show the channels the signed-in user is
+// notified through
+func (app *App) getPreferences(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Preferences retrieved successfully", Data: app.preferences(user)})
+}
+
+// PUT /account/preferences - Choose the channels the signed-in user is
+// notified through
+func (app *App) setPreferences(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	var req PreferencesRequest
+	if err := decodeBody(r, &req); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	updated, err := app.SetPreferences(user.Username, req)
+	var invalid *ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		app.writeValidationError(w, r, invalid)
+		return
+	case err != nil:
+		app.writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Preferences updated successfully", Data: app.preferences(updated)})
+}
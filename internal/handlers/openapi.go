@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go-spring/internal/model"
+)
+
+type openapiState struct {
+	// OpenAPI document generated from apiRoutes by New, and again with the
+	// plugin routes when Router builds a router
+	openAPIDocument atomic.Pointer[[]byte]
+}
+
+func (app *App) setOpenAPIDocument(routes []Route) {
+	doc, err := json.MarshalIndent(buildOpenAPI(routes), "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	app.openAPIDocument.Store(&doc)
+}
+
+// GET /openapi.json - OpenAPI 3 description of this API
+func (app *App) getOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(*app.openAPIDocument.Load())
+}
+
+var pathParamRe = regexp.MustCompile(`\{([^}]+)\}`)
+
+// Build the OpenAPI 3.0 document for a route table
+func buildOpenAPI(routes []Route) map[string]interface{} {
+	schemas := map[string]interface{}{}
+	gen := &schemaGenerator{components: schemas}
+
+	responseSchema := func(data interface{}) map[string]interface{} {
+		props := map[string]interface{}{
+			"message": map[string]interface{}{"type": "string"},
+		}
+		if data != nil {
+			props["data"] = gen.schema(reflect.TypeOf(data))
+		}
+		return map[string]interface{}{
+			"type":       "object",
+			"properties": props,
+			"required":   []string{"message"},
+		}
+	}
+	errorResponse := map[string]interface{}{
+		"description": "Error",
+		"content": map[string]interface{}{
+			"text/plain": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+		},
+	}
+
+	paths := map[string]interface{}{}
+	for _, route := range routes {
+		if route.Hidden {
+			continue
+		}
+		op := map[string]interface{}{
+			"summary":     route.Summary,
+			"operationId": operationID(route),
+		}
+
+		var params []interface{}
+		for _, m := range pathParamRe.FindAllStringSubmatch(route.Path, -1) {
+			params = append(params, map[string]interface{}{
+				"name": m[1], "in": "path", "required": true,
+				"schema": map[string]interface{}{"type": "integer"},
+			})
+		}
+		for _, q := range route.Query {
+			params = append(params, map[string]interface{}{
+				"name": q.Name, "in": "query", "description": q.Description,
+				"schema": map[string]interface{}{"type": q.Type},
+			})
+		}
+		if params != nil {
+			op["parameters"] = params
+		}
+
+		switch {
+		case route.Request != nil:
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  codecContent(gen.schema(reflect.TypeOf(route.Request))),
+			}
+		case route.Upload:
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"multipart/form-data": map[string]interface{}{"schema": map[string]interface{}{
+						"type":       "object",
+						"properties": map[string]interface{}{"file": map[string]interface{}{"type": "string", "format": "binary"}},
+						"required":   []string{"file"},
+					}},
+				},
+			}
+		}
+
+		var content map[string]interface{}
+		if route.ContentType != "" {
+			schema := map[string]interface{}{"type": "string"}
+			if route.ContentType == "application/octet-stream" {
+				schema["format"] = "binary"
+			}
+			if route.ContentType == "application/json" {
+				schema = map[string]interface{}{"type": "object"}
+			}
+			content = map[string]interface{}{route.ContentType: map[string]interface{}{"schema": schema}}
+		} else {
+			content = codecContent(responseSchema(route.Response))
+		}
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		responses := map[string]interface{}{
+			strconv.Itoa(status): map[string]interface{}{
+				"description": http.StatusText(status),
+				"content":     content,
+			},
+			"default": errorResponse,
+		}
+		if route.Request != nil {
+			responses["400"] = map[string]interface{}{
+				"description": "Validation failed",
+				"content":     codecContent(gen.schema(reflect.TypeOf(Response{}))),
+			}
+		}
+		op["responses"] = responses
+
+		item, ok := paths[route.Path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[route.Path] = item
+		}
+		item[strings.ToLower(route.Method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Go Spring API",
+			"version":     "1.0.0",
+			"description": "CRUD API for articles with persistent file storage",
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+// Derive an operationId such as "getArticleAttachments" from method and path
+func operationID(route Route) string {
+	id := strings.ToLower(route.Method)
+	for _, part := range strings.FieldsFunc(route.Path, func(r rune) bool {
+		return r == '/' || r == '.' || r == '-' || r == '{' || r == '}'
+	}) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	if route.Path == "/" {
+		id += "Root"
+	}
+	return id
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Article IDs are strings under HASHIDS_SALT; Article.ID is an int that
+// marshals as one
+var articleIDType = reflect.TypeOf(model.ArticleID(0))
+
+// schemaGenerator derives JSON schemas from Go types via their json tags.
+// Named structs are emitted once under components/schemas and referenced.
+type schemaGenerator struct {
+	components map[string]interface{}
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == articleIDType && model.PublicIDs != nil:
+		return map[string]interface{}{"type": "string"}
+	case t.Kind() == reflect.Ptr:
+		s := g.schema(t.Elem())
+		if _, isRef := s["$ref"]; isRef {
+			return map[string]interface{}{"allOf": []interface{}{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, done := g.components[t.Name()]; !done {
+			g.components[t.Name()] = map[string]interface{}{} // placeholder for recursive types
+			g.components[t.Name()] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	var required []string
+	g.addFields(t, props, &required)
+	schema := map[string]interface{}{"type": "object", "properties": props}
+	if required != nil {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (g *schemaGenerator) addFields(t reflect.Type, props map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			g.addFields(f.Type, props, required) // embedded struct fields are promoted
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+		if t == reflect.TypeOf(model.Article{}) && f.Name == "ID" {
+			props[name] = g.schema(articleIDType)
+		}
+		if rules, ok := f.Tag.Lookup("validate"); ok && addConstraints(props[name].(map[string]interface{}), rules) {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// Describe the rules of a validate tag in a field's schema, reporting
+// whether the field is required
+func addConstraints(schema map[string]interface{}, rules string) bool {
+	required := false
+	for _, rule := range strings.Split(rules, ",") {
+		rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch rule {
+		case "required":
+			required = true
+		case "oneof":
+			schema["enum"] = strings.Fields(param)
+		case "email":
+			schema["format"] = "email"
+		case "url":
+			schema["format"] = "uri"
+		case "min", "max":
+			keyword := rule + "imum" // minimum, maximum
+			switch schema["type"] {
+			case "string":
+				keyword = rule + "Length"
+			case "array":
+				keyword = rule + "Items"
+			}
+			n, _ := strconv.Atoi(param)
+			schema[keyword] = n
+		}
+	}
+	return required
+}
+
+// The same schema in every registered representation; JSON:API documents
+// wrap resources differently and are only described as objects
+func codecContent(schema interface{}) map[string]interface{} {
+	content := map[string]interface{}{}
+	for mediaType, codec := range codecs {
+		if mediaType != codec.ContentType() {
+			continue // alias
+		}
+		if _, ok := codec.(jsonAPICodec); ok {
+			content[mediaType] = map[string]interface{}{"schema": map[string]interface{}{"type": "object"}}
+		} else {
+			content[mediaType] = map[string]interface{}{"schema": schema}
+		}
+	}
+	return content
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go-spring/internal/config"
+	"go-spring/internal/pii"
+)
+
+// Personal data in submitted articles (PII_SCAN). The scanner looks at the
+// sanitized title, description and content of every create and update, from
+// REST, gRPC and imports alike: flag keeps what it finds, redact replaces
+// it. Create and update responses list the findings as warnings; the other
+// ways in don't report them.
+
+// Codes of the warnings of the PII scanner
+const (
+	WarningPIIDetected = "PII_DETECTED"
+	WarningPIIRedacted = "PII_REDACTED"
+)
+
+// Warning is something a client should know about a request that
+// succeeded
+type Warning struct {
+	Code    string `json:"code"`
+	Field   string `json:"field,omitempty"` // the JSON name
+	Kind    string `json:"kind,omitempty"`  // e.g. email, phone or national_id
+	Count   int    `json:"count,omitempty"`
+	Message string `json:"message"`
+}
+
+type piiState struct {
+	// Scans article text; nil when PII_SCAN is off
+	piiScanner *pii.Scanner
+}
+
+func (app *App) initPII(cfg config.Config) error {
+	app.piiScanner = nil
+	if cfg.PIIScan == config.PIIOff {
+		return nil
+	}
+	var extra []pii.Pattern
+	if cfg.PIIPatterns != "" {
+		text, err := os.ReadFile(cfg.PIIPatterns)
+		if err != nil {
+			return err
+		}
+		if extra, err = pii.ParsePatterns(string(text)); err != nil {
+			return fmt.Errorf("%s: %w", cfg.PIIPatterns, err)
+		}
+	}
+	app.piiScanner = pii.New(extra)
+	return nil
+}
+
+type warningsKey struct{}
+
+// Collect the warnings of the article operations that run with the
+// returned context
+func collectWarnings(ctx context.Context) (context.Context, *[]Warning) {
+	warnings := &[]Warning{}
+	return context.WithValue(ctx, warningsKey{}, warnings), warnings
+}
+
+// Scan the fields of an article for personal data, redacting it under
+// PII_SCAN=redact, and warn about it if ctx collects warnings
+func (app *App) scanPII(ctx context.Context, title, desc, content *string) {
+	if app.piiScanner == nil {
+		return
+	}
+	warnings, _ := ctx.Value(warningsKey{}).(*[]Warning)
+	fields := []struct {
+		name string
+		text *string
+	}{{"title", title}, {"desc", desc}, {"content", content}}
+	for _, field := range fields {
+		var findings []pii.Finding
+		code, message := WarningPIIDetected, "Possible personal data found"
+		if app.cfg.PIIScan == config.PIIRedact {
+			*field.text, findings = app.piiScanner.Redact(*field.text)
+			code, message = WarningPIIRedacted, "Personal data was redacted"
+		} else {
+			findings = app.piiScanner.Scan(*field.text)
+		}
+		if warnings == nil {
+			continue
+		}
+		for _, f := range findings {
+			*warnings = append(*warnings, Warning{Code: code, Field: field.name, Kind: f.Kind, Count: f.Count, Message: message})
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"go-spring/internal/embeddings"
+	"go-spring/internal/i18n"
+	"go-spring/internal/model"
+	"go-spring/internal/spam"
+	"go-spring/internal/summarize"
+)
+
+// Plugin extends the API from a program that embeds the server, without
+// forking it. Every field is optional; see server.Server.Use.
+type Plugin struct {
+	Name string
+
+	// BeforeCreate sees every new article before it is sanitized and
+	// validated, whether it comes from the REST or gRPC API or an import.
+	// It may change the request; an error rejects the article, with
+	// status 400 and its message for a *ValidationError.
+	BeforeCreate func(ctx context.Context, req *model.CreateArticleRequest) error
+
+	// SpamCheck scores articles submitted without credentials next to the
+	// built-in checks, when SPAM_CHECK is on (see spam.go)
+	SpamCheck spam.Check
+
+	// AfterUpdate is called after an article has been updated
+	AfterUpdate func(ctx context.Context, article model.Article)
+
+	// OnServeList may filter, reorder or enrich the articles of a listing
+	// (GET /articles and ListArticles) before they are sent. Listings are
+	// cached, so the result shouldn't depend on the request.
+	OnServeList func(ctx context.Context, articles []model.Article) []model.Article
+
+	// Summarizer writes article descriptions in place of the one of
+	// SUMMARIZER_URL (see summarize.go); the first plugin's is used
+	Summarizer summarize.Summarizer
+
+	// Embedder makes the vectors of semantic search in place of the one
+	// of EMBEDDINGS_URL (see search.go); the first plugin's is used
+	Embedder embeddings.Embedder
+
+	// Routes are added to the router next to the built-in ones
+	Routes []PluginRoute
+
+	// Middleware stages run after the built-in ones, closest to the handler
+	Middleware []Middleware
+
+	// Interceptors run around the handlers of the routes they select
+	Interceptors []Interceptor
+
+	// Messages translates API messages before the bundled catalogs, e.g.
+	// into more languages or with other wording
+	Messages i18n.Source
+}
+
+// PluginRoute is a custom endpoint of a plugin
+type PluginRoute struct {
+	Method  string
+	Path    string
+	Handler http.Handler
+	Summary string // for the route listing and the OpenAPI document
+	Admin   bool   // require an admin account, like the /admin routes
+}
+
+type pluginsState struct {
+	// Plugins registered since New; guarded by pluginsMutex
+	pluginsMutex sync.RWMutex
+	plugins      []Plugin
+}
+
+// AddPlugin registers a plugin. Its routes are part of routers built after
+// this, so the server rebuilds its router.
+func (app *App) AddPlugin(p Plugin) error {
+	for _, route := range p.Routes {
+		if route.Method == "" || !strings.HasPrefix(route.Path, "/") || route.Handler == nil {
+			return fmt.Errorf("plugin %s: route %s %q needs a method, a path starting with / and a handler", p.Name, route.Method, route.Path)
+		}
+		if slices.ContainsFunc(app.apiRoutes(), func(r Route) bool { return r.Method == route.Method && r.Path == route.Path }) {
+			return fmt.Errorf("plugin %s: route %s %s is already defined", p.Name, route.Method, route.Path)
+		}
+	}
+	for _, m := range p.Middleware {
+		if m.Wrap == nil {
+			return fmt.Errorf("plugin %s: middleware %q has no Wrap", p.Name, m.Name)
+		}
+	}
+	app.pluginsMutex.Lock()
+	defer app.pluginsMutex.Unlock()
+	app.plugins = append(app.plugins, p)
+	return nil
+}
+
+func (app *App) registeredPlugins() []Plugin {
+	app.pluginsMutex.RLock()
+	defer app.pluginsMutex.RUnlock()
+	return app.plugins
+}
+
+// Run the BeforeCreate hooks in registration order, stopping at the first error
+func (app *App) runBeforeCreate(ctx context.Context, req *model.CreateArticleRequest) error {
+	for _, p := range app.registeredPlugins() {
+		if p.BeforeCreate == nil {
+			continue
+		}
+		if err := p.BeforeCreate(ctx, req); err != nil {
+			var invalid *ValidationError
+			if errors.As(err, &invalid) {
+				return err
+			}
+			return fmt.Errorf("plugin %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+func (app *App) runAfterUpdate(ctx context.Context, article model.Article) {
+	for _, p := range app.registeredPlugins() {
+		if p.AfterUpdate != nil {
+			p.AfterUpdate(ctx, article)
+		}
+	}
+}
+
+func (app *App) runOnServeList(ctx context.Context, list []model.Article) []model.Article {
+	for _, p := range app.registeredPlugins() {
+		if p.OnServeList != nil {
+			list = p.OnServeList(ctx, list)
+		}
+	}
+	if list == nil {
+		list = []model.Article{}
+	}
+	return list
+}
+
+// The routes of the registered plugins, as route table entries
+func (app *App) pluginRoutes() []Route {
+	var routes []Route
+	for _, p := range app.registeredPlugins() {
+		for _, route := range p.Routes {
+			routes = append(routes, Route{
+				Method:  route.Method,
+				Path:    route.Path,
+				Handler: route.Handler.ServeHTTP,
+				Summary: route.Summary,
+				Hidden:  route.Summary == "",
+				admin:   route.Admin,
+			})
+		}
+	}
+	return routes
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"go-spring/internal/activity"
+	"go-spring/internal/model"
+)
+
+// Data subject requests. Articles have no author field: a user's articles
+// are those their activity says they created, so articles created without
+// credentials, or whose entries the retention rules or the activity cap have
+// dropped, are not found. Requests are audited as admin routes; the erasure
+// also goes into the admin's activity, naming the erased user by a hash
+// only.
+
+// Action of an erasure in the activity of the admin who made it
+const actionUserErased = "user.erased"
+
+// UserData is user.json of a user's archive: the account without its
+// password hash
+type UserData struct {
+	Username      string            `json:"username"`
+	Role          string            `json:"role"`
+	Created       time.Time         `json:"created"`
+	Email         string            `json:"email,omitempty"`
+	Notifications map[string]bool   `json:"notifications,omitempty"`
+	Workspaces    map[string]string `json:"workspaces"` // role by workspace slug
+
+	SavedSearches []model.SavedSearch `json:"saved_searches,omitempty"`
+
+	Preferences      map[string][]string `json:"preferences,omitempty"` // channels by event
+	NotifyWebhookURL string              `json:"notify_webhook_url,omitempty"`
+	Digest           string              `json:"digest,omitempty"` // daily or weekly
+
+	PushSubscriptions []model.PushSubscription `json:"push_subscriptions,omitempty"` // endpoints, without their keys
+}
+
+// Erasure is what POST /admin/users/{username}/erase did
+type Erasure struct {
+	Subject         string    `json:"subject"` // sha256: of the lowercased username, as the record keeps it
+	Time            time.Time `json:"time"`
+	By              string    `json:"by,omitempty"`
+	Articles        string    `json:"articles"` // keep or delete
+	ArticlesDeleted int       `json:"articles_deleted"`
+	ActivityEntries int       `json:"activity_entries"`
+	Workspaces      int       `json:"workspaces"` // left
+}
+
+// GET /admin/users/{username}/export.zip - Download everything kept about
+// a user as a zip archive
+//
+// The archive holds user.json, activity.json (every entry, newest first),
+// articles.json (the articles they created that still exist), the files of
+// those articles as attachments/{article}/{attachment}/{name} and
+// manifest.json, as in the full export.
+func (app *App) exportUserData(w http.ResponseWriter, r *http.Request) {
+	user, found := app.findUser(mux.Vars(r)["username"])
+	if !found {
+		app.writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+	now := time.Now().UTC()
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="go-spring-user-%s-%s.zip"`, user.Username, now.Format("20060102-150405")))
+
+	if err := app.WriteUserArchive(r.Context(), w, user, now); err != nil {
+		log.Printf("Warning: user archive failed: %v", err)
+	}
+}
+
+// Write the archive of a user described at exportUserData
+func (app *App) WriteUserArchive(ctx context.Context, w io.Writer, user model.User, now time.Time) error {
+	z := newExportZip(w, now, "go-spring-user-export")
+	data := UserData{
+		Username:      user.Username,
+		Role:          user.Role,
+		Created:       user.Created,
+		Email:         user.Email,
+		Notifications: user.Notifications,
+		Workspaces:    map[string]string{},
+		SavedSearches: user.SavedSearches,
+
+		Preferences:      user.Preferences,
+		NotifyWebhookURL: user.NotifyWebhookURL,
+		Digest:           user.Digest,
+
+		PushSubscriptions: user.PushSubscriptions,
+	}
+	app.articlesMutex.RLock()
+	for _, ws := range app.workspaces {
+		if role, ok := ws.Members[user.Username]; ok {
+			data.Workspaces[ws.Slug] = role
+		}
+	}
+	app.articlesMutex.RUnlock()
+
+	var entries []activity.Entry
+	if app.activityLog != nil {
+		entries, _ = app.activityLog.Page(user.Username, 0, activity.MaxEntries)
+	}
+	list := []ActivityEntry{}
+	for _, e := range entries {
+		list = append(list, activityEntry(e))
+	}
+	var created []model.Article
+	for _, id := range createdArticles(entries) {
+		if article, ok := app.readArticle(id); ok {
+			created = append(created, article)
+		}
+	}
+	z.manifest.Articles = len(created)
+
+	var attachments []model.Attachment
+	var attachmentPaths []string
+	for _, article := range created {
+		for _, a := range article.Attachments {
+			attachments = append(attachments, a)
+			attachmentPaths = append(attachmentPaths, fmt.Sprintf("attachments/%d/%d/%s", article.ID, a.ID, a.Filename))
+		}
+	}
+
+	err := z.add("user.json", func(out io.Writer) error { return writeIndentedJSON(out, data) })
+	if err == nil {
+		err = z.add("activity.json", func(out io.Writer) error { return writeIndentedJSON(out, list) })
+	}
+	if err == nil {
+		err = z.add("articles.json", func(out io.Writer) error { return writeIndentedJSON(out, append([]model.Article{}, created...)) })
+	}
+	if err == nil {
+		err = app.addAttachments(ctx, z, attachments, attachmentPaths)
+	}
+	if err == nil {
+		err = z.close()
+	}
+	return err
+}
+
+// The IDs of the articles entries say were created, oldest first
+func createdArticles(entries []activity.Entry) []int {
+	var ids []int
+	for _, e := range slices.Backward(entries) {
+		if e.Action == EventArticleCreated {
+			ids = append(ids, e.ArticleID)
+		}
+	}
+	return ids
+}
+
+// POST /admin/users/{username}/erase - Delete a user's account and
+// activity, and their articles with ?articles=delete; kept articles are
+// left without anything that ties them to the user
+func (app *App) eraseUser(w http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("articles")
+	switch mode {
+	case "":
+		mode = "keep"
+	case "keep", "delete":
+	default:
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "articles must be keep or delete")
+		return
+	}
+	user, found := app.findUser(mux.Vars(r)["username"])
+	if !found {
+		app.writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+	caller, _ := currentUser(r)
+	if caller.Username == user.Username {
+		app.writeError(w, r, http.StatusConflict, CodeConflict, "You can't erase your own account")
+		return
+	}
+
+	erasure := Erasure{Subject: erasureSubject(user.Username), Time: time.Now().UTC(), By: caller.Username, Articles: mode}
+	if mode == "delete" && app.activityLog != nil {
+		entries, _ := app.activityLog.Page(user.Username, 0, activity.MaxEntries)
+		for _, id := range createdArticles(entries) {
+			err := app.articleService.Delete(r.Context(), id)
+			if errors.Is(err, ErrArticleNotFound) {
+				continue
+			} else if err != nil {
+				app.writeArticleError(w, r, err)
+				return
+			}
+			erasure.ArticlesDeleted++
+		}
+	}
+
+	app.articlesMutex.Lock()
+	app.users = slices.DeleteFunc(slices.Clone(app.users), func(u model.User) bool { return u.Username == user.Username })
+	app.noteUserEndpoints()
+	for i, ws := range app.workspaces {
+		if _, ok := ws.Members[user.Username]; ok {
+			// A new map, as copies from findWorkspace may still be reading the old one
+			app.workspaces[i].Members = maps.Clone(ws.Members)
+			delete(app.workspaces[i].Members, user.Username)
+			erasure.Workspaces++
+		}
+	}
+	app.articlesMutex.Unlock()
+	app.workspaceChanged()
+
+	if app.activityLog != nil {
+		erasure.ActivityEntries = app.activityLog.DeleteUser(user.Username)
+		if caller.Username != "" {
+			app.activityLog.Add(caller.Username, activity.Entry{Time: erasure.Time, Action: actionUserErased, Subject: erasure.Subject})
+		}
+	}
+	log.Printf("Audit: %s erased user %s: articles %s, %d deleted, %d activity entries, %d workspaces",
+		cmp.Or(caller.Username, "-"), erasure.Subject, mode, erasure.ArticlesDeleted, erasure.ActivityEntries, erasure.Workspaces)
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "User erased successfully", Data: erasure})
+}
+
+// How the erasure record names a user: a hash that answers whether a given
+// username was erased without keeping it
+func erasureSubject(username string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(username)))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Minimal protocol buffers wire format support for the gRPC transport
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errProtoTruncated = errors.New("proto: truncated message")
+
+// protoEncoder appends fields to a message buffer; zero values are skipped as in proto3
+type protoEncoder struct {
+	buf []byte
+}
+
+func (e *protoEncoder) tag(field, wireType int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(field)<<3|uint64(wireType))
+}
+
+func (e *protoEncoder) Int(field int, v int64) {
+	if v != 0 {
+		e.tag(field, wireVarint)
+		e.buf = binary.AppendUvarint(e.buf, uint64(v))
+	}
+}
+
+func (e *protoEncoder) Bool(field int, v bool) {
+	if v {
+		e.tag(field, wireVarint)
+		e.buf = append(e.buf, 1)
+	}
+}
+
+func (e *protoEncoder) String(field int, v string) {
+	if v != "" {
+		e.tag(field, wireBytes)
+		e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+		e.buf = append(e.buf, v...)
+	}
+}
+
+// Message writes an embedded message (always, even when empty)
+func (e *protoEncoder) Message(field int, msg []byte) {
+	e.tag(field, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(msg)))
+	e.buf = append(e.buf, msg...)
+}
+
+// Timestamp writes a google.protobuf.Timestamp; the zero time is omitted
+func (e *protoEncoder) Timestamp(field int, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	var ts protoEncoder
+	ts.Int(1, t.Unix())
+	ts.Int(2, int64(t.Nanosecond()))
+	e.Message(field, ts.buf)
+}
+
+// protoField is one decoded field; Bytes is set for length-delimited fields
+type protoField struct {
+	Num      int
+	WireType int
+	Varint   uint64
+	Bytes    []byte
+}
+
+func (f protoField) Int() int64     { return int64(f.Varint) }
+func (f protoField) Bool() bool     { return f.Varint != 0 }
+func (f protoField) String() string { return string(f.Bytes) }
+
+// Decode a message, calling fn for each field. Unknown fields can simply be ignored by fn.
+func decodeProto(data []byte, fn func(protoField) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errProtoTruncated
+		}
+		data = data[n:]
+		f := protoField{Num: int(key >> 3), WireType: int(key & 7)}
+
+		switch f.WireType {
+		case wireVarint:
+			f.Varint, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errProtoTruncated
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return errProtoTruncated
+			}
+			f.Varint = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return errProtoTruncated
+			}
+			f.Varint = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		case wireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > math.MaxInt32 || uint64(len(data)-n) < size {
+				return errProtoTruncated
+			}
+			f.Bytes = data[n : n+int(size)]
+			data = data[n+int(size):]
+		default:
+			return errors.New("proto: unsupported wire type")
+		}
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Encode a struct as a message using its `proto:"N"` field tags. Supported
+// field types: int, string, bool, *bool, time.Time, structs and slices of structs.
+func marshalProto(v any) []byte {
+	var e protoEncoder
+	e.encodeStruct(reflect.Indirect(reflect.ValueOf(v)))
+	return e.buf
+}
+
+func (e *protoEncoder) encodeStruct(v reflect.Value) {
+	for i := range v.NumField() {
+		num, err := strconv.Atoi(v.Type().Field(i).Tag.Get("proto"))
+		if err != nil {
+			continue
+		}
+		fv := v.Field(i)
+		switch {
+		case fv.Type() == timeType:
+			e.Timestamp(num, fv.Interface().(time.Time))
+		case fv.Kind() == reflect.Int:
+			e.Int(num, fv.Int())
+		case fv.Kind() == reflect.String:
+			e.String(num, fv.String())
+		case fv.Kind() == reflect.Bool:
+			e.Bool(num, fv.Bool())
+		case fv.Kind() == reflect.Pointer && fv.Type().Elem().Kind() == reflect.Bool:
+			// optional bool: presence is explicit, so false is written too
+			if !fv.IsNil() {
+				e.tag(num, wireVarint)
+				e.buf = append(e.buf, boolByte(fv.Elem().Bool()))
+			}
+		case fv.Kind() == reflect.Struct:
+			e.Message(num, marshalProto(fv.Interface()))
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.String:
+			for j := range fv.Len() {
+				e.String(num, fv.Index(j).String())
+			}
+		case fv.Kind() == reflect.Slice:
+			for j := range fv.Len() {
+				e.Message(num, marshalProto(fv.Index(j).Interface()))
+			}
+		}
+	}
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Decode a message into the struct pointed to by v, the inverse of marshalProto
+func unmarshalProto(data []byte, v any) error {
+	rv := reflect.ValueOf(v).Elem()
+	fields := map[int]reflect.Value{}
+	for i := range rv.NumField() {
+		if num, err := strconv.Atoi(rv.Type().Field(i).Tag.Get("proto")); err == nil {
+			fields[num] = rv.Field(i)
+		}
+	}
+
+	return decodeProto(data, func(f protoField) error {
+		fv, ok := fields[f.Num]
+		if !ok {
+			return nil // unknown field
+		}
+		switch {
+		case fv.Type() == timeType:
+			var ts struct {
+				Seconds int `proto:"1"`
+				Nanos   int `proto:"2"`
+			}
+			if err := unmarshalProto(f.Bytes, &ts); err != nil {
+				return err
+			}
+			fv.Set(reflect.ValueOf(time.Unix(int64(ts.Seconds), int64(ts.Nanos))))
+		case fv.Kind() == reflect.Int:
+			fv.SetInt(f.Int())
+		case fv.Kind() == reflect.String:
+			fv.SetString(f.String())
+		case fv.Kind() == reflect.Bool:
+			fv.SetBool(f.Bool())
+		case fv.Kind() == reflect.Pointer && fv.Type().Elem().Kind() == reflect.Bool:
+			b := f.Bool()
+			fv.Set(reflect.ValueOf(&b))
+		case fv.Kind() == reflect.Struct:
+			return unmarshalProto(f.Bytes, fv.Addr().Interface())
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.String:
+			fv.Set(reflect.Append(fv, reflect.ValueOf(f.String())))
+		case fv.Kind() == reflect.Slice:
+			elem := reflect.New(fv.Type().Elem())
+			if err := unmarshalProto(f.Bytes, elem.Interface()); err != nil {
+				return err
+			}
+			fv.Set(reflect.Append(fv, elem.Elem()))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-spring/internal/model"
+)
+
+func TestProtoVarints(t *testing.T) {
+	for v, want := range map[int64][]byte{
+		1:             {0x08, 0x01},
+		127:           {0x08, 0x7f},
+		128:           {0x08, 0x80, 0x01},
+		300:           {0x08, 0xac, 0x02},
+		math.MaxInt64: {0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f},
+		// negative int64s take all ten bytes, as in protobuf
+		-1:            {0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		math.MinInt64: {0x08, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01},
+	} {
+		var e protoEncoder
+		e.Int(1, v)
+		if !bytes.Equal(e.buf, want) {
+			t.Errorf("%d: % x, want % x", v, e.buf, want)
+		}
+		var got struct {
+			N int `proto:"1"`
+		}
+		if err := unmarshalProto(e.buf, &got); err != nil || int64(got.N) != v {
+			t.Errorf("%d: decoded %d, %v", v, got.N, err)
+		}
+	}
+
+	// Zero values are left out
+	var e protoEncoder
+	e.Int(1, 0)
+	e.Bool(2, false)
+	e.String(3, "")
+	e.Timestamp(4, time.Time{})
+	if len(e.buf) != 0 {
+		t.Errorf("zero values: % x", e.buf)
+	}
+}
+
+func TestProtoLengthDelimited(t *testing.T) {
+	var e protoEncoder
+	e.String(2, "testing")
+	if want := []byte{0x12, 0x07, 't', 'e', 's', 't', 'i', 'n', 'g'}; !bytes.Equal(e.buf, want) {
+		t.Errorf("string: % x, want % x", e.buf, want)
+	}
+
+	var inner protoEncoder
+	inner.Int(1, 150)
+	e = protoEncoder{}
+	e.Message(3, inner.buf)
+	e.Message(4, nil)
+	if want := []byte{0x1a, 0x03, 0x08, 0x96, 0x01, 0x22, 0x00}; !bytes.Equal(e.buf, want) {
+		t.Errorf("messages: % x, want % x", e.buf, want)
+	}
+
+	// Whole articles, with timestamps, repeated strings and optional bools
+	pinned := false
+	update := model.ArticleUpdate{ID: 7, Title: "Päivitys", Pinned: &pinned}
+	var gotUpdate model.ArticleUpdate
+	if err := unmarshalProto(marshalProto(update), &gotUpdate); err != nil || !reflect.DeepEqual(gotUpdate, update) {
+		t.Errorf("update: %+v, %v", gotUpdate, err)
+	}
+	created := time.Date(2026, 10, 16, 9, 30, 0, 123456789, time.UTC)
+	resp := ListArticlesResponse{
+		Articles:      []model.Article{{ID: 1, Title: "a", Created: created, Tags: []string{"go", "grpc"}}, {ID: 2, Featured: true}},
+		NextPageToken: "2",
+	}
+	var got ListArticlesResponse
+	if err := unmarshalProto(marshalProto(resp), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Articles) != 2 || got.NextPageToken != "2" || !got.Articles[0].Created.Equal(created) ||
+		!reflect.DeepEqual(got.Articles[0].Tags, []string{"go", "grpc"}) || !got.Articles[1].Featured {
+		t.Errorf("list: %+v", got)
+	}
+}
+
+func TestProtoUnknownFields(t *testing.T) {
+	var e protoEncoder
+	e.Int(1, 5)
+	e.String(20, "a newer field")
+	e.tag(21, wireFixed64)
+	e.buf = append(e.buf, 1, 2, 3, 4, 5, 6, 7, 8)
+	e.tag(22, wireFixed32)
+	e.buf = append(e.buf, 1, 2, 3, 4)
+	e.Int(23, -1)
+	e.String(2, "kept")
+
+	var got grpcIDRequest
+	if err := unmarshalProto(e.buf, &got); err != nil || got.ID != 5 {
+		t.Errorf("%+v, %v", got, err)
+	}
+	var article model.Article
+	if err := unmarshalProto(e.buf, &article); err != nil || article.ID != 5 || article.Title != "kept" {
+		t.Errorf("%+v, %v", article, err)
+	}
+
+	// Groups are not supported
+	if err := decodeProto([]byte{0x0b, 0x0c}, func(protoField) error { return nil }); err == nil {
+		t.Error("group: no error")
+	}
+}
+
+func TestProtoTruncated(t *testing.T) {
+	msg := marshalProto(model.Article{ID: 300, Title: "title", Created: time.Now(), Tags: []string{"x"}})
+	for n := range len(msg) {
+		var got model.Article
+		if err := unmarshalProto(msg[:n], &got); err == nil && n > 0 {
+			// a cut can only fall between two fields
+			if bytes.Equal(marshalProto(got), msg[:n]) {
+				continue
+			}
+			t.Errorf("cut at %d of %d: no error, %+v", n, len(msg), got)
+		}
+	}
+
+	for name, data := range map[string][]byte{
+		"key":           {0x80},
+		"varint":        {0x08, 0xff, 0xff},
+		"long varint":   {0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		"fixed64":       {0x09, 1, 2, 3},
+		"fixed32":       {0x0d, 1},
+		"length":        {0x12},
+		"bytes":         {0x12, 0x05, 'a', 'b'},
+		"huge length":   {0x12, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f},
+		"nested":        {0x2a, 0x02, 0x08, 0xff},
+		"nested length": {0x2a, 0x01, 0x08},
+	} {
+		var got model.Article
+		if err := unmarshalProto(data, &got); err == nil {
+			t.Errorf("%s: no error, %+v", name, got)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"go-spring/internal/config"
+	"go-spring/internal/jobs"
+	"go-spring/internal/model"
+	"go-spring/internal/notify"
+	"go-spring/internal/validate"
+	"go-spring/internal/webpush"
+)
+
+// Web Push. With VAPID_PRIVATE_KEY set, a frontend subscribes the browser
+// with the key of GET /push/key and posts the subscription to
+// /push/subscribe, and the user's notifications with the push channel are
+// pushed to it: by default the events of PUSH_EVENTS, unless the user
+// chose the channels of an event in /account/preferences. Each browser
+// gets its own job, so one that is offline is retried on its own, and a
+// subscription its push service says is gone is removed.
+
+// Most browsers a user may subscribe; a new one beyond that replaces the
+// oldest
+const maxPushSubscriptions = 10
+
+// How long push services keep a message for a browser that is offline
+const pushTTL = 24 * time.Hour
+
+// Kind of the job that pushes one notification to one browser
+const jobPush = "push"
+
+type pushState struct {
+	// Set up by initNotifier: the VAPID key, nil without VAPID_PRIVATE_KEY,
+	// and the events pushed by default
+	pushKey    *webpush.VAPID
+	pushEvents []string
+
+	// Client for posting to push services, whose endpoints browsers name, so
+	// they may not reach internal hosts
+	pushClient *http.Client
+}
+
+func pushEnabled(cfg config.Config) bool { return cfg.VAPIDPrivateKey != "" }
+
+// Read VAPID_PRIVATE_KEY and check PUSH_EVENTS; nil without a key
+func parsePush(cfg config.Config) (*webpush.VAPID, error) {
+	if cfg.VAPIDPrivateKey == "" {
+		return nil, nil
+	}
+	key, err := webpush.ParseVAPID(cfg.VAPIDPrivateKey, cfg.VAPIDSubject)
+	if err != nil {
+		return nil, fmt.Errorf("VAPID_PRIVATE_KEY: %w", err)
+	}
+	for _, event := range cfg.PushEvents {
+		if !notify.ValidEvent(event) {
+			return nil, fmt.Errorf("PUSH_EVENTS: unknown event %q, expected some of %s", event, strings.Join(notify.Events, ", "))
+		}
+	}
+	return key, nil
+}
+
+// The channels user is notified of event through: those of
+// notify.ChannelsFor, and push for the events of PUSH_EVENTS the user
+// hasn't chosen channels for
+func (app *App) channelsFor(user model.User, event string) []string {
+	channels := notify.ChannelsFor(user, event)
+	if _, chosen := user.Preferences[event]; !chosen && app.pushKey != nil && slices.Contains(app.pushEvents, event) {
+		channels = append(slices.Clone(channels), notify.ChannelPush)
+	}
+	return channels
+}
+
+// PushKey is the public key browsers subscribe with, as their
+// applicationServerKey
+type PushKey struct {
+	PublicKey string `json:"public_key"`
+}
+
+// PushMessage is what a browser is pushed, for its service worker to show
+type PushMessage struct {
+	Event     string    `json:"event"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body,omitempty"`
+	URL       string    `json:"url,omitempty"`        // of the article, absolute with PUBLIC_URL
+	ArticleID string    `json:"article_id,omitempty"` // public ID
+	Time      time.Time `json:"time"`
+}
+
+// Payload of a push job. It names the subscription rather than carrying
+// its keys, which stay out of the job file.
+type pushDelivery struct {
+	Username string      `json:"username"`
+	Endpoint string      `json:"endpoint"`
+	Message  PushMessage `json:"message"`
+}
+
+// Cut s to at most n runes
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
+
+// The message of a notification, titled with the subject of its email
+func (app *App) pushMessage(n notify.Notification, user model.User) (PushMessage, error) {
+	msg, err := app.mailTemplates.Render(n, user)
+	if err != nil {
+		return PushMessage{}, err
+	}
+	push := PushMessage{Event: n.Event, Title: truncateRunes(msg.Subject, 200), Time: n.Time.UTC()}
+	if n.Article != nil {
+		push.Body = truncateRunes(n.Article.Desc, 200)
+		push.URL = articleURL(strings.TrimSuffix(app.cfg.PublicURL, "/"), *n.Article) + "/html"
+		push.ArticleID = n.Article.PublicID()
+	}
+	return push, nil
+}
+
+// The pushes to the browsers of the users who want the notification
+// pushed, none without VAPID_PRIVATE_KEY
+func (app *App) pushDeliveries(n notify.Notification, recipients []model.User) []pushDelivery {
+	if app.pushKey == nil {
+		return nil
+	}
+	var deliveries []pushDelivery
+	for _, user := range recipients {
+		if len(user.PushSubscriptions) == 0 || !notificationFor(n, user) || !slices.Contains(app.channelsFor(user, n.Event), notify.ChannelPush) {
+			continue
+		}
+		msg, err := app.pushMessage(n, user)
+		if err != nil {
+			log.Printf("Warning: Failed to render %s push message: %v", n.Event, err)
+			continue
+		}
+		for _, sub := range user.PushSubscriptions {
+			deliveries = append(deliveries, pushDelivery{Username: user.Username, Endpoint: sub.Endpoint, Message: msg})
+		}
+	}
+	return deliveries
+}
+
+// Push the message to the browser, unless it was unsubscribed since. A
+// subscription the push service no longer knows is removed.
+func (app *App) push(ctx context.Context, d pushDelivery) error {
+	user, found := app.findUser(d.Username)
+	i := slices.IndexFunc(user.PushSubscriptions, func(s model.PushSubscription) bool { return s.Endpoint == d.Endpoint })
+	if !found || i < 0 {
+		return nil
+	}
+	stored := user.PushSubscriptions[i]
+	payload, err := json.Marshal(d.Message)
+	if err != nil {
+		return err
+	}
+	sub := webpush.Subscription{Endpoint: stored.Endpoint, Keys: webpush.Keys{P256dh: stored.P256dh, Auth: stored.Auth}}
+	err = app.pushKey.Send(ctx, app.pushClient, sub, payload, pushTTL)
+	if errors.Is(err, webpush.ErrGone) {
+		log.Printf("Removing the push subscription of %s at %s, which is gone", d.Username, sub)
+		app.changePushSubscriptions(d.Username, func(subs []model.PushSubscription) ([]model.PushSubscription, error) {
+			return slices.DeleteFunc(subs, func(s model.PushSubscription) bool { return s.Endpoint == d.Endpoint }), nil
+		})
+		return nil
+	}
+	return err
+}
+
+// Push one notification to one browser
+func (app *App) runPushJob(ctx context.Context, job jobs.Job) error {
+	var d pushDelivery
+	if err := json.Unmarshal(job.Payload, &d); err != nil {
+		return jobs.Permanent(err)
+	}
+	if app.pushKey == nil {
+		return jobs.Permanent(errors.New("Web Push is not configured (VAPID_PRIVATE_KEY)"))
+	}
+	return app.push(ctx, d)
+}
+
+// Change the push subscriptions of a user
+func (app *App) changePushSubscriptions(username string, change func([]model.PushSubscription) ([]model.PushSubscription, error)) error {
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+	i := slices.IndexFunc(app.users, func(u model.User) bool { return strings.EqualFold(u.Username, username) })
+	if i < 0 {
+		return fmt.Errorf("user %q not found", username)
+	}
+	// A copy, as copies from Users may still be reading the old ones
+	subs, err := change(slices.Clone(app.users[i].PushSubscriptions))
+	if err != nil {
+		return err
+	}
+	app.users[i].PushSubscriptions = subs
+	app.noteUserEndpoints()
+	app.scheduleSave()
+	return nil
+}
+
+var errPushSubscriptionNotFound = errors.New("push subscription not found")
+
+// GET /push/key - The public key to subscribe browsers with
+func (app *App) getPushKey(w http.ResponseWriter, r *http.Request) {
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Push key retrieved successfully", Data: PushKey{PublicKey: app.pushKey.PublicKey()}})
+}
+
+// POST /push/subscribe - Push the signed-in user's notifications to a
+// browser. Subscribing the same endpoint again replaces its keys.
+func (app *App) subscribePush(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	var req webpush.Subscription
+	if err := decodeBody(r, &req); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	if err := req.Check(); err != nil {
+		field, _, _ := strings.Cut(err.Error(), " ")
+		app.writeValidationError(w, r, &ValidationError{Message: err.Error(), Fields: []validate.FieldError{{Field: field, Rule: "required", Message: err.Error()}}})
+		return
+	}
+	sub := model.PushSubscription{Endpoint: req.Endpoint, P256dh: req.Keys.P256dh, Auth: req.Keys.Auth, Created: time.Now()}
+	replaced := false
+	err := app.changePushSubscriptions(user.Username, func(subs []model.PushSubscription) ([]model.PushSubscription, error) {
+		if i := slices.IndexFunc(subs, func(s model.PushSubscription) bool { return s.Endpoint == sub.Endpoint }); i >= 0 {
+			sub.Created, replaced = subs[i].Created, true
+			subs[i] = sub
+			return subs, nil
+		}
+		if len(subs) >= maxPushSubscriptions {
+			subs = subs[len(subs)-maxPushSubscriptions+1:]
+		}
+		return append(subs, sub), nil
+	})
+	if err != nil {
+		app.writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+	if replaced {
+		app.writeResponse(w, r, http.StatusOK, Response{Message: "Push subscription updated successfully", Data: sub})
+		return
+	}
+	app.writeResponse(w, r, http.StatusCreated, Response{Message: "Push subscription created successfully", Data: sub})
+}
+
+// PushUnsubscribeRequest names the subscription to remove
+type PushUnsubscribeRequest struct {
+	Endpoint string `json:"endpoint" validate:"required"`
+}
+
+// POST /push/unsubscribe - Stop pushing to a browser of the signed-in user
+func (app *App) unsubscribePush(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	var req PushUnsubscribeRequest
+	if err := decodeBody(r, &req); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	if errs := validate.Struct(req); errs != nil {
+		app.writeValidationError(w, r, &ValidationError{Message: errs.Error(), Fields: errs})
+		return
+	}
+	err := app.changePushSubscriptions(user.Username, func(subs []model.PushSubscription) ([]model.PushSubscription, error) {
+		i := slices.IndexFunc(subs, func(s model.PushSubscription) bool { return s.Endpoint == req.Endpoint })
+		if i < 0 {
+			return nil, errPushSubscriptionNotFound
+		}
+		return slices.Delete(subs, i, i+1), nil
+	})
+	switch {
+	case errors.Is(err, errPushSubscriptionNotFound):
+		app.writeError(w, r, http.StatusNotFound, CodePushSubscriptionNotFound, "Push subscription not found")
+		return
+	case err != nil:
+		app.writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
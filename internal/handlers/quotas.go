@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"go-spring/internal/model"
+)
+
+// Workspaces are the tenants of a server: each has limits on its articles,
+// attachment storage and request rate, the QUOTA_* defaults unless an
+// admin sets its own.
+
+// ErrQuotaExceeded is returned when a workspace has all the articles it may
+var ErrQuotaExceeded = errors.New("article quota exceeded")
+
+// QuotaUsage is what a workspace uses of its limits
+type QuotaUsage struct {
+	Articles     int   `json:"articles"`
+	StorageBytes int64 `json:"storage_bytes"`
+}
+
+// Quota is a workspace's limits and usage, as shown at /admin/quotas
+type Quota struct {
+	Workspace string       `json:"workspace"`
+	Limits    model.Limits `json:"limits"`    // the workspace's own; zero fields are the defaults
+	Effective model.Limits `json:"effective"` // in force; zero fields are unlimited
+	Usage     QuotaUsage   `json:"usage"`
+}
+
+// LimitsRequest is the body of PUT /admin/quotas/{ws}; 0 restores a
+// default and -1 lifts a limit
+type LimitsRequest struct {
+	MaxArticles     int   `json:"max_articles" validate:"min=-1"`
+	MaxStorageBytes int64 `json:"max_storage_bytes" validate:"min=-1"`
+	RateLimit       int   `json:"rate_limit" validate:"min=-1"`
+}
+
+// The limits in force for a workspace with own limits; 0 is unlimited
+func (app *App) effectiveLimits(own model.Limits) model.Limits {
+	pick := func(own, def int64) int64 {
+		switch {
+		case own < 0:
+			return 0
+		case own > 0:
+			return own
+		}
+		return def
+	}
+	return model.Limits{
+		MaxArticles:     int(pick(int64(own.MaxArticles), int64(app.cfg.QuotaMaxArticles))),
+		MaxStorageBytes: pick(own.MaxStorageBytes, app.cfg.QuotaMaxStorageBytes),
+		RateLimit:       int(pick(int64(own.RateLimit), int64(app.cfg.QuotaRateLimit))),
+	}
+}
+
+// What the workspace uses; the caller holds articlesMutex
+func (app *App) workspaceUsage(ws string) QuotaUsage {
+	var usage QuotaUsage
+	for _, a := range app.articles {
+		if a.Workspace != ws {
+			continue
+		}
+		usage.Articles++
+		for _, att := range a.Attachments {
+			usage.StorageBytes += att.Size
+		}
+	}
+	return usage
+}
+
+// Check that the workspace may have another article. Concurrent creates
+// may overshoot the limit by the articles they create at once.
+func (app *App) checkArticleQuota(ws string) error {
+	if ws == "" {
+		return nil
+	}
+	app.articlesMutex.RLock()
+	defer app.articlesMutex.RUnlock()
+	i := slices.IndexFunc(app.workspaces, func(w model.Workspace) bool { return w.Slug == ws })
+	if i < 0 {
+		return nil
+	}
+	limit := app.effectiveLimits(app.workspaces[i].Limits).MaxArticles
+	if limit > 0 && app.workspaceUsage(ws).Articles >= limit {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// Check that the workspace of article i may store size more attachment
+// bytes, the ones already stored included; the caller holds articlesMutex
+func (app *App) checkStorageQuota(i int, size int64) error {
+	ws := app.articles[i].Workspace
+	j := slices.IndexFunc(app.workspaces, func(w model.Workspace) bool { return w.Slug == ws })
+	if ws == "" || j < 0 {
+		return nil
+	}
+	limit := app.effectiveLimits(app.workspaces[j].Limits).MaxStorageBytes
+	if limit > 0 && app.workspaceUsage(ws).StorageBytes+size > limit {
+		return &attachmentError{http.StatusForbidden, CodeQuotaExceeded, "Storage quota exceeded"}
+	}
+	return nil
+}
+
+// Routes whose requests count against a workspace's rate limit: those of
+// the workspace and of its articles
+var quotaRoutes = func(r Route) bool {
+	return strings.HasPrefix(r.Path, "/workspaces/{ws}") || strings.HasPrefix(r.Path, "/articles/{id}")
+}
+
+// limitWorkspaceRate answers 429 Too Many Requests once a workspace's
+// requests go over its rate limit, whoever sends them
+func (app *App) limitWorkspaceRate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var ws model.Workspace
+		var ok bool
+		if s, isWorkspace := mux.Vars(r)["ws"]; isWorkspace {
+			ws, ok = app.findWorkspace(s)
+		} else if id, err := app.articleRouteID(r); err == nil {
+			ws, ok = app.articleWorkspace(id)
+		}
+		if !ok {
+			next(w, r)
+			return
+		}
+		rate := app.effectiveLimits(ws.Limits).RateLimit
+		if rate <= 0 {
+			next(w, r)
+			return
+		}
+		if wait := app.takeToken("workspace:"+ws.Slug, rate, min(app.cfg.RateLimitBurst, rate), time.Now()); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			app.writeError(w, r, http.StatusTooManyRequests, CodeRateLimited, "Workspace rate limit exceeded")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// The quota of a workspace; the caller holds articlesMutex
+func (app *App) workspaceQuota(ws model.Workspace) Quota {
+	return Quota{Workspace: ws.Slug, Limits: ws.Limits, Effective: app.effectiveLimits(ws.Limits), Usage: app.workspaceUsage(ws.Slug)}
+}
+
+// GET /admin/quotas - Limits and usage of every workspace
+func (app *App) getQuotas(w http.ResponseWriter, r *http.Request) {
+	app.articlesMutex.RLock()
+	list := []Quota{}
+	for _, ws := range app.workspaces {
+		list = append(list, app.workspaceQuota(ws))
+	}
+	app.articlesMutex.RUnlock()
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Quotas retrieved successfully", Data: list})
+}
+
+// GET /admin/quotas/{ws} - Limits and usage of a workspace
+func (app *App) getQuota(w http.ResponseWriter, r *http.Request) {
+	app.articlesMutex.RLock()
+	i := slices.IndexFunc(app.workspaces, func(ws model.Workspace) bool { return ws.Slug == mux.Vars(r)["ws"] })
+	var quota Quota
+	if i >= 0 {
+		quota = app.workspaceQuota(app.workspaces[i])
+	}
+	app.articlesMutex.RUnlock()
+	if i < 0 {
+		app.writeError(w, r, http.StatusNotFound, CodeWorkspaceNotFound, "Workspace not found")
+		return
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Quota retrieved successfully", Data: quota})
+}
+
+// PUT /admin/quotas/{ws} - Set a workspace's limits. Usage over a lowered
+// limit is kept; only new articles and attachments are refused.
+func (app *App) setQuota(w http.ResponseWriter, r *http.Request) {
+	var req LimitsRequest
+	if err := decodeBody(r, &req); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	if err := validateRequest(req); err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+
+	var quota Quota
+	_, err := app.updateWorkspaceWith(r, func(ws *model.Workspace) error {
+		ws.Limits = model.Limits(req)
+		quota = app.workspaceQuota(*ws)
+		return nil
+	})
+	if err != nil {
+		app.writeWorkspaceError(w, r, err)
+		return
+	}
+	app.scheduleSave()
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Quota updated successfully", Data: quota})
+}
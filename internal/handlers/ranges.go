@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-spring/internal/model"
+	"go-spring/internal/store"
+)
+
+// Attachments and article content answer a Range header with the bytes
+// asked for, so that a client can fetch the first few KB of a long article
+// or resume a download. One range is served; a header with several, or one
+// that can't be parsed, gets the whole content, as does an If-Range that
+// isn't the Last-Modified time.
+
+// errRangeNotSatisfiable means a range starts past the end of the content
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// The byte range a request asks for out of size bytes last modified at
+// modified: ok is false for the whole content
+func requestedRange(r *http.Request, size int64, modified time.Time) (start, length int64, ok bool, err error) {
+	spec, found := strings.CutPrefix(r.Header.Get("Range"), "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false, nil
+	}
+	if ifRange := r.Header.Get("If-Range"); ifRange != "" {
+		if t, err := http.ParseTime(ifRange); err != nil || !t.Equal(modified.Truncate(time.Second)) {
+			return 0, 0, false, nil
+		}
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false, nil
+	}
+
+	// The last n bytes
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false, nil
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, false, errRangeNotSatisfiable
+		}
+		n = min(n, size)
+		return size - n, n, true, nil
+	}
+
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false, nil
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, 0, false, nil
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return 0, 0, false, errRangeNotSatisfiable
+	}
+	return start, end - start + 1, true, nil
+}
+
+// Advertise ranges of content last modified at modified
+func acceptRanges(w http.ResponseWriter, modified time.Time) {
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+}
+
+// The headers of a 206 with length bytes from start out of size
+func setContentRange(w http.ResponseWriter, start, length, size int64) {
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, size))
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+}
+
+func (app *App) writeRangeNotSatisfiable(w http.ResponseWriter, r *http.Request, size int64) {
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+	app.writeError(w, r, http.StatusRequestedRangeNotSatisfiable, CodeRangeNotSatisfiable, "Range not satisfiable")
+}
+
+// Stream an attachment, or the range of it the request asks for
+func (app *App) writeAttachment(w http.ResponseWriter, r *http.Request, attachment model.Attachment) {
+	acceptRanges(w, attachment.Created)
+	start, length, ok, err := requestedRange(r, attachment.Size, attachment.Created)
+	switch {
+	case err != nil:
+		app.writeRangeNotSatisfiable(w, r, attachment.Size)
+		return
+	case !ok:
+		app.writeBlob(w, r, attachment.Key, attachment.ContentType, attachment.Filename)
+		return
+	}
+
+	blob, err := app.getBlobRange(r.Context(), attachment.Key, start, length)
+	if err != nil {
+		if errors.Is(err, store.ErrBlobNotFound) {
+			app.writeError(w, r, http.StatusNotFound, CodeAttachmentNotFound, "Attachment not found")
+			return
+		}
+		app.writeStoreError(w, r, fmt.Errorf("blob %s: %w", attachment.Key, err), "Failed to read attachment")
+		return
+	}
+	defer blob.Close()
+
+	setDownloadHeaders(w, attachment.ContentType, attachment.Filename)
+	setContentRange(w, start, length, attachment.Size)
+	w.WriteHeader(http.StatusPartialContent)
+	io.Copy(w, blob)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Token bucket of one client or workspace: RATE_LIMIT tokens a minute, up
+// to RATE_LIMIT_BURST saved up
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+
+	perSecond, burst float64
+}
+
+type ratelimitState struct {
+	rateMutex   sync.Mutex
+	rateBuckets map[string]*rateBucket
+	rateSweep   time.Time // when idle buckets were last dropped
+}
+
+// limitRate answers 429 Too Many Requests to clients, by IP address, that
+// send more than RATE_LIMIT requests a minute on average
+func (app *App) limitRate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		if wait := app.takeToken(client, app.cfg.RateLimit, app.cfg.RateLimitBurst, time.Now()); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			app.writeError(w, r, http.StatusTooManyRequests, CodeRateLimited, "Too many requests")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Take a token from the bucket of key, refilled at perMinute, or return
+// how long until there is one
+func (app *App) takeToken(key string, perMinute, burst int, now time.Time) time.Duration {
+	app.rateMutex.Lock()
+	defer app.rateMutex.Unlock()
+	// A bucket idle long enough to be full again is the same as none
+	if now.Sub(app.rateSweep) > time.Minute {
+		for k, b := range app.rateBuckets {
+			if b.tokens+now.Sub(b.last).Seconds()*b.perSecond >= b.burst {
+				delete(app.rateBuckets, k)
+			}
+		}
+		app.rateSweep = now
+	}
+
+	b, ok := app.rateBuckets[key]
+	if !ok {
+		b = &rateBucket{tokens: float64(max(burst, 1)), last: now}
+		app.rateBuckets[key] = b
+	}
+	// The rate may have changed since the bucket was made
+	b.perSecond, b.burst = float64(perMinute)/60, float64(max(burst, 1))
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.perSecond)
+	b.last = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / b.perSecond * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
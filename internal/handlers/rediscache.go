@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go-spring/internal/config"
+)
+
+// redisCache shares cached responses between replicas through Redis.
+//
+// Keys carry a generation number kept in Redis. Clearing the cache
+// increments it and publishes the new value, so every replica switches to
+// fresh keys at once; old entries simply expire.
+type redisCache struct {
+	client  *redisClient
+	prefix  string
+	ttl     time.Duration
+	gen     atomic.Int64
+	pending atomic.Int32 // clears not yet acknowledged by Redis
+}
+
+func newRedisCache(cfg config.Config) (*redisCache, error) {
+	client, err := newRedisClient(cfg.RedisURL)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisCache{client: client, prefix: cfg.RedisPrefix, ttl: cfg.ResponseCacheTTL}
+
+	reply, err := client.Do("GET", c.prefix+"gen")
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	if s, ok := reply.(string); ok {
+		n, _ := strconv.ParseInt(s, 10, 64)
+		c.gen.Store(n)
+	}
+	return c, nil
+}
+
+func (c *redisCache) key(key string) string {
+	return c.prefix + strconv.FormatInt(c.gen.Load(), 10) + ":" + key
+}
+
+func (c *redisCache) Get(key string) (CachedResponse, bool) {
+	if c.pending.Load() > 0 {
+		return CachedResponse{}, false
+	}
+	reply, err := c.client.Do("GET", c.key(key))
+	if err != nil {
+		log.Printf("Warning: redis cache get: %v", err)
+		return CachedResponse{}, false
+	}
+	value, ok := reply.(string)
+	if !ok {
+		return CachedResponse{}, false
+	}
+	contentType, rest, _ := strings.Cut(value, "\n")
+	language, body, _ := strings.Cut(rest, "\n")
+	return CachedResponse{ContentType: contentType, Language: language, Body: []byte(body)}, true
+}
+
+func (c *redisCache) Set(key string, resp CachedResponse) {
+	if c.pending.Load() > 0 {
+		return
+	}
+	value := resp.ContentType + "\n" + resp.Language + "\n" + string(resp.Body)
+	ttl := strconv.FormatInt(c.ttl.Milliseconds(), 10)
+	if _, err := c.client.Do("SET", c.key(key), value, "PX", ttl); err != nil {
+		log.Printf("Warning: redis cache set: %v", err)
+	}
+}
+
+// Clear runs on the writer's goroutine, so the round trips happen in the
+// background; until they finish this replica bypasses the cache.
+func (c *redisCache) Clear() {
+	c.pending.Add(1)
+	go func() {
+		defer c.pending.Add(-1)
+		reply, err := c.client.Do("INCR", c.prefix+"gen")
+		if err != nil {
+			log.Printf("Warning: redis cache invalidation: %v", err)
+			return
+		}
+		gen, _ := reply.(int64)
+		c.setGen(gen)
+		if _, err := c.client.Do("PUBLISH", c.prefix+"invalidate", strconv.FormatInt(gen, 10)); err != nil {
+			log.Printf("Warning: redis cache invalidation: %v", err)
+		}
+	}()
+}
+
+func (c *redisCache) setGen(gen int64) {
+	for {
+		current := c.gen.Load()
+		if gen <= current || c.gen.CompareAndSwap(current, gen) {
+			return
+		}
+	}
+}
+
+// Follow invalidations published by other replicas, reconnecting on errors,
+// until ctx is done, calling invalidated for each. Runs as part of the
+// background work, see StartBackground.
+func (c *redisCache) subscribe(ctx context.Context, invalidated func()) {
+	channel := c.prefix + "invalidate"
+	for {
+		err := c.client.Subscribe(ctx, channel, func(message string) {
+			if gen, err := strconv.ParseInt(message, 10, 64); err == nil {
+				c.setGen(gen)
+				invalidated()
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Warning: redis subscription lost: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// redisClient is a minimal RESP2 client with a small connection pool
+type redisClient struct {
+	addr     string
+	password string
+	db       int
+	pool     chan *redisConn
+}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+const redisTimeout = 2 * time.Second
+
+// Parse redis://[:password@]host[:port][/db]
+func newRedisClient(rawURL string) (*redisClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "redis" {
+		return nil, errors.New("REDIS_URL must look like redis://[:password@]host:port/db")
+	}
+	c := &redisClient{addr: u.Host, pool: make(chan *redisConn, 8)}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if password, ok := u.User.Password(); ok {
+		c.password = password
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid Redis database %q", db)
+		}
+	}
+	return c, nil
+}
+
+func (c *redisClient) dial() (*redisConn, error) {
+	conn, err := net.DialTimeout("tcp", c.addr, redisTimeout)
+	if err != nil {
+		return nil, err
+	}
+	rc := &redisConn{conn: conn, r: bufio.NewReader(conn)}
+	if c.password != "" {
+		if _, err := rc.do("AUTH", c.password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := rc.do("SELECT", strconv.Itoa(c.db)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+// Do runs one command. Replies are nil, string, int64, []any or a
+// redisError for error replies.
+func (c *redisClient) Do(args ...string) (any, error) {
+	var rc *redisConn
+	select {
+	case rc = <-c.pool:
+	default:
+		var err error
+		if rc, err = c.dial(); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := rc.do(args...)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		rc.conn.Close() // the connection state is unknown
+		return nil, err
+	}
+
+	select {
+	case c.pool <- rc:
+	default:
+		rc.conn.Close()
+	}
+	return reply, err
+}
+
+// Subscribe delivers messages on channel to fn until the connection fails
+// or ctx is done
+func (c *redisClient) Subscribe(ctx context.Context, channel string, fn func(message string)) error {
+	rc, err := c.dial()
+	if err != nil {
+		return err
+	}
+	defer rc.conn.Close()
+	stop := context.AfterFunc(ctx, func() { rc.conn.Close() })
+	defer stop()
+
+	if err := rc.send("SUBSCRIBE", channel); err != nil {
+		return err
+	}
+	rc.conn.SetDeadline(time.Time{})
+	for {
+		reply, err := readRESP(rc.r)
+		if err != nil {
+			return err
+		}
+		// ["message", channel, payload]
+		if msg, ok := reply.([]any); ok && len(msg) == 3 && msg[0] == "message" {
+			if payload, ok := msg[2].(string); ok {
+				fn(payload)
+			}
+		}
+	}
+}
+
+func (rc *redisConn) send(args ...string) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	rc.conn.SetDeadline(time.Now().Add(redisTimeout))
+	_, err := rc.conn.Write(b.Bytes())
+	return err
+}
+
+func (rc *redisConn) do(args ...string) (any, error) {
+	if err := rc.send(args...); err != nil {
+		return nil, err
+	}
+	reply, err := readRESP(rc.r)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := reply.(redisError); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+func readRESP(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: malformed reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return redisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err // -1 is a nil reply
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		list := make([]any, n)
+		for i := range list {
+			if list[i], err = readRESP(r); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+	return nil, errors.New("redis: malformed reply")
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-spring/internal/config"
+)
+
+// fakeRedis speaks enough RESP for the cache: AUTH, SELECT, GET, SET with
+// PX, INCR, PUBLISH and SUBSCRIBE
+type fakeRedis struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	values   map[string]string
+	expires  map[string]time.Time
+	commands [][]string
+	errors   map[string]string // error reply by command
+	conns    map[net.Conn]bool
+	subs     map[string][]net.Conn
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{ln: ln, values: map[string]string{}, expires: map[string]time.Time{},
+		errors: map[string]string{}, conns: map[net.Conn]bool{}, subs: map[string][]net.Conn{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.conns[conn] = true
+			f.mu.Unlock()
+			go f.serve(conn)
+		}
+	}()
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeRedis) URL() string {
+	return "redis://" + f.ln.Addr().String()
+}
+
+// Close stops the listener and drops every connection, as a dead server would
+func (f *fakeRedis) Close() {
+	f.ln.Close()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for conn := range f.conns {
+		conn.Close()
+	}
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		req, err := readRESP(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range req.([]any) {
+			args = append(args, arg.(string))
+		}
+		conn.Write([]byte(f.run(conn, args)))
+	}
+}
+
+func (f *fakeRedis) run(conn net.Conn, args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, args)
+	if msg, ok := f.errors[args[0]]; ok {
+		return "-" + msg + "\r\n"
+	}
+	if len(args) < 2 {
+		return "-ERR wrong number of arguments\r\n"
+	}
+	if exp, ok := f.expires[args[1]]; ok && time.Now().After(exp) {
+		delete(f.values, args[1])
+		delete(f.expires, args[1])
+	}
+
+	switch args[0] {
+	case "AUTH", "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		value, ok := f.values[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "SET":
+		f.values[args[1]] = args[2]
+		delete(f.expires, args[1])
+		if len(args) == 5 && args[3] == "PX" {
+			ms, _ := strconv.Atoi(args[4])
+			f.expires[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+		return "+OK\r\n"
+	case "INCR":
+		n, _ := strconv.ParseInt(f.values[args[1]], 10, 64)
+		f.values[args[1]] = strconv.FormatInt(n+1, 10)
+		return fmt.Sprintf(":%d\r\n", n+1)
+	case "PUBLISH":
+		message := fmt.Sprintf("*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(args[1]), args[1], len(args[2]), args[2])
+		for _, sub := range f.subs[args[1]] {
+			sub.Write([]byte(message))
+		}
+		return fmt.Sprintf(":%d\r\n", len(f.subs[args[1]]))
+	case "SUBSCRIBE":
+		f.subs[args[1]] = append(f.subs[args[1]], conn)
+		return fmt.Sprintf("*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(args[1]), args[1])
+	}
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+func (f *fakeRedis) sent(command string) [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var sent [][]string
+	for _, args := range f.commands {
+		if args[0] == command {
+			sent = append(sent, args)
+		}
+	}
+	return sent
+}
+
+func (f *fakeRedis) fail(command, message string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if message == "" {
+		delete(f.errors, command)
+	} else {
+		f.errors[command] = message
+	}
+}
+
+func (f *fakeRedis) subscribers(channel string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subs[channel])
+}
+
+func newTestRedisCache(t *testing.T, url string, ttl time.Duration) *redisCache {
+	t.Helper()
+	cache, err := newRedisCache(config.Config{RedisURL: url, RedisPrefix: "test:", ResponseCacheTTL: ttl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cache
+}
+
+// Wait until cond holds, for up to a second
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func TestRedisCache(t *testing.T) {
+	redis := newFakeRedis(t)
+	cache := newTestRedisCache(t, redis.URL(), 100*time.Millisecond)
+
+	if _, ok := cache.Get("/articles"); ok {
+		t.Error("hit in an empty cache")
+	}
+	want := CachedResponse{ContentType: "application/json", Language: "fi", Body: []byte("{\"data\":\n[]}")}
+	cache.Set("/articles", want)
+	got, ok := cache.Get("/articles")
+	if !ok || got.ContentType != want.ContentType || got.Language != want.Language || string(got.Body) != string(want.Body) {
+		t.Errorf("got %+v, %v", got, ok)
+	}
+	if set := redis.sent("SET"); len(set) != 1 || !slices.Equal(set[0][3:], []string{"PX", "100"}) || set[0][1] != "test:0:/articles" {
+		t.Errorf("SET commands %q", set)
+	}
+
+	// Entries expire after the TTL
+	time.Sleep(150 * time.Millisecond)
+	if _, ok := cache.Get("/articles"); ok {
+		t.Error("hit after the TTL")
+	}
+
+	// The password and database in the URL are sent on every new connection
+	u := strings.Replace(redis.URL(), "redis://", "redis://:secret@", 1) + "/3"
+	newTestRedisCache(t, u, time.Minute)
+	if auth, sel := redis.sent("AUTH"), redis.sent("SELECT"); len(auth) != 1 || auth[0][1] != "secret" || len(sel) != 1 || sel[0][1] != "3" {
+		t.Errorf("AUTH %q, SELECT %q", auth, sel)
+	}
+	if _, err := newRedisClient("http://localhost:6379"); err == nil {
+		t.Error("http URL accepted")
+	}
+}
+
+func TestRedisCacheInvalidation(t *testing.T) {
+	redis := newFakeRedis(t)
+	cache := newTestRedisCache(t, redis.URL(), time.Minute)
+	replica := newTestRedisCache(t, redis.URL(), time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	var invalidated atomic.Int32
+	go replica.subscribe(ctx, func() { invalidated.Add(1) })
+	eventually(t, "the subscription", func() bool { return redis.subscribers("test:invalidate") == 1 })
+
+	response := CachedResponse{ContentType: "text/plain", Body: []byte("old")}
+	cache.Set("/a", response)
+	if _, ok := replica.Get("/a"); !ok {
+		t.Fatal("entry not shared")
+	}
+
+	// Clearing moves both replicas to the next generation
+	cache.Clear()
+	eventually(t, "the clear", func() bool { return cache.pending.Load() == 0 })
+	eventually(t, "the published generation", func() bool { return replica.gen.Load() == 1 && invalidated.Load() == 1 })
+	if cache.gen.Load() != 1 {
+		t.Errorf("generation %d, want 1", cache.gen.Load())
+	}
+	if _, ok := replica.Get("/a"); ok {
+		t.Error("replica hit after the clear")
+	}
+	if _, ok := cache.Get("/a"); ok {
+		t.Error("hit after the clear")
+	}
+
+	// A cache started later picks up the current generation
+	if later := newTestRedisCache(t, redis.URL(), time.Minute); later.gen.Load() != 1 {
+		t.Errorf("later cache at generation %d", later.gen.Load())
+	}
+
+	// Older generations published late are ignored
+	replica.setGen(0)
+	if replica.gen.Load() != 1 {
+		t.Errorf("generation went back to %d", replica.gen.Load())
+	}
+}
+
+func TestRedisCacheErrors(t *testing.T) {
+	redis := newFakeRedis(t)
+	cache := newTestRedisCache(t, redis.URL(), time.Minute)
+	cache.Set("/a", CachedResponse{Body: []byte("a")})
+
+	// Error replies are misses and keep the connection
+	redis.fail("GET", "ERR out of memory")
+	if _, ok := cache.Get("/a"); ok {
+		t.Error("hit on an error reply")
+	}
+	_, err := cache.client.Do("GET", "test:0:/a")
+	if err == nil || err.Error() != "redis: ERR out of memory" {
+		t.Errorf("error %v", err)
+	}
+	if len(cache.client.pool) != 1 {
+		t.Errorf("%d pooled connections after an error reply, want 1", len(cache.client.pool))
+	}
+	redis.fail("GET", "")
+	if _, ok := cache.Get("/a"); !ok {
+		t.Error("miss after the error")
+	}
+
+	// A failed INCR leaves the generation as it was
+	redis.fail("INCR", "READONLY You can't write against a read only replica")
+	cache.Clear()
+	eventually(t, "the clear", func() bool { return cache.pending.Load() == 0 })
+	if cache.gen.Load() != 0 {
+		t.Errorf("generation %d after a failed clear", cache.gen.Load())
+	}
+
+	// Malformed replies are errors, not panics
+	for _, reply := range []string{"", "\r\n", "?what\r\n", ":x\r\n", "$5\r\nab"} {
+		if _, err := readRESP(bufio.NewReader(strings.NewReader(reply))); err == nil {
+			t.Errorf("%q: no error", reply)
+		}
+	}
+}
+
+func TestRedisCacheDown(t *testing.T) {
+	redis := newFakeRedis(t)
+	cache := newTestRedisCache(t, redis.URL(), time.Minute)
+	cache.Set("/a", CachedResponse{Body: []byte("a")})
+	redis.Close()
+
+	// Requests go past the cache while Redis is down
+	if _, ok := cache.Get("/a"); ok {
+		t.Error("hit while Redis is down")
+	}
+	cache.Set("/b", CachedResponse{Body: []byte("b")})
+	if _, ok := cache.Get("/b"); ok {
+		t.Error("hit while Redis is down")
+	}
+	cache.Clear()
+	eventually(t, "the clear", func() bool { return cache.pending.Load() == 0 })
+	if cache.gen.Load() != 0 {
+		t.Errorf("generation %d", cache.gen.Load())
+	}
+
+	// The subscription gives up when its context ends
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		cache.subscribe(ctx, func() {})
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Error("subscription still retrying")
+	}
+
+	// A server can't start with its cache unreachable
+	if _, err := newRedisCache(config.Config{RedisURL: redis.URL()}); err == nil {
+		t.Error("no error with Redis down")
+	}
+}
@@ -0,0 +1,430 @@
+package handlers
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"go-spring/internal/model"
+)
+
+// A primary streams its change feed to read-only replicas (REPLICA_OF): a
+// snapshot of the articles, users and workspaces, then every article event
+// as it happens and the users and workspaces whenever a save finds them
+// changed, with heartbeats in between. Replicas apply it to their own
+// store and serve reads; /readyz fails while they lag behind.
+//
+// The feed is a gob stream of ReplicationMessages rather than JSON, as the
+// replicas need every field the primary keeps, including the ones the API
+// never shows (attachment keys, push subscription keys, webhook secrets).
+
+// Change feed message types besides the article events
+const (
+	ReplicationSnapshot     = "snapshot"
+	ReplicationAccessUpdate = "access"
+	ReplicationHeartbeat    = "heartbeat"
+)
+
+// Media type of the change feed
+const replicationContentType = "application/x-gob"
+
+// How often the primary sends a heartbeat. A replica that hears nothing for
+// three heartbeats reconnects.
+var replicationHeartbeat = 5 * time.Second
+
+// ReplicationMessage is one message of the change feed
+type ReplicationMessage struct {
+	Type     string             `json:"type"` // snapshot, access, heartbeat or an article event
+	Time     time.Time          `json:"time"` // on the primary's clock
+	Article  *model.Article     `json:"article,omitempty"`
+	Snapshot *ReplicationState  `json:"snapshot,omitempty"`
+	Access   *ReplicationAccess `json:"access,omitempty"`
+}
+
+// ReplicationState is the primary's data when a replica connects
+type ReplicationState struct {
+	Articles         []model.Article `json:"articles"`
+	NextID           int             `json:"next_id"`
+	NextAttachmentID int             `json:"next_attachment_id"`
+	ReplicationAccess
+}
+
+// ReplicationAccess is the users and workspaces, which decide who may read
+// confidential articles and workspace drafts, and who may sign in
+type ReplicationAccess struct {
+	Users      []model.User      `json:"users"`
+	Workspaces []model.Workspace `json:"workspaces"`
+}
+
+type replicationState struct {
+	// The users and workspaces as saved, for the change feeds
+	accessChanges *EventBus[ReplicationAccess]
+	accessMutex   sync.Mutex
+	lastAccess    ReplicationAccess
+
+	replicaMutex     sync.Mutex
+	replicaConnected bool
+	replicaLastSync  time.Time
+	replicaRestart   context.CancelCauseFunc // ends the current connection
+}
+
+// Publish the users and workspaces if they changed since the last save; the
+// caller holds articlesMutex. Writers change them in many places, and all
+// of them save.
+func (app *App) publishAccess() {
+	app.accessMutex.Lock()
+	defer app.accessMutex.Unlock()
+	if reflect.DeepEqual(app.lastAccess, ReplicationAccess{Users: app.users, Workspaces: app.workspaces}) {
+		return
+	}
+	// Writers replace the users' and workspaces' maps and slices rather
+	// than change them, so copies of the lists are enough
+	app.lastAccess = ReplicationAccess{Users: slices.Clone(app.users), Workspaces: slices.Clone(app.workspaces)}
+	app.accessChanges.Publish(app.lastAccess)
+}
+
+// GET /admin/replication - Stream the change feed as gob until the replica
+// disconnects. A replica too slow to keep up is disconnected and
+// starts over from a new snapshot.
+func (app *App) streamReplication(w http.ResponseWriter, r *http.Request) {
+	feed := make(chan ArticleEvent, 256)
+	accessFeed := make(chan ReplicationAccess, 16)
+	overflow := make(chan struct{})
+	var once sync.Once
+
+	// Writers publish while holding articlesMutex, so no event falls between
+	// the snapshot and the feed
+	app.articlesMutex.RLock()
+	stop := app.events.Listen(func(event ArticleEvent) {
+		select {
+		case feed <- event:
+		default:
+			once.Do(func() { close(overflow) })
+		}
+	})
+	stopAccess := app.accessChanges.Listen(func(access ReplicationAccess) {
+		select {
+		case accessFeed <- access:
+		default:
+			once.Do(func() { close(overflow) })
+		}
+	})
+	state := ReplicationState{
+		Articles: slices.Clone(app.articles), NextID: app.articleIDs.Peek(), NextAttachmentID: app.attachmentIDs.Peek(),
+		ReplicationAccess: ReplicationAccess{Users: slices.Clone(app.users), Workspaces: slices.Clone(app.workspaces)},
+	}
+	app.articlesMutex.RUnlock()
+	defer stop()
+	defer stopAccess()
+
+	w.Header().Set("Content-Type", replicationContentType)
+	enc := gob.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	send := func(msg ReplicationMessage) bool {
+		if err := enc.Encode(msg); err != nil {
+			return false // replica went away
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	}
+
+	log.Printf("Replica %s connected", r.RemoteAddr)
+	if !send(ReplicationMessage{Type: ReplicationSnapshot, Time: time.Now(), Snapshot: &state}) {
+		return
+	}
+	ticker := time.NewTicker(replicationHeartbeat)
+	defer ticker.Stop()
+	for {
+		var msg ReplicationMessage
+		select {
+		case <-r.Context().Done():
+			return
+		case <-app.ShutdownRequested():
+			return
+		case <-overflow:
+			log.Printf("Replica %s fell behind, closing its change feed", r.RemoteAddr)
+			return
+		case event := <-feed:
+			msg = ReplicationMessage{Type: event.Type, Time: event.Time, Article: &event.Article}
+		case access := <-accessFeed:
+			msg = ReplicationMessage{Type: ReplicationAccessUpdate, Time: time.Now(), Access: &access}
+		case now := <-ticker.C:
+			msg = ReplicationMessage{Type: ReplicationHeartbeat, Time: now}
+		}
+		if !send(msg) {
+			return
+		}
+	}
+}
+
+// ReplicaStatus is a replica's view of its primary, in /readyz
+type ReplicaStatus struct {
+	Primary    string    `json:"primary"`
+	Connected  bool      `json:"connected"`
+	LastSync   time.Time `json:"last_sync,omitzero"` // primary time of the last message applied
+	LagSeconds float64   `json:"lag_seconds"`
+}
+
+// Ends a connection to a primary that is no longer the leader
+var errLeaderChanged = errors.New("leader changed")
+
+// Whether this server is a read-only replica: with REPLICA_OF, or while it
+// hasn't won the election
+func (app *App) replicating() bool {
+	if app.electing() {
+		return !app.electedLeader()
+	}
+	return app.cfg.ReplicaOf != ""
+}
+
+// The primary a replica streams from, "" while no leader is elected
+func (app *App) primaryURL() string {
+	if app.electing() {
+		// A leader whose lease is running out has no one to follow
+		if primary := app.electedPrimary(); primary != app.cfg.ElectionURL {
+			return primary
+		}
+		return ""
+	}
+	return app.cfg.ReplicaOf
+}
+
+func (app *App) replicaStatus() ReplicaStatus {
+	primary := redactedURL(app.primaryURL())
+	app.replicaMutex.Lock()
+	defer app.replicaMutex.Unlock()
+	status := ReplicaStatus{Primary: primary, Connected: app.replicaConnected, LastSync: app.replicaLastSync}
+	if !app.replicaLastSync.IsZero() {
+		status.LagSeconds = max(time.Since(app.replicaLastSync), 0).Seconds()
+	}
+	return status
+}
+
+// Whether the replica is behind by more than REPLICA_MAX_LAG, or hasn't
+// synced at all
+func (app *App) replicaLagging() bool {
+	app.replicaMutex.Lock()
+	defer app.replicaMutex.Unlock()
+	return app.replicaLastSync.IsZero() || time.Since(app.replicaLastSync) > app.cfg.ReplicaMaxLag
+}
+
+// Follow the primary's change feed until ctx is done, reconnecting after
+// failures and when another primary is elected
+func (app *App) runReplica(ctx context.Context) {
+	for {
+		var err error
+		primary := app.primaryURL()
+		if primary != "" {
+			err = app.followPrimary(ctx, primary)
+		}
+		app.replicaMutex.Lock()
+		app.replicaConnected = false
+		app.replicaMutex.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errLeaderChanged) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Warning: Replication from %s stopped: %v", redactedURL(primary), err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// Make the replica reconnect, to the primary just elected
+func (app *App) restartReplication() {
+	app.replicaMutex.Lock()
+	defer app.replicaMutex.Unlock()
+	if app.replicaRestart != nil {
+		app.replicaRestart(errLeaderChanged)
+	}
+}
+
+// Read the change feed of one connection to the primary
+func (app *App) followPrimary(ctx context.Context, primary string) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	app.replicaMutex.Lock()
+	app.replicaRestart = cancel
+	app.replicaMutex.Unlock()
+	// Give up on a primary that went silent without closing the connection
+	watchdog := time.AfterFunc(3*replicationHeartbeat, func() { cancel(errors.New("primary went silent")) })
+	defer watchdog.Stop()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, primary+"/admin/replication", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", replicationContentType)
+	resp, err := http.DefaultClient.Do(req) // sends the credentials in the URL
+	if err != nil {
+		return cancelCause(ctx, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("primary answered %s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != replicationContentType {
+		return fmt.Errorf("primary sent %q, not a gob change feed", ct)
+	}
+
+	app.replicaMutex.Lock()
+	app.replicaConnected = true
+	app.replicaMutex.Unlock()
+	dec := gob.NewDecoder(resp.Body)
+	for {
+		var msg ReplicationMessage
+		if err := dec.Decode(&msg); err != nil {
+			return cancelCause(ctx, err)
+		}
+		watchdog.Reset(3 * replicationHeartbeat)
+		if err := app.applyReplication(msg); err != nil {
+			return err
+		}
+		app.replicaMutex.Lock()
+		app.replicaLastSync = msg.Time
+		app.replicaMutex.Unlock()
+	}
+}
+
+// Why ctx was canceled if it was, otherwise err
+func cancelCause(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return err
+}
+
+// Apply a change feed message to the articles in memory and save them.
+// Article events are published again, so that this server's cache, gRPC
+// watchers and own replicas follow too.
+func (app *App) applyReplication(msg ReplicationMessage) error {
+	switch msg.Type {
+	case ReplicationHeartbeat:
+		return nil
+	case ReplicationSnapshot:
+		if msg.Snapshot == nil {
+			return errors.New("snapshot without articles")
+		}
+	case ReplicationAccessUpdate:
+		if msg.Access == nil {
+			return errors.New("access without users and workspaces")
+		}
+	case EventArticleCreated, EventArticleUpdated, EventArticleDeleted:
+		if msg.Article == nil {
+			return fmt.Errorf("%s without an article", msg.Type)
+		}
+	default:
+		return nil // from a newer primary
+	}
+
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+	switch msg.Type {
+	case ReplicationSnapshot:
+		app.articles = msg.Snapshot.Articles
+		app.articleIDs.Advance(msg.Snapshot.NextID)
+		app.attachmentIDs.Advance(msg.Snapshot.NextAttachmentID)
+		app.applyAccess(msg.Snapshot.ReplicationAccess)
+		return nil
+	case ReplicationAccessUpdate:
+		app.applyAccess(*msg.Access)
+		return nil
+	}
+
+	article := *msg.Article
+	// The articles slice is kept in ID order
+	i := sort.Search(len(app.articles), func(i int) bool { return app.articles[i].ID >= article.ID })
+	found := i < len(app.articles) && app.articles[i].ID == article.ID
+	switch {
+	case msg.Type == EventArticleDeleted:
+		if found {
+			app.articles = slices.Delete(app.articles, i, i+1)
+		}
+	case found:
+		app.articles[i] = article
+	default:
+		app.articles = slices.Insert(app.articles, i, article)
+	}
+	// The counters matter once the replica is promoted
+	app.articleIDs.Advance(article.ID + 1)
+	for _, a := range article.Attachments {
+		app.attachmentIDs.Advance(a.ID + 1)
+	}
+	app.events.Publish(ArticleEvent{Type: msg.Type, Article: article, Time: msg.Time})
+	app.scheduleSave()
+	return nil
+}
+
+// Take the primary's users and workspaces, with the rest of a snapshot or
+// on their own; the caller holds articlesMutex
+func (app *App) applyAccess(access ReplicationAccess) {
+	app.users, app.workspaces = access.Users, access.Workspaces
+	app.noteUserEndpoints()
+	if app.responseCache != nil {
+		app.invalidateResponseCache()
+	}
+	app.scheduleSave()
+}
+
+// A URL without its password, for logs and status
+func redactedURL(s string) string {
+	if u, err := url.Parse(s); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return s
+}
+
+// Readiness is the answer of /readyz
+type Readiness struct {
+	Ready   bool           `json:"ready"`
+	Leader  string         `json:"leader,omitempty"` // the elected primary, with ELECTION_URL
+	Replica *ReplicaStatus `json:"replica,omitempty"`
+	// The stores' circuit breakers; every node shares the stores, so an
+	// open breaker doesn't make this one less ready than the others
+	DataStore BreakerStatus `json:"data_store"`
+	BlobStore BreakerStatus `json:"blob_store"`
+}
+
+// GET /readyz - Whether load balancers should send requests here: not while
+// draining, nor while a replica lags more than REPLICA_MAX_LAG
+func (app *App) getReadiness(w http.ResponseWriter, r *http.Request) {
+	readiness := Readiness{Ready: true, DataStore: app.dataBreaker.status(), BlobStore: app.blobBreaker.status()}
+	message := "Ready"
+	if app.electing() {
+		readiness.Leader = redactedURL(app.electedPrimary())
+	}
+	if app.replicating() {
+		status := app.replicaStatus()
+		readiness.Replica = &status
+		if app.replicaLagging() {
+			readiness.Ready, message = false, "Replica is lagging behind the primary"
+		}
+	}
+	if app.draining() {
+		readiness.Ready, message = false, "Server is draining"
+	}
+
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	app.writeResponse(w, r, status, Response{Message: message, Data: readiness})
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-spring/internal/jobs"
+)
+
+// Retention rules drop history the server would otherwise keep forever:
+// daily view counts older than RETAIN_VIEWS, activity entries older than
+// RETAIN_ACTIVITY and searches older than RETAIN_SEARCHES. The retention job enforces them every night at
+// RETENTION_AT, or on demand; a dry run reports what it would drop. Article
+// data is never touched: the store keeps no deleted articles or revisions.
+
+// Kind of the job that enforces the retention rules
+const jobRetention = "retention"
+
+// Payload of a retention job
+type retentionJob struct {
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// RetentionReport is the result of a retention job: what each rule that is
+// on dropped, or would have in a dry run
+type RetentionReport struct {
+	DryRun bool            `json:"dry_run"`
+	Rules  []RetentionRule `json:"rules"`
+}
+
+// RetentionRule is one rule and what it dropped
+type RetentionRule struct {
+	Name    string    `json:"name"`    // views, activity or searches
+	MaxAge  string    `json:"max_age"` // e.g. 8760h0m0s
+	Before  time.Time `json:"before"`
+	Removed int       `json:"removed"` // daily view buckets, activity entries or searches
+}
+
+// Whether any retention rule is on
+func (app *App) retentionRules() bool {
+	return app.cfg.RetainViews > 0 || app.cfg.RetainActivity > 0 || app.cfg.RetainSearches > 0
+}
+
+// POST /admin/retention - Enforce the retention rules in the background
+func (app *App) startRetention(w http.ResponseWriter, r *http.Request) {
+	dryRun, err := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	if err != nil && r.URL.Query().Has("dry_run") {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "dry_run must be true or false")
+		return
+	}
+	app.acceptJob(w, r, jobRetention, retentionJob{DryRun: dryRun})
+}
+
+// Drop what the retention rules say is too old
+func (app *App) runRetentionJob(ctx context.Context, job jobs.Job) error {
+	var payload retentionJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return err
+	}
+	now := time.Now()
+	report := RetentionReport{DryRun: payload.DryRun, Rules: []RetentionRule{}}
+	if maxAge := app.cfg.RetainViews; maxAge > 0 && app.viewCounter != nil {
+		before := now.Add(-maxAge)
+		report.Rules = append(report.Rules, RetentionRule{Name: "views", MaxAge: maxAge.String(), Before: before,
+			Removed: app.viewCounter.DropDaily(before, payload.DryRun)})
+	}
+	if maxAge := app.cfg.RetainActivity; maxAge > 0 && app.activityLog != nil {
+		before := now.Add(-maxAge)
+		report.Rules = append(report.Rules, RetentionRule{Name: "activity", MaxAge: maxAge.String(), Before: before,
+			Removed: app.activityLog.DropBefore(before, payload.DryRun)})
+	}
+	if maxAge := app.cfg.RetainSearches; maxAge > 0 && app.searchLog != nil {
+		before := now.Add(-maxAge)
+		report.Rules = append(report.Rules, RetentionRule{Name: "searches", MaxAge: maxAge.String(), Before: before,
+			Removed: app.searchLog.DropBefore(before, payload.DryRun)})
+	}
+	return jobs.SetResult(ctx, report)
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+
+	"go-spring/internal/config"
+	"go-spring/internal/jobs"
+	"go-spring/internal/model"
+	"go-spring/internal/webpush"
+)
+
+// Route describes one API endpoint. The table below is the single source of
+// truth: it registers the handlers and generates the OpenAPI document.
+type Route struct {
+	Method  string
+	Path    string
+	Handler http.HandlerFunc
+	Summary string
+
+	Query        []QueryParam // documented query parameters
+	Request      interface{}  // zero value of the JSON request body type, nil if none
+	Upload       bool         // multipart/form-data body with a "file" field
+	Response     interface{}  // zero value of Response.Data, nil if none
+	Status       int          // success status, defaults to 200
+	ContentType  string       // non-JSON success response media type
+	Hidden       bool         // left out of the OpenAPI document
+	Cached       bool         // served from the response cache until an article changes
+	CacheControl string       // default Cache-Control of successful responses, see CACHE_CONTROL
+
+	enabled       func(config.Config) bool // whether the configuration turns the route on; nil is always on
+	admin         bool                     // requires an admin account outside /admin/ and /jobs/ (plugin routes)
+	workspaceRole string                   // role required in the {ws} workspace
+	editors       bool                     // a GET of an article only its workspace's editors may make, as with changes
+	linkSigned    bool                     // authorized by a signed link in the query rather than credentials
+}
+
+type QueryParam struct {
+	Name        string
+	Type        string // OpenAPI primitive type
+	Description string
+}
+
+func (app *App) apiRoutes() []Route {
+	return []Route{
+		{Method: "GET", Path: "/", Handler: app.homePage, Summary: "Welcome message"},
+		{Method: "GET", Path: "/readyz", Handler: app.getReadiness, Summary: "Readiness for load balancers, with replica lag",
+			Response: Readiness{}},
+		{Method: "GET", Path: "/stats", Handler: app.getStats, Summary: "Article counts by status, category and workspace, content size, dates and store health",
+			Response: Stats{}, admin: true},
+		{Method: "GET", Path: "/articles", Handler: app.getAllArticles, Summary: "Get all articles",
+			Query: []QueryParam{
+				{"page_size", "integer", "page in ID order instead of the full list (default 20, max 100)"},
+				{"page_token", "string", "next_page_token of the previous page"},
+				{"language", "string", "only articles in this language, e.g. fi"},
+			},
+			Response: []model.Article{}, Cached: true},
+		{Method: "GET", Path: "/articles/featured", Handler: app.getFeaturedArticles, Summary: "Get featured articles",
+			Response: []model.Article{}, Cached: true},
+		{Method: "GET", Path: "/articles/search", Handler: app.searchArticles, Summary: "Search the articles by their words, or by meaning with mode=semantic",
+			Query: []QueryParam{
+				{"q", "string", "what to search for"},
+				{"mode", "string", "keyword (default) or semantic"},
+				{"limit", "integer", "how many results (default 10, max 100)"},
+			},
+			Response: []SearchResult{}},
+		{Method: "GET", Path: "/articles/trending", Handler: app.getTrendingArticles, Summary: "Get the published articles with the most recent views",
+			Query:    []QueryParam{{"limit", "integer", "how many (default 10, max 100)"}},
+			Response: []TrendingArticle{}},
+		{Method: "PUT", Path: "/articles/featured/order", Handler: app.setFeaturedOrder, Summary: "Set featured order",
+			Request: FeaturedOrderRequest{}, Response: []model.Article{}, admin: true},
+		{Method: "GET", Path: "/articles/export.ndjson", Handler: app.exportArticlesNDJSON, Summary: "Export all articles as NDJSON",
+			ContentType: "application/x-ndjson"},
+		{Method: "GET", Path: "/articles/export.csv", Handler: app.exportArticlesCSV, Summary: "Export all articles as CSV",
+			Query: []QueryParam{
+				{"columns", "string", "comma separated columns to include, in order"},
+				{"delimiter", "string", "field separator, default comma (tab for TSV)"},
+				{"bom", "boolean", "start with a UTF-8 byte order mark for Excel"},
+			},
+			ContentType: "text/csv"},
+		{Method: "POST", Path: "/articles/import.csv", Handler: app.importArticlesCSV, Summary: "Create articles from CSV rows",
+			Query: []QueryParam{
+				{"map", "string", "column to field mapping, e.g. Otsikko:title,Kuvaus:desc"},
+				{"delimiter", "string", "field separator, detected from the header by default"},
+				{"encoding", "string", "utf-8, utf-16le, utf-16be, windows-1252 or latin1; detected by default"},
+			},
+			Upload: true, Response: ImportReport{}},
+		{Method: "GET", Path: "/articles/by-slug/{slug}", Handler: app.getArticleBySlug, Summary: "Get single article by its slug",
+			Query: []QueryParam{
+				{"format", "string", "html adds content rendered from Markdown"},
+				{"lang", "string", "language of a translation, in place of Accept-Language"},
+			},
+			Response: RenderedArticle{}, Cached: true},
+		{Method: "GET", Path: "/articles/{id}", Handler: app.getArticle, Summary: "Get single article",
+			Query: []QueryParam{
+				{"format", "string", "html adds content rendered from Markdown"},
+				{"lang", "string", "language of a translation, in place of Accept-Language"},
+			},
+			Response: RenderedArticle{}, Cached: true},
+		{Method: "GET", Path: "/articles/{id}/analytics", Handler: app.getArticleAnalytics, Summary: "Views of an article over time, in hourly or daily buckets",
+			Query: []QueryParam{
+				{"interval", "string", "hour or day (default)"},
+				{"from", "string", "start, RFC 3339 or a date (default 30 days, or 48 hours, before to)"},
+				{"to", "string", "end, RFC 3339 or a date (default now)"},
+			},
+			Response: ArticleAnalytics{}, editors: true},
+		{Method: "GET", Path: "/articles/{id}/html", Handler: app.getArticleHTML, Summary: "Get rendered article content",
+			Query:       []QueryParam{{"lang", "string", "language of a translation, in place of Accept-Language"}},
+			ContentType: "text/html", Cached: true},
+		// Not cached: the response cache would answer a Range with the whole content
+		{Method: "GET", Path: "/articles/{id}/content", Handler: app.getArticleContent, Summary: "Get article Markdown, or the byte range in a Range header",
+			ContentType: "text/markdown"},
+		{Method: "POST", Path: "/articles", Handler: app.createArticle, Summary: "Create new article",
+			Request: model.CreateArticleRequest{}, Response: model.Article{}, Status: http.StatusCreated},
+		{Method: "POST", Path: "/articles/import-url", Handler: app.importArticleFromURL, Summary: "Create draft article from a web page",
+			Request: ImportURLRequest{}, Response: model.Article{}, Status: http.StatusCreated},
+		{Method: "PUT", Path: "/articles/{id}", Handler: app.updateArticle, Summary: "Update article",
+			Request: model.ArticleUpdate{}, Response: model.Article{}},
+		{Method: "DELETE", Path: "/articles/{id}", Handler: app.deleteArticle, Summary: "Delete article"},
+		{Method: "GET", Path: "/articles/{id}/attachments", Handler: app.getAttachments, Summary: "List attachments",
+			Response: []model.Attachment{}},
+		{Method: "POST", Path: "/articles/{id}/attachments", Handler: app.uploadAttachment, Summary: "Upload attachment",
+			Query: []QueryParam{{"private", "boolean", "serve only to users with access and signed URLs"}}, Upload: true, Response: model.Attachment{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/articles/{id}/attachments/{attachmentId}", Handler: app.serveAttachment, Summary: "Download attachment",
+			ContentType: "application/octet-stream", CacheControl: "public, max-age=86400"},
+		{Method: "DELETE", Path: "/articles/{id}/attachments/{attachmentId}", Handler: app.deleteAttachment, Summary: "Delete attachment"},
+		{Method: "POST", Path: "/articles/{id}/attachments/{attachmentId}/signed-url", Handler: app.createSignedURL, Summary: "Sign an expiring download URL for an attachment",
+			Query: []QueryParam{{"expires_in", "string", "lifetime such as 10m, up to SIGNED_URL_TTL"}}, Response: SignedURL{}},
+		{Method: "POST", Path: "/articles/{id}/uploads", Handler: app.createUpload, Summary: "Open a resumable upload of an attachment or the content",
+			Request: UploadRequest{}, Response: UploadSession{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/articles/{id}/uploads/{uploadId}", Handler: app.getUpload, Summary: "Show how much of an upload has arrived",
+			Response: UploadSession{}},
+		{Method: "PATCH", Path: "/articles/{id}/uploads/{uploadId}", Handler: app.patchUpload, Summary: "Send the chunk at Upload-Offset; the last one completes the upload",
+			Response: UploadSession{}},
+		{Method: "DELETE", Path: "/articles/{id}/uploads/{uploadId}", Handler: app.deleteUpload, Summary: "Abandon an upload"},
+		{Method: "GET", Path: "/articles/{id}/translations", Handler: app.getTranslations, Summary: "List the translations of an article and whether they are up to date",
+			Response: ArticleTranslations{}, editors: true},
+		{Method: "PUT", Path: "/articles/{id}/translations/{lang}", Handler: app.putTranslation, Summary: "Add or replace the translation of an article into a language",
+			Request: TranslationRequest{}, Response: model.Article{}},
+		{Method: "DELETE", Path: "/articles/{id}/translations/{lang}", Handler: app.deleteTranslation, Summary: "Delete a translation"},
+		{Method: "POST", Path: "/articles/{id}/summarize", Handler: app.summarizeArticleDesc, Summary: "Write the description of an article again from its content",
+			Response: model.Article{}, enabled: app.summarizerEnabled},
+		{Method: "PUT", Path: "/articles/{id}/cover", Handler: app.setCoverImage, Summary: "Set cover image from attachment or URL",
+			Request: CoverRequest{}, Response: model.Article{}},
+		{Method: "POST", Path: "/articles/{id}/cover", Handler: app.uploadCoverImage, Summary: "Upload cover image",
+			Upload: true, Response: model.Article{}, Status: http.StatusCreated},
+		{Method: "DELETE", Path: "/articles/{id}/cover", Handler: app.deleteCoverImage, Summary: "Remove cover image"},
+		{Method: "GET", Path: "/attachments/{id}", Handler: app.serveAttachmentVariant, Summary: "Download attachment, images resized to fit",
+			Query: []QueryParam{
+				{"w", "integer", "maximum width in pixels"},
+				{"h", "integer", "maximum height in pixels"},
+			},
+			ContentType: "application/octet-stream", CacheControl: "public, max-age=86400"},
+		{Method: "GET", Path: "/feed.rss", Handler: app.getRSSFeed, Summary: "RSS feed of published articles",
+			ContentType: "application/rss+xml", Cached: true, CacheControl: "public, max-age=300"},
+		{Method: "GET", Path: "/feed.atom", Handler: app.getAtomFeed, Summary: "Atom feed of published articles",
+			Query:       []QueryParam{{"page", "integer", "page number, 1 is newest"}},
+			ContentType: "application/atom+xml", Cached: true, CacheControl: "public, max-age=300"},
+		{Method: "GET", Path: "/workspaces", Handler: app.getWorkspaces, Summary: "List workspaces",
+			Response: []model.Workspace{}},
+		{Method: "POST", Path: "/workspaces", Handler: app.createWorkspace, Summary: "Create workspace, owned by you",
+			Request: WorkspaceRequest{}, Response: model.Workspace{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/workspaces/{ws}", Handler: app.getWorkspace, Summary: "Get workspace, with members for owners",
+			Response: model.Workspace{}},
+		{Method: "PUT", Path: "/workspaces/{ws}", Handler: app.updateWorkspace, Summary: "Update workspace settings",
+			Request: WorkspaceUpdate{}, Response: model.Workspace{}, workspaceRole: model.WorkspaceOwner},
+		{Method: "DELETE", Path: "/workspaces/{ws}", Handler: app.deleteWorkspace, Summary: "Delete workspace without articles",
+			workspaceRole: model.WorkspaceOwner},
+		{Method: "PUT", Path: "/workspaces/{ws}/members/{username}", Handler: app.setWorkspaceMember, Summary: "Add workspace member or change their role",
+			Request: MemberRequest{}, Response: model.Workspace{}, workspaceRole: model.WorkspaceOwner},
+		{Method: "DELETE", Path: "/workspaces/{ws}/members/{username}", Handler: app.removeWorkspaceMember, Summary: "Remove workspace member",
+			Response: model.Workspace{}, workspaceRole: model.WorkspaceOwner},
+		{Method: "GET", Path: "/workspaces/{ws}/articles", Handler: app.getWorkspaceArticles, Summary: "Get workspace articles, with drafts for members",
+			Response: []model.Article{}},
+		{Method: "POST", Path: "/workspaces/{ws}/articles", Handler: app.createWorkspaceArticle, Summary: "Create article in workspace",
+			Request: model.CreateArticleRequest{}, Response: model.Article{}, Status: http.StatusCreated, workspaceRole: model.WorkspaceEditor},
+		{Method: "GET", Path: "/workspaces/{ws}/feed.rss", Handler: app.getWorkspaceRSSFeed, Summary: "RSS feed of a workspace",
+			ContentType: "application/rss+xml", Cached: true, CacheControl: "public, max-age=300"},
+		{Method: "GET", Path: "/workspaces/{ws}/feed.atom", Handler: app.getWorkspaceAtomFeed, Summary: "Atom feed of a workspace",
+			Query:       []QueryParam{{"page", "integer", "page number, 1 is newest"}},
+			ContentType: "application/atom+xml", Cached: true, CacheControl: "public, max-age=300"},
+		{Method: "POST", Path: "/admin/import", Handler: app.importArticlesJSON, Summary: "Create articles from a JSON or NDJSON file",
+			Query: []QueryParam{
+				{"dry_run", "boolean", "validate and report without storing anything"},
+				{"async", "boolean", "answer 202 with a job whose result is the report (or send Prefer: respond-async)"},
+			},
+			Upload: true, Response: ImportReport{}},
+		{Method: "POST", Path: "/admin/import/wordpress", Handler: app.importWordPress, Summary: "Create articles from a WordPress export (WXR)",
+			Query: []QueryParam{
+				{"dry_run", "boolean", "validate and report without storing anything"},
+				{"async", "boolean", "answer 202 with a job whose result is the report (or send Prefer: respond-async)"},
+			},
+			Upload: true, Response: ImportReport{}},
+		{Method: "GET", Path: "/admin/export.zip", Handler: app.exportArchive, Summary: "Download articles, attachments and metadata as a zip archive",
+			ContentType: "application/zip"},
+		{Method: "POST", Path: "/admin/export", Handler: app.startExport, Summary: "Build the export archive in the background",
+			Response: jobs.Job{}, Status: http.StatusAccepted},
+		{Method: "POST", Path: "/admin/blobs/gc", Handler: app.startBlobGC, Summary: "Delete attachment files no attachment refers to, in the background",
+			Response: jobs.Job{}, Status: http.StatusAccepted},
+		{Method: "POST", Path: "/admin/compact", Handler: app.startCompact, Summary: "Rewrite and vacuum the data store in the background",
+			Response: jobs.Job{}, Status: http.StatusAccepted},
+		{Method: "POST", Path: "/admin/retention", Handler: app.startRetention, Summary: "Drop view counts and activity older than the retention rules, in the background",
+			Query:    []QueryParam{{"dry_run", "boolean", "report what would be dropped without dropping it"}},
+			Response: jobs.Job{}, Status: http.StatusAccepted},
+		{Method: "GET", Path: "/jobs/{id}", Handler: app.getJob, Summary: "Poll the state, progress and result of a background job",
+			Response: jobs.Job{}},
+		{Method: "GET", Path: "/jobs/{id}/download", Handler: app.downloadJobResult, Summary: "Download the archive of a finished export job",
+			ContentType: "application/zip"},
+		{Method: "GET", Path: "/admin/jobs", Handler: app.listJobs, Summary: "List background jobs",
+			Query:    []QueryParam{{"state", "string", "pending, running, done or dead (failed too many times)"}},
+			Response: []jobs.Job{}},
+		{Method: "POST", Path: "/admin/jobs/{id}/retry", Handler: app.retryJob, Summary: "Queue a dead job again",
+			Response: jobs.Job{}},
+		{Method: "POST", Path: "/admin/shutdown", Handler: app.shutdownServer, Summary: "Shut down gracefully",
+			Response: DrainStatus{}, Status: http.StatusAccepted},
+		{Method: "POST", Path: "/admin/drain", Handler: app.drainServer, Summary: "Close connections after every response for a grace period, then shut down",
+			Query:    []QueryParam{{"grace", "string", "how long to keep serving, e.g. 30s (default DRAIN_GRACE)"}},
+			Response: DrainStatus{}, Status: http.StatusAccepted},
+		{Method: "GET", Path: "/admin/replication", Handler: app.streamReplication, Summary: "Stream the change feed to a replica as gob",
+			ContentType: replicationContentType},
+		{Method: "GET", Path: "/admin/mode", Handler: app.getMode, Summary: "Show the server mode",
+			Response: ServerMode{}},
+		{Method: "PUT", Path: "/admin/mode", Handler: app.putMode, Summary: "Switch between normal, read-only and maintenance mode",
+			Request: ModeRequest{}, Response: ServerMode{}},
+		{Method: "GET", Path: "/admin/users", Handler: app.listUsers, Summary: "List users",
+			Response: []UserSummary{}},
+		{Method: "GET", Path: "/admin/users/{username}/export.zip", Handler: app.exportUserData, Summary: "Download a user's account, activity and articles as a zip archive",
+			ContentType: "application/zip"},
+		{Method: "POST", Path: "/admin/users/{username}/erase", Handler: app.eraseUser, Summary: "Delete a user's account and activity, keeping or deleting their articles",
+			Query:    []QueryParam{{"articles", "string", "keep (default) or delete the articles they created"}},
+			Response: Erasure{}},
+		{Method: "GET", Path: "/admin/translations", Handler: app.listTranslations, Summary: "List the translation states of the articles",
+			Query:    []QueryParam{{"language", "string", "only articles without an up-to-date translation into this language"}},
+			Response: []ArticleTranslations{}},
+		{Method: "GET", Path: "/admin/search-analytics", Handler: app.getSearchAnalytics, Summary: "The most searched queries, and those that find nothing",
+			Query: []QueryParam{
+				{"from", "string", "start, RFC 3339 or a date (default 30 days before to)"},
+				{"to", "string", "end, RFC 3339 or a date (default now)"},
+				{"limit", "integer", "queries in each list (default 20, max 100)"},
+			},
+			Response: SearchAnalytics{}},
+		{Method: "GET", Path: "/admin/moderation", Handler: app.listModeration, Summary: "List the articles held for moderation as likely spam",
+			Response: []model.Article{}},
+		{Method: "POST", Path: "/admin/moderation/{id}/approve", Handler: app.approveArticle, Summary: "Publish a held article",
+			Response: model.Article{}},
+		{Method: "POST", Path: "/admin/moderation/{id}/reject", Handler: app.rejectArticle, Summary: "Delete a held article"},
+		{Method: "GET", Path: "/admin/quotas", Handler: app.getQuotas, Summary: "List the limits and usage of every workspace",
+			Response: []Quota{}},
+		{Method: "GET", Path: "/admin/quotas/{ws}", Handler: app.getQuota, Summary: "Get the limits and usage of a workspace",
+			Response: Quota{}},
+		{Method: "PUT", Path: "/admin/quotas/{ws}", Handler: app.setQuota, Summary: "Set the limits of a workspace",
+			Request: LimitsRequest{}, Response: Quota{}},
+		{Method: "GET", Path: "/account/notifications", Handler: app.getNotificationSettings, Summary: "Show your email notification settings",
+			Response: NotificationSettings{}},
+		{Method: "PUT", Path: "/account/notifications", Handler: app.setNotificationSettings, Summary: "Change your email address and the events you are emailed about",
+			Request: NotificationSettingsRequest{}, Response: NotificationSettings{}},
+		{Method: "GET", Path: "/account/preferences", Handler: app.getPreferences, Summary: "Show the channels you are notified through about each event",
+			Response: Preferences{}},
+		{Method: "PUT", Path: "/account/preferences", Handler: app.setPreferences, Summary: "Choose the channels you are notified through, and your notification webhook",
+			Request: PreferencesRequest{}, Response: Preferences{}},
+		{Method: "GET", Path: "/account/searches", Handler: app.listSavedSearches, Summary: "List your saved searches",
+			Response: []model.SavedSearch{}},
+		{Method: "POST", Path: "/account/searches", Handler: app.createSavedSearch, Summary: "Save a search, optionally alerting you to new articles that match it",
+			Request: SavedSearchRequest{}, Response: model.SavedSearch{}},
+		{Method: "PUT", Path: "/account/searches/{id}", Handler: app.updateSavedSearch, Summary: "Replace a saved search",
+			Request: SavedSearchRequest{}, Response: model.SavedSearch{}},
+		{Method: "DELETE", Path: "/account/searches/{id}", Handler: app.deleteSavedSearch, Summary: "Delete a saved search"},
+		{Method: "GET", Path: "/account/searches/{id}/results", Handler: app.getSavedSearchResults, Summary: "Run a saved search",
+			Query:    []QueryParam{{"limit", "integer", "how many results (default 10, max 100)"}},
+			Response: []SearchResult{}},
+		{Method: "GET", Path: "/digest/unsubscribe", Handler: app.getDigestUnsubscribe, Summary: "The page of a digest's unsubscribe link",
+			Query: []QueryParam{
+				{"user", "string", "the user the link is for"},
+				{"expires", "integer", "expiry of the link, in Unix seconds"},
+				{"signature", "string", "signature of the link"},
+			},
+			ContentType: "text/html"},
+		{Method: "POST", Path: "/digest/unsubscribe", Handler: app.postDigestUnsubscribe, Summary: "Stop the digest of the user of a signed link",
+			Query: []QueryParam{
+				{"user", "string", "the user the link is for"},
+				{"expires", "integer", "expiry of the link, in Unix seconds"},
+				{"signature", "string", "signature of the link"},
+			},
+			linkSigned: true},
+		{Method: "GET", Path: "/push/key", Handler: app.getPushKey, Summary: "The public key to subscribe browsers to Web Push with",
+			Response: PushKey{}, enabled: pushEnabled},
+		{Method: "POST", Path: "/push/subscribe", Handler: app.subscribePush, Summary: "Push your notifications to a browser's Web Push subscription",
+			Request: webpush.Subscription{}, Response: model.PushSubscription{}, enabled: pushEnabled},
+		{Method: "POST", Path: "/push/unsubscribe", Handler: app.unsubscribePush, Summary: "Stop pushing to a browser",
+			Request: PushUnsubscribeRequest{}, enabled: pushEnabled},
+		{Method: "GET", Path: "/users/{username}/activity", Handler: app.getUserActivity, Summary: "List what a user created, changed and deleted, newest first",
+			Query: []QueryParam{
+				{"page_size", "integer", "entries per page, default 20, max 100"},
+				{"page_token", "string", "next_page_token of the previous page"},
+			},
+			Response: UserActivity{}},
+		{Method: "GET", Path: "/openapi.json", Handler: app.getOpenAPISpec, Summary: "OpenAPI 3 description of this API",
+			ContentType: "application/json"},
+		{Method: "GET", Path: "/docs", Handler: serveDocs, Summary: "Interactive API explorer", Hidden: true},
+		{Method: "GET", Path: "/docs/{asset}", Handler: serveDocs, Summary: "API explorer assets", Hidden: true,
+			CacheControl: "public, max-age=3600"},
+		{Method: "GET", Path: "/blog", Handler: app.getBlogIndex, Summary: "HTML page of the latest published articles",
+			Query:       []QueryParam{{"page", "integer", "page number, 1 is newest"}},
+			ContentType: "text/html", Cached: true, CacheControl: "public, max-age=300", enabled: blogEnabled},
+		{Method: "GET", Path: "/blog/assets/{asset}", Handler: app.serveBlogAsset, Summary: "Blog page assets", Hidden: true,
+			CacheControl: "public, max-age=3600", enabled: blogEnabled},
+		{Method: "GET", Path: "/blog/{slug}", Handler: app.getBlogArticle, Summary: "HTML page of a published article",
+			ContentType: "text/html", Cached: true, CacheControl: "public, max-age=300", enabled: blogEnabled},
+		{Method: "GET", Path: "/admin", Handler: serveDashboard, Summary: "Admin dashboard", Hidden: true},
+		{Method: "GET", Path: "/admin-ui/{asset}", Handler: serveDashboard, Summary: "Admin dashboard assets", Hidden: true,
+			CacheControl: "public, max-age=3600"},
+	}
+}
+
+// The routes the configuration turns on, then those of plugins
+func (app *App) activeRoutes(cfg config.Config) []Route {
+	routes := slices.DeleteFunc(slices.Clone(app.apiRoutes()), func(r Route) bool { return r.enabled != nil && !r.enabled(cfg) })
+	return slices.Concat(routes, app.pluginRoutes())
+}
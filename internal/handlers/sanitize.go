@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"strings"
+
+	"go-spring/internal/config"
+	"go-spring/internal/markdown"
+)
+
+// Apply the configured sanitizer to an article's text fields. Title and
+// description are always plain text; content follows SANITIZE_MODE.
+func (app *App) sanitizeArticle(title, desc, content *string) {
+	*title = strings.TrimSpace(markdown.Sanitize(*title, config.SanitizePlain))
+	*desc = strings.TrimSpace(markdown.Sanitize(*desc, config.SanitizePlain))
+	*content = markdown.SanitizeMarkdown(*content, app.cfg.SanitizeMode)
+}
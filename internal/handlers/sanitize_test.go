@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+
+	"go-spring/internal/config"
+	"go-spring/internal/model"
+)
+
+func TestSanitizeArticle(t *testing.T) {
+	title, desc, content := " <b>Hi</b> <script>x</script>", "<i>d</i>", "<p onclick=x>c</p>"
+	newApp(config.Config{SanitizeMode: config.SanitizeBasic}).sanitizeArticle(&title, &desc, &content)
+	if title != "Hi" || desc != "d" || content != "<p>c</p>" {
+		t.Errorf("article %q %q %q", title, desc, content)
+	}
+}
+
+// The HTML that SANITIZE_MODE keeps in content reaches the rendered article
+func TestSanitizeModeRendered(t *testing.T) {
+	content := `A <span class=\"note\">note</span> in <b>bold</b> <script>alert(1)</script>`
+	got := map[string]string{}
+	for _, mode := range []string{config.SanitizePlain, config.SanitizeBasic, config.SanitizeFull} {
+		srv := newTestServer(t, 0)
+		srv.cfg.SanitizeMode = mode
+		var created model.Article
+		call(t, "POST", srv.URL+"/articles", `{"title":"t","desc":"d","content":"`+content+`"}`, &created)
+		var rendered RenderedArticle
+		call(t, "GET", fmt.Sprintf("%s/articles/%d?format=html", srv.URL, created.ID), "", &rendered)
+		got[mode] = rendered.ContentHTML
+	}
+	for mode, want := range map[string]string{
+		config.SanitizePlain: "<p>A note in bold</p>\n",
+		config.SanitizeBasic: "<p>A note in <b>bold</b></p>\n",
+		config.SanitizeFull:  `<p>A <span class="note">note</span> in <b>bold</b></p>` + "\n",
+	} {
+		if got[mode] != want {
+			t.Errorf("%s: got %q, want %q", mode, got[mode], want)
+		}
+	}
+}
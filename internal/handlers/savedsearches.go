@@ -0,0 +1,443 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"go-spring/internal/config"
+	"go-spring/internal/ids"
+	"go-spring/internal/jobs"
+	"go-spring/internal/model"
+	"go-spring/internal/notify"
+	"go-spring/internal/validate"
+	"go-spring/internal/webhooks"
+)
+
+// Saved searches are named keyword searches of the signed-in user, under
+// /account/searches. One with email on, or a webhook_url, alerts its owner
+// to the articles published after it was saved that match it: the change
+// feed marks the articles created and updated, and every
+// SAVED_SEARCH_INTERVAL the scheduler runs each alerting search over those
+// that are published and that the owner may read, queueing one email and
+// one signed webhook post per search with all of its new matches. An
+// article is alerted about once per search, however often it changes.
+// Replicas send nothing, or every alert would arrive once per node.
+
+// Most saved searches a user may have, and how many of the articles it
+// alerted about a search remembers
+const (
+	maxSavedSearches = 50
+	maxAlerted       = 200
+)
+
+// Kind of the job that posts a saved search's new matches to its webhook
+const jobSearchAlert = "search-alert"
+
+type savedsearchesState struct {
+	// Client for posting to the webhooks of saved searches, which users name,
+	// so they may not reach internal hosts
+	searchAlertClient *http.Client
+
+	// The articles created or updated since the saved searches last looked
+	// for new matches
+	changedForAlerts struct {
+		mutex sync.Mutex
+		ids   map[int]bool
+	}
+}
+
+// SavedSearchRequest creates a saved search, or replaces one. A webhook
+// keeps its secret when an update leaves the URL as it was and gives no
+// new one.
+type SavedSearchRequest struct {
+	Name          string `json:"name" validate:"required,max=100"`
+	Query         string `json:"query" validate:"required,max=200"`
+	Email         bool   `json:"email"`
+	WebhookURL    string `json:"webhook_url,omitempty" validate:"url"`
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+}
+
+// Check the tags, that the query has words to search for and that a
+// webhook has a secret to sign its posts with
+func (req SavedSearchRequest) validate(user model.User, previous model.SavedSearch) error {
+	errs := validate.Struct(req)
+	if len(searchWords(req.Query)) == 0 && strings.TrimSpace(req.Query) != "" {
+		errs = append(errs, validate.FieldError{Field: "query", Rule: "required", Message: "query has no words to search for"})
+	}
+	if req.Email && user.Email == "" {
+		errs = append(errs, validate.FieldError{Field: "email", Rule: "required", Message: "email alerts need an email address, see /account/notifications"})
+	}
+	if req.WebhookURL != "" && len(webhookKey(req, previous)) < webhooks.MinSecretLength {
+		errs = append(errs, validate.FieldError{Field: "webhook_secret", Rule: "min",
+			Message: fmt.Sprintf("webhook_secret must be at least %d characters", webhooks.MinSecretLength)})
+	}
+	if errs != nil {
+		return &ValidationError{Message: errs.Error(), Fields: errs}
+	}
+	return nil
+}
+
+// The secret of the webhook of req, which replaces previous
+func webhookKey(req SavedSearchRequest, previous model.SavedSearch) string {
+	if req.WebhookSecret == "" && req.WebhookURL == previous.WebhookURL {
+		return previous.WebhookKey
+	}
+	return req.WebhookSecret
+}
+
+// Whether a saved search alerts its owner
+func alerting(s model.SavedSearch) bool {
+	return s.Email || s.WebhookURL != ""
+}
+
+// Note an article changed for the next look for new matches
+func (app *App) noteChangedForAlerts(event ArticleEvent) {
+	if event.Type == EventArticleDeleted || app.cfg.SavedSearchInterval <= 0 {
+		return
+	}
+	app.changedForAlerts.mutex.Lock()
+	if app.changedForAlerts.ids != nil {
+		app.changedForAlerts.ids[event.Article.ID] = true
+	}
+	app.changedForAlerts.mutex.Unlock()
+}
+
+// Register the webhook job and start over the changed articles
+func (app *App) initSavedSearches(cfg config.Config) {
+	if app.jobQueue != nil {
+		app.jobQueue.Handle(jobSearchAlert, app.runSearchAlertJob)
+	}
+	app.changedForAlerts.mutex.Lock()
+	app.changedForAlerts.ids = map[int]bool{}
+	app.changedForAlerts.mutex.Unlock()
+}
+
+// The user's saved search with the route's ID, answering 400 or 404 if
+// there is none
+func (app *App) routeSavedSearch(w http.ResponseWriter, r *http.Request, user model.User) (model.SavedSearch, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid saved search ID")
+		return model.SavedSearch{}, false
+	}
+	i := slices.IndexFunc(user.SavedSearches, func(s model.SavedSearch) bool { return s.ID == id })
+	if i < 0 {
+		app.writeError(w, r, http.StatusNotFound, CodeSavedSearchNotFound, "Saved search not found")
+		return model.SavedSearch{}, false
+	}
+	return user.SavedSearches[i], true
+}
+
+// Change the saved searches of a user with change, which gets a copy of
+// them; an error from it is returned as it is
+func (app *App) changeSavedSearches(username string, change func([]model.SavedSearch) ([]model.SavedSearch, error)) error {
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+	i := slices.IndexFunc(app.users, func(u model.User) bool { return strings.EqualFold(u.Username, username) })
+	if i < 0 {
+		return fmt.Errorf("user %q not found", username)
+	}
+	// A copy, as copies from Users may still be reading the old ones
+	searches, err := change(slices.Clone(app.users[i].SavedSearches))
+	if err != nil {
+		return err
+	}
+	app.users[i].SavedSearches = searches
+	app.scheduleSave()
+	return nil
+}
+
+var errTooManySearches = fmt.Errorf("a user may have at most %d saved searches", maxSavedSearches)
+
+// GET /account/searches - List the saved searches of the signed-in user
+func (app *App) listSavedSearches(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Saved searches retrieved successfully", Data: append([]model.SavedSearch{}, user.SavedSearches...)})
+}
+
+// POST /account/searches - Save a search, with alerts if asked for
+func (app *App) createSavedSearch(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	var req SavedSearchRequest
+	if err := decodeBody(r, &req); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	var invalid *ValidationError
+	if err := req.validate(user, model.SavedSearch{}); errors.As(err, &invalid) {
+		app.writeValidationError(w, r, invalid)
+		return
+	}
+	now := time.Now()
+	search := model.SavedSearch{Name: strings.TrimSpace(req.Name), Query: strings.TrimSpace(req.Query), Email: req.Email,
+		WebhookURL: req.WebhookURL, WebhookKey: req.WebhookSecret, Created: now, Checked: now}
+	err := app.changeSavedSearches(user.Username, func(searches []model.SavedSearch) ([]model.SavedSearch, error) {
+		if len(searches) >= maxSavedSearches {
+			return nil, errTooManySearches
+		}
+		search.ID = 1
+		for _, s := range searches {
+			search.ID = max(search.ID, s.ID+1)
+		}
+		return append(searches, search), nil
+	})
+	switch {
+	case errors.Is(err, errTooManySearches):
+		app.writeError(w, r, http.StatusConflict, CodeConflict, "Too many saved searches")
+		return
+	case err != nil:
+		app.writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/account/searches/%d", search.ID))
+	app.writeResponse(w, r, http.StatusCreated, Response{Message: "Search saved successfully", Data: search})
+}
+
+// PUT /account/searches/{id} - Replace a saved search. Articles published
+// before it was first saved still don't alert.
+func (app *App) updateSavedSearch(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	previous, ok := app.routeSavedSearch(w, r, user)
+	if !ok {
+		return
+	}
+	var req SavedSearchRequest
+	if err := decodeBody(r, &req); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	var invalid *ValidationError
+	if err := req.validate(user, previous); errors.As(err, &invalid) {
+		app.writeValidationError(w, r, invalid)
+		return
+	}
+	search := previous
+	search.Name, search.Query, search.Email = strings.TrimSpace(req.Name), strings.TrimSpace(req.Query), req.Email
+	search.WebhookURL, search.WebhookKey = req.WebhookURL, webhookKey(req, previous)
+	if search.WebhookURL == "" {
+		search.WebhookKey = ""
+	}
+	err := app.changeSavedSearches(user.Username, func(searches []model.SavedSearch) ([]model.SavedSearch, error) {
+		i := slices.IndexFunc(searches, func(s model.SavedSearch) bool { return s.ID == search.ID })
+		if i < 0 {
+			return nil, errors.New("saved search not found")
+		}
+		searches[i] = search
+		return searches, nil
+	})
+	if err != nil {
+		app.writeError(w, r, http.StatusNotFound, CodeSavedSearchNotFound, "Saved search not found")
+		return
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Saved search updated successfully", Data: search})
+}
+
+// DELETE /account/searches/{id} - Delete a saved search and its alerts
+func (app *App) deleteSavedSearch(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	search, ok := app.routeSavedSearch(w, r, user)
+	if !ok {
+		return
+	}
+	err := app.changeSavedSearches(user.Username, func(searches []model.SavedSearch) ([]model.SavedSearch, error) {
+		return slices.DeleteFunc(searches, func(s model.SavedSearch) bool { return s.ID == search.ID }), nil
+	})
+	if err != nil {
+		app.writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /account/searches/{id}/results - Run a saved search
+func (app *App) getSavedSearchResults(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	search, ok := app.routeSavedSearch(w, r, user)
+	if !ok {
+		return
+	}
+	limit := defaultSearchResults
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSearchResults {
+			app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, fmt.Sprintf("limit must be between 1 and %d", maxSearchResults))
+			return
+		}
+		limit = n
+	}
+	mayRead := app.confidentialReader(user, true)
+	list := slices.DeleteFunc(slices.Clone(app.readArticles()), func(a model.Article) bool { return a.Confidential && !mayRead(a) })
+	results := keywordResults(list, search.Query)
+	slices.SortStableFunc(results, func(a, b SearchResult) int { return cmp.Compare(b.Score, a.Score) })
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Search results retrieved successfully", Data: results[:min(len(results), limit)]})
+}
+
+// Look for new matches every SAVED_SEARCH_INTERVAL until ctx is done,
+// first counting the articles changed since the searches last looked as
+// changed, in case the server was down meanwhile
+func (app *App) runSearchAlerts(ctx context.Context) {
+	var since time.Time
+	for _, user := range app.Users() {
+		for _, s := range user.SavedSearches {
+			if alerting(s) && (since.IsZero() || s.Checked.Before(since)) {
+				since = s.Checked
+			}
+		}
+	}
+	if !since.IsZero() {
+		list := app.readArticles() // before locking, as writers lock the other way round
+		app.changedForAlerts.mutex.Lock()
+		for _, article := range list {
+			if article.Updated.After(since) || article.Created.After(since) {
+				app.changedForAlerts.ids[article.ID] = true
+			}
+		}
+		app.changedForAlerts.mutex.Unlock()
+	}
+
+	ticker := time.NewTicker(app.cfg.SavedSearchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.checkSavedSearches(time.Now())
+		}
+	}
+}
+
+// A saved search's new matches, to alert its owner to
+type searchAlert struct {
+	user     model.User
+	search   model.SavedSearch
+	articles []model.Article
+}
+
+// Run the alerting saved searches over the articles changed since the
+// last check, and queue the alerts of those with new matches
+func (app *App) checkSavedSearches(now time.Time) {
+	app.changedForAlerts.mutex.Lock()
+	changed := app.changedForAlerts.ids
+	app.changedForAlerts.ids = map[int]bool{}
+	app.changedForAlerts.mutex.Unlock()
+	if len(changed) == 0 || app.replicating() {
+		return
+	}
+	var published []model.Article
+	for id := range changed {
+		if article, ok := app.readArticle(id); ok && article.IsPublished() {
+			published = append(published, article)
+		}
+	}
+	slices.SortFunc(published, func(a, b model.Article) int { return cmp.Compare(a.ID, b.ID) })
+
+	var alerts []searchAlert
+	for _, user := range app.Users() {
+		mayRead := app.confidentialReader(user, true)
+		for _, s := range user.SavedSearches {
+			if !alerting(s) {
+				continue
+			}
+			candidates := slices.DeleteFunc(slices.Clone(published), func(a model.Article) bool {
+				return !publishedAt(a).After(s.Created) || slices.Contains(s.Alerted, a.ID) || a.Confidential && !mayRead(a)
+			})
+			if results := keywordResults(candidates, s.Query); len(results) > 0 {
+				alert := searchAlert{user: user, search: s}
+				for _, result := range results {
+					alert.articles = append(alert.articles, withoutContent(result.Article))
+				}
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+
+	// Remember what is alerted about before queueing, so nothing is sent twice
+	app.articlesMutex.Lock()
+	for i := range app.users {
+		searches := slices.Clone(app.users[i].SavedSearches)
+		for j, s := range searches {
+			if !alerting(s) {
+				continue
+			}
+			searches[j].Checked = now
+			for _, alert := range alerts {
+				if alert.user.Username == app.users[i].Username && alert.search.ID == s.ID {
+					alerted := slices.Clone(s.Alerted)
+					for _, article := range alert.articles {
+						alerted = append(alerted, article.ID)
+					}
+					searches[j].Alerted = alerted[max(0, len(alerted)-maxAlerted):]
+				}
+			}
+		}
+		app.users[i].SavedSearches = searches
+	}
+	app.articlesMutex.Unlock()
+	app.scheduleSave()
+
+	for _, alert := range alerts {
+		app.queueSearchAlert(alert, now)
+	}
+}
+
+// Payload of a search alert job. It names the search rather than carrying
+// its webhook's secret, which stays out of the job file.
+type searchAlertJob struct {
+	Username string            `json:"username"`
+	SearchID int               `json:"search_id"`
+	URL      string            `json:"url"`
+	Delivery webhooks.Delivery `json:"delivery"`
+}
+
+// Queue the email and the webhook post of an alert
+func (app *App) queueSearchAlert(alert searchAlert, now time.Time) {
+	s := alert.search
+	if s.Email && app.mailer != nil && app.mailTemplates != nil {
+		n := notify.Notification{Event: notify.EventSearchMatched, Articles: alert.articles, Detail: s.Name, Time: now}
+		if msg, err := app.mailTemplates.Render(n, alert.user); err != nil {
+			log.Printf("Warning: Failed to render %s email: %v", n.Event, err)
+		} else if msg.To != "" {
+			app.enqueueJob(jobEmail, msg)
+		}
+	}
+	if s.WebhookURL != "" {
+		data, err := json.Marshal(map[string]any{"search": s, "articles": alert.articles})
+		if err != nil {
+			log.Printf("Warning: search alert of %s: %v", alert.user.Username, err)
+			return
+		}
+		delivery := webhooks.Delivery{ID: ids.NewULID(now), Event: notify.EventSearchMatched, Time: now.UTC(), Data: data}
+		app.enqueueJob(jobSearchAlert, searchAlertJob{Username: alert.user.Username, SearchID: s.ID, URL: s.WebhookURL, Delivery: delivery})
+	}
+}
+
+// Post one alert to the webhook of a saved search
+func (app *App) runSearchAlertJob(ctx context.Context, job jobs.Job) error {
+	var payload searchAlertJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+	user, found := app.findUser(payload.Username)
+	if !found {
+		return nil // erased since the job was queued
+	}
+	i := slices.IndexFunc(user.SavedSearches, func(s model.SavedSearch) bool { return s.ID == payload.SearchID })
+	if i < 0 || user.SavedSearches[i].WebhookURL != payload.URL {
+		return nil // deleted, or the webhook changed, since the job was queued
+	}
+	subscription := webhooks.Subscription{URL: payload.URL, Secret: user.SavedSearches[i].WebhookKey}
+	return subscription.Deliver(ctx, app.searchAlertClient, payload.Delivery)
+}
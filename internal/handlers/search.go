@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"go-spring/internal/config"
+	"go-spring/internal/embeddings"
+	"go-spring/internal/model"
+	"go-spring/internal/spell"
+)
+
+// GET /articles/search finds articles by their words, or with
+// mode=semantic by what they are about. Keyword search ranks the articles
+// that have every word of the query, a title or tag match counting more
+// than one in the description or content. Semantic search compares the
+// embedding of the query with those of the articles, which a background
+// worker makes with the first plugin's Embedder or the endpoint of
+// EMBEDDINGS_URL as articles are created and changed. Confidential articles
+// are only searched for readers with access, and never get an embedding,
+// which would say what their content is about outside the encryption.
+//
+// A keyword search with few results suggests a query with its misspelt
+// words corrected against the words of the articles, and with none,
+// returns the results of that query instead.
+
+// Search modes
+const (
+	SearchKeyword  = "keyword"
+	SearchSemantic = "semantic"
+)
+
+// Most results, and the default of ?limit
+const (
+	maxSearchResults     = 100
+	defaultSearchResults = 10
+)
+
+// SearchResult is an article of GET /articles/search and how well it
+// matches: the weighted count of the query's words, or the cosine
+// similarity of the embeddings
+type SearchResult struct {
+	Article model.Article `json:"article"`
+	Score   float64       `json:"score"`
+}
+
+type searchState struct {
+	// The embedder of EMBEDDINGS_URL; nil without one. Set by New.
+	configEmbedder embeddings.Embedder
+
+	// The words of the articles but the confidential ones, and the snapshot
+	// they are of
+	vocabulary struct {
+		mutex    sync.Mutex
+		snapshot *[]model.Article
+		dict     *spell.Dictionary
+	}
+}
+
+func (app *App) initEmbeddings(cfg config.Config) {
+	app.configEmbedder = nil
+	if cfg.EmbeddingsURL != "" {
+		app.configEmbedder = embeddings.API{Endpoint: cfg.EmbeddingsURL, Model: cfg.EmbeddingsModel, APIKey: cfg.EmbeddingsAPIKey}
+	}
+}
+
+// The embedder to use and the name of its model, which embeddings are
+// kept under; nil if there is none
+func (app *App) activeEmbedder() (embeddings.Embedder, string) {
+	for _, p := range app.registeredPlugins() {
+		if p.Embedder != nil {
+			return p.Embedder, "plugin:" + p.Name
+		}
+	}
+	return app.configEmbedder, app.cfg.EmbeddingsModel
+}
+
+// GET /articles/search?q=...&mode=semantic - Articles that match a query
+func (app *App) searchArticles(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "q is required")
+		return
+	}
+	limit := defaultSearchResults
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSearchResults {
+			app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, fmt.Sprintf("limit must be between 1 and %d", maxSearchResults))
+			return
+		}
+		limit = n
+	}
+
+	// Confidential articles the request may not read aren't searched, so
+	// their content can't be guessed a word at a time, nor are held ones
+	mayRead := app.requestReader(r)
+	list := slices.DeleteFunc(app.withoutHeld(r, slices.Clone(app.readArticles())), func(a model.Article) bool { return a.Confidential && !mayRead(a) })
+
+	var results []SearchResult
+	var didYouMean string
+	mode := cmp.Or(query.Get("mode"), SearchKeyword)
+	switch mode {
+	case SearchKeyword:
+		results = keywordResults(list, q)
+		if len(results) < fewSearchResults {
+			if corrected := app.correctedQuery(q); corrected != "" {
+				if more := keywordResults(list, corrected); len(more) > len(results) {
+					didYouMean = corrected
+					if len(results) == 0 {
+						results = more
+					}
+				}
+			}
+		}
+	case SearchSemantic:
+		embedder, name := app.activeEmbedder()
+		if embedder == nil {
+			app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "Semantic search is not enabled")
+			return
+		}
+		var err error
+		if results, err = semanticResults(r.Context(), embedder, name, list, q); err != nil {
+			log.Printf("Error: %v", err)
+			app.writeError(w, r, http.StatusBadGateway, CodeUpstreamFailed, "Embedding provider failed")
+			return
+		}
+	default:
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "mode must be keyword or semantic")
+		return
+	}
+	slices.SortStableFunc(results, func(a, b SearchResult) int { return cmp.Compare(b.Score, a.Score) })
+	app.logSearch(q, mode, len(results), start)
+	results = results[:min(len(results), limit)]
+	for i := range results {
+		results[i].Article = app.withheldArticle(r, results[i].Article)
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Search results retrieved successfully", Data: results, DidYouMean: didYouMean})
+}
+
+// The lower-case words of text
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(c rune) bool { return !unicode.IsLetter(c) && !unicode.IsDigit(c) })
+}
+
+// The articles with every word of q, or a word beginning with it, scored
+// by the matches: 3 in the title or tags, 2 in the description, 1 in the
+// content
+func keywordResults(list []model.Article, q string) []SearchResult {
+	terms := slices.Compact(slices.Sorted(slices.Values(searchWords(q))))
+	if len(terms) == 0 {
+		return nil
+	}
+	results := []SearchResult{}
+	for _, article := range list {
+		fields := []struct {
+			words  []string
+			weight float64
+		}{
+			{searchWords(article.Title + " " + strings.Join(article.Tags, " ")), 3},
+			{searchWords(article.Desc), 2},
+			{searchWords(article.Content), 1},
+		}
+		score := 0.0
+		for _, term := range terms {
+			matches := 0.0
+			for _, field := range fields {
+				for _, word := range field.words {
+					if strings.HasPrefix(word, term) {
+						matches += field.weight
+					}
+				}
+			}
+			if matches == 0 {
+				score = 0
+				break
+			}
+			score += matches
+		}
+		if score > 0 {
+			results = append(results, SearchResult{Article: article, Score: score})
+		}
+	}
+	return results
+}
+
+// Fewer keyword results than this look for a correction of the query
+const fewSearchResults = 3
+
+// The vocabulary of the articles as they are now, indexed again after
+// changes when it is next needed
+func (app *App) searchVocabulary() *spell.Dictionary {
+	snapshot := app.loadSnapshot()
+	app.vocabulary.mutex.Lock()
+	defer app.vocabulary.mutex.Unlock()
+	if app.vocabulary.snapshot != snapshot {
+		counts := map[string]int{}
+		for _, article := range *snapshot {
+			if article.Confidential {
+				continue
+			}
+			for _, word := range searchWords(article.Title + " " + strings.Join(article.Tags, " ") + " " + article.Desc + " " + article.Content) {
+				if correctable(word) {
+					counts[word]++
+				}
+			}
+		}
+		app.vocabulary.snapshot, app.vocabulary.dict = snapshot, spell.New(counts)
+	}
+	return app.vocabulary.dict
+}
+
+// Words of 3 to 30 letters, not numbers or codes
+func correctable(word string) bool {
+	n := len([]rune(word))
+	return n >= 3 && n <= 30 && !strings.ContainsFunc(word, unicode.IsDigit)
+}
+
+// q with the words no article has corrected, "" if none could be
+func (app *App) correctedQuery(q string) string {
+	dict := app.searchVocabulary()
+	words, changed := searchWords(q), false
+	for i, word := range words {
+		if !correctable(word) || dict.HasPrefix(word) {
+			continue // a prefix search finds it as it is
+		}
+		if correction, ok := dict.Correct(word); ok {
+			words[i], changed = correction, true
+		}
+	}
+	if !changed {
+		return ""
+	}
+	return strings.Join(words, " ")
+}
+
+// How long the embedding of a query may take
+const queryEmbeddingTimeout = 30 * time.Second
+
+// The articles with embeddings from the model, scored by their similarity
+// to the query's
+func semanticResults(ctx context.Context, embedder embeddings.Embedder, name string, list []model.Article, q string) ([]SearchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryEmbeddingTimeout)
+	defer cancel()
+	vectors, err := embedder.Embed(ctx, []string{q})
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("embed query: %d vectors for 1 text", len(vectors))
+	}
+	results := []SearchResult{}
+	for _, article := range list {
+		if article.Embedding == nil || article.Embedding.Model != name || article.Confidential {
+			continue
+		}
+		results = append(results, SearchResult{Article: article, Score: embeddings.Cosine(vectors[0], article.Embedding.Vector)})
+	}
+	return results, nil
+}
+
+// The text of an article that its embedding is made from
+func embeddingText(article model.Article) string {
+	return article.Title + "\n\n" + article.Desc + "\n\n" + article.Content
+}
+
+func embeddingSource(article model.Article) string {
+	sum := sha256.Sum256([]byte(embeddingText(article)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Whether an article needs a new embedding from the model, or to lose the
+// one it has
+func embeddingStale(article model.Article, name string) bool {
+	if article.Confidential {
+		return article.Embedding != nil
+	}
+	return article.Embedding == nil || article.Embedding.Model != name || article.Embedding.Source != embeddingSource(article)
+}
+
+// Texts sent to the embedder at once
+const embeddingBatch = 16
+
+// Make the embeddings of the articles that lack an up-to-date one, a batch
+// at a time, and drop those of confidential articles
+func (app *App) embedArticles(ctx context.Context) error {
+	embedder, name := app.activeEmbedder()
+	if embedder == nil {
+		return nil
+	}
+	for {
+		var batch []model.Article
+		for _, article := range app.readArticles() {
+			if embeddingStale(article, name) {
+				batch = append(batch, article)
+				if len(batch) == embeddingBatch {
+					break
+				}
+			}
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		texts, sources := []string{}, map[int]string{}
+		for _, article := range batch {
+			if !article.Confidential {
+				texts = append(texts, embeddingText(article))
+				sources[article.ID] = embeddingSource(article)
+			}
+		}
+		var vectors [][]float32
+		if len(texts) > 0 {
+			var err error
+			if vectors, err = embedder.Embed(ctx, texts); err != nil {
+				return err
+			}
+			if len(vectors) != len(texts) {
+				return fmt.Errorf("%d vectors for %d texts", len(vectors), len(texts))
+			}
+		}
+
+		// Articles that changed meanwhile wait for the next batch
+		app.articlesMutex.Lock()
+		for _, article := range batch {
+			i := app.findArticleIndex(article.ID)
+			switch {
+			case i < 0:
+			case app.articles[i].Confidential:
+				app.articles[i].Embedding = nil
+			case embeddingSource(app.articles[i]) == sources[article.ID]:
+				app.articles[i].Embedding = &model.Embedding{Model: name, Source: sources[article.ID], Vector: vectors[0]}
+			}
+			if !article.Confidential {
+				vectors = vectors[1:]
+			}
+		}
+		app.articlesMutex.Unlock()
+		app.scheduleSave()
+	}
+}
+
+// Keep the embeddings up to date with the articles until ctx is done:
+// after changes, once they have settled for a second, and every minute
+// to retry after a failure
+func (app *App) runEmbedder(ctx context.Context) {
+	changes, unsubscribe := app.events.Subscribe(64)
+	defer unsubscribe()
+	retry := time.NewTicker(time.Minute)
+	defer retry.Stop()
+	for {
+		if err := app.embedArticles(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Warning: embeddings: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-retry.C:
+		case <-changes:
+			settle := time.NewTimer(time.Second)
+		drain:
+			for {
+				select {
+				case <-changes:
+				case <-settle.C:
+					break drain
+				case <-ctx.Done():
+					settle.Stop()
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-spring/internal/config"
+	"go-spring/internal/searchlog"
+)
+
+// Every search that answers is logged with its query, how many results it
+// found and how long it took, but not who made it. GET
+// /admin/search-analytics adds them up by query: the most searched ones,
+// and those whose latest search found nothing, which is the content readers
+// look for and don't find. A keyword search that found nothing as typed but
+// something with its words corrected counts what it found.
+
+type searchlogState struct {
+	// The searches made, opened by New; nil for commands that run without a
+	// server
+	searchLog *searchlog.Log
+}
+
+// Most queries of each list, and the default of ?limit
+const (
+	maxSearchAnalytics     = 100
+	defaultSearchAnalytics = 20
+)
+
+// SearchAnalytics is the answer of GET /admin/search-analytics
+type SearchAnalytics struct {
+	From                 time.Time    `json:"from"`
+	To                   time.Time    `json:"to"`
+	Searches             int          `json:"searches"`
+	ZeroResultSearches   int          `json:"zero_result_searches"`
+	AvgLatencyMs         float64      `json:"avg_latency_ms"`
+	TopQueries           []QueryStats `json:"top_queries"`
+	TopZeroResultQueries []QueryStats `json:"top_zero_result_queries"`
+}
+
+// QueryStats is the searches of one query
+type QueryStats struct {
+	Query              string    `json:"query"`
+	Searches           int       `json:"searches"`
+	ZeroResultSearches int       `json:"zero_result_searches"`
+	Hits               int       `json:"hits"` // of the latest search
+	AvgLatencyMs       float64   `json:"avg_latency_ms"`
+	LastSearched       time.Time `json:"last_searched"`
+}
+
+// Open the searches in SEARCH_LOG_FILE
+func (app *App) initSearchLog(cfg config.Config) error {
+	path := cfg.SearchLogFile
+	if path == "off" {
+		path = ""
+	}
+	log, err := searchlog.Open(path)
+	if err != nil {
+		return err
+	}
+	app.searchLog = log
+	return nil
+}
+
+// Write the searches every analyticsSaveInterval, and once more when ctx is
+// done
+func (app *App) runSearchLogSaver(ctx context.Context) {
+	runPeriodicSave(ctx, "search log", func(time.Time) error { return app.searchLog.Save() })
+}
+
+// Log a search of q that found hits results, started at start
+func (app *App) logSearch(q, mode string, hits int, start time.Time) {
+	if app.searchLog == nil {
+		return
+	}
+	now := time.Now()
+	app.searchLog.Add(searchlog.Entry{Time: now, Query: q, Mode: mode, Hits: hits, Latency: now.Sub(start)})
+}
+
+// GET /admin/search-analytics - The most searched queries, and those that
+// find nothing
+func (app *App) getSearchAnalytics(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	to, err := analyticsTime(query.Get("to"), time.Now())
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid to: "+err.Error())
+		return
+	}
+	from, err := analyticsTime(query.Get("from"), to.Add(-30*24*time.Hour))
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid from: "+err.Error())
+		return
+	}
+	if from.After(to) {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "from must not be after to")
+		return
+	}
+	limit := defaultSearchAnalytics
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSearchAnalytics {
+			app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, fmt.Sprintf("limit must be between 1 and %d", maxSearchAnalytics))
+			return
+		}
+		limit = n
+	}
+
+	result := SearchAnalytics{From: from.UTC(), To: to.UTC(), TopQueries: []QueryStats{}, TopZeroResultQueries: []QueryStats{}}
+	if app.searchLog != nil {
+		summary := app.searchLog.Top(from, to, limit)
+		result.Searches, result.ZeroResultSearches, result.AvgLatencyMs = summary.Searches, summary.ZeroResults, milliseconds(summary.Latency)
+		result.TopQueries, result.TopZeroResultQueries = queryStats(summary.Top), queryStats(summary.TopZero)
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Search analytics retrieved successfully", Data: result})
+}
+
+func queryStats(queries []searchlog.Query) []QueryStats {
+	stats := make([]QueryStats, len(queries))
+	for i, q := range queries {
+		stats[i] = QueryStats{Query: q.Query, Searches: q.Searches, ZeroResultSearches: q.ZeroResults, Hits: q.Hits,
+			AvgLatencyMs: milliseconds(q.Latency), LastSearched: q.Last.UTC()}
+	}
+	return stats
+}
+
+// d in milliseconds, to the microsecond
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"go-spring/internal/model"
+)
+
+// Word lists for generated articles
+var (
+	seedTopics = []string{
+		"Go", "Goroutines", "Channels", "Generics", "Error Handling", "Testing", "Benchmarks",
+		"REST APIs", "gRPC", "Docker", "Kubernetes", "PostgreSQL", "SQLite", "Redis", "Caching",
+		"Observability", "Structured Logging", "Microservices", "Message Queues", "WebAssembly",
+		"Profiling", "Memory Management", "Concurrency Patterns", "CLI Tools", "Code Review",
+		"Continuous Integration", "Feature Flags", "Rate Limiting", "Authentication", "TLS",
+	}
+	seedTitleTemplates = []string{
+		"Getting Started with %s",
+		"%d Tips for Better %s",
+		"A Practical Guide to %s",
+		"Why %s Matters",
+		"%s in Production: Lessons Learned",
+		"Understanding %s from First Principles",
+		"Common Mistakes with %s",
+		"%s Explained in %d Minutes",
+		"Scaling %s Without the Pain",
+		"What's New in %s",
+		"Debugging %s Like a Pro",
+		"%s: Myths and Reality",
+	}
+	seedCategories = []string{
+		"Tutorials", "Engineering", "Best Practices", "Performance", "Security",
+		"Operations", "Architecture", "News", "Tooling", "Case Studies",
+	}
+	seedOpenings = []string{
+		"Teams adopting %s often underestimate how much it shapes everyday work.",
+		"%s looks simple at first, but the details matter once traffic grows.",
+		"This article walks through %s with small, runnable examples.",
+		"We rebuilt a critical service around %s last year; here is what we learned.",
+		"Most problems with %s come from a handful of recurring patterns.",
+	}
+	seedSentences = []string{
+		"Start with the simplest design that could work and measure before optimizing.",
+		"Keep interfaces small so implementations stay easy to swap and test.",
+		"Document the trade-offs you made, not just the decisions.",
+		"Automate the boring parts early; manual steps are where incidents begin.",
+		"Prefer explicit configuration over clever defaults that surprise operators.",
+		"Benchmarks on a laptop rarely match production, so profile the real thing.",
+		"Small, frequent releases make rollbacks cheap and reviews faster.",
+		"Errors deserve as much design attention as the happy path.",
+		"Readable code beats clever code when someone is paged at 3 a.m.",
+		"Write the test that would have caught the last bug before fixing it.",
+		"Timeouts and retries need limits, or one slow dependency takes everything down.",
+		"Logs are most useful when every line answers who, what and why.",
+	}
+	seedHeadings = []string{"Background", "The Problem", "Our Approach", "Trade-offs", "Results", "Next Steps"}
+)
+
+// Generate n articles with realistic titles, Markdown content, categories
+// and dates spread over the past year. The same seed gives the same articles
+// (apart from dates, which are relative to now); 0 picks a random seed.
+func GenerateArticles(n int, seed uint64) []model.Article {
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	rng := rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+	pick := func(list []string) string { return list[rng.IntN(len(list))] }
+	now := time.Now()
+
+	generated := make([]model.Article, n)
+	for i := range generated {
+		topic := pick(seedTopics)
+		title := pick(seedTitleTemplates)
+		if strings.Contains(title, "%d") {
+			if strings.Index(title, "%d") < strings.Index(title, "%s") {
+				title = fmt.Sprintf(title, 3+rng.IntN(10), topic)
+			} else {
+				title = fmt.Sprintf(title, topic, 3+rng.IntN(10))
+			}
+		} else {
+			title = fmt.Sprintf(title, topic)
+		}
+
+		var content strings.Builder
+		for j, heading := range seedHeadings[:2+rng.IntN(len(seedHeadings)-1)] {
+			if j > 0 {
+				content.WriteString("\n\n")
+			}
+			content.WriteString("## " + heading + "\n\n")
+			for k, sentence := range rng.Perm(len(seedSentences))[:2+rng.IntN(3)] {
+				if k > 0 {
+					content.WriteString(" ")
+				}
+				content.WriteString(seedSentences[sentence])
+			}
+		}
+
+		// A third of the articles were edited after they were written
+		created := now.Add(-time.Duration(rng.Int64N(int64(365 * 24 * time.Hour))))
+		updated := created
+		if rng.IntN(3) == 0 {
+			updated = created.Add(time.Duration(rng.Int64N(int64(now.Sub(created)))))
+		}
+
+		article := model.Article{
+			Title:    title,
+			Desc:     fmt.Sprintf(pick(seedOpenings), topic),
+			Content:  content.String(),
+			Created:  created,
+			Updated:  updated,
+			Status:   model.StatusPublished,
+			Featured: rng.IntN(20) == 0,
+			Pinned:   rng.IntN(50) == 0,
+		}
+		if rng.IntN(10) == 0 {
+			article.Status = model.StatusDraft
+		} else {
+			article.Published = created
+		}
+		for range 1 + rng.IntN(3) {
+			if c := pick(seedCategories); !slices.Contains(article.Categories, c) {
+				article.Categories = append(article.Categories, c)
+			}
+		}
+		generated[i] = article
+	}
+	return generated
+}
+
+// Add generated articles to the store in one go, in creation order, without
+// the per-article events and saves of insertArticle; the caller saves.
+func (app *App) SeedStore(generated []model.Article) {
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+
+	slices.SortFunc(generated, func(a, b model.Article) int { return a.Created.Compare(b.Created) })
+	for _, article := range generated {
+		article.ID = app.articleIDs.Next()
+		app.addArticle(article)
+	}
+	app.assignMissingSlugs()
+	app.assignMissingUIDs()
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"slices"
+	"testing"
+
+	"go-spring/internal/model"
+)
+
+func TestGenerateArticles(t *testing.T) {
+	first, again := GenerateArticles(50, 42), GenerateArticles(50, 42)
+	if len(first) != 50 {
+		t.Fatalf("generated %d articles", len(first))
+	}
+	drafts := 0
+	for i, a := range first {
+		// The same seed gives the same articles, apart from the dates
+		if b := again[i]; a.Title != b.Title || a.Content != b.Content || !slices.Equal(a.Categories, b.Categories) {
+			t.Errorf("article %d differs: %q, %q", i, a.Title, b.Title)
+		}
+		if a.Title == "" || a.Desc == "" || a.Content == "" || len(a.Categories) == 0 {
+			t.Errorf("article %d is incomplete: %+v", i, a)
+		}
+		if a.Updated.Before(a.Created) {
+			t.Errorf("article %d updated before it was created", i)
+		}
+		switch a.Status {
+		case model.StatusDraft:
+			drafts++
+			if !a.Published.IsZero() {
+				t.Errorf("draft %d has a publication date", i)
+			}
+		case model.StatusPublished:
+			if !a.Published.Equal(a.Created) {
+				t.Errorf("article %d published %v, created %v", i, a.Published, a.Created)
+			}
+		default:
+			t.Errorf("article %d has status %q", i, a.Status)
+		}
+	}
+	if drafts == len(first) {
+		t.Error("every article is a draft")
+	}
+	if other := GenerateArticles(50, 43); other[0].Content == first[0].Content && other[1].Content == first[1].Content {
+		t.Error("another seed gives the same articles")
+	}
+}
+
+// Seeded articles get IDs and slugs in the order they were written,
+// after the articles already stored
+func TestSeedStore(t *testing.T) {
+	srv := newTestServer(t, 2)
+	srv.SeedStore(GenerateArticles(10, 7))
+	articles := srv.readArticles()
+	if len(articles) != 12 {
+		t.Fatalf("%d articles", len(articles))
+	}
+	for i, a := range articles[2:] {
+		if a.ID != i+3 || a.Slug == "" {
+			t.Errorf("article %d: ID %d, slug %q", i, a.ID, a.Slug)
+		}
+		if i > 0 && a.Created.Before(articles[i+1].Created) {
+			t.Errorf("article %d was written before the one seeded ahead of it", a.ID)
+		}
+	}
+
+	srv.ResetArticles()
+	srv.SeedStore(GenerateArticles(1, 7))
+	if articles := srv.readArticles(); len(articles) != 1 || articles[0].ID != 1 {
+		t.Errorf("after a reset: %+v", articles)
+	}
+}
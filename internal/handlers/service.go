@@ -0,0 +1,407 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"go-spring/internal/config"
+	"go-spring/internal/langdetect"
+	"go-spring/internal/model"
+	"go-spring/internal/validate"
+)
+
+// ArticleService holds the article operations shared by the REST and gRPC
+// transports, so both validate and store articles in exactly the same way.
+type ArticleService interface {
+	Get(ctx context.Context, id int) (model.Article, error)
+	List(ctx context.Context, req ListArticlesRequest) (ListArticlesResponse, error)
+	Create(ctx context.Context, req model.CreateArticleRequest) (model.Article, error)
+	Update(ctx context.Context, req model.ArticleUpdate) (model.Article, error)
+	Delete(ctx context.Context, id int) error
+
+	// Watch streams article changes until the returned stop function is called
+	Watch(ctx context.Context) (<-chan ArticleEvent, func())
+}
+
+// ListArticlesRequest pages through articles in ID order
+type ListArticlesRequest struct {
+	PageSize  int    `json:"page_size" proto:"1"`  // default 20, max 100
+	PageToken string `json:"page_token" proto:"2"` // next_page_token of the previous page
+	Language  string `json:"language" proto:"3"`   // only articles in this language
+}
+
+type ListArticlesResponse struct {
+	Articles      []model.Article `json:"articles" proto:"1"`
+	NextPageToken string          `json:"next_page_token,omitempty" proto:"2"`
+}
+
+var ErrArticleNotFound = errors.New("article not found")
+
+// ValidationError reports invalid input; Message is shown to the client,
+// with the invalid fields if the request failed its validate tags
+type ValidationError struct {
+	Message string
+	Fields  []validate.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// Check a request against its validate tags
+func validateRequest(req any) error {
+	if errs := validate.Struct(req); errs != nil {
+		return &ValidationError{Message: errs.Error(), Fields: errs}
+	}
+	return nil
+}
+
+// Normalize the language tag of a request, if it has one
+func checkLanguage(lang *string) error {
+	if *lang == "" {
+		return nil
+	}
+	tag, ok := languageTag(*lang)
+	if !ok {
+		return &ValidationError{Message: "Invalid language tag"}
+	}
+	*lang = tag
+	return nil
+}
+
+// storeArticleService implements ArticleService on the in-memory store
+type storeArticleService struct{ *App }
+
+type serviceState struct {
+	articleService ArticleService
+}
+
+func (s storeArticleService) Get(ctx context.Context, id int) (model.Article, error) {
+	if article, ok := s.readArticle(id); ok && (article.Moderation == nil || showsHeld(ctx)) {
+		return article, nil
+	}
+	return model.Article{}, ErrArticleNotFound
+}
+
+func (s storeArticleService) List(ctx context.Context, req ListArticlesRequest) (ListArticlesResponse, error) {
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	pageSize = min(pageSize, 100)
+
+	// Page tokens encode the last ID of the previous page
+	afterID := 0
+	if req.PageToken != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(req.PageToken)
+		if err == nil {
+			var after model.ArticleID
+			after, err = model.ParseArticleID(strings.TrimPrefix(string(raw), "after:"))
+			afterID = int(after)
+		}
+		if err != nil {
+			return ListArticlesResponse{}, &ValidationError{Message: "Invalid page_token"}
+		}
+	}
+
+	var keep func(model.Article) bool
+	if req.Language != "" {
+		lang, ok := languageTag(req.Language)
+		if !ok {
+			return ListArticlesResponse{}, &ValidationError{Message: "Invalid language tag"}
+		}
+		keep = s.inLanguage(lang)
+	}
+	page := s.articlesAfter(afterID, pageSize+1, notHeld(ctx, keep))
+	resp := ListArticlesResponse{Articles: page}
+	if len(page) > pageSize {
+		resp.Articles = page[:pageSize]
+		last := page[pageSize-1].ID
+		resp.NextPageToken = base64.RawURLEncoding.EncodeToString([]byte("after:" + model.ArticleID(last).String()))
+	}
+	// Plugins may drop articles from the page; the token still follows the last stored one
+	resp.Articles = s.runOnServeList(ctx, resp.Articles)
+	return resp, nil
+}
+
+// Sanitize, validate and store a new article
+func (s storeArticleService) Create(ctx context.Context, req model.CreateArticleRequest) (model.Article, error) {
+	article, err := s.newArticle(withAutoSummary(ctx), req)
+	if err != nil {
+		return model.Article{}, err
+	}
+	if err := s.checkArticleQuota(article.Workspace); err != nil {
+		return model.Article{}, err
+	}
+	s.checkSpam(ctx, &article)
+	article = s.insertArticle(ctx, article)
+	if article.Status == model.StatusPublished {
+		s.notifyPublished(article)
+	}
+	s.notifyMentions(ctx, model.Article{}, article)
+	return article, nil
+}
+
+// Build a sanitized, validated article from a create request without
+// storing it (the bulk import checks whole files this way first). Plugins
+// see the request first.
+func (app *App) newArticle(ctx context.Context, req model.CreateArticleRequest) (model.Article, error) {
+	if err := app.runBeforeCreate(ctx, &req); err != nil {
+		return model.Article{}, err
+	}
+	app.sanitizeArticle(&req.Title, &req.Desc, &req.Content)
+	app.scanPII(ctx, &req.Title, &req.Desc, &req.Content)
+	if err := checkLanguage(&req.Language); err != nil {
+		return model.Article{}, err
+	}
+	if req.Language == "" && app.cfg.DetectLanguage {
+		req.Language = langdetect.Detect(req.Title + "\n" + req.Desc + "\n" + req.Content)
+	}
+	if err := app.summarizeRequest(ctx, &req); err != nil {
+		return model.Article{}, err
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return model.Article{}, err
+	}
+	req.Tags = tags
+	if err := validateRequest(req); err != nil {
+		return model.Article{}, err
+	}
+	article := model.Article{
+		Title:    req.Title,
+		Desc:     req.Desc,
+		Content:  req.Content,
+		Status:   req.Status,
+		Pinned:   req.Pinned,
+		Featured: req.Featured,
+		Language: req.Language,
+		Tags:     req.Tags,
+
+		Workspace: req.Workspace,
+	}
+	if article.Status == "" {
+		article.Status = model.StatusPublished
+	}
+	if len(article.Tags) == 0 {
+		article.Tags = nil
+		if app.cfg.AutoTags == config.AutoTagsApply {
+			article.Tags = app.keywordTags(article)
+		}
+	}
+	app.suggestTags(ctx, article)
+	app.articlesMutex.RLock()
+	defer app.articlesMutex.RUnlock()
+	if err := app.setConfidential(&article, req.Confidential); err != nil {
+		return model.Article{}, err
+	}
+	return article, nil
+}
+
+// Apply a partial update; empty strings and nil flags leave fields unchanged
+func (s storeArticleService) Update(ctx context.Context, updateData model.ArticleUpdate) (model.Article, error) {
+	s.sanitizeArticle(&updateData.Title, &updateData.Desc, &updateData.Content)
+	s.scanPII(ctx, &updateData.Title, &updateData.Desc, &updateData.Content)
+	tags, err := normalizeTags(updateData.Tags)
+	if err != nil {
+		return model.Article{}, err
+	}
+	updateData.Tags = tags
+	if err := validateRequest(updateData); err != nil {
+		return model.Article{}, err
+	}
+	if err := checkLanguage(&updateData.Language); err != nil {
+		return model.Article{}, err
+	}
+
+	// Tell plugins, notify about a first publication and new mentions and
+	// suggest tags for new text once the lock is released
+	var updated, published bool
+	var before, article model.Article
+	defer func() {
+		if updated {
+			s.runAfterUpdate(ctx, article)
+			s.notifyMentions(ctx, before, article)
+			if updateData.Title+updateData.Desc+updateData.Content != "" {
+				s.suggestTags(ctx, article)
+			}
+		}
+		if published {
+			s.notifyPublished(article)
+		}
+	}()
+
+	s.articlesMutex.Lock()
+	defer s.articlesMutex.Unlock()
+
+	i := s.findArticleIndex(updateData.ID)
+	if i < 0 {
+		return model.Article{}, ErrArticleNotFound
+	}
+	// Only an approval publishes a held article
+	approve := false
+	if updateData.Status == model.StatusPublished && s.articles[i].Moderation != nil {
+		if ctx.Value(approvalKey{}) == nil {
+			return model.Article{}, ErrHeldForModeration
+		}
+		approve = true
+	}
+	before = s.articles[i]
+	if updateData.Confidential != nil {
+		if err := s.setConfidential(&s.articles[i], *updateData.Confidential); err != nil {
+			return model.Article{}, err
+		}
+	}
+
+	// Update fields if provided
+	if updateData.Title != "" {
+		s.articles[i].Title = updateData.Title
+	}
+	if updateData.Desc != "" {
+		s.articles[i].Desc = updateData.Desc
+	}
+	if updateData.Content != "" {
+		s.articles[i].Content = updateData.Content
+	}
+	if updateData.Language != "" {
+		s.articles[i].Language = updateData.Language
+	} else if s.cfg.DetectLanguage && updateData.Title+updateData.Desc+updateData.Content != "" {
+		// New text may be in another language; a language it can't tell
+		// leaves the one there was
+		if lang := langdetect.Detect(s.articles[i].Title + "\n" + s.articles[i].Desc + "\n" + s.articles[i].Content); lang != "" {
+			s.articles[i].Language = lang
+		}
+	}
+	if updateData.Tags != nil {
+		s.articles[i].Tags = updateData.Tags
+		if len(s.articles[i].Tags) == 0 {
+			s.articles[i].Tags = nil
+		}
+	}
+	if approve {
+		s.articles[i].Moderation = nil
+	}
+	if updateData.Status != "" {
+		s.articles[i].Status = updateData.Status
+		if updateData.Status == model.StatusPublished && s.articles[i].Published.IsZero() {
+			s.articles[i].Published = time.Now()
+			published = true
+		}
+	}
+	if updateData.Pinned != nil {
+		s.articles[i].Pinned = *updateData.Pinned
+	}
+	if updateData.Featured != nil {
+		s.articles[i].Featured = *updateData.Featured
+		if !s.articles[i].Featured {
+			s.articles[i].FeaturedOrder = 0
+		}
+	}
+	s.articles[i].Updated = time.Now()
+	s.publishArticleEvent(ctx, EventArticleUpdated, s.articles[i])
+
+	// Save to file
+	s.scheduleSave()
+
+	article, updated = s.articles[i], true
+	return article, nil
+}
+
+// Delete an article together with its attachment files
+func (s storeArticleService) Delete(ctx context.Context, id int) error {
+	s.articlesMutex.Lock()
+	defer s.articlesMutex.Unlock()
+
+	i := s.findArticleIndex(id)
+	if i < 0 {
+		return ErrArticleNotFound
+	}
+
+	// Remove article from slice
+	article := s.articles[i]
+	s.articles = append(s.articles[:i], s.articles[i+1:]...)
+	s.deleteAttachmentBlobs(article.Attachments)
+	s.publishArticleEvent(ctx, EventArticleDeleted, article)
+
+	// Save to file
+	s.scheduleSave()
+
+	return nil
+}
+
+func (s storeArticleService) Watch(ctx context.Context) (<-chan ArticleEvent, func()) {
+	return s.events.Subscribe(64)
+}
+
+// Add an article in ID order; the caller holds articlesMutex. IDs are
+// handed out before the lock, so a concurrent create may have added a
+// higher one already.
+func (app *App) addArticle(article model.Article) {
+	i := len(app.articles)
+	for i > 0 && app.articles[i-1].ID > article.ID {
+		i--
+	}
+	app.articles = slices.Insert(app.articles, i, article)
+}
+
+// Copy up to limit articles with IDs above afterID, only those keep
+// accepts unless it is nil. The articles slice is kept in ID order, see
+// addArticle.
+func (app *App) articlesAfter(afterID, limit int, keep func(model.Article) bool) []model.Article {
+	articles := app.readArticles()
+	start := sort.Search(len(articles), func(i int) bool { return articles[i].ID > afterID })
+	if keep == nil {
+		end := min(start+limit, len(articles))
+		return append([]model.Article(nil), articles[start:end]...)
+	}
+	var list []model.Article
+	for _, article := range articles[start:] {
+		if len(list) == limit {
+			break
+		}
+		if keep(article) {
+			list = append(list, article)
+		}
+	}
+	return list
+}
+
+// Store a new article, assigning its ID, slug, uid and timestamps. Server-managed
+// fields that a client may have sent are reset. The user in ctx, if any,
+// made it.
+func (app *App) insertArticle(ctx context.Context, article model.Article) model.Article {
+	article.ID = app.articleIDs.Next()
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+
+	// Set timestamps; imported articles keep their original dates
+	article.Slug = app.uniqueSlug(article.Title, article.ID)
+	if article.Created.IsZero() {
+		article.Created = time.Now()
+	}
+	if article.Updated.IsZero() {
+		article.Updated = article.Created
+	}
+	article.UID = app.newUID(article.Created)
+	if article.Status != model.StatusPublished {
+		article.Published = time.Time{}
+	} else if article.Published.IsZero() {
+		article.Published = article.Created
+	}
+	article.FeaturedOrder = 0
+	article.Attachments = nil
+	article.CoverImage = nil
+
+	app.addArticle(article)
+	app.publishArticleEvent(ctx, EventArticleCreated, article)
+
+	// Save to file
+	app.scheduleSave()
+
+	return article
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type shutdownState struct {
+	// Orchestration scripts that can't signal the process shut it down over
+	// HTTP. The handlers only announce the request; whoever runs the server
+	// (the binary, server.Run or an embedding program) does the shutdown.
+	shutdownMutex     sync.Mutex
+	shutdownRequested chan struct{}
+	shutdownAsked     bool
+	drainUntil        time.Time   // zero unless draining
+	drainTimer        *time.Timer // asks for the shutdown at drainUntil
+}
+
+// DrainStatus is the answer to POST /admin/drain and /admin/shutdown
+type DrainStatus struct {
+	ShutdownAt time.Time `json:"shutdown_at"` // when the shutdown starts
+}
+
+// ShutdownRequested returns a channel that is closed when an admin asks for
+// the server to shut down, at once or at the end of a drain
+func (app *App) ShutdownRequested() <-chan struct{} {
+	app.shutdownMutex.Lock()
+	defer app.shutdownMutex.Unlock()
+	return app.shutdownRequested
+}
+
+func (app *App) requestShutdown() {
+	app.shutdownMutex.Lock()
+	defer app.shutdownMutex.Unlock()
+	if !app.shutdownAsked {
+		app.shutdownAsked = true
+		close(app.shutdownRequested)
+	}
+}
+
+// Whether the server is draining, so clients should take their next
+// requests elsewhere
+func (app *App) draining() bool {
+	app.shutdownMutex.Lock()
+	defer app.shutdownMutex.Unlock()
+	return !app.drainUntil.IsZero()
+}
+
+// closeWhenDraining ends every connection after its response while the
+// server drains, so that keep-alive clients and load balancers move on to
+// other instances before it shuts down
+func (app *App) closeWhenDraining(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.draining() {
+			w.Header().Set("Connection", "close")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Who asked, for the log
+func requester(r *http.Request) string {
+	if user, ok := currentUser(r); ok {
+		return user.Username
+	}
+	return r.RemoteAddr
+}
+
+// POST /admin/shutdown - Shut down gracefully: requests in progress finish,
+// changes are saved
+func (app *App) shutdownServer(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Shutdown requested by %s", requester(r))
+	app.writeResponse(w, r, http.StatusAccepted, Response{Message: "Shutting down", Data: DrainStatus{ShutdownAt: time.Now()}})
+	app.requestShutdown()
+}
+
+// POST /admin/drain?grace=30s - Keep serving for the grace period with
+// connections closed after every response, then shut down
+func (app *App) drainServer(w http.ResponseWriter, r *http.Request) {
+	grace := app.cfg.DrainGrace
+	if v := r.URL.Query().Get("grace"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid grace")
+			return
+		}
+		grace = d
+	}
+
+	app.shutdownMutex.Lock()
+	// A second drain may only bring the shutdown forward
+	if at := time.Now().Add(grace); app.drainUntil.IsZero() || at.Before(app.drainUntil) {
+		if app.drainTimer != nil {
+			app.drainTimer.Stop()
+		}
+		app.drainUntil = at
+		app.drainTimer = time.AfterFunc(grace, app.requestShutdown)
+	}
+	status := DrainStatus{ShutdownAt: app.drainUntil}
+	app.shutdownMutex.Unlock()
+
+	log.Printf("Drain requested by %s, shutting down at %s", requester(r), status.ShutdownAt.Format(time.RFC3339))
+	app.writeResponse(w, r, http.StatusAccepted, Response{Message: "Draining", Data: status})
+}
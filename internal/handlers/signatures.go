@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-spring/internal/config"
+)
+
+// Signed requests (SIGNING_KEYS_FILE), for machine clients that shouldn't
+// hold a password. A client signs the method, path and query, the SHA-256
+// of the body, the time and a random nonce with a key's secret and sends
+//
+//	Authorization: HMAC-SHA256 KeyId=<id>,Timestamp=<unix>,Nonce=<nonce>,Signature=<base64>
+//
+// A valid signature authenticates the request as the key's user, as Basic
+// credentials would. Signatures are good for signatureTolerance either way
+// of the server's clock, and once: a copy of a request is refused, while
+// the nonce lets a client retry with a new signature in the same second.
+// A key may be limited to scopes of routes and may expire, so a CI system
+// gets no more than it needs for as long as it needs it.
+
+const (
+	signatureScheme    = "HMAC-SHA256"
+	signatureTolerance = 5 * time.Minute
+)
+
+// SigningKey is a shared secret that signs requests as a user
+type SigningKey struct {
+	ID     string `json:"id"`
+	User   string `json:"user"`
+	Secret string `json:"secret"`
+
+	Scopes  []string  `json:"scopes,omitempty"` // what the key may do, see routeScope; all if empty
+	Expires time.Time `json:"expires,omitzero"` // when the key stops working; never if zero
+}
+
+// Scopes of signing keys
+const (
+	ScopeArticlesRead  = "articles:read"  // GET of the routes outside admin
+	ScopeArticlesWrite = "articles:write" // changes through the routes outside admin
+	ScopeAdmin         = "admin"          // the admin routes, with an admin user
+)
+
+var signingScopes = []string{ScopeArticlesRead, ScopeArticlesWrite, ScopeAdmin}
+
+// Shortest secret a signing key may have
+const minSigningSecret = 16
+
+type signaturesState struct {
+	signingKeys map[string]SigningKey
+
+	// Signatures of the requests accepted within signatureTolerance, to refuse
+	// replays, and the same in the order they were accepted, so the expired
+	// ones are dropped from the front instead of by a scan of them all
+	signaturesMutex sync.Mutex
+	seenSignatures  map[string]bool
+	signatureQueue  []seenSignature
+}
+
+type seenSignature struct {
+	signature string
+	accepted  time.Time
+}
+
+// Load the keys of SIGNING_KEYS_FILE, a JSON array of SigningKey. Their
+// users are looked up on each request, so keys may name users added later.
+func (app *App) initSigningKeys(cfg config.Config) error {
+	app.signingKeys = nil
+	if cfg.SigningKeysFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(cfg.SigningKeysFile)
+	if err != nil {
+		return err
+	}
+	var keys []SigningKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	app.signingKeys = map[string]SigningKey{}
+	for i, key := range keys {
+		switch {
+		case key.ID == "" || key.User == "":
+			return fmt.Errorf("key %d: id and user are required", i+1)
+		case len(key.Secret) < minSigningSecret:
+			return fmt.Errorf("key %s: secret must be at least %d characters", key.ID, minSigningSecret)
+		}
+		for _, scope := range key.Scopes {
+			if !slices.Contains(signingScopes, scope) {
+				return fmt.Errorf("key %s: unknown scope %q (want %s)", key.ID, scope, strings.Join(signingScopes, ", "))
+			}
+		}
+		if !key.Expires.IsZero() && time.Now().After(key.Expires) {
+			log.Printf("Warning: signing key %s expired at %s", key.ID, key.Expires.Format(time.RFC3339))
+		}
+		if _, dup := app.signingKeys[key.ID]; dup {
+			return fmt.Errorf("key %s is listed twice", key.ID)
+		}
+		app.signingKeys[key.ID] = key
+	}
+	return nil
+}
+
+// Authenticate signed requests, answering 401 if the signature doesn't
+// check out; other requests pass as they are
+func (app *App) verifySignatures(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, params, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, signatureScheme) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The body is hashed before the handler reads it
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, app.cfg.AttachmentMaxBytes+1<<20))
+		if err != nil {
+			app.writeError(w, r, http.StatusRequestEntityTooLarge, CodeFileTooLarge, "Request body too large")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key, err := app.checkSignature(r, params, body, time.Now())
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", signatureScheme)
+			app.writeError(w, r, http.StatusUnauthorized, CodeInvalidSignature, err.Error())
+			return
+		}
+		user, ok := app.findUser(key.User)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", signatureScheme)
+			app.writeError(w, r, http.StatusUnauthorized, CodeInvalidSignature, errUnknownKey.Error())
+			return
+		}
+		ctx := context.WithValue(r.Context(), userKey{}, user)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, signingKeyKey{}, key)))
+	})
+}
+
+type signingKeyKey struct{}
+
+// The scope a request needs for a route: admin for the admin routes, else
+// articles:read to read and articles:write to change anything. Routes
+// authorized by a signed link instead of credentials need none.
+func routeScope(route Route) string {
+	switch {
+	case route.linkSigned:
+		return ""
+	case adminRoutes(route):
+		return ScopeAdmin
+	case route.Method == http.MethodGet || route.Method == http.MethodHead:
+		return ScopeArticlesRead
+	}
+	return ScopeArticlesWrite
+}
+
+// Hold every request to the scopes of whoever sent it, once there are
+// scoped keys: a signed request to those of its key, answering 403, and an
+// unsigned one that changes something to a user's Basic credentials,
+// answering 401 without them. Otherwise anyone could make the changes a
+// read-only key may not, simply by not signing. Users have every scope;
+// their role and workspaces are checked as well, after this. Until the
+// first user is added there is no one to authenticate, as for the admin
+// routes.
+func (app *App) requireScope(route Route, next http.Handler) http.Handler {
+	scope := routeScope(route)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, signed := r.Context().Value(signingKeyKey{}).(SigningKey)
+		switch {
+		case signed:
+			if len(key.Scopes) > 0 && scope != "" && !slices.Contains(key.Scopes, scope) {
+				app.writeError(w, r, http.StatusForbidden, CodeForbidden, "Signing key scope required: "+scope)
+				return
+			}
+		case scope == ScopeArticlesWrite && app.hasUsers():
+			if _, ok := app.requestUser(r); !ok {
+				w.Header().Add("WWW-Authenticate", signatureScheme)
+				w.Header().Add("WWW-Authenticate", `Basic realm="go-spring admin", charset="UTF-8"`)
+				app.writeError(w, r, http.StatusUnauthorized, CodeAuthenticationRequired, "Changes need a signed request or credentials")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Errors of checkSignature, which are shown to the client
+var (
+	errUnknownKey      = errors.New("Unknown signing key")
+	errBadSignature    = errors.New("Invalid request signature")
+	errStaleSignature  = errors.New("Request signature has expired")
+	errExpiredKey      = errors.New("Signing key has expired")
+	errReplayedRequest = errors.New("Request was already received")
+)
+
+// Check the signature of a request, returning its key
+func (app *App) checkSignature(r *http.Request, params string, body []byte, now time.Time) (SigningKey, error) {
+	fields := map[string]string{}
+	for _, param := range strings.Split(params, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		fields[strings.ToLower(name)] = strings.Trim(value, `"`)
+	}
+	key, ok := app.signingKeys[fields["keyid"]]
+	if !ok {
+		return SigningKey{}, errUnknownKey
+	}
+	unix, err := strconv.ParseInt(fields["timestamp"], 10, 64)
+	if err != nil || fields["nonce"] == "" {
+		return SigningKey{}, errBadSignature
+	}
+	want := signRequest(key.Secret, r.Method, r.URL.RequestURI(), body, fields["timestamp"], fields["nonce"])
+	if !hmac.Equal([]byte(fields["signature"]), []byte(want)) {
+		return SigningKey{}, errBadSignature
+	}
+	if sent := time.Unix(unix, 0); sent.Before(now.Add(-signatureTolerance)) || sent.After(now.Add(signatureTolerance)) {
+		return SigningKey{}, errStaleSignature
+	}
+	if !key.Expires.IsZero() && !now.Before(key.Expires) {
+		return SigningKey{}, errExpiredKey
+	}
+
+	app.signaturesMutex.Lock()
+	defer app.signaturesMutex.Unlock()
+	expired := 0
+	for _, seen := range app.signatureQueue {
+		if now.Sub(seen.accepted) <= 2*signatureTolerance {
+			break
+		}
+		delete(app.seenSignatures, seen.signature)
+		expired++
+	}
+	app.signatureQueue = app.signatureQueue[expired:]
+	if app.seenSignatures[want] {
+		return SigningKey{}, errReplayedRequest
+	}
+	app.seenSignatures[want] = true
+	app.signatureQueue = append(app.signatureQueue, seenSignature{want, now})
+	return key, nil
+}
+
+// The signature of a request: base64 HMAC-SHA256 of the method, the path
+// with its query, the hex SHA-256 of the body, the timestamp and the
+// nonce, one per line. The client package signs the same way.
+func signRequest(secret, method, requestURI string, body []byte, timestamp, nonce string) string {
+	sum := sha256.Sum256(body)
+	h := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(h, "%s\n%s\n%s\n%s\n%s", method, requestURI, hex.EncodeToString(sum[:]), timestamp, nonce)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-spring/client"
+	"go-spring/internal/model"
+)
+
+func TestSignedRequests(t *testing.T) {
+	srv := newTestServer(t, 1)
+	file := filepath.Join(t.TempDir(), "keys.json")
+	os.WriteFile(file, []byte(`[
+		{"id": "ci", "user": "alice", "secret": "0123456789abcdef"},
+		{"id": "bot", "user": "bob", "secret": "fedcba9876543210"},
+		{"id": "reader", "user": "alice", "secret": "0123456789abcdef", "scopes": ["articles:read"]},
+		{"id": "old", "user": "alice", "secret": "0123456789abcdef", "expires": "2020-01-01T00:00:00Z"}
+	]`), 0o600)
+	srv.cfg.SigningKeysFile = file
+	if err := srv.initSigningKeys(srv.cfg); err != nil {
+		t.Fatal(err)
+	}
+	srv.reroute()
+	srv.AddUser("alice", "correct horse", model.RoleAdmin)
+	srv.AddUser("bob", "battery staple", model.RoleEditor)
+
+	send := func(req *http.Request) (int, string) {
+		t.Helper()
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body struct{ Code string }
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body.Code
+	}
+	signed := func(method, path, body, keyID, secret string) *http.Request {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		client.SignRequest(req, []byte(body), keyID, secret)
+		return req
+	}
+
+	req := signed("GET", "/admin/moderation?x=1", "", "ci", "0123456789abcdef")
+	if status, _ := send(req); status != http.StatusOK {
+		t.Errorf("signed admin request: %d", status)
+	}
+	// The same request again is a replay
+	replay, _ := http.NewRequest("GET", req.URL.String(), nil)
+	replay.Header = req.Header
+	if status, code := send(replay); status != http.StatusUnauthorized || code != CodeInvalidSignature {
+		t.Errorf("replay: %d %s", status, code)
+	}
+	if status, code := send(signed("GET", "/admin/moderation", "", "ci", "wrong secret....")); status != http.StatusUnauthorized || code != CodeInvalidSignature {
+		t.Errorf("wrong secret: %d %s", status, code)
+	}
+	if status, _ := send(signed("GET", "/admin/moderation", "", "bot", "fedcba9876543210")); status != http.StatusForbidden {
+		t.Errorf("editor's key on an admin route: %d", status)
+	}
+
+	// Scopes limit a key below its user, and expired keys are refused
+	if status, _ := send(signed("GET", "/articles/1", "", "reader", "0123456789abcdef")); status != http.StatusOK {
+		t.Errorf("read with a read key: %d", status)
+	}
+	if status, code := send(signed("GET", "/admin/moderation", "", "reader", "0123456789abcdef")); status != http.StatusForbidden || code != CodeForbidden {
+		t.Errorf("admin route with a read key: %d %s", status, code)
+	}
+	if status, _ := send(signed("DELETE", "/articles/1", "", "reader", "0123456789abcdef")); status != http.StatusForbidden {
+		t.Errorf("delete with a read key: %d", status)
+	}
+	if status, code := send(signed("GET", "/articles/1", "", "old", "0123456789abcdef")); status != http.StatusUnauthorized || code != CodeInvalidSignature {
+		t.Errorf("expired key: %d %s", status, code)
+	}
+
+	// The body is signed too
+	tampered, _ := http.NewRequest("PUT", srv.URL+"/articles/1", strings.NewReader(`{"title":"Evil"}`))
+	client.SignRequest(tampered, []byte(`{"title":"Fine"}`), "bot", "fedcba9876543210")
+	tampered.Header.Set("Content-Type", "application/json")
+	if status, code := send(tampered); status != http.StatusUnauthorized || code != CodeInvalidSignature {
+		t.Errorf("tampered body: %d %s", status, code)
+	}
+	stale := signed("GET", "/admin/moderation", "", "ci", "0123456789abcdef")
+	_, params, _ := strings.Cut(stale.Header.Get("Authorization"), " ")
+	if _, err := srv.checkSignature(stale, params, nil, time.Now().Add(10*time.Minute)); err != errStaleSignature {
+		t.Errorf("stale signature: %v", err)
+	}
+
+	// The client signs every attempt afresh
+	c := client.New(srv.URL)
+	c.KeyID, c.KeySecret = "bot", "fedcba9876543210"
+	article, err := c.Update(context.Background(), 1, client.UpdateArticleRequest{Title: "Signed"})
+	if err != nil || article.Title != "Signed" {
+		t.Fatalf("client update: %+v, %v", article, err)
+	}
+	if _, err := c.Update(context.Background(), 1, client.UpdateArticleRequest{Title: "Again"}); err != nil {
+		t.Errorf("second signed request: %v", err)
+	}
+}
+
+// A key limited to reading can't change anything, by any route or over
+// gRPC, nor by leaving the request unsigned
+func TestReadKeyCannotWrite(t *testing.T) {
+	srv := newTestServer(t, 1)
+	file := filepath.Join(t.TempDir(), "keys.json")
+	os.WriteFile(file, []byte(`[{"id": "reader", "user": "alice", "secret": "0123456789abcdef", "scopes": ["articles:read"]}]`), 0o600)
+	srv.cfg.SigningKeysFile = file
+	if err := srv.initSigningKeys(srv.cfg); err != nil {
+		t.Fatal(err)
+	}
+	srv.reroute()
+	srv.AddUser("alice", "correct horse", model.RoleAdmin)
+	before, _ := srv.readArticle(1)
+
+	paths := strings.NewReplacer("{id}", "1", "{attachmentId}", "1", "{uploadId}", "1", "{ws}", "team",
+		"{username}", "alice", "{lang}", "fi", "{slug}", before.Slug, "{uid}", "x", "{key}", "x", "{name}", "x")
+	body := `{"title":"Changed","desc":"d","content":"c"}`
+	for _, route := range srv.activeRoutes(srv.cfg) {
+		scope := routeScope(route)
+		if scope == ScopeArticlesRead || scope == "" {
+			continue
+		}
+		path := paths.Replace(route.Path)
+		req, _ := http.NewRequest(route.Method, srv.URL+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		client.SignRequest(req, []byte(body), "reader", "0123456789abcdef")
+		if resp := mustDo(t, req); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s %s with a read key: status %d", route.Method, path, resp.StatusCode)
+		}
+		req, _ = http.NewRequest(route.Method, srv.URL+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if resp := mustDo(t, req); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("unsigned %s %s: status %d", route.Method, path, resp.StatusCode)
+		}
+	}
+	if after, ok := srv.readArticle(1); !ok || after.Title != before.Title || srv.ArticleCount() != 1 {
+		t.Errorf("articles changed: %d, %+v", srv.ArticleCount(), after)
+	}
+
+	// gRPC calls can't be signed, so changes take Basic credentials there
+	base, grpc := newGRPCServer(t, srv)
+	create := model.CreateArticleRequest{Title: "Over gRPC", Desc: "d", Content: "c"}
+	signed, _ := http.NewRequest("POST", base, nil)
+	client.SignRequest(signed, nil, "reader", "0123456789abcdef")
+	for name, metadata := range map[string]http.Header{"unsigned": nil, "read key": signed.Header} {
+		if code, _ := grpcCallWith(t, grpc, base, "CreateArticle", metadata, create, &model.Article{}); code != grpcUnauthenticated {
+			t.Errorf("gRPC create, %s: status %d", name, code)
+		}
+		if code, _ := grpcCallWith(t, grpc, base, "DeleteArticle", metadata, grpcIDRequest{ID: 1}, &struct{}{}); code != grpcUnauthenticated {
+			t.Errorf("gRPC delete, %s: status %d", name, code)
+		}
+	}
+	basic, _ := http.NewRequest("POST", base, nil)
+	basic.SetBasicAuth("alice", "correct horse")
+	var created model.Article
+	if code, msg := grpcCallWith(t, grpc, base, "CreateArticle", basic.Header, create, &created); code != grpcOK || created.Title != "Over gRPC" {
+		t.Errorf("gRPC create with credentials: %d %s, %+v", code, msg, created)
+	}
+	// and reads need none
+	var got model.Article
+	if code, _ := grpcCall(t, grpc, base, "GetArticle", grpcIDRequest{ID: 1}, &got); code != grpcOK || got.ID != 1 {
+		t.Errorf("gRPC get: %d, %+v", code, got)
+	}
+}
+
+func mustDo(t *testing.T, req *http.Request) *http.Response {
+	t.Helper()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+// Signatures are remembered for twice signatureTolerance, and forgotten
+// oldest first
+func TestSeenSignaturesExpire(t *testing.T) {
+	srv := newTestServer(t, 0)
+	srv.signingKeys = map[string]SigningKey{"ci": {ID: "ci", User: "alice", Secret: "0123456789abcdef"}}
+	start := time.Now()
+	check := func(nonce string, now time.Time) error {
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		signature := signRequest("0123456789abcdef", "GET", "/articles", nil, timestamp, nonce)
+		params := "KeyId=ci,Timestamp=" + timestamp + ",Nonce=" + nonce + ",Signature=" + signature
+		_, err := srv.checkSignature(httptest.NewRequest("GET", "/articles", nil), params, nil, now)
+		return err
+	}
+	for i, nonce := range []string{"a", "b", "c"} {
+		if err := check(nonce, start.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatalf("request %s: %v", nonce, err)
+		}
+	}
+	if err := check("a", start); err != errReplayedRequest {
+		t.Errorf("replay: %v", err)
+	}
+	if err := check("d", start.Add(12*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.seenSignatures) != 2 || len(srv.signatureQueue) != 2 || !srv.signatureQueue[0].accepted.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("remembered %d signatures, queue %+v", len(srv.seenSignatures), srv.signatureQueue)
+	}
+}
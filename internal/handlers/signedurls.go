@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-spring/internal/config"
+	"go-spring/internal/model"
+	"go-spring/internal/urlsign"
+)
+
+// Private attachments and signed URLs. An attachment uploaded with
+// ?private=true is only served to users who may see its article, or to
+// whoever has a signed URL for it that hasn't expired; signed URLs need no
+// credentials, so they work in <img> tags and shared links. Until the first
+// user is added private attachments are open, as everything else is.
+
+type signedurlsState struct {
+	urlSigner urlsign.Signer
+}
+
+func (app *App) initSignedURLs(cfg config.Config) {
+	app.urlSigner = urlsign.New(cfg.URLSigningKey)
+}
+
+// SignedURL is a link to an attachment that works without credentials until
+// it expires
+type SignedURL struct {
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+// What a signed URL grants access to: the attachment with its resized
+// copies, under either download route
+func signedResource(attachmentID int) string {
+	return "attachments/" + strconv.Itoa(attachmentID)
+}
+
+// POST /articles/{id}/attachments/{attachmentId}/signed-url - Sign a link to
+// an attachment, good for ?expires_in or SIGNED_URL_TTL
+func (app *App) createSignedURL(w http.ResponseWriter, r *http.Request) {
+	id, attachmentID, err := app.attachmentRouteIDs(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, err.Error())
+		return
+	}
+	ttl := app.cfg.SignedURLTTL
+	if v := r.URL.Query().Get("expires_in"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > app.cfg.SignedURLTTL {
+			app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, fmt.Sprintf("expires_in must be a duration up to %s", app.cfg.SignedURLTTL))
+			return
+		}
+		ttl = d
+	}
+	// Workspace articles were checked by the workspaces stage
+	if _, ok := currentUser(r); !ok && app.hasUsers() {
+		if _, ok := app.basicAuthUser(w, r); !ok {
+			return
+		}
+	}
+	if _, ok := app.findAttachment(id, attachmentID); !ok {
+		app.writeError(w, r, http.StatusNotFound, CodeAttachmentNotFound, "Attachment not found")
+		return
+	}
+
+	expires := time.Now().Add(ttl).Truncate(time.Second).UTC()
+	query := app.urlSigner.Sign(signedResource(attachmentID), expires)
+	signed := SignedURL{
+		URL:     fmt.Sprintf("%s/attachments/%d?%s", app.publicBaseURL(r), attachmentID, query.Encode()),
+		Expires: expires,
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Signed URL created", Data: signed})
+}
+
+// Check that the request may download a private attachment of the article
+// with articleID, answering 401 or 403 if not. Public attachments are open.
+func (app *App) allowAttachment(w http.ResponseWriter, r *http.Request, articleID int, attachment model.Attachment) bool {
+	if !attachment.Private {
+		return true
+	}
+	// Shared caches must not hand a private file to others
+	w.Header().Set("Cache-Control", "private")
+	if !app.hasUsers() {
+		return true
+	}
+	switch err := app.urlSigner.Verify(signedResource(attachment.ID), r.URL.Query(), time.Now()); {
+	case err == nil:
+		return true
+	case errors.Is(err, urlsign.ErrExpired):
+		app.writeError(w, r, http.StatusForbidden, CodeSignedURLExpired, "Signed URL has expired")
+		return false
+	case !errors.Is(err, urlsign.ErrMissing):
+		app.writeError(w, r, http.StatusForbidden, CodeSignedURLInvalid, "Invalid URL signature")
+		return false
+	}
+	user, ok := currentUser(r)
+	if !ok {
+		if user, ok = app.basicAuthUser(w, r); !ok {
+			return false
+		}
+	}
+	if ws, ok := app.articleWorkspace(articleID); ok && !ws.Allows(user, model.WorkspaceViewer) {
+		app.writeError(w, r, http.StatusForbidden, CodeForbidden, "Workspace role required: "+model.WorkspaceViewer)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"go-spring/internal/config"
+	"go-spring/internal/model"
+	"go-spring/internal/slug"
+)
+
+// The slug of a title under SLUG_STRATEGY
+func (app *App) slugFor(title string) string {
+	if app.cfg.SlugStrategy == config.SlugPercent {
+		return slug.MakeUnicode(title)
+	}
+	return slug.Make(title)
+}
+
+// A slug for title that no article but id has, with -2, -3... added on a
+// collision. Call with articlesMutex held for writing.
+func (app *App) uniqueSlug(title string, id int) string {
+	return slug.Unique(app.slugFor(title), func(s string) bool {
+		for _, a := range app.articles {
+			if a.Slug == s && a.ID != id {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Give slugs to articles stored or seeded without one, in ID order so that
+// the first of two equal titles keeps the plain slug. Call with
+// articlesMutex held for writing.
+func (app *App) assignMissingSlugs() {
+	taken := map[string]bool{}
+	for _, a := range app.articles {
+		taken[a.Slug] = true
+	}
+	for i := range app.articles {
+		if app.articles[i].Slug == "" {
+			app.articles[i].Slug = slug.Unique(app.slugFor(app.articles[i].Title), func(s string) bool { return taken[s] })
+			taken[app.articles[i].Slug] = true
+		}
+	}
+}
+
+func (app *App) findArticleBySlug(s string) (model.Article, bool) {
+	for _, a := range app.readArticles() {
+		if a.Slug == s {
+			return a, true
+		}
+	}
+	return model.Article{}, false
+}
+
+// GET /articles/by-slug/{slug} - Get single article by its slug. Percent-
+// encoded slugs arrive decoded.
+func (app *App) getArticleBySlug(w http.ResponseWriter, r *http.Request) {
+	article, ok := app.findArticleBySlug(mux.Vars(r)["slug"])
+	if !ok || isHeld(article) && !app.requestShowsHeld(r) {
+		app.writeArticleError(w, r, ErrArticleNotFound)
+		return
+	}
+	app.writeArticle(w, r, article)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"go-spring/internal/model"
+)
+
+type snapshotState struct {
+	// The busiest reads (getting and listing articles, feeds, lookups by slug
+	// or UID) don't take articlesMutex: they share an immutable copy of the
+	// articles, so heavy read traffic doesn't queue up behind writers, nor
+	// writers behind long reads. Writers still take articlesMutex, and
+	// releasing it drops the copy; the next reader makes a new one.
+	//
+	// The copy shares the attachment and category slices of the articles, so
+	// writers replace those slices instead of changing their elements.
+	articleSnapshot atomic.Pointer[[]model.Article]
+	snapshotMutex   sync.Mutex // one reader copies at a time
+}
+
+// articlesLock is the type of articlesMutex: a sync.RWMutex whose Unlock
+// drops the snapshot, as the writer may have changed the articles
+type articlesLock struct {
+	sync.RWMutex
+	snapshot *atomic.Pointer[[]model.Article] // articleSnapshot
+}
+
+func (l *articlesLock) Unlock() {
+	l.snapshot.Store(nil)
+	l.RWMutex.Unlock()
+}
+
+// The articles as of the last change, in ID order, without locking.
+// Callers must not modify them.
+func (app *App) readArticles() []model.Article {
+	return *app.loadSnapshot()
+}
+
+// The snapshot itself; a new one is a new pointer
+func (app *App) loadSnapshot() *[]model.Article {
+	if list := app.articleSnapshot.Load(); list != nil {
+		return list
+	}
+	app.snapshotMutex.Lock()
+	defer app.snapshotMutex.Unlock()
+	if list := app.articleSnapshot.Load(); list != nil {
+		return list
+	}
+	// Writers can't drop a snapshot taken while they wait for the lock
+	app.articlesMutex.RLock()
+	defer app.articlesMutex.RUnlock()
+	list := slices.Clone(app.articles)
+	app.articleSnapshot.Store(&list)
+	return &list
+}
+
+// The article with id, from the snapshot
+func (app *App) readArticle(id int) (model.Article, bool) {
+	list := app.readArticles()
+	i, found := slices.BinarySearchFunc(list, id, func(a model.Article, id int) int { return a.ID - id })
+	if !found {
+		return model.Article{}, false
+	}
+	return list[i], true
+}
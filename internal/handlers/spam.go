@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"go-spring/internal/config"
+	"go-spring/internal/model"
+	"go-spring/internal/spam"
+)
+
+// Spam checks of public submissions (SPAM_CHECK). An article created
+// without credentials is scored by the keyword, link density and Akismet
+// checks and those of plugins; one that scores SPAM_THRESHOLD is stored as
+// a draft held for moderation instead of being published, and can't be
+// published until an admin approves it. Until then only admins see it,
+// and everyone while there are no users, as with the admin routes. The
+// server has no comments, so articles are all there is to check.
+
+// ErrHeldForModeration is returned for an attempt to publish a held article
+// other than by approving it
+var ErrHeldForModeration = errors.New("article is held for moderation")
+
+type spamState struct {
+	// The built-in checks; none when SPAM_CHECK is off
+	spamChecks []spam.Check
+}
+
+func (app *App) initSpam(cfg config.Config) {
+	app.spamChecks = nil
+	if !cfg.SpamCheck {
+		return
+	}
+	if len(cfg.SpamKeywords) > 0 {
+		app.spamChecks = append(app.spamChecks, spam.Keywords(cfg.SpamKeywords))
+	}
+	app.spamChecks = append(app.spamChecks, spam.LinkDensity(cfg.SpamLinkDensity))
+	if cfg.AkismetKey != "" {
+		app.spamChecks = append(app.spamChecks, spam.Akismet{Key: cfg.AkismetKey, Blog: cfg.AkismetBlog})
+	}
+}
+
+type submitterKey struct{}
+
+// Remember where a submission came from, for the checks that ask
+func withSubmitter(ctx context.Context, r *http.Request) context.Context {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return context.WithValue(ctx, submitterKey{}, spam.Submission{IP: ip, UserAgent: r.UserAgent(), Referrer: r.Referer()})
+}
+
+// Hold a new article for moderation if it was submitted without
+// credentials and scores too much. A check that fails is logged and
+// counts for nothing, so an outage of Akismet doesn't stop submissions.
+func (app *App) checkSpam(ctx context.Context, article *model.Article) {
+	if !app.cfg.SpamCheck {
+		return
+	}
+	if _, ok := ctx.Value(userKey{}).(model.User); ok {
+		return
+	}
+	checks := slices.Clone(app.spamChecks)
+	for _, p := range app.registeredPlugins() {
+		if p.SpamCheck != nil {
+			checks = append(checks, p.SpamCheck)
+		}
+	}
+	submission, _ := ctx.Value(submitterKey{}).(spam.Submission)
+	submission.Title, submission.Desc, submission.Content = article.Title, article.Desc, article.Content
+	verdict, err := spam.Scorer{Checks: checks}.Score(ctx, submission)
+	if err != nil {
+		log.Printf("Warning: spam check: %v", err)
+	}
+	if verdict.Score < app.cfg.SpamThreshold {
+		return
+	}
+	article.Status = model.StatusDraft
+	article.Moderation = &model.Moderation{Score: verdict.Score, Reasons: verdict.Reasons, Held: time.Now().UTC()}
+}
+
+type approvalKey struct{}
+
+type showHeldKey struct{}
+
+// Let the article service return held articles if shows says the reader
+// may see them; it is asked only once there is one
+func withHeld(ctx context.Context, shows func() bool) context.Context {
+	return context.WithValue(ctx, showHeldKey{}, shows)
+}
+
+func showsHeld(ctx context.Context) bool {
+	shows, _ := ctx.Value(showHeldKey{}).(func() bool)
+	return shows != nil && shows()
+}
+
+// Whether the request may see held articles: an admin's may, and everyone's
+// until the first user is added
+func (app *App) requestShowsHeld(r *http.Request) bool {
+	if !app.hasUsers() {
+		return true
+	}
+	user, ok := app.requestUser(r)
+	return ok && user.Role == model.RoleAdmin
+}
+
+// The context of a request for the article service, with held articles if
+// the request may see them
+func (app *App) readerContext(r *http.Request) context.Context {
+	return withHeld(r.Context(), func() bool { return app.requestShowsHeld(r) })
+}
+
+// gRPC calls carry no credentials, so they see held articles only while
+// there are no users
+func (app *App) grpcReaderContext(ctx context.Context) context.Context {
+	return withHeld(ctx, func() bool { return !app.hasUsers() })
+}
+
+// Leave out the held articles the request may not see, copying list before
+// changing it
+func (app *App) withoutHeld(r *http.Request, list []model.Article) []model.Article {
+	if !slices.ContainsFunc(list, isHeld) || app.requestShowsHeld(r) {
+		return list
+	}
+	return slices.DeleteFunc(slices.Clone(list), isHeld)
+}
+
+// The stored article of id, unless it is held and the request may not see it
+func (app *App) requestArticle(r *http.Request, id int) (model.Article, bool) {
+	article, ok := app.readArticle(id)
+	return article, ok && (!isHeld(article) || app.requestShowsHeld(r))
+}
+
+func isHeld(article model.Article) bool {
+	return article.Moderation != nil
+}
+
+// keep, if not nil, and not held unless the reader of ctx may see it
+func notHeld(ctx context.Context, keep func(model.Article) bool) func(model.Article) bool {
+	shows := sync.OnceValue(func() bool { return showsHeld(ctx) })
+	return func(article model.Article) bool {
+		return (!isHeld(article) || shows()) && (keep == nil || keep(article))
+	}
+}
+
+// GET /admin/moderation - List the articles held for moderation, oldest
+// first
+func (app *App) listModeration(w http.ResponseWriter, r *http.Request) {
+	held := []model.Article{}
+	for _, a := range app.readArticles() {
+		if a.Moderation != nil {
+			held = append(held, a)
+		}
+	}
+	slices.SortStableFunc(held, func(a, b model.Article) int { return a.Moderation.Held.Compare(b.Moderation.Held) })
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Held articles retrieved successfully", Data: held})
+}
+
+// POST /admin/moderation/{id}/approve - Publish a held article
+func (app *App) approveArticle(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.heldArticleID(w, r)
+	if !ok {
+		return
+	}
+	ctx := context.WithValue(r.Context(), approvalKey{}, true)
+	article, err := app.articleService.Update(ctx, model.ArticleUpdate{ID: id, Status: model.StatusPublished})
+	if err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+	caller, _ := currentUser(r)
+	log.Printf("Audit: %s approved held article %d", cmp.Or(caller.Username, "-"), id)
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Article approved and published", Data: article})
+}
+
+// POST /admin/moderation/{id}/reject - Delete a held article
+func (app *App) rejectArticle(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.heldArticleID(w, r)
+	if !ok {
+		return
+	}
+	if err := app.articleService.Delete(r.Context(), id); err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+	caller, _ := currentUser(r)
+	log.Printf("Audit: %s rejected held article %d", cmp.Or(caller.Username, "-"), id)
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Article rejected and deleted"})
+}
+
+// The ID of the held article a moderation route names, or an error
+// response
+func (app *App) heldArticleID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := app.articleRouteID(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid article ID")
+		return 0, false
+	}
+	article, found := app.readArticle(id)
+	if !found {
+		app.writeError(w, r, http.StatusNotFound, CodeArticleNotFound, "Article not found")
+		return 0, false
+	}
+	if article.Moderation == nil {
+		app.writeError(w, r, http.StatusConflict, CodeConflict, "Article is not held for moderation")
+		return 0, false
+	}
+	return id, true
+}
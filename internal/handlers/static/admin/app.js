@@ -0,0 +1,283 @@
+// Admin dashboard: articles, activity and backups through the API
+(function () {
+  "use strict";
+
+  var auth = sessionStorage.getItem("auth") || "";
+  var articles = [];
+  var editing = null; // the article in the editor, null for a new one
+  var activityToken = "";
+
+  function $(id) { return document.getElementById(id); }
+
+  function el(tag, attrs, children) {
+    var node = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (k) {
+      if (k === "text") node.textContent = attrs[k];
+      else node.setAttribute(k, attrs[k]);
+    });
+    (children || []).forEach(function (c) { if (c) node.appendChild(c); });
+    return node;
+  }
+
+  // Call the API with the session's credentials; resolves to the response
+  // data and rejects with the error message
+  function api(method, path, body, raw) {
+    var headers = { "Accept": "application/json" };
+    if (auth) headers["Authorization"] = "Basic " + auth;
+    if (body !== undefined) headers["Content-Type"] = "application/json";
+    return fetch(path, {
+      method: method,
+      headers: headers,
+      body: body === undefined ? undefined : JSON.stringify(body)
+    }).then(function (resp) {
+      if (resp.ok && raw) return resp;
+      return resp.text().then(function (text) {
+        var payload = {};
+        try { payload = text ? JSON.parse(text) : {}; } catch (e) { payload = { error: text }; }
+        if (resp.status === 401) showSignIn(payload.error);
+        if (!resp.ok) {
+          var message = payload.error || resp.status + " " + resp.statusText;
+          if (payload.fields) {
+            message += ": " + payload.fields.map(function (f) { return f.message; }).join(", ");
+          }
+          throw new Error(message);
+        }
+        return payload.data;
+      });
+    });
+  }
+
+  function formatTime(t) {
+    return t ? new Date(t).toLocaleString() : "";
+  }
+
+  // Base64 of the UTF-8 bytes, as Basic credentials are sent
+  function basic(username, password) {
+    var bytes = new TextEncoder().encode(username + ":" + password);
+    var binary = "";
+    bytes.forEach(function (b) { binary += String.fromCharCode(b); });
+    return btoa(binary);
+  }
+
+  // Sign-in
+
+  function showSignIn(message) {
+    ["tabs", "articles", "editor", "activity", "backups"].forEach(function (id) { $(id).hidden = true; });
+    $("signin").hidden = false;
+    $("signin-error").textContent = message || "";
+  }
+
+  // The admin users route tells whether the credentials are an admin's; it
+  // is open until the first user is added
+  function start() {
+    api("GET", "/admin/users").then(function (users) {
+      $("signin").hidden = true;
+      $("tabs").hidden = false;
+      $("who").textContent = auth ? "" : "No users yet: the admin routes are open";
+      $("signout").hidden = !auth;
+      fillUsers(users);
+      showTab("articles");
+    }, function (err) {
+      showSignIn(auth ? err.message : "");
+    });
+  }
+
+  $("signin").addEventListener("submit", function (e) {
+    e.preventDefault();
+    auth = basic($("username").value, $("password").value);
+    sessionStorage.setItem("auth", auth);
+    $("password").value = "";
+    start();
+  });
+
+  $("signout").addEventListener("click", function () {
+    auth = "";
+    sessionStorage.removeItem("auth");
+    showSignIn();
+  });
+
+  // Tabs
+
+  function showTab(name) {
+    ["articles", "editor", "activity", "backups"].forEach(function (id) { $(id).hidden = id !== name; });
+    document.querySelectorAll("#tabs [data-tab]").forEach(function (b) {
+      b.classList.toggle("active", b.getAttribute("data-tab") === name);
+    });
+    if (name === "articles") loadArticles();
+    if (name === "activity") loadActivity(true);
+    if (name === "backups") loadBackups();
+  }
+
+  document.querySelectorAll("#tabs [data-tab]").forEach(function (b) {
+    b.addEventListener("click", function () { showTab(b.getAttribute("data-tab")); });
+  });
+
+  // Articles
+
+  function loadArticles() {
+    api("GET", "/articles").then(function (list) {
+      articles = list || [];
+      renderArticles();
+    }, function (err) {
+      $("article-rows").replaceChildren(el("tr", {}, [el("td", { colspan: "5", "class": "error", text: err.message })]));
+    });
+  }
+
+  function renderArticles() {
+    var q = $("search").value.trim().toLowerCase();
+    var rows = articles.filter(function (a) {
+      return !q || [a.title, a.desc, a.slug, a.status, a.workspace].some(function (s) {
+        return (s || "").toLowerCase().indexOf(q) >= 0;
+      });
+    }).map(function (a) {
+      var row = el("tr", { "class": "link" }, [
+        el("td", { text: String(a.id) }),
+        el("td", { text: a.title }),
+        el("td", { text: a.status + (a.confidential ? ", confidential" : "") }),
+        el("td", { text: a.workspace || "" }),
+        el("td", { text: formatTime(a.updated) })
+      ]);
+      row.addEventListener("click", function () { openEditor(a); });
+      return row;
+    });
+    $("article-rows").replaceChildren.apply($("article-rows"), rows);
+  }
+
+  $("search").addEventListener("input", renderArticles);
+  $("new-article").addEventListener("click", function () { openEditor(null); });
+
+  function openEditor(article) {
+    editing = article;
+    var a = article || { status: "published" };
+    $("editor-title").textContent = article ? "Article " + article.id : "New article";
+    $("f-title").value = a.title || "";
+    $("f-desc").value = a.desc || "";
+    $("f-content").value = a.content || "";
+    $("f-status").value = a.status || "published";
+    $("f-pinned").checked = !!a.pinned;
+    $("f-featured").checked = !!a.featured;
+    $("f-confidential").checked = !!a.confidential;
+    $("delete").hidden = !article;
+    $("editor-error").textContent = "";
+    showTab("editor");
+  }
+
+  $("article-form").addEventListener("submit", function (e) {
+    e.preventDefault();
+    var body = {
+      title: $("f-title").value,
+      desc: $("f-desc").value,
+      content: $("f-content").value,
+      status: $("f-status").value,
+      pinned: $("f-pinned").checked,
+      featured: $("f-featured").checked,
+      confidential: $("f-confidential").checked
+    };
+    var saved = editing ? api("PUT", "/articles/" + editing.id, body) : api("POST", "/articles", body);
+    saved.then(function () { showTab("articles"); }, function (err) {
+      $("editor-error").textContent = err.message;
+    });
+  });
+
+  $("cancel").addEventListener("click", function () { showTab("articles"); });
+
+  $("delete").addEventListener("click", function () {
+    if (!editing || !confirm("Delete “" + editing.title + "”?")) return;
+    api("DELETE", "/articles/" + editing.id).then(function () { showTab("articles"); }, function (err) {
+      $("editor-error").textContent = err.message;
+    });
+  });
+
+  // Activity
+
+  function fillUsers(users) {
+    var options = (users || []).map(function (u) {
+      return el("option", { value: u.username, text: u.username + " (" + u.role + ")" });
+    });
+    $("activity-user").replaceChildren.apply($("activity-user"), options);
+  }
+
+  function loadActivity(reset) {
+    var user = $("activity-user").value;
+    if (reset) {
+      activityToken = "";
+      $("activity-rows").replaceChildren();
+    }
+    $("activity-more").hidden = true;
+    if (!user) {
+      $("activity-rows").replaceChildren(el("tr", {}, [el("td", { colspan: "4", "class": "hint", text: "No users yet" })]));
+      return;
+    }
+    var path = "/users/" + encodeURIComponent(user) + "/activity?page_size=50";
+    if (activityToken) path += "&page_token=" + encodeURIComponent(activityToken);
+    api("GET", path).then(function (page) {
+      page.entries.forEach(function (e) {
+        $("activity-rows").appendChild(el("tr", {}, [
+          el("td", { text: formatTime(e.time) }),
+          el("td", { text: e.action }),
+          el("td", { text: e.article_id ? "#" + e.article_id + " " + (e.title || "") : "" }),
+          el("td", { text: e.subject || "" })
+        ]));
+      });
+      activityToken = page.next_page_token || "";
+      $("activity-more").hidden = !activityToken;
+    }, function (err) {
+      $("activity-rows").appendChild(el("tr", {}, [el("td", { colspan: "4", "class": "error", text: err.message })]));
+    });
+  }
+
+  $("activity-user").addEventListener("change", function () { loadActivity(true); });
+  $("activity-more").addEventListener("click", function () { loadActivity(false); });
+
+  // Backups are export jobs; running ones are polled until they finish
+
+  var pollTimer = null;
+
+  function loadBackups() {
+    clearTimeout(pollTimer);
+    api("GET", "/admin/jobs").then(function (jobs) {
+      var exports = (jobs || []).filter(function (j) { return j.kind === "export"; });
+      exports.sort(function (a, b) { return b.id - a.id; });
+      var rows = exports.map(function (j) {
+        var download = null;
+        if (j.state === "done") {
+          download = el("button", { type: "button", "class": "plain", text: "Download" });
+          download.addEventListener("click", function () { downloadBackup(j.id); });
+        }
+        var state = j.state;
+        if (j.progress && j.state === "running") state += " " + j.progress.done + "/" + j.progress.total;
+        if (j.last_error) state += ": " + j.last_error;
+        return el("tr", {}, [
+          el("td", { text: String(j.id) }),
+          el("td", { text: formatTime(j.created) }),
+          el("td", { text: state }),
+          el("td", {}, [download])
+        ]);
+      });
+      $("backup-rows").replaceChildren.apply($("backup-rows"), rows);
+      var busy = exports.some(function (j) { return j.state === "pending" || j.state === "running"; });
+      if (busy && !$("backups").hidden) pollTimer = setTimeout(loadBackups, 2000);
+    }, function (err) {
+      $("backup-rows").replaceChildren(el("tr", {}, [el("td", { colspan: "4", "class": "error", text: err.message })]));
+    });
+  }
+
+  $("backup").addEventListener("click", function () {
+    api("POST", "/admin/export").then(loadBackups, function (err) { alert(err.message); });
+  });
+
+  // A link wouldn't carry the credentials, so fetch the archive and save it
+  function downloadBackup(id) {
+    api("GET", "/jobs/" + id + "/download", undefined, true).then(function (resp) {
+      return resp.blob();
+    }).then(function (blob) {
+      var a = el("a", { href: URL.createObjectURL(blob), download: "go-spring-export-" + id + ".zip" });
+      document.body.appendChild(a);
+      a.click();
+      a.remove();
+      URL.revokeObjectURL(a.href);
+    }, function (err) { alert(err.message); });
+  }
+
+  start();
+})();
@@ -0,0 +1,145 @@
+// Minimal API explorer driven by /openapi.json
+(function () {
+  "use strict";
+
+  var spec;
+
+  function el(tag, attrs, children) {
+    var node = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (k) {
+      if (k === "text") node.textContent = attrs[k];
+      else node.setAttribute(k, attrs[k]);
+    });
+    (children || []).forEach(function (c) { if (c) node.appendChild(c); });
+    return node;
+  }
+
+  function resolve(schema) {
+    if (schema && schema.$ref) {
+      return spec.components.schemas[schema.$ref.split("/").pop()];
+    }
+    return schema || {};
+  }
+
+  // Build an example value for a schema, used to prefill request bodies
+  function example(schema, depth) {
+    schema = resolve(schema);
+    if ((depth || 0) > 3) return null;
+    if (schema.allOf) return example(schema.allOf[0], depth);
+    switch (schema.type) {
+      case "object":
+        var obj = {};
+        Object.keys(schema.properties || {}).forEach(function (k) {
+          obj[k] = example(schema.properties[k], (depth || 0) + 1);
+        });
+        return obj;
+      case "array": return [];
+      case "integer": case "number": return 0;
+      case "boolean": return false;
+      case "string": return schema.format === "date-time" ? new Date().toISOString() : "";
+    }
+    return null;
+  }
+
+  function renderOperation(path, method, op) {
+    var params = op.parameters || [];
+    var inputs = {};
+    var body = el("div", { "class": "body" });
+
+    params.forEach(function (p) {
+      var input = el("input", { type: "text", placeholder: p.description || p.schema.type });
+      inputs[p.name] = { param: p, input: input };
+      body.appendChild(el("label", { text: p.name + (p.in === "path" ? " (path)" : " (query)") }));
+      body.appendChild(input);
+    });
+
+    var content = op.requestBody && op.requestBody.content;
+    var jsonBody, fileInput;
+    if (content && content["application/json"]) {
+      jsonBody = el("textarea");
+      jsonBody.value = JSON.stringify(example(content["application/json"].schema), null, 2);
+      body.appendChild(el("label", { text: "Request body (JSON)" }));
+      body.appendChild(jsonBody);
+    } else if (content && content["multipart/form-data"]) {
+      fileInput = el("input", { type: "file" });
+      body.appendChild(el("label", { text: "file" }));
+      body.appendChild(fileInput);
+    }
+
+    var result = el("div");
+    var button = el("button", { type: "button", text: "Send request" });
+    button.addEventListener("click", function () {
+      var url = path, query = [];
+      Object.keys(inputs).forEach(function (name) {
+        var v = inputs[name].input.value;
+        if (inputs[name].param.in === "path") url = url.replace("{" + name + "}", encodeURIComponent(v));
+        else if (v !== "") query.push(encodeURIComponent(name) + "=" + encodeURIComponent(v));
+      });
+      if (query.length) url += "?" + query.join("&");
+
+      var init = { method: method.toUpperCase(), headers: {} };
+      if (jsonBody) {
+        init.body = jsonBody.value;
+        init.headers["Content-Type"] = "application/json";
+      } else if (fileInput && fileInput.files.length) {
+        init.body = new FormData();
+        init.body.append("file", fileInput.files[0]);
+      }
+
+      result.textContent = "…";
+      fetch(url, init).then(function (resp) {
+        return resp.text().then(function (text) {
+          var type = resp.headers.get("Content-Type") || "";
+          if (type.indexOf("json") >= 0) {
+            try { text = JSON.stringify(JSON.parse(text), null, 2); } catch (e) { /* keep raw */ }
+          } else if (!/^text\/|xml/.test(type)) {
+            text = "(" + type + " response, " + text.length + " bytes)";
+          }
+          result.replaceChildren(
+            el("p", { "class": "status", text: resp.status + " " + resp.statusText + "  " + method.toUpperCase() + " " + url }),
+            el("pre", { text: text })
+          );
+        });
+      }).catch(function (err) {
+        result.replaceChildren(el("pre", { text: String(err) }));
+      });
+    });
+    body.appendChild(button);
+    body.appendChild(result);
+
+    var summary = el("summary", {}, [
+      el("span", { "class": "method " + method, text: method.toUpperCase() }),
+      el("span", { "class": "path", text: path }),
+      el("span", { "class": "summary", text: op.summary || "" })
+    ]);
+    var details = el("details", { "class": "op", "data-search": (method + " " + path + " " + (op.summary || "")).toLowerCase() }, [summary, body]);
+    return details;
+  }
+
+  function render() {
+    document.getElementById("title").textContent = spec.info.title + " " + spec.info.version;
+    document.getElementById("description").textContent = spec.info.description || "";
+    var container = document.getElementById("operations");
+    container.replaceChildren();
+    Object.keys(spec.paths).sort().forEach(function (path) {
+      ["get", "post", "put", "patch", "delete"].forEach(function (method) {
+        var op = spec.paths[path][method];
+        if (op) container.appendChild(renderOperation(path, method, op));
+      });
+    });
+  }
+
+  document.getElementById("filter").addEventListener("input", function (e) {
+    var q = e.target.value.toLowerCase();
+    document.querySelectorAll("details.op").forEach(function (d) {
+      d.style.display = d.getAttribute("data-search").indexOf(q) >= 0 ? "" : "none";
+    });
+  });
+
+  fetch("/openapi.json").then(function (r) { return r.json(); }).then(function (s) {
+    spec = s;
+    render();
+  }).catch(function (err) {
+    document.getElementById("operations").textContent = "Failed to load /openapi.json: " + err;
+  });
+})();
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"go-spring/internal/model"
+	"go-spring/internal/store"
+)
+
+// Stats is the answer of GET /stats: the size and shape of the data set,
+// for dashboards and capacity planning
+type Stats struct {
+	Articles    int            `json:"articles"`
+	ByStatus    map[string]int `json:"by_status"`
+	ByCategory  map[string]int `json:"by_category"`
+	ByWorkspace map[string]int `json:"by_workspace"` // articles outside a workspace aren't counted
+	ByLanguage  map[string]int `json:"by_language"`  // of the articles' own text
+
+	ContentBytes        int64 `json:"content_bytes"`
+	AverageContentBytes int64 `json:"average_content_bytes"`
+	Attachments         int   `json:"attachments"`
+	AttachmentBytes     int64 `json:"attachment_bytes"` // every attachment in full, as quotas count them
+
+	Oldest      time.Time `json:"oldest,omitzero"` // created
+	Newest      time.Time `json:"newest,omitzero"`
+	LastUpdated time.Time `json:"last_updated,omitzero"`
+
+	Store StoreHealth `json:"store"`
+}
+
+// StoreHealth is how the data and blob stores are doing
+type StoreHealth struct {
+	Backend   string        `json:"backend"` // gob, sqlite or postgres
+	ReadOnly  bool          `json:"read_only"`
+	DataStore BreakerStatus `json:"data_store"`
+	BlobStore BreakerStatus `json:"blob_store"`
+}
+
+// GET /stats - Article counts, sizes and dates, and store health
+func (app *App) getStats(w http.ResponseWriter, r *http.Request) {
+	stats := Stats{
+		ByStatus:    map[string]int{model.StatusPublished: 0, model.StatusDraft: 0},
+		ByCategory:  map[string]int{},
+		ByWorkspace: map[string]int{},
+		ByLanguage:  map[string]int{},
+		Store: StoreHealth{
+			Backend:   storeBackend(app.cfg.Store),
+			ReadOnly:  app.dataStore != nil && store.IsReadOnly(app.dataStore),
+			DataStore: app.dataBreaker.status(),
+			BlobStore: app.blobBreaker.status(),
+		},
+	}
+	for _, article := range app.readArticles() {
+		stats.Articles++
+		if article.IsPublished() {
+			stats.ByStatus[model.StatusPublished]++
+		} else {
+			stats.ByStatus[article.Status]++
+		}
+		for _, category := range article.Categories {
+			stats.ByCategory[category]++
+		}
+		if article.Workspace != "" {
+			stats.ByWorkspace[article.Workspace]++
+		}
+		stats.ByLanguage[app.articleLanguage(article)]++
+		stats.ContentBytes += int64(len(article.Content))
+		for _, attachment := range article.Attachments {
+			stats.Attachments++
+			stats.AttachmentBytes += attachment.Size
+		}
+		if stats.Oldest.IsZero() || article.Created.Before(stats.Oldest) {
+			stats.Oldest = article.Created
+		}
+		if article.Created.After(stats.Newest) {
+			stats.Newest = article.Created
+		}
+		if article.Updated.After(stats.LastUpdated) {
+			stats.LastUpdated = article.Updated
+		}
+	}
+	if stats.Articles > 0 {
+		stats.AverageContentBytes = stats.ContentBytes / int64(stats.Articles)
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Statistics retrieved successfully", Data: stats})
+}
+
+// The kind of store at location, without the credentials a URL may carry
+func storeBackend(location string) string {
+	switch {
+	case strings.HasPrefix(location, "sqlite:"):
+		return "sqlite"
+	case strings.HasPrefix(location, "postgres://"), strings.HasPrefix(location, "postgresql://"):
+		return "postgres"
+	}
+	return "gob"
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"go-spring/internal/store"
+)
+
+// Store operations are bounded by STORE_TIMEOUT, so that a slow database or
+// bucket fails the request with a 504 instead of holding its goroutine, and
+// maybe articlesMutex, for ever. Data store operations get STORE_TIMEOUT in
+// all. Blobs can be large, so a blob operation times out when it makes no
+// progress for that long. Each store has a circuit breaker, see breaker.go.
+
+// Wraps context.DeadlineExceeded, which a data store reports itself
+var errStoreTimeout = fmt.Errorf("no progress for STORE_TIMEOUT: %w", context.DeadlineExceeded)
+
+// Run one data store operation with STORE_TIMEOUT, through the data store's
+// breaker
+func (app *App) callDataStore(operation func(ctx context.Context) error) error {
+	if err := app.dataBreaker.allow(time.Now()); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if app.cfg.StoreTimeout > 0 {
+		ctx, cancel = context.WithTimeoutCause(context.Background(), app.cfg.StoreTimeout, errStoreTimeout)
+	}
+	defer cancel()
+	err := operation(ctx)
+	result := breakerFailure
+	// Answers from a store that works
+	if err == nil || errors.Is(err, os.ErrNotExist) || errors.Is(err, store.ErrReadOnly) {
+		result = breakerSuccess
+	}
+	app.dataBreaker.end(result, err, time.Now())
+	return err
+}
+
+// blobOperation is a blob store call whose clock restarts with every chunk
+// read from or written to the blob
+type blobOperation struct {
+	parent    context.Context
+	ctx       context.Context
+	cancel    context.CancelCauseFunc
+	timeout   time.Duration // STORE_TIMEOUT
+	timer     *time.Timer   // nil without STORE_TIMEOUT
+	breaker   *circuitBreaker
+	sourceErr error // reading what Put stores failed, not the store
+}
+
+// Start a blob operation, unless the blob store's breaker is open
+func (app *App) startBlobOperation(parent context.Context) (*blobOperation, error) {
+	if err := app.blobBreaker.allow(time.Now()); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancelCause(parent)
+	op := &blobOperation{parent: parent, ctx: ctx, cancel: cancel, timeout: app.cfg.StoreTimeout, breaker: app.blobBreaker}
+	if app.cfg.StoreTimeout > 0 {
+		op.timer = time.AfterFunc(app.cfg.StoreTimeout, func() { cancel(errStoreTimeout) })
+	}
+	return op, nil
+}
+
+func (op *blobOperation) progress() {
+	if op.timer != nil {
+		op.timer.Reset(op.timeout)
+	}
+}
+
+func (op *blobOperation) end() {
+	if op.timer != nil {
+		op.timer.Stop()
+	}
+	op.cancel(nil)
+}
+
+// The store reports a canceled context; say why it was canceled
+func (op *blobOperation) result(err error) error {
+	if err != nil && errors.Is(context.Cause(op.ctx), errStoreTimeout) {
+		return fmt.Errorf("%w (%v)", errStoreTimeout, err)
+	}
+	return err
+}
+
+// Tell the breaker how the call to the store went. Failures of the caller's
+// own, an upload that broke off or a client that left, don't count.
+func (op *blobOperation) called(err error) error {
+	err = op.result(err)
+	switch {
+	case err == nil || errors.Is(err, store.ErrBlobNotFound):
+		op.breaker.end(breakerSuccess, nil, time.Now())
+	case op.sourceErr != nil || op.parent.Err() != nil:
+		op.breaker.end(breakerIgnore, err, time.Now())
+	default:
+		op.breaker.end(breakerFailure, err, time.Now())
+	}
+	return err
+}
+
+// blobReader is a blob, or the data going into one, that keeps its
+// operation alive while it moves
+type blobReader struct {
+	r      io.Reader
+	op     *blobOperation
+	source bool // what Put reads, rather than what Get returned
+}
+
+func (b *blobReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if n > 0 {
+		b.op.progress()
+	}
+	if err != nil && err != io.EOF {
+		if b.source {
+			b.op.sourceErr = err
+		}
+		err = b.op.result(err)
+	}
+	return n, err
+}
+
+// Ends the operation of a blob from getBlob
+func (b *blobReader) Close() error {
+	defer b.op.end()
+	if c, ok := b.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Store r as the blob key
+func (app *App) putBlob(ctx context.Context, key string, r io.Reader) error {
+	op, err := app.startBlobOperation(ctx)
+	if err != nil {
+		return err
+	}
+	defer op.end()
+	return op.called(app.blobStore.Put(op.ctx, key, &blobReader{r: r, op: op, source: true}))
+}
+
+// Open the blob key; closing it ends the operation
+func (app *App) getBlob(ctx context.Context, key string) (io.ReadCloser, error) {
+	op, err := app.startBlobOperation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := app.blobStore.Get(op.ctx, key)
+	if err = op.called(err); err != nil {
+		op.end()
+		return nil, err
+	}
+	op.progress()
+	return &blobReader{r: blob, op: op}, nil
+}
+
+// Open length bytes of the blob key from offset, like getBlob
+func (app *App) getBlobRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	op, err := app.startBlobOperation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := store.GetRange(op.ctx, app.blobStore, key, offset, length)
+	if err = op.called(err); err != nil {
+		op.end()
+		return nil, err
+	}
+	op.progress()
+	return &blobReader{r: blob, op: op}, nil
+}
+
+func (app *App) deleteBlob(ctx context.Context, key string) error {
+	op, err := app.startBlobOperation(ctx)
+	if err != nil {
+		return err
+	}
+	defer op.end()
+	return op.called(app.blobStore.Delete(op.ctx, key))
+}
+
+// List the blobs under prefix, if the blob store can
+func (app *App) listBlobs(ctx context.Context, prefix string) ([]store.BlobInfo, error) {
+	lister, ok := app.blobStore.(store.BlobLister)
+	if !ok {
+		return nil, fmt.Errorf("%T can't list blobs", app.blobStore)
+	}
+	op, err := app.startBlobOperation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer op.end()
+	blobs, err := lister.List(op.ctx, prefix)
+	return blobs, op.called(err)
+}
+
+// Answer a failed store operation: 504 when the store didn't answer within
+// STORE_TIMEOUT, 503 when it failed or its breaker is open
+func (app *App) writeStoreError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	var open *breakerOpenError
+	if errors.As(err, &open) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(open.retryAfter.Seconds()))))
+		app.writeError(w, r, http.StatusServiceUnavailable, CodeUnavailable, message)
+		return
+	}
+	log.Printf("Error: %s: %v", message, err)
+	if errors.Is(err, context.DeadlineExceeded) {
+		app.writeError(w, r, http.StatusGatewayTimeout, CodeStoreTimeout, message)
+		return
+	}
+	app.writeError(w, r, http.StatusServiceUnavailable, CodeUnavailable, message)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-spring/internal/config"
+	"go-spring/internal/markdown"
+	"go-spring/internal/model"
+	"go-spring/internal/summarize"
+)
+
+// A summarizer writes the description of an article from its content: on
+// demand at POST /articles/{id}/summarize, and with SUMMARIZE_ON_CREATE for
+// articles created without one. It is the first plugin's Summarizer, or
+// the chat completions endpoint of SUMMARIZER_URL. Confidential articles
+// are never sent to it, and imports keep the descriptions they have.
+
+type summarizeState struct {
+	// The summarizer of SUMMARIZER_URL; nil without one. Set by New.
+	configSummarizer summarize.Summarizer
+}
+
+func (app *App) initSummarizer(cfg config.Config) {
+	app.configSummarizer = nil
+	if cfg.SummarizerURL != "" {
+		app.configSummarizer = summarize.ChatCompletions{Endpoint: cfg.SummarizerURL, Model: cfg.SummarizerModel, APIKey: cfg.SummarizerAPIKey}
+	}
+}
+
+// The summarizer to use, nil if there is none
+func (app *App) activeSummarizer() summarize.Summarizer {
+	for _, p := range app.registeredPlugins() {
+		if p.Summarizer != nil {
+			return p.Summarizer
+		}
+	}
+	return app.configSummarizer
+}
+
+func (app *App) summarizerEnabled(cfg config.Config) bool {
+	return cfg.SummarizerURL != "" || app.activeSummarizer() != nil
+}
+
+// How long a summary is asked to be, and the most a description may be
+const (
+	summaryLength   = 300
+	summaryMaxRunes = 1000
+	summaryTimeout  = 90 * time.Second
+)
+
+// ErrSummarizerFailed is returned when the summarizer can't be reached or
+// gives no summary
+var ErrSummarizerFailed = errors.New("summarizer failed")
+
+// Write a description of an article's text with the active summarizer,
+// sanitized as descriptions are and cut to fit one
+func (app *App) summarizeArticle(ctx context.Context, title, content, lang string) (string, error) {
+	summarizer := app.activeSummarizer()
+	if summarizer == nil {
+		return "", fmt.Errorf("%w: none configured", ErrSummarizerFailed)
+	}
+	ctx, cancel := context.WithTimeout(ctx, summaryTimeout)
+	defer cancel()
+	desc, err := summarizer.Summarize(ctx, summarize.Request{Title: title, Content: content, Language: lang, MaxLength: summaryLength})
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrSummarizerFailed, err)
+	}
+	desc = strings.TrimSpace(markdown.Sanitize(desc, config.SanitizePlain))
+	if desc == "" {
+		return "", fmt.Errorf("%w: empty summary", ErrSummarizerFailed)
+	}
+	if runes := []rune(desc); len(runes) > summaryMaxRunes {
+		desc = strings.TrimSpace(string(runes[:summaryMaxRunes-1])) + "…"
+	}
+	return desc, nil
+}
+
+type autoSummaryKey struct{}
+
+// Let newArticle write the description of a request that has none, for
+// articles created one at a time
+func withAutoSummary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, autoSummaryKey{}, true)
+}
+
+// Fill in the description of a new article without one, when
+// SUMMARIZE_ON_CREATE is on and the article won't be confidential
+func (app *App) summarizeRequest(ctx context.Context, req *model.CreateArticleRequest) error {
+	if req.Desc != "" || !app.cfg.SummarizeOnCreate || ctx.Value(autoSummaryKey{}) == nil || app.activeSummarizer() == nil {
+		return nil
+	}
+	if ws, ok := app.findWorkspace(req.Workspace); req.Confidential || ok && ws.Confidential {
+		return nil // desc stays required
+	}
+	lang := req.Language
+	if lang == "" {
+		lang = app.articleLanguage(model.Article{Workspace: req.Workspace})
+	}
+	desc, err := app.summarizeArticle(ctx, req.Title, req.Content, lang)
+	if err != nil {
+		return err
+	}
+	req.Desc = desc
+	return nil
+}
+
+// POST /articles/{id}/summarize - Write the description again from the
+// content
+func (app *App) summarizeArticleDesc(w http.ResponseWriter, r *http.Request) {
+	id, err := app.articleRouteID(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid article ID")
+		return
+	}
+	article, ok := app.readArticle(id)
+	if !ok {
+		app.writeError(w, r, http.StatusNotFound, CodeArticleNotFound, "Article not found")
+		return
+	}
+	if article.Confidential {
+		app.writeError(w, r, http.StatusConflict, CodeConflict, "Confidential articles are not sent to the summarizer")
+		return
+	}
+
+	// The summarizer may take its time, so the article isn't locked
+	// meanwhile; the update goes through the service as any other
+	desc, err := app.summarizeArticle(r.Context(), article.Title, article.Content, app.articleLanguage(article))
+	if err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+	ctx, warnings := collectWarnings(r.Context())
+	article, err = app.articleService.Update(ctx, model.ArticleUpdate{ID: id, Desc: desc})
+	if err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Article summarized successfully", Data: article, Warnings: *warnings})
+}
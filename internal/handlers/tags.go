@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"go-spring/internal/config"
+	"go-spring/internal/keywords"
+	"go-spring/internal/markdown"
+	"go-spring/internal/model"
+)
+
+// Tags label an article with short lower-case words or phrases, which
+// editors set on create and update. With AUTO_TAGS, the keywords of the
+// text of a new or changed article that aren't among its tags come back
+// in the response as suggested_tags; AUTO_TAGS=apply also tags an article
+// created without tags with them.
+
+// The longest tag, and how many keywords are suggested
+const (
+	maxTagLength  = 50
+	suggestedTags = 5
+)
+
+// Trim, lower-case and deduplicate tags, in the order given; nil stays nil
+// for updates that leave the tags as they are
+func normalizeTags(tags []string) ([]string, error) {
+	if tags == nil {
+		return nil, nil
+	}
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(strings.ToLower(markdown.Sanitize(tag, config.SanitizePlain))), " ")
+		if tag == "" || slices.Contains(normalized, tag) {
+			continue
+		}
+		if len([]rune(tag)) > maxTagLength {
+			return nil, &ValidationError{Message: "Tag is too long: " + tag}
+		}
+		normalized = append(normalized, tag)
+	}
+	return normalized, nil
+}
+
+type suggestedTagsKey struct{}
+
+// Collect the tags suggested for the articles created or updated with the
+// returned context
+func collectSuggestedTags(ctx context.Context) (context.Context, *[]string) {
+	tags := &[]string{}
+	return context.WithValue(ctx, suggestedTagsKey{}, tags), tags
+}
+
+// The keywords of an article's text that aren't among its tags, if
+// AUTO_TAGS is on
+func (app *App) keywordTags(article model.Article) []string {
+	if app.cfg.AutoTags == config.AutoTagsOff {
+		return nil
+	}
+	text := article.Title + ".\n\n" + article.Desc + ".\n\n" + article.Content
+	var tags []string
+	for _, keyword := range keywords.Extract(text, app.articleLanguage(article), suggestedTags+len(article.Tags)) {
+		if len(tags) < suggestedTags && len([]rune(keyword)) <= maxTagLength && !slices.Contains(article.Tags, keyword) {
+			tags = append(tags, keyword)
+		}
+	}
+	return tags
+}
+
+// Suggest the keywords of an article as tags, if ctx collects suggestions
+func (app *App) suggestTags(ctx context.Context, article model.Article) {
+	if tags, ok := ctx.Value(suggestedTagsKey{}).(*[]string); ok {
+		*tags = app.keywordTags(article)
+	}
+}
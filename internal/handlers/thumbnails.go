@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"go-spring/internal/model"
+)
+
+// Limits for generated image variants
+const (
+	maxThumbnailSide   = 2048
+	maxSourceMegapixel = 50
+)
+
+// Image types that can be resized with the standard library decoders
+var resizableTypes = []string{"image/png", "image/jpeg", "image/gif"}
+
+// GET /attachments/{id}?w=400&h=300 - Download an attachment, optionally resized
+func (app *App) serveAttachmentVariant(w http.ResponseWriter, r *http.Request) {
+	attachmentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid attachment ID")
+		return
+	}
+
+	attachment, articleID, ok := app.findAttachmentByID(attachmentID)
+	if !ok {
+		app.writeError(w, r, http.StatusNotFound, CodeAttachmentNotFound, "Attachment not found")
+		return
+	}
+	if !app.allowAttachment(w, r, articleID, attachment) {
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("w") == "" && query.Get("h") == "" {
+		app.writeAttachment(w, r, attachment)
+		return
+	}
+
+	width, errW := parseDimension(query.Get("w"))
+	height, errH := parseDimension(query.Get("h"))
+	if errW != nil || errH != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, fmt.Sprintf("w and h must be between 1 and %d", maxThumbnailSide))
+		return
+	}
+	if !app.thumbnailSizeAllowed(width, height) {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter,
+			"w and h must be one of THUMBNAIL_SIZES: "+strings.Join(app.cfg.ThumbnailSizes, ", "))
+		return
+	}
+	if !slices.Contains(resizableTypes, attachment.ContentType) {
+		app.writeError(w, r, http.StatusUnsupportedMediaType, CodeUnsupportedType, "Attachment is not a resizable image")
+		return
+	}
+
+	// Serve a cached variant if we generated it before
+	variantKey := fmt.Sprintf("thumbs/%d/%dx%d", attachment.ID, width, height)
+	outputType := thumbnailType(attachment.ContentType)
+	if slices.Contains(attachment.Variants, variantKey) {
+		app.writeBlob(w, r, variantKey, outputType, attachment.Filename)
+		return
+	}
+
+	data, err := app.generateThumbnail(r.Context(), attachment, width, height)
+	if errors.Is(err, errThumbnailSource) {
+		app.writeStoreError(w, r, err, "Failed to read attachment")
+		return
+	}
+	if err != nil {
+		log.Printf("Error: Failed to resize attachment %d: %v", attachment.ID, err)
+		app.writeError(w, r, http.StatusUnprocessableEntity, CodeImageFailed, "Failed to resize image")
+		return
+	}
+
+	if err := app.putBlob(r.Context(), variantKey, bytes.NewReader(data)); err != nil {
+		log.Printf("Warning: Failed to cache thumbnail %s: %v", variantKey, err)
+	} else {
+		app.recordVariant(articleID, attachment.ID, variantKey)
+	}
+
+	w.Header().Set("Content-Type", outputType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(data)
+}
+
+// Width/height query parameters; 0 means "unconstrained"
+func parseDimension(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > maxThumbnailSide {
+		return 0, errors.New("invalid dimension")
+	}
+	return n, nil
+}
+
+// A THUMBNAIL_SIZES entry such as 400x300, with 0 for an unconstrained side
+func parseThumbnailSize(size string) (width, height int, ok bool) {
+	w, h, _ := strings.Cut(size, "x")
+	if w == "0" {
+		w = ""
+	}
+	if h == "0" {
+		h = ""
+	}
+	width, errW := parseDimension(w)
+	height, errH := parseDimension(h)
+	return width, height, errW == nil && errH == nil && width+height > 0
+}
+
+// Whether width x height is one of THUMBNAIL_SIZES. Only those are made, so
+// an attachment has no more variants than the list has sizes, whatever
+// clients ask for.
+func (app *App) thumbnailSizeAllowed(width, height int) bool {
+	for _, size := range app.cfg.ThumbnailSizes {
+		if w, h, ok := parseThumbnailSize(size); ok && w == width && h == height {
+			return true
+		}
+	}
+	return false
+}
+
+// GIFs are re-encoded as PNG (first frame only)
+func thumbnailType(contentType string) string {
+	if contentType == "image/jpeg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// Find an attachment by its global ID, returning the owning article ID
+func (app *App) findAttachmentByID(attachmentID int) (model.Attachment, int, bool) {
+	app.articlesMutex.RLock()
+	defer app.articlesMutex.RUnlock()
+
+	for _, article := range app.articles {
+		for _, attachment := range article.Attachments {
+			if attachment.ID == attachmentID {
+				return attachment, article.ID, true
+			}
+		}
+	}
+	return model.Attachment{}, 0, false
+}
+
+// Remember a cached variant so it is removed together with the attachment
+func (app *App) recordVariant(articleID, attachmentID int, key string) {
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+
+	i := app.findArticleIndex(articleID)
+	if i < 0 {
+		return
+	}
+	for j, attachment := range app.articles[i].Attachments {
+		if attachment.ID == attachmentID && !slices.Contains(attachment.Variants, key) {
+			// Readers may share the attachments, see readArticles
+			app.articles[i].Attachments = slices.Clone(app.articles[i].Attachments)
+			app.articles[i].Attachments[j].Variants = append(slices.Clone(attachment.Variants), key)
+
+			// Save to file
+			app.scheduleSave()
+			return
+		}
+	}
+}
+
+// Wraps the store's error when the original can't be read
+var errThumbnailSource = errors.New("read original")
+
+// Decode the original, scale it to fit within width x height and re-encode
+func (app *App) generateThumbnail(ctx context.Context, attachment model.Attachment, width, height int) ([]byte, error) {
+	blob, err := app.getBlob(ctx, attachment.Key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errThumbnailSource, err)
+	}
+	defer blob.Close()
+
+	original, err := io.ReadAll(blob)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errThumbnailSource, err)
+	}
+
+	// Refuse decompression bombs before allocating the full image
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(original))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxSourceMegapixel*1000*1000 {
+		return nil, errors.New("source image too large")
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(original))
+	if err != nil {
+		return nil, err
+	}
+	dst := resizeImage(src, width, height)
+
+	var buf bytes.Buffer
+	if thumbnailType(attachment.ContentType) == "image/jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	return buf.Bytes(), err
+}
+
+// Scale an image to fit within maxW x maxH (0 = unconstrained) keeping the
+// aspect ratio, using box filtering. Images are never enlarged.
+func resizeImage(src image.Image, maxW, maxH int) image.Image {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	scale := 1.0
+	if maxW > 0 && sw > maxW {
+		scale = float64(maxW) / float64(sw)
+	}
+	if maxH > 0 && float64(sh)*scale > float64(maxH) {
+		scale = float64(maxH) / float64(sh)
+	}
+	if scale >= 1 {
+		return src
+	}
+
+	dw, dh := max(int(float64(sw)*scale), 1), max(int(float64(sh)*scale), 1)
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := b.Min.Y+y*sh/dh, b.Min.Y+max((y+1)*sh/dh, y*sh/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := b.Min.X+x*sw/dw, b.Min.X+max((x+1)*sw/dw, x*sw/dw+1)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBA64Model.Convert(src.At(sx, sy)).(color.NRGBA64)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					bl += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			dst.Set(x, y, color.NRGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"testing"
+
+	"go-spring/internal/model"
+)
+
+// A width x height PNG
+func pngImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Upload a width x height PNG to article 1, returning its attachment ID
+func uploadImage(t *testing.T, srv *testServer, width, height int) int {
+	t.Helper()
+	var attachment model.Attachment
+	if resp := upload(t, srv.URL+"/articles/1/attachments", "image.png", pngImage(t, width, height), &attachment); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("upload: status %d", resp.StatusCode)
+	}
+	return attachment.ID
+}
+
+func TestThumbnails(t *testing.T) {
+	srv := newTestServer(t, 1)
+	srv.cfg.ThumbnailSizes = []string{"400x300", "200x0"}
+	id := uploadImage(t, srv, 800, 400)
+
+	// The sizes of THUMBNAIL_SIZES are made, keeping the aspect ratio
+	for query, want := range map[string]image.Point{"w=400&h=300": {400, 200}, "w=200": {200, 100}} {
+		resp, err := http.Get(fmt.Sprintf("%s/attachments/%d?%s", srv.URL, id, query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(resp.Body)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusOK || img.Bounds().Size() != want {
+			t.Errorf("%s: status %d, %v, size %v, want %v", query, resp.StatusCode, err, img, want)
+		}
+	}
+
+	// Other sizes are refused, so they can't fill the blob store
+	for _, query := range []string{"w=401&h=300", "w=400", "h=300", "w=1&h=1", "w=2048&h=2048", "w=0", "w=x"} {
+		if resp := call(t, "GET", fmt.Sprintf("%s/attachments/%d?%s", srv.URL, id, query), "", nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status %d", query, resp.StatusCode)
+		}
+	}
+	attachment, _ := srv.findAttachment(1, id)
+	if len(attachment.Variants) != 2 {
+		t.Errorf("variants %v", attachment.Variants)
+	}
+
+	// The original needs no size, and without sizes nothing is resized
+	if resp := call(t, "GET", fmt.Sprintf("%s/attachments/%d", srv.URL, id), "", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("original: status %d", resp.StatusCode)
+	}
+	srv.cfg.ThumbnailSizes = nil
+	if resp := call(t, "GET", fmt.Sprintf("%s/attachments/%d?w=400&h=300", srv.URL, id), "", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("without THUMBNAIL_SIZES: status %d", resp.StatusCode)
+	}
+}
+
+func TestParseThumbnailSize(t *testing.T) {
+	for size, want := range map[string][3]int{
+		"400x300": {400, 300, 1},
+		"200x0":   {200, 0, 1},
+		"0x150":   {0, 150, 1},
+		"0x0":     {0, 0, 0},
+		"400":     {400, 0, 1},
+		"3000x10": {0, 10, 0},
+		"axb":     {0, 0, 0},
+	} {
+		w, h, ok := parseThumbnailSize(size)
+		if ok != (want[2] == 1) || ok && (w != want[0] || h != want[1]) {
+			t.Errorf("%s: %d x %d, %v", size, w, h, ok)
+		}
+	}
+}
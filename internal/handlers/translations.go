@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"go-spring/internal/i18n"
+	"go-spring/internal/model"
+)
+
+// An article may have translations: its title, description and content in
+// other languages, kept with it under their language tags. The article's
+// own language is its language field, or the feed language of its
+// workspace or the server. Reads of one article pick the version by
+// Accept-Language, or ?lang=, and fall back to the article's own text; a
+// translation the article has changed since shows as outdated, and its
+// editors change translations as they change the article.
+
+// Body of PUT /articles/{id}/translations/{lang}
+type TranslationRequest struct {
+	Title   string `json:"title" validate:"required,max=200"`
+	Desc    string `json:"desc" validate:"required,max=1000"`
+	Content string `json:"content" validate:"required"`
+}
+
+// Translation states
+const (
+	TranslationCurrent  = "current"
+	TranslationOutdated = "outdated" // the article changed after it was saved
+	TranslationMissing  = "missing"
+)
+
+// TranslationStatus is the state of one language of an article
+type TranslationStatus struct {
+	Language string    `json:"language"`
+	State    string    `json:"state"`
+	Updated  time.Time `json:"updated,omitzero"`
+}
+
+// ArticleTranslations lists the translations of an article and how they
+// stand against its own text
+type ArticleTranslations struct {
+	ID           int                 `json:"id"`
+	Title        string              `json:"title"`
+	Language     string              `json:"language"`
+	Workspace    string              `json:"workspace,omitempty"`
+	Translations []TranslationStatus `json:"translations"`
+}
+
+// A lower-case language tag such as fi, pt-br or zh-hant-tw, and whether s
+// is one
+func languageTag(s string) (string, bool) {
+	tag := strings.ToLower(strings.TrimSpace(s))
+	if tag == "" || len(tag) > 35 {
+		return tag, false
+	}
+	for i, part := range strings.Split(tag, "-") {
+		if len(part) == 0 || len(part) > 8 || i == 0 && len(part) < 2 {
+			return tag, false
+		}
+		for _, c := range part {
+			if (c < 'a' || c > 'z') && (i == 0 || c < '0' || c > '9') {
+				return tag, false
+			}
+		}
+	}
+	return tag, true
+}
+
+// The language of an article's own text
+func (app *App) articleLanguage(article model.Article) string {
+	if article.Language != "" {
+		return article.Language
+	}
+	if ws, ok := app.findWorkspace(article.Workspace); ok && ws.FeedLanguage != "" {
+		return strings.ToLower(ws.FeedLanguage)
+	}
+	return strings.ToLower(app.cfg.FeedLanguage)
+}
+
+// Whether an article is in lang, or in a variant of it such as pt-br of pt
+func (app *App) inLanguage(lang string) func(model.Article) bool {
+	return func(article model.Article) bool {
+		own := app.articleLanguage(article)
+		return own == lang || strings.HasPrefix(own, lang+"-")
+	}
+}
+
+// Hash of the text a translation is made from
+func translationSource(article model.Article) string {
+	sum := sha256.Sum256([]byte(article.Title + "\x00" + article.Desc + "\x00" + article.Content))
+	return hex.EncodeToString(sum[:8])
+}
+
+// The article in the language the request asks for with ?lang= or
+// Accept-Language, if it has a translation in it; requests asking for no
+// language get the article's own text
+func localizedArticle(r *http.Request, article model.Article) model.Article {
+	if len(article.Translations) == 0 {
+		return article
+	}
+	lang := r.URL.Query().Get("lang")
+	if lang == "" {
+		header := r.Header.Get("Accept-Language")
+		if header == "" {
+			return article
+		}
+		lang = i18n.Negotiate(header, slices.Collect(maps.Keys(article.Translations)))
+	}
+	return translatedArticle(article, strings.ToLower(lang))
+}
+
+// The article with the text of its translation in lang, if it has one
+func translatedArticle(article model.Article, lang string) model.Article {
+	if t, ok := article.Translations[lang]; ok {
+		article.Title, article.Desc, article.Content = t.Title, t.Desc, t.Content
+		article.Language = lang
+	}
+	return article
+}
+
+// The translation states of an article, in language order; with lang, that
+// language's alone, missing if the article has no translation in it
+func (app *App) translationStates(article model.Article, lang string) []TranslationStatus {
+	source := translationSource(article)
+	states := []TranslationStatus{}
+	for _, tag := range slices.Sorted(maps.Keys(article.Translations)) {
+		if lang != "" && tag != lang {
+			continue
+		}
+		t := article.Translations[tag]
+		state := TranslationCurrent
+		if t.SourceHash != source {
+			state = TranslationOutdated
+		}
+		states = append(states, TranslationStatus{Language: tag, State: state, Updated: t.Updated})
+	}
+	if lang != "" && len(states) == 0 && lang != app.articleLanguage(article) {
+		states = append(states, TranslationStatus{Language: lang, State: TranslationMissing})
+	}
+	return states
+}
+
+func (app *App) articleTranslations(article model.Article, lang string) ArticleTranslations {
+	return ArticleTranslations{
+		ID:           article.ID,
+		Title:        article.Title,
+		Language:     app.articleLanguage(article),
+		Workspace:    article.Workspace,
+		Translations: app.translationStates(article, lang),
+	}
+}
+
+// GET /articles/{id}/translations - The translations of an article and
+// whether they are up to date
+func (app *App) getTranslations(w http.ResponseWriter, r *http.Request) {
+	id, err := app.articleRouteID(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid article ID")
+		return
+	}
+	article, ok := app.requestArticle(r, id)
+	if !ok {
+		app.writeError(w, r, http.StatusNotFound, CodeArticleNotFound, "Article not found")
+		return
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Translations retrieved successfully", Data: app.articleTranslations(article, "")})
+}
+
+// GET /admin/translations?language=fi - The translation states of every
+// article, or of those that lack an up-to-date translation in a language
+func (app *App) listTranslations(w http.ResponseWriter, r *http.Request) {
+	lang := r.URL.Query().Get("language")
+	if lang != "" {
+		var ok bool
+		if lang, ok = languageTag(lang); !ok {
+			app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "Invalid language tag")
+			return
+		}
+	}
+	list := []ArticleTranslations{}
+	for _, article := range app.readArticles() {
+		entry := app.articleTranslations(article, lang)
+		if lang != "" && (len(entry.Translations) == 0 || entry.Translations[0].State == TranslationCurrent) {
+			continue // written in that language, or translated
+		}
+		list = append(list, entry)
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Translations retrieved successfully", Data: list})
+}
+
+// PUT /articles/{id}/translations/{lang} - Add or replace a translation
+func (app *App) putTranslation(w http.ResponseWriter, r *http.Request) {
+	id, err := app.articleRouteID(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid article ID")
+		return
+	}
+	lang, ok := languageTag(mux.Vars(r)["lang"])
+	if !ok {
+		app.writeError(w, r, http.StatusBadRequest, CodeValidationFailed, "Invalid language tag")
+		return
+	}
+	var req TranslationRequest
+	if err := decodeBody(r, &req); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	ctx, warnings := collectWarnings(r.Context())
+	app.sanitizeArticle(&req.Title, &req.Desc, &req.Content)
+	app.scanPII(ctx, &req.Title, &req.Desc, &req.Content)
+	if err := validateRequest(req); err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+
+	// The workspace's language is looked up under a read lock of its own
+	if article, ok := app.readArticle(id); ok && lang == app.articleLanguage(article) {
+		app.writeError(w, r, http.StatusBadRequest, CodeValidationFailed, "Translation is in the article's own language: "+lang)
+		return
+	}
+
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+
+	i := app.findArticleIndex(id)
+	if i < 0 {
+		app.writeError(w, r, http.StatusNotFound, CodeArticleNotFound, "Article not found")
+		return
+	}
+	_, exists := app.articles[i].Translations[lang]
+	translations := maps.Clone(app.articles[i].Translations) // readers may hold the old map
+	if translations == nil {
+		translations = map[string]model.Translation{}
+	}
+	now := time.Now()
+	translations[lang] = model.Translation{
+		Title:      req.Title,
+		Desc:       req.Desc,
+		Content:    req.Content,
+		Updated:    now,
+		SourceHash: translationSource(app.articles[i]),
+	}
+	app.articles[i].Translations = translations
+	app.articles[i].Updated = now
+	app.publishArticleEvent(ctx, EventArticleUpdated, app.articles[i])
+
+	// Save to file
+	app.scheduleSave()
+
+	status := http.StatusOK
+	if !exists {
+		status = http.StatusCreated
+	}
+	app.writeResponse(w, r, status, Response{
+		Message:  "Translation saved successfully",
+		Data:     translatedArticle(app.articles[i], lang),
+		Warnings: *warnings,
+	})
+}
+
+// DELETE /articles/{id}/translations/{lang} - Delete a translation
+func (app *App) deleteTranslation(w http.ResponseWriter, r *http.Request) {
+	id, err := app.articleRouteID(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid article ID")
+		return
+	}
+	lang, _ := languageTag(mux.Vars(r)["lang"])
+
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+
+	i := app.findArticleIndex(id)
+	if i < 0 {
+		app.writeError(w, r, http.StatusNotFound, CodeArticleNotFound, "Article not found")
+		return
+	}
+	if _, ok := app.articles[i].Translations[lang]; !ok {
+		app.writeError(w, r, http.StatusNotFound, CodeTranslationNotFound, "Translation not found")
+		return
+	}
+	translations := maps.Clone(app.articles[i].Translations)
+	delete(translations, lang)
+	if len(translations) == 0 {
+		translations = nil
+	}
+	app.articles[i].Translations = translations
+	app.articles[i].Updated = time.Now()
+	app.publishArticleEvent(r.Context(), EventArticleUpdated, app.articles[i])
+
+	// Save to file
+	app.scheduleSave()
+
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Translation deleted successfully"})
+}
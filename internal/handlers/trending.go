@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-spring/internal/model"
+)
+
+// GET /articles/trending ranks published articles by their recent views,
+// each weighed down by its age with a half-life of TRENDING_HALF_LIFE, see
+// analytics.Counter.Scores. Views are the only signal the server records;
+// the ranking is recomputed in the background every TRENDING_INTERVAL
+// rather than per request, and the route serves the last one.
+
+// Most articles in the ranking, and the default of ?limit
+const (
+	maxTrending     = 100
+	defaultTrending = 10
+)
+
+// TrendingArticle is an article of GET /articles/trending and its score
+type TrendingArticle struct {
+	Article model.Article `json:"article"`
+	Score   float64       `json:"score"`
+}
+
+type trendingState struct {
+	// The last ranking, by ID and score; the articles are looked up when
+	// served, so the ranking never shows an outdated article
+	trending struct {
+		mutex    sync.Mutex
+		ranked   []rankedArticle
+		computed time.Time
+	}
+}
+
+type rankedArticle struct {
+	id    int
+	score float64
+}
+
+// Rank the published articles by score
+func (app *App) recomputeTrending(now time.Time) {
+	halfLife := app.cfg.TrendingHalfLife
+	if halfLife <= 0 {
+		halfLife = 24 * time.Hour
+	}
+	scores := app.viewCounter.Scores(now, halfLife)
+	var ranked []rankedArticle
+	for _, article := range app.readArticles() {
+		if score := scores[article.ID]; score > 0 && article.IsPublished() {
+			ranked = append(ranked, rankedArticle{article.ID, score})
+		}
+	}
+	slices.SortFunc(ranked, func(a, b rankedArticle) int {
+		return cmp.Or(cmp.Compare(b.score, a.score), cmp.Compare(a.id, b.id))
+	})
+
+	app.trending.mutex.Lock()
+	defer app.trending.mutex.Unlock()
+	app.trending.ranked = ranked[:min(len(ranked), maxTrending)]
+	app.trending.computed = now
+}
+
+// Recompute the ranking now and every TRENDING_INTERVAL until ctx is done
+func (app *App) runTrending(ctx context.Context) {
+	app.recomputeTrending(time.Now())
+	ticker := time.NewTicker(app.cfg.TrendingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			app.recomputeTrending(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// GET /articles/trending - Published articles with the most recent views
+func (app *App) getTrendingArticles(w http.ResponseWriter, r *http.Request) {
+	limit := defaultTrending
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTrending {
+			app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, fmt.Sprintf("limit must be between 1 and %d", maxTrending))
+			return
+		}
+		limit = n
+	}
+
+	app.trending.mutex.Lock()
+	ranked, computed := app.trending.ranked, app.trending.computed
+	app.trending.mutex.Unlock()
+
+	list := []TrendingArticle{}
+	mayRead := app.requestReader(r)
+	for _, entry := range ranked {
+		if len(list) == limit {
+			break
+		}
+		// Deleted or unpublished since the ranking was computed
+		if article, ok := app.readArticle(entry.id); ok && article.IsPublished() {
+			list = append(list, TrendingArticle{withholdContent([]model.Article{article}, mayRead)[0], entry.score})
+		}
+	}
+	if !computed.IsZero() {
+		w.Header().Set("Last-Modified", computed.UTC().Format(http.TimeFormat))
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Trending articles retrieved successfully", Data: list})
+}
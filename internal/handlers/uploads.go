@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+
+	"go-spring/internal/model"
+)
+
+// Resumable uploads, for attachments and article content too large to send
+// in one request over a flaky connection. A client opens a session with the
+// total size, and optionally the SHA-256, of the file, then PATCHes chunks
+// at their Upload-Offset. After a broken chunk it asks for the offset the
+// server has and carries on from there. The chunk that completes the upload
+// checks the SHA-256 and creates the attachment or replaces the content.
+//
+// Chunks wait in the blob store as uploads/<id>/<n>. Sessions live in the
+// memory of the node that opened them, and expire UPLOAD_SESSION_TTL after
+// their last chunk, when their chunks are deleted.
+
+// Upload kinds: what the uploaded file becomes
+const (
+	UploadAttachment = "attachment"
+	UploadContent    = "content" // replaces the article's content
+)
+
+// UploadRequest opens an upload session
+type UploadRequest struct {
+	Kind     string `json:"kind" validate:"required,oneof=attachment content"`
+	Filename string `json:"filename" validate:"max=255"` // of an attachment
+	Size     int64  `json:"size" validate:"required,min=1"`
+	SHA256   string `json:"sha256,omitempty" validate:"min=64,max=64"` // hex, checked on completion
+}
+
+// UploadSession is the state of a resumable upload
+type UploadSession struct {
+	ID        string          `json:"id"`
+	ArticleID model.ArticleID `json:"article_id"`
+	Kind      string          `json:"kind"`
+	Filename  string          `json:"filename,omitempty"`
+	Size      int64           `json:"size"`
+	Offset    int64           `json:"offset"` // bytes received so far
+	SHA256    string          `json:"sha256,omitempty"`
+	Expires   time.Time       `json:"expires"`
+}
+
+type uploadSession struct {
+	mutex   sync.Mutex // held while a chunk is stored or the upload completes
+	session UploadSession
+	parts   int       // chunks stored
+	hash    hash.Hash // of the bytes received
+	done    bool      // completed or abandoned
+
+	expires atomic.Int64 // session.Expires in Unix nanoseconds, read without the mutex
+}
+
+func (u *uploadSession) setExpires(t time.Time) {
+	u.session.Expires = t
+	u.expires.Store(t.UnixNano())
+}
+
+func (u *uploadSession) expired(now time.Time) bool {
+	return now.UnixNano() > u.expires.Load()
+}
+
+type uploadsState struct {
+	uploadsMutex     sync.Mutex
+	uploads          map[string]*uploadSession
+	uploadsLastSweep time.Time
+}
+
+func uploadPartKey(id string, part int) string {
+	return fmt.Sprintf("uploads/%s/%d", id, part)
+}
+
+// Delete the stored chunks of an upload
+func (app *App) deleteUploadParts(id string, parts int) {
+	for part := range parts {
+		if err := app.deleteBlob(context.Background(), uploadPartKey(id, part)); err != nil {
+			log.Printf("Error: deleting upload chunk %s: %v", uploadPartKey(id, part), err)
+		}
+	}
+}
+
+// The upload id of the article with articleID, after dropping the expired
+// ones at most once a minute
+func (app *App) findUpload(articleID int, id string) (*uploadSession, bool) {
+	app.uploadsMutex.Lock()
+	defer app.uploadsMutex.Unlock()
+	now := time.Now()
+	if now.Sub(app.uploadsLastSweep) > time.Minute {
+		for key, upload := range app.uploads {
+			if upload.expired(now) {
+				delete(app.uploads, key)
+				go app.abandonUpload(upload)
+			}
+		}
+		app.uploadsLastSweep = now
+	}
+	upload, ok := app.uploads[id]
+	if !ok || upload.expired(now) || int(upload.session.ArticleID) != articleID {
+		return nil, false
+	}
+	return upload, true
+}
+
+// Drop an upload's chunks, once the chunk being stored, if any, is
+func (app *App) abandonUpload(u *uploadSession) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if !u.done {
+		u.done = true
+		app.deleteUploadParts(u.session.ID, u.parts)
+	}
+}
+
+func (app *App) removeUpload(id string) {
+	app.uploadsMutex.Lock()
+	delete(app.uploads, id)
+	app.uploadsMutex.Unlock()
+}
+
+// The upload of an upload route; writes the error if the article ID is
+// invalid or the upload unknown
+func (app *App) uploadFromRoute(w http.ResponseWriter, r *http.Request) (*uploadSession, bool) {
+	id, err := app.articleRouteID(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid article ID")
+		return nil, false
+	}
+	upload, ok := app.findUpload(id, mux.Vars(r)["uploadId"])
+	if !ok {
+		app.writeError(w, r, http.StatusNotFound, CodeUploadNotFound, "Upload not found")
+		return nil, false
+	}
+	return upload, true
+}
+
+func (app *App) writeUpload(w http.ResponseWriter, r *http.Request, status int, message string, session UploadSession) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(session.Size, 10))
+	w.Header().Set("Upload-Expires", session.Expires.UTC().Format(http.TimeFormat))
+	app.writeResponse(w, r, status, Response{Message: message, Data: session})
+}
+
+// POST /articles/{id}/uploads - Open a resumable upload
+func (app *App) createUpload(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := app.articleRouteID(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid article ID")
+		return
+	}
+	var req UploadRequest
+	if err := decodeBody(r, &req); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	if err := validateRequest(req); err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+	if _, err := hex.DecodeString(req.SHA256); err != nil {
+		app.writeArticleError(w, r, &ValidationError{Message: "sha256 must be hexadecimal"})
+		return
+	}
+	if req.Size > app.cfg.AttachmentMaxBytes {
+		app.writeError(w, r, http.StatusRequestEntityTooLarge, CodeFileTooLarge, "File too large")
+		return
+	}
+	if _, ok := app.readArticle(id); !ok {
+		app.writeError(w, r, http.StatusNotFound, CodeArticleNotFound, "Article not found")
+		return
+	}
+
+	upload := &uploadSession{
+		session: UploadSession{
+			ID:        rand.Text(),
+			ArticleID: model.ArticleID(id),
+			Kind:      req.Kind,
+			Filename:  req.Filename,
+			Size:      req.Size,
+			SHA256:    strings.ToLower(req.SHA256),
+		},
+		hash: sha256.New(),
+	}
+	upload.setExpires(time.Now().Add(app.cfg.UploadSessionTTL))
+	app.uploadsMutex.Lock()
+	app.uploads[upload.session.ID] = upload
+	app.uploadsMutex.Unlock()
+
+	w.Header().Set("Location", fmt.Sprintf("/articles/%s/uploads/%s", upload.session.ArticleID, upload.session.ID))
+	app.writeUpload(w, r, http.StatusCreated, "Upload started", upload.session)
+}
+
+// GET /articles/{id}/uploads/{uploadId} - Show how much of an upload has arrived
+func (app *App) getUpload(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	upload, ok := app.uploadFromRoute(w, r)
+	if !ok {
+		return
+	}
+	if !upload.mutex.TryLock() {
+		app.writeError(w, r, http.StatusConflict, CodeRequestInProgress, "A chunk of this upload is still being stored")
+		return
+	}
+	session := upload.session
+	upload.mutex.Unlock()
+	app.writeUpload(w, r, http.StatusOK, "Upload retrieved successfully", session)
+}
+
+// PATCH /articles/{id}/uploads/{uploadId} - Store the next chunk of an
+// upload, and complete it with the last one
+func (app *App) patchUpload(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	upload, ok := app.uploadFromRoute(w, r)
+	if !ok {
+		return
+	}
+	if !upload.mutex.TryLock() {
+		app.writeError(w, r, http.StatusConflict, CodeRequestInProgress, "A chunk of this upload is still being stored")
+		return
+	}
+	defer upload.mutex.Unlock()
+	if upload.done {
+		app.writeError(w, r, http.StatusNotFound, CodeUploadNotFound, "Upload not found")
+		return
+	}
+	session := &upload.session
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset != session.Offset {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+		app.writeError(w, r, http.StatusConflict, CodeConflict, fmt.Sprintf("Upload-Offset does not match the upload: expected %d", session.Offset))
+		return
+	}
+	checksum, err := chunkChecksum(r.Header.Get("Upload-Checksum"))
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeBadRequest, err.Error())
+		return
+	}
+
+	// Store at most the rest of the file; a chunk that doesn't fit is refused
+	// whole. The chunk counts only once it is stored and its checksum
+	// matches, so the hash is rolled back otherwise.
+	state, _ := upload.hash.(encoding.BinaryMarshaler).MarshalBinary()
+	chunkHash := sha256.New()
+	body := &countingReader{r: io.TeeReader(io.LimitReader(r.Body, session.Size-session.Offset+1), io.MultiWriter(upload.hash, chunkHash))}
+	key := uploadPartKey(session.ID, upload.parts)
+	err = app.putBlob(r.Context(), key, body)
+	switch {
+	case err == nil && session.Offset+body.n > session.Size:
+		err = &attachmentError{http.StatusRequestEntityTooLarge, CodeFileTooLarge, fmt.Sprintf("Chunk too large: %d bytes left", session.Size-session.Offset)}
+	case err == nil && checksum != nil && string(checksum) != string(chunkHash.Sum(nil)):
+		err = &attachmentError{http.StatusUnprocessableEntity, CodeChecksumMismatch, "Chunk checksum mismatch"}
+	}
+	if err != nil {
+		upload.hash.(encoding.BinaryUnmarshaler).UnmarshalBinary(state)
+		app.deleteBlob(context.Background(), key)
+		app.writeAttachmentError(w, r, err)
+		return
+	}
+	if body.n > 0 {
+		upload.parts++
+		session.Offset += body.n
+	}
+	upload.setExpires(time.Now().Add(app.cfg.UploadSessionTTL))
+
+	if session.Offset < session.Size {
+		app.writeUpload(w, r, http.StatusOK, "Chunk stored", *session)
+		return
+	}
+	app.completeUpload(w, r, upload)
+}
+
+// Parse an Upload-Checksum header, "sha256 <base64 digest>", if there is one
+func chunkChecksum(header string) ([]byte, error) {
+	if header == "" {
+		return nil, nil
+	}
+	algorithm, digest, _ := strings.Cut(header, " ")
+	if algorithm != "sha256" {
+		return nil, errors.New("Upload-Checksum must be sha256 <base64 digest>")
+	}
+	sum, err := base64.StdEncoding.DecodeString(digest)
+	if err != nil || len(sum) != sha256.Size {
+		return nil, errors.New("Upload-Checksum must be sha256 <base64 digest>")
+	}
+	return sum, nil
+}
+
+// Turn a fully received upload into what it was for; called with the
+// upload's mutex held. Whatever the outcome, the upload is done.
+func (app *App) completeUpload(w http.ResponseWriter, r *http.Request, upload *uploadSession) {
+	session := upload.session
+	upload.done = true
+	app.removeUpload(session.ID)
+	defer app.deleteUploadParts(session.ID, upload.parts)
+
+	if sum := hex.EncodeToString(upload.hash.Sum(nil)); session.SHA256 != "" && sum != session.SHA256 {
+		app.writeError(w, r, http.StatusUnprocessableEntity, CodeChecksumMismatch, "Upload checksum mismatch: the SHA-256 is "+sum)
+		return
+	}
+	parts := &uploadReader{app: app, ctx: r.Context(), id: session.ID, parts: upload.parts}
+	defer parts.Close()
+
+	if session.Kind == UploadAttachment {
+		attachment, err := app.storeAttachment(r.Context(), int(session.ArticleID), session.Filename, parts, app.cfg.AttachmentTypes, nil)
+		if err != nil {
+			app.writeAttachmentError(w, r, err)
+			return
+		}
+		app.enqueueThumbnails(int(session.ArticleID), attachment)
+		app.writeResponse(w, r, http.StatusCreated, Response{Message: "Attachment uploaded successfully", Data: attachment})
+		return
+	}
+
+	content, err := io.ReadAll(parts)
+	if err != nil {
+		app.writeStoreError(w, r, err, "Failed to read upload")
+		return
+	}
+	if !utf8.Valid(content) {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidFile, "Content must be UTF-8 text")
+		return
+	}
+	article, err := app.articleService.Update(r.Context(), model.ArticleUpdate{ID: int(session.ArticleID), Content: string(content)})
+	if err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Article updated successfully", Data: article})
+}
+
+// DELETE /articles/{id}/uploads/{uploadId} - Abandon an upload
+func (app *App) deleteUpload(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	upload, ok := app.uploadFromRoute(w, r)
+	if !ok {
+		return
+	}
+	if !upload.mutex.TryLock() {
+		app.writeError(w, r, http.StatusConflict, CodeRequestInProgress, "A chunk of this upload is still being stored")
+		return
+	}
+	upload.mutex.Unlock()
+	app.removeUpload(upload.session.ID)
+	app.abandonUpload(upload)
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Upload deleted successfully"})
+}
+
+// uploadReader reads the chunks of an upload one after the other
+type uploadReader struct {
+	app   *App
+	ctx   context.Context
+	id    string
+	parts int
+	next  int           // the part to open next
+	part  io.ReadCloser // the open part, if any
+}
+
+func (u *uploadReader) Read(p []byte) (int, error) {
+	for {
+		if u.part == nil {
+			if u.next == u.parts {
+				return 0, io.EOF
+			}
+			part, err := u.app.getBlob(u.ctx, uploadPartKey(u.id, u.next))
+			if err != nil {
+				return 0, err
+			}
+			u.part, u.next = part, u.next+1
+		}
+		n, err := u.part.Read(p)
+		if err == io.EOF {
+			u.part.Close()
+			u.part = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (u *uploadReader) Close() error {
+	if u.part != nil {
+		return u.part.Close()
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-spring/internal/model"
+)
+
+type usersState struct {
+	// Users are stored in the data file next to the articles; guarded by articlesMutex
+	users []model.User
+}
+
+var usernameRe = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+// Add a user; the caller saves the data file
+func (app *App) AddUser(username, password, role string) (model.User, error) {
+	if !usernameRe.MatchString(username) {
+		return model.User{}, errors.New("username may contain letters, digits, dots, dashes and underscores (max 64)")
+	}
+	if role != model.RoleAdmin && role != model.RoleEditor {
+		return model.User{}, fmt.Errorf("role must be %s or %s", model.RoleAdmin, model.RoleEditor)
+	}
+	if len(password) < 8 {
+		return model.User{}, errors.New("password must be at least 8 characters")
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+	for _, u := range app.users {
+		if strings.EqualFold(u.Username, username) {
+			return model.User{}, fmt.Errorf("user %q already exists", u.Username)
+		}
+	}
+	user := model.User{Username: username, Role: role, PasswordHash: hash, Created: time.Now()}
+	app.users = append(app.users, user)
+	return user, nil
+}
+
+// Check a username and password, returning the user on success
+func (app *App) authenticate(username, password string) (model.User, bool) {
+	app.articlesMutex.RLock()
+	var user model.User
+	found := false
+	for _, u := range app.users {
+		if strings.EqualFold(u.Username, username) {
+			user, found = u, true
+		}
+	}
+	app.articlesMutex.RUnlock()
+
+	if !found {
+		checkPassword(dummyPasswordHash(), password) // take the same time as a wrong password
+		return model.User{}, false
+	}
+	return user, checkPassword(user.PasswordHash, password)
+}
+
+func (app *App) hasUsers() bool {
+	app.articlesMutex.RLock()
+	defer app.articlesMutex.RUnlock()
+	return len(app.users) > 0
+}
+
+// Passwords are stored as pbkdf2-sha256$iterations$salt$key (base64)
+const passwordIterations = 600_000
+
+// Hashed on first use so startup doesn't pay for it
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := hashPassword("not a real password")
+	return hash
+})
+
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, 32)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+func checkPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	enc := base64.RawStdEncoding
+	salt, err1 := enc.DecodeString(parts[2])
+	want, err2 := enc.DecodeString(parts[3])
+	if err != nil || err1 != nil || err2 != nil {
+		return false
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	return err == nil && subtle.ConstantTimeCompare(key, want) == 1
+}
+
+// UserSummary is a user as GET /admin/users lists them, without the
+// password hash
+type UserSummary struct {
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	Created  time.Time `json:"created"`
+	Email    string    `json:"email,omitempty"`
+}
+
+// GET /admin/users - List the users in the order they were added
+func (app *App) listUsers(w http.ResponseWriter, r *http.Request) {
+	list := []UserSummary{}
+	for _, u := range app.Users() {
+		list = append(list, UserSummary{Username: u.Username, Role: u.Role, Created: u.Created, Email: u.Email})
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Users retrieved successfully", Data: list})
+}
+
+// Require HTTP Basic credentials of an admin user. Until the first user is
+// added the admin routes stay open, as they were before users existed.
+func (app *App) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !app.hasUsers() {
+			next(w, r)
+			return
+		}
+		user, ok := app.basicAuthUser(w, r)
+		if !ok {
+			return
+		}
+		if user.Role != model.RoleAdmin {
+			app.writeError(w, r, http.StatusForbidden, CodeForbidden, "Admin role required")
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
+	}
+}
+
+// Require HTTP Basic credentials of any user; the handler finds the user
+// with currentUser
+func (app *App) requireUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := app.basicAuthUser(w, r)
+		if !ok {
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
+	}
+}
+
+type userKey struct{}
+
+// The user authenticated by requireUser or requireAdmin
+func currentUser(r *http.Request) (model.User, bool) {
+	user, ok := r.Context().Value(userKey{}).(model.User)
+	return user, ok
+}
+
+// A request's Basic credentials as checked. Hashing a password takes
+// hundreds of milliseconds on purpose, so a request checks them once,
+// however many stages and handlers ask who sent it.
+type requestAuth struct {
+	once sync.Once
+	user model.User
+	ok   bool
+}
+
+type authKey struct{}
+
+// Give each request a place to keep its checked credentials
+func withAuthCache(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authKey{}, &requestAuth{})))
+	})
+}
+
+// The user of the request's Basic credentials, checked the first time
+// they're asked for; false without credentials, with wrong ones or while
+// there are no users
+func (app *App) checkBasicAuth(r *http.Request) (model.User, bool) {
+	username, password, ok := r.BasicAuth()
+	if !ok || !app.hasUsers() {
+		return model.User{}, false
+	}
+	auth, cached := r.Context().Value(authKey{}).(*requestAuth)
+	if !cached {
+		return app.authenticate(username, password)
+	}
+	auth.once.Do(func() { auth.user, auth.ok = app.authenticate(username, password) })
+	return auth.user, auth.ok
+}
+
+// Check the Basic credentials of the request, answering 401 if they're
+// missing or wrong. A signed request was authenticated already.
+func (app *App) basicAuthUser(w http.ResponseWriter, r *http.Request) (model.User, bool) {
+	if user, ok := currentUser(r); ok {
+		return user, true
+	}
+	if _, _, ok := r.BasicAuth(); !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="go-spring admin", charset="UTF-8"`)
+		app.writeError(w, r, http.StatusUnauthorized, CodeAuthenticationRequired, "Authentication required")
+		return model.User{}, false
+	}
+	user, ok := app.checkBasicAuth(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="go-spring admin", charset="UTF-8"`)
+		app.writeError(w, r, http.StatusUnauthorized, CodeInvalidCredentials, "Invalid username or password")
+		return model.User{}, false
+	}
+	return user, true
+}
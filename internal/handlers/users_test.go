@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"go-spring/internal/model"
+)
+
+// Credentials are checked only when something needs them, and at most once
+// a request
+func TestRequestCredentialsCheckedOnce(t *testing.T) {
+	srv := newTestServer(t, 2)
+	if _, err := srv.AddUser("alice", "correct horse", model.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	setPassword := func(password string) {
+		hash, err := hashPassword(password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.articlesMutex.Lock()
+		users := slices.Clone(srv.users)
+		users[0].PasswordHash = hash
+		srv.users = users
+		srv.articlesMutex.Unlock()
+	}
+
+	r := httptest.NewRequest("GET", "/articles", nil)
+	r = r.WithContext(context.WithValue(r.Context(), authKey{}, &requestAuth{}))
+	r.SetBasicAuth("alice", "correct horse")
+
+	// Public articles don't need them: changing the password now shows
+	// they haven't been checked yet
+	withholdContent(srv.readArticles(), srv.requestReader(r))
+	setPassword("battery staple")
+	if _, ok := srv.requestUser(r); ok {
+		t.Fatal("old password accepted: the credentials were checked before they were needed")
+	}
+	// The answer stands for the rest of the request
+	setPassword("correct horse")
+	if _, ok := srv.requestUser(r); ok {
+		t.Error("credentials checked again")
+	}
+	if srv.requestShowsHeld(r) {
+		t.Error("held articles shown to wrong credentials")
+	}
+
+	// Without users there is nothing to check them against
+	open := newTestServer(t, 1)
+	r = httptest.NewRequest("GET", "/articles", nil)
+	r.SetBasicAuth("alice", "correct horse")
+	if _, ok := open.requestUser(r); ok {
+		t.Error("credentials accepted without users")
+	}
+}
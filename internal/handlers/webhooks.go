@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"slices"
+
+	"go-spring/internal/config"
+	"go-spring/internal/ids"
+	"go-spring/internal/jobs"
+	"go-spring/internal/webhooks"
+)
+
+// Webhook subscriptions (WEBHOOKS_FILE). Every article change an endpoint
+// subscribed to is queued as a job, which posts it signed with the
+// subscription's secret and is retried like any other job. Replicas
+// deliver nothing, or every change would arrive once per node.
+
+type webhooksState struct {
+	webhookSubscriptions []webhooks.Subscription
+}
+
+// Kind of the job that delivers one change to one subscription
+const jobWebhook = "webhook"
+
+// Events a subscription can ask for
+var webhookEvents = []string{EventArticleCreated, EventArticleUpdated, EventArticleDeleted}
+
+// Payload of a webhook job. It names the subscription by URL rather than
+// carrying its secret, which stays out of the job file.
+type webhookJob struct {
+	URL      string            `json:"url"`
+	Delivery webhooks.Delivery `json:"delivery"`
+}
+
+// Load the subscriptions of WEBHOOKS_FILE and register the delivery job
+func (app *App) initWebhooks(cfg config.Config) error {
+	app.webhookSubscriptions = nil
+	if app.jobQueue != nil {
+		app.jobQueue.Handle(jobWebhook, app.runWebhookJob)
+	}
+	if cfg.WebhooksFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(cfg.WebhooksFile)
+	if err != nil {
+		return err
+	}
+	subs, err := webhooks.Parse(data, func(event string) bool { return slices.Contains(webhookEvents, event) })
+	if err != nil {
+		return err
+	}
+	app.webhookSubscriptions = subs
+	return nil
+}
+
+// Queue a delivery of an article change to each subscription that wants
+// it. Runs under articlesMutex, so it only queues.
+func (app *App) queueWebhooks(event ArticleEvent) {
+	if len(app.webhookSubscriptions) == 0 || app.replicating() {
+		return
+	}
+	data, err := json.Marshal(event.Article)
+	if err != nil {
+		log.Printf("Warning: webhook delivery of %s: %v", event.Type, err)
+		return
+	}
+	for _, s := range app.webhookSubscriptions {
+		if !s.Wants(event.Type) {
+			continue
+		}
+		delivery := webhooks.Delivery{ID: ids.NewULID(event.Time), Event: event.Type, Time: event.Time.UTC(), Data: data}
+		app.enqueueJob(jobWebhook, webhookJob{URL: s.URL, Delivery: delivery})
+	}
+}
+
+// Deliver one change
+func (app *App) runWebhookJob(ctx context.Context, job jobs.Job) error {
+	var payload webhookJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+	i := slices.IndexFunc(app.webhookSubscriptions, func(s webhooks.Subscription) bool { return s.URL == payload.URL })
+	if i < 0 {
+		return nil // unsubscribed since the job was queued
+	}
+	return app.webhookSubscriptions[i].Deliver(ctx, app.webhookClient, payload.Delivery)
+}
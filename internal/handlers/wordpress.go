@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-spring/internal/model"
+)
+
+// One <item> of a WordPress export (WXR) file
+type wxrItem struct {
+	Title       string
+	Link        string
+	PubDate     string
+	Content     string
+	Excerpt     string
+	PostType    string
+	Status      string
+	PostDateGMT string
+	PostDate    string
+	ModifiedGMT string
+	Categories  []string
+}
+
+var (
+	wpBlockCommentRe = regexp.MustCompile(`(?s)<!--\s*/?wp:.*?-->`)
+	wpBlockTagRe     = regexp.MustCompile(`(?i)^<(h[1-6]|p|ul|ol|li|pre|blockquote|div|figure|table)\b`)
+	wpParagraphRe    = regexp.MustCompile(`\n\s*\n`)
+)
+
+// POST /admin/import/wordpress - Create articles from a WordPress export
+//
+// Takes the WXR file written by Tools > Export in WordPress, as the body or a
+// multipart upload in field "file". Posts become articles with their excerpt
+// as description, their original dates and categories; published posts stay
+// published and everything else becomes a draft. Pages, attachments, trashed
+// posts and posts imported before (same link) are skipped. ?dry_run=true
+// reports without storing anything.
+func (app *App) importWordPress(w http.ResponseWriter, r *http.Request) {
+	dryRun, err := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	if err != nil && r.URL.Query().Has("dry_run") {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, "dry_run must be true or false")
+		return
+	}
+	data, err := readImportFile(w, r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidFile, err.Error())
+		return
+	}
+	if wantsAsync(r) {
+		app.acceptImportJob(w, r, "wordpress", data, dryRun)
+		return
+	}
+
+	report, err := app.ImportWXRFile(r.Context(), data, dryRun)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidFile, err.Error())
+		return
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: ImportMessage(report, "posts"), Data: report})
+}
+
+// Import the posts of a WordPress export. XML syntax errors are reported
+// with their line; an error is returned only for files that aren't WXR.
+func (app *App) ImportWXRFile(ctx context.Context, data []byte, dryRun bool) (ImportReport, error) {
+	// Links of articles already imported, to make re-running an import safe
+	seen := map[string]bool{}
+	app.articlesMutex.RLock()
+	for _, article := range app.articles {
+		if article.SourceURL != "" {
+			seen[article.SourceURL] = true
+		}
+	}
+	app.articlesMutex.RUnlock()
+
+	report := ImportReport{DryRun: dryRun, Errors: []ImportError{}}
+	var valid []model.Article
+	err := readWXR(data, func(line int, item wxrItem) {
+		if item.PostType != "post" || item.Status == "trash" || item.Status == "auto-draft" {
+			return
+		}
+		if item.Link != "" && seen[item.Link] {
+			report.Skipped++
+			return
+		}
+		article, err := app.wxrArticle(ctx, item)
+		if err != nil {
+			report.Errors = append(report.Errors, ImportError{line, err.Error()})
+			report.Skipped++
+			return
+		}
+		seen[item.Link] = true
+		valid = append(valid, article)
+	})
+	if err != nil {
+		var syntaxErr *xml.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			return report, errors.New("Invalid WordPress export: " + err.Error())
+		}
+		report.Errors = append(report.Errors, ImportError{syntaxErr.Line, "Invalid XML: " + syntaxErr.Msg})
+	}
+
+	app.storeImported(ctx, &report, valid)
+	return report, nil
+}
+
+// Stream the items of a WXR file to fn with the line each starts on
+func readWXR(data []byte, fn func(line int, item wxrItem)) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return nil, fmt.Errorf("unsupported encoding %s, WordPress exports are UTF-8", charset)
+	}
+
+	found := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			if el.Name.Local == "rss" {
+				found = true
+			}
+			if el.Name.Local != "item" {
+				continue
+			}
+			line, _ := dec.InputPos()
+			item, err := readWXRItem(dec)
+			if err != nil {
+				return err
+			}
+			fn(line, item)
+		}
+	}
+	if !found {
+		return errors.New("no <rss> element")
+	}
+	return nil
+}
+
+// Read the fields of an <item> up to its end tag
+func readWXRItem(dec *xml.Decoder) (wxrItem, error) {
+	var item wxrItem
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return item, err
+		}
+		switch el := tok.(type) {
+		case xml.EndElement:
+			return item, nil
+		case xml.StartElement:
+			var field *string
+			switch space, name := el.Name.Space, el.Name.Local; {
+			case name == "title" && space == "":
+				field = &item.Title
+			case name == "link" && space == "":
+				field = &item.Link
+			case name == "pubDate":
+				field = &item.PubDate
+			case name == "category" && space == "":
+				var text string
+				if err := dec.DecodeElement(&text, &el); err != nil {
+					return item, err
+				}
+				if xmlAttr(el, "domain") == "category" {
+					if text = strings.TrimSpace(text); text != "" && !slices.Contains(item.Categories, text) {
+						item.Categories = append(item.Categories, text)
+					}
+				}
+				continue
+			case name == "encoded" && strings.Contains(space, "/excerpt/"):
+				field = &item.Excerpt
+			case name == "encoded":
+				field = &item.Content
+			case name == "post_type":
+				field = &item.PostType
+			case name == "status":
+				field = &item.Status
+			case name == "post_date_gmt":
+				field = &item.PostDateGMT
+			case name == "post_date":
+				field = &item.PostDate
+			case name == "post_modified_gmt":
+				field = &item.ModifiedGMT
+			}
+			if field == nil {
+				if err := dec.Skip(); err != nil {
+					return item, err
+				}
+				continue
+			}
+			if err := dec.DecodeElement(field, &el); err != nil {
+				return item, err
+			}
+			*field = strings.TrimSpace(*field)
+		}
+	}
+}
+
+func xmlAttr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// Map a WordPress post onto a new article
+func (app *App) wxrArticle(ctx context.Context, item wxrItem) (model.Article, error) {
+	extract := extractReadable("<body>" + wpAutoParagraphs(item.Content) + "</body>")
+	req := model.CreateArticleRequest{
+		Title:   cleanText(item.Title),
+		Desc:    firstNonEmpty(cleanText(item.Excerpt), extract.Desc),
+		Content: extract.Content,
+		Status:  model.StatusDraft,
+	}
+	if item.Status == "publish" {
+		req.Status = model.StatusPublished
+	}
+	article, err := app.newArticle(ctx, req)
+	if err != nil {
+		return model.Article{}, err
+	}
+
+	article.SourceURL = item.Link
+	article.Categories = item.Categories
+	article.Created = wxrTime(item.PostDateGMT, item.PostDate, item.PubDate)
+	article.Updated = wxrTime(item.ModifiedGMT)
+	article.Published = article.Created
+	return article, nil
+}
+
+// Classic editor posts keep paragraphs as blank lines, like WordPress's
+// wpautop(); wrap them in <p> so they survive the HTML conversion
+func wpAutoParagraphs(content string) string {
+	content = wpBlockCommentRe.ReplaceAllString(content, "")
+	chunks := wpParagraphRe.Split(strings.ReplaceAll(content, "\r\n", "\n"), -1)
+	for i, chunk := range chunks {
+		chunk = strings.TrimSpace(chunk)
+		if chunk != "" && !wpBlockTagRe.MatchString(chunk) {
+			chunk = "<p>" + chunk + "</p>"
+		}
+		chunks[i] = chunk
+	}
+	return strings.Join(chunks, "\n")
+}
+
+// Parse the first usable WordPress date: GMT "2006-01-02 15:04:05" fields
+// (all zeros for unpublished drafts), the site-local post_date taken as
+// UTC, or the RSS pubDate.
+// Zero if none parse, which makes the import use the current time.
+func wxrTime(values ...string) time.Time {
+	for _, v := range values {
+		if v == "" || strings.HasPrefix(v, "0000") {
+			continue
+		}
+		if t, err := time.Parse(time.DateTime, v); err == nil {
+			return t
+		}
+		if t, err := time.Parse(time.RFC1123Z, v); err == nil && t.Unix() > 0 {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go-spring/internal/model"
+)
+
+// A WordPress export with one item of each kind the importer handles
+const wxrExport = `<?xml version="1.0" encoding="UTF-8" ?>
+<rss version="2.0"
+	xmlns:excerpt="http://wordpress.org/export/1.2/excerpt/"
+	xmlns:content="http://purl.org/rss/1.0/modules/content/"
+	xmlns:wp="http://wordpress.org/export/1.2/">
+<channel>
+	<title>Example blog</title>
+	<link>https://blog.example.com</link>
+	<wp:wxr_version>1.2</wp:wxr_version>
+	<item>
+		<title>Hello &amp; welcome</title>
+		<link>https://blog.example.com/hello/</link>
+		<pubDate>Tue, 05 Mar 2024 08:30:00 +0000</pubDate>
+		<category domain="category" nicename="news"><![CDATA[News]]></category>
+		<category domain="post_tag" nicename="intro"><![CDATA[intro]]></category>
+		<content:encoded><![CDATA[First paragraph of the post.
+
+Second paragraph with <strong>bold</strong> text.]]></content:encoded>
+		<excerpt:encoded><![CDATA[A short welcome.]]></excerpt:encoded>
+		<wp:post_date><![CDATA[2024-03-05 10:30:00]]></wp:post_date>
+		<wp:post_date_gmt><![CDATA[2024-03-05 08:30:00]]></wp:post_date_gmt>
+		<wp:post_modified_gmt><![CDATA[2024-03-06 12:00:00]]></wp:post_modified_gmt>
+		<wp:status><![CDATA[publish]]></wp:status>
+		<wp:post_type><![CDATA[post]]></wp:post_type>
+	</item>
+	<item>
+		<title>Unfinished thoughts</title>
+		<link>https://blog.example.com/?p=12</link>
+		<content:encoded><![CDATA[<!-- wp:paragraph -->
+<p>Written in the block editor.</p>
+<!-- /wp:paragraph -->]]></content:encoded>
+		<excerpt:encoded><![CDATA[]]></excerpt:encoded>
+		<wp:post_date><![CDATA[2024-04-01 09:00:00]]></wp:post_date>
+		<wp:post_date_gmt><![CDATA[0000-00-00 00:00:00]]></wp:post_date_gmt>
+		<wp:status><![CDATA[draft]]></wp:status>
+		<wp:post_type><![CDATA[post]]></wp:post_type>
+	</item>
+	<item>
+		<title>Waiting for review</title>
+		<link>https://blog.example.com/?p=13</link>
+		<content:encoded><![CDATA[Pending text.]]></content:encoded>
+		<wp:status><![CDATA[pending]]></wp:status>
+		<wp:post_type><![CDATA[post]]></wp:post_type>
+	</item>
+	<item>
+		<title>About</title>
+		<link>https://blog.example.com/about/</link>
+		<content:encoded><![CDATA[A page.]]></content:encoded>
+		<wp:status><![CDATA[publish]]></wp:status>
+		<wp:post_type><![CDATA[page]]></wp:post_type>
+	</item>
+	<item>
+		<title>photo.jpg</title>
+		<link>https://blog.example.com/photo/</link>
+		<wp:status><![CDATA[inherit]]></wp:status>
+		<wp:post_type><![CDATA[attachment]]></wp:post_type>
+	</item>
+	<item>
+		<title>Deleted post</title>
+		<link>https://blog.example.com/deleted/</link>
+		<content:encoded><![CDATA[Gone.]]></content:encoded>
+		<wp:status><![CDATA[trash]]></wp:status>
+		<wp:post_type><![CDATA[post]]></wp:post_type>
+	</item>
+	<item>
+		<title>Auto Draft</title>
+		<link>https://blog.example.com/?p=17</link>
+		<wp:status><![CDATA[auto-draft]]></wp:status>
+		<wp:post_type><![CDATA[post]]></wp:post_type>
+	</item>
+	<item>
+		<title></title>
+		<link>https://blog.example.com/?p=18</link>
+		<content:encoded><![CDATA[A post without a title.]]></content:encoded>
+		<wp:status><![CDATA[publish]]></wp:status>
+		<wp:post_type><![CDATA[post]]></wp:post_type>
+	</item>
+</channel>
+</rss>
+`
+
+// Line of the nth <item> of data, counting from 1
+func itemLine(data string, n int) int {
+	i := 0
+	for range n {
+		i += strings.Index(data[i:], "<item>") + 1
+	}
+	return strings.Count(data[:i], "\n") + 1
+}
+
+func TestWordPressImport(t *testing.T) {
+	srv := newTestServer(t, 0)
+
+	var report ImportReport
+	resp := call(t, "POST", srv.URL+"/admin/import/wordpress", wxrExport, &report)
+	if resp.StatusCode != http.StatusOK || report.Created != 3 || report.Skipped != 1 || len(report.IDs) != 3 {
+		t.Fatalf("status %d, report %+v", resp.StatusCode, report)
+	}
+	if len(report.Errors) != 1 || report.Errors[0].Line != itemLine(wxrExport, 8) || !strings.Contains(report.Errors[0].Message, "title") {
+		t.Errorf("errors %+v, want the untitled post on line %d", report.Errors, itemLine(wxrExport, 8))
+	}
+
+	var published, draft, pending model.Article
+	call(t, "GET", srv.URL+"/articles/1", "", &published)
+	call(t, "GET", srv.URL+"/articles/2", "", &draft)
+	call(t, "GET", srv.URL+"/articles/3", "", &pending)
+
+	if published.Title != "Hello & welcome" || published.Desc != "A short welcome." || published.Status != model.StatusPublished ||
+		published.SourceURL != "https://blog.example.com/hello/" || !slices.Equal(published.Categories, []string{"News"}) {
+		t.Errorf("published post %+v", published)
+	}
+	if want := "First paragraph of the post.\n\nSecond paragraph with bold text."; published.Content != want {
+		t.Errorf("content %q, want %q", published.Content, want)
+	}
+	if !published.Created.Equal(time.Date(2024, 3, 5, 8, 30, 0, 0, time.UTC)) || !published.Updated.Equal(time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC)) ||
+		!published.Published.Equal(published.Created) {
+		t.Errorf("dates created %v, updated %v, published %v", published.Created, published.Updated, published.Published)
+	}
+
+	// Other statuses become drafts; the description falls back to the first paragraph
+	if draft.Status != model.StatusDraft || draft.Content != "Written in the block editor." || draft.Desc != "Written in the block editor." ||
+		!draft.Created.Equal(time.Date(2024, 4, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("draft %+v", draft)
+	}
+	if pending.Status != model.StatusDraft || pending.Created.IsZero() {
+		t.Errorf("pending post %+v", pending)
+	}
+
+	// Importing again skips the posts already imported
+	resp = call(t, "POST", srv.URL+"/admin/import/wordpress", wxrExport, &report)
+	if resp.StatusCode != http.StatusOK || report.Created != 0 || report.Skipped != 4 || srv.ArticleCount() != 3 {
+		t.Errorf("second import: status %d, report %+v, %d articles", resp.StatusCode, report, srv.ArticleCount())
+	}
+}
+
+func TestWordPressImportDryRun(t *testing.T) {
+	srv := newTestServer(t, 0)
+
+	var report ImportReport
+	resp := call(t, "POST", srv.URL+"/admin/import/wordpress?dry_run=true", wxrExport, &report)
+	if resp.StatusCode != http.StatusOK || !report.DryRun || report.Created != 3 || len(report.IDs) != 0 || srv.ArticleCount() != 0 {
+		t.Errorf("status %d, report %+v, %d articles", resp.StatusCode, report, srv.ArticleCount())
+	}
+	if resp := call(t, "POST", srv.URL+"/admin/import/wordpress?dry_run=maybe", wxrExport, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("dry_run=maybe: status %d", resp.StatusCode)
+	}
+}
+
+func TestWordPressImportMalformed(t *testing.T) {
+	srv := newTestServer(t, 0)
+
+	// Files that aren't WordPress exports are refused
+	for name, body := range map[string]string{
+		"empty":    "",
+		"JSON":     `{"title":"t"}`,
+		"not RSS":  `<?xml version="1.0"?><feed><entry><title>t</title></entry></feed>`,
+		"encoding": `<?xml version="1.0" encoding="ISO-8859-1"?><rss><channel></channel></rss>`,
+	} {
+		if resp := call(t, "POST", srv.URL+"/admin/import/wordpress", body, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status %d", name, resp.StatusCode)
+		}
+	}
+
+	// A cut-off export keeps the items before the break and reports its line
+	cut := wxrExport[:strings.Index(wxrExport, "<wp:post_type><![CDATA[post]]></wp:post_type>\n\t</item>\n\t<item>\n\t\t<title>Waiting")]
+	var report ImportReport
+	resp := call(t, "POST", srv.URL+"/admin/import/wordpress", cut, &report)
+	if resp.StatusCode != http.StatusOK || report.Created != 1 || len(report.Errors) != 1 || !strings.HasPrefix(report.Errors[0].Message, "Invalid XML") ||
+		report.Errors[0].Line < itemLine(wxrExport, 2) {
+		t.Errorf("status %d, report %+v", resp.StatusCode, report)
+	}
+	if srv.ArticleCount() != 1 {
+		t.Errorf("%d articles, want the one before the break", srv.ArticleCount())
+	}
+}
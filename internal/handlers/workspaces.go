@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"go-spring/internal/model"
+	"go-spring/internal/slug"
+)
+
+type workspacesState struct {
+	// Workspaces, kept and saved with the articles under articlesMutex
+	workspaces []model.Workspace
+}
+
+// WorkspaceRequest is the body of POST /workspaces
+type WorkspaceRequest struct {
+	Slug            string `json:"slug" validate:"max=60"` // made from the name if empty
+	Name            string `json:"name" validate:"required,max=200"`
+	Description     string `json:"description" validate:"max=1000"`
+	DefaultStatus   string `json:"default_status" validate:"oneof=draft published"`
+	FeedTitle       string `json:"feed_title" validate:"max=200"`
+	FeedDescription string `json:"feed_description" validate:"max=1000"`
+	FeedLanguage    string `json:"feed_language" validate:"max=35"`
+	Confidential    bool   `json:"confidential"` // encrypt the content of its articles
+}
+
+// WorkspaceUpdate is the body of PUT /workspaces/{ws}; empty fields are
+// left unchanged
+type WorkspaceUpdate struct {
+	Name            string `json:"name" validate:"max=200"`
+	Description     string `json:"description" validate:"max=1000"`
+	DefaultStatus   string `json:"default_status" validate:"oneof=draft published"`
+	FeedTitle       string `json:"feed_title" validate:"max=200"`
+	FeedDescription string `json:"feed_description" validate:"max=1000"`
+	FeedLanguage    string `json:"feed_language" validate:"max=35"`
+	Confidential    *bool  `json:"confidential"` // true makes its articles confidential too
+}
+
+// MemberRequest is the body of PUT /workspaces/{ws}/members/{username}
+type MemberRequest struct {
+	Role string `json:"role" validate:"required,oneof=viewer editor owner"`
+}
+
+func (app *App) findWorkspace(s string) (model.Workspace, bool) {
+	app.articlesMutex.RLock()
+	defer app.articlesMutex.RUnlock()
+	i := slices.IndexFunc(app.workspaces, func(ws model.Workspace) bool { return ws.Slug == s })
+	if i < 0 {
+		return model.Workspace{}, false
+	}
+	return app.workspaces[i], true
+}
+
+// The workspace of the article with id, if it has one
+func (app *App) articleWorkspace(id int) (model.Workspace, bool) {
+	app.articlesMutex.RLock()
+	i := app.findArticleIndex(id)
+	var s string
+	if i >= 0 {
+		s = app.articles[i].Workspace
+	}
+	app.articlesMutex.RUnlock()
+	if s == "" {
+		return model.Workspace{}, false
+	}
+	return app.findWorkspace(s)
+}
+
+// Routes that need a role in a workspace: those with workspaceRole, and
+// the ones that change an article or show what only its editors see,
+// which need editor in the article's workspace if it has one
+var workspaceRoutes = func(r Route) bool {
+	return r.workspaceRole != "" || (r.Method != http.MethodGet || r.editors) && strings.HasPrefix(r.Path, "/articles/{id}")
+}
+
+// Require HTTP Basic credentials of a user with the route's role in the
+// workspace, answering 404 for an unknown {ws}. Until the first user is
+// added workspaces are open, as the admin routes are.
+func (app *App) requireWorkspaceRole(route Route, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var ws model.Workspace
+		role := route.workspaceRole
+		if role != "" {
+			var ok bool
+			if ws, ok = app.findWorkspace(mux.Vars(r)["ws"]); !ok {
+				app.writeError(w, r, http.StatusNotFound, CodeWorkspaceNotFound, "Workspace not found")
+				return
+			}
+		} else {
+			id, err := app.articleRouteID(r)
+			var ok bool
+			if err == nil {
+				ws, ok = app.articleWorkspace(id)
+			}
+			if !ok {
+				next(w, r) // the handler reports a bad ID; articles outside workspaces are open
+				return
+			}
+			role = model.WorkspaceEditor
+		}
+		if !app.hasUsers() {
+			next(w, r)
+			return
+		}
+		user, ok := app.basicAuthUser(w, r)
+		if !ok {
+			return
+		}
+		if !ws.Allows(user, role) {
+			app.writeError(w, r, http.StatusForbidden, CodeForbidden, "Workspace role required: "+role)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
+	}
+}
+
+// The user of valid Basic credentials, if the request has them; for routes
+// that show more to members
+func (app *App) requestUser(r *http.Request) (model.User, bool) {
+	if user, ok := currentUser(r); ok {
+		return user, true
+	}
+	return app.checkBasicAuth(r)
+}
+
+// Whether the request may act with role in ws without having been checked
+// by requireWorkspaceRole
+func (app *App) requestAllows(r *http.Request, ws model.Workspace, role string) bool {
+	if !app.hasUsers() {
+		return true
+	}
+	user, ok := app.requestUser(r)
+	return ok && ws.Allows(user, role)
+}
+
+// A workspace as the request may see it: members only for owners
+func (app *App) workspaceView(r *http.Request, ws model.Workspace) model.Workspace {
+	if !app.requestAllows(r, ws, model.WorkspaceOwner) {
+		ws.Members = nil
+	}
+	return ws
+}
+
+// Workspace settings show in the feeds, which may be cached
+func (app *App) workspaceChanged() {
+	app.scheduleSave()
+	if app.responseCache != nil {
+		app.invalidateResponseCache()
+	}
+}
+
+// GET /workspaces - List workspaces
+func (app *App) getWorkspaces(w http.ResponseWriter, r *http.Request) {
+	app.articlesMutex.RLock()
+	list := slices.Clone(app.workspaces)
+	app.articlesMutex.RUnlock()
+	for i, ws := range list {
+		list[i] = app.workspaceView(r, ws)
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Workspaces retrieved successfully", Data: list})
+}
+
+// GET /workspaces/{ws} - Get a workspace
+func (app *App) getWorkspace(w http.ResponseWriter, r *http.Request) {
+	ws, ok := app.findWorkspace(mux.Vars(r)["ws"])
+	if !ok {
+		app.writeError(w, r, http.StatusNotFound, CodeWorkspaceNotFound, "Workspace not found")
+		return
+	}
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Workspace retrieved successfully", Data: app.workspaceView(r, ws)})
+}
+
+// POST /workspaces - Create a workspace, owned by the user who creates it
+func (app *App) createWorkspace(w http.ResponseWriter, r *http.Request) {
+	var owner string
+	if app.hasUsers() {
+		user, ok := app.basicAuthUser(w, r)
+		if !ok {
+			return
+		}
+		owner = user.Username
+	}
+
+	var req WorkspaceRequest
+	if err := decodeBody(r, &req); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	req.Slug, req.Name = strings.TrimSpace(req.Slug), strings.TrimSpace(req.Name)
+	if err := validateRequest(req); err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+	if req.Slug != "" && slug.Make(req.Slug) != req.Slug {
+		app.writeArticleError(w, r, &ValidationError{Message: "slug must be lower-case letters, digits and hyphens"})
+		return
+	}
+	if req.Confidential && app.contentKeys == nil {
+		app.writeArticleError(w, r, errNoContentKeys)
+		return
+	}
+
+	ws := model.Workspace{
+		Slug:            req.Slug,
+		Name:            req.Name,
+		Description:     req.Description,
+		Created:         time.Now(),
+		DefaultStatus:   req.DefaultStatus,
+		FeedTitle:       req.FeedTitle,
+		FeedDescription: req.FeedDescription,
+		FeedLanguage:    req.FeedLanguage,
+		Confidential:    req.Confidential,
+	}
+	if owner != "" {
+		ws.Members = map[string]string{owner: model.WorkspaceOwner}
+	}
+
+	app.articlesMutex.Lock()
+	taken := func(s string) bool {
+		return slices.ContainsFunc(app.workspaces, func(ws model.Workspace) bool { return ws.Slug == s })
+	}
+	if ws.Slug == "" {
+		ws.Slug = slug.Unique(slug.Make(ws.Name), taken)
+	} else if taken(ws.Slug) {
+		app.articlesMutex.Unlock()
+		app.writeError(w, r, http.StatusConflict, CodeConflict, "Workspace slug is taken")
+		return
+	}
+	app.workspaces = append(app.workspaces, ws)
+	app.articlesMutex.Unlock()
+	app.workspaceChanged()
+
+	app.writeResponse(w, r, http.StatusCreated, Response{Message: "Workspace created successfully", Data: ws})
+}
+
+// Change the workspace of the {ws} route variable under the lock
+func (app *App) updateWorkspaceWith(r *http.Request, change func(ws *model.Workspace) error) (model.Workspace, error) {
+	app.articlesMutex.Lock()
+	defer app.articlesMutex.Unlock()
+	i := slices.IndexFunc(app.workspaces, func(ws model.Workspace) bool { return ws.Slug == mux.Vars(r)["ws"] })
+	if i < 0 {
+		return model.Workspace{}, errWorkspaceNotFound
+	}
+	if err := change(&app.workspaces[i]); err != nil {
+		return model.Workspace{}, err
+	}
+	return app.workspaces[i], nil
+}
+
+var (
+	errWorkspaceNotFound = errors.New("workspace not found")
+	errMemberNotFound    = errors.New("user not found")
+)
+
+// Map workspace errors to HTTP responses
+func (app *App) writeWorkspaceError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errWorkspaceNotFound):
+		app.writeError(w, r, http.StatusNotFound, CodeWorkspaceNotFound, "Workspace not found")
+	case errors.Is(err, errMemberNotFound):
+		app.writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User not found")
+	default:
+		app.writeArticleError(w, r, err)
+	}
+}
+
+// PUT /workspaces/{ws} - Change a workspace's name, description and settings
+func (app *App) updateWorkspace(w http.ResponseWriter, r *http.Request) {
+	var req WorkspaceUpdate
+	if err := decodeBody(r, &req); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if err := validateRequest(req); err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+
+	ws, err := app.updateWorkspaceWith(r, func(ws *model.Workspace) error {
+		set := func(field *string, value string) {
+			if value != "" {
+				*field = value
+			}
+		}
+		set(&ws.Name, req.Name)
+		set(&ws.Description, req.Description)
+		set(&ws.DefaultStatus, req.DefaultStatus)
+		set(&ws.FeedTitle, req.FeedTitle)
+		set(&ws.FeedDescription, req.FeedDescription)
+		set(&ws.FeedLanguage, req.FeedLanguage)
+		if req.Confidential != nil {
+			return app.setWorkspaceConfidential(ws, *req.Confidential)
+		}
+		return nil
+	})
+	if err != nil {
+		app.writeWorkspaceError(w, r, err)
+		return
+	}
+	app.workspaceChanged()
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Workspace updated successfully", Data: ws})
+}
+
+// DELETE /workspaces/{ws} - Delete a workspace that has no articles left
+func (app *App) deleteWorkspace(w http.ResponseWriter, r *http.Request) {
+	app.articlesMutex.Lock()
+	s := mux.Vars(r)["ws"]
+	if slices.ContainsFunc(app.articles, func(a model.Article) bool { return a.Workspace == s }) {
+		app.articlesMutex.Unlock()
+		app.writeError(w, r, http.StatusConflict, CodeConflict, "Workspace still has articles")
+		return
+	}
+	app.workspaces = slices.DeleteFunc(app.workspaces, func(ws model.Workspace) bool { return ws.Slug == s })
+	app.articlesMutex.Unlock()
+	app.workspaceChanged()
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Workspace deleted successfully"})
+}
+
+// PUT /workspaces/{ws}/members/{username} - Add a member or change their role
+func (app *App) setWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	var req MemberRequest
+	if err := decodeBody(r, &req); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	if err := validateRequest(req); err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+	username := mux.Vars(r)["username"]
+	ws, err := app.updateWorkspaceWith(r, func(ws *model.Workspace) error {
+		i := slices.IndexFunc(app.users, func(u model.User) bool { return strings.EqualFold(u.Username, username) })
+		if i < 0 {
+			return errMemberNotFound
+		}
+		username = app.users[i].Username
+		// A new map, as copies from findWorkspace may still be reading the old one
+		ws.Members = maps.Clone(ws.Members)
+		if ws.Members == nil {
+			ws.Members = map[string]string{}
+		}
+		ws.Members[username] = req.Role
+		return nil
+	})
+	if err != nil {
+		app.writeWorkspaceError(w, r, err)
+		return
+	}
+	app.workspaceChanged()
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Workspace member saved successfully", Data: ws})
+}
+
+// DELETE /workspaces/{ws}/members/{username} - Remove a member
+func (app *App) removeWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	ws, err := app.updateWorkspaceWith(r, func(ws *model.Workspace) error {
+		ws.Members = maps.Clone(ws.Members)
+		delete(ws.Members, mux.Vars(r)["username"])
+		return nil
+	})
+	if err != nil {
+		app.writeWorkspaceError(w, r, err)
+		return
+	}
+	app.workspaceChanged()
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Workspace member removed successfully", Data: ws})
+}
+
+// GET /workspaces/{ws}/articles - Articles of a workspace, pinned first;
+// drafts only for members
+func (app *App) getWorkspaceArticles(w http.ResponseWriter, r *http.Request) {
+	ws, ok := app.findWorkspace(mux.Vars(r)["ws"])
+	if !ok {
+		app.writeError(w, r, http.StatusNotFound, CodeWorkspaceNotFound, "Workspace not found")
+		return
+	}
+	drafts := app.requestAllows(r, ws, model.WorkspaceViewer)
+
+	app.articlesMutex.RLock()
+	list := []model.Article{}
+	for _, a := range app.articles {
+		if a.Workspace == ws.Slug && (drafts || a.IsPublished()) {
+			list = append(list, a)
+		}
+	}
+	app.articlesMutex.RUnlock()
+	slices.SortStableFunc(list, func(a, b model.Article) int {
+		switch {
+		case a.Pinned == b.Pinned:
+			return 0
+		case a.Pinned:
+			return -1
+		}
+		return 1
+	})
+	list = withholdContent(app.runOnServeList(r.Context(), app.withoutHeld(r, list)), app.requestReader(r))
+	app.writeResponse(w, r, http.StatusOK, Response{Message: "Articles retrieved successfully", Data: list})
+}
+
+// POST /workspaces/{ws}/articles - Create an article in a workspace, with
+// the workspace's default status
+func (app *App) createWorkspaceArticle(w http.ResponseWriter, r *http.Request) {
+	ws, ok := app.findWorkspace(mux.Vars(r)["ws"])
+	if !ok {
+		app.writeError(w, r, http.StatusNotFound, CodeWorkspaceNotFound, "Workspace not found")
+		return
+	}
+	var req model.CreateArticleRequest
+	if err := decodeBody(r, &req); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+		return
+	}
+	req.Workspace = ws.Slug
+	if req.Status == "" {
+		req.Status = ws.DefaultStatus
+	}
+	ctx, tags := collectSuggestedTags(r.Context())
+	article, err := app.articleService.Create(ctx, req)
+	if err != nil {
+		app.writeArticleError(w, r, err)
+		return
+	}
+	app.writeResponse(w, r, http.StatusCreated, Response{Message: "Article created successfully", Data: article, SuggestedTags: *tags})
+}
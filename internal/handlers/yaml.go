@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// yamlCodec handles the block-style YAML subset needed for API documents:
+// nested mappings and sequences, plain and quoted scalars, and literal (|)
+// or folded (>) block scalars for long text. Anchors, tags and multi-document
+// streams are not supported.
+type yamlCodec struct{}
+
+func (yamlCodec) ContentType() string { return "application/yaml" }
+
+func (yamlCodec) Encode(w io.Writer, v any) error {
+	value, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	switch v := value.(type) {
+	case orderedMap:
+		writeYAMLMap(&b, v, 0)
+	case []any:
+		writeYAMLList(&b, v, 0)
+	default:
+		b.WriteString(yamlScalar(v, 0) + "\n")
+	}
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// Write a value after "key:" or "-", nesting collections on the next lines
+func writeYAMLValue(b *bytes.Buffer, value any, indent int) {
+	switch v := value.(type) {
+	case orderedMap:
+		if len(v) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYAMLMap(b, v, indent)
+	case []any:
+		if len(v) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYAMLList(b, v, indent)
+	default:
+		b.WriteString(" " + yamlScalar(v, indent) + "\n")
+	}
+}
+
+func writeYAMLMap(b *bytes.Buffer, m orderedMap, indent int) {
+	for _, field := range m {
+		b.WriteString(strings.Repeat(" ", indent) + yamlScalar(field.Key, indent) + ":")
+		writeYAMLValue(b, field.Value, indent+2)
+	}
+}
+
+func writeYAMLList(b *bytes.Buffer, list []any, indent int) {
+	for _, item := range list {
+		b.WriteString(strings.Repeat(" ", indent) + "-")
+		writeYAMLValue(b, item, indent+2)
+	}
+}
+
+var (
+	yamlPlainRe    = regexp.MustCompile(`^[A-Za-z_/][A-Za-z0-9 _./-]*$`)
+	yamlReservedRe = regexp.MustCompile(`(?i)^(true|false|null|yes|no|on|off|y|n|~)$`)
+)
+
+func yamlScalar(value any, indent int) string {
+	s, ok := value.(string)
+	if !ok {
+		if value == nil {
+			return "null"
+		}
+		return fmt.Sprint(value) // bool or json.Number
+	}
+	switch {
+	case yamlPlainRe.MatchString(s) && !strings.HasSuffix(s, " ") && !yamlReservedRe.MatchString(s):
+		return s
+	case strings.Contains(s, "\n") && !strings.ContainsAny(s, "\r\x00") &&
+		!strings.HasPrefix(s, " ") && !strings.HasPrefix(s, "\n") && !strings.HasSuffix(s, "\n\n"):
+		// Literal block keeps long Markdown content readable
+		header := "|-"
+		if strings.HasSuffix(s, "\n") {
+			header = "|"
+		}
+		pad := strings.Repeat(" ", indent)
+		lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+		for i, line := range lines {
+			if line != "" {
+				lines[i] = pad + line
+			}
+		}
+		return header + "\n" + strings.Join(lines, "\n")
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s) // a JSON string is a valid double-quoted YAML scalar
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (yamlCodec) Decode(r io.Reader, v any) error {
+	p := &yamlParser{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		raw := strings.TrimRight(scanner.Text(), " \t\r")
+		text := strings.TrimLeft(raw, " ")
+		p.lines = append(p.lines, yamlLine{indent: len(raw) - len(text), text: text})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	p.skipBlank()
+	if p.pos < len(p.lines) && p.lines[p.pos].text == "---" {
+		p.pos++
+		p.skipBlank()
+	}
+	if p.pos == len(p.lines) {
+		return errors.New("yaml: empty document")
+	}
+	value, err := p.parseNode(p.lines[p.pos].indent)
+	if err != nil {
+		return err
+	}
+	return assignGeneric(reflect.ValueOf(v).Elem(), value)
+}
+
+type yamlLine struct {
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// Skip blank and comment lines
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && (p.lines[p.pos].text == "" || strings.HasPrefix(p.lines[p.pos].text, "#")) {
+		p.pos++
+	}
+}
+
+func isYAMLItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) parseNode(indent int) (any, error) {
+	line := p.lines[p.pos]
+	if isYAMLItem(line.text) {
+		return p.parseList(indent)
+	}
+	if _, _, ok := splitYAMLKey(line.text); ok {
+		return p.parseMap(indent)
+	}
+	p.pos++
+	return parseYAMLScalar(line.text)
+}
+
+func (p *yamlParser) parseList(indent int) (any, error) {
+	list := []any{}
+	for p.skipBlank(); p.pos < len(p.lines); p.skipBlank() {
+		line := p.lines[p.pos]
+		if line.indent != indent || !isYAMLItem(line.text) {
+			break
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		var item any
+		var err error
+		switch {
+		case rest == "":
+			p.pos++
+			item, err = p.parseChild(indent)
+		default:
+			// The item's content starts on the same line as the dash
+			inner := indent + len(line.text) - len(rest)
+			p.lines[p.pos] = yamlLine{indent: inner, text: rest}
+			item, err = p.parseNode(inner)
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+func (p *yamlParser) parseMap(indent int) (any, error) {
+	m := map[string]any{}
+	for p.skipBlank(); p.pos < len(p.lines); p.skipBlank() {
+		line := p.lines[p.pos]
+		if line.indent != indent || isYAMLItem(line.text) {
+			if line.indent > indent {
+				return nil, fmt.Errorf("yaml: line %d: unexpected indentation", p.pos+1)
+			}
+			break
+		}
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("yaml: line %d: expected key: value", p.pos+1)
+		}
+		p.pos++
+
+		var value any
+		var err error
+		switch {
+		case rest == "":
+			value, err = p.parseChild(indent)
+		case rest[0] == '|' || rest[0] == '>':
+			value = p.parseBlock(indent, rest)
+		default:
+			value, err = parseYAMLScalar(rest)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// Parse the nested value after "key:" or "-" with nothing on the same line.
+// A sequence may sit at the same indentation as its parent key.
+func (p *yamlParser) parseChild(indent int) (any, error) {
+	p.skipBlank()
+	if p.pos == len(p.lines) {
+		return nil, nil
+	}
+	next := p.lines[p.pos]
+	if next.indent > indent || (next.indent == indent && isYAMLItem(next.text)) {
+		return p.parseNode(next.indent)
+	}
+	return nil, nil
+}
+
+// Parse a literal (|) or folded (>) block scalar with optional chomping
+func (p *yamlParser) parseBlock(indent int, header string) string {
+	var lines []yamlLine
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.text != "" && line.indent <= indent {
+			break
+		}
+		lines = append(lines, line)
+		p.pos++
+	}
+
+	blockIndent := -1
+	for _, line := range lines {
+		if line.text != "" {
+			blockIndent = line.indent
+			break
+		}
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if line.text != "" {
+			out[i] = strings.Repeat(" ", line.indent-blockIndent) + line.text
+		}
+	}
+
+	var s string
+	if header[0] == '>' {
+		// Folding joins lines of a paragraph; each blank line between
+		// paragraphs is one line break, and more indented lines keep theirs
+		var b strings.Builder
+		for i, line := range out {
+			moreIndented := strings.HasPrefix(line, " ")
+			switch {
+			case i == 0:
+			case line == "" || moreIndented || strings.HasPrefix(out[i-1], " "):
+				b.WriteString("\n")
+			case out[i-1] == "":
+			default:
+				b.WriteString(" ")
+			}
+			b.WriteString(line)
+		}
+		s = b.String()
+	} else {
+		s = strings.Join(out, "\n")
+	}
+
+	body := strings.TrimRight(s, "\n")
+	switch {
+	case strings.Contains(header, "-"):
+		return body
+	case strings.Contains(header, "+"):
+		return s + "\n"
+	}
+	if body == "" {
+		return ""
+	}
+	return body + "\n"
+}
+
+// Split "key: value" at the first colon outside quotes that ends the line
+// or is followed by a space
+func splitYAMLKey(text string) (key, rest string, ok bool) {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && i == 0:
+			quote = c
+		case c == '#' && i > 0 && text[i-1] == ' ':
+			return "", "", false
+		case c == ':' && (i+1 == len(text) || text[i+1] == ' '):
+			k, err := parseYAMLScalar(strings.TrimSpace(text[:i]))
+			if err != nil || k == nil {
+				return "", "", false
+			}
+			return fmt.Sprint(k), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// Parse a scalar or a simple flow collection ([a, b] or {}). Plain scalars
+// stay strings and are converted to the destination type later.
+func parseYAMLScalar(text string) (any, error) {
+	switch {
+	case strings.HasPrefix(text, `"`):
+		var s string
+		end := strings.LastIndex(text, `"`)
+		if err := json.Unmarshal([]byte(text[:end+1]), &s); err != nil || end == 0 {
+			return nil, fmt.Errorf("yaml: invalid quoted string %s", text)
+		}
+		return s, nil
+	case strings.HasPrefix(text, "'"):
+		end := strings.LastIndex(text, "'")
+		if end == 0 {
+			return nil, fmt.Errorf("yaml: invalid quoted string %s", text)
+		}
+		return strings.ReplaceAll(text[1:end], "''", "'"), nil
+	case text == "{}":
+		return map[string]any{}, nil
+	case strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]"):
+		list := []any{}
+		for _, part := range splitYAMLFlow(text[1 : len(text)-1]) {
+			if part = strings.TrimSpace(part); part != "" {
+				item, err := parseYAMLScalar(part)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, item)
+			}
+		}
+		return list, nil
+	}
+
+	if i := strings.Index(text, " #"); i >= 0 {
+		text = strings.TrimSpace(text[:i])
+	}
+	if text == "" || text == "~" || text == "null" {
+		return nil, nil
+	}
+	return text, nil
+}
+
+// Split the items of a flow sequence at the commas outside quotes
+func splitYAMLFlow(text string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			parts = append(parts, text[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, text[start:])
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// codecDoc has a field of each kind the codecs convert, for round trips
+type codecDoc struct {
+	Title    string              `json:"title"`
+	Count    int                 `json:"count"`
+	Big      int64               `json:"big"`
+	Small    int64               `json:"small"`
+	Huge     uint64              `json:"huge"`
+	Ratio    float64             `json:"ratio"`
+	On       bool                `json:"on"`
+	Optional *string             `json:"optional"`
+	Tags     []string            `json:"tags"`
+	Empty    []string            `json:"empty"`
+	Channels map[string][]string `json:"channels"`
+	Nested   codecChild          `json:"nested"`
+	Children []codecChild        `json:"children"`
+	Data     []byte              `json:"data"`
+	Time     time.Time           `json:"time"`
+}
+
+type codecChild struct {
+	Name  string            `json:"name"`
+	Attrs map[string]string `json:"attrs"`
+	Inner *codecChild       `json:"inner,omitempty"`
+}
+
+func newCodecDoc() codecDoc {
+	note := "see below"
+	return codecDoc{
+		Title:    "Hello: world # not a comment",
+		Count:    42,
+		Big:      math.MaxInt64,
+		Small:    math.MinInt64,
+		Huge:     math.MaxUint64,
+		Ratio:    -0.125,
+		On:       true,
+		Optional: &note,
+		Tags:     []string{"go", "true", "123", "- dash", "", " padded ", "null", "quote \" 'mark'", "ünïcode"},
+		Empty:    []string{},
+		Channels: map[string][]string{"article.published": {"email", "push"}, "user.mentioned": {"webhook"}},
+		Nested:   codecChild{Name: "outer", Attrs: map[string]string{"a: b": "c", "#d": "~"}, Inner: &codecChild{Name: "inner"}},
+		Children: []codecChild{{Name: "first"}, {Name: "second\nline\n"}},
+		Data:     []byte{0, 1, 2, 0xff, '\n'},
+		Time:     time.Date(2025, 3, 1, 12, 30, 0, 5, time.UTC),
+	}
+}
+
+// Encode doc with codec and decode it back
+func roundTrip(t *testing.T, codec Codec, doc codecDoc) codecDoc {
+	t.Helper()
+	var b bytes.Buffer
+	if err := codec.Encode(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	var got codecDoc
+	if err := codec.Decode(&b, &got); err != nil {
+		t.Fatalf("decode: %v\n%s", err, b.String())
+	}
+	return got
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	doc := newCodecDoc()
+	got := roundTrip(t, yamlCodec{}, doc)
+	// An empty map of the child has nothing to tell it from nil
+	doc.Nested.Inner.Attrs, doc.Children[0].Attrs, doc.Children[1].Attrs = nil, nil, nil
+	if !reflect.DeepEqual(got, doc) {
+		t.Errorf("round trip\n got %+v\nwant %+v", got, doc)
+	}
+}
+
+func TestYAMLScalars(t *testing.T) {
+	for value, want := range map[string]string{
+		"plain":         "plain",
+		"two words":     "two words",
+		"true":          `"true"`,
+		"No":            `"No"`,
+		"~":             `"~"`,
+		"123":           `"123"`,
+		"":              `""`,
+		"trailing ":     `"trailing "`,
+		" leading":      `" leading"`,
+		"a: b":          `"a: b"`,
+		"#comment":      `"#comment"`,
+		"- item":        `"- item"`,
+		"[list]":        `"[list]"`,
+		"{map}":         `"{map}"`,
+		"*alias":        `"*alias"`,
+		"tab\there":     `"tab\there"`,
+		"<b>&amp;</b>":  `"<b>&amp;</b>"`,
+		"two\nlines":    "|-\n  two\n  lines",
+		"ends\nwith\n":  "|\n  ends\n  with",
+		"\nstarts":      `"\nstarts"`,
+		"cr\r\nlf":      `"cr\r\nlf"`,
+		"many\n\n\n":    `"many\n\n\n"`,
+		" indented\nx":  `" indented\nx"`,
+		"x\n  indented": "|-\n  x\n    indented",
+	} {
+		if got := yamlScalar(value, 2); got != want {
+			t.Errorf("yamlScalar(%q) = %q, want %q", value, got, want)
+		}
+	}
+	if got := yamlScalar(nil, 0); got != "null" {
+		t.Errorf("nil became %q", got)
+	}
+}
+
+func TestYAMLDecode(t *testing.T) {
+	input := `---
+# an article
+title: 'It''s here' # comment
+count: 7
+on: true
+tags: [a, "b, c", 'd']
+empty: []
+channels:
+  user.mentioned:
+  - email
+  - push
+nested:
+  name: >
+    folded
+    text
+
+    next
+  attrs: {}
+children:
+  - name: |+
+      kept
+
+  - inner:
+      name: deep
+    name: x
+`
+	var ok codecDoc
+	if err := (yamlCodec{}).Decode(strings.NewReader(input), &ok); err != nil {
+		t.Fatal(err)
+	}
+	if ok.Title != "It's here" || ok.Count != 7 || !ok.On || !reflect.DeepEqual(ok.Tags, []string{"a", "b, c", "d"}) {
+		t.Errorf("scalars %+v", ok)
+	}
+	if !reflect.DeepEqual(ok.Channels, map[string][]string{"user.mentioned": {"email", "push"}}) {
+		t.Errorf("channels %v", ok.Channels)
+	}
+	if ok.Nested.Name != "folded text\nnext\n" || ok.Nested.Attrs == nil || len(ok.Nested.Attrs) != 0 {
+		t.Errorf("nested %+v", ok.Nested)
+	}
+	if len(ok.Children) != 2 || ok.Children[0].Name != "kept\n\n" || ok.Children[1].Name != "x" ||
+		ok.Children[1].Inner == nil || ok.Children[1].Inner.Name != "deep" {
+		t.Errorf("children %+v", ok.Children)
+	}
+
+	var got codecDoc
+	for _, bad := range []string{
+		"",
+		"on: yes\n", // YAML 1.2 has no yes
+		"# only a comment\n",
+		"title: \"unterminated\n",
+		"title: x\n   count: 1\n",
+		"count: many\n",
+		"tags:\n  - a\n  b\n",
+	} {
+		if err := (yamlCodec{}).Decode(strings.NewReader(bad), &got); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
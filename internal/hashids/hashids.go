@@ -0,0 +1,156 @@
+// Package hashids encodes integer IDs as short opaque strings with the
+// Hashids algorithm (https://hashids.org), so that public IDs don't reveal
+// how many records there are or which IDs exist:
+//
+//	h := hashids.New("this is my salt", 8)
+//	h.Encode(1)          // "gB0NV05e"
+//	h.Decode("gB0NV05e") // 1, true
+//
+// The output is that of the other Hashids implementations with the default
+// alphabet, for one non-negative number. The salt is what keeps the IDs
+// from being decoded by others; changing it changes every ID.
+package hashids
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	defaultAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890"
+	defaultSeps     = "cfhistuCFHISTU"
+	sepDiv          = 3.5
+	guardDiv        = 12
+)
+
+// Hashids encodes with one salt and minimum length
+type Hashids struct {
+	salt      []byte
+	minLength int
+	alphabet  []byte
+	seps      []byte
+	guards    []byte
+}
+
+// New returns an encoder for salt whose IDs are at least minLength long
+func New(salt string, minLength int) *Hashids {
+	h := &Hashids{salt: []byte(salt), minLength: minLength}
+	alphabet := []byte(defaultAlphabet)
+
+	// Separators are the default ones, shuffled, topped up from the
+	// alphabet to keep their ratio
+	for _, c := range []byte(defaultSeps) {
+		if i := strings.IndexByte(string(alphabet), c); i >= 0 {
+			h.seps = append(h.seps, c)
+			alphabet = append(alphabet[:i], alphabet[i+1:]...)
+		}
+	}
+	shuffle(h.seps, h.salt)
+	if float64(len(alphabet))/float64(len(h.seps)) > sepDiv {
+		n := max(int(math.Ceil(float64(len(alphabet))/sepDiv)), 2)
+		if n > len(h.seps) {
+			diff := n - len(h.seps)
+			h.seps = append(h.seps, alphabet[:diff]...)
+			alphabet = alphabet[diff:]
+		} else {
+			h.seps = h.seps[:n]
+		}
+	}
+	shuffle(alphabet, h.salt)
+
+	// Guards pad short IDs
+	n := int(math.Ceil(float64(len(alphabet)) / guardDiv))
+	h.guards, h.alphabet = alphabet[:n], alphabet[n:]
+	return h
+}
+
+// Encode returns the ID of n, which must not be negative
+func (h *Hashids) Encode(n int) string {
+	alphabet := append([]byte(nil), h.alphabet...)
+	numbersHash := n % 100
+	lottery := alphabet[numbersHash%len(alphabet)]
+
+	buffer := append(append([]byte{lottery}, h.salt...), alphabet...)
+	shuffle(alphabet, buffer[:len(alphabet)])
+	out := append([]byte{lottery}, hash(n, alphabet)...)
+
+	if len(out) < h.minLength {
+		out = append([]byte{h.guards[(numbersHash+int(out[0]))%len(h.guards)]}, out...)
+		if len(out) < h.minLength {
+			out = append(out, h.guards[(numbersHash+int(out[2]))%len(h.guards)])
+		}
+	}
+	half := len(alphabet) / 2
+	for len(out) < h.minLength {
+		shuffle(alphabet, append([]byte(nil), alphabet...))
+		out = append(append(append([]byte(nil), alphabet[half:]...), out...), alphabet[:half]...)
+		if excess := len(out) - h.minLength; excess > 0 {
+			out = out[excess/2 : excess/2+h.minLength]
+		}
+	}
+	return string(out)
+}
+
+// Decode returns the number that s is the ID of. Anything that Encode
+// wouldn't have made, including other salts and lengths, is rejected.
+func (h *Hashids) Decode(s string) (int, bool) {
+	parts := strings.Split(strings.Map(func(r rune) rune {
+		if strings.ContainsRune(string(h.guards), r) {
+			return ' '
+		}
+		return r
+	}, s), " ")
+	body := parts[0]
+	if len(parts) == 2 || len(parts) == 3 {
+		body = parts[1]
+	}
+	if len(body) < 2 || strings.ContainsAny(body, string(h.seps)) {
+		return 0, false // one number only
+	}
+
+	alphabet := append([]byte(nil), h.alphabet...)
+	buffer := append(append([]byte{body[0]}, h.salt...), alphabet...)
+	shuffle(alphabet, buffer[:len(alphabet)])
+	n, ok := unhash(body[1:], alphabet)
+	if !ok || h.Encode(n) != s {
+		return 0, false
+	}
+	return n, true
+}
+
+// The digits of n in base len(alphabet)
+func hash(n int, alphabet []byte) []byte {
+	var out []byte
+	for {
+		out = append([]byte{alphabet[n%len(alphabet)]}, out...)
+		n /= len(alphabet)
+		if n == 0 {
+			return out
+		}
+	}
+}
+
+func unhash(s string, alphabet []byte) (int, bool) {
+	n := 0
+	for i := range len(s) {
+		digit := strings.IndexByte(string(alphabet), s[i])
+		if digit < 0 || n > (math.MaxInt-digit)/len(alphabet) {
+			return 0, false
+		}
+		n = n*len(alphabet) + digit
+	}
+	return n, true
+}
+
+// Shuffle alphabet in place, the same way every time for the same salt
+func shuffle(alphabet, salt []byte) {
+	if len(salt) == 0 {
+		return
+	}
+	for i, v, p := len(alphabet)-1, 0, 0; i > 0; i, v = i-1, v+1 {
+		v %= len(salt)
+		p += int(salt[v])
+		j := (int(salt[v]) + v + p) % i
+		alphabet[i], alphabet[j] = alphabet[j], alphabet[i]
+	}
+}
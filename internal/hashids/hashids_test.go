@@ -0,0 +1,50 @@
+package hashids
+
+import "testing"
+
+func TestEncode(t *testing.T) {
+	// Outputs of the reference JavaScript implementation
+	tests := []struct {
+		salt      string
+		minLength int
+		n         int
+		want      string
+	}{
+		{"this is my salt", 0, 12345, "NkK9"},
+		{"this is my salt", 8, 1, "gB0NV05e"},
+	}
+	for _, tt := range tests {
+		h := New(tt.salt, tt.minLength)
+		if got := h.Encode(tt.n); got != tt.want {
+			t.Errorf("New(%q, %d).Encode(%d) = %q, want %q", tt.salt, tt.minLength, tt.n, got, tt.want)
+		}
+		if n, ok := h.Decode(tt.want); !ok || n != tt.n {
+			t.Errorf("Decode(%q) = %d, %v, want %d", tt.want, n, ok, tt.n)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	h := New("go-spring", 8)
+	seen := map[string]bool{}
+	for n := range 2000 {
+		s := h.Encode(n)
+		if len(s) < 8 || seen[s] {
+			t.Fatalf("Encode(%d) = %q", n, s)
+		}
+		seen[s] = true
+		if got, ok := h.Decode(s); !ok || got != n {
+			t.Fatalf("Decode(%q) = %d, %v, want %d", s, got, ok, n)
+		}
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	h := New("go-spring", 8)
+	other := New("another salt", 8).Encode(42)
+	for _, s := range []string{"", "42", "x", other, h.Encode(42) + "a"} {
+		if n, ok := h.Decode(s); ok {
+			t.Errorf("Decode(%q) = %d, want rejected", s, n)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Package i18n translates the API's English messages into the language a
+// client asks for with Accept-Language. Messages are looked up by their
+// English text; a message without a translation stays in English.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default is the language of the messages in the code
+const Default = "en"
+
+// Source translates messages. Catalog is the built-in one; embedding
+// programs can add their own through a plugin.
+type Source interface {
+	// Translate returns message in lang, or false without a translation
+	Translate(lang, message string) (string, bool)
+
+	// Languages lists the languages Translate knows, as lower-case tags
+	Languages() []string
+}
+
+// Catalog holds translations by language tag, then English message
+type Catalog map[string]map[string]string
+
+func (c Catalog) Translate(lang, message string) (string, bool) {
+	t, ok := c[lang][message]
+	return t, ok && t != ""
+}
+
+func (c Catalog) Languages() []string {
+	return slices.Sorted(maps.Keys(c))
+}
+
+//go:embed messages/*.json
+var bundledFiles embed.FS
+
+var bundled = sync.OnceValue(func() Catalog {
+	c, err := load(Catalog{}, bundledFiles, "messages")
+	if err != nil {
+		panic(err) // the bundled files are checked by the tests
+	}
+	return c
+})
+
+// Bundled returns the catalogs that ship with the server, one per language.
+// The result is shared; Load copies it before adding to it.
+func Bundled() Catalog {
+	return bundled()
+}
+
+// Load returns base with the <lang>.json files of dir added, each a JSON
+// object from English message to translation. Entries of a file override
+// those of base for the same language.
+func Load(base Catalog, dir string) (Catalog, error) {
+	return load(base, os.DirFS(dir), ".")
+}
+
+func load(base Catalog, fsys fs.FS, dir string) (Catalog, error) {
+	c := Catalog{}
+	for lang, messages := range base {
+		c[lang] = maps.Clone(messages)
+	}
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || path.Ext(name) != ".json" {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		lang := strings.ToLower(strings.TrimSuffix(name, ".json"))
+		if c[lang] == nil {
+			c[lang] = map[string]string{}
+		}
+		maps.Copy(c[lang], messages)
+	}
+	return c, nil
+}
+
+// Negotiate picks the language for an Accept-Language header from the
+// available ones, by q value, matching fi-FI to fi when there is no fi-FI.
+// It returns Default when nothing matches.
+func Negotiate(header string, available []string) string {
+	type choice struct {
+		tag string
+		q   float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(v, 64); err == nil {
+				q = v
+			}
+		}
+		if tag != "" && q > 0 {
+			choices = append(choices, choice{tag, q})
+		}
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+
+	for _, c := range choices {
+		if c.tag == "*" {
+			return Default
+		}
+		primary, _, _ := strings.Cut(c.tag, "-")
+		for _, tag := range []string{c.tag, primary} {
+			if tag == Default || slices.Contains(available, tag) {
+				return tag
+			}
+		}
+	}
+	return Default
+}
@@ -0,0 +1,59 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	available := []string{"fi", "sv"}
+	for header, want := range map[string]string{
+		"":                        "en",
+		"fi":                      "fi",
+		"fi-FI,fi;q=0.9,en;q=0.8": "fi",
+		"de,sv;q=0.5":             "sv",
+		"en-GB,fi;q=0.5":          "en",
+		"de, *;q=0.1":             "en",
+		"fi;q=0,sv":               "sv",
+		"FI-fi":                   "fi",
+	} {
+		if got := Negotiate(header, available); got != want {
+			t.Errorf("Negotiate(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestBundled(t *testing.T) {
+	c := Bundled()
+	if got, ok := c.Translate("fi", "Article not found"); !ok || got != "Artikkelia ei löydy" {
+		t.Errorf("fi: %q, %v", got, ok)
+	}
+	if _, ok := c.Translate("en", "Article not found"); ok {
+		t.Error("English has a translation of itself")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "sv.json"), []byte(`{"Article not found": "Artikeln hittades inte"}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "fi.json"), []byte(`{"Article not found": "Juttua ei löydy"}`), 0o644)
+	c, err := Load(Bundled(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.Translate("sv", "Article not found"); got != "Artikeln hittades inte" {
+		t.Errorf("sv: %q", got)
+	}
+	if got, _ := c.Translate("fi", "Article not found"); got != "Juttua ei löydy" {
+		t.Errorf("fi override: %q", got)
+	}
+	if got, _ := Bundled().Translate("fi", "Article not found"); got != "Artikkelia ei löydy" {
+		t.Errorf("Load changed the bundled catalog: %q", got)
+	}
+
+	os.WriteFile(filepath.Join(dir, "de.json"), []byte(`{`), 0o644)
+	if _, err := Load(Bundled(), dir); err == nil {
+		t.Error("no error for a broken file")
+	}
+}
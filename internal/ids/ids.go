@@ -0,0 +1,81 @@
+// Package ids makes the string identifiers that articles can have besides
+// their integer IDs: ULIDs and version 7 UUIDs. Both start with a
+// millisecond timestamp followed by random bits, so they sort by creation
+// time, can't be guessed from each other, and don't collide between
+// servers that create articles independently.
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// NewULID returns a ULID timestamped t: 26 characters of Crockford base32,
+// e.g. 01JA2B3C4D5E6F7G8H9JKMNPQR
+func NewULID(t time.Time) string {
+	return encodeULID(timestamped(t))
+}
+
+// NewUUIDv7 returns a version 7 UUID timestamped t, e.g.
+// 01928f3e-7b1a-7c3d-9e4f-5a6b7c8d9e0f
+func NewUUIDv7(t time.Time) string {
+	b := timestamped(t)
+	b[6] = 0x70 | b[6]&0x0f // version 7
+	b[8] = 0x80 | b[8]&0x3f // RFC 9562 variant
+	s := hex.EncodeToString(b[:])
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+// 48 bits of Unix milliseconds, then 80 random bits
+func timestamped(t time.Time) [16]byte {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(t.UnixMilli())<<16)
+	rand.Read(b[6:])
+	return b
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// The 128 bits as 26 base32 digits, the first holding only 3 bits
+func encodeULID(b [16]byte) string {
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	out := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out)
+}
+
+// Valid reports whether s looks like an identifier of either kind, so that
+// route variables can tell them from integer IDs
+func Valid(s string) bool {
+	switch len(s) {
+	case 26:
+		return s[0] <= '7' && strings.Trim(strings.ToUpper(s), crockford) == ""
+	case 36:
+		for i, c := range s {
+			if i == 8 || i == 13 || i == 18 || i == 23 {
+				if c != '-' {
+					return false
+				}
+			} else if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Normalize returns s in the case New makes: upper-case ULIDs, lower-case UUIDs
+func Normalize(s string) string {
+	if len(s) == 26 {
+		return strings.ToUpper(s)
+	}
+	return strings.ToLower(s)
+}
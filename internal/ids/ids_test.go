@@ -0,0 +1,53 @@
+package ids
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	earlier, later := time.UnixMilli(1700000000000), time.UnixMilli(1700000000001)
+	for name, tt := range map[string]struct {
+		make    func(time.Time) string
+		pattern string
+	}{
+		"ulid":   {NewULID, `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`},
+		"uuidv7": {NewUUIDv7, `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+	} {
+		a, b, c := tt.make(earlier), tt.make(earlier), tt.make(later)
+		if !regexp.MustCompile(tt.pattern).MatchString(a) || !Valid(a) {
+			t.Errorf("%s: %q is malformed", name, a)
+		}
+		if a == b {
+			t.Errorf("%s: two identifiers for the same time are both %q", name, a)
+		}
+		if a >= c || b >= c {
+			t.Errorf("%s: %q and %q don't sort before the later %q", name, a, b, c)
+		}
+	}
+}
+
+func TestULIDTimestamp(t *testing.T) {
+	// The reference ULID spec example: 1469918176385 ms is 01ARYZ6S41
+	if got := NewULID(time.UnixMilli(1469918176385))[:10]; got != "01ARYZ6S41" {
+		t.Errorf("timestamp part %q, want 01ARYZ6S41", got)
+	}
+}
+
+func TestValid(t *testing.T) {
+	for s, want := range map[string]bool{
+		"01ARYZ6S41TSV4RRFFQ69G5FAV":                  true,
+		strings.ToLower("01ARYZ6S41TSV4RRFFQ69G5FAV"): true,
+		"81ARYZ6S41TSV4RRFFQ69G5FAV":                  false, // overflows 128 bits
+		"01ARYZ6S41TSV4RRFFQ69G5FAU":                  false, // U isn't Crockford
+		"01928f3e-7b1a-7c3d-9e4f-5a6b7c8d9e0f":        true,
+		"01928f3e7b1a-7c3d-9e4f-5a6b7c8d9e0f-":        false,
+		"42":                                          false,
+	} {
+		if Valid(s) != want {
+			t.Errorf("Valid(%q) = %v, want %v", s, !want, want)
+		}
+	}
+}
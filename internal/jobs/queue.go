@@ -0,0 +1,465 @@
+// Package jobs is a persistent queue of background work. Handlers enqueue
+// slow operations and return; a pool of workers runs them, retries failures
+// with exponential backoff and keeps jobs that run out of attempts as dead
+// letters for an administrator to inspect and retry.
+//
+// The queue is written to a gob file on every change. Jobs that were running
+// when the process stopped run again after a restart, so job handlers must
+// be safe to repeat.
+package jobs
+
+import (
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// State of a job
+type State string
+
+const (
+	StatePending State = "pending" // waiting for a worker, possibly until RunAt
+	StateRunning State = "running"
+	StateDone    State = "done"
+	StateDead    State = "dead" // failed MaxAttempts times
+)
+
+// Job is a unit of background work. Payload is the JSON the job was
+// enqueued with; Progress and Result are set by its handler with
+// ReportProgress and SetResult.
+type Job struct {
+	ID        int             `json:"id"`
+	Kind      string          `json:"kind"`
+	Payload   json.RawMessage `json:"payload"`
+	State     State           `json:"state"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error,omitempty"`
+	Progress  *Progress       `json:"progress,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Created   time.Time       `json:"created"`
+	RunAt     time.Time       `json:"run_at"`
+	Finished  time.Time       `json:"finished,omitzero"`
+}
+
+// Progress of a running job: Done of Total items
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// Handler runs a job of one kind. ctx is cancelled when the queue stops;
+// a job interrupted that way runs again later without using up an attempt.
+type Handler func(ctx context.Context, job Job) error
+
+// Options tune a Queue; zero values take the defaults
+type Options struct {
+	Workers     int           // concurrent jobs, default 2
+	MaxAttempts int           // attempts before a job is dead, default 5
+	Backoff     time.Duration // delay before the first retry, doubled after each, default 1s
+	MaxBackoff  time.Duration // default 1h
+	Retention   time.Duration // how long finished jobs are kept, default 24h
+	OnRemove    func(Job)     // called when a finished job is dropped, e.g. to delete its files
+	OnDead      func(Job)     // called when a job gives up, outside the queue's lock
+}
+
+var (
+	ErrNotFound = errors.New("job not found")
+	ErrNotDead  = errors.New("only dead jobs can be retried")
+)
+
+// Permanent wraps an error that retrying won't fix, such as invalid input;
+// the job becomes dead without using up its attempts
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Queue is a persistent job queue; create it with Open
+type Queue struct {
+	path     string // "" keeps the queue in memory only
+	opts     Options
+	handlers map[string]Handler
+
+	mu     sync.Mutex
+	jobs   []*Job // in ID order
+	nextID int
+
+	wake    chan struct{}
+	stop    context.CancelFunc // set while started
+	workers sync.WaitGroup
+}
+
+// The persisted queue
+type queueFile struct {
+	NextID int
+	Jobs   []*Job
+}
+
+// Open loads the queue kept at path, or starts an empty one if the file
+// does not exist yet. An empty path keeps the queue in memory.
+func Open(path string, opts Options) (*Queue, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = 2
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = time.Hour
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = 24 * time.Hour
+	}
+	q := &Queue{
+		path:     path,
+		opts:     opts,
+		handlers: make(map[string]Handler),
+		nextID:   1,
+		wake:     make(chan struct{}, opts.Workers),
+	}
+	if path == "" {
+		return q, nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var data queueFile
+	if err := gob.NewDecoder(file).Decode(&data); err != nil {
+		return nil, fmt.Errorf("read job queue %s: %w", path, err)
+	}
+	q.jobs, q.nextID = data.Jobs, max(data.NextID, 1)
+	for _, job := range q.jobs {
+		if job.State == StateRunning {
+			job.State = StatePending // interrupted by a crash
+		}
+	}
+	return q, nil
+}
+
+// Handle registers the handler for jobs of a kind. Register handlers
+// before Start; jobs of a kind without a handler fail.
+func (q *Queue) Handle(kind string, h Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = h
+}
+
+// Enqueue adds a job whose payload is marshaled as JSON
+func (q *Queue) Enqueue(kind string, payload any) (Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, err
+	}
+
+	q.mu.Lock()
+	now := time.Now()
+	job := &Job{ID: q.nextID, Kind: kind, Payload: data, State: StatePending, Created: now, RunAt: now}
+	q.nextID++
+	q.jobs = append(q.jobs, job)
+	err = q.save()
+	copied := *job
+	q.mu.Unlock()
+
+	q.notify()
+	return copied, err
+}
+
+// Get returns a copy of a job
+func (q *Queue) Get(id int) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if job := q.find(id); job != nil {
+		return *job, nil
+	}
+	return Job{}, ErrNotFound
+}
+
+// List returns copies of the jobs in a state, or of all jobs when state is
+// empty, in ID order
+func (q *Queue) List(state State) []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var out []Job
+	for _, job := range q.jobs {
+		if state == "" || job.State == state {
+			out = append(out, *job)
+		}
+	}
+	return out
+}
+
+// Retry puts a dead job back in the queue with fresh attempts
+func (q *Queue) Retry(id int) (Job, error) {
+	q.mu.Lock()
+	job := q.find(id)
+	if job == nil {
+		q.mu.Unlock()
+		return Job{}, ErrNotFound
+	}
+	if job.State != StateDead {
+		q.mu.Unlock()
+		return Job{}, fmt.Errorf("job %d is %s: %w", id, job.State, ErrNotDead)
+	}
+	job.State, job.Attempts, job.RunAt, job.Finished = StatePending, 0, time.Now(), time.Time{}
+	err := q.save()
+	copied := *job
+	q.mu.Unlock()
+
+	q.notify()
+	return copied, err
+}
+
+// Start runs the workers until Stop
+func (q *Queue) Start(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stop != nil {
+		return errors.New("job queue already started")
+	}
+	workCtx, cancel := context.WithCancel(context.Background())
+	q.stop = cancel
+	for range q.opts.Workers {
+		q.workers.Go(func() { q.work(workCtx) })
+	}
+	return nil
+}
+
+// Stop cancels the running jobs, which run again after the next Start, and
+// waits for the workers to return or for ctx to be done
+func (q *Queue) Stop(ctx context.Context) error {
+	q.mu.Lock()
+	stop := q.stop
+	q.stop = nil
+	q.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+	stop()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Wake a worker without blocking
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Queue) find(id int) *Job {
+	i, ok := slices.BinarySearchFunc(q.jobs, id, func(job *Job, id int) int { return job.ID - id })
+	if !ok {
+		return nil
+	}
+	return q.jobs[i]
+}
+
+// Run due jobs until ctx is cancelled, sleeping until the next retry or
+// Enqueue when there are none
+func (q *Queue) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, handler, wait := q.next()
+		if job.ID == 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-q.wake:
+			case <-timer.C:
+			}
+			timer.Stop()
+			continue
+		}
+		q.finish(ctx, job, q.run(ctx, job, handler))
+	}
+}
+
+// Claim the oldest due job; without one, return how long to wait
+func (q *Queue) next() (Job, Handler, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	wait := time.Minute
+	for _, job := range q.jobs {
+		if job.State != StatePending {
+			continue
+		}
+		if job.RunAt.After(now) {
+			wait = min(wait, job.RunAt.Sub(now))
+			continue
+		}
+		job.State = StateRunning
+		job.Attempts++
+		job.Progress = nil
+		if err := q.save(); err != nil {
+			log.Printf("Warning: save job queue: %v", err)
+		}
+		return *job, q.handlers[job.Kind], 0
+	}
+	return Job{}, nil, wait
+}
+
+func (q *Queue) run(ctx context.Context, job Job, handler Handler) (err error) {
+	if handler == nil {
+		return fmt.Errorf("no handler for %q jobs", job.Kind)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return handler(context.WithValue(ctx, runningKey{}, runningJob{q, job.ID}), job)
+}
+
+// The job a handler is running, found in its context
+type runningKey struct{}
+
+type runningJob struct {
+	q  *Queue
+	id int
+}
+
+// ReportProgress records how far the job running in ctx has come; it does
+// nothing outside a job. Progress is kept in memory only.
+func ReportProgress(ctx context.Context, done, total int) {
+	if run, ok := ctx.Value(runningKey{}).(runningJob); ok {
+		run.q.mu.Lock()
+		defer run.q.mu.Unlock()
+		if job := run.q.find(run.id); job != nil {
+			job.Progress = &Progress{Done: done, Total: total}
+		}
+	}
+}
+
+// SetResult stores v as JSON for clients polling the job running in ctx,
+// replacing an earlier result. It does nothing outside a job.
+func SetResult(ctx context.Context, v any) error {
+	run, ok := ctx.Value(runningKey{}).(runningJob)
+	if !ok {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	run.q.mu.Lock()
+	defer run.q.mu.Unlock()
+	if job := run.q.find(run.id); job != nil {
+		job.Result = data
+	}
+	return nil
+}
+
+// Record the outcome of a run: done, retried later or dead
+func (q *Queue) finish(ctx context.Context, run Job, err error) {
+	var dead *Job
+	q.mu.Lock()
+	defer func() {
+		q.mu.Unlock()
+		if dead != nil && q.opts.OnDead != nil {
+			q.opts.OnDead(*dead)
+		}
+	}()
+	job := q.find(run.ID)
+	if job == nil {
+		return
+	}
+
+	now := time.Now()
+	switch {
+	case err == nil:
+		job.State, job.LastError, job.Finished = StateDone, "", now
+	case ctx.Err() != nil:
+		// Stopped, not failed: run it again after the next Start
+		job.State = StatePending
+		job.Attempts--
+	case job.Attempts >= q.opts.MaxAttempts || errors.As(err, new(*permanentError)):
+		job.State, job.LastError, job.Finished = StateDead, err.Error(), now
+		log.Printf("Error: job %d (%s) failed %d times, giving up: %v", job.ID, job.Kind, job.Attempts, err)
+		copied := *job
+		dead = &copied
+	default:
+		job.State, job.LastError = StatePending, err.Error()
+		job.RunAt = now.Add(q.backoff(job.Attempts))
+		log.Printf("Warning: job %d (%s) failed, retrying at %s: %v", job.ID, job.Kind, job.RunAt.Format(time.TimeOnly), err)
+	}
+	if err := q.save(); err != nil {
+		log.Printf("Warning: save job queue: %v", err)
+	}
+}
+
+// Delay before retrying after the given number of failed attempts
+func (q *Queue) backoff(attempts int) time.Duration {
+	d := q.opts.Backoff
+	for range attempts - 1 {
+		if d >= q.opts.MaxBackoff {
+			break
+		}
+		d *= 2
+	}
+	return min(d, q.opts.MaxBackoff)
+}
+
+// Drop done jobs past their retention and write the queue; called with mu
+// held. Dead jobs are kept until they are retried.
+func (q *Queue) save() error {
+	cutoff := time.Now().Add(-q.opts.Retention)
+	q.jobs = slices.DeleteFunc(q.jobs, func(job *Job) bool {
+		if job.State != StateDone || !job.Finished.Before(cutoff) {
+			return false
+		}
+		if q.opts.OnRemove != nil {
+			q.opts.OnRemove(*job)
+		}
+		return true
+	})
+	if q.path == "" {
+		return nil
+	}
+
+	// Replace the file, so an interrupted save never leaves a torn queue
+	file, err := os.Create(q.path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(file).Encode(queueFile{NextID: q.nextID, Jobs: q.jobs}); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(q.path+".tmp", q.path)
+}
@@ -0,0 +1,97 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Poll until the job reaches a final state
+func waitFor(t *testing.T, q *Queue, id int, want State) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := q.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.State == want {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %d is %s after 5s, want %s", id, job.State, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRetryThenDeadLetter(t *testing.T) {
+	q, err := Open("", Options{MaxAttempts: 3, Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls atomic.Int32
+	q.Handle("flaky", func(ctx context.Context, job Job) error {
+		if calls.Add(1) == 2 {
+			return nil
+		}
+		return errors.New("unavailable")
+	})
+	q.Handle("broken", func(ctx context.Context, job Job) error { return errors.New("always") })
+	q.Start(context.Background())
+	defer q.Stop(context.Background())
+
+	flaky, _ := q.Enqueue("flaky", map[string]int{"n": 1})
+	if job := waitFor(t, q, flaky.ID, StateDone); job.Attempts != 2 || string(job.Payload) != `{"n":1}` {
+		t.Errorf("flaky job %+v", job)
+	}
+
+	broken, _ := q.Enqueue("broken", nil)
+	if job := waitFor(t, q, broken.ID, StateDead); job.Attempts != 3 || job.LastError != "always" {
+		t.Errorf("dead job %+v", job)
+	}
+	if dead := q.List(StateDead); len(dead) != 1 || dead[0].ID != broken.ID {
+		t.Errorf("dead letters %+v", dead)
+	}
+	if _, err := q.Retry(flaky.ID); !errors.Is(err, ErrNotDead) {
+		t.Errorf("Retry of a done job: %v", err)
+	}
+	if job, err := q.Retry(broken.ID); err != nil || job.State != StatePending || job.Attempts != 0 {
+		t.Errorf("Retry = %+v, %v", job, err)
+	}
+}
+
+func TestQueueSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.gob")
+	q, err := Open(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Not started, so the job stays queued
+	job, err := q.Enqueue("thumbnail", struct{ ID int }{7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q, err = Open(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ran := make(chan string, 1)
+	q.Handle("thumbnail", func(ctx context.Context, job Job) error {
+		ran <- string(job.Payload)
+		return nil
+	})
+	q.Start(context.Background())
+	defer q.Stop(context.Background())
+	if payload := <-ran; payload != `{"ID":7}` {
+		t.Errorf("payload %s", payload)
+	}
+	waitFor(t, q, job.ID, StateDone)
+	if next, _ := q.Enqueue("thumbnail", nil); next.ID != job.ID+1 {
+		t.Errorf("next job ID %d, want %d", next.ID, job.ID+1)
+	}
+}
@@ -0,0 +1,121 @@
+package keyring
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// LoadFile reads a keys file, a JSON array of Key whose first key
+// encrypts. With w, wrapped keys are unwrapped, and keys in plaintext or
+// wrapped with an older version of the master key are wrapped anew, after
+// which the file is rewritten to hold only wrapped keys; rewrapped counts
+// them. Without w, wrapped keys are an error.
+func LoadFile(ctx context.Context, path string, w Wrapper) (k *Keyring, rewrapped int, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	var keys []Key
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, 0, err
+	}
+	if w != nil {
+		if rewrapped, err = unwrapKeys(ctx, keys, w); err != nil {
+			return nil, 0, err
+		}
+		if rewrapped > 0 {
+			if err := writeFile(path, keys); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+	k, err = New(keys)
+	return k, rewrapped, err
+}
+
+// Fill in the Key of wrapped keys, wrapping those that need it; the count
+// of those
+func unwrapKeys(ctx context.Context, keys []Key, w Wrapper) (rewrapped int, err error) {
+	for i := range keys {
+		key := &keys[i]
+		var raw []byte
+		current := false
+		if key.WrappedKey != "" {
+			wrapped, err := base64.StdEncoding.DecodeString(key.WrappedKey)
+			if err != nil {
+				return 0, fmt.Errorf("key %s: wrapped_key is not base64", key.ID)
+			}
+			if raw, current, err = w.Unwrap(ctx, wrapped); err != nil {
+				return 0, fmt.Errorf("unwrap key %s: %w", key.ID, err)
+			}
+			key.Key = base64.StdEncoding.EncodeToString(raw)
+		} else if raw, err = base64.StdEncoding.DecodeString(key.Key); err != nil || len(raw) != KeySize {
+			return 0, fmt.Errorf("key %s: want %d bytes in base64", key.ID, KeySize)
+		}
+		if current {
+			continue
+		}
+		wrapped, err := w.Wrap(ctx, raw)
+		if err != nil {
+			return 0, fmt.Errorf("wrap key %s: %w", key.ID, err)
+		}
+		key.WrappedKey = base64.StdEncoding.EncodeToString(wrapped)
+		rewrapped++
+	}
+	return rewrapped, nil
+}
+
+// AddKey generates a data key with id and makes it the first, and so the
+// primary, key of the file at path, which is created if it is missing.
+// With w, the key is stored wrapped and so are the others.
+func AddKey(ctx context.Context, path, id string, w Wrapper) error {
+	var keys []Key
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return err
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+	if id == "" || slices.ContainsFunc(keys, func(k Key) bool { return k.ID == id }) {
+		return fmt.Errorf("key id %q is empty or taken", id)
+	}
+	raw := make([]byte, KeySize)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	keys = slices.Insert(keys, 0, Key{ID: id, Key: base64.StdEncoding.EncodeToString(raw)})
+	if w != nil {
+		if _, err := unwrapKeys(ctx, keys, w); err != nil {
+			return err
+		}
+	}
+	return writeFile(path, keys)
+}
+
+// Write keys to path through a temporary file, so a failed write leaves
+// the old keys. Wrapped keys are written without their plaintext.
+func writeFile(path string, keys []Key) error {
+	keys = slices.Clone(keys)
+	for i := range keys {
+		if keys[i].WrappedKey != "" {
+			keys[i].Key = ""
+		}
+	}
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
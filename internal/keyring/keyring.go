@@ -0,0 +1,121 @@
+// Package keyring encrypts data with AES-256-GCM under named data keys. A
+// ciphertext is stored with the ID of the key that made it, so keys can be
+// rotated: the primary key encrypts, and the others still decrypt what they
+// encrypted until it is rewritten.
+package keyring
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// KeySize is the length of a data key: 32 bytes for AES-256
+const KeySize = 32
+
+// Key is a data key as listed in a keys file: an ID and 32 random bytes in
+// base64, or those bytes wrapped by a Wrapper
+type Key struct {
+	ID         string `json:"id"`
+	Key        string `json:"key,omitempty"`
+	WrappedKey string `json:"wrapped_key,omitempty"`
+}
+
+// Wrapper encrypts data keys with a master key kept elsewhere, such as in
+// a cloud KMS (see the kms package), so they are never stored in plaintext
+type Wrapper interface {
+	// Wrap encrypts a data key with the current version of the master key
+	Wrap(ctx context.Context, key []byte) ([]byte, error)
+	// Unwrap decrypts a wrapped key. current is false for a key wrapped
+	// with another version of the master key, which should be wrapped
+	// again.
+	Unwrap(ctx context.Context, wrapped []byte) (key []byte, current bool, err error)
+}
+
+var (
+	// ErrUnknownKey is returned for a ciphertext of a key the keyring lacks
+	ErrUnknownKey = errors.New("keyring: unknown key")
+	// ErrDecrypt is returned for a ciphertext that was changed, or made by
+	// another key of the same ID
+	ErrDecrypt = errors.New("keyring: message authentication failed")
+)
+
+// Keyring holds the data keys by ID
+type Keyring struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+}
+
+// New returns a keyring of keys; the first is the primary key
+func New(keys []Key) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no keys")
+	}
+	k := &Keyring{primary: keys[0].ID, aeads: map[string]cipher.AEAD{}}
+	for i, key := range keys {
+		if key.ID == "" {
+			return nil, fmt.Errorf("key %d: id is required", i+1)
+		}
+		if _, dup := k.aeads[key.ID]; dup {
+			return nil, fmt.Errorf("key %s is listed twice", key.ID)
+		}
+		if key.Key == "" && key.WrappedKey != "" {
+			return nil, fmt.Errorf("key %s is wrapped and needs a KMS", key.ID)
+		}
+		raw, err := base64.StdEncoding.DecodeString(key.Key)
+		if err != nil || len(raw) != KeySize {
+			return nil, fmt.Errorf("key %s: want %d bytes in base64", key.ID, KeySize)
+		}
+		aead, err := newAEAD(raw)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key.ID, err)
+		}
+		k.aeads[key.ID] = aead
+	}
+	return k, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Primary is the ID of the key that Encrypt uses
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// Encrypt seals plaintext with the primary key, returning the key's ID
+// with the ciphertext. The random nonce is the ciphertext's first bytes.
+func (k *Keyring) Encrypt(plaintext []byte) (keyID string, ciphertext []byte, err error) {
+	aead := k.aeads[k.primary]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return k.primary, aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt opens a ciphertext made by Encrypt with the key keyID
+func (k *Keyring) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	aead, ok := k.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,122 @@
+package keyring
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testKey(id string, fill byte) Key {
+	return Key{ID: id, Key: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{fill}, KeySize))}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	old, err := New([]Key{testKey("k1", 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID, sealed, err := old.Encrypt([]byte("secret plans"))
+	if err != nil || keyID != "k1" {
+		t.Fatalf("Encrypt = %q, %v", keyID, err)
+	}
+	if bytes.Contains(sealed, []byte("secret")) {
+		t.Error("ciphertext contains the plaintext")
+	}
+
+	// After rotation the new key encrypts and the old one still decrypts
+	rotated, err := New([]Key{testKey("k2", 2), testKey("k1", 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := rotated.Decrypt(keyID, sealed); err != nil || string(got) != "secret plans" {
+		t.Errorf("Decrypt = %q, %v", got, err)
+	}
+	if keyID, _, _ := rotated.Encrypt([]byte("x")); keyID != "k2" {
+		t.Errorf("rotated keyring encrypts with %q", keyID)
+	}
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := rotated.Decrypt(keyID, tampered); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("tampered ciphertext: %v", err)
+	}
+	other, _ := New([]Key{testKey("k1", 3)})
+	if _, err := other.Decrypt(keyID, sealed); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("another key of the same ID: %v", err)
+	}
+	if _, err := old.Decrypt("k2", sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("unknown key: %v", err)
+	}
+}
+
+func TestNewRejectsBadKeys(t *testing.T) {
+	for _, keys := range [][]Key{
+		nil,
+		{testKey("", 1)},
+		{{ID: "k1", Key: "c2hvcnQ="}},
+		{{ID: "k1", WrappedKey: "d3JhcHBlZA=="}},
+		{testKey("k1", 1), testKey("k1", 2)},
+	} {
+		if _, err := New(keys); err == nil {
+			t.Errorf("New(%v) accepted", keys)
+		}
+	}
+}
+
+// Wraps by prefixing the version of a pretend master key
+type fakeWrapper struct{ version byte }
+
+func (f *fakeWrapper) Wrap(ctx context.Context, key []byte) ([]byte, error) {
+	return append([]byte{f.version}, key...), nil
+}
+
+func (f *fakeWrapper) Unwrap(ctx context.Context, wrapped []byte) ([]byte, bool, error) {
+	return wrapped[1:], wrapped[0] == f.version, nil
+}
+
+func TestLoadFileWrapsKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	data, _ := json.Marshal([]Key{testKey("k1", 1)})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// A plaintext key is wrapped and the file keeps only the wrapped key
+	w := &fakeWrapper{version: 1}
+	k, rewrapped, err := LoadFile(context.Background(), path, w)
+	if err != nil || rewrapped != 1 || k.Primary() != "k1" {
+		t.Fatalf("LoadFile = %d, %v", rewrapped, err)
+	}
+	data, _ = os.ReadFile(path)
+	if bytes.Contains(data, []byte(testKey("k1", 1).Key)) {
+		t.Errorf("file still holds the plaintext key: %s", data)
+	}
+	if _, _, err := LoadFile(context.Background(), path, nil); err == nil {
+		t.Error("wrapped keys loaded without a wrapper")
+	}
+
+	// A new key is added wrapped; after the master key rotates both are
+	// wrapped anew, and the old key still decrypts
+	_, sealed, _ := k.Encrypt([]byte("secret"))
+	if err := AddKey(context.Background(), path, "k2", w); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddKey(context.Background(), path, "k2", w); err == nil {
+		t.Error("AddKey accepted a taken id")
+	}
+	if _, rewrapped, _ := LoadFile(context.Background(), path, w); rewrapped != 0 {
+		t.Errorf("%d keys rewrapped without a rotation", rewrapped)
+	}
+	w.version = 2
+	k, rewrapped, err = LoadFile(context.Background(), path, w)
+	if err != nil || rewrapped != 2 || k.Primary() != "k2" {
+		t.Fatalf("after rotation LoadFile = %d, %v", rewrapped, err)
+	}
+	if got, err := k.Decrypt("k1", sealed); err != nil || string(got) != "secret" {
+		t.Errorf("Decrypt = %q, %v", got, err)
+	}
+}
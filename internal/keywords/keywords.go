@@ -0,0 +1,214 @@
+// Package keywords picks the key phrases of a text, as RAKE (Rapid
+// Automatic Keyword Extraction) does: common words, punctuation and
+// Markdown structure split the text into candidate phrases of content
+// words. Tags should be short and about what the text keeps coming back
+// to, so words rank by how often they appear, counting a plural in -s with
+// its singular, and a phrase only when the text repeats it, by how often
+// its words appear.
+package keywords
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"go-spring/internal/langdetect"
+)
+
+// Words that seldom make a keyword on their own, next to the common words
+// langdetect knows; most articles are in English, so its list is longer
+var fillers = map[string][]string{
+	"en": {"a", "i", "if", "so", "no", "do", "does", "did", "done", "had", "he", "she", "his", "her", "its", "our", "your", "them", "then", "than",
+		"these", "those", "who", "whom", "whose", "where", "why", "how", "all", "any", "both", "each", "few", "most", "other", "some", "such",
+		"only", "own", "same", "too", "very", "just", "also", "into", "over", "under", "again", "once", "here", "out", "up", "down", "off",
+		"should", "could", "may", "might", "must", "shall", "one", "two", "get", "gets", "got", "make", "makes", "made", "use", "used", "uses",
+		"using", "like", "new", "many", "much", "well", "way", "even", "still", "because", "while", "after", "before", "between", "through",
+		"during", "without", "within", "being", "having", "let", "lets", "see", "now", "first", "last", "every", "another", "often", "always",
+		"never", "however", "yet", "say", "says", "said", "know", "need", "needs", "want", "take", "takes", "give", "gives", "go", "goes",
+		"come", "comes", "thing", "things", "lot", "me", "my", "us", "him", "am", "don't", "it's", "that's", "there's", "can't", "isn't"},
+}
+
+// Markdown that isn't prose: code, link targets, URLs and HTML tags
+var markup = regexp.MustCompile("(?s)```.*?```|`[^`]*`|\\]\\([^)]*\\)|https?://\\S+|<[^>]*>")
+
+// The longest phrase that makes a keyword, in words; longer runs of
+// content words are taken a few at a time
+const maxWords = 3
+
+// Extract returns up to n key phrases of text in lower case, best first.
+// lang is the text's language tag, or "" to count the common words of
+// every language langdetect knows.
+func Extract(text, lang string, n int) []string {
+	stop := stopWords(lang)
+	var phrases [][]string
+	var phrase []string
+	flush := func() {
+		for len(phrase) > 0 {
+			size := min(len(phrase), maxWords)
+			phrases = append(phrases, phrase[:size])
+			phrase = phrase[size:]
+		}
+	}
+	for _, field := range strings.FieldsFunc(strings.ToLower(markup.ReplaceAllString(blocks(text), " ")), unicode.IsSpace) {
+		// Punctuation around a word ends the phrase there
+		word := strings.TrimRightFunc(field, isBreak)
+		ends := word != field
+		if trimmed := strings.TrimLeftFunc(word, isBreak); trimmed != word {
+			flush()
+			word = trimmed
+		}
+		if strings.ContainsFunc(word, isBreak) || !isContentWord(word, stop) {
+			flush()
+		} else {
+			phrase = append(phrase, word)
+		}
+		if ends {
+			flush()
+		}
+	}
+	flush()
+
+	// A word and its plural in -s count as one, under the form used more
+	uses := map[string]int{}
+	for _, p := range phrases {
+		for _, word := range p {
+			uses[word]++
+		}
+	}
+	form := map[string]string{}
+	for word, n := range uses {
+		if singular, ok := strings.CutSuffix(word, "s"); ok && uses[singular] > 0 {
+			if n > uses[singular] {
+				form[singular] = word
+			} else {
+				form[word] = singular
+			}
+		}
+	}
+	for _, p := range phrases {
+		for i, word := range p {
+			if f, ok := form[word]; ok {
+				p[i] = f
+			}
+		}
+	}
+
+	// Words score how often they appear; a phrase the text repeats, alone
+	// or within a longer one, is a candidate too, scoring what its words
+	// do on average each time
+	freq, counts := map[string]int{}, map[string]int{}
+	for _, p := range phrases {
+		for _, word := range p {
+			freq[word]++
+		}
+		for n := 2; n <= len(p); n++ {
+			for i := 0; i+n <= len(p); i++ {
+				counts[strings.Join(p[i:i+n], " ")]++
+			}
+		}
+	}
+	type candidate struct {
+		words []string
+		score float64
+		first int
+	}
+	var ranked []candidate
+	seen := map[string]bool{}
+	for i, p := range phrases {
+		for _, word := range p {
+			if !seen[word] {
+				seen[word] = true
+				ranked = append(ranked, candidate{[]string{word}, float64(freq[word]), i})
+			}
+		}
+		for n := 2; n <= len(p); n++ {
+			for j := 0; j+n <= len(p); j++ {
+				words := p[j : j+n]
+				key := strings.Join(words, " ")
+				if counts[key] < 2 || seen[key] {
+					continue
+				}
+				seen[key] = true
+				sum := 0
+				for _, word := range words {
+					sum += freq[word]
+				}
+				ranked = append(ranked, candidate{words, float64(counts[key]*sum) / float64(n), i})
+			}
+		}
+	}
+	slices.SortStableFunc(ranked, func(a, b candidate) int {
+		if a.score != b.score {
+			return cmp.Compare(b.score, a.score)
+		}
+		if a.first != b.first {
+			return a.first - b.first
+		}
+		return len(b.words) - len(a.words) // the phrase before its words
+	})
+
+	// A phrase whose words a better one has already said adds nothing
+	var keywords []string
+	clear(seen)
+	for _, c := range ranked {
+		if len(keywords) == n {
+			break
+		}
+		if !slices.ContainsFunc(c.words, func(w string) bool { return !seen[w] }) {
+			continue
+		}
+		for _, word := range c.words {
+			seen[word] = true
+		}
+		keywords = append(keywords, strings.Join(c.words, " "))
+	}
+	return keywords
+}
+
+// Text with a full stop at the end of each heading, list item, table row
+// and paragraph, as Markdown leaves them out
+func blocks(text string) string {
+	var b strings.Builder
+	for line := range strings.Lines(text) {
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.ContainsAny(trimmed[:1], "#-*+>|0123456789") {
+			b.WriteString(line) // a line of a paragraph goes on
+			continue
+		}
+		b.WriteString(" . " + line + " . ")
+	}
+	return b.String()
+}
+
+func stopWords(lang string) map[string]bool {
+	lang, _, _ = strings.Cut(lang, "-")
+	if len(langdetect.StopWords(lang)) == 0 {
+		lang = "" // a language langdetect doesn't know
+	}
+	stop := map[string]bool{}
+	for _, word := range langdetect.StopWords(lang) {
+		stop[word] = true
+	}
+	for l, words := range fillers {
+		if lang == "" || l == lang {
+			for _, word := range words {
+				stop[word] = true
+			}
+		}
+	}
+	return stop
+}
+
+// Whether c ends a phrase; hyphens and apostrophes join words
+func isBreak(c rune) bool {
+	return !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '-' && c != '\''
+}
+
+// Words of three letters or more that aren't common words or numbers
+func isContentWord(word string, stop map[string]bool) bool {
+	if len([]rune(word)) < 3 || stop[word] {
+		return false
+	}
+	return strings.ContainsFunc(word, unicode.IsLetter)
+}
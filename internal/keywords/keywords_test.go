@@ -0,0 +1,41 @@
+package keywords
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	text := `# Raft consensus
+
+Raft consensus keeps a replicated log in step across a cluster. The leader
+appends entries to its log and sends them to the followers; once most of
+the cluster has an entry, the leader commits it. See https://raft.github.io
+and run ` + "`go test -tags raft`" + `.
+
+When the leader fails, the followers hold a leader election.`
+	got := Extract(text, "en", 4)
+	if len(got) != 4 {
+		t.Fatalf("got %q", got)
+	}
+	for _, want := range []string{"raft consensus", "leader"} {
+		if !slices.Contains(got, want) {
+			t.Errorf("%q lacks %q", got, want)
+		}
+	}
+	for _, word := range got {
+		if word == "https" || word == "tags" || word == "the" {
+			t.Errorf("%q has %q", got, word)
+		}
+	}
+
+	if got := Extract("Sauna on lämmin. Sauna on puulämmitteinen, ja järvi on lähellä.", "fi", 2); !slices.Equal(got, []string{"sauna", "lämmin"}) {
+		t.Errorf("fi: %q", got)
+	}
+	if got := Extract("Tests pass. The test is green, and more tests fail.", "en", 1); !slices.Equal(got, []string{"tests"}) {
+		t.Errorf("plural: %q", got)
+	}
+	if got := Extract("It is what it is.", "", 5); len(got) != 0 {
+		t.Errorf("no content words: %q", got)
+	}
+}
@@ -0,0 +1,125 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Where a GCE, GKE or Cloud Run instance gets the access tokens of its
+// service account
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// GCP wraps keys with a Cloud KMS symmetric key. Encrypt uses the key's
+// primary version; Decrypt tells whether that made a ciphertext, so keys
+// wrapped before the primary version changed count as not current.
+type GCP struct {
+	Endpoint string // e.g. https://cloudkms.googleapis.com
+	Name     string // projects/<p>/locations/<l>/keyRings/<r>/cryptoKeys/<k>
+
+	// OAuth access token; the instance's service account's from the
+	// metadata server if empty
+	AccessToken string
+
+	Client *http.Client
+
+	tokenMutex sync.Mutex
+	token      string
+	expires    time.Time
+}
+
+func (k *GCP) Wrap(ctx context.Context, key []byte) ([]byte, error) {
+	var out struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	err := k.call(ctx, "encrypt", map[string]any{"plaintext": key}, &out)
+	return out.Ciphertext, err
+}
+
+func (k *GCP) Unwrap(ctx context.Context, wrapped []byte) ([]byte, bool, error) {
+	var out struct {
+		Plaintext   []byte `json:"plaintext"`
+		UsedPrimary bool   `json:"usedPrimary"`
+	}
+	err := k.call(ctx, "decrypt", map[string]any{"ciphertext": wrapped}, &out)
+	return out.Plaintext, out.UsedPrimary, err
+}
+
+// Call cryptoKeys.encrypt or decrypt; byte slices travel as base64, as
+// json encodes them
+func (k *GCP) call(ctx context.Context, method string, in, out any) error {
+	token, err := k.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	url := strings.TrimRight(k.Endpoint, "/") + "/v1/" + k.Name + ":" + method
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := k.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error struct{ Message string }
+		}
+		json.Unmarshal(data, &e)
+		return fmt.Errorf("gcp kms %s: %s: %s", method, resp.Status, e.Error.Message)
+	}
+	return json.Unmarshal(data, out)
+}
+
+// AccessToken, or a token from the metadata server that is renewed a
+// minute before it expires
+func (k *GCP) accessToken(ctx context.Context) (string, error) {
+	if k.AccessToken != "" {
+		return k.AccessToken, nil
+	}
+	k.tokenMutex.Lock()
+	defer k.tokenMutex.Unlock()
+	if k.token != "" && time.Now().Before(k.expires) {
+		return k.token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := k.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("gcp kms: no KMS_ACCESS_TOKEN and no metadata server: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("gcp kms: metadata server token: %s", resp.Status)
+	}
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	k.token = out.AccessToken
+	k.expires = time.Now().Add(time.Duration(out.ExpiresIn)*time.Second - time.Minute)
+	return k.token, nil
+}
@@ -0,0 +1,63 @@
+// Package kms wraps data keys with a master key in AWS KMS or Google Cloud
+// KMS, through their REST APIs, so that the keys of ENCRYPTION_KEYS_FILE
+// are stored only wrapped. Both report whether a key was wrapped with the
+// master key's current version, for keyring.LoadFile to wrap it again
+// after a rotation.
+package kms
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-spring/internal/config"
+	"go-spring/internal/keyring"
+)
+
+// Schemes of KMS_KEY
+const (
+	schemeAWS = "aws-kms://"
+	schemeGCP = "gcp-kms://"
+)
+
+// How long a KMS call may take
+const callTimeout = 30 * time.Second
+
+// New returns the wrapper of KMS_KEY: aws-kms://<key ID, ARN or alias> or
+// gcp-kms://projects/<p>/locations/<l>/keyRings/<r>/cryptoKeys/<k>
+func New(cfg config.Config) (keyring.Wrapper, error) {
+	client := &http.Client{Timeout: callTimeout}
+	switch {
+	case strings.HasPrefix(cfg.KMSKey, schemeAWS):
+		if cfg.KMSAccessKey == "" || cfg.KMSSecretKey == "" {
+			return nil, errors.New("aws-kms needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+		k := &AWS{
+			Endpoint:  cfg.KMSEndpoint,
+			Region:    cfg.KMSRegion,
+			KeyID:     strings.TrimPrefix(cfg.KMSKey, schemeAWS),
+			AccessKey: cfg.KMSAccessKey,
+			SecretKey: cfg.KMSSecretKey,
+			Token:     cfg.KMSSessionToken,
+			Client:    client,
+		}
+		if k.Endpoint == "" {
+			k.Endpoint = "https://kms." + k.Region + ".amazonaws.com"
+		}
+		return k, nil
+	case strings.HasPrefix(cfg.KMSKey, schemeGCP):
+		k := &GCP{
+			Endpoint:    cfg.KMSEndpoint,
+			Name:        strings.TrimPrefix(cfg.KMSKey, schemeGCP),
+			AccessToken: cfg.KMSAccessToken,
+			Client:      client,
+		}
+		if k.Endpoint == "" {
+			k.Endpoint = "https://cloudkms.googleapis.com"
+		}
+		return k, nil
+	}
+	return nil, fmt.Errorf("unsupported KMS_KEY %q (want aws-kms://... or gcp-kms://...)", cfg.KMSKey)
+}
@@ -0,0 +1,118 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-spring/internal/config"
+)
+
+// A fake AWS KMS whose ciphertexts are the ARN of the key, a | and the
+// plaintext
+func TestAWS(t *testing.T) {
+	arn := "arn:aws:kms:eu-north-1:1:key/one"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") ||
+			!strings.Contains(r.Header.Get("Authorization"), "/eu-north-1/kms/aws4_request") {
+			http.Error(w, `{"__type":"AccessDenied"}`, http.StatusForbidden)
+			return
+		}
+		var in struct {
+			KeyId          string
+			Plaintext      []byte
+			CiphertextBlob []byte
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.Encrypt":
+			json.NewEncoder(w).Encode(map[string]any{"CiphertextBlob": append([]byte(arn+"|"), in.Plaintext...)})
+		case "TrentService.Decrypt":
+			keyARN, plaintext, _ := bytes.Cut(in.CiphertextBlob, []byte("|"))
+			json.NewEncoder(w).Encode(map[string]any{"KeyId": string(keyARN), "Plaintext": plaintext})
+		case "TrentService.DescribeKey":
+			json.NewEncoder(w).Encode(map[string]any{"KeyMetadata": map[string]string{"Arn": arn}})
+		default:
+			http.Error(w, `{"__type":"UnknownOperation"}`, http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	w, err := New(config.Config{KMSKey: "aws-kms://alias/go-spring", KMSEndpoint: srv.URL,
+		KMSRegion: "eu-north-1", KMSAccessKey: "AKID", KMSSecretKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	wrapped, err := w.Wrap(ctx, []byte("data key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, current, err := w.Unwrap(ctx, wrapped); err != nil || string(key) != "data key" || !current {
+		t.Errorf("Unwrap = %q, %v, %v", key, current, err)
+	}
+
+	// After the alias moves to another key, old ciphertexts are not current
+	arn = "arn:aws:kms:eu-north-1:1:key/two"
+	w.(*AWS).arn = ""
+	if _, current, err := w.Unwrap(ctx, wrapped); err != nil || current {
+		t.Errorf("after rotation Unwrap = %v, %v", current, err)
+	}
+
+	if _, err := New(config.Config{KMSKey: "aws-kms://alias/go-spring"}); err == nil {
+		t.Error("aws-kms without credentials accepted")
+	}
+	if _, err := New(config.Config{KMSKey: "vault://x"}); err == nil {
+		t.Error("unknown scheme accepted")
+	}
+}
+
+// A fake Cloud KMS whose ciphertexts are the primary version and the
+// plaintext
+func TestGCP(t *testing.T) {
+	name := "projects/p/locations/global/keyRings/r/cryptoKeys/k"
+	primary := byte(1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, `{"error":{"message":"unauthenticated"}}`, http.StatusUnauthorized)
+			return
+		}
+		var in struct{ Plaintext, Ciphertext []byte }
+		json.NewDecoder(r.Body).Decode(&in)
+		switch r.URL.Path {
+		case "/v1/" + name + ":encrypt":
+			json.NewEncoder(w).Encode(map[string]any{"ciphertext": append([]byte{primary}, in.Plaintext...)})
+		case "/v1/" + name + ":decrypt":
+			json.NewEncoder(w).Encode(map[string]any{"plaintext": in.Ciphertext[1:], "usedPrimary": in.Ciphertext[0] == primary})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	w, err := New(config.Config{KMSKey: "gcp-kms://" + name, KMSEndpoint: srv.URL, KMSAccessToken: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	wrapped, err := w.Wrap(ctx, []byte("data key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, current, err := w.Unwrap(ctx, wrapped); err != nil || string(key) != "data key" || !current {
+		t.Errorf("Unwrap = %q, %v, %v", key, current, err)
+	}
+	primary = 2
+	if _, current, err := w.Unwrap(ctx, wrapped); err != nil || current {
+		t.Errorf("after rotation Unwrap = %v, %v", current, err)
+	}
+
+	w.(*GCP).AccessToken = "wrong"
+	if _, err := w.Wrap(ctx, []byte("x")); err == nil || !strings.Contains(err.Error(), "unauthenticated") {
+		t.Errorf("bad token: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+// Package langdetect guesses the language of a text from the short words
+// that make up much of any running text in its language: articles,
+// pronouns, conjunctions and prepositions. That needs no model data and is
+// reliable from a sentence or two on; shorter or mixed texts get no answer
+// rather than a wrong one.
+package langdetect
+
+import (
+	"strings"
+	"unicode"
+)
+
+// The words of each language, by ISO 639-1 code. Words two languages share
+// count for both, so they seldom decide.
+var profiles = map[string][]string{
+	"en": {"the", "and", "of", "to", "in", "is", "that", "it", "for", "was", "with", "as", "on", "are", "this", "be", "by", "not", "or", "from", "have", "but", "which", "you", "they", "at", "an", "we", "has", "were", "their", "there", "been", "would", "what", "when", "can", "will", "more", "about"},
+	"fi": {"ja", "on", "ei", "se", "että", "oli", "ovat", "kun", "mutta", "tai", "myös", "niin", "kuin", "ole", "tämä", "joka", "jos", "sen", "hän", "nyt", "vain", "voi", "olla", "mitä", "jo", "sekä", "siitä", "tämän", "heidän", "kanssa", "joten", "koska", "vielä", "mukaan", "jotka", "eivät", "olisi", "kaikki", "hyvin", "ennen"},
+	"sv": {"och", "att", "det", "som", "en", "på", "är", "av", "för", "med", "till", "den", "har", "de", "inte", "om", "ett", "men", "var", "jag", "sig", "från", "vi", "så", "kan", "man", "när", "också", "efter", "eller", "nu", "hade", "skulle", "vid", "mot", "under", "sina", "detta", "vara", "hur"},
+	"de": {"der", "die", "und", "in", "den", "von", "zu", "das", "mit", "sich", "des", "auf", "für", "ist", "im", "dem", "nicht", "ein", "eine", "als", "auch", "es", "an", "werden", "aus", "er", "hat", "dass", "sie", "nach", "wird", "bei", "einer", "um", "am", "sind", "noch", "wie", "einem", "über"},
+	"fr": {"de", "la", "le", "et", "les", "des", "en", "un", "du", "une", "que", "est", "pour", "qui", "dans", "par", "plus", "pas", "au", "sur", "ne", "se", "ce", "il", "sont", "avec", "son", "aux", "mais", "ou", "nous", "vous", "elle", "comme", "leur", "été", "cette", "tout", "aussi", "être"},
+	"es": {"de", "la", "que", "el", "en", "y", "los", "del", "se", "las", "por", "un", "para", "con", "no", "una", "su", "al", "es", "lo", "como", "más", "pero", "sus", "le", "ya", "o", "fue", "este", "ha", "sí", "porque", "esta", "son", "entre", "cuando", "muy", "sin", "sobre", "también"},
+	"it": {"di", "e", "il", "la", "che", "in", "a", "per", "un", "è", "del", "non", "una", "della", "sono", "le", "si", "con", "gli", "da", "al", "dei", "come", "ma", "nel", "anche", "più", "questo", "alla", "ha", "lo", "delle", "nella", "essere", "tra", "o", "se", "perché", "molto", "quando"},
+	"nl": {"de", "en", "van", "het", "een", "in", "is", "dat", "op", "te", "zijn", "voor", "met", "die", "niet", "aan", "er", "om", "ook", "als", "bij", "of", "door", "maar", "naar", "dan", "wordt", "hij", "nog", "wel", "uit", "kan", "worden", "meer", "geen", "deze", "tot", "heeft", "ze", "zo"},
+	"pt": {"de", "a", "o", "que", "e", "do", "da", "em", "um", "para", "é", "com", "não", "uma", "os", "no", "se", "na", "por", "mais", "as", "dos", "como", "mas", "foi", "ao", "ele", "das", "tem", "à", "seu", "sua", "ou", "ser", "quando", "muito", "nos", "já", "está", "também"},
+}
+
+var index = func() map[string][]string {
+	index := map[string][]string{}
+	for lang, words := range profiles {
+		for _, word := range words {
+			index[word] = append(index[word], lang)
+		}
+	}
+	return index
+}()
+
+// StopWords returns the common words of a language by ISO 639-1 code, or of
+// every language it knows for "", for telling words apart from the
+// content words around them
+func StopWords(lang string) []string {
+	if lang != "" {
+		return profiles[lang]
+	}
+	words := make([]string, 0, len(index))
+	for word := range index {
+		words = append(words, word)
+	}
+	return words
+}
+
+// Thresholds: the best language must have this many word hits, and this
+// many times as many as the next best
+const (
+	minHits   = 4
+	minMargin = 1.5
+)
+
+// Detect returns the ISO 639-1 code of the language of text, or "" when
+// it can't tell
+func Detect(text string) string {
+	hits := map[string]int{}
+	words := strings.FieldsFunc(strings.ToLower(text), func(c rune) bool {
+		return !unicode.IsLetter(c) && c != '\''
+	})
+	for _, word := range words {
+		for _, lang := range index[word] {
+			hits[lang]++
+		}
+	}
+	best, first, second := "", 0, 0
+	for lang, n := range hits {
+		switch {
+		case n > first || n == first && lang < best:
+			best, first, second = lang, n, max(first, second)
+		case n > second:
+			second = n
+		}
+	}
+	if first < minHits || float64(first) < minMargin*float64(second) {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,22 @@
+package langdetect
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct{ text, want string }{
+		{"The quick brown fox jumps over the lazy dog, and it is not the first time that this has happened in the garden.", "en"},
+		{"Kesä on tänä vuonna ollut lämmin, ja se on näkynyt myös kaupungin puistoissa, kun ihmiset ovat viettäneet niissä iltoja.", "fi"},
+		{"Det var en gång en katt som bodde på landet, och den hade inte så mycket att göra men var nöjd med det.", "sv"},
+		{"Die Stadt hat sich in den letzten Jahren stark verändert, und es ist nicht mehr so ruhig wie früher.", "de"},
+		{"Le musée est fermé pour des travaux de rénovation, mais il sera ouvert à nouveau dans le courant de l'année.", "fr"},
+		{"El proyecto se presentó en la reunión del consejo, y la mayoría de los miembros votaron a favor de la propuesta.", "es"},
+		{"Hello world", ""},
+		{"", ""},
+		{"12345 67890", ""},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.text); got != tt.want {
+			t.Errorf("Detect(%.30q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,482 @@
+// Package markdown renders article content to HTML and sanitizes submitted
+// text. Render supports the common block and inline syntax, with tables,
+// code highlighting and raw HTML as options; Sanitize and SanitizeMarkdown
+// strip HTML down to one of the SANITIZE_MODE policies.
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go-spring/internal/config"
+)
+
+// Options selects optional Markdown extensions
+type Options struct {
+	Tables    bool
+	Highlight bool
+	HTML      string // SANITIZE_MODE policy raw HTML is kept to; escaped with "" or plain
+}
+
+// Whether raw HTML goes through the sanitizer instead of being escaped
+func (opts Options) rawHTML() bool {
+	return opts.HTML == config.SanitizeBasic || opts.HTML == config.SanitizeFull
+}
+
+// Render renders Markdown to HTML. Raw HTML in the source is escaped, or
+// with Options.HTML sanitized to that policy, so the output only contains
+// tags of the renderer and of the policy. Code is always escaped.
+func Render(src string, opts Options) string {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	var b strings.Builder
+	renderBlocks(&b, lines, opts)
+	return b.String()
+}
+
+var (
+	headingRe   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	hrRe        = regexp.MustCompile(`^\s*([-*_])(\s*[-*_]){2,}\s*$`)
+	ulItemRe    = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	olItemRe    = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	fenceRe     = regexp.MustCompile("^\\s*(```|~~~)\\s*([\\w+-]*)\\s*$")
+	tableSepRe  = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	strongEmRe  = regexp.MustCompile(`\*\*\*(.+?)\*\*\*`)
+	strongRe    = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	emRe        = regexp.MustCompile(`\*([^*\s][^*]*?)\*`)
+	emUnderRe   = regexp.MustCompile(`(^|[^\w])_([^_\s][^_]*?)_([^\w]|$)`)
+	strikeRe    = regexp.MustCompile(`~~(.+?)~~`)
+	safeSchemes = []string{"http:", "https:", "mailto:"}
+	// A line starting or ending a block-level element, such as <div>
+	htmlBlockRe = regexp.MustCompile(`^\s*</?([a-zA-Z][a-zA-Z0-9]*)(\s|/?>|$)`)
+	// Where renderEmphasis set aside a tag of raw HTML
+	placeholderRe = regexp.MustCompile("\x00([0-9]+)\x00")
+)
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// Whether a line starts an HTML block: a block-level element the policy
+// keeps. Like a paragraph it runs to the next blank line.
+func startsHTMLBlock(line string, opts Options) bool {
+	m := htmlBlockRe.FindStringSubmatch(line)
+	if m == nil || !opts.rawHTML() {
+		return false
+	}
+	name := strings.ToLower(m[1])
+	_, allowed := policy(opts.HTML)[name]
+	return allowed && blockTags[name]
+}
+
+// Whether a line starts a block other than a paragraph
+func startsBlock(line string, next string, opts Options) bool {
+	switch {
+	case startsHTMLBlock(line, opts):
+		return true
+	case headingRe.MatchString(line), hrRe.MatchString(line), fenceRe.MatchString(line):
+		return true
+	case strings.HasPrefix(strings.TrimSpace(line), ">"):
+		return true
+	case ulItemRe.MatchString(line), olItemRe.MatchString(line):
+		return true
+	case opts.Tables && strings.Contains(line, "|") && tableSepRe.MatchString(next):
+		return true
+	}
+	return false
+}
+
+func renderBlocks(b *strings.Builder, lines []string, opts Options) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		next := ""
+		if i+1 < len(lines) {
+			next = lines[i+1]
+		}
+
+		switch {
+		case isBlank(line):
+			i++
+
+		case fenceRe.MatchString(line):
+			m := fenceRe.FindStringSubmatch(line)
+			fence, lang := m[1], strings.ToLower(m[2])
+			var code []string
+			i++
+			for i < len(lines) && strings.TrimSpace(lines[i]) != fence {
+				code = append(code, lines[i])
+				i++
+			}
+			i++ // closing fence
+			renderCodeBlock(b, strings.Join(code, "\n"), lang, opts)
+
+		case headingRe.MatchString(line):
+			m := headingRe.FindStringSubmatch(line)
+			level := strconv.Itoa(len(m[1]))
+			b.WriteString("<h" + level + ">" + renderInline(m[2], opts) + "</h" + level + ">\n")
+			i++
+
+		case hrRe.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+
+		case strings.HasPrefix(strings.TrimSpace(line), ">"):
+			var quoted []string
+			for i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">") {
+				l := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(l, " "))
+				i++
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted, opts)
+			b.WriteString("</blockquote>\n")
+
+		case ulItemRe.MatchString(line), olItemRe.MatchString(line):
+			i = renderList(b, lines, i, opts)
+
+		case opts.Tables && strings.Contains(line, "|") && tableSepRe.MatchString(next):
+			i = renderTable(b, lines, i, opts)
+
+		case startsHTMLBlock(line, opts):
+			var block []string
+			for i < len(lines) && !isBlank(lines[i]) {
+				block = append(block, lines[i])
+				i++
+			}
+			b.WriteString(Sanitize(strings.Join(block, "\n"), opts.HTML) + "\n")
+
+		default:
+			para := []string{strings.TrimSpace(line)}
+			i++
+			for i < len(lines) && !isBlank(lines[i]) {
+				following := ""
+				if i+1 < len(lines) {
+					following = lines[i+1]
+				}
+				if startsBlock(lines[i], following, opts) {
+					break
+				}
+				para = append(para, strings.TrimSpace(lines[i]))
+				i++
+			}
+			b.WriteString("<p>" + renderInline(strings.Join(para, "\n"), opts) + "</p>\n")
+		}
+	}
+}
+
+// Render a bullet or numbered list starting at lines[i]; returns the next line index
+func renderList(b *strings.Builder, lines []string, i int, opts Options) int {
+	itemRe, tag := ulItemRe, "ul"
+	if olItemRe.MatchString(lines[i]) {
+		itemRe, tag = olItemRe, "ol"
+	}
+
+	b.WriteString("<" + tag + ">\n")
+	for i < len(lines) && itemRe.MatchString(lines[i]) {
+		item := []string{itemRe.FindStringSubmatch(lines[i])[1]}
+		i++
+		// Indented continuation lines belong to the current item
+		for i < len(lines) && !isBlank(lines[i]) && !itemRe.MatchString(lines[i]) &&
+			(strings.HasPrefix(lines[i], " ") || strings.HasPrefix(lines[i], "\t")) {
+			item = append(item, strings.TrimSpace(lines[i]))
+			i++
+		}
+		b.WriteString("<li>" + renderInline(strings.Join(item, "\n"), opts) + "</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+	cells := strings.Split(line, "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	return cells
+}
+
+// Render a pipe table starting at lines[i]; returns the next line index
+func renderTable(b *strings.Builder, lines []string, i int, opts Options) int {
+	header := splitTableRow(lines[i])
+	var aligns []string
+	for _, sep := range splitTableRow(lines[i+1]) {
+		left, right := strings.HasPrefix(sep, ":"), strings.HasSuffix(sep, ":")
+		switch {
+		case left && right:
+			aligns = append(aligns, "center")
+		case right:
+			aligns = append(aligns, "right")
+		case left:
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+	i += 2
+
+	cell := func(tag string, col int, text string) string {
+		attr := ""
+		if col < len(aligns) && aligns[col] != "" {
+			attr = ` style="text-align:` + aligns[col] + `"`
+		}
+		return "<" + tag + attr + ">" + renderInline(text, opts) + "</" + tag + ">"
+	}
+
+	b.WriteString("<table>\n<thead>\n<tr>")
+	for col, text := range header {
+		b.WriteString(cell("th", col, text))
+	}
+	b.WriteString("</tr>\n</thead>\n<tbody>\n")
+	for i < len(lines) && !isBlank(lines[i]) && strings.Contains(lines[i], "|") {
+		b.WriteString("<tr>")
+		for col, text := range splitTableRow(lines[i]) {
+			if col >= len(header) {
+				break
+			}
+			b.WriteString(cell("td", col, text))
+		}
+		b.WriteString("</tr>\n")
+		i++
+	}
+	b.WriteString("</tbody>\n</table>\n")
+	return i
+}
+
+func renderCodeBlock(b *strings.Builder, code, lang string, opts Options) {
+	b.WriteString("<pre><code")
+	if lang != "" {
+		b.WriteString(` class="language-` + html.EscapeString(lang) + `"`)
+	}
+	b.WriteString(">")
+	if opts.Highlight {
+		b.WriteString(highlightCode(code))
+	} else {
+		b.WriteString(html.EscapeString(code))
+	}
+	b.WriteString("</code></pre>\n")
+}
+
+// Render inline Markdown: code spans, links, images, emphasis and raw HTML
+func renderInline(text string, opts Options) string {
+	var b strings.Builder
+	plain := 0 // start of the pending plain-text run
+
+	flush := func(end int) {
+		if end > plain {
+			b.WriteString(renderEmphasis(text[plain:end], opts))
+		}
+	}
+
+	for i := 0; i < len(text); {
+		switch {
+		case text[i] == '`':
+			end := strings.IndexByte(text[i+1:], '`')
+			if end < 0 {
+				i++
+				continue
+			}
+			flush(i)
+			b.WriteString("<code>" + html.EscapeString(text[i+1:i+1+end]) + "</code>")
+			i += end + 2
+			plain = i
+
+		case text[i] == '[' || (text[i] == '!' && i+1 < len(text) && text[i+1] == '['):
+			image := text[i] == '!'
+			start := i
+			if image {
+				start++
+			}
+			label, url, n, ok := parseLink(text[start:])
+			if !ok {
+				i++
+				continue
+			}
+			flush(i)
+			if image {
+				b.WriteString(`<img src="` + html.EscapeString(safeURL(url)) + `" alt="` + html.EscapeString(label) + `">`)
+			} else {
+				b.WriteString(`<a href="` + html.EscapeString(safeURL(url)) + `">` + renderInline(label, opts) + `</a>`)
+			}
+			i = start + n
+			plain = i
+
+		default:
+			i++
+		}
+	}
+	flush(len(text))
+
+	return strings.ReplaceAll(b.String(), "\n", "<br>\n")
+}
+
+// Parse "[label](url)" at the start of s, returning the consumed length.
+// Brackets in the label and parentheses in the URL must be balanced, so
+// "[x] done, see [docs](url)" links only "docs".
+func parseLink(s string) (label, url string, n int, ok bool) {
+	closeLabel := closingBracket(s, '[', ']')
+	if closeLabel < 0 || !strings.HasPrefix(s[closeLabel+1:], "(") {
+		return "", "", 0, false
+	}
+	closeURL := closingBracket(s[closeLabel+1:], '(', ')')
+	if closeURL < 0 {
+		return "", "", 0, false
+	}
+	label = s[1:closeLabel]
+	url = strings.TrimSpace(s[closeLabel+2 : closeLabel+1+closeURL])
+	if i := strings.IndexAny(url, " \t"); i >= 0 {
+		url = url[:i] // drop an optional "title"
+	}
+	return label, url, closeLabel + 2 + closeURL, true
+}
+
+// The index of the bracket closing the one s starts with, or -1
+func closingBracket(s string, open, close byte) int {
+	if s == "" || s[0] != open {
+		return -1
+	}
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case open:
+			depth++
+		case close:
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Only allow relative URLs and a small set of schemes (no javascript:, data:, ...)
+func safeURL(url string) string {
+	lower := strings.ToLower(url)
+	colon := strings.IndexByte(lower, ':')
+	if colon < 0 || strings.ContainsAny(lower[:colon], "/?#") {
+		return url
+	}
+	for _, scheme := range safeSchemes {
+		if strings.HasPrefix(lower, scheme) {
+			return url
+		}
+	}
+	return "#"
+}
+
+// Render emphasis in plain text, escaping it. Tags of raw HTML the policy
+// keeps are set aside first, so neither escaping nor emphasis touches them.
+func renderEmphasis(text string, opts Options) string {
+	var tags []string
+	if opts.rawHTML() {
+		text = Sanitize(strings.ReplaceAll(text, "\x00", ""), opts.HTML)
+		text = htmlTagRe.ReplaceAllStringFunc(text, func(tag string) string {
+			tags = append(tags, Sanitize(tag, opts.HTML))
+			return "\x00" + strconv.Itoa(len(tags)-1) + "\x00"
+		})
+	}
+	s := html.EscapeString(text)
+	s = strongEmRe.ReplaceAllString(s, "<strong><em>$1</em></strong>")
+	s = strongRe.ReplaceAllStringFunc(s, func(m string) string {
+		return "<strong>" + m[2:len(m)-2] + "</strong>"
+	})
+	s = strikeRe.ReplaceAllString(s, "<del>$1</del>")
+	s = emRe.ReplaceAllString(s, "<em>$1</em>")
+	s = emUnderRe.ReplaceAllString(s, "$1<em>$2</em>$3")
+	if tags != nil {
+		s = placeholderRe.ReplaceAllStringFunc(s, func(m string) string {
+			n, _ := strconv.Atoi(m[1 : len(m)-1])
+			return tags[n]
+		})
+	}
+	return s
+}
+
+var highlightKeywords = map[string]bool{}
+
+func init() {
+	for _, kw := range strings.Fields(`break case chan const continue default defer else fallthrough for func go goto if
+		import interface map package range return select struct switch type var nil true false
+		class def elif except finally from in is lambda not or and pass raise try while with yield None True False
+		function let new this throw typeof async await export extends static public private protected void int string bool`) {
+		highlightKeywords[kw] = true
+	}
+}
+
+// Minimal language-agnostic highlighter: wraps comments, strings, numbers and
+// common keywords in <span class="hl-*"> elements. Everything is escaped.
+func highlightCode(code string) string {
+	var b strings.Builder
+	span := func(class, text string) {
+		b.WriteString(`<span class="hl-` + class + `">` + html.EscapeString(text) + `</span>`)
+	}
+
+	for i := 0; i < len(code); {
+		c := code[i]
+		switch {
+		case strings.HasPrefix(code[i:], "//") || c == '#':
+			end := strings.IndexByte(code[i:], '\n')
+			if end < 0 {
+				end = len(code) - i
+			}
+			span("comment", code[i:i+end])
+			i += end
+
+		case strings.HasPrefix(code[i:], "/*"):
+			end := strings.Index(code[i+2:], "*/")
+			if end < 0 {
+				end = len(code) - i
+			} else {
+				end += 4
+			}
+			span("comment", code[i:i+end])
+			i += end
+
+		case c == '"' || c == '\'' || c == '`':
+			j := i + 1
+			for j < len(code) && code[j] != c && (c == '`' || code[j] != '\n') {
+				if code[j] == '\\' && c != '`' {
+					j++
+				}
+				j++
+			}
+			if j < len(code) && code[j] == c {
+				j++ // closing quote; an unterminated string ends before the newline
+			}
+			span("string", code[i:min(j, len(code))])
+			i = min(j, len(code))
+
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(code) && (isWordByte(code[j]) || code[j] == '.') {
+				j++
+			}
+			span("number", code[i:j])
+			i = j
+
+		case isWordByte(c):
+			j := i
+			for j < len(code) && isWordByte(code[j]) {
+				j++
+			}
+			if highlightKeywords[code[i:j]] {
+				span("keyword", code[i:j])
+			} else {
+				b.WriteString(html.EscapeString(code[i:j]))
+			}
+			i = j
+
+		default:
+			b.WriteString(html.EscapeString(code[i : i+1]))
+			i++
+		}
+	}
+	return b.String()
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
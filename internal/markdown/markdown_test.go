@@ -0,0 +1,159 @@
+package markdown
+
+import (
+	"testing"
+
+	"go-spring/internal/config"
+)
+
+func TestMarkdownBlocks(t *testing.T) {
+	for in, want := range map[string]string{
+		"# One\n## Two ##\n###### Six\n####### Seven": "<h1>One</h1>\n<h2>Two</h2>\n<h6>Six</h6>\n<p>####### Seven</p>\n",
+		"#NoSpace":                         "<p>#NoSpace</p>\n",
+		"a\nb\n\nc":                        "<p>a<br>\nb</p>\n<p>c</p>\n",
+		"a\r\nb":                           "<p>a<br>\nb</p>\n",
+		"---\n* * *":                       "<hr>\n<hr>\n",
+		"- a\n- b\n  continued\n* c":       "<ul>\n<li>a</li>\n<li>b<br>\ncontinued</li>\n<li>c</li>\n</ul>\n",
+		"1. one\n2) two":                   "<ol>\n<li>one</li>\n<li>two</li>\n</ol>\n",
+		"para\n- list":                     "<p>para</p>\n<ul>\n<li>list</li>\n</ul>\n",
+		"> quote\n> **b**\n>\n> # h":       "<blockquote>\n<p>quote<br>\n<strong>b</strong></p>\n<h1>h</h1>\n</blockquote>\n",
+		"<script>alert(1)</script>":        "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n",
+		"~~~\n<b>x</b>\n~~~\nafter":        "<pre><code>&lt;b&gt;x&lt;/b&gt;</code></pre>\n<p>after</p>\n",
+		"```\nunclosed\n\n# not a heading": "<pre><code>unclosed\n\n# not a heading</code></pre>\n",
+		"```go\nx := 1\n```":               "<pre><code class=\"language-go\">x := 1</code></pre>\n",
+		// Tables are off by default
+		"a | b\n--|--\n1 | 2": "<p>a | b<br>\n--|--<br>\n1 | 2</p>\n",
+	} {
+		if got := Render(in, Options{}); got != want {
+			t.Errorf("%q\n got %q\nwant %q", in, got, want)
+		}
+	}
+}
+
+func TestMarkdownTables(t *testing.T) {
+	opts := Options{Tables: true}
+	for in, want := range map[string]string{
+		"a | b\n--|--\n1 | *2*": "<table>\n<thead>\n<tr><th>a</th><th>b</th></tr>\n</thead>\n<tbody>\n<tr><td>1</td><td><em>2</em></td></tr>\n</tbody>\n</table>\n",
+		// Alignments; extra cells are dropped and missing ones left out
+		"| a | b | c |\n|:--|--:|:-:|\n| 1 | 2 | 3 | 4 |\n| x |\n\nafter": "<table>\n<thead>\n" +
+			`<tr><th style="text-align:left">a</th><th style="text-align:right">b</th><th style="text-align:center">c</th></tr>` + "\n</thead>\n<tbody>\n" +
+			`<tr><td style="text-align:left">1</td><td style="text-align:right">2</td><td style="text-align:center">3</td></tr>` + "\n" +
+			`<tr><td style="text-align:left">x</td></tr>` + "\n</tbody>\n</table>\n<p>after</p>\n",
+		// A paragraph ends where a table starts
+		"text\na | b\n-|-":    "<p>text</p>\n<table>\n<thead>\n<tr><th>a</th><th>b</th></tr>\n</thead>\n<tbody>\n</tbody>\n</table>\n",
+		"a | b\nno separator": "<p>a | b<br>\nno separator</p>\n",
+	} {
+		if got := Render(in, opts); got != want {
+			t.Errorf("%q\n got %q\nwant %q", in, got, want)
+		}
+	}
+}
+
+func TestMarkdownHighlight(t *testing.T) {
+	in := "```go\nfunc f() { return \"s\\\"\" // c <b>\n}\n```\n```\nx = 1.5 /* c */ # h\n'a' `b\nc` 0x1F funcs\n```"
+	want := `<pre><code class="language-go"><span class="hl-keyword">func</span> f() { <span class="hl-keyword">return</span> ` +
+		`<span class="hl-string">&#34;s\&#34;&#34;</span> <span class="hl-comment">// c &lt;b&gt;</span>` + "\n}</code></pre>\n" +
+		`<pre><code>x = <span class="hl-number">1.5</span> <span class="hl-comment">/* c */</span> <span class="hl-comment"># h</span>` + "\n" +
+		`<span class="hl-string">&#39;a&#39;</span> <span class="hl-string">` + "`b\nc`" + `</span> <span class="hl-number">0x1F</span> funcs</code></pre>` + "\n"
+	if got := Render(in, Options{Highlight: true}); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	// Unterminated strings and comments run to the end of the line or code
+	if got := highlightCode("\"open\nx /* open"); got != `<span class="hl-string">&#34;open</span>`+"\nx "+`<span class="hl-comment">/* open</span>` {
+		t.Errorf("unterminated: %q", got)
+	}
+}
+
+func TestMarkdownLinks(t *testing.T) {
+	for in, want := range map[string]string{
+		"[docs](http://a)":                 `<a href="http://a">docs</a>`,
+		"[x] done, see [docs](http://a)":   `[x] done, see <a href="http://a">docs</a>`,
+		"[a [b] c](/u)":                    `<a href="/u">a [b] c</a>`,
+		"[a] [b](/u)":                      `[a] <a href="/u">b</a>`,
+		"[[x]](/u)":                        `<a href="/u">[x]</a>`,
+		"[w](http://e.org/Go_(language))":  `<a href="http://e.org/Go_(language)">w</a>`,
+		`[t](/u "title")`:                  `<a href="/u">t</a>`,
+		"[**bold** link](/x)":              `<a href="/x"><strong>bold</strong> link</a>`,
+		"[unclosed](http://a":              `[unclosed](http://a`,
+		"[a]b(c)":                          `[a]b(c)`,
+		"[open [label](/u)":                `[open <a href="/u">label</a>`,
+		"[q](/a?x=1&y=\"2\")":              `<a href="/a?x=1&amp;y=&#34;2&#34;">q</a>`,
+		"![alt <x>](http://a/i.png)":       `<img src="http://a/i.png" alt="alt &lt;x&gt;">`,
+		"![i](JavaScript:x)":               `<img src="#" alt="i">`,
+		"[bad](javascript:alert(1))":       `<a href="#">bad</a>`,
+		"[d](data:text/html,x)":            `<a href="#">d</a>`,
+		"`[not](/link)` [yes](/link)":      `<code>[not](/link)</code> <a href="/link">yes</a>`,
+		"![x](/i.png \"t\") and [y](#top)": `<img src="/i.png" alt="x"> and <a href="#top">y</a>`,
+	} {
+		if got := Render(in, Options{}); got != "<p>"+want+"</p>\n" {
+			t.Errorf("%q\n got %q\nwant %q", in, got, "<p>"+want+"</p>\n")
+		}
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	for url, want := range map[string]string{
+		"http://a":         "http://a",
+		"HTTPS://a":        "HTTPS://a",
+		"mailto:me@x.org":  "mailto:me@x.org",
+		"/path":            "/path",
+		"relative/x:y":     "relative/x:y",
+		"./javascript:x":   "./javascript:x",
+		"?q=a:b":           "?q=a:b",
+		"#frag:x":          "#frag:x",
+		"javascript:x":     "#",
+		"JavaScript:x":     "#",
+		"vbscript:x":       "#",
+		"data:text/html,x": "#",
+		"file:///etc":      "#",
+		"a:b/c":            "#",
+	} {
+		if got := safeURL(url); got != want {
+			t.Errorf("safeURL(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestMarkdownEmphasis(t *testing.T) {
+	for in, want := range map[string]string{
+		"**b** *i* _u_ ~~s~~ __B__":   `<strong>b</strong> <em>i</em> <em>u</em> <del>s</del> <strong>B</strong>`,
+		"snake_case_name":             `snake_case_name`,
+		"2*3*4":                       `2<em>3</em>4`,
+		"a * not * and ** not":        `a * not * and ** not`,
+		"***both***":                  `<strong><em>both</em></strong>`,
+		"_a_, (_b_)":                  `<em>a</em>, (<em>b</em>)`,
+		"**a <b> & c**":               `<strong>a &lt;b&gt; &amp; c</strong>`,
+		"`*code* <b>` and ` unclosed": "<code>*code* &lt;b&gt;</code> and ` unclosed",
+		"~~one~~ ~two~":               `<del>one</del> ~two~`,
+		"one  \n**two**":              "one<br>\n<strong>two</strong>",
+	} {
+		if got := Render(in, Options{}); got != "<p>"+want+"</p>\n" {
+			t.Errorf("%q\n got %q\nwant %q", in, got, "<p>"+want+"</p>\n")
+		}
+	}
+}
+
+func TestMarkdownRawHTML(t *testing.T) {
+	in := "Some <b>bold</b> and <span class=\"x\">span</span> *<i title=\"*a*\">it</i>*\n\n" +
+		"<div class=\"note\">\nA <kbd>key</kbd>\n</div>\n\n" +
+		"`<b>code</b>` <img src=x onerror=alert(1)><script>alert(1)</script>"
+	escaped := "<p>Some &lt;b&gt;bold&lt;/b&gt; and &lt;span class=&#34;x&#34;&gt;span&lt;/span&gt; <em>&lt;i title=&#34;</em>a<em>&#34;&gt;it&lt;/i&gt;</em></p>\n" +
+		"<p>&lt;div class=&#34;note&#34;&gt;<br>\nA &lt;kbd&gt;key&lt;/kbd&gt;<br>\n&lt;/div&gt;</p>\n" +
+		"<p><code>&lt;b&gt;code&lt;/b&gt;</code> &lt;img src=x onerror=alert(1)&gt;&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"
+	for mode, want := range map[string]string{
+		// Escaped without a policy, or with plain
+		"":                   escaped,
+		config.SanitizePlain: escaped,
+		// The tags of the policy are kept, others dropped; code stays escaped
+		config.SanitizeBasic: "<p>Some <b>bold</b> and span <em><i>it</i></em></p>\n" +
+			"<p><br>\nA key<br>\n</p>\n" +
+			"<p><code>&lt;b&gt;code&lt;/b&gt;</code> </p>\n",
+		config.SanitizeFull: "<p>Some <b>bold</b> and <span class=\"x\">span</span> <em><i title=\"*a*\">it</i></em></p>\n" +
+			"<div class=\"note\">\nA <kbd>key</kbd>\n</div>\n" +
+			"<p><code>&lt;b&gt;code&lt;/b&gt;</code> <img src=\"x\"></p>\n",
+	} {
+		if got := Render(in, Options{HTML: mode}); got != want {
+			t.Errorf("%q:\n got %q\nwant %q", mode, got, want)
+		}
+	}
+}
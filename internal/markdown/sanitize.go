@@ -0,0 +1,174 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+
+	"go-spring/internal/config"
+)
+
+// Tags whose content is dropped together with the tag itself
+var dangerousTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"frame": true, "frameset": true, "applet": true, "noscript": true, "template": true,
+}
+
+// Allowed tags and their allowed attributes, per policy
+var basicPolicy = map[string][]string{
+	"a": {"href", "title"}, "b": nil, "strong": nil, "i": nil, "em": nil, "u": nil, "s": nil, "del": nil,
+	"p": nil, "br": nil, "ul": nil, "ol": nil, "li": nil, "code": nil, "pre": nil, "blockquote": nil,
+	"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil, "hr": nil,
+}
+
+var fullPolicy = map[string][]string{}
+
+// Tags that break the flow of text, leaving a space when they are dropped
+var blockTags = map[string]bool{}
+
+func init() {
+	for _, tag := range strings.Fields(`p br hr div h1 h2 h3 h4 h5 h6 ul ol li dl dt dd pre blockquote
+		table tr th td caption section article header footer aside nav main figure figcaption details summary`) {
+		blockTags[tag] = true
+	}
+	for tag, attrs := range basicPolicy {
+		fullPolicy[tag] = attrs
+	}
+	for _, tag := range strings.Fields(`div span section article header footer aside nav main figure figcaption
+		table thead tbody tfoot tr th td caption colgroup col sup sub small mark abbr cite q kbd samp var
+		dl dt dd details summary img video audio source picture`) {
+		fullPolicy[tag] = nil
+	}
+	common := []string{"class", "id", "title", "lang", "dir"}
+	for tag := range fullPolicy {
+		fullPolicy[tag] = append(append([]string{}, fullPolicy[tag]...), common...)
+	}
+	fullPolicy["a"] = append(fullPolicy["a"], "rel", "target")
+	fullPolicy["img"] = append(fullPolicy["img"], "src", "alt", "width", "height")
+	fullPolicy["video"] = append(fullPolicy["video"], "src", "controls", "width", "height", "poster")
+	fullPolicy["audio"] = append(fullPolicy["audio"], "src", "controls")
+	fullPolicy["source"] = append(fullPolicy["source"], "src", "type")
+	fullPolicy["td"] = append(fullPolicy["td"], "colspan", "rowspan", "align")
+	fullPolicy["th"] = append(fullPolicy["th"], "colspan", "rowspan", "align", "scope")
+}
+
+var (
+	htmlCommentRe = regexp.MustCompile(`(?s)<!--.*?(-->|$)`)
+	htmlTagRe     = regexp.MustCompile(`(?s)<(/?)([a-zA-Z][a-zA-Z0-9-]*)((?:[^<>"']|"[^"]*"|'[^']*')*)>|<![^>]*>|<\?[^>]*>`)
+	htmlAttrRe    = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+)))?`)
+	urlAttrs      = map[string]bool{"href": true, "src": true, "poster": true}
+	// The < of a tag that never ends, which a browser would end at the next
+	// > of the page
+	tagStartRe = regexp.MustCompile(`<+([a-zA-Z/!?])`)
+)
+
+// The allowed tags of mode; none for plain
+func policy(mode string) map[string][]string {
+	switch mode {
+	case config.SanitizeBasic:
+		return basicPolicy
+	case config.SanitizeFull:
+		return fullPolicy
+	}
+	return nil
+}
+
+// Sanitize strips s down to the tags and attributes of mode, one of the
+// config.Sanitize policies
+func Sanitize(s string, mode string) string {
+	policy := policy(mode)
+
+	s = htmlCommentRe.ReplaceAllString(s, "")
+
+	var b strings.Builder
+	last := 0
+	skipUntil := "" // closing tag of a dangerous element being dropped
+	for _, m := range htmlTagRe.FindAllStringSubmatchIndex(s, -1) {
+		if skipUntil == "" {
+			b.WriteString(tagStartRe.ReplaceAllString(s[last:m[0]], "$1"))
+		}
+		last = m[1]
+
+		if m[4] < 0 {
+			continue // doctype or processing instruction
+		}
+		closing := m[3] > m[2]
+		name := strings.ToLower(s[m[4]:m[5]])
+
+		if skipUntil != "" {
+			if closing && name == skipUntil {
+				skipUntil = ""
+			}
+			continue
+		}
+		if dangerousTags[name] {
+			if !closing && !strings.HasSuffix(strings.TrimSpace(s[m[6]:m[7]]), "/") {
+				skipUntil = name
+			}
+			continue
+		}
+
+		allowed, ok := policy[name]
+		if !ok {
+			if out := b.String(); blockTags[name] && out != "" && !strings.HasSuffix(out, " ") && !strings.HasSuffix(out, "\n") {
+				b.WriteByte(' ')
+			}
+			continue
+		}
+		if closing {
+			b.WriteString("</" + name + ">")
+			continue
+		}
+		b.WriteString("<" + name + sanitizeAttributes(s[m[6]:m[7]], allowed) + ">")
+	}
+	if skipUntil == "" {
+		b.WriteString(tagStartRe.ReplaceAllString(s[last:], "$1"))
+	}
+
+	return b.String()
+}
+
+// Rebuild an attribute list keeping only allowed, safe attributes
+func sanitizeAttributes(raw string, allowed []string) string {
+	var b strings.Builder
+	for _, m := range htmlAttrRe.FindAllStringSubmatch(raw, -1) {
+		name := strings.ToLower(m[1])
+		if strings.HasPrefix(name, "on") || !slices.Contains(allowed, name) {
+			continue
+		}
+		value := html.UnescapeString(m[2] + m[3] + m[4])
+		if urlAttrs[name] && safeURL(strings.TrimSpace(value)) == "#" {
+			continue
+		}
+		b.WriteString(" " + name + `="` + html.EscapeString(value) + `"`)
+	}
+	return b.String()
+}
+
+// SanitizeMarkdown sanitizes Markdown source, leaving fenced code blocks
+// untouched since Render escapes them anyway (so HTML examples in code
+// survive)
+func SanitizeMarkdown(s string, mode string) string {
+	var out, chunk []string
+	fence := ""
+	for _, line := range strings.Split(s, "\n") {
+		m := fenceRe.FindStringSubmatch(line)
+		switch {
+		case fence == "" && m != nil:
+			out = append(out, Sanitize(strings.Join(chunk, "\n"), mode))
+			chunk = nil
+			fence = m[1]
+			out = append(out, line)
+		case fence != "":
+			out = append(out, line)
+			if strings.TrimSpace(line) == fence {
+				fence = ""
+			}
+		default:
+			chunk = append(chunk, line)
+		}
+	}
+	out = append(out, Sanitize(strings.Join(chunk, "\n"), mode))
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
@@ -0,0 +1,129 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"go-spring/internal/config"
+)
+
+func TestSanitizeURLs(t *testing.T) {
+	for _, href := range []string{
+		`javascript:alert(1)`,
+		`JaVaScRiPt:alert(1)`,
+		` javascript:alert(1)`,
+		`&#106;avascript:alert(1)`,
+		`&#x6A;avascript:alert(1)`,
+		`javascript&#58;alert(1)`,
+		`javascript&colon;alert(1)`,
+		`java&#x09;script:alert(1)`,
+		`java&NewLine;script:alert(1)`,
+		`vbscript:msgbox(1)`,
+		`data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==`,
+	} {
+		for _, attr := range []string{`"` + href + `"`, `'` + href + `'`} {
+			in := `<a href=` + attr + ` title="t">x</a>`
+			if got := Sanitize(in, config.SanitizeBasic); got != `<a title="t">x</a>` {
+				t.Errorf("%s became %s", in, got)
+			}
+		}
+		in := `<img src="` + href + `" alt="a">`
+		if got := Sanitize(in, config.SanitizeFull); got != `<img alt="a">` {
+			t.Errorf("%s became %s", in, got)
+		}
+	}
+	for in, want := range map[string]string{
+		`<a href="https://example.com/?a=1&amp;b=2">x</a>`: `<a href="https://example.com/?a=1&amp;b=2">x</a>`,
+		`<a href=mailto:me@example.com>x</a>`:              `<a href="mailto:me@example.com">x</a>`,
+		`<a href="/articles/1">x</a>`:                      `<a href="/articles/1">x</a>`,
+		`<a href="#top">x</a>`:                             `<a href="#top">x</a>`,
+		`<a href="a:b/c">x</a>`:                            `<a>x</a>`,
+		`<a href="./javascript:x">x</a>`:                   `<a href="./javascript:x">x</a>`,
+	} {
+		if got := Sanitize(in, config.SanitizeBasic); got != want {
+			t.Errorf("%s became %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestSanitizeEventHandlers(t *testing.T) {
+	for in, want := range map[string]string{
+		`<img src=x onerror=alert(1)>`:                `<img src="x">`,
+		`<img src="x" OnError="alert(1)">`:            `<img src="x">`,
+		`<div onmouseover='alert(1)' class=c>x</div>`: `<div class="c">x</div>`,
+		`<a/onclick=alert(1) href=/x>x</a>`:           `<a href="/x">x</a>`,
+		`<p	onclick="alert(1)"
+			id=p>x</p>`: `<p id="p">x</p>`,
+		`<video poster=javascript:alert(1) controls></video>`:            `<video controls=""></video>`,
+		`<span title="&quot; onclick=&quot;alert(1)">x</span>`:           `<span title="&#34; onclick=&#34;alert(1)">x</span>`,
+		`<span title="a > b" onclick="alert('>')">x</span>`:              `<span title="a &gt; b">x</span>`,
+		`<span style="background:url(javascript:alert(1))">x</span>`:     `<span>x</span>`,
+		`<details open ontoggle=alert(1)><summary>s</summary></details>`: `<details><summary>s</summary></details>`,
+	} {
+		if got := Sanitize(in, config.SanitizeFull); got != want {
+			t.Errorf("%s became %s, want %s", in, got, want)
+		}
+	}
+	if got := Sanitize(`<b onclick="alert(1)">x</b>`, config.SanitizeBasic); got != `<b>x</b>` {
+		t.Errorf("basic kept %s", got)
+	}
+}
+
+func TestSanitizeTags(t *testing.T) {
+	for in, want := range map[string]string{
+		`a<script>alert(1)</script>b`:                         `ab`,
+		`a<SCRIPT type="text/javascript">alert(1)</SCRIPT >b`: `ab`,
+		`a<script>alert(1)`:                                   `a`,
+		`a<script src=x />b`:                                  `ab`,
+		`<scr<script>ipt>alert(1)</script>`:                   `scr`,
+		`<style>*{}</style><iframe src=x></iframe><template><b>t</b></template>x`:   `x`,
+		`<noscript><p title="</noscript><img src=x onerror=alert(1)>"></noscript>x`: `x`,
+		`<svg onload=alert(1)><circle r=1 /></svg>`:                                 ``,
+		`<svg><script>alert(1)</script><a href=x>t</a></svg>`:                       `<a href="x">t</a>`,
+		`<math><mtext><img src=x onerror=alert(1)></mtext></math>`:                  `<img src="x">`,
+		`<math><mi xlink:href="javascript:alert(1)">m</mi></math>`:                  `m`,
+		`<form action=x><input autofocus onfocus=alert(1)></form>`:                  ``,
+		`a<!-- <script>alert(1)</script> -->b`:                                      `ab`,
+		`a<!-- --!><img src=x onerror=alert(1)> -->b`:                               `ab`,
+		`a<!-- unclosed <img src=x onerror=alert(1)>`:                               `a`,
+		`<!DOCTYPE html><?xml version="1.0"?>x`:                                     `x`,
+		`<img src=x onerror=alert(1)`:                                               `img src=x onerror=alert(1)`,
+		`<img src="x onerror=alert(1)>`:                                             `img src="x onerror=alert(1)>`,
+		`<<img src=x onerror=alert(1)`:                                              `img src=x onerror=alert(1)`,
+		`x</b`:                                                                      `x/b`,
+		`1 < 2 && 2 <= 3 > 0`:                                                       `1 < 2 && 2 <= 3 > 0`,
+	} {
+		got := Sanitize(in, config.SanitizeFull)
+		if got != want {
+			t.Errorf("%s became %s, want %s", in, got, want)
+		}
+		if strings.Contains(strings.ToLower(got), "<script") || strings.Contains(got, "<svg") || strings.Contains(got, "<math") {
+			t.Errorf("%s kept a dangerous tag: %s", in, got)
+		}
+	}
+}
+
+func TestSanitizePolicies(t *testing.T) {
+	in := `<h2 id="h">Title</h2><p class="c"><b>bold</b> <span>text</span> <img src="/a.png"></p>`
+	for mode, want := range map[string]string{
+		config.SanitizePlain: `Title bold text `,
+		config.SanitizeBasic: `<h2>Title</h2><p><b>bold</b> text </p>`,
+		config.SanitizeFull:  `<h2 id="h">Title</h2><p class="c"><b>bold</b> <span>text</span> <img src="/a.png"></p>`,
+	} {
+		if got := Sanitize(in, mode); got != want {
+			t.Errorf("%s: got %s, want %s", mode, got, want)
+		}
+	}
+}
+
+func TestSanitizeMarkdown(t *testing.T) {
+	in := "# <b onclick=x>Head</b>\n\n```html\n<script>alert(1)</script>\n```\n\n<script>alert(2)</script>\n~~~\n<img src=x onerror=y>\n~~~\nafter<img src=x onerror=z>"
+	want := "# <b>Head</b>\n\n```html\n<script>alert(1)</script>\n```\n\n\n~~~\n<img src=x onerror=y>\n~~~\nafter"
+	if got := SanitizeMarkdown(in, config.SanitizeBasic); got != want {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+	// A fence that never closes runs to the end, as the renderer has it
+	if got := SanitizeMarkdown("```\n<script>x</script>", config.SanitizeBasic); got != "```\n<script>x</script>" {
+		t.Errorf("unclosed fence %q", got)
+	}
+}
@@ -0,0 +1,260 @@
+// Package model holds the types shared by the store, the service layer and
+// the transports.
+package model
+
+import "time"
+
+// Article is shared by the REST (json tags) and gRPC (proto tags) transports;
+// a field tagged for both only needs to be added here and in proto/article.proto
+type Article struct {
+	ID      int       `json:"id" proto:"1"`
+	Title   string    `json:"title" proto:"2"`
+	Slug    string    `json:"slug" proto:"14"`          // unique, set from the first title
+	UID     string    `json:"uid,omitempty" proto:"15"` // ULID or UUIDv7 under ID_STRATEGY
+	Desc    string    `json:"desc" proto:"3"`
+	Content string    `json:"content" proto:"4"`
+	Created time.Time `json:"created" proto:"5"`
+	Updated time.Time `json:"updated" proto:"6"`
+
+	Status    string    `json:"status" proto:"7"`
+	Published time.Time `json:"published,omitzero" proto:"8"`
+	SourceURL string    `json:"source_url,omitempty" proto:"12"` // page an imported article came from
+
+	Categories []string `json:"categories,omitempty" proto:"13"` // set by the WordPress import
+	Workspace  string   `json:"workspace,omitempty" proto:"16"`  // slug of the workspace, if any
+	Tags       []string `json:"tags,omitempty" proto:"19"`       // lower case, set by editors or AUTO_TAGS
+
+	// Curation flags for the homepage hero list
+	Pinned        bool `json:"pinned" proto:"9"`
+	Featured      bool `json:"featured" proto:"10"`
+	FeaturedOrder int  `json:"featured_order,omitempty" proto:"11"`
+
+	Attachments []Attachment `json:"attachments,omitempty"`
+	CoverImage  *CoverImage  `json:"cover_image,omitempty"`
+
+	// Set while the article is held for moderation as likely spam
+	Moderation *Moderation `json:"moderation,omitempty"`
+
+	// Content is encrypted in the store and served only to readers with
+	// access; set by the request or the article's workspace
+	Confidential bool   `json:"confidential,omitempty" proto:"17"`
+	ContentKeyID string `json:"-"` // data key of the stored content, set by the store
+
+	// Language tag of title, desc and content; empty for the site's (or
+	// the workspace's) feed language
+	Language     string                 `json:"language,omitempty" proto:"18"`
+	Translations map[string]Translation `json:"translations,omitempty"` // by language tag
+
+	Embedding *Embedding `json:"-"` // for semantic search, set in the background
+}
+
+// CreateArticleRequest is the POST body; validate tags are checked after
+// sanitizing (see the validate package)
+type CreateArticleRequest struct {
+	Title    string `json:"title" proto:"1" validate:"required,max=200"`
+	Desc     string `json:"desc" proto:"2" validate:"required,max=1000"`
+	Content  string `json:"content" proto:"3" validate:"required"`
+	Status   string `json:"status" proto:"4" validate:"oneof=draft published"` // default published
+	Pinned   bool   `json:"pinned" proto:"5"`
+	Featured bool   `json:"featured" proto:"6"`
+
+	Confidential bool `json:"confidential" proto:"7"`
+
+	Language string `json:"language" proto:"8"` // tag such as fi or pt-br
+
+	Tags []string `json:"tags" proto:"9" validate:"max=20"`
+
+	Workspace string `json:"-"` // taken from the URL in REST
+}
+
+// ArticleUpdate is the PUT body; pointer fields distinguish "not sent" from false
+type ArticleUpdate struct {
+	ID       int    `json:"-" proto:"1"` // taken from the URL in REST
+	Title    string `json:"title" proto:"2" validate:"max=200"`
+	Desc     string `json:"desc" proto:"3" validate:"max=1000"`
+	Content  string `json:"content" proto:"4"`
+	Status   string `json:"status" proto:"5" validate:"oneof=draft published"`
+	Pinned   *bool  `json:"pinned" proto:"6"`
+	Featured *bool  `json:"featured" proto:"7"`
+
+	Confidential *bool `json:"confidential" proto:"8"`
+
+	Language string `json:"language" proto:"9"`
+
+	Tags []string `json:"tags" proto:"10" validate:"max=20"` // nil leaves them, [] removes them
+}
+
+// Article statuses; articles stored before statuses existed count as published
+const (
+	StatusDraft     = "draft"
+	StatusPublished = "published"
+)
+
+// Whether an article is publicly visible
+func (a Article) IsPublished() bool {
+	return a.Status == "" || a.Status == StatusPublished
+}
+
+// Translation is an article's title, description and content in another
+// language. SourceHash is of the article's own text when the translation
+// was saved, so a translation whose article has changed since shows as
+// outdated.
+type Translation struct {
+	Title      string    `json:"title"`
+	Desc       string    `json:"desc"`
+	Content    string    `json:"content"`
+	Updated    time.Time `json:"updated"`
+	SourceHash string    `json:"source_hash"`
+
+	ContentKeyID string `json:"-"` // as Article's, set by the store
+}
+
+// Embedding is the vector of an article's text from a model, for semantic
+// search. Source is the hash of the text, as a Translation's, so an
+// embedding whose article has changed since is made again.
+type Embedding struct {
+	Model  string
+	Source string
+	Vector []float32
+}
+
+// Attachment is a file uploaded to an article; the file itself is in the blob store
+type Attachment struct {
+	ID          int       `json:"id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Key         string    `json:"-"`
+	Variants    []string  `json:"-"` // blob keys of cached resized copies
+	Private     bool      `json:"private,omitempty"`
+	Created     time.Time `json:"created"`
+}
+
+// Moderation is why a submitted article was held instead of published
+type Moderation struct {
+	Score   int       `json:"score"`
+	Reasons []string  `json:"reasons"`
+	Held    time.Time `json:"held"`
+}
+
+// CoverImage points at either an uploaded attachment or an external image
+type CoverImage struct {
+	AttachmentID int    `json:"attachment_id,omitempty"`
+	URL          string `json:"url"`
+	SourceURL    string `json:"source_url,omitempty"` // original external URL, if any
+}
+
+// User is an operator account, created with `go-spring user add`
+type User struct {
+	Username     string
+	Role         string // admin or editor
+	PasswordHash string // pbkdf2-sha256$iterations$salt$key
+	Created      time.Time
+
+	// Notification emails: the address, and per-event choices that override
+	// the defaults for the role (see the notify package)
+	Email         string
+	Notifications map[string]bool
+
+	// The channels each event is sent through, which override
+	// Notifications and the defaults; and the webhook of the webhook
+	// channel with the secret its posts are signed with
+	Preferences      map[string][]string
+	NotifyWebhookURL string
+	NotifyWebhookKey string
+
+	// Digest emails of newly published articles: DigestDaily, DigestWeekly
+	// or empty for none; and up to when the last one covered them
+	Digest     string
+	DigestSent time.Time
+
+	// Browsers subscribed to Web Push notifications
+	PushSubscriptions []PushSubscription
+
+	SavedSearches []SavedSearch
+}
+
+// PushSubscription is a browser's Web Push subscription. The endpoint is
+// the push service URL that delivers to the browser, and the keys encrypt
+// the messages for it.
+type PushSubscription struct {
+	Endpoint string    `json:"endpoint"`
+	P256dh   string    `json:"-"`
+	Auth     string    `json:"-"`
+	Created  time.Time `json:"created"`
+}
+
+// SavedSearch is a named keyword search of a user, which can alert them
+// by email or webhook to newly published articles that match it
+type SavedSearch struct {
+	ID         int       `json:"id"` // per user
+	Name       string    `json:"name"`
+	Query      string    `json:"query"`
+	Email      bool      `json:"email"`                 // email new matches
+	WebhookURL string    `json:"webhook_url,omitempty"` // post new matches to
+	WebhookKey string    `json:"-"`                     // the secret the posts are signed with
+	Created    time.Time `json:"created"`
+	Checked    time.Time `json:"-"` // when new matches were last looked for
+	Alerted    []int     `json:"-"` // the latest articles alerted about
+}
+
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+)
+
+// How often a user gets the digest
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// Workspace groups articles, such as the blogs of one server, with settings
+// of its own and members who may change its articles
+type Workspace struct {
+	Slug        string    `json:"slug"` // in URLs: /workspaces/{slug}/articles
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Created     time.Time `json:"created"`
+
+	// Status of new articles when the request has none; published if empty
+	DefaultStatus string `json:"default_status,omitempty"`
+
+	// Feed channel metadata; empty falls back to the FEED_* settings
+	FeedTitle       string `json:"feed_title,omitempty"`
+	FeedDescription string `json:"feed_description,omitempty"`
+	FeedLanguage    string `json:"feed_language,omitempty"`
+
+	// Whether all its articles are confidential, see Article
+	Confidential bool `json:"confidential,omitempty"`
+
+	// Workspace roles by username
+	Members map[string]string `json:"members,omitempty"`
+
+	// Quotas set by admins instead of the server defaults
+	Limits Limits `json:"limits"`
+}
+
+// Limits caps what a workspace may use. Zero fields take the server's
+// QUOTA_* defaults and -1 lifts a limit.
+type Limits struct {
+	MaxArticles     int   `json:"max_articles,omitempty"`
+	MaxStorageBytes int64 `json:"max_storage_bytes,omitempty"`
+	RateLimit       int   `json:"rate_limit,omitempty"` // requests a minute
+}
+
+// Workspace roles, each allowed what the ones before it are: viewers see
+// drafts, editors write articles, owners change settings and members
+const (
+	WorkspaceViewer = "viewer"
+	WorkspaceEditor = "editor"
+	WorkspaceOwner  = "owner"
+)
+
+var workspaceRanks = map[string]int{WorkspaceViewer: 1, WorkspaceEditor: 2, WorkspaceOwner: 3}
+
+// Allows reports whether user may act with role in the workspace: admins
+// always, members up to their own role
+func (ws Workspace) Allows(user User, role string) bool {
+	return user.Role == RoleAdmin || workspaceRanks[ws.Members[user.Username]] >= workspaceRanks[role]
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// IDCodec turns article IDs into public IDs and back
+type IDCodec interface {
+	Encode(id int) string
+	Decode(s string) (int, bool)
+}
+
+// PublicIDs, when set, replaces article IDs in the API with opaque public
+// IDs (HASHIDS_SALT); nil leaves them numbers. The server sets it at
+// startup, before serving.
+var PublicIDs IDCodec
+
+// ArticleID is an article ID as the API shows it: a number, or its public
+// ID under PublicIDs
+type ArticleID int
+
+func (id ArticleID) String() string {
+	if PublicIDs != nil {
+		return PublicIDs.Encode(int(id))
+	}
+	return strconv.Itoa(int(id))
+}
+
+func (id ArticleID) MarshalJSON() ([]byte, error) {
+	if PublicIDs != nil {
+		return json.Marshal(id.String())
+	}
+	return strconv.AppendInt(nil, int64(id), 10), nil
+}
+
+// UnmarshalJSON takes a number, or a public ID under PublicIDs
+func (id *ArticleID) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) != nil {
+		s = string(data) // a number
+	}
+	parsed, err := ParseArticleID(s)
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// ParseArticleID reads an ID as the API shows it. Under PublicIDs only
+// public IDs are accepted, so that IDs can't be enumerated.
+func ParseArticleID(s string) (ArticleID, error) {
+	if PublicIDs != nil {
+		if id, ok := PublicIDs.Decode(s); ok {
+			return ArticleID(id), nil
+		}
+		return 0, fmt.Errorf("invalid article ID %q", s)
+	}
+	id, err := strconv.Atoi(s)
+	return ArticleID(id), err
+}
+
+// PublicID is the ID of the article as the API shows it
+func (a Article) PublicID() string {
+	return ArticleID(a.ID).String()
+}
+
+// Article without its JSON methods
+type articleFields Article
+
+// MarshalJSON writes the ID as the API shows it
+func (a Article) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ID ArticleID `json:"id"`
+		articleFields
+	}{ArticleID(a.ID), articleFields(a)})
+}
+
+func (a *Article) UnmarshalJSON(data []byte) error {
+	v := struct {
+		ID ArticleID `json:"id"`
+		*articleFields
+	}{articleFields: (*articleFields)(a)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	a.ID = int(v.ID)
+	return nil
+}
@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Chat services that accept incoming-webhook posts
+const (
+	Slack   = "slack"
+	Discord = "discord"
+	Teams   = "teams"
+)
+
+// Webhook is an incoming-webhook URL of a chat channel
+type Webhook struct {
+	URL     string `json:"url"`
+	Service string `json:"service"` // Slack, Discord or Teams
+}
+
+// ParseWebhook recognises the service from the host of an incoming-webhook
+// URL. Other hosts get Slack's format, which Mattermost and Rocket.Chat
+// accept too.
+func ParseWebhook(raw string) (Webhook, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return Webhook{}, fmt.Errorf("invalid webhook URL %q", raw)
+	}
+	host := strings.ToLower(u.Hostname())
+	w := Webhook{URL: raw, Service: Slack}
+	switch {
+	case host == "discord.com" || host == "discordapp.com" || strings.HasSuffix(host, ".discord.com"):
+		w.Service = Discord
+	case strings.HasSuffix(host, ".webhook.office.com") || strings.HasSuffix(host, ".logic.azure.com"):
+		w.Service = Teams
+	}
+	return w, nil
+}
+
+// String hides the secret part of the URL, for logs
+func (w Webhook) String() string {
+	u, err := url.Parse(w.URL)
+	if err != nil {
+		return w.Service
+	}
+	return w.Service + " " + u.Host
+}
+
+// Longest message Discord accepts
+const discordMaxContent = 2000
+
+// The JSON body each service expects
+func (w Webhook) payload(text string) any {
+	switch w.Service {
+	case Discord:
+		if r := []rune(text); len(r) > discordMaxContent {
+			text = string(r[:discordMaxContent-1]) + "…"
+		}
+		return map[string]string{"content": text}
+	case Teams:
+		return map[string]string{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  strings.SplitN(text, "\n", 2)[0],
+			"text":     text,
+		}
+	default:
+		return map[string]string{"text": text}
+	}
+}
+
+// Post sends a message to the channel
+func (w Webhook) Post(ctx context.Context, client *http.Client, text string) error {
+	body, err := json.Marshal(w.payload(text))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err // the URL is a secret
+		}
+		return fmt.Errorf("post to %s: %w", w, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("post to %s: %s: %s", w, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseWebhook(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://hooks.slack.com/services/T0/B0/XyZ":                       Slack,
+		"https://discord.com/api/webhooks/1/AbC":                           Discord,
+		"https://contoso.webhook.office.com/webhookb2/abc/IncomingWebhook": Teams,
+		"https://chat.example.com/hooks/abc":                               Slack,
+	} {
+		w, err := ParseWebhook(raw)
+		if err != nil || w.Service != want || w.URL != raw {
+			t.Errorf("ParseWebhook(%q) = %+v, %v", raw, w, err)
+		}
+	}
+	if _, err := ParseWebhook("hooks.slack.com/services/x"); err == nil {
+		t.Error("URL without a scheme accepted")
+	}
+	w, _ := ParseWebhook("https://hooks.slack.com/services/T0/B0/secret")
+	if strings.Contains(w.String(), "secret") {
+		t.Errorf("String() = %q shows the token", w)
+	}
+}
+
+func TestWebhookPost(t *testing.T) {
+	var got map[string]string
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = nil
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+		io.WriteString(w, "invalid_token")
+	}))
+	defer srv.Close()
+
+	for service, field := range map[string]string{Slack: "text", Discord: "content", Teams: "text"} {
+		w := Webhook{URL: srv.URL + "/hook", Service: service}
+		if err := w.Post(context.Background(), srv.Client(), "Published: Hello\nhttps://example.com"); err != nil {
+			t.Fatal(err)
+		}
+		if got[field] != "Published: Hello\nhttps://example.com" {
+			t.Errorf("%s: posted %v", service, got)
+		}
+		if service == Teams && got["summary"] != "Published: Hello" {
+			t.Errorf("teams summary %q", got["summary"])
+		}
+	}
+
+	discord := Webhook{URL: srv.URL, Service: Discord}
+	discord.Post(context.Background(), srv.Client(), strings.Repeat("ä", 3000))
+	if n := len([]rune(got["content"])); n != discordMaxContent {
+		t.Errorf("discord content of %d characters", n)
+	}
+
+	status = http.StatusForbidden
+	err := Webhook{URL: srv.URL + "/secret", Service: Slack}.Post(context.Background(), srv.Client(), "x")
+	if err == nil || !strings.Contains(err.Error(), "invalid_token") || strings.Contains(err.Error(), "/secret") {
+		t.Errorf("error %v", err)
+	}
+}
+
+func TestChatTemplate(t *testing.T) {
+	templates, err := LoadTemplates("", "https://blog.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := templates.Chat(Notification{Event: EventImportCompleted, Detail: "Imported 3 articles, skipped 1", Error: "line 4: Title, description, and content are required"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Import finished: Imported 3 articles, skipped 1 (first error: line 4: Title, description, and content are required)" {
+		t.Errorf("chat text %q", text)
+	}
+}
@@ -0,0 +1,117 @@
+// Package pii finds personal data in text: email addresses, phone numbers
+// and national identity numbers out of the box, and whatever else a
+// patterns file names. Matching is by pattern only, so it finds likely
+// personal data, not all of it, and the odd number that only looks like a
+// phone number.
+package pii
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// What Redact replaces matches with
+const Redacted = "[redacted]"
+
+// Kinds of the built-in patterns
+const (
+	KindEmail      = "email"
+	KindPhone      = "phone"
+	KindNationalID = "national_id"
+)
+
+// Pattern is a kind of personal data and how to find it
+type Pattern struct {
+	Kind   string
+	Regexp *regexp.Regexp // nil in a patterns file turns the kind off
+}
+
+// Email matches an email address
+var Email = regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)*\.[a-zA-Z]{2,}`)
+
+// Builtin are the patterns a Scanner starts with. Phone numbers need a
+// separator or a leading + so dates and plain numbers don't match; national
+// IDs are Finnish (HETU), Swedish (personnummer) and US (SSN) numbers.
+var Builtin = []Pattern{
+	{KindEmail, Email},
+	{KindPhone, regexp.MustCompile(`(\+\d{1,3}[ -]?|\b)(\(\d{1,4}\)[ -]?)?\d{2,4}[ -]\d{3,4}[ -]?\d{3,4}\b`)},
+	{KindNationalID, regexp.MustCompile(`\b(\d{6}[-+A-FU-Y]\d{3}[0-9A-Y]|(19|20)?\d{6}[-+]\d{4}|\d{3}-\d{2}-\d{4})\b`)},
+}
+
+// Finding is how often a kind of personal data occurs
+type Finding struct {
+	Kind  string
+	Count int
+}
+
+// Scanner finds personal data by its patterns
+type Scanner struct {
+	patterns []Pattern
+}
+
+// New returns a scanner with the built-in patterns and those of extra: one
+// of a built-in kind replaces it, one without a regexp removes it
+func New(extra []Pattern) *Scanner {
+	patterns := slices.Clone(Builtin)
+	for _, p := range extra {
+		patterns = slices.DeleteFunc(patterns, func(q Pattern) bool { return q.Kind == p.Kind })
+		if p.Regexp != nil {
+			patterns = append(patterns, p)
+		}
+	}
+	return &Scanner{patterns: patterns}
+}
+
+// ParsePatterns reads a patterns file: a kind and a regular expression per
+// line, separated by spaces, or a kind alone to turn it off. Blank lines
+// and lines starting with # are skipped.
+func ParsePatterns(text string) ([]Pattern, error) {
+	var list []Pattern
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kind, expr, _ := strings.Cut(line, " ")
+		p := Pattern{Kind: kind}
+		if expr = strings.TrimSpace(expr); expr != "" {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			p.Regexp = re
+		}
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+// Scan returns the kinds of personal data in text, in pattern order
+func (s *Scanner) Scan(text string) []Finding {
+	var findings []Finding
+	for _, p := range s.patterns {
+		if n := len(p.Regexp.FindAllStringIndex(text, -1)); n > 0 {
+			findings = append(findings, Finding{p.Kind, n})
+		}
+	}
+	return findings
+}
+
+// Redact replaces the personal data in text with Redacted and returns what
+// it replaced
+func (s *Scanner) Redact(text string) (string, []Finding) {
+	var findings []Finding
+	for _, p := range s.patterns {
+		n := 0
+		text = p.Regexp.ReplaceAllStringFunc(text, func(string) string {
+			n++
+			return Redacted
+		})
+		if n > 0 {
+			findings = append(findings, Finding{p.Kind, n})
+		}
+	}
+	return text, findings
+}
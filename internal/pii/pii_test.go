@@ -0,0 +1,48 @@
+package pii
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestScanAndRedact(t *testing.T) {
+	s := New(nil)
+	text := "Mail anna.virtanen@example.fi or call +358 40 123 4567 (or 040-1234567). " +
+		"HETU 131052-308T, SSN 078-05-1120. Published 2026-10-16, order 98765432."
+
+	findings := s.Scan(text)
+	want := []Finding{{KindEmail, 1}, {KindPhone, 2}, {KindNationalID, 2}}
+	if !slices.Equal(findings, want) {
+		t.Errorf("Scan found %v, want %v", findings, want)
+	}
+
+	redacted, findings := s.Redact(text)
+	if !slices.Equal(findings, want) {
+		t.Errorf("Redact found %v, want %v", findings, want)
+	}
+	for _, personal := range []string{"anna", "4567", "1234567", "131052", "1120"} {
+		if strings.Contains(redacted, personal) {
+			t.Errorf("%q left in %q", personal, redacted)
+		}
+	}
+	// Dates and plain numbers are left alone
+	if !strings.Contains(redacted, "2026-10-16") || !strings.Contains(redacted, "98765432") {
+		t.Errorf("redacted too much: %q", redacted)
+	}
+}
+
+func TestPatternsFile(t *testing.T) {
+	extra, err := ParsePatterns("# customers\ncustomer_id CUST-\\d+\n\nphone\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(extra)
+	findings := s.Scan("CUST-42 called 040-1234567")
+	if !slices.Equal(findings, []Finding{{"customer_id", 1}}) {
+		t.Errorf("found %v", findings)
+	}
+	if _, err := ParsePatterns("ok x\nbad (unclosed"); err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Errorf("bad pattern gave %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+// Package searchlog keeps the searches made, newest last: what was asked,
+// how many results it found and how long it took, without who asked. Top
+// adds them up by query, so the most searched queries, and those that find
+// nothing, show what readers look for. The log keeps the latest MaxEntries
+// searches, less those DropBefore drops for their age, in memory, and is
+// written to a gob file by Save, as the activity log is.
+package searchlog
+
+import (
+	"cmp"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How many searches are kept; older ones are dropped
+const MaxEntries = 100000
+
+// Entry is one search
+type Entry struct {
+	Time    time.Time
+	Query   string // normalized, see Normalize
+	Mode    string // keyword or semantic
+	Hits    int
+	Latency time.Duration
+}
+
+// Zero reports whether the search found nothing
+func (e Entry) Zero() bool { return e.Hits == 0 }
+
+// Query is the searches of one query in a range
+type Query struct {
+	Query       string
+	Searches    int
+	ZeroResults int // searches that found nothing
+	Hits        int // of the latest search
+	Latency     time.Duration
+	Last        time.Time
+}
+
+// Summary is the searches of a range, and their queries ranked
+type Summary struct {
+	Searches    int
+	ZeroResults int
+	Latency     time.Duration // mean
+	Top         []Query       // most searched first
+	TopZero     []Query       // those whose latest search found nothing, most searched first
+}
+
+// Log holds the searches
+type Log struct {
+	path string
+
+	mutex   sync.Mutex
+	entries []Entry // oldest first
+	dirty   bool
+}
+
+// What is written to the file
+type logFile struct {
+	Entries []Entry
+}
+
+// Open loads the log from path, or starts empty if it doesn't exist; an
+// empty path keeps it in memory only
+func Open(path string) (*Log, error) {
+	l := &Log{path: path}
+	if path == "" {
+		return l, nil
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var data logFile
+	if err := gob.NewDecoder(file).Decode(&data); err != nil {
+		return nil, fmt.Errorf("read search log %s: %w", path, err)
+	}
+	l.entries = data.Entries
+	return l, nil
+}
+
+// Normalize lower-cases q and collapses its spaces, so queries typed alike
+// add up
+func Normalize(q string) string {
+	return strings.Join(strings.Fields(strings.ToLower(q)), " ")
+}
+
+// Add a search, normalizing its query
+func (l *Log) Add(e Entry) {
+	e.Query = Normalize(e.Query)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.entries = append(l.entries, e)
+	if len(l.entries) > MaxEntries {
+		l.entries = append([]Entry(nil), l.entries[len(l.entries)-MaxEntries:]...)
+	}
+	l.dirty = true
+}
+
+// Top adds up the searches from from up to to by query and returns the n
+// most searched queries, and the n most searched of those whose latest
+// search found nothing; ties go to the latest searched
+func (l *Log) Top(from, to time.Time, n int) Summary {
+	l.mutex.Lock()
+	queries := map[string]*Query{}
+	var summary Summary
+	for _, e := range l.entries {
+		if e.Time.Before(from) || e.Time.After(to) {
+			continue
+		}
+		summary.Searches++
+		summary.Latency += e.Latency
+		q := queries[e.Query]
+		if q == nil {
+			q = &Query{Query: e.Query}
+			queries[e.Query] = q
+		}
+		q.Searches++
+		if e.Zero() {
+			summary.ZeroResults++
+			q.ZeroResults++
+		}
+		// The mean latency of the query, once all are added
+		q.Latency += e.Latency
+		if !e.Time.Before(q.Last) {
+			q.Hits, q.Last = e.Hits, e.Time
+		}
+	}
+	l.mutex.Unlock()
+
+	if summary.Searches > 0 {
+		summary.Latency /= time.Duration(summary.Searches)
+	}
+	ranked := make([]Query, 0, len(queries))
+	for _, q := range queries {
+		q.Latency /= time.Duration(q.Searches)
+		ranked = append(ranked, *q)
+	}
+	slices.SortFunc(ranked, func(a, b Query) int {
+		return cmp.Or(cmp.Compare(b.Searches, a.Searches), b.Last.Compare(a.Last), cmp.Compare(a.Query, b.Query))
+	})
+	summary.Top = ranked[:min(len(ranked), n)]
+	summary.TopZero = []Query{}
+	for _, q := range ranked {
+		if q.Hits == 0 && len(summary.TopZero) < n {
+			summary.TopZero = append(summary.TopZero, q)
+		}
+	}
+	return summary
+}
+
+// DropBefore removes the searches older than before and returns how many
+// there were; a dry run only counts them
+func (l *Log) DropBefore(before time.Time, dryRun bool) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	n := 0
+	for n < len(l.entries) && l.entries[n].Time.Before(before) {
+		n++
+	}
+	if !dryRun && n > 0 {
+		l.entries = append([]Entry(nil), l.entries[n:]...)
+		l.dirty = true
+	}
+	return n
+}
+
+// Save writes the log to the file, if it changed
+func (l *Log) Save() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.path == "" || !l.dirty {
+		return nil
+	}
+
+	// Replace the file, so an interrupted save never leaves a torn one
+	file, err := os.Create(l.path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(file).Encode(logFile{Entries: l.entries}); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(l.path+".tmp", l.path); err != nil {
+		return err
+	}
+	l.dirty = false
+	return nil
+}
@@ -0,0 +1,58 @@
+package searchlog
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "searches.gob")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 10, 14, 30, 0, 0, time.UTC)
+	add := func(ago time.Duration, q string, hits int, latency time.Duration) {
+		l.Add(Entry{Time: now.Add(-ago), Query: q, Mode: "keyword", Hits: hits, Latency: latency})
+	}
+	add(48*time.Hour, "raft", 0, time.Millisecond) // before the range
+	add(3*time.Hour, "Raft", 4, 2*time.Millisecond)
+	add(2*time.Hour, "  raft ", 3, 4*time.Millisecond)
+	add(2*time.Hour, "kubernetes", 0, time.Millisecond)
+	add(time.Hour, "Kubernetes", 0, time.Millisecond)
+	add(time.Hour, "wasm", 0, 8*time.Millisecond)
+	add(30*time.Minute, "goroutnes", 0, time.Millisecond)
+	add(10*time.Minute, "goroutnes", 2, time.Millisecond) // found after all
+
+	if err := l.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if l, err = Open(path); err != nil {
+		t.Fatal(err)
+	}
+	s := l.Top(now.Add(-24*time.Hour), now, 2)
+	if s.Searches != 7 || s.ZeroResults != 4 || s.Latency != 18*time.Millisecond/7 {
+		t.Errorf("summary %d searches, %d zero, %v", s.Searches, s.ZeroResults, s.Latency)
+	}
+	// Ties go to the latest searched
+	if len(s.Top) != 2 || s.Top[0].Query != "goroutnes" || s.Top[1].Query != "kubernetes" {
+		t.Fatalf("top %+v", s.Top)
+	}
+	if raft := l.Top(now.Add(-24*time.Hour), now, 5).Top[2]; raft.Query != "raft" || raft.Searches != 2 || raft.Hits != 3 || raft.Latency != 3*time.Millisecond {
+		t.Errorf("raft %+v", raft)
+	}
+	if len(s.TopZero) != 2 || s.TopZero[0].Query != "kubernetes" || s.TopZero[0].ZeroResults != 2 || s.TopZero[1].Query != "wasm" {
+		t.Errorf("top zero %+v", s.TopZero)
+	}
+
+	if n := l.DropBefore(now.Add(-24*time.Hour), true); n != 1 {
+		t.Errorf("dry run counted %d", n)
+	}
+	if n := l.DropBefore(now.Add(-150*time.Minute), false); n != 2 {
+		t.Errorf("dropped %d", n)
+	}
+	if s := l.Top(time.Time{}, now, 10); s.Searches != 6 || s.Top[3].Query != "raft" || s.Top[3].Searches != 1 {
+		t.Errorf("after drop %+v", s)
+	}
+}
@@ -0,0 +1,123 @@
+// Package slug turns article titles into URL path segments. Titles are
+// mostly Finnish, so letters with diacritics are transliterated rather than
+// dropped:
+//
+//	Make("Äänestys päättyy: yö on pitkä")        // "aanestys-paattyy-yo-on-pitka"
+//	MakeUnicode("Äänestys päättyy: yö on pitkä") // "äänestys-päättyy-yö-on-pitkä"
+//
+// Make keeps ASCII only, dropping scripts it can't transliterate such as
+// CJK; MakeUnicode keeps every letter and digit, to be percent-encoded in
+// URLs. Both drop emoji and punctuation, and return Fallback when nothing
+// is left.
+package slug
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Fallback is the slug of a title with no usable characters, e.g. only emoji
+const Fallback = "article"
+
+// MaxLength is the most characters a slug has, before a collision suffix
+const MaxLength = 80
+
+// Latin letters and their ASCII transliterations, by lower-case letter
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'þ': "th", 'ð': "d",
+	'ł': "l", 'đ': "d", 'ħ': "h", 'ı': "i", 'ŋ': "ng", 'ĸ': "k",
+}
+
+func init() {
+	for ascii, letters := range map[string]string{
+		"a": "àáâãäåāăąǎǻ",
+		"c": "çćĉċč",
+		"d": "ď",
+		"e": "èéêëēĕėęě",
+		"g": "ĝğġģ",
+		"h": "ĥ",
+		"i": "ìíîïĩīĭįǐ",
+		"j": "ĵ",
+		"k": "ķ",
+		"l": "ĺļľŀ",
+		"n": "ñńņňŉ",
+		"o": "òóôõöōŏőǒ",
+		"r": "ŕŗř",
+		"s": "śŝşšș",
+		"t": "ţťŧț",
+		"u": "ùúûüũūŭůűųǔ",
+		"w": "ŵ",
+		"y": "ýÿŷ",
+		"z": "źżž",
+	} {
+		for _, r := range letters {
+			transliterations[r] = ascii
+		}
+	}
+}
+
+// Make returns the ASCII slug of title: lower-case letters and digits,
+// words joined by hyphens
+func Make(title string) string {
+	return build(title, func(r rune) string {
+		if r < unicode.MaxASCII {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return string(r)
+			}
+			return ""
+		}
+		return transliterations[r]
+	})
+}
+
+// MakeUnicode returns the slug of title keeping letters and digits of any
+// script
+func MakeUnicode(title string) string {
+	return build(title, func(r rune) string {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) {
+			return string(r)
+		}
+		return ""
+	})
+}
+
+// Join the kept characters of title, one hyphen for every run of others
+func build(title string, keep func(rune) string) string {
+	var b strings.Builder
+	gap, length := false, 0
+	for _, r := range strings.ToLower(title) {
+		s := keep(r)
+		if s == "" {
+			// "don't" is one word, and so is a letter with a combining accent
+			gap = gap || r != '\'' && r != '’' && !unicode.Is(unicode.Mn, r)
+			continue
+		}
+		if gap && b.Len() > 0 {
+			if length+1 >= MaxLength {
+				break
+			}
+			b.WriteByte('-')
+			length++
+		}
+		gap = false
+		if length+len([]rune(s)) > MaxLength {
+			break
+		}
+		b.WriteString(s)
+		length += len([]rune(s))
+	}
+	if b.Len() == 0 {
+		return Fallback
+	}
+	return b.String()
+}
+
+// Unique returns base, or base-2, base-3... for the first one that isn't taken
+func Unique(base string, taken func(string) bool) string {
+	s := base
+	for n := 2; taken(s); n++ {
+		s = base + "-" + strconv.Itoa(n)
+	}
+	return s
+}
@@ -0,0 +1,47 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		title, ascii, unicode string
+	}{
+		{"Hello, World!", "hello-world", "hello-world"},
+		{"Äänestys päättyy: yö on pitkä", "aanestys-paattyy-yo-on-pitka", "äänestys-päättyy-yö-on-pitkä"},
+		{"Åland & Straße", "aland-strasse", "åland-straße"},
+		{"Don't panic", "dont-panic", "dont-panic"},
+		{"Go 1.23 – uutta", "go-1-23-uutta", "go-1-23-uutta"},
+		{"東京 travel 🚀", "travel", "東京-travel"},
+		{"🎉🎉", Fallback, Fallback},
+		{"Caf\u00e9 au lait", "cafe-au-lait", "caf\u00e9-au-lait"},
+		{"Cafe\u0301 noir", "cafe-noir", "cafe\u0301-noir"}, // decomposed accent
+	}
+	for _, tt := range tests {
+		if got := Make(tt.title); got != tt.ascii {
+			t.Errorf("Make(%q) = %q, want %q", tt.title, got, tt.ascii)
+		}
+		if got := MakeUnicode(tt.title); got != tt.unicode {
+			t.Errorf("MakeUnicode(%q) = %q, want %q", tt.title, got, tt.unicode)
+		}
+	}
+}
+
+func TestMakeLength(t *testing.T) {
+	got := Make(strings.Repeat("sana ", 40))
+	if len(got) > MaxLength || strings.HasSuffix(got, "-") {
+		t.Errorf("Make of a long title = %q (%d characters)", got, len(got))
+	}
+}
+
+func TestUnique(t *testing.T) {
+	taken := map[string]bool{"uutiset": true, "uutiset-2": true}
+	if got := Unique("uutiset", func(s string) bool { return taken[s] }); got != "uutiset-3" {
+		t.Errorf("Unique = %q, want uutiset-3", got)
+	}
+	if got := Unique("muut", func(s string) bool { return taken[s] }); got != "muut" {
+		t.Errorf("Unique = %q, want muut", got)
+	}
+}
@@ -0,0 +1,162 @@
+// Package spam scores submissions by how likely they are spam. A Scorer
+// adds up the points of its checks: keyword rules, link density, Akismet,
+// or any other Check; the caller decides what score is too much.
+package spam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Submission is what is checked: the text and where it came from
+type Submission struct {
+	Title     string
+	Desc      string
+	Content   string
+	IP        string // of the client, if known
+	UserAgent string
+	Referrer  string
+}
+
+// Text is the title, description and content together
+func (s Submission) Text() string {
+	return s.Title + "\n" + s.Desc + "\n" + s.Content
+}
+
+// Check scores a submission: points and why, or 0 if it finds nothing
+type Check interface {
+	Check(ctx context.Context, s Submission) (points int, reason string, err error)
+}
+
+// CheckFunc is a Check as a function
+type CheckFunc func(ctx context.Context, s Submission) (int, string, error)
+
+func (f CheckFunc) Check(ctx context.Context, s Submission) (int, string, error) {
+	return f(ctx, s)
+}
+
+// Verdict is the score of a submission and the reasons for it
+type Verdict struct {
+	Score   int
+	Reasons []string
+}
+
+// Scorer adds up the points of its checks
+type Scorer struct {
+	Checks []Check
+}
+
+// Score runs every check. A check that fails counts for nothing; the
+// errors are returned with the verdict of the others.
+func (sc Scorer) Score(ctx context.Context, s Submission) (Verdict, error) {
+	var v Verdict
+	var errs []error
+	for _, c := range sc.Checks {
+		points, reason, err := c.Check(ctx, s)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if points > 0 {
+			v.Score += points
+			v.Reasons = append(v.Reasons, reason)
+		}
+	}
+	return v, errors.Join(errs...)
+}
+
+// Keywords gives a point for every word or phrase of words found in a
+// submission, ignoring case
+func Keywords(words []string) Check {
+	return CheckFunc(func(ctx context.Context, s Submission) (int, string, error) {
+		text := strings.ToLower(s.Text())
+		var found []string
+		for _, w := range words {
+			if strings.Contains(text, strings.ToLower(w)) {
+				found = append(found, w)
+			}
+		}
+		if len(found) == 0 {
+			return 0, "", nil
+		}
+		return len(found), "keywords: " + strings.Join(found, ", "), nil
+	})
+}
+
+var linkRe = regexp.MustCompile(`(?i)\b(https?://|www\.)`)
+
+// LinkDensity gives two points to a submission with more than perHundred
+// links per 100 words, and at least three links
+func LinkDensity(perHundred int) Check {
+	return CheckFunc(func(ctx context.Context, s Submission) (int, string, error) {
+		text := s.Text()
+		links := len(linkRe.FindAllStringIndex(text, -1))
+		words := max(len(strings.Fields(text)), 1)
+		if links < 3 || links*100 <= perHundred*words {
+			return 0, "", nil
+		}
+		return 2, fmt.Sprintf("%d links in %d words", links, words), nil
+	})
+}
+
+// The comment-check endpoint of the Akismet API
+const AkismetEndpoint = "https://rest.akismet.com/1.1/comment-check"
+
+// Akismet asks the Akismet service, which gives three points to what it
+// thinks is spam. Submissions without a client IP, which it requires, are
+// not sent.
+type Akismet struct {
+	Key      string // API key
+	Blog     string // URL of the site, as registered with Akismet
+	Endpoint string // AkismetEndpoint if empty
+	Client   *http.Client
+}
+
+func (a Akismet) Check(ctx context.Context, s Submission) (int, string, error) {
+	if s.IP == "" {
+		return 0, "", nil
+	}
+	form := url.Values{
+		"api_key":         {a.Key},
+		"blog":            {a.Blog},
+		"user_ip":         {s.IP},
+		"user_agent":      {s.UserAgent},
+		"referrer":        {s.Referrer},
+		"comment_type":    {"blog-post"},
+		"comment_content": {s.Text()},
+	}
+	endpoint := a.Endpoint
+	if endpoint == "" {
+		endpoint = AkismetEndpoint
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	client := a.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("akismet: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	switch strings.TrimSpace(string(body)) {
+	case "true":
+		return 3, "akismet", nil
+	case "false":
+		return 0, "", nil
+	}
+	// Anything else is an error, explained in a header
+	return 0, "", fmt.Errorf("akismet: %s %s", resp.Status, resp.Header.Get("X-akismet-debug-help"))
+}
@@ -0,0 +1,70 @@
+package spam
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestScorer(t *testing.T) {
+	sc := Scorer{Checks: []Check{
+		Keywords([]string{"casino", "payday loan"}),
+		LinkDensity(10),
+		CheckFunc(func(ctx context.Context, s Submission) (int, string, error) {
+			return 0, "", errors.New("down")
+		}),
+	}}
+	ctx := context.Background()
+
+	clean := Submission{Title: "Release notes", Content: "We fixed a bug. See https://example.com/changelog for more."}
+	if v, err := sc.Score(ctx, clean); v.Score != 0 || err == nil {
+		t.Errorf("clean submission scored %+v, %v", v, err)
+	}
+
+	links := strings.Repeat("https://spam.example ", 4)
+	spammy := Submission{Title: "Best CASINO bonus", Desc: "No payday loan needed", Content: "Click " + links}
+	v, _ := sc.Score(ctx, spammy)
+	if v.Score != 4 || len(v.Reasons) != 2 || v.Reasons[0] != "keywords: casino, payday loan" {
+		t.Errorf("spam scored %+v", v)
+	}
+}
+
+func TestAkismet(t *testing.T) {
+	var form map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = map[string]string{"key": r.Form.Get("api_key"), "ip": r.Form.Get("user_ip"), "content": r.Form.Get("comment_content")}
+		switch {
+		case strings.Contains(form["content"], "viagra"):
+			w.Write([]byte("true"))
+		case strings.Contains(form["content"], "broken"):
+			w.Header().Set("X-akismet-debug-help", "Empty api_key")
+			w.Write([]byte("invalid"))
+		default:
+			w.Write([]byte("false"))
+		}
+	}))
+	defer srv.Close()
+	a := Akismet{Key: "k", Blog: "https://blog.example", Endpoint: srv.URL}
+	ctx := context.Background()
+
+	if points, reason, err := a.Check(ctx, Submission{Content: "cheap viagra", IP: "192.0.2.1"}); points != 3 || reason != "akismet" || err != nil {
+		t.Errorf("spam got %d %q %v", points, reason, err)
+	}
+	if form["key"] != "k" || form["ip"] != "192.0.2.1" {
+		t.Errorf("sent %v", form)
+	}
+	if points, _, err := a.Check(ctx, Submission{Content: "hello", IP: "192.0.2.1"}); points != 0 || err != nil {
+		t.Errorf("ham got %d %v", points, err)
+	}
+	if _, _, err := a.Check(ctx, Submission{Content: "broken", IP: "192.0.2.1"}); err == nil || !strings.Contains(err.Error(), "Empty api_key") {
+		t.Errorf("invalid answer gave %v", err)
+	}
+	form = nil
+	if points, _, _ := a.Check(ctx, Submission{Content: "cheap viagra"}); points != 0 || form != nil {
+		t.Error("submission without an IP was sent")
+	}
+}
@@ -0,0 +1,139 @@
+// Package spell corrects misspelt words against a vocabulary with the
+// symmetric delete algorithm of SymSpell: every word is indexed under the
+// strings left when up to two of its letters are deleted, so the words
+// close to a misspelling are found by deleting letters from it in turn,
+// with no alphabet to try insertions and replacements from. Only the first
+// letters of a word are indexed, as typos past them change little of what
+// is found, and every candidate is checked with the full edit distance.
+package spell
+
+import (
+	"slices"
+	"strings"
+)
+
+const (
+	maxDistance  = 2 // edits a correction may be away
+	prefixLength = 7 // letters of a word indexed
+)
+
+// Dictionary is a vocabulary and how often each word occurs in it
+type Dictionary struct {
+	counts  map[string]int
+	deletes map[string][]string // indexed prefix with letters deleted: the words
+	sorted  []string
+}
+
+// New indexes the words of counts, which it keeps
+func New(counts map[string]int) *Dictionary {
+	d := &Dictionary{counts: counts, deletes: map[string][]string{}, sorted: make([]string, 0, len(counts))}
+	for word := range counts {
+		d.sorted = append(d.sorted, word)
+	}
+	slices.Sort(d.sorted) // so the index, and ties, don't depend on map order
+	for _, word := range d.sorted {
+		for key := range variants(prefix(word), maxDistance) {
+			d.deletes[key] = append(d.deletes[key], word)
+		}
+	}
+	return d
+}
+
+// Contains reports whether word is in the vocabulary
+func (d *Dictionary) Contains(word string) bool {
+	_, ok := d.counts[word]
+	return ok
+}
+
+// HasPrefix reports whether a word of the vocabulary begins with prefix
+func (d *Dictionary) HasPrefix(prefix string) bool {
+	i, _ := slices.BinarySearch(d.sorted, prefix)
+	return i < len(d.sorted) && strings.HasPrefix(d.sorted[i], prefix)
+}
+
+// Correct returns the word of the vocabulary closest to word: the fewest
+// edits away, one for words of up to four letters and two for longer
+// ones, then the most frequent. ok is false if there is none, or word is
+// in the vocabulary.
+func (d *Dictionary) Correct(word string) (correction string, ok bool) {
+	if d.Contains(word) {
+		return "", false
+	}
+	limit := maxDistance
+	if len([]rune(word)) <= 4 {
+		limit = 1
+	}
+	best, bestDistance := "", limit+1
+	seen := map[string]bool{}
+	for key := range variants(prefix(word), limit) {
+		for _, candidate := range d.deletes[key] {
+			if seen[candidate] {
+				continue
+			}
+			seen[candidate] = true
+			n := distance(word, candidate)
+			if n < bestDistance || n == bestDistance && d.counts[candidate] > d.counts[best] {
+				best, bestDistance = candidate, n
+			}
+		}
+	}
+	return best, best != ""
+}
+
+func prefix(word string) string {
+	if r := []rune(word); len(r) > prefixLength {
+		return string(r[:prefixLength])
+	}
+	return word
+}
+
+// word and the strings left by deleting up to n of its letters
+func variants(word string, n int) map[string]bool {
+	set := map[string]bool{word: true}
+	level := []string{word}
+	for ; n > 0; n-- {
+		var next []string
+		for _, w := range level {
+			r := []rune(w)
+			if len(r) <= 1 {
+				continue
+			}
+			for i := range r {
+				deleted := string(slices.Concat(r[:i], r[i+1:]))
+				if !set[deleted] {
+					set[deleted] = true
+					next = append(next, deleted)
+				}
+			}
+		}
+		level = next
+	}
+	return set
+}
+
+// The optimal string alignment distance of a and b: insertions,
+// deletions, replacements and swaps of adjacent letters
+func distance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	rows := make([][]int, len(s)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(t)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(s)][len(t)]
+}
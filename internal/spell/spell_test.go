@@ -0,0 +1,29 @@
+package spell
+
+import "testing"
+
+func TestCorrect(t *testing.T) {
+	d := New(map[string]int{"goroutines": 5, "goroutine": 3, "channels": 4, "channel": 2, "concurrency": 2, "cat": 1, "cart": 3, "säästö": 1})
+	for _, c := range []struct{ word, want string }{
+		{"goroutnes", "goroutines"},
+		{"gorotuines", "goroutines"}, // a swap is one edit
+		{"chanel", "channel"},
+		{"concurrenyc", "concurrency"},
+		{"cst", "cat"},
+		{"saastö", "säästö"},
+		{"caat", "cart"}, // cat too, but cart is more frequent
+	} {
+		if got, ok := d.Correct(c.word); !ok || got != c.want {
+			t.Errorf("Correct(%q) = %q, %t; want %q", c.word, got, ok, c.want)
+		}
+	}
+	for _, word := range []string{"goroutines", "xyz", "kubernetes", "dog"} {
+		if got, ok := d.Correct(word); ok {
+			t.Errorf("Correct(%q) = %q", word, got)
+		}
+	}
+
+	if !d.HasPrefix("gorout") || !d.HasPrefix("cat") || d.HasPrefix("gorx") || d.HasPrefix("zz") {
+		t.Error("HasPrefix")
+	}
+}
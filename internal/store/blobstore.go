@@ -0,0 +1,248 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go-spring/internal/config"
+)
+
+// ErrBlobNotFound is returned by BlobStore.Get for unknown keys
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore stores opaque binary objects (attachment files) by key. An
+// operation gives up with ctx's error once ctx is done; the reader from Get
+// stops with it too.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// BlobRanger is a BlobStore that reads part of a blob without reading what
+// comes before it
+type BlobRanger interface {
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+}
+
+// BlobLister is a BlobStore that lists its blobs, which finding the ones
+// nothing refers to any more needs
+type BlobLister interface {
+	List(ctx context.Context, prefix string) ([]BlobInfo, error)
+}
+
+// BlobInfo describes a blob in a listing
+type BlobInfo struct {
+	Key      string
+	Size     int64
+	Modified time.Time
+}
+
+// GetRange reads length bytes of the blob key from offset, which must be
+// within it: with the store's GetRange if it has one, otherwise by skipping
+// ahead in what Get returns
+func GetRange(ctx context.Context, bs BlobStore, key string, offset, length int64) (io.ReadCloser, error) {
+	if ranger, ok := bs.(BlobRanger); ok {
+		return ranger.GetRange(ctx, key, offset, length)
+	}
+	blob, err := bs.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, blob, offset); err != nil {
+		blob.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(blob, length), blob}, nil
+}
+
+// Create the blob store selected by configuration (BLOB_STORE)
+func NewBlobStore(cfg config.Config) (BlobStore, error) {
+	switch cfg.BlobStore {
+	case "", "local":
+		return NewLocalBlobStore(cfg.AttachmentsDir), nil
+	case "s3", "gcs":
+		if cfg.S3Bucket == "" || cfg.S3AccessKey == "" || cfg.S3SecretKey == "" {
+			return nil, fmt.Errorf("%s blob store needs S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY", cfg.BlobStore)
+		}
+		store := &S3BlobStore{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			Prefix:    cfg.S3Prefix,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			Token:     cfg.S3SessionToken,
+			PathStyle: cfg.S3PathStyle,
+		}
+		// GCS speaks the S3 protocol with HMAC interoperability keys
+		if cfg.BlobStore == "gcs" && store.Endpoint == "" {
+			store.Endpoint = "https://storage.googleapis.com"
+			store.Region = "auto"
+		}
+		if store.Endpoint == "" {
+			store.Endpoint = "https://s3." + store.Region + ".amazonaws.com"
+		}
+		return store, nil
+	}
+	return nil, fmt.Errorf("unknown BLOB_STORE %q (expected local, s3 or gcs)", cfg.BlobStore)
+}
+
+// LocalBlobStore keeps blobs as files under a directory
+type LocalBlobStore struct {
+	Dir string
+}
+
+func NewLocalBlobStore(dir string) *LocalBlobStore {
+	return &LocalBlobStore{Dir: dir}
+}
+
+// Map a key to a path inside Dir, rejecting keys that would escape it
+func (s *LocalBlobStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", errors.New("invalid blob key")
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(clean)), nil
+}
+
+func (s *LocalBlobStore) Put(ctx context.Context, key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temp file first so readers never see partial blobs
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, contextReader{ctx, r}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{contextReader{ctx, file}, file}, nil
+}
+
+func (s *LocalBlobStore) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{contextReader{ctx, io.NewSectionReader(file, offset, length)}, file}, nil
+}
+
+func (s *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List walks the files under prefix, skipping uploads in progress
+func (s *LocalBlobStore) List(ctx context.Context, prefix string) ([]BlobInfo, error) {
+	var blobs []BlobInfo
+	dir, _ := path.Split(prefix)
+	err := filepath.WalkDir(filepath.Join(s.Dir, filepath.FromSlash(dir)), func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.Dir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, BlobInfo{Key: key, Size: info.Size(), Modified: info.ModTime()})
+		return nil
+	})
+	return blobs, err
+}
+
+// contextReader stops reading once ctx is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+)
+
+// Article content is most of a store's size and Markdown compresses to a
+// fraction of it, so content of compressMinBytes or more is written
+// gzip-compressed, when that makes it smaller. Reads expand it, so nothing
+// above the store sees the difference. In a .gob file the compressed bytes
+// take the place of the text: gzip's magic number can't start valid UTF-8,
+// so content that starts with it was compressed. SQL stores leave the
+// content column empty and keep the bytes in the details column.
+//
+// Data written before, or with compression off, is read as it is; the
+// compact command rewrites it with the current setting.
+
+const (
+	CompressionGzip = "gzip"
+	CompressionOff  = "off"
+)
+
+// Shorter content isn't worth the gzip header and a decompression on load
+const compressMinBytes = 512
+
+const gzipMagic = "\x1f\x8b"
+
+// The content as written, compressed when that is on and saves space: ok
+// is false to keep the text
+func compressContent(content, compression string) (compressed []byte, ok bool) {
+	if compression == CompressionOff || len(content) < compressMinBytes {
+		return nil, false
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	io.WriteString(zw, content)
+	if err := zw.Close(); err != nil || buf.Len() >= len(content) {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// Whether content read from a .gob file was compressed
+func isCompressed(content string) bool {
+	return strings.HasPrefix(content, gzipMagic)
+}
+
+func expandContent(compressed []byte) (string, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return "", err
+	}
+	var content strings.Builder
+	if _, err := io.Copy(&content, zr); err != nil {
+		return "", err
+	}
+	return content.String(), nil
+}
+
+// Compact rewrites all of st with its current ContentOptions, so that
+// data written before it was on, or with another setting, follows it; SQL
+// databases are reindexed and vacuumed afterwards to give the freed space
+// back, see Vacuum
+func Compact(ctx context.Context, st DataStore) error {
+	db, err := st.Load(ctx)
+	if err != nil {
+		return err
+	}
+	if err := st.Save(ctx, db); err != nil {
+		return err
+	}
+	return Vacuum(ctx, st)
+}
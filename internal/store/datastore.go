@@ -0,0 +1,672 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-spring/internal/model"
+)
+
+// Contents of the data store; new fields must stay gob-compatible
+// with the articles.gob file
+type Database struct {
+	Articles         []model.Article
+	NextID           int
+	NextAttachmentID int
+	Users            []model.User
+	Workspaces       []model.Workspace
+	Version          int // number of Migrations applied
+}
+
+// DataStore persists the database: the gob file by default, or an SQL
+// database. The server keeps everything in memory and uses Load and Save;
+// copying between stores (go-spring migrate -to) works in batches. Every
+// operation gives up with ctx's error once ctx is done; a save that gives
+// up leaves the stored data as it was.
+type DataStore interface {
+	// Load everything; os.ErrNotExist when the store holds no data yet
+	Load(ctx context.Context) (Database, error)
+	// Replace everything with db
+	Save(ctx context.Context, db Database) error
+	// Insert or replace articles by ID
+	PutArticles(ctx context.Context, batch []model.Article) error
+	// Highest stored article ID, 0 when there are none
+	LastArticleID(ctx context.Context) (int, error)
+	// Store counters, users, workspaces and version, leaving articles alone
+	SaveMeta(ctx context.Context, db Database) error
+	Close() error
+}
+
+var (
+	// ErrLocked means another process has the .gob file open for writing
+	ErrLocked = errors.New("store: locked by another process")
+	// ErrReadOnly is returned by saves to a store opened with OpenReadOnly
+	ErrReadOnly = errors.New("store: opened read-only")
+)
+
+// Open a store from a location: a .gob file path (optionally gob:path),
+// sqlite:path, or a postgres:// URL. SQL drivers are compiled in with the
+// sqlite and postgres build tags.
+//
+// A .gob file is locked until Close, as two processes saving it would
+// overwrite each other's changes; while another process holds the lock,
+// Open fails with ErrLocked. SQL databases take care of concurrent writers
+// themselves.
+func Open(location string) (DataStore, error) {
+	st, err := open(location)
+	if gob, ok := st.(*gobStore); ok {
+		// Saves replace the file, so the lock is on a file next to it
+		if gob.lock, err = lockFile(gob.path + ".lock"); errors.Is(err, ErrLocked) {
+			err = fmt.Errorf("%s: %w (is the server running?)", gob.path, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return st, err
+}
+
+// OpenReadOnly opens a store for reading while another process may be
+// using it, as backups do: a .gob file is not locked and saving it fails
+// with ErrReadOnly. Saves replace the file at once, so reads never see a
+// partly written one.
+func OpenReadOnly(location string) (DataStore, error) {
+	st, err := open(location)
+	if gob, ok := st.(*gobStore); ok {
+		gob.readOnly = true
+	}
+	return st, err
+}
+
+// IsReadOnly reports whether st was opened with OpenReadOnly and can't save
+func IsReadOnly(st DataStore) bool {
+	gob, ok := st.(*gobStore)
+	return ok && gob.readOnly
+}
+
+func open(location string) (DataStore, error) {
+	switch {
+	case strings.HasPrefix(location, "sqlite:"):
+		return openSQLStore("sqlite3", strings.TrimPrefix(location, "sqlite:"), false)
+	case strings.HasPrefix(location, "postgres://"), strings.HasPrefix(location, "postgresql://"):
+		return openSQLStore("postgres", location, true)
+	case strings.Contains(location, "://"):
+		return nil, fmt.Errorf("unsupported store %q (want a .gob path, sqlite:path or postgres://...)", location)
+	}
+	return &gobStore{path: strings.TrimPrefix(location, "gob:")}, nil
+}
+
+// gobStore is the original single-file store
+type gobStore struct {
+	path     string
+	pending  *Database // loaded by PutArticles/SaveMeta for batched writes
+	lock     *os.File  // held from Open to Close
+	readOnly bool
+	content  ContentOptions
+}
+
+func (s *gobStore) Load(ctx context.Context) (Database, error) {
+	var db Database
+	if err := ctx.Err(); err != nil {
+		return db, err
+	}
+	file, err := os.Open(s.path)
+	if err != nil {
+		return db, err
+	}
+	defer file.Close()
+	if err := gob.NewDecoder(file).Decode(&db); err != nil {
+		return db, err
+	}
+	for i := range db.Articles {
+		if a := &db.Articles[i]; a.ContentKeyID != "" || isCompressed(a.Content) {
+			if a.Content, err = s.content.decodeContent([]byte(a.Content), a.ContentKeyID); err != nil {
+				return db, fmt.Errorf("article %d content: %w", a.ID, err)
+			}
+			a.ContentKeyID = ""
+		}
+		if err := s.content.decodeTranslations(db.Articles[i].Translations); err != nil {
+			return db, fmt.Errorf("article %d: %w", db.Articles[i].ID, err)
+		}
+	}
+	return db, nil
+}
+
+// The data is written to a temporary file that replaces the old one, so an
+// interrupted save never leaves a torn file
+func (s *gobStore) Save(ctx context.Context, db Database) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	file, err := os.Create(s.path + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Compressed and encrypted into a copy: db is the caller's, or pending
+	db.Articles = slices.Clone(db.Articles)
+	for i := range db.Articles {
+		a := &db.Articles[i]
+		data, keyID, ok, err := s.content.encodeContent(*a)
+		if err != nil {
+			return fmt.Errorf("article %d content: %w", a.ID, err)
+		}
+		if ok {
+			a.Content, a.ContentKeyID = string(data), keyID
+		}
+		if a.Translations, err = s.content.encodeTranslations(*a); err != nil {
+			return fmt.Errorf("article %d: %w", a.ID, err)
+		}
+	}
+	if err := gob.NewEncoder(file).Encode(db); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	// Too late for the caller, who will report the save as failed
+	if err := ctx.Err(); err != nil {
+		os.Remove(s.path + ".tmp")
+		return err
+	}
+	return os.Rename(s.path+".tmp", s.path)
+}
+
+func (s *gobStore) current(ctx context.Context) (*Database, error) {
+	if s.pending == nil {
+		db, err := s.Load(ctx)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		s.pending = &db
+	}
+	return s.pending, nil
+}
+
+func (s *gobStore) PutArticles(ctx context.Context, batch []model.Article) error {
+	db, err := s.current(ctx)
+	if err != nil {
+		return err
+	}
+	for _, article := range batch {
+		i, found := slices.BinarySearchFunc(db.Articles, article.ID, func(a model.Article, id int) int { return a.ID - id })
+		if found {
+			db.Articles[i] = article
+		} else {
+			db.Articles = slices.Insert(db.Articles, i, article)
+		}
+	}
+	return s.Save(ctx, *db)
+}
+
+func (s *gobStore) LastArticleID(ctx context.Context) (int, error) {
+	db, err := s.current(ctx)
+	if err != nil || len(db.Articles) == 0 {
+		return 0, err
+	}
+	return db.Articles[len(db.Articles)-1].ID, nil
+}
+
+func (s *gobStore) SaveMeta(ctx context.Context, meta Database) error {
+	db, err := s.current(ctx)
+	if err != nil {
+		return err
+	}
+	db.NextID, db.NextAttachmentID, db.Users, db.Workspaces, db.Version = meta.NextID, meta.NextAttachmentID, meta.Users, meta.Workspaces, meta.Version
+	return s.Save(ctx, *db)
+}
+
+func (s *gobStore) Close() error {
+	if s.lock == nil {
+		return nil
+	}
+	err := s.lock.Close() // releases the lock
+	s.lock = nil
+	return err
+}
+
+// sqlStore keeps articles, users and counters in three tables. Scalar
+// article fields get their own columns; attachments, cover image,
+// categories, slug, uid, moderation, language, translations, tags,
+// embedding and
+// compressed or encrypted content are gob-encoded into the details column. User fields added later live
+// gob-encoded in user_details, and workspaces whole in workspaces, so
+// older databases need no column changes.
+type sqlStore struct {
+	db       *sql.DB
+	postgres bool // $n placeholders and Postgres column types
+	content  ContentOptions
+}
+
+// Article fields kept in the details column
+type articleDetails struct {
+	Categories   []string
+	Attachments  []model.Attachment
+	CoverImage   *model.CoverImage
+	Slug         string
+	UID          string
+	Workspace    string
+	Content      []byte // gzip-compressed or encrypted content, in place of the content column
+	Moderation   *model.Moderation
+	Confidential bool
+	KeyID        string // data key Content is encrypted with, if any
+	Language     string
+	Translations map[string]model.Translation // content encoded as Content is
+	Tags         []string
+	Embedding    *model.Embedding
+}
+
+// User fields kept in the user_details table
+type userDetails struct {
+	Email         string
+	Notifications map[string]bool
+	SavedSearches []model.SavedSearch
+
+	Preferences      map[string][]string
+	NotifyWebhookURL string
+	NotifyWebhookKey string
+
+	Digest     string
+	DigestSent time.Time
+
+	PushSubscriptions []model.PushSubscription
+}
+
+const articleColumns = "id, title, description, content, status, created, updated, published, source_url, pinned, featured, featured_order, details"
+
+func openSQLStore(driver, dsn string, postgres bool) (*sqlStore, error) {
+	if !slices.Contains(sql.Drivers(), driver) {
+		return nil, fmt.Errorf("this binary was built without the %s driver (build with -tags %s)", driver, strings.TrimSuffix(driver, "3"))
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	s := &sqlStore{db: db, postgres: postgres}
+	if err := s.createTables(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *sqlStore) createTables() error {
+	colTime, colBlob := "DATETIME", "BLOB"
+	if s.postgres {
+		colTime, colBlob = "TIMESTAMPTZ", "BYTEA"
+	}
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS articles (
+			id INTEGER PRIMARY KEY,
+			title TEXT NOT NULL,
+			description TEXT NOT NULL,
+			content TEXT NOT NULL,
+			status TEXT NOT NULL,
+			created ` + colTime + ` NOT NULL,
+			updated ` + colTime + ` NOT NULL,
+			published ` + colTime + `,
+			source_url TEXT NOT NULL,
+			pinned BOOLEAN NOT NULL,
+			featured BOOLEAN NOT NULL,
+			featured_order INTEGER NOT NULL,
+			details ` + colBlob + `
+		)`,
+		`CREATE TABLE IF NOT EXISTS users (
+			username TEXT PRIMARY KEY,
+			role TEXT NOT NULL,
+			password_hash TEXT NOT NULL,
+			created ` + colTime + ` NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS user_details (username TEXT PRIMARY KEY, details ` + colBlob + ` NOT NULL)`,
+		`CREATE TABLE IF NOT EXISTS workspaces (slug TEXT PRIMARY KEY, details ` + colBlob + ` NOT NULL)`,
+		`CREATE TABLE IF NOT EXISTS meta (name TEXT PRIMARY KEY, value TEXT NOT NULL)`,
+	}
+	for _, stmt := range statements {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rewrite ? placeholders as $1, $2... for Postgres
+func (s *sqlStore) query(q string) string {
+	if !s.postgres {
+		return q
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range q {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (s *sqlStore) Load(ctx context.Context) (Database, error) {
+	var db Database
+	meta, err := s.loadMeta(ctx)
+	if err != nil {
+		return db, err
+	}
+	if len(meta) == 0 {
+		return db, os.ErrNotExist
+	}
+	db.NextID, _ = strconv.Atoi(meta["next_id"])
+	db.NextAttachmentID, _ = strconv.Atoi(meta["next_attachment_id"])
+	db.Version, _ = strconv.Atoi(meta["version"])
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+articleColumns+" FROM articles ORDER BY id")
+	if err != nil {
+		return db, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		article, err := s.scanArticle(rows)
+		if err != nil {
+			return db, err
+		}
+		db.Articles = append(db.Articles, article)
+	}
+	if err := rows.Err(); err != nil {
+		return db, err
+	}
+
+	userRows, err := s.db.QueryContext(ctx, "SELECT username, role, password_hash, created FROM users ORDER BY created")
+	if err != nil {
+		return db, err
+	}
+	defer userRows.Close()
+	for userRows.Next() {
+		var u model.User
+		if err := userRows.Scan(&u.Username, &u.Role, &u.PasswordHash, &u.Created); err != nil {
+			return db, err
+		}
+		db.Users = append(db.Users, u)
+	}
+	if err := userRows.Err(); err != nil {
+		return db, err
+	}
+	if err := s.loadUserDetails(ctx, db.Users); err != nil {
+		return db, err
+	}
+	db.Workspaces, err = s.loadWorkspaces(ctx)
+	return db, err
+}
+
+func (s *sqlStore) loadWorkspaces(ctx context.Context) ([]model.Workspace, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT slug, details FROM workspaces ORDER BY slug")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []model.Workspace
+	for rows.Next() {
+		var slug string
+		var details []byte
+		if err := rows.Scan(&slug, &details); err != nil {
+			return nil, err
+		}
+		var ws model.Workspace
+		if err := gob.NewDecoder(bytes.NewReader(details)).Decode(&ws); err != nil {
+			return nil, fmt.Errorf("workspace %s: %w", slug, err)
+		}
+		list = append(list, ws)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqlStore) loadUserDetails(ctx context.Context, users []model.User) error {
+	rows, err := s.db.QueryContext(ctx, "SELECT username, details FROM user_details")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var username string
+		var details []byte
+		if err := rows.Scan(&username, &details); err != nil {
+			return err
+		}
+		var d userDetails
+		if err := gob.NewDecoder(bytes.NewReader(details)).Decode(&d); err != nil {
+			return fmt.Errorf("user %s details: %w", username, err)
+		}
+		if i := slices.IndexFunc(users, func(u model.User) bool { return u.Username == username }); i >= 0 {
+			users[i].Email, users[i].Notifications, users[i].SavedSearches = d.Email, d.Notifications, d.SavedSearches
+			users[i].Preferences, users[i].NotifyWebhookURL, users[i].NotifyWebhookKey = d.Preferences, d.NotifyWebhookURL, d.NotifyWebhookKey
+			users[i].Digest, users[i].DigestSent = d.Digest, d.DigestSent
+			users[i].PushSubscriptions = d.PushSubscriptions
+		}
+	}
+	return rows.Err()
+}
+
+func (s *sqlStore) loadMeta(ctx context.Context) (map[string]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT name, value FROM meta")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	meta := map[string]string{}
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		meta[name] = value
+	}
+	return meta, rows.Err()
+}
+
+func (s *sqlStore) scanArticle(rows *sql.Rows) (model.Article, error) {
+	var a model.Article
+	var published sql.NullTime
+	var details []byte
+	err := rows.Scan(&a.ID, &a.Title, &a.Desc, &a.Content, &a.Status, &a.Created, &a.Updated, &published,
+		&a.SourceURL, &a.Pinned, &a.Featured, &a.FeaturedOrder, &details)
+	if err != nil {
+		return a, err
+	}
+	a.Published = published.Time
+	if len(details) > 0 {
+		var d articleDetails
+		if err := gob.NewDecoder(bytes.NewReader(details)).Decode(&d); err != nil {
+			return a, fmt.Errorf("article %d details: %w", a.ID, err)
+		}
+		a.Categories, a.Attachments, a.CoverImage, a.Slug, a.UID, a.Workspace = d.Categories, d.Attachments, d.CoverImage, d.Slug, d.UID, d.Workspace
+		a.Moderation, a.Confidential = d.Moderation, d.Confidential
+		a.Language, a.Translations, a.Tags, a.Embedding = d.Language, d.Translations, d.Tags, d.Embedding
+		if len(d.Content) > 0 {
+			if a.Content, err = s.content.decodeContent(d.Content, d.KeyID); err != nil {
+				return a, fmt.Errorf("article %d content: %w", a.ID, err)
+			}
+		}
+		if err := s.content.decodeTranslations(a.Translations); err != nil {
+			return a, fmt.Errorf("article %d: %w", a.ID, err)
+		}
+	}
+	return a, nil
+}
+
+func (s *sqlStore) Save(ctx context.Context, db Database) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM articles"); err != nil {
+		return err
+	}
+	if err := s.putArticles(ctx, tx, db.Articles); err != nil {
+		return err
+	}
+	if err := s.saveMeta(ctx, tx, db); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) PutArticles(ctx context.Context, batch []model.Article) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := s.putArticles(ctx, tx, batch); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) putArticles(ctx context.Context, tx *sql.Tx, batch []model.Article) error {
+	stmt, err := tx.PrepareContext(ctx, s.query("INSERT INTO articles ("+articleColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			title = excluded.title, description = excluded.description, content = excluded.content,
+			status = excluded.status, created = excluded.created, updated = excluded.updated,
+			published = excluded.published, source_url = excluded.source_url, pinned = excluded.pinned,
+			featured = excluded.featured, featured_order = excluded.featured_order, details = excluded.details`))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, a := range batch {
+		var details bytes.Buffer
+		d := articleDetails{a.Categories, a.Attachments, a.CoverImage, a.Slug, a.UID, a.Workspace, nil, a.Moderation, a.Confidential, "", a.Language, nil, a.Tags, a.Embedding}
+		content := a.Content
+		data, keyID, ok, err := s.content.encodeContent(a)
+		if err != nil {
+			return fmt.Errorf("article %d content: %w", a.ID, err)
+		}
+		if d.Translations, err = s.content.encodeTranslations(a); err != nil {
+			return fmt.Errorf("article %d: %w", a.ID, err)
+		}
+		if ok {
+			d.Content, d.KeyID, content = data, keyID, ""
+		}
+		if err := gob.NewEncoder(&details).Encode(d); err != nil {
+			return err
+		}
+		published := sql.NullTime{Time: a.Published, Valid: !a.Published.IsZero()}
+		_, err = stmt.ExecContext(ctx, a.ID, a.Title, a.Desc, content, a.Status, a.Created, a.Updated, published,
+			a.SourceURL, a.Pinned, a.Featured, a.FeaturedOrder, details.Bytes())
+		if err != nil {
+			return fmt.Errorf("article %d: %w", a.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *sqlStore) LastArticleID(ctx context.Context) (int, error) {
+	var id sql.NullInt64
+	err := s.db.QueryRowContext(ctx, "SELECT MAX(id) FROM articles").Scan(&id)
+	return int(id.Int64), err
+}
+
+func (s *sqlStore) SaveMeta(ctx context.Context, db Database) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := s.saveMeta(ctx, tx, db); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) saveMeta(ctx context.Context, tx *sql.Tx, db Database) error {
+	for _, table := range []string{"users", "user_details", "workspaces"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+			return err
+		}
+	}
+	for _, u := range db.Users {
+		_, err := tx.ExecContext(ctx, s.query("INSERT INTO users (username, role, password_hash, created) VALUES (?, ?, ?, ?)"),
+			u.Username, u.Role, u.PasswordHash, u.Created)
+		if err != nil {
+			return err
+		}
+		d := userDetails{u.Email, u.Notifications, u.SavedSearches, u.Preferences, u.NotifyWebhookURL, u.NotifyWebhookKey, u.Digest, u.DigestSent, u.PushSubscriptions}
+		if d.Email == "" && len(d.Notifications) == 0 && len(d.SavedSearches) == 0 && len(d.Preferences) == 0 && d.NotifyWebhookURL == "" &&
+			d.Digest == "" && len(d.PushSubscriptions) == 0 {
+			continue
+		}
+		var details bytes.Buffer
+		if err := gob.NewEncoder(&details).Encode(d); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, s.query("INSERT INTO user_details (username, details) VALUES (?, ?)"), u.Username, details.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	for _, ws := range db.Workspaces {
+		var details bytes.Buffer
+		if err := gob.NewEncoder(&details).Encode(ws); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, s.query("INSERT INTO workspaces (slug, details) VALUES (?, ?)"), ws.Slug, details.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	meta := map[string]int{"next_id": db.NextID, "next_attachment_id": db.NextAttachmentID, "version": db.Version}
+	for name, value := range meta {
+		_, err := tx.ExecContext(ctx, s.query("INSERT INTO meta (name, value) VALUES (?, ?) ON CONFLICT (name) DO UPDATE SET value = excluded.value"),
+			name, strconv.Itoa(value))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlStore) Close() error { return s.db.Close() }
+
+// Normalize times the way SQL stores keep them (UTC, microseconds), so
+// copies can be compared with the original
+func normalizedArticle(a model.Article) model.Article {
+	norm := func(t time.Time) time.Time {
+		if t.IsZero() {
+			return time.Time{}
+		}
+		return t.UTC().Truncate(time.Microsecond)
+	}
+	a.Created, a.Updated, a.Published = norm(a.Created), norm(a.Updated), norm(a.Published)
+	if len(a.Attachments) > 0 {
+		a.Attachments = slices.Clone(a.Attachments)
+		for i := range a.Attachments {
+			a.Attachments[i].Created = norm(a.Attachments[i].Created)
+		}
+	}
+	if len(a.Translations) > 0 {
+		a.Translations = maps.Clone(a.Translations)
+		for lang, t := range a.Translations {
+			t.Updated = norm(t.Updated)
+			a.Translations[lang] = t
+		}
+	}
+	return a
+}
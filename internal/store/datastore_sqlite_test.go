@@ -0,0 +1,87 @@
+//go:build sqlite
+
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go-spring/internal/model"
+)
+
+// go test -tags sqlite ./internal/store (needs cgo)
+func TestSQLStoreRoundTrip(t *testing.T) {
+	s, err := Open("sqlite:" + filepath.Join(t.TempDir(), "articles.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Load(t.Context()); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Load of an empty database: %v, want os.ErrNotExist", err)
+	}
+
+	// The fields of the details column and user_details, and times in
+	// another zone
+	db := testDatabase(3)
+	helsinki := time.FixedZone("EET", 2*60*60)
+	db.Articles[0].Created = time.Date(2025, 3, 1, 14, 0, 0, 123456789, helsinki)
+	db.Articles[0].Published = time.Time{}
+	db.Articles[0].Status = model.StatusDraft
+	db.Articles[0].Slug, db.Articles[0].Workspace, db.Articles[0].Language = "article", "news", "fi"
+	db.Articles[0].Tags = []string{"go", "sql"}
+	db.Articles[0].Attachments = []model.Attachment{{ID: 1, Filename: "a.png", ContentType: "image/png", Size: 3, Key: "attachments/1", Variants: []string{"thumbs/1/400x300"}, Created: db.Articles[0].Created}}
+	db.Articles[0].CoverImage = &model.CoverImage{AttachmentID: 1, URL: "/attachments/1"}
+	db.Articles[1].Content = strings.Repeat("Long enough to be compressed. ", 100)
+	db.Articles[1].Translations = map[string]model.Translation{"fi": {Title: "Artikkeli", Content: "Sisältö"}}
+	db.Articles[2].Pinned, db.Articles[2].Featured, db.Articles[2].FeaturedOrder = true, true, 1
+	db.NextAttachmentID = 2
+	db.Users[0].Email = "alice@example.com"
+	if err := s.Save(t.Context(), db); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := s.Load(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, a := range db.Articles {
+		if got, want := normalizedArticle(loaded.Articles[i]), normalizedArticle(a); !reflect.DeepEqual(got, want) {
+			t.Errorf("article %d:\n got %+v\nwant %+v", a.ID, got, want)
+		}
+	}
+	if loaded.NextID != 4 || loaded.NextAttachmentID != 2 || loaded.Version != len(Migrations) {
+		t.Errorf("counters %d, %d, version %d", loaded.NextID, loaded.NextAttachmentID, loaded.Version)
+	}
+	if len(loaded.Users) != 1 || loaded.Users[0].Email != "alice@example.com" || !loaded.Users[0].Created.Equal(db.Users[0].Created) {
+		t.Errorf("users %+v", loaded.Users)
+	}
+	if !reflect.DeepEqual(loaded.Workspaces, db.Workspaces) {
+		t.Errorf("workspaces %+v", loaded.Workspaces)
+	}
+
+	// Articles are put one batch at a time, replacing those with the same ID
+	db.Articles[2].Title = "Changed"
+	extra := db.Articles[2]
+	extra.ID = 9
+	if err := s.PutArticles(t.Context(), []model.Article{db.Articles[2], extra}); err != nil {
+		t.Fatal(err)
+	}
+	if last, err := s.LastArticleID(t.Context()); err != nil || last != 9 {
+		t.Errorf("LastArticleID = %d, %v; want 9", last, err)
+	}
+	if loaded, _ = s.Load(t.Context()); len(loaded.Articles) != 4 || loaded.Articles[2].Title != "Changed" {
+		t.Errorf("after PutArticles: %d articles, third %q", len(loaded.Articles), loaded.Articles[2].Title)
+	}
+
+	// Saving replaces everything
+	if err := s.Save(t.Context(), testDatabase(1)); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, _ = s.Load(t.Context()); len(loaded.Articles) != 1 || loaded.NextID != 2 {
+		t.Errorf("after Save: %d articles, next ID %d", len(loaded.Articles), loaded.NextID)
+	}
+}
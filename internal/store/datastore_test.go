@@ -0,0 +1,329 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-spring/internal/keyring"
+	"go-spring/internal/model"
+)
+
+func testDatabase(n int) Database {
+	db := Database{NextID: n + 1, NextAttachmentID: 1, Version: len(Migrations)}
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := range n {
+		db.Articles = append(db.Articles, model.Article{
+			ID:         i + 1,
+			Title:      "Article",
+			Desc:       "Description",
+			Content:    "Content",
+			Created:    created,
+			Updated:    created,
+			Status:     model.StatusPublished,
+			Published:  created,
+			Categories: []string{"News"},
+		})
+	}
+	db.Users = []model.User{{Username: "alice", Role: model.RoleAdmin, PasswordHash: "x", Created: created}}
+	db.Workspaces = []model.Workspace{{Slug: "news", Name: "News", Created: created, Members: map[string]string{"alice": model.WorkspaceOwner}}}
+	return db
+}
+
+func TestGobStoreRoundTrip(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "articles.gob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load(t.Context()); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Load of a missing file: %v, want os.ErrNotExist", err)
+	}
+
+	db := testDatabase(3)
+	if err := s.Save(t.Context(), db); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := s.Load(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Articles) != 3 || loaded.NextID != 4 || len(loaded.Users) != 1 || loaded.Articles[2].Categories[0] != "News" {
+		t.Errorf("loaded %+v", loaded)
+	}
+	if last, err := s.LastArticleID(t.Context()); err != nil || last != 3 {
+		t.Errorf("LastArticleID = %d, %v; want 3", last, err)
+	}
+}
+
+func TestContentCompression(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "articles.gob")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	db := testDatabase(20)
+	long := strings.Repeat("Markdown paragraph with *emphasis*.\n", 200)
+	for i := range db.Articles {
+		db.Articles[i].Content = long
+	}
+
+	SetContentOptions(s, ContentOptions{Compression: CompressionOff})
+	if err := s.Save(t.Context(), db); err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := Size(t.Context(), s)
+	SetContentOptions(s, ContentOptions{Compression: CompressionGzip})
+	if err := Compact(t.Context(), s); err != nil {
+		t.Fatal(err)
+	}
+	compressed, sized := Size(t.Context(), s)
+	if !sized || compressed*5 > plain {
+		t.Errorf("compacted file is %d bytes, uncompressed %d", compressed, plain)
+	}
+	if db.Articles[0].Content != long {
+		t.Error("Save compressed the caller's articles")
+	}
+
+	loaded, err := s.Load(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range loaded.Articles {
+		if a.Content != long {
+			t.Fatalf("article %d content not expanded: %.20q", a.ID, a.Content)
+		}
+	}
+	// Short content stays as it is
+	if c, ok := compressContent("Content", CompressionGzip); ok {
+		t.Errorf("short content compressed to %q", c)
+	}
+}
+
+func TestContentEncryption(t *testing.T) {
+	key := func(id string, fill byte) keyring.Key {
+		return keyring.Key{ID: id, Key: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{fill}, keyring.KeySize))}
+	}
+	path := filepath.Join(t.TempDir(), "articles.gob")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	useKeys := func(keys ...keyring.Key) {
+		ring, err := keyring.New(keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		SetContentOptions(s, ContentOptions{Cipher: ring})
+	}
+	db := testDatabase(3)
+	long := strings.Repeat("Secret paragraph. ", 100)
+	db.Articles[0].Content, db.Articles[0].Confidential = long, true
+	db.Articles[1].Content, db.Articles[1].Confidential = "Secret note", true
+	db.Articles[1].Translations = map[string]model.Translation{"fi": {Title: "Salaisuus", Content: "Secret muistiinpano"}}
+
+	if err := s.Save(t.Context(), db); !errors.Is(err, ErrNoCipher) {
+		t.Fatalf("Save without keys: %v, want ErrNoCipher", err)
+	}
+	useKeys(key("k1", 1))
+	if err := s.Save(t.Context(), db); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := os.ReadFile(path); bytes.Contains(raw, []byte("Secret")) {
+		t.Error("confidential content stored as text")
+	}
+
+	// A rotated keyring reads the old data and compacting moves it to the
+	// new key, after which the old one can go
+	useKeys(key("k2", 2), key("k1", 1))
+	if err := Compact(t.Context(), s); err != nil {
+		t.Fatal(err)
+	}
+	useKeys(key("k2", 2))
+	loaded, err := s.Load(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := loaded.Articles[0]; a.Content != long || !a.Confidential || a.ContentKeyID != "" {
+		t.Errorf("article 1: confidential %v, key %q, content %.20q", a.Confidential, a.ContentKeyID, a.Content)
+	}
+	if a := loaded.Articles[1]; a.Content != "Secret note" || a.Translations["fi"].Content != "Secret muistiinpano" || a.Translations["fi"].ContentKeyID != "" {
+		t.Errorf("article 2 content %q, translations %+v", a.Content, a.Translations)
+	}
+	if a := loaded.Articles[2]; a.Content != "Content" || a.Confidential {
+		t.Errorf("article 3: confidential %v, content %q", a.Confidential, a.Content)
+	}
+
+	useKeys(key("k1", 1))
+	if _, err := s.Load(t.Context()); !errors.Is(err, keyring.ErrUnknownKey) {
+		t.Errorf("Load without the key: %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestGobStoreLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "articles.gob")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(t.Context(), testDatabase(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); !errors.Is(err, ErrLocked) {
+		t.Fatalf("second Open: %v, want ErrLocked", err)
+	}
+
+	// Readers don't need the lock, and can't save
+	r, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db, err := r.Load(t.Context()); err != nil || len(db.Articles) != 1 {
+		t.Errorf("read-only Load: %v, %d articles", err, len(db.Articles))
+	}
+	if err := r.Save(t.Context(), testDatabase(2)); !errors.Is(err, ErrReadOnly) || !IsReadOnly(r) {
+		t.Errorf("read-only Save: %v, want ErrReadOnly", err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open after Close: %v", err)
+	}
+	s.Close()
+}
+
+func TestCanceledOperations(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(filepath.Join(dir, "articles.gob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Save(t.Context(), testDatabase(1)); err != nil {
+		t.Fatal(err)
+	}
+	blobs := NewLocalBlobStore(dir)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if err := s.Save(ctx, testDatabase(2)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Save: %v, want context.Canceled", err)
+	}
+	if db, err := s.Load(t.Context()); err != nil || len(db.Articles) != 1 {
+		t.Errorf("after a canceled Save: %v, %d articles, want 1", err, len(db.Articles))
+	}
+	if err := blobs.Put(ctx, "a", strings.NewReader("data")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Put: %v, want context.Canceled", err)
+	}
+	if _, err := blobs.Get(t.Context(), "a"); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("Get after a canceled Put: %v, want ErrBlobNotFound", err)
+	}
+}
+
+func TestGetRange(t *testing.T) {
+	local := NewLocalBlobStore(t.TempDir())
+	if err := local.Put(t.Context(), "a", strings.NewReader("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	// The local store seeks; without GetRange the start is skipped
+	for _, bs := range []BlobStore{local, struct{ BlobStore }{local}} {
+		blob, err := GetRange(t.Context(), bs, "a", 3, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(blob)
+		blob.Close()
+		if string(data) != "3456" {
+			t.Errorf("%T: range %q, want 3456", bs, data)
+		}
+	}
+	if _, err := GetRange(t.Context(), local, "b", 0, 1); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("missing blob: %v, want ErrBlobNotFound", err)
+	}
+}
+
+func TestSequence(t *testing.T) {
+	var seq Sequence
+	if id := seq.Next(); id != 1 {
+		t.Fatalf("first ID %d, want 1", id)
+	}
+	seq.Advance(10)
+	seq.Advance(5) // a stale next ID
+	if id := seq.Next(); id != 10 || seq.Peek() != 11 {
+		t.Errorf("after Advance: ID %d, next %d, want 10 and 11", id, seq.Peek())
+	}
+
+	seq.Reset(1)
+	ids := make(chan int, 1000)
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			for range 100 {
+				ids <- seq.Next()
+			}
+		})
+	}
+	wg.Wait()
+	close(ids)
+	seen := map[int]bool{}
+	for id := range ids {
+		if seen[id] || id < 1 || id > 1000 {
+			t.Fatalf("ID %d handed out twice or out of range", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestMigrateSetsMissingStatus(t *testing.T) {
+	db := testDatabase(2)
+	db.Version = 0
+	db.Articles[0].Status = ""
+	db.Articles[0].Published = time.Time{}
+
+	if applied := Migrate(&db); len(applied) != len(Migrations) {
+		t.Errorf("applied %v, want all %d migrations", applied, len(Migrations))
+	}
+	if db.Articles[0].Status != model.StatusPublished || db.Version != len(Migrations) {
+		t.Errorf("after migration: status %q, version %d", db.Articles[0].Status, db.Version)
+	}
+	if applied := Migrate(&db); len(applied) != 0 {
+		t.Errorf("second Migrate applied %v", applied)
+	}
+}
+
+func TestCopyResumesAndVerifies(t *testing.T) {
+	dir := t.TempDir()
+	src, _ := Open(filepath.Join(dir, "src.gob"))
+	dst, _ := Open(filepath.Join(dir, "dst.gob"))
+	db := testDatabase(7)
+	if err := src.Save(t.Context(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	// An interrupted copy left the first three articles behind
+	if err := dst.PutArticles(t.Context(), db.Articles[:3]); err != nil {
+		t.Fatal(err)
+	}
+	if err := Copy(t.Context(), src, dst, 2, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	copied, err := dst.Load(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(copied.Articles) != 7 || copied.NextID != 8 || len(copied.Users) != 1 || copied.Workspaces[0].Members["alice"] != model.WorkspaceOwner {
+		t.Errorf("copied %d articles, next ID %d, %d users", len(copied.Articles), copied.NextID, len(copied.Users))
+	}
+}
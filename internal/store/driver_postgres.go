@@ -0,0 +1,9 @@
+//go:build postgres
+
+package store
+
+// Postgres support for STORE=postgres://... The driver, github.com/lib/pq,
+// is not in go.mod, so that builds without the tag need no network: run
+// `go get github.com/lib/pq` before `go build -tags postgres`. Without the
+// tag a postgres:// store is refused with a message naming it.
+import _ "github.com/lib/pq"
@@ -0,0 +1,6 @@
+//go:build sqlite
+
+package store
+
+// SQLite support for STORE=sqlite:path (needs cgo)
+import _ "github.com/mattn/go-sqlite3"
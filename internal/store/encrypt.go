@@ -0,0 +1,122 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+
+	"go-spring/internal/model"
+)
+
+// The content of a confidential article is encrypted before it is written,
+// after compression, and the ID of the data key is stored with it: in a
+// .gob file as the article's ContentKeyID, in SQL stores in the details
+// column next to the encrypted bytes, with the content column left empty.
+// Reads decrypt it with that key, so nothing above the store sees the
+// difference. Translations of the article are written the same way, each
+// with the ID of its own key. Keys are rotated by making a new one primary: data stays
+// under the key it was written with until it is saved again, or the
+// compact command rewrites it.
+
+// Cipher encrypts content under data keys, see the keyring package
+type Cipher interface {
+	// Encrypt with the current key, returning its ID
+	Encrypt(plaintext []byte) (keyID string, ciphertext []byte, err error)
+	Decrypt(keyID string, ciphertext []byte) ([]byte, error)
+}
+
+// ContentOptions say how a store writes article content. Each store has
+// its own, set with SetContentOptions before anything is saved; the zero
+// value compresses and has no keys.
+type ContentOptions struct {
+	Compression string // CompressionGzip (the default) or CompressionOff, from STORE_COMPRESSION
+	Cipher      Cipher // encrypts confidential content; nil when no keys are configured
+}
+
+// SetContentOptions sets how st writes content from now on, and the keys it
+// reads encrypted content with. Stores that don't encode content, such as
+// those of tests, ignore them.
+func SetContentOptions(st DataStore, opts ContentOptions) {
+	switch st := st.(type) {
+	case *gobStore:
+		st.content = opts
+	case *sqlStore:
+		st.content = opts
+	}
+}
+
+// ErrNoCipher is returned for confidential content when the store has no
+// Cipher
+var ErrNoCipher = errors.New("store: confidential content needs encryption keys")
+
+// The content of a as written: compressed when that saves space and
+// encrypted if a is confidential, with the key's ID. ok is false to keep
+// the text.
+func (o ContentOptions) encodeContent(a model.Article) (data []byte, keyID string, ok bool, err error) {
+	data, ok = compressContent(a.Content, o.Compression)
+	if !a.Confidential {
+		return data, "", ok, nil
+	}
+	if o.Cipher == nil {
+		return nil, "", false, ErrNoCipher
+	}
+	if !ok {
+		data = []byte(a.Content)
+	}
+	keyID, data, err = o.Cipher.Encrypt(data)
+	return data, keyID, err == nil, err
+}
+
+// The text of content written by encodeContent; keyID is empty for content
+// that was only compressed
+func (o ContentOptions) decodeContent(data []byte, keyID string) (string, error) {
+	if keyID != "" {
+		if o.Cipher == nil {
+			return "", ErrNoCipher
+		}
+		var err error
+		if data, err = o.Cipher.Decrypt(keyID, data); err != nil {
+			return "", err
+		}
+	}
+	if isCompressed(string(data)) {
+		return expandContent(data)
+	}
+	return string(data), nil
+}
+
+// A copy of the article's translations with their content as written by
+// encodeContent, or the translations themselves if there are none
+func (o ContentOptions) encodeTranslations(a model.Article) (map[string]model.Translation, error) {
+	if len(a.Translations) == 0 {
+		return a.Translations, nil
+	}
+	translations := maps.Clone(a.Translations)
+	for lang, t := range translations {
+		data, keyID, ok, err := o.encodeContent(model.Article{Content: t.Content, Confidential: a.Confidential})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			t.Content, t.ContentKeyID = string(data), keyID
+			translations[lang] = t
+		}
+	}
+	return translations, nil
+}
+
+// Decode the translations written by encodeTranslations in place
+func (o ContentOptions) decodeTranslations(translations map[string]model.Translation) error {
+	for lang, t := range translations {
+		if t.ContentKeyID == "" && !isCompressed(t.Content) {
+			continue
+		}
+		var err error
+		if t.Content, err = o.decodeContent([]byte(t.Content), t.ContentKeyID); err != nil {
+			return fmt.Errorf("%s translation: %w", lang, err)
+		}
+		t.ContentKeyID = ""
+		translations[lang] = t
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package store
+
+import "os"
+
+// No file locking on this platform
+func lockFile(path string) (*os.File, error) {
+	return nil, nil
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+	"os"
+)
+
+// Vacuum rebuilds the indexes of a SQL database and gives the space of
+// deleted rows back, which every save of the whole data leaves behind. A
+// .gob file is rewritten whole on every save, so there is nothing to do.
+func Vacuum(ctx context.Context, st DataStore) error {
+	s, ok := st.(*sqlStore)
+	if !ok {
+		return nil
+	}
+	reindex := "REINDEX"
+	if s.postgres {
+		reindex = "REINDEX TABLE articles" // REINDEX alone needs a database or schema
+	}
+	if _, err := s.db.ExecContext(ctx, reindex); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, "VACUUM")
+	return err
+}
+
+// Size is the space st takes: its file, or the whole Postgres database. ok
+// is false for stores of other kinds.
+func Size(ctx context.Context, st DataStore) (size int64, ok bool) {
+	switch s := st.(type) {
+	case *gobStore:
+		info, err := os.Stat(s.path)
+		if err != nil {
+			return 0, false
+		}
+		return info.Size(), true
+	case *sqlStore:
+		query := "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()"
+		if s.postgres {
+			query = "SELECT pg_database_size(current_database())"
+		}
+		if err := s.db.QueryRowContext(ctx, query).Scan(&size); err != nil {
+			return 0, false
+		}
+		return size, true
+	}
+	return 0, false
+}
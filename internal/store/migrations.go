@@ -0,0 +1,34 @@
+package store
+
+import "go-spring/internal/model"
+
+// Data file migrations, applied in order when the file is loaded.
+// Database.Version counts the ones already applied; files written before
+// versioning are version 0. Only ever append to this list.
+var Migrations = []struct {
+	Name  string
+	Apply func(*Database)
+}{
+	{"give articles without a status the published status", func(db *Database) {
+		for i := range db.Articles {
+			if article := &db.Articles[i]; article.Status == "" {
+				article.Status = model.StatusPublished
+				if article.Published.IsZero() {
+					article.Published = article.Created
+				}
+			}
+		}
+	}},
+}
+
+// Apply pending migrations, returning the names of those that ran
+func Migrate(db *Database) []string {
+	var applied []string
+	for db.Version < len(Migrations) {
+		m := Migrations[db.Version]
+		m.Apply(db)
+		applied = append(applied, m.Name)
+		db.Version++
+	}
+	return applied
+}
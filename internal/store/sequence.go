@@ -0,0 +1,37 @@
+package store
+
+import "sync/atomic"
+
+// Sequence hands out increasing IDs without a lock. It never goes back:
+// Advance only moves it forward, so an ID is never handed out twice, even
+// when a stale save or replicated state reports a lower next ID. The zero
+// value starts at 1.
+type Sequence struct {
+	last atomic.Int64
+}
+
+// Next returns a new ID
+func (s *Sequence) Next() int {
+	return int(s.last.Add(1))
+}
+
+// Peek returns the ID Next hands out next, as kept in Database.NextID.
+// IDs below it may still be on their way into the store.
+func (s *Sequence) Peek() int {
+	return int(s.last.Load()) + 1
+}
+
+// Advance makes sure Next returns next or more
+func (s *Sequence) Advance(next int) {
+	for {
+		last := s.last.Load()
+		if last >= int64(next)-1 || s.last.CompareAndSwap(last, int64(next)-1) {
+			return
+		}
+	}
+}
+
+// Reset starts over at next (at least 1), for data loaded from scratch
+func (s *Sequence) Reset(next int) {
+	s.last.Store(int64(max(next, 1)) - 1)
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
+	"io"
+
+	"go-spring/internal/model"
+)
+
+// Copy everything from src to dst in batches of articles, reporting progress
+// to out. Articles are copied in ID order, so an interrupted copy resumes
+// after the highest ID already in dst. Counters, users and workspaces are
+// written last, then every article is read back from dst and compared with
+// the source.
+func Copy(ctx context.Context, src, dst DataStore, batchSize int, out io.Writer) error {
+	db, err := src.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("read source: %w", err)
+	}
+	Migrate(&db)
+
+	last, err := dst.LastArticleID(ctx)
+	if err != nil {
+		return fmt.Errorf("read target: %w", err)
+	}
+	remaining := db.Articles
+	for len(remaining) > 0 && remaining[0].ID <= last {
+		remaining = remaining[1:]
+	}
+	total, done := len(db.Articles), len(db.Articles)-len(remaining)
+	if done > 0 {
+		fmt.Fprintf(out, "Resuming after article %d (%d of %d already copied)\n", last, done, total)
+	}
+
+	for len(remaining) > 0 {
+		batch := remaining[:min(batchSize, len(remaining))]
+		if err := dst.PutArticles(ctx, batch); err != nil {
+			return fmt.Errorf("write articles: %w", err)
+		}
+		remaining = remaining[len(batch):]
+		done += len(batch)
+		fmt.Fprintf(out, "Copied %d/%d articles (%d%%)\n", done, total, done*100/max(total, 1))
+	}
+	if err := dst.SaveMeta(ctx, db); err != nil {
+		return fmt.Errorf("write counters and users: %w", err)
+	}
+
+	fmt.Fprintln(out, "Verifying...")
+	return verifyDataStore(ctx, db, dst)
+}
+
+// Check that dst holds exactly the articles, counters and users of db
+func verifyDataStore(ctx context.Context, db Database, dst DataStore) error {
+	copied, err := dst.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("read back target: %w", err)
+	}
+	if len(copied.Articles) != len(db.Articles) {
+		return fmt.Errorf("verification failed: target has %d articles, source %d", len(copied.Articles), len(db.Articles))
+	}
+	for i, article := range db.Articles {
+		if articleChecksum(article) != articleChecksum(copied.Articles[i]) {
+			return fmt.Errorf("verification failed: article %d differs", article.ID)
+		}
+	}
+	if copied.NextID != db.NextID || copied.NextAttachmentID != db.NextAttachmentID || len(copied.Users) != len(db.Users) ||
+		len(copied.Workspaces) != len(db.Workspaces) {
+		return fmt.Errorf("verification failed: counters, users or workspaces differ")
+	}
+	return nil
+}
+
+func articleChecksum(a model.Article) [sha256.Size]byte {
+	var buf bytes.Buffer
+	gob.NewEncoder(&buf).Encode(normalizedArticle(a))
+	return sha256.Sum256(buf.Bytes())
+}
@@ -0,0 +1,115 @@
+// Package summarize writes the descriptions of articles from their
+// content. A Summarizer is usually a large language model behind an API;
+// ChatCompletions speaks the OpenAI chat completions protocol, which most
+// hosted and self-hosted model servers offer, and any other can be plugged
+// in through the interface.
+package summarize
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Request is what is summarized
+type Request struct {
+	Title     string
+	Content   string // Markdown
+	Language  string // tag of the language to write in, empty if unknown
+	MaxLength int    // in characters
+}
+
+// Summarizer writes a summary of an article
+type Summarizer interface {
+	Summarize(ctx context.Context, req Request) (string, error)
+}
+
+// SummarizerFunc is a Summarizer as a function
+type SummarizerFunc func(ctx context.Context, req Request) (string, error)
+
+func (f SummarizerFunc) Summarize(ctx context.Context, req Request) (string, error) {
+	return f(ctx, req)
+}
+
+// How much of the content is sent, to bound the cost of long articles;
+// their opening says most of what a description needs
+const maxContent = 24000
+
+// ChatCompletions asks an OpenAI-compatible chat completions endpoint,
+// such as https://api.openai.com/v1/chat/completions or a local model
+// server's /v1/chat/completions
+type ChatCompletions struct {
+	Endpoint string
+	Model    string
+	APIKey   string // sent as a bearer token, if set
+	Client   *http.Client
+}
+
+func (c ChatCompletions) Summarize(ctx context.Context, req Request) (string, error) {
+	instructions := fmt.Sprintf("Summarize the article you are given in one or two sentences, at most %d characters, "+
+		"as the description shown under its title in listings. Write plain text without Markdown, quotes or a preamble.", req.MaxLength)
+	if req.Language != "" {
+		instructions += " Write in the language with the tag " + req.Language + "."
+	} else {
+		instructions += " Write in the language of the article."
+	}
+	content := req.Content
+	if len(content) > maxContent {
+		content = strings.ToValidUTF8(content[:maxContent], "")
+	}
+	body, err := json.Marshal(map[string]any{
+		"model": c.Model,
+		"messages": []map[string]string{
+			{"role": "system", "content": instructions},
+			{"role": "user", "content": "# " + req.Title + "\n\n" + content},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	client := c.Client
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("summarizer: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("summarizer: %w", err)
+	}
+	var out struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.Unmarshal(data, &out)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("summarizer: %s: %s", resp.Status, out.Error.Message)
+	}
+	if len(out.Choices) == 0 || strings.TrimSpace(out.Choices[0].Message.Content) == "" {
+		return "", errors.New("summarizer: empty answer")
+	}
+	return strings.TrimSpace(out.Choices[0].Message.Content), nil
+}
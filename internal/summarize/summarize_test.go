@@ -0,0 +1,47 @@
+package summarize
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChatCompletions(t *testing.T) {
+	var got struct {
+		Model    string
+		Messages []struct{ Role, Content string }
+	}
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+		if strings.Contains(got.Messages[1].Content, "fail") {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"message": "rate limited"}}`))
+			return
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "  A short summary.\n"}}]}`))
+	}))
+	defer srv.Close()
+
+	c := ChatCompletions{Endpoint: srv.URL, Model: "small", APIKey: "secret"}
+	summary, err := c.Summarize(t.Context(), Request{Title: "Title", Content: "Long text", Language: "fi", MaxLength: 300})
+	if err != nil || summary != "A short summary." {
+		t.Fatalf("summary %q, %v", summary, err)
+	}
+	if auth != "Bearer secret" || got.Model != "small" || len(got.Messages) != 2 {
+		t.Errorf("request: auth %q, %+v", auth, got)
+	}
+	if system := got.Messages[0].Content; !strings.Contains(system, "300 characters") || !strings.Contains(system, "tag fi") {
+		t.Errorf("instructions %q", system)
+	}
+	if got.Messages[1].Content != "# Title\n\nLong text" {
+		t.Errorf("article %q", got.Messages[1].Content)
+	}
+
+	if _, err := c.Summarize(t.Context(), Request{Title: "fail", MaxLength: 300}); err == nil || !strings.Contains(err.Error(), "rate limited") {
+		t.Errorf("error answer: %v", err)
+	}
+}
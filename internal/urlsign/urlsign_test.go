@@ -0,0 +1,40 @@
+package urlsign
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	s := New("k3y")
+	now := time.Unix(1_700_000_000, 0)
+	query := s.Sign("attachment/7", now.Add(time.Hour))
+
+	if err := s.Verify("attachment/7", query, now); err != nil {
+		t.Errorf("valid signature: %v", err)
+	}
+	if err := s.Verify("attachment/7", query, now.Add(time.Hour)); !errors.Is(err, ErrExpired) {
+		t.Errorf("at expiry: %v", err)
+	}
+	if err := s.Verify("attachment/8", query, now); !errors.Is(err, ErrInvalid) {
+		t.Errorf("other resource: %v", err)
+	}
+	if err := New("other").Verify("attachment/7", query, now); !errors.Is(err, ErrInvalid) {
+		t.Errorf("other key: %v", err)
+	}
+	if err := s.Verify("attachment/7", url.Values{}, now); !errors.Is(err, ErrMissing) {
+		t.Errorf("unsigned: %v", err)
+	}
+
+	// Pushing the expiry out breaks the signature
+	query.Set(ExpiresParam, "9999999999")
+	if err := s.Verify("attachment/7", query, now); !errors.Is(err, ErrInvalid) {
+		t.Errorf("changed expiry: %v", err)
+	}
+
+	if err := New("").Verify("attachment/7", New("").Sign("attachment/7", now.Add(time.Hour)), now); !errors.Is(err, ErrInvalid) {
+		t.Error("random keys matched")
+	}
+}
@@ -0,0 +1,192 @@
+// Package validate checks request structs against rules in their validate
+// struct tags, so handlers don't repeat hand-written checks:
+//
+//	type CreateArticleRequest struct {
+//		Title  string `json:"title" validate:"required,max=200"`
+//		Status string `json:"status" validate:"oneof=draft published"`
+//	}
+//
+// Rules are separated by commas:
+//
+//	required     not empty (strings are trimmed first), not nil, not zero
+//	min=N max=N  length of a string (in characters), slice or map, or the value of a number
+//	oneof=a b c  one of the space-separated values
+//	email        a bare email address
+//	url          an absolute http or https URL
+//
+// Every rule but required lets an empty value through. Pointers are checked
+// by what they point to. Fields are reported by their JSON names, with
+// messages rendered from English templates that Localize can translate.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError is one invalid field
+type FieldError struct {
+	Field   string `json:"field"`           // the JSON name
+	Rule    string `json:"rule"`            // e.g. required, max
+	Param   string `json:"param,omitempty"` // e.g. 200 for max=200
+	Message string `json:"message"`         // e.g. "title is required"
+
+	// Template is Message with {field} and {param} placeholders, e.g.
+	// "{field} must be at most {param} characters"; empty if the message
+	// has no template
+	Template string `json:"-"`
+}
+
+// Localize renders the message from the translation of its template, or
+// translates Message if there is no template
+func (fe FieldError) Localize(translate func(string) string) string {
+	if fe.Template == "" {
+		return translate(fe.Message)
+	}
+	return strings.NewReplacer("{field}", fe.Field, "{param}", fe.Param).Replace(translate(fe.Template))
+}
+
+// Errors lists the invalid fields of a struct, in field order
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Struct checks the tagged fields of a struct or pointer to one. It returns
+// nil when they are all valid, and at most one error per field. An unknown
+// rule is a programming error and panics.
+func Struct(v any) Errors {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: %T is not a struct", v))
+	}
+	var errs Errors
+	rt := rv.Type()
+	for i := range rt.NumField() {
+		field := rt.Field(i)
+		tag, ok := field.Tag.Lookup("validate")
+		if !ok || !field.IsExported() {
+			continue
+		}
+		name := jsonName(field)
+		value := rv.Field(i)
+		for _, rule := range strings.Split(tag, ",") {
+			rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+			if template := check(value, rule, param); template != "" {
+				if rule == "oneof" {
+					param = strings.Join(strings.Fields(param), ", ")
+				}
+				fe := FieldError{Field: name, Rule: rule, Param: param, Template: template}
+				fe.Message = fe.Localize(func(s string) string { return s })
+				errs = append(errs, fe)
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// The name a field has in JSON bodies
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// Check one rule, returning the template of what is wrong or ""
+func check(v reflect.Value, rule, param string) string {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			if rule == "required" {
+				return "{field} is required"
+			}
+			return ""
+		}
+		v = v.Elem()
+	}
+	if rule == "required" {
+		if isEmpty(v) {
+			return "{field} is required"
+		}
+		return ""
+	}
+	if isEmpty(v) {
+		return ""
+	}
+
+	switch rule {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			panic(fmt.Sprintf("validate: %s=%q is not a number", rule, param))
+		}
+		size, unit := measure(v)
+		switch {
+		case rule == "min" && size < limit:
+			return "{field} must be at least {param}" + unit
+		case rule == "max" && size > limit:
+			return "{field} must be at most {param}" + unit
+		}
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, option := range strings.Fields(param) {
+			if s == option {
+				return ""
+			}
+		}
+		return "{field} must be one of {param}"
+	case "email":
+		s := v.String()
+		if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+			return "{field} must be an email address"
+		}
+	case "url":
+		u, err := url.Parse(v.String())
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "{field} must be an http or https URL"
+		}
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q", rule))
+	}
+	return ""
+}
+
+// Whether a value counts as not given
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// The size that min and max compare, and its unit for messages
+func measure(v reflect.Value) (float64, string) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), ""
+	}
+	panic(fmt.Sprintf("validate: min and max don't apply to %s", v.Kind()))
+}
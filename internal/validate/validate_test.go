@@ -0,0 +1,69 @@
+package validate
+
+import (
+	"slices"
+	"testing"
+)
+
+type request struct {
+	Title  string   `json:"title" validate:"required,max=5"`
+	Status string   `json:"status,omitempty" validate:"oneof=draft published"`
+	Email  *string  `json:"email" validate:"email"`
+	Link   string   `validate:"url"`
+	Tags   []string `json:"tags" validate:"min=1,max=2"`
+	Count  int      `json:"count" validate:"max=10"`
+	Note   string   `json:"note"`
+}
+
+func TestStruct(t *testing.T) {
+	addr, bad := "bob@example.com", "Bob <bob@example.com>"
+	tests := []struct {
+		name string
+		req  request
+		want []string // field:rule
+	}{
+		{"valid", request{Title: "héllo", Status: "draft", Email: &addr, Link: "https://example.com/x", Tags: []string{"a"}, Count: 10}, nil},
+		{"empty optional fields", request{Title: "t"}, nil},
+		{"blank required", request{Title: "  "}, []string{"title:required"}},
+		{"too long", request{Title: "héllo!", Tags: []string{"a", "b", "c"}, Count: 11}, []string{"title:max", "tags:max", "count:max"}},
+		{"formats", request{Title: "t", Status: "archived", Email: &bad, Link: "ftp://example.com"}, []string{"status:oneof", "email:email", "Link:url"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, fe := range Struct(&tt.req) {
+				got = append(got, fe.Field+":"+fe.Rule)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorMessages(t *testing.T) {
+	errs := Struct(request{Status: "x"})
+	if got, want := errs.Error(), "title is required; status must be one of draft, published"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestUnknownRulePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for an unknown rule")
+		}
+	}()
+	Struct(struct {
+		Name string `validate:"required,shiny"`
+	}{"x"})
+}
+
+func TestLocalize(t *testing.T) {
+	errs := Struct(request{Title: "too long"})
+	fi := map[string]string{"{field} must be at most {param} characters": "{field} saa olla enintään {param} merkkiä"}
+	got := errs[0].Localize(func(s string) string { return fi[s] })
+	if want := "title saa olla enintään 5 merkkiä"; got != want {
+		t.Errorf("Localize = %q, want %q", got, want)
+	}
+}
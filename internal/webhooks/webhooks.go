@@ -0,0 +1,127 @@
+// Package webhooks delivers events to the HTTP endpoints subscribed to
+// them. Every delivery is signed with the subscription's secret, in the
+// scheme of Standard Webhooks: an HMAC-SHA256 of the delivery ID, the time
+// of sending and the body, so that a receiver can tell a delivery is
+// genuine and turn away one that is replayed later.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Headers of a delivery
+const (
+	IDHeader        = "Webhook-Id"
+	TimestampHeader = "Webhook-Timestamp"
+	SignatureHeader = "Webhook-Signature"
+)
+
+// Shortest secret a subscription may have
+const MinSecretLength = 16
+
+// Subscription is an endpoint that wants some events
+type Subscription struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events,omitempty"` // all if empty
+}
+
+// Wants reports whether the subscription is sent event
+func (s Subscription) Wants(event string) bool {
+	return len(s.Events) == 0 || slices.Contains(s.Events, event)
+}
+
+// String hides all of the URL but its host, for logs
+func (s Subscription) String() string {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return "webhook"
+	}
+	return u.Host
+}
+
+// Parse reads a JSON array of subscriptions, checking that each has an
+// http(s) URL, a long enough secret and events that valid accepts
+func Parse(data []byte, valid func(event string) bool) ([]Subscription, error) {
+	var subs []Subscription
+	if err := json.Unmarshal(data, &subs); err != nil {
+		return nil, err
+	}
+	for i, s := range subs {
+		u, err := url.Parse(s.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf("subscription %d: invalid URL", i+1)
+		}
+		if len(s.Secret) < MinSecretLength {
+			return nil, fmt.Errorf("subscription %d (%s): secret must be at least %d characters", i+1, s, MinSecretLength)
+		}
+		for _, event := range s.Events {
+			if !valid(event) {
+				return nil, fmt.Errorf("subscription %d (%s): unknown event %q", i+1, s, event)
+			}
+		}
+	}
+	return subs, nil
+}
+
+// Delivery is the body of a webhook request
+type Delivery struct {
+	ID    string          `json:"id"`
+	Event string          `json:"event"`
+	Time  time.Time       `json:"time"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// Sign returns the signature header of a body sent at t
+func Sign(secret, id string, t time.Time, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(h, "%s.%d.", id, t.Unix())
+	h.Write(body)
+	return "v1," + base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Deliver posts d to the subscription, signed as sent now. A retry keeps
+// the ID, so the receiver can tell it from a new delivery, but gets a new
+// timestamp.
+func (s Subscription) Deliver(ctx context.Context, client *http.Client, d Delivery) error {
+	body, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IDHeader, d.ID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(s.Secret, d.ID, now, body))
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err // the URL may hold a token
+		}
+		return fmt.Errorf("deliver to %s: %w", s, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("deliver to %s: %s: %s", s, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	valid := func(event string) bool { return strings.HasPrefix(event, "article.") }
+	subs, err := Parse([]byte(`[
+		{"url": "https://hooks.example.com/a?token=x", "secret": "0123456789abcdef", "events": ["article.created"]},
+		{"url": "http://10.0.0.5/hook", "secret": "0123456789abcdef"}
+	]`), valid)
+	if err != nil || len(subs) != 2 {
+		t.Fatalf("Parse: %v, %v", subs, err)
+	}
+	if !subs[0].Wants("article.created") || subs[0].Wants("article.deleted") || !subs[1].Wants("article.deleted") {
+		t.Errorf("Wants: %+v", subs)
+	}
+	if s := subs[0].String(); strings.Contains(s, "token") {
+		t.Errorf("String() = %q shows the query", s)
+	}
+
+	for _, bad := range []string{
+		`[{"url": "ftp://example.com", "secret": "0123456789abcdef"}]`,
+		`[{"url": "https://example.com", "secret": "short"}]`,
+		`[{"url": "https://example.com", "secret": "0123456789abcdef", "events": ["backup.failed"]}]`,
+		`{"url": "https://example.com"}`,
+	} {
+		if _, err := Parse([]byte(bad), valid); err == nil {
+			t.Errorf("accepted %s", bad)
+		}
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	var header http.Header
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header, body = r.Header, nil
+		body, _ = io.ReadAll(r.Body)
+		if strings.Contains(string(body), "reject") {
+			http.Error(w, "nope", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	s := Subscription{URL: srv.URL, Secret: "0123456789abcdef"}
+	d := Delivery{ID: "d1", Event: "article.created", Time: time.Now(), Data: json.RawMessage(`{"id":1}`)}
+
+	if err := s.Deliver(context.Background(), srv.Client(), d); err != nil {
+		t.Fatal(err)
+	}
+	unix, _ := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if header.Get(IDHeader) != "d1" || time.Since(time.Unix(unix, 0)) > time.Minute {
+		t.Errorf("headers %v", header)
+	}
+	if got, want := header.Get(SignatureHeader), Sign(s.Secret, "d1", time.Unix(unix, 0), body); got != want || !strings.HasPrefix(got, "v1,") {
+		t.Errorf("signature %q, want %q", got, want)
+	}
+	if Sign("another secret!!", "d1", time.Unix(unix, 0), body) == header.Get(SignatureHeader) {
+		t.Error("signature doesn't depend on the secret")
+	}
+
+	d.Data = json.RawMessage(`"reject"`)
+	if err := s.Deliver(context.Background(), srv.Client(), d); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("rejected delivery: %v", err)
+	}
+}
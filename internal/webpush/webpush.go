@@ -0,0 +1,264 @@
+// Package webpush sends Web Push messages to the push services of
+// browsers. A message is encrypted for the browser's subscription as RFC
+// 8291 says (aes128gcm content coding, RFC 8188), and the request carries a
+// VAPID token (RFC 8292) signed with the server's key, the public half of
+// which the browser was given when it subscribed.
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record size of the encrypted body, which is one record
+const recordSize = 4096
+
+// MaxPayload is the longest payload Send accepts. Push services take
+// bodies of up to 4096 bytes, which leaves that less the header, the
+// padding delimiter and the AEAD tag.
+const MaxPayload = 4096 - 86 - 1 - 16
+
+// ErrGone is returned for a subscription the push service no longer
+// knows, which should be removed
+var ErrGone = errors.New("push subscription has expired or was removed")
+
+// Subscription is a browser's PushSubscription, as its toJSON() gives it
+type Subscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     Keys   `json:"keys"`
+}
+
+// Keys of a subscription, base64url encoded
+type Keys struct {
+	P256dh string `json:"p256dh"` // the browser's ECDH public key
+	Auth   string `json:"auth"`   // the shared authentication secret
+}
+
+// String hides all of the endpoint but its host, for logs
+func (s Subscription) String() string {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return "push subscription"
+	}
+	return u.Host
+}
+
+// Check that the endpoint is an https URL and the keys are a P-256 public
+// key and a 16-byte secret. An error starts with the JSON name of the
+// field at fault.
+func (s Subscription) Check() error {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("endpoint must be an https URL")
+	}
+	if _, _, err := s.keys(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// The browser's public key and the authentication secret
+func (s Subscription) keys() (*ecdh.PublicKey, []byte, error) {
+	raw, err := decode(s.Keys.P256dh)
+	if err != nil {
+		return nil, nil, errors.New("keys.p256dh is not base64url")
+	}
+	public, err := ecdh.P256().NewPublicKey(raw)
+	if err != nil {
+		return nil, nil, errors.New("keys.p256dh is not a P-256 public key")
+	}
+	auth, err := decode(s.Keys.Auth)
+	if err != nil || len(auth) != 16 {
+		return nil, nil, errors.New("keys.auth must be 16 bytes of base64url")
+	}
+	return public, auth, nil
+}
+
+// Base64url, with or without padding, as browsers and libraries differ
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// Encrypt payload for the subscription
+func encrypt(sub Subscription, payload []byte) ([]byte, error) {
+	if len(payload) > MaxPayload {
+		return nil, fmt.Errorf("push payload of %d bytes is over %d", len(payload), MaxPayload)
+	}
+	uaPublic, auth, err := sub.keys()
+	if err != nil {
+		return nil, err
+	}
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+
+	// RFC 8291 section 3.4: the input keying material from the ECDH secret
+	// and the authentication secret, then the content encryption key and
+	// nonce from it and a random salt
+	info := append(append([]byte("WebPush: info\x00"), uaPublic.Bytes()...), asPublic...)
+	ikm, err := hkdf.Key(sha256.New, secret, auth, string(info), 32)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// RFC 8188: salt, record size, key ID (the server's public key), then
+	// the one record, its plaintext ended by the last-record delimiter
+	var body bytes.Buffer
+	body.Write(salt)
+	binary.Write(&body, binary.BigEndian, uint32(recordSize))
+	body.WriteByte(byte(len(asPublic)))
+	body.Write(asPublic)
+	body.Write(gcm.Seal(nil, nonce, append(slices.Clone(payload), 2), nil))
+	return body.Bytes(), nil
+}
+
+// VAPID is the server's application key pair
+type VAPID struct {
+	key     *ecdsa.PrivateKey
+	subject string
+}
+
+// GenerateVAPID returns a new private key, base64url encoded
+func GenerateVAPID() (string, error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(key.Bytes()), nil
+}
+
+// ParseVAPID reads a base64url private key, as GenerateVAPID makes. The
+// subject, a mailto: or https: URL, lets a push service contact the
+// operator; it may be empty.
+func ParseVAPID(privateKey, subject string) (*VAPID, error) {
+	raw, err := decode(privateKey)
+	if err != nil {
+		return nil, errors.New("VAPID key is not base64url")
+	}
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, errors.New("VAPID key is not a P-256 private key")
+	}
+	if subject != "" && !strings.HasPrefix(subject, "mailto:") && !strings.HasPrefix(subject, "https://") {
+		return nil, errors.New("VAPID subject must be a mailto: or https: URL")
+	}
+	return &VAPID{key: key, subject: subject}, nil
+}
+
+// PublicKey returns the uncompressed public key, base64url encoded, which
+// browsers take as the applicationServerKey of a subscription
+func (v *VAPID) PublicKey() string {
+	raw, _ := v.key.PublicKey.Bytes()
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// The Authorization header for a push service at origin, good for 12
+// hours from now
+func (v *VAPID) authorization(origin string, now time.Time) (string, error) {
+	claims := map[string]any{"aud": origin, "exp": now.Add(12 * time.Hour).Unix()}
+	if v.subject != "" {
+		claims["sub"] = v.subject
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`)) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, v.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	// JWS wants r and s as 32 bytes each
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return "vapid t=" + signed + "." + base64.RawURLEncoding.EncodeToString(sig) + ", k=" + v.PublicKey(), nil
+}
+
+// Send encrypts payload for the subscription and posts it to its push
+// service, which keeps it for up to ttl while the browser is offline. A
+// subscription the service no longer knows gets ErrGone.
+func (v *VAPID) Send(ctx context.Context, client *http.Client, sub Subscription, payload []byte, ttl time.Duration) error {
+	body, err := encrypt(sub, payload)
+	if err != nil {
+		return err
+	}
+	endpoint, err := url.Parse(sub.Endpoint)
+	if err != nil {
+		return err
+	}
+	auth, err := v.authorization(endpoint.Scheme+"://"+endpoint.Host, time.Now())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(ttl.Seconds())))
+	req.Header.Set("Authorization", auth)
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err // the endpoint holds the subscription's token
+		}
+		return fmt.Errorf("push to %s: %w", sub, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrGone
+	case resp.StatusCode >= 300:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("push to %s: %s: %s", sub, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
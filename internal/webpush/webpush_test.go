@@ -0,0 +1,185 @@
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A browser's side of a subscription
+type browser struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+}
+
+func newBrowser(t *testing.T, endpoint string) (browser, Subscription) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := browser{key: key, auth: make([]byte, 16)}
+	rand.Read(b.auth)
+	return b, Subscription{Endpoint: endpoint, Keys: Keys{
+		P256dh: base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		Auth:   base64.URLEncoding.EncodeToString(b.auth), // padded, as some browsers send it
+	}}
+}
+
+// Decrypt a message as the browser does
+func (b browser) decrypt(body []byte) ([]byte, error) {
+	if len(body) < 21 || len(body) < 21+int(body[20]) {
+		return nil, errors.New("short header")
+	}
+	salt, rs, idlen := body[:16], binary.BigEndian.Uint32(body[16:20]), int(body[20])
+	if rs != recordSize {
+		return nil, errors.New("record size")
+	}
+	asPublic, err := ecdh.P256().NewPublicKey(body[21 : 21+idlen])
+	if err != nil {
+		return nil, err
+	}
+	secret, err := b.key.ECDH(asPublic)
+	if err != nil {
+		return nil, err
+	}
+	info := append(append([]byte("WebPush: info\x00"), b.key.PublicKey().Bytes()...), asPublic.Bytes()...)
+	ikm, _ := hkdf.Key(sha256.New, secret, b.auth, string(info), 32)
+	cek, _ := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	nonce, _ := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plain, err := gcm.Open(nil, nonce, body[21+idlen:], nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(plain) == 0 || plain[len(plain)-1] != 2 {
+		return nil, errors.New("no last-record delimiter")
+	}
+	return plain[:len(plain)-1], nil
+}
+
+// Check a VAPID Authorization header for origin, as a push service does
+func verify(header, publicKey, origin string, now time.Time) error {
+	params := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(header, "vapid "), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		params[name] = value
+	}
+	if params["k"] != publicKey {
+		return errors.New("another key")
+	}
+	raw, _ := decode(publicKey)
+	key, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), raw)
+	if err != nil {
+		return err
+	}
+	token := strings.Split(params["t"], ".")
+	if len(token) != 3 {
+		return errors.New("malformed token")
+	}
+	sig, err := decode(token[2])
+	if err != nil || len(sig) != 64 {
+		return errors.New("malformed signature")
+	}
+	digest := sha256.Sum256([]byte(token[0] + "." + token[1]))
+	if !ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		return errors.New("bad signature")
+	}
+	payload, _ := decode(token[1])
+	var claims struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return err
+	}
+	if claims.Aud != origin || now.Unix() >= claims.Exp || claims.Sub != "mailto:ops@example.com" {
+		return errors.New("wrong claims")
+	}
+	return nil
+}
+
+func TestSend(t *testing.T) {
+	var got []byte
+	var header http.Header
+	gone := false
+	service := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if gone {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		got, _ = io.ReadAll(r.Body)
+		header = r.Header
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer service.Close()
+
+	private, err := GenerateVAPID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseVAPID(private, "ops@example.com"); err == nil {
+		t.Error("subject without mailto: accepted")
+	}
+	if _, err := ParseVAPID("not a key", ""); err == nil {
+		t.Error("invalid key accepted")
+	}
+	vapid, err := ParseVAPID(private, "mailto:ops@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, sub := newBrowser(t, service.URL+"/push/abc")
+	if err := sub.Check(); err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []Subscription{
+		{Endpoint: "http://push.example.com/x", Keys: sub.Keys},
+		{Endpoint: sub.Endpoint, Keys: Keys{P256dh: "AAAA", Auth: sub.Keys.Auth}},
+		{Endpoint: sub.Endpoint, Keys: Keys{P256dh: sub.Keys.P256dh, Auth: "c2hvcnQ"}},
+	} {
+		if bad.Check() == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+
+	payload := []byte(`{"title":"Published: Hello"}`)
+	if err := vapid.Send(context.Background(), service.Client(), sub, payload, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := b.decrypt(got); err != nil || !bytes.Equal(plain, payload) {
+		t.Errorf("decrypted %q, %v", plain, err)
+	}
+	if header.Get("Content-Encoding") != "aes128gcm" || header.Get("TTL") != "3600" {
+		t.Errorf("headers %v", header)
+	}
+	if err := verify(header.Get("Authorization"), vapid.PublicKey(), service.URL, time.Now()); err != nil {
+		t.Errorf("authorization %q: %v", header.Get("Authorization"), err)
+	}
+
+	if err := vapid.Send(context.Background(), service.Client(), sub, make([]byte, MaxPayload+1), time.Hour); err == nil {
+		t.Error("oversized payload sent")
+	}
+	gone = true
+	if err := vapid.Send(context.Background(), service.Client(), sub, payload, time.Hour); !errors.Is(err, ErrGone) {
+		t.Errorf("expired subscription: %v", err)
+	}
+}
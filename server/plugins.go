@@ -0,0 +1,57 @@
+package server
+
+import (
+	"errors"
+
+	"go-spring/internal/embeddings"
+	"go-spring/internal/handlers"
+	"go-spring/internal/i18n"
+	"go-spring/internal/model"
+	"go-spring/internal/summarize"
+)
+
+// Types that plugins work with
+type (
+	Plugin               = handlers.Plugin
+	PluginRoute          = handlers.PluginRoute
+	Middleware           = handlers.Middleware
+	Route                = handlers.Route
+	Interceptor          = handlers.Interceptor
+	Invocation           = handlers.Invocation
+	Article              = model.Article
+	CreateArticleRequest = model.CreateArticleRequest
+	ValidationError      = handlers.ValidationError
+	MessageSource        = i18n.Source
+	Catalog              = i18n.Catalog
+	Summarizer           = summarize.Summarizer
+	SummarizerFunc       = summarize.SummarizerFunc
+	SummarizeRequest     = summarize.Request
+	Embedder             = embeddings.Embedder
+	EmbedderFunc         = embeddings.EmbedderFunc
+)
+
+// Use registers a plugin's hooks and routes. Call it after New and before
+// Start; plugins run in the order they were added.
+//
+//	srv.Use(server.Plugin{
+//		Name: "house-style",
+//		BeforeCreate: func(ctx context.Context, req *server.CreateArticleRequest) error {
+//			if strings.Contains(req.Title, "!!") {
+//				return &server.ValidationError{Message: "Titles may not shout"}
+//			}
+//			return nil
+//		},
+//		Routes: []server.PluginRoute{{Method: "GET", Path: "/stats/words", Handler: words}},
+//	})
+func (s *Server) Use(p Plugin) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started || s.shutdown {
+		return errors.New("server: Use after Start")
+	}
+	if err := s.app.AddPlugin(p); err != nil {
+		return err
+	}
+	s.handler = s.app.Router()
+	return nil
+}
@@ -0,0 +1,205 @@
+// Package server is the go-spring articles API. The go-spring binary runs it
+// on its own; other programs can embed it and mount the handler:
+//
+//	store, err := server.OpenDataStore("articles.gob")
+//	cfg := server.LoadConfig()
+//	cfg.Addr, cfg.GRPCAddr = "off", "off"
+//	srv, err := server.New(cfg, store)
+//	err = srv.Start(ctx) // background saving
+//	mux.Handle("/api/", http.StripPrefix("/api", srv))
+//	...
+//	srv.Shutdown(ctx) // saves and closes the store
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"go-spring/internal/config"
+	"go-spring/internal/handlers"
+	"go-spring/internal/store"
+)
+
+// Types and constructors of the internal packages that embedders need
+type (
+	Config    = config.Config
+	DataStore = store.DataStore
+	Database  = store.Database
+)
+
+// LoadConfig reads the configuration from the environment, like the binary
+func LoadConfig() Config {
+	return config.Load()
+}
+
+// OpenDataStore opens a .gob file path, sqlite:path or postgres:// URL
+func OpenDataStore(location string) (DataStore, error) {
+	return store.Open(location)
+}
+
+// Server serves the REST API as an http.Handler. Each Server has its own
+// articles, users and configuration, so a process may run several, each on
+// its own store; they share only the HASHIDS settings, which must agree.
+type Server struct {
+	cfg     Config
+	store   DataStore
+	app     *handlers.App
+	handler http.Handler
+
+	mu        sync.Mutex
+	started   bool
+	shutdown  bool
+	listeners []*http.Server // set by Start
+	serving   sync.WaitGroup
+	failed    chan error    // first error of a listener after Start
+	closed    chan struct{} // closed when Shutdown has finished
+}
+
+// New loads the data in store, seeding sample articles into an empty store
+// as the binary does, and prepares attachment storage and the response cache
+// from cfg. The Server owns store from then on and closes it in Shutdown.
+func New(cfg Config, store DataStore) (*Server, error) {
+	app, err := handlers.New(cfg, store)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{cfg: cfg, store: store, app: app, handler: app.Router(), failed: make(chan error, 1), closed: make(chan struct{})}, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// Start begins the background work (saving changes, following cache
+// invalidations from other replicas) and listens on the configured Addr and
+// GRPCAddr, either of which may be "off" for a Server that is only mounted
+// as a handler. It returns once the listeners are open.
+func (s *Server) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shutdown || s.started {
+		return errors.New("server: already started")
+	}
+
+	var listeners []net.Listener
+	var servers []*http.Server
+	for _, l := range []struct {
+		addr   string
+		server *http.Server
+	}{
+		{s.cfg.Addr, &http.Server{Handler: s.handler}},
+		{s.cfg.GRPCAddr, s.app.GRPCServer()},
+	} {
+		if l.addr == "off" {
+			continue
+		}
+		ln, err := new(net.ListenConfig).Listen(ctx, "tcp", l.addr)
+		if err != nil {
+			for _, ln := range listeners {
+				ln.Close()
+			}
+			return err
+		}
+		listeners = append(listeners, ln)
+		servers = append(servers, l.server)
+	}
+	if err := s.app.StartBackground(ctx); err != nil {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+		return err
+	}
+
+	s.started = true
+	s.listeners = servers
+	for i, server := range servers {
+		s.serving.Go(func() {
+			if err := server.Serve(listeners[i]); !errors.Is(err, http.ErrServerClosed) {
+				select {
+				case s.failed <- fmt.Errorf("server: serve on %s: %w", listeners[i].Addr(), err):
+				default:
+				}
+			}
+		})
+	}
+	return nil
+}
+
+// Stop is Shutdown, for the application context
+func (s *Server) Stop(ctx context.Context) error {
+	return s.Shutdown(ctx)
+}
+
+// Wait blocks until Shutdown has finished, returning nil, or until a
+// listener fails, returning its error; the Server still needs a Shutdown
+// then.
+func (s *Server) Wait() error {
+	select {
+	case err := <-s.failed:
+		return err
+	case <-s.closed:
+		return nil
+	}
+}
+
+// Run starts the Server and serves until Shutdown, which makes Run return
+// nil once it has finished. An admin's POST /admin/shutdown or /admin/drain
+// shuts it down too.
+func (s *Server) Run() error {
+	if err := s.Start(context.Background()); err != nil {
+		return err
+	}
+	go func() {
+		select {
+		case <-s.ShutdownRequested():
+			ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+			defer cancel()
+			s.Shutdown(ctx)
+		case <-s.closed:
+		}
+	}()
+	return s.Wait()
+}
+
+// ShutdownRequested returns a channel that is closed when an admin asks for
+// a shutdown with POST /admin/shutdown, or a drain has ended. Programs that
+// Start the Server themselves call Shutdown then.
+func (s *Server) ShutdownRequested() <-chan struct{} {
+	return s.app.ShutdownRequested()
+}
+
+// Shutdown stops the listeners started by Start, waiting for requests in
+// progress until ctx is done, then stops the background work, saves the
+// data and closes the store. An embedding program that mounts the handler
+// itself should stop sending requests to it first.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.shutdown {
+		s.mu.Unlock()
+		return errors.New("server: already shut down")
+	}
+	s.shutdown = true
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	var errs []error
+	for _, server := range listeners {
+		errs = append(errs, server.Shutdown(ctx))
+	}
+	s.serving.Wait()
+	// The saver writes the changes still pending; Save covers a Server that
+	// was never started
+	errs = append(errs, s.app.StopBackground(ctx), s.app.Save(), s.store.Close())
+	close(s.closed)
+	return errors.Join(errs...)
+}
+
+// PrintRoutes prints the listening addresses and the routes, as the binary
+// does on startup
+func (s *Server) PrintRoutes() {
+	s.app.PrintRoutes()
+}
@@ -0,0 +1,358 @@
+// Package spring is a small dependency injection container. Components are
+// registered as constructors, whose parameters are resolved from other
+// components by type (or by name), and an ApplicationContext creates them in
+// dependency order and runs their lifecycle:
+//
+//	ac := spring.NewApplicationContext()
+//	ac.Supply(cfg)
+//	ac.Provide(openStore)  // func(config.Config) (store.DataStore, error)
+//	ac.Provide(server.New) // func(config.Config, store.DataStore) (*server.Server, error)
+//	if err := ac.Start(ctx); err != nil { ... }
+//	srv, err := spring.Get[*server.Server](ac)
+//	...
+//	ac.Shutdown(ctx)
+//
+// Components take part in the lifecycle by implementing Initializer (called
+// once the component and its dependencies exist), Starter (called in
+// dependency order after every component is initialized) and Stopper (called
+// in reverse order on Shutdown).
+//
+// Background work belongs in Start and Stop rather than in constructors or
+// init functions: a component's dependencies are started before it and
+// stopped after it, so a scheduler can rely on the store it writes to until
+// its own Stop returns.
+package spring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Initializer is implemented by components that need setup after construction
+type Initializer interface {
+	Init(ctx context.Context) error
+}
+
+// Starter is implemented by components that run in the background, such as
+// listeners and schedulers. Start should return once the work is running;
+// ctx only bounds the startup.
+type Starter interface {
+	Start(ctx context.Context) error
+}
+
+// Stopper is implemented by components that finish their work and release
+// resources on Shutdown. Stop should return when ctx is done even if the
+// work has not finished.
+type Stopper interface {
+	Stop(ctx context.Context) error
+}
+
+// In marks a struct parameter whose fields are injected one by one: a field
+// tagged `bean:"name"` gets the component with that name, other fields the
+// component of their type. Tag a field `bean:",optional"` (or
+// `bean:"name,optional"`) to leave it zero when there is no such component.
+//
+//	type serverDeps struct {
+//		spring.In
+//		Primary store.DataStore `bean:"primary"`
+//		Cache   cache.Cache     `bean:",optional"`
+//	}
+type In struct{}
+
+var (
+	inType    = reflect.TypeFor[In]()
+	errorType = reflect.TypeFor[error]()
+)
+
+// ErrNotFound is returned when no component matches a type or name
+var ErrNotFound = errors.New("spring: no such component")
+
+// Option changes how a component is registered
+type Option func(*bean)
+
+// Name registers the component under a name, for `bean:"name"` fields and Get
+func Name(name string) Option {
+	return func(b *bean) { b.name = name }
+}
+
+// Primary picks the component when several have the requested type
+func Primary() Option {
+	return func(b *bean) { b.primary = true }
+}
+
+// A registered component; value is set once it has been created
+type bean struct {
+	name     string
+	primary  bool
+	typ      reflect.Type
+	ctor     reflect.Value // nil for supplied values
+	value    reflect.Value
+	created  bool
+	creating bool
+}
+
+func (b *bean) String() string {
+	if b.name != "" {
+		return fmt.Sprintf("%s (%q)", b.typ, b.name)
+	}
+	return b.typ.String()
+}
+
+// ApplicationContext holds the components of an application
+type ApplicationContext struct {
+	mu      sync.Mutex
+	beans   []*bean
+	order   []*bean // in creation order
+	started bool
+}
+
+func NewApplicationContext() *ApplicationContext {
+	return &ApplicationContext{}
+}
+
+// Provide registers a constructor: a function returning the component and
+// optionally an error. Its parameters are resolved from other components
+// when the context starts.
+func (ac *ApplicationContext) Provide(constructor any, opts ...Option) error {
+	fn := reflect.ValueOf(constructor)
+	t := fn.Type()
+	if t.Kind() != reflect.Func || t.NumOut() < 1 || t.NumOut() > 2 || (t.NumOut() == 2 && t.Out(1) != errorType) {
+		return fmt.Errorf("spring: constructor must be a func returning T or (T, error), got %s", t)
+	}
+	return ac.add(&bean{typ: t.Out(0), ctor: fn}, opts)
+}
+
+// Supply registers an existing value, such as the configuration. The caller
+// keeps ownership: supplied values are not initialized, started or stopped.
+func (ac *ApplicationContext) Supply(value any, opts ...Option) error {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return errors.New("spring: cannot supply nil")
+	}
+	return ac.add(&bean{typ: v.Type(), value: v, created: true}, opts)
+}
+
+func (ac *ApplicationContext) add(b *bean, opts []Option) error {
+	for _, opt := range opts {
+		opt(b)
+	}
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if ac.started {
+		return errors.New("spring: context already started")
+	}
+	if b.name != "" && slices.ContainsFunc(ac.beans, func(other *bean) bool { return other.name == b.name }) {
+		return fmt.Errorf("spring: duplicate component name %q", b.name)
+	}
+	ac.beans = append(ac.beans, b)
+	return nil
+}
+
+// Start creates every component in dependency order, calling Init on each
+// as it is created, then Start on all of them in the same order. If anything
+// fails, the components created so far are stopped and the error is
+// returned; a component whose own Start failed is not stopped.
+func (ac *ApplicationContext) Start(ctx context.Context) error {
+	ac.mu.Lock()
+	if ac.started {
+		ac.mu.Unlock()
+		return errors.New("spring: context already started")
+	}
+	ac.started = true
+	ac.mu.Unlock()
+
+	err := func() error {
+		for _, b := range ac.beans {
+			if _, err := ac.create(ctx, b, nil); err != nil {
+				return err
+			}
+		}
+		for i, b := range ac.order {
+			if starter, ok := b.value.Interface().(Starter); ok {
+				if err := starter.Start(ctx); err != nil {
+					ac.order = slices.Delete(ac.order, i, i+1)
+					return fmt.Errorf("spring: %s start: %w", b, err)
+				}
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		return errors.Join(err, ac.Shutdown(ctx))
+	}
+	return nil
+}
+
+// Create a component after its dependencies; path is the chain of
+// components being created, for cycle errors
+func (ac *ApplicationContext) create(ctx context.Context, b *bean, path []*bean) (reflect.Value, error) {
+	if b.created {
+		return b.value, nil
+	}
+	path = append(path, b)
+	if b.creating {
+		names := make([]string, len(path))
+		for i, p := range path {
+			names[i] = p.String()
+		}
+		return reflect.Value{}, fmt.Errorf("spring: dependency cycle: %s", strings.Join(names, " -> "))
+	}
+	b.creating = true
+	defer func() { b.creating = false }()
+
+	t := b.ctor.Type()
+	args := make([]reflect.Value, t.NumIn())
+	for i := range args {
+		arg, err := ac.argument(ctx, t.In(i), path)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("spring: creating %s: %w", b, err)
+		}
+		args[i] = arg
+	}
+	out := b.ctor.Call(args)
+	if len(out) == 2 && !out[1].IsNil() {
+		return reflect.Value{}, fmt.Errorf("spring: creating %s: %w", b, out[1].Interface().(error))
+	}
+	b.value, b.created = out[0], true
+	ac.order = append(ac.order, b)
+
+	if init, ok := b.value.Interface().(Initializer); ok {
+		if err := init.Init(ctx); err != nil {
+			return reflect.Value{}, fmt.Errorf("spring: %s init: %w", b, err)
+		}
+	}
+	return b.value, nil
+}
+
+// Value for a constructor parameter: a component of type t, or an In struct
+func (ac *ApplicationContext) argument(ctx context.Context, t reflect.Type, path []*bean) (reflect.Value, error) {
+	if t.Kind() != reflect.Struct || !embedsIn(t) {
+		b, err := ac.lookup(t, "")
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return ac.create(ctx, b, path)
+	}
+
+	v := reflect.New(t).Elem()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if f.Anonymous && f.Type == inType {
+			continue
+		}
+		name, flags, _ := strings.Cut(f.Tag.Get("bean"), ",")
+		b, err := ac.lookup(f.Type, name)
+		if errors.Is(err, ErrNotFound) && flags == "optional" {
+			continue
+		}
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		value, err := ac.create(ctx, b, path)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v.Field(i).Set(value)
+	}
+	return v, nil
+}
+
+func embedsIn(t reflect.Type) bool {
+	for i := range t.NumField() {
+		if f := t.Field(i); f.Anonymous && f.Type == inType {
+			return true
+		}
+	}
+	return false
+}
+
+// Find the component for a type, or the named one (which must be assignable
+// to t). Components of exactly type t win over others assignable to it, and
+// among several candidates a Primary one wins.
+func (ac *ApplicationContext) lookup(t reflect.Type, name string) (*bean, error) {
+	if name != "" {
+		for _, b := range ac.beans {
+			if b.name == name {
+				if !b.typ.AssignableTo(t) {
+					return nil, fmt.Errorf("spring: component %q is a %s, not a %s", name, b.typ, t)
+				}
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("%w named %q", ErrNotFound, name)
+	}
+
+	var exact, assignable []*bean
+	for _, b := range ac.beans {
+		switch {
+		case b.typ == t:
+			exact = append(exact, b)
+		case b.typ.AssignableTo(t):
+			assignable = append(assignable, b)
+		}
+	}
+	candidates := exact
+	if len(candidates) == 0 {
+		candidates = assignable
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("%w of type %s", ErrNotFound, t)
+	case 1:
+		return candidates[0], nil
+	}
+	var primary []*bean
+	for _, b := range candidates {
+		if b.primary {
+			primary = append(primary, b)
+		}
+	}
+	if len(primary) == 1 {
+		return primary[0], nil
+	}
+	return nil, fmt.Errorf("spring: %d components of type %s and no single Primary one", len(candidates), t)
+}
+
+// Get returns the component of type T, or the one named name, from a
+// started context
+func Get[T any](ac *ApplicationContext, name ...string) (T, error) {
+	var zero T
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if !ac.started {
+		return zero, errors.New("spring: context not started")
+	}
+	b, err := ac.lookup(reflect.TypeFor[T](), strings.Join(name, ""))
+	if err != nil {
+		return zero, err
+	}
+	if !b.created {
+		return zero, fmt.Errorf("spring: %s was not created", b)
+	}
+	return b.value.Interface().(T), nil
+}
+
+// Shutdown calls Stop on every created component that implements Stopper,
+// in reverse creation order, and returns the errors joined. Each component
+// is stopped once even if Shutdown is called again.
+func (ac *ApplicationContext) Shutdown(ctx context.Context) error {
+	ac.mu.Lock()
+	order := ac.order
+	ac.order = nil
+	ac.mu.Unlock()
+
+	var errs []error
+	for _, b := range slices.Backward(order) {
+		if stopper, ok := b.value.Interface().(Stopper); ok {
+			if err := stopper.Stop(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("spring: %s stop: %w", b, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
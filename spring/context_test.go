@@ -0,0 +1,161 @@
+package spring
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type config struct{ dsn string }
+
+type repo interface{ DSN() string }
+
+// lifecycle events of all components, in order
+type recorder struct{ events []string }
+
+type db struct {
+	dsn string
+	rec *recorder
+}
+
+func (d *db) DSN() string                { return d.dsn }
+func (d *db) Init(context.Context) error { d.rec.add("db init"); return nil }
+func (d *db) Stop(context.Context) error { d.rec.add("db stop"); return nil }
+
+type service struct {
+	repo repo
+	rec  *recorder
+	err  error // returned by Start
+}
+
+func (s *service) Init(context.Context) error { s.rec.add("service init"); return nil }
+func (s *service) Stop(context.Context) error { s.rec.add("service stop"); return nil }
+
+func (s *service) Start(context.Context) error {
+	s.rec.add("service start")
+	return s.err
+}
+
+func (r *recorder) add(event string) { r.events = append(r.events, event) }
+
+func TestLifecycleOrder(t *testing.T) {
+	rec := &recorder{}
+	ac := NewApplicationContext()
+	// Registered before its dependency; creation follows dependencies
+	ac.Provide(func(r repo) *service { return &service{repo: r, rec: rec} })
+	ac.Provide(func(cfg config) (*db, error) { return &db{dsn: cfg.dsn, rec: rec}, nil })
+	ac.Supply(config{dsn: "sqlite:test.db"})
+
+	if err := ac.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := Get[*service](ac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc.repo.DSN() != "sqlite:test.db" {
+		t.Errorf("service got repo %v", svc.repo)
+	}
+	if err := ac.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"db init", "service init", "service start", "service stop", "db stop"}
+	if !slices.Equal(rec.events, want) {
+		t.Errorf("events %q, want %q", rec.events, want)
+	}
+}
+
+func TestResolveByName(t *testing.T) {
+	type deps struct {
+		In
+		Primary repo     `bean:"primary"`
+		Replica repo     `bean:"replica"`
+		Missing *service `bean:",optional"`
+	}
+	rec := &recorder{}
+	var got deps
+	ac := NewApplicationContext()
+	ac.Provide(func() *db { return &db{dsn: "one", rec: rec} }, Name("primary"))
+	ac.Provide(func() *db { return &db{dsn: "two", rec: rec} }, Name("replica"))
+	ac.Provide(func(d deps) string { got = d; return "done" })
+
+	if err := ac.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got.Primary.DSN() != "one" || got.Replica.DSN() != "two" || got.Missing != nil {
+		t.Errorf("injected %+v", got)
+	}
+	if d, err := Get[*db](ac, "replica"); err != nil || d.dsn != "two" {
+		t.Errorf("Get replica = %v, %v", d, err)
+	}
+}
+
+func TestAmbiguousAndPrimary(t *testing.T) {
+	rec := &recorder{}
+	ac := NewApplicationContext()
+	ac.Provide(func() *db { return &db{dsn: "one", rec: rec} })
+	ac.Provide(func() *db { return &db{dsn: "two", rec: rec} })
+	ac.Provide(func(r repo) *service { return &service{repo: r, rec: rec} })
+	if err := ac.Start(context.Background()); err == nil || !strings.Contains(err.Error(), "no single Primary") {
+		t.Errorf("Start = %v, want an ambiguity error", err)
+	}
+
+	ac = NewApplicationContext()
+	ac.Provide(func() *db { return &db{dsn: "one", rec: rec} })
+	ac.Provide(func() *db { return &db{dsn: "two", rec: rec} }, Primary())
+	ac.Provide(func(r repo) *service { return &service{repo: r, rec: rec} })
+	if err := ac.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if svc, _ := Get[*service](ac); svc.repo.DSN() != "two" {
+		t.Errorf("service got %q, want the primary", svc.repo.DSN())
+	}
+}
+
+func TestStartErrors(t *testing.T) {
+	rec := &recorder{}
+
+	ac := NewApplicationContext()
+	ac.Provide(func(*service) *db { return nil })
+	ac.Provide(func(*db) *service { return nil })
+	if err := ac.Start(context.Background()); err == nil || !strings.Contains(err.Error(), "dependency cycle") {
+		t.Errorf("cycle: Start = %v", err)
+	}
+
+	ac = NewApplicationContext()
+	ac.Provide(func(config) *db { return nil })
+	if err := ac.Start(context.Background()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing dependency: Start = %v, want ErrNotFound", err)
+	}
+
+	// A failing constructor shuts down what was already created
+	boom := errors.New("boom")
+	ac = NewApplicationContext()
+	ac.Provide(func() *db { return &db{rec: rec} })
+	ac.Provide(func(*db) (*service, error) { return nil, boom })
+	if err := ac.Start(context.Background()); !errors.Is(err, boom) {
+		t.Errorf("constructor error: Start = %v", err)
+	}
+	if !slices.Contains(rec.events, "db stop") {
+		t.Errorf("created components were not stopped: %q", rec.events)
+	}
+
+	// A component that failed to start is not stopped, its dependencies are
+	rec = &recorder{}
+	ac = NewApplicationContext()
+	ac.Provide(func() *db { return &db{rec: rec} })
+	ac.Provide(func(d *db) *service { return &service{repo: d, rec: rec, err: boom} })
+	if err := ac.Start(context.Background()); !errors.Is(err, boom) {
+		t.Errorf("start error: Start = %v", err)
+	}
+	if want := []string{"db init", "service init", "service start", "db stop"}; !slices.Equal(rec.events, want) {
+		t.Errorf("events %q, want %q", rec.events, want)
+	}
+
+	if err := NewApplicationContext().Provide("not a func"); err == nil {
+		t.Error("Provide accepted a non-function")
+	}
+}
@@ -0,0 +1,493 @@
+// Package springtest provides an in-memory fake of the go-spring articles
+// API for testing code that talks to it, without running the real server.
+//
+//	func TestSync(t *testing.T) {
+//		srv := springtest.NewServer(t, client.Article{Title: "Hello", Desc: "d", Content: "c"})
+//		runSync(srv.APIClient())
+//		srv.AssertRequested(t, "POST", "/articles")
+//	}
+//
+// The fake serves GET, POST, PUT and DELETE on /articles and /articles/{id}
+// with the same envelope, status codes, validation and paging as the real
+// server. Other routes return 404.
+package springtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"go-spring/client"
+	"go-spring/internal/slug"
+)
+
+// Server is a fake go-spring server backed by httptest.Server
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	articles []client.Article // in ID order
+	nextID   int
+	requests []Request
+	failures []failure
+}
+
+// Request is a request received by the fake server
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   []byte
+}
+
+type failure struct {
+	status  int
+	message string
+}
+
+// NewServer starts a fake server holding the fixtures and closes it when the
+// test ends. Fixtures without an ID are numbered after the highest ID given;
+// missing timestamps are set to now and a missing status to published.
+func NewServer(t testing.TB, fixtures ...client.Article) *Server {
+	s := &Server{nextID: 1}
+	s.Seed(fixtures...)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /articles", s.list)
+	mux.HandleFunc("POST /articles", s.create)
+	mux.HandleFunc("GET /articles/{id}", s.get)
+	mux.HandleFunc("GET /articles/by-slug/{slug}", s.getBySlug)
+	mux.HandleFunc("PUT /articles/{id}", s.update)
+	mux.HandleFunc("DELETE /articles/{id}", s.delete)
+	s.Server = httptest.NewServer(s.record(mux))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// APIClient returns a client for the fake server. Retries wait only a few
+// milliseconds, so tests of retry behavior stay fast.
+func (s *Server) APIClient() *client.Client {
+	c := client.New(s.URL)
+	c.HTTPClient = s.Client()
+	c.Retry = client.RetryPolicy{MaxRetries: 3, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+	return c
+}
+
+// Seed adds articles to the fake server
+func (s *Server) Seed(fixtures ...client.Article) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range fixtures {
+		if a.ID >= s.nextID {
+			s.nextID = a.ID + 1
+		}
+	}
+	now := time.Now()
+	for _, a := range fixtures {
+		if a.ID == 0 {
+			a.ID = s.nextID
+			s.nextID++
+		}
+		if a.Created.IsZero() {
+			a.Created = now
+		}
+		if a.Updated.IsZero() {
+			a.Updated = a.Created
+		}
+		if a.Status == "" {
+			a.Status = client.StatusPublished
+		}
+		if a.Status == client.StatusPublished && a.Published.IsZero() {
+			a.Published = a.Created
+		}
+		if a.Slug == "" {
+			a.Slug = s.uniqueSlug(a.Title)
+		}
+		s.articles = append(s.articles, a)
+	}
+	slices.SortStableFunc(s.articles, func(a, b client.Article) int { return a.ID - b.ID })
+}
+
+// Articles returns the articles currently stored, in ID order
+func (s *Server) Articles() []client.Article {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.articles)
+}
+
+// FailNext makes the next count requests fail with status and message,
+// e.g. to test how a consumer handles outages or rate limiting
+func (s *Server) FailNext(count, status int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for range count {
+		s.failures = append(s.failures, failure{status, message})
+	}
+}
+
+// Requests returns every request received so far, oldest first
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+// RequestCount returns how many requests matched method and path
+func (s *Server) RequestCount(method, path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, r := range s.requests {
+		if r.Method == method && r.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+// AssertRequested fails the test unless method and path were requested
+func (s *Server) AssertRequested(t testing.TB, method, path string) {
+	t.Helper()
+	if s.RequestCount(method, path) == 0 {
+		t.Errorf("springtest: expected a %s %s request; got %s", method, path, s.describeRequests())
+	}
+}
+
+// AssertNotRequested fails the test if method and path were requested
+func (s *Server) AssertNotRequested(t testing.TB, method, path string) {
+	t.Helper()
+	if n := s.RequestCount(method, path); n > 0 {
+		t.Errorf("springtest: expected no %s %s request; got %d", method, path, n)
+	}
+}
+
+// AssertRequestCount fails the test unless method and path were requested
+// exactly n times
+func (s *Server) AssertRequestCount(t testing.TB, method, path string, n int) {
+	t.Helper()
+	if got := s.RequestCount(method, path); got != n {
+		t.Errorf("springtest: expected %d %s %s requests; got %d", n, method, path, got)
+	}
+}
+
+func (s *Server) describeRequests() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return "none"
+	}
+	list := make([]string, len(s.requests))
+	for i, r := range s.requests {
+		list[i] = r.Method + " " + r.Path
+	}
+	return strings.Join(list, ", ")
+}
+
+// Record every request, then serve it or fail it as set up by FailNext
+func (s *Server) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := readBody(r)
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.RawQuery,
+			Header: r.Header.Clone(),
+			Body:   body,
+		})
+		var fail *failure
+		if len(s.failures) > 0 {
+			fail = &s.failures[0]
+			s.failures = s.failures[1:]
+		}
+		s.mu.Unlock()
+
+		if fail != nil {
+			writeError(w, fail.status, statusCode(fail.status), fail.message, nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := r.URL.Query()
+	if !query.Has("page_size") && !query.Has("page_token") {
+		// Pinned articles first, like the real server
+		list := slices.Clone(s.articles)
+		slices.SortStableFunc(list, func(a, b client.Article) int {
+			switch {
+			case a.Pinned && !b.Pinned:
+				return -1
+			case b.Pinned && !a.Pinned:
+				return 1
+			}
+			return 0
+		})
+		writeData(w, http.StatusOK, "Articles retrieved successfully", nonNil(list))
+		return
+	}
+
+	pageSize, _ := strconv.Atoi(query.Get("page_size"))
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	pageSize = min(pageSize, 100)
+	afterID := 0
+	if token := query.Get("page_token"); token != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(token)
+		if err == nil {
+			afterID, err = strconv.Atoi(strings.TrimPrefix(string(raw), "after:"))
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, client.CodeValidationFailed, "Invalid page_token", nil)
+			return
+		}
+	}
+
+	var page client.ArticlePage
+	for _, a := range s.articles {
+		if a.ID > afterID {
+			page.Articles = append(page.Articles, a)
+		}
+	}
+	if len(page.Articles) > pageSize {
+		page.Articles = page.Articles[:pageSize]
+		last := page.Articles[pageSize-1].ID
+		page.NextPageToken = base64.RawURLEncoding.EncodeToString([]byte("after:" + strconv.Itoa(last)))
+	}
+	page.Articles = nonNil(page.Articles)
+	writeData(w, http.StatusOK, "Articles retrieved successfully", page)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+	writeData(w, http.StatusOK, "Article retrieved successfully", s.articles[i])
+}
+
+func (s *Server) getBySlug(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.articles, func(a client.Article) bool { return a.Slug == r.PathValue("slug") })
+	if i < 0 {
+		writeError(w, http.StatusNotFound, client.CodeArticleNotFound, "Article not found", nil)
+		return
+	}
+	writeData(w, http.StatusOK, "Article retrieved successfully", s.articles[i])
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request) {
+	var req client.CreateArticleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, client.CodeInvalidBody, "Invalid request body", nil)
+		return
+	}
+	var invalid []client.FieldError
+	for _, f := range []struct{ name, value string }{{"title", req.Title}, {"desc", req.Desc}, {"content", req.Content}} {
+		if strings.TrimSpace(f.value) == "" {
+			invalid = append(invalid, client.FieldError{Field: f.name, Rule: "required", Message: f.name + " is required"})
+		}
+	}
+	if invalid = append(invalid, checkArticle(req.Title, req.Desc, req.Status)...); len(invalid) > 0 {
+		writeValidationError(w, invalid)
+		return
+	}
+	if req.Status == "" {
+		req.Status = client.StatusPublished
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	article := client.Article{
+		ID:       s.nextID,
+		Title:    req.Title,
+		Slug:     s.uniqueSlug(req.Title),
+		Desc:     req.Desc,
+		Content:  req.Content,
+		Created:  now,
+		Updated:  now,
+		Status:   req.Status,
+		Pinned:   req.Pinned,
+		Featured: req.Featured,
+	}
+	if article.Status == client.StatusPublished {
+		article.Published = now
+	}
+	s.nextID++
+	s.articles = append(s.articles, article)
+	writeData(w, http.StatusCreated, "Article created successfully", article)
+}
+
+func (s *Server) update(w http.ResponseWriter, r *http.Request) {
+	var req client.UpdateArticleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, client.CodeInvalidBody, "Invalid request body", nil)
+		return
+	}
+	if invalid := checkArticle(req.Title, req.Desc, req.Status); len(invalid) > 0 {
+		writeValidationError(w, invalid)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+	a := &s.articles[i]
+	if req.Title != "" {
+		a.Title = req.Title
+	}
+	if req.Desc != "" {
+		a.Desc = req.Desc
+	}
+	if req.Content != "" {
+		a.Content = req.Content
+	}
+	if req.Status != "" {
+		a.Status = req.Status
+		if a.Status == client.StatusPublished && a.Published.IsZero() {
+			a.Published = time.Now()
+		}
+	}
+	if req.Pinned != nil {
+		a.Pinned = *req.Pinned
+	}
+	if req.Featured != nil {
+		a.Featured = *req.Featured
+		if !a.Featured {
+			a.FeaturedOrder = 0
+		}
+	}
+	a.Updated = time.Now()
+	writeData(w, http.StatusOK, "Article updated successfully", *a)
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+	s.articles = slices.Delete(s.articles, i, i+1)
+	writeData(w, http.StatusOK, "Article deleted successfully", nil)
+}
+
+// Index of the article in the {id} path value; writes the error response
+// if the ID is invalid or unknown. Called with s.mu held.
+func (s *Server) find(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, client.CodeInvalidID, "Invalid article ID", nil)
+		return 0, false
+	}
+	i := slices.IndexFunc(s.articles, func(a client.Article) bool { return a.ID == id })
+	if i < 0 {
+		writeError(w, http.StatusNotFound, client.CodeArticleNotFound, "Article not found", nil)
+		return 0, false
+	}
+	return i, true
+}
+
+// The transliterated slug of title, as the server makes with the default
+// SLUG_STRATEGY
+func (s *Server) uniqueSlug(title string) string {
+	return slug.Unique(slug.Make(title), func(v string) bool {
+		return slices.ContainsFunc(s.articles, func(a client.Article) bool { return a.Slug == v })
+	})
+}
+
+func writeData(w http.ResponseWriter, status int, message string, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Message string `json:"message"`
+		Data    any    `json:"data,omitempty"`
+	}{message, data})
+}
+
+// The length and status rules of the real server, for fields that are set
+func checkArticle(title, desc, status string) []client.FieldError {
+	var invalid []client.FieldError
+	if utf8.RuneCountInString(title) > 200 {
+		invalid = append(invalid, client.FieldError{Field: "title", Rule: "max", Message: "title must be at most 200 characters"})
+	}
+	if utf8.RuneCountInString(desc) > 1000 {
+		invalid = append(invalid, client.FieldError{Field: "desc", Rule: "max", Message: "desc must be at most 1000 characters"})
+	}
+	if status != "" && status != client.StatusDraft && status != client.StatusPublished {
+		invalid = append(invalid, client.FieldError{Field: "status", Rule: "oneof", Message: "status must be one of draft, published"})
+	}
+	return invalid
+}
+
+// Write a validation error like the real server does
+func writeValidationError(w http.ResponseWriter, invalid []client.FieldError) {
+	messages := make([]string, len(invalid))
+	for i, f := range invalid {
+		messages[i] = f.Message
+	}
+	writeError(w, http.StatusBadRequest, client.CodeValidationFailed, strings.Join(messages, "; "), invalid)
+}
+
+// Write an error payload like the real server does
+func writeError(w http.ResponseWriter, status int, code, message string, invalid []client.FieldError) {
+	summary := http.StatusText(status)
+	if code == client.CodeValidationFailed {
+		summary = "Validation failed"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Message string              `json:"message"`
+		Error   string              `json:"error"`
+		Code    string              `json:"code"`
+		Fields  []client.FieldError `json:"fields,omitempty"`
+	}{summary, message, code, invalid})
+}
+
+// The code the real server gives errors without one of their own
+func statusCode(status int) string {
+	return strings.ToUpper(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+}
+
+func nonNil(list []client.Article) []client.Article {
+	if list == nil {
+		return []client.Article{}
+	}
+	return list
+}
+
+// Read the request body and put it back for the handler
+func readBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, err
+}
@@ -0,0 +1,132 @@
+package springtest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"go-spring/client"
+	"go-spring/springtest"
+)
+
+func TestServer(t *testing.T) {
+	srv := springtest.NewServer(t,
+		client.Article{Title: "Hello", Desc: "d", Content: "c"},
+		client.Article{ID: 5, Title: "Pinned", Desc: "d", Content: "c", Pinned: true},
+		client.Article{Title: "Draft", Desc: "d", Content: "c", Status: client.StatusDraft},
+	)
+	c := srv.APIClient()
+	ctx := context.Background()
+
+	// Fixtures are numbered after the highest ID, pinned first in the full list
+	page, err := c.ListArticles(ctx, client.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, a := range page.Articles {
+		ids = append(ids, a.ID)
+	}
+	if len(ids) != 3 || ids[0] != 5 || page.NextPageToken != "" {
+		t.Errorf("IDs %v, next %q", ids, page.NextPageToken)
+	}
+	if draft, err := c.Get(ctx, 7); err != nil || draft.Status != client.StatusDraft || !draft.Published.IsZero() {
+		t.Errorf("draft %+v, %v", draft, err)
+	}
+
+	created, err := c.Create(ctx, client.CreateArticleRequest{Title: "Hello", Desc: "d", Content: "c"})
+	if err != nil || created.ID != 8 || created.Slug != "hello-2" || created.Status != client.StatusPublished || created.Published.IsZero() {
+		t.Fatalf("created %+v, %v", created, err)
+	}
+	if got, err := c.GetBySlug(ctx, "hello-2"); err != nil || got.ID != 8 {
+		t.Errorf("by slug %+v, %v", got, err)
+	}
+	pinned := true
+	updated, err := c.Update(ctx, 8, client.UpdateArticleRequest{Title: "Hello again", Pinned: &pinned})
+	if err != nil || updated.Title != "Hello again" || updated.Desc != "d" || !updated.Pinned {
+		t.Errorf("updated %+v, %v", updated, err)
+	}
+	if err := c.Delete(ctx, 6); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, 6); !errors.Is(err, client.ErrNotFound) || client.ErrorCode(err) != client.CodeArticleNotFound {
+		t.Errorf("deleted article: %v", err)
+	}
+
+	// Paging walks every article once
+	var walked []int
+	for a, err := range c.Articles(ctx, 2) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		walked = append(walked, a.ID)
+	}
+	if len(walked) != 3 || walked[0] != 5 || walked[1] != 7 || walked[2] != 8 {
+		t.Errorf("walked %v", walked)
+	}
+	srv.AssertRequestCount(t, "GET", "/articles", 3)
+	if n := len(srv.Articles()); n != 3 {
+		t.Errorf("%d articles stored", n)
+	}
+}
+
+func TestServerValidation(t *testing.T) {
+	srv := springtest.NewServer(t)
+	c := srv.APIClient()
+
+	_, err := c.Create(context.Background(), client.CreateArticleRequest{Title: "t", Status: "archived"})
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || !errors.Is(err, client.ErrBadRequest) || apiErr.Code != client.CodeValidationFailed {
+		t.Fatalf("error %v", err)
+	}
+	var fields []string
+	for _, f := range apiErr.Fields {
+		fields = append(fields, f.Field+":"+f.Rule)
+	}
+	if len(fields) != 3 || fields[0] != "desc:required" || fields[1] != "content:required" || fields[2] != "status:oneof" {
+		t.Errorf("fields %v", fields)
+	}
+	if _, err := c.ListArticles(context.Background(), client.ListOptions{PageToken: "not a token"}); !errors.Is(err, client.ErrBadRequest) {
+		t.Errorf("bad page token: %v", err)
+	}
+
+	// Client errors are not retried
+	srv.AssertRequestCount(t, "POST", "/articles", 1)
+	if len(srv.Articles()) != 0 {
+		t.Error("invalid article stored")
+	}
+}
+
+func TestServerFailures(t *testing.T) {
+	srv := springtest.NewServer(t)
+	c := srv.APIClient()
+
+	// The client retries through an outage, with one Idempotency-Key
+	srv.FailNext(2, http.StatusServiceUnavailable, "down for maintenance")
+	if _, err := c.Create(context.Background(), client.CreateArticleRequest{Title: "t", Desc: "d", Content: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	srv.AssertRequestCount(t, "POST", "/articles", 3)
+	requests := srv.Requests()
+	key := requests[0].Header.Get("Idempotency-Key")
+	for _, r := range requests {
+		if key == "" || r.Header.Get("Idempotency-Key") != key {
+			t.Errorf("Idempotency-Key %q, want %q", r.Header.Get("Idempotency-Key"), key)
+		}
+	}
+	if string(requests[2].Body) != `{"title":"t","desc":"d","content":"c"}` {
+		t.Errorf("body %s", requests[2].Body)
+	}
+
+	// and gives up when the outage lasts
+	srv.FailNext(4, http.StatusTooManyRequests, "slow down")
+	_, err := c.Get(context.Background(), 1)
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || !errors.Is(err, client.ErrRateLimited) || apiErr.Attempts != 4 ||
+		apiErr.Message != "slow down" || apiErr.Code != "TOO_MANY_REQUESTS" {
+		t.Errorf("error %+v", err)
+	}
+	srv.AssertRequestCount(t, "GET", "/articles/1", 4)
+	srv.AssertNotRequested(t, "DELETE", "/articles/1")
+}
@@ -1,195 +1,102 @@
+//go:build ignore
+
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"time"
-)
-
-type Article struct {
-	ID      int       `json:"id"`
-	Title   string    `json:"title"`
-	Desc    string    `json:"desc"`
-	Content string    `json:"content"`
-	Created time.Time `json:"created"`
-	Updated time.Time `json:"updated"`
-}
 
-type Response struct {
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
-}
+	"go-spring/client"
+)
 
 func main() {
-	baseURL := "http://localhost:8080"
-	
+	c := client.New("http://localhost:8080")
+	ctx := context.Background()
+
 	fmt.Println("🚀 Testing Go Spring CRUD API")
 	fmt.Println("===============================")
-	
+
 	// Wait a moment for server to be ready
 	time.Sleep(2 * time.Second)
-	
+
 	// Test 1: GET all articles
 	fmt.Println("\n1️⃣ Testing GET /articles (Get all articles)")
-	resp, err := http.Get(baseURL + "/articles")
+	page, err := c.ListArticles(ctx, client.ListOptions{})
 	if err != nil {
 		fmt.Printf("❌ Error: %v\n", err)
 		return
 	}
-	defer resp.Body.Close()
-	
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Printf("❌ Error reading response: %v\n", err)
-		return
-	}
-	
-	fmt.Printf("✅ Response: %s\n", string(body))
-	
+	fmt.Printf("✅ Response: %s\n", toJSON(page.Articles))
+
 	// Test 2: GET single article
 	fmt.Println("\n2️⃣ Testing GET /articles/1 (Get single article)")
-	resp, err = http.Get(baseURL + "/articles/1")
+	article, err := c.Get(ctx, 1)
 	if err != nil {
 		fmt.Printf("❌ Error: %v\n", err)
 		return
 	}
-	defer resp.Body.Close()
-	
-	body, err = io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Printf("❌ Error reading response: %v\n", err)
-		return
-	}
-	
-	fmt.Printf("✅ Response: %s\n", string(body))
-	
+	fmt.Printf("✅ Response: %s\n", toJSON(article))
+
 	// Test 3: POST new article
 	fmt.Println("\n3️⃣ Testing POST /articles (Create new article)")
-	newArticle := Article{
+	created, err := c.Create(ctx, client.CreateArticleRequest{
 		Title:   "Testing CRUD Operations",
 		Desc:    "A guide to testing REST APIs",
 		Content: "This article demonstrates how to test CRUD operations in a REST API built with Go.",
-	}
-	
-	jsonData, err := json.Marshal(newArticle)
-	if err != nil {
-		fmt.Printf("❌ Error marshaling JSON: %v\n", err)
-		return
-	}
-	
-	resp, err = http.Post(baseURL+"/articles", "application/json", bytes.NewBuffer(jsonData))
+	})
 	if err != nil {
 		fmt.Printf("❌ Error: %v\n", err)
 		return
 	}
-	defer resp.Body.Close()
-	
-	body, err = io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Printf("❌ Error reading response: %v\n", err)
-		return
-	}
-	
-	fmt.Printf("✅ Response: %s\n", string(body))
-	
-	// Parse the response to get the created article ID
-	var createResponse Response
-	err = json.Unmarshal(body, &createResponse)
-	if err != nil {
-		fmt.Printf("❌ Error parsing response: %v\n", err)
-		return
-	}
-	
-	// Get the created article ID
-	articleData, ok := createResponse.Data.(map[string]interface{})
-	if !ok {
-		fmt.Println("❌ Error getting article data")
-		return
-	}
-	
-	articleID := int(articleData["id"].(float64))
-	
+	fmt.Printf("✅ Response: %s\n", toJSON(created))
+
 	// Test 4: PUT update article
-	fmt.Printf("\n4️⃣ Testing PUT /articles/%d (Update article)\n", articleID)
-	updateArticle := Article{
+	fmt.Printf("\n4️⃣ Testing PUT /articles/%d (Update article)\n", created.ID)
+	updated, err := c.Update(ctx, created.ID, client.UpdateArticleRequest{
 		Title:   "Updated: Testing CRUD Operations",
 		Desc:    "An updated guide to testing REST APIs",
 		Content: "This article has been updated to demonstrate how to test CRUD operations in a REST API built with Go.",
-	}
-	
-	jsonData, err = json.Marshal(updateArticle)
-	if err != nil {
-		fmt.Printf("❌ Error marshaling JSON: %v\n", err)
-		return
-	}
-	
-	client := &http.Client{}
-	req, err := http.NewRequest("PUT", fmt.Sprintf("%s/articles/%d", baseURL, articleID), bytes.NewBuffer(jsonData))
-	if err != nil {
-		fmt.Printf("❌ Error creating request: %v\n", err)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	
-	resp, err = client.Do(req)
+	})
 	if err != nil {
 		fmt.Printf("❌ Error: %v\n", err)
 		return
 	}
-	defer resp.Body.Close()
-	
-	body, err = io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Printf("❌ Error reading response: %v\n", err)
-		return
-	}
-	
-	fmt.Printf("✅ Response: %s\n", string(body))
-	
+	fmt.Printf("✅ Response: %s\n", toJSON(updated))
+
 	// Test 5: DELETE article
-	fmt.Printf("\n5️⃣ Testing DELETE /articles/%d (Delete article)\n", articleID)
-	req, err = http.NewRequest("DELETE", fmt.Sprintf("%s/articles/%d", baseURL, articleID), nil)
-	if err != nil {
-		fmt.Printf("❌ Error creating request: %v\n", err)
-		return
-	}
-	
-	resp, err = client.Do(req)
-	if err != nil {
+	fmt.Printf("\n5️⃣ Testing DELETE /articles/%d (Delete article)\n", created.ID)
+	if err := c.Delete(ctx, created.ID); err != nil {
 		fmt.Printf("❌ Error: %v\n", err)
 		return
 	}
-	defer resp.Body.Close()
-	
-	body, err = io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Printf("❌ Error reading response: %v\n", err)
-		return
-	}
-	
-	fmt.Printf("✅ Response: %s\n", string(body))
-	
-	// Test 6: GET all articles again to see final state
-	fmt.Println("\n6️⃣ Final state - GET /articles (Get all articles)")
-	resp, err = http.Get(baseURL + "/articles")
-	if err != nil {
-		fmt.Printf("❌ Error: %v\n", err)
-		return
+	fmt.Println("✅ Deleted")
+
+	// Test 6: GET the deleted article, expecting a typed not found error
+	fmt.Printf("\n6️⃣ Testing GET /articles/%d (Deleted article)\n", created.ID)
+	if _, err := c.Get(ctx, created.ID); errors.Is(err, client.ErrNotFound) {
+		fmt.Printf("✅ Not found as expected: %v\n", err)
+	} else {
+		fmt.Printf("❌ Expected not found, got %v\n", err)
 	}
-	defer resp.Body.Close()
-	
-	body, err = io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Printf("❌ Error reading response: %v\n", err)
-		return
+
+	// Test 7: Page through all articles to see final state
+	fmt.Println("\n7️⃣ Final state - GET /articles?page_size=2 (Paged)")
+	for article, err := range c.Articles(ctx, 2) {
+		if err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			return
+		}
+		fmt.Printf("✅ %d: %s\n", article.ID, article.Title)
 	}
-	
-	fmt.Printf("✅ Response: %s\n", string(body))
-	
+
 	fmt.Println("\n🎉 CRUD API testing completed!")
 	fmt.Println("📁 Data persisted to: articles.gob")
-}
\ No newline at end of file
+}
+
+func toJSON(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}